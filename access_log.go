@@ -0,0 +1,46 @@
+package timber
+
+import (
+	"fmt"
+	"sync/atomic"
+)
+
+// The filter type of access logs, see the httplog package.  A Timber
+// builds these filters only once TakeAccessLogs has been called on it,
+// and then nothing else, so one config file sets up the application's
+// loggers and the access log's, each on its own Timber.  The writer is
+// the one of the filter type in the writer property, file unless it's
+// set, with all of that type's properties; rotation, fallbacks and the
+// rest work as they do for the application's logs.  The defaults of the
+// config don't apply and without a format the record's message, the
+// combined log line, is written.
+const AccessLogType = "accesslog"
+
+// Makes the config loaders of t build the accesslog filters of a config
+// and skip the others; call it before loading the config.  A Timber that
+// hasn't been called skips the accesslog filters.
+func (t *Timber) TakeAccessLogs() {
+	atomic.StoreInt32(&t.accessLogs, 1)
+}
+
+// True if t builds filter when it loads a config
+func (t *Timber) takesFilter(filter JSONFilter) bool {
+	return (filter.Type == AccessLogType) == (atomic.LoadInt32(&t.accessLogs) == 1)
+}
+
+// An accesslog filter as the filter of its writer type that
+// buildFilterLogger makes the logger from
+func accessLogFilter(filter JSONFilter) (JSONFilter, error) {
+	writer := filter.property("writer")
+	if writer == "" {
+		writer = "file"
+	}
+	if _, registered := writerFactory(writer); writer == AccessLogType || (!knownFilterTypes[writer] && !registered) {
+		return filter, fmt.Errorf("TIMBER! Unknown writer %q for accesslog filter", writer)
+	}
+	filter.Type = writer
+	if !filter.hasFormat() {
+		filter.Format = JSONProperty{Name: "pattern", Value: "%M"}
+	}
+	return filter, nil
+}
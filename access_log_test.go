@@ -0,0 +1,48 @@
+package timber
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestAccessLogFilters(t *testing.T) {
+	dir := t.TempDir()
+	appLog, accessLog := filepath.Join(dir, "app.log"), filepath.Join(dir, "access.log")
+	config := fmt.Sprintf(`{"Defaults": {"Level": "WARNING", "Format": {"Name": "pattern", "Value": "%%L %%M"}},
+	"Filters": [
+		{"Enabled": true, "Type": "file", "Properties": [{"Name": "filename", "Value": %q}]},
+		{"Enabled": true, "Type": "accesslog", "Properties": [{"Name": "filename", "Value": %q}]}
+	]}`, appLog, accessLog)
+	app, access := NewTimber(), NewTimber()
+	access.TakeAccessLogs()
+	for _, logger := range []*Timber{app, access} {
+		if err := logger.LoadJSONConfigString(config); err != nil {
+			t.Fatal(err)
+		}
+		// the defaults' WARNING doesn't apply to the access log
+		logger.Info("GET / 200")
+		logger.Warn("careful")
+		logger.Close()
+	}
+	for file, expected := range map[string]string{appLog: "WARN careful\n", accessLog: "GET / 200\ncareful\n"} {
+		if got, _ := os.ReadFile(file); string(got) != expected {
+			t.Errorf("%s: expected %q got %q", filepath.Base(file), expected, got)
+		}
+	}
+
+	bad := `{"Filters": [{"Enabled": true, "Type": "accesslog", "Properties": [{"Name": "writer", "Value": "carrier-pigeon"}]}]}`
+	access = NewTimber()
+	access.TakeAccessLogs()
+	if err := access.LoadJSONConfigString(bad); err == nil {
+		t.Errorf("expected an error for an unknown writer")
+	}
+	access.Close()
+	if err := NewTimber().LoadJSONConfigString(bad); err != nil {
+		t.Errorf("expected the application's Timber to skip the accesslog filter, got %v", err)
+	}
+	if _, err := validateConfig(JSONConfig{Filters: []JSONFilter{{Enabled: true, Type: AccessLogType}}}); err == nil {
+		t.Errorf("expected an error for an accesslog file without a filename")
+	}
+}
@@ -0,0 +1,144 @@
+// Package admin provides an http.Handler for looking at and changing a
+// timber logger's levels while it runs.  It's a separate package so only
+// applications that want it carry net/http.  Mount it on a debug mux
+// next to expvar and pprof:
+//
+//	http.Handle("/debug/timber", admin.Handler(nil))
+//
+// GET returns the loggers, by index, with their levels and granulars:
+//
+//	{"loggers": [{"index": 0, "tag": "file", "level": "INFO",
+//	              "granulars": {"github.com/me/app/db": "DEBUG"}}]}
+//
+// PUT takes the same shape with only what should change.  A logger's
+// level is picked by index (as Timber.SetLevel does) and granulars are
+// set on every logger (as Timber.SetGranular does):
+//
+//	{"loggers": [{"index": 0, "level": "DEBUG"}],
+//	 "granulars": {"github.com/me/app/api": "FINE"}}
+//
+// Nothing is changed unless the whole request is valid.  The response to
+// a PUT is the state after the change.
+package admin
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/smw1218/timber"
+)
+
+// The most a PUT body can be
+const maxBody = 1 << 20
+
+// One logger in a GET response or a PUT request; the granulars are only
+// in responses since a PUT sets them on every logger
+type Logger struct {
+	Index     int               `json:"index"`
+	Tag       string            `json:"tag,omitempty"`
+	Level     string            `json:"level"`
+	Granulars map[string]string `json:"granulars,omitempty"`
+}
+
+// The body of a GET response or a PUT request
+type Levels struct {
+	Loggers   []Logger          `json:"loggers"`
+	Granulars map[string]string `json:"granulars,omitempty"`
+}
+
+// Serves the levels of t, the Global logger if t is nil
+func Handler(t *timber.Timber) http.Handler {
+	return handler{t}
+}
+
+type handler struct {
+	t *timber.Timber
+}
+
+func (h handler) timber() *timber.Timber {
+	if h.t == nil {
+		return timber.GetGlobal()
+	}
+	return h.t
+}
+
+func (h handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet, http.MethodHead:
+	case http.MethodPut:
+		if err := h.put(w, r); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	default:
+		w.Header().Set("Allow", "GET, HEAD, PUT")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(current(h.timber()))
+}
+
+// Checks the whole request before changing anything
+func (h handler) put(w http.ResponseWriter, r *http.Request) error {
+	var req Levels
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxBody)).Decode(&req); err != nil {
+		return fmt.Errorf("TIMBER! Can't parse levels: %v", err)
+	}
+	t := h.timber()
+	count := len(t.Levels())
+	levels := make([]timber.Level, len(req.Loggers))
+	for i, logger := range req.Loggers {
+		if logger.Index < 0 || logger.Index >= count {
+			return fmt.Errorf("TIMBER! No logger at index %d", logger.Index)
+		}
+		lvl, err := timber.ParseLevel(logger.Level)
+		if err != nil {
+			return fmt.Errorf("%v for logger %d", err, logger.Index)
+		}
+		levels[i] = lvl
+	}
+	granulars := make(map[string]timber.Level, len(req.Granulars))
+	for path, lvlString := range req.Granulars {
+		lvl, err := timber.ParseLevel(lvlString)
+		if err != nil {
+			return fmt.Errorf("%v for granular %s", err, path)
+		}
+		if err := timber.CheckGranularPath(path); err != nil {
+			return err
+		}
+		granulars[path] = lvl
+	}
+	for i, logger := range req.Loggers {
+		t.SetLevel(logger.Index, levels[i])
+	}
+	for path, lvl := range granulars {
+		if err := t.SetGranular(path, lvl); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func current(t *timber.Timber) Levels {
+	levels := Levels{Loggers: []Logger{}}
+	for i, l := range t.Levels() {
+		logger := Logger{Index: i, Tag: l.Tag, Level: levelName(l.Level)}
+		if len(l.Granulars) > 0 {
+			logger.Granulars = make(map[string]string, len(l.Granulars))
+			for path, lvl := range l.Granulars {
+				logger.Granulars[path] = levelName(lvl)
+			}
+		}
+		levels.Loggers = append(levels.Loggers, logger)
+	}
+	return levels
+}
+
+func levelName(lvl timber.Level) string {
+	if int(lvl) < len(timber.LongLevelStrings) {
+		return timber.LongLevelStrings[lvl]
+	}
+	return fmt.Sprint(int(lvl))
+}
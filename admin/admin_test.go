@@ -0,0 +1,64 @@
+package admin
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/smw1218/timber"
+)
+
+func do(t *testing.T, h http.Handler, method, body string) (int, string) {
+	req := httptest.NewRequest(method, "/debug/timber", strings.NewReader(body))
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	return rec.Code, strings.TrimSpace(rec.Body.String())
+}
+
+func TestHandler(t *testing.T) {
+	log := timber.NewTimber()
+	defer log.Close()
+	log.AddLogger(timber.ConfigLogger{LogWriter: timber.NullWriter{}, Level: timber.INFO,
+		Formatter: timber.NewPatFormatter("%M"), Tag: "null",
+		Granulars: map[string]timber.Level{"github.com/me/app/db": timber.DEBUG}})
+	h := Handler(log)
+
+	code, body := do(t, h, "GET", "")
+	expected := `{"loggers":[{"index":0,"tag":"null","level":"INFO","granulars":{"github.com/me/app/db":"DEBUG"}}]}`
+	if code != http.StatusOK || body != expected {
+		t.Errorf("expected %s got %d %s", expected, code, body)
+	}
+
+	code, body = do(t, h, "PUT", `{"loggers": [{"index": 0, "level": "debug"}], "granulars": {"github.com/me/app/api": "FINE"}}`)
+	expected = `{"loggers":[{"index":0,"tag":"null","level":"DEBUG","granulars":{"github.com/me/app/api":"FINE","github.com/me/app/db":"DEBUG"}}]}`
+	if code != http.StatusOK || body != expected {
+		t.Errorf("expected %s got %d %s", expected, code, body)
+	}
+
+	// nothing changes when part of the request is bad
+	for _, bad := range []string{
+		`{"loggers": [{"index": 0, "level": "INFO"}, {"index": 3, "level": "INFO"}]}`,
+		`{"loggers": [{"index": 0, "level": "INFO"}], "granulars": {"x": "LOUD"}}`,
+		`{"loggers": [{"index": 0, "level": "INFO"}], "granulars": {"re:(": "FINE"}}`,
+		`{not json`,
+	} {
+		if code, body = do(t, h, "PUT", bad); code != http.StatusBadRequest {
+			t.Errorf("expected a bad request for %s got %d %s", bad, code, body)
+		}
+	}
+	if levels := log.Levels(); levels[0].Level != timber.DEBUG || len(levels[0].Granulars) != 2 {
+		t.Errorf("expected a bad request to change nothing, got %v", levels)
+	}
+
+	if code, _ = do(t, h, "DELETE", ""); code != http.StatusMethodNotAllowed {
+		t.Errorf("expected method not allowed got %d", code)
+	}
+}
+
+func TestHandlerGlobal(t *testing.T) {
+	// nothing in this package adds to the Global logger
+	if code, body := do(t, Handler(nil), "GET", ""); code != http.StatusOK || body != `{"loggers":[]}` {
+		t.Errorf("expected the Global logger's empty list, got %d %s", code, body)
+	}
+}
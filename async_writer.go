@@ -0,0 +1,305 @@
+package timber
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// From an AsyncWriter's LogWriteChecked when OverflowDrop throws the
+// record away
+var ErrQueueFull = errors.New("TIMBER! Writer queue is full")
+
+// What an AsyncWriter does with a message when its queue is full
+type OverflowPolicy int
+
+const (
+	OverflowBlock      OverflowPolicy = iota // wait for room, slowing down the logger
+	OverflowDrop                             // throw the new message away and count it
+	OverflowDropOldest                       // make room by throwing away the oldest queued message
+)
+
+// Called with the queue depth and capacity when a water mark is crossed
+type WaterMarkFunc func(depth, capacity int)
+
+// Puts a queue in front of a slow writer so a stalled socket or disk
+// doesn't hold up the logger.  The child writer is only ever called from
+// the AsyncWriter's own goroutine.
+type AsyncWriter struct {
+	writer  LogWriter
+	queue   chan asyncMsg
+	policy  OverflowPolicy
+	dropped uint64 // accessed atomically
+	done    chan bool
+
+	// closed is set by Close under the write lock, the sends to queue
+	// hold the read lock so none can race the close of the channel
+	closeMu sync.RWMutex
+	closed  bool
+
+	// water marks, see SetWaterMarks
+	highMark, lowMark int
+	onHigh, onLow     WaterMarkFunc
+	interval          time.Duration
+	aboveHigh         int32 // accessed atomically
+	lastHigh          int64 // unix nanos, accessed atomically
+}
+
+type asyncMsg struct {
+	rec   *LogRecord
+	msg   string
+	flush chan bool
+}
+
+// Queues up to size messages for writer.  OverflowDropOldest needs a
+// queue to drop from, so with it a size below 1 is 1.
+func NewAsyncWriter(writer LogWriter, size int, policy OverflowPolicy) *AsyncWriter {
+	return NewAsyncWriterContext(context.Background(), writer, size, policy)
+}
+
+// Same as NewAsyncWriter but cancelling ctx shuts the writer down as if
+// Close had been called: what's queued is written and the child is
+// closed.  Messages logged after that are dropped and counted.  Close is
+// still safe to call and waits for the drain.
+func NewAsyncWriterContext(ctx context.Context, writer LogWriter, size int, policy OverflowPolicy) *AsyncWriter {
+	if policy == OverflowDropOldest && size < 1 {
+		// unbuffered, replaceOldest would spin while the child blocks
+		size = 1
+	}
+	aw := &AsyncWriter{
+		writer: writer,
+		queue:  make(chan asyncMsg, size),
+		policy: policy,
+		done:   make(chan bool),
+	}
+	go aw.writeLoop(ctx)
+	return aw
+}
+
+// Calls onHigh once the queue is at least high (a fraction of capacity)
+// full and onLow once it drains back down to low.  The callbacks alternate
+// so onLow only follows an onHigh, and onHigh fires at most once per
+// interval.  They run on their own goroutine so they never hold up the
+// logger.  Either may be nil.  Call it before the writer is used.
+func (aw *AsyncWriter) SetWaterMarks(high, low float64, interval time.Duration, onHigh, onLow WaterMarkFunc) {
+	capacity := float64(cap(aw.queue))
+	aw.highMark = int(high * capacity)
+	if aw.highMark < 1 {
+		aw.highMark = 1
+	}
+	aw.lowMark = int(low * capacity)
+	aw.interval = interval
+	aw.onHigh, aw.onLow = onHigh, onLow
+}
+
+// Number of messages thrown away by OverflowDrop or OverflowDropOldest or
+// because they came after the context of NewAsyncWriterContext was
+// cancelled
+func (aw *AsyncWriter) Dropped() uint64 {
+	return atomic.LoadUint64(&aw.dropped)
+}
+
+func (aw *AsyncWriter) LogWrite(msg string) {
+	aw.enqueue(asyncMsg{msg: msg})
+}
+
+// RecordWriter interface, the record is passed on if the child wants it
+func (aw *AsyncWriter) LogWriteRecord(rec *LogRecord, msg string) {
+	aw.enqueue(asyncMsg{rec: rec, msg: msg})
+}
+
+// SourceUser interface
+func (aw *AsyncWriter) UsesSource() bool {
+	return writerUsesSource(aw.writer)
+}
+
+// CheckedWriter interface, ErrQueueFull when OverflowDrop throws the
+// record away.  A queued record may still fail in the child.
+func (aw *AsyncWriter) LogWriteChecked(rec *LogRecord, msg string) error {
+	if !aw.enqueue(asyncMsg{rec: rec, msg: msg}) {
+		return ErrQueueFull
+	}
+	return nil
+}
+
+// false if am was dropped
+func (aw *AsyncWriter) enqueue(am asyncMsg) bool {
+	aw.closeMu.RLock()
+	defer aw.closeMu.RUnlock()
+	if aw.closed {
+		atomic.AddUint64(&aw.dropped, 1)
+		return false
+	}
+	select {
+	case <-aw.done:
+		// stopped by the context, nobody will read it
+		atomic.AddUint64(&aw.dropped, 1)
+		return false
+	default:
+	}
+	queued := true
+	switch aw.policy {
+	case OverflowDrop:
+		select {
+		case aw.queue <- am:
+		default:
+			atomic.AddUint64(&aw.dropped, 1)
+			queued = false
+		}
+	case OverflowDropOldest:
+		aw.replaceOldest(am)
+	default:
+		select {
+		case aw.queue <- am:
+		case <-aw.done:
+			atomic.AddUint64(&aw.dropped, 1)
+			queued = false
+		}
+	}
+	aw.checkHigh()
+	return queued
+}
+
+// Queues am, throwing away the oldest messages until there's room.  A
+// Flush waiting in the queue isn't thrown away, it goes back in line
+// after am.
+func (aw *AsyncWriter) replaceOldest(am asyncMsg) {
+	var flushes []asyncMsg
+	for sent := false; !sent; {
+		select {
+		case aw.queue <- am:
+			sent = true
+		default:
+			select {
+			case old := <-aw.queue:
+				if old.flush != nil {
+					flushes = append(flushes, old)
+				} else {
+					atomic.AddUint64(&aw.dropped, 1)
+				}
+			default:
+			}
+		}
+	}
+	for _, flush := range flushes {
+		select {
+		case aw.queue <- flush:
+		case <-aw.done:
+		}
+	}
+}
+
+func (aw *AsyncWriter) checkHigh() {
+	if aw.onHigh == nil && aw.onLow == nil {
+		return
+	}
+	depth := len(aw.queue)
+	if depth < aw.highMark || atomic.LoadInt32(&aw.aboveHigh) == 1 {
+		return
+	}
+	now := time.Now().UnixNano()
+	if now-atomic.LoadInt64(&aw.lastHigh) < int64(aw.interval) {
+		return
+	}
+	if atomic.CompareAndSwapInt32(&aw.aboveHigh, 0, 1) {
+		atomic.StoreInt64(&aw.lastHigh, now)
+		if aw.onHigh != nil {
+			go aw.onHigh(depth, cap(aw.queue))
+		}
+	}
+}
+
+func (aw *AsyncWriter) checkLow() {
+	depth := len(aw.queue)
+	if depth > aw.lowMark || atomic.LoadInt32(&aw.aboveHigh) == 0 {
+		return
+	}
+	if atomic.CompareAndSwapInt32(&aw.aboveHigh, 1, 0) && aw.onLow != nil {
+		go aw.onLow(depth, cap(aw.queue))
+	}
+}
+
+func (aw *AsyncWriter) writeLoop(ctx context.Context) {
+	defer func() {
+		aw.writer.Close()
+		close(aw.done)
+	}()
+	for {
+		select {
+		case am, ok := <-aw.queue:
+			if !ok {
+				return
+			}
+			aw.write(am)
+		case <-ctx.Done():
+			aw.drain()
+			return
+		}
+	}
+}
+
+// writes what's already queued without waiting for more
+func (aw *AsyncWriter) drain() {
+	for {
+		select {
+		case am, ok := <-aw.queue:
+			if !ok {
+				return
+			}
+			aw.write(am)
+		default:
+			return
+		}
+	}
+}
+
+func (aw *AsyncWriter) write(am asyncMsg) {
+	aw.checkLow()
+	switch {
+	case am.flush != nil:
+		if fl, ok := aw.writer.(Flusher); ok {
+			fl.Flush()
+		}
+		am.flush <- true
+	case am.rec != nil:
+		writeRecord(aw.writer, am.rec, am.msg)
+	default:
+		aw.writer.LogWrite(am.msg)
+	}
+}
+
+// Blocks until everything queued so far has been written and the child
+// flushed, if it's a Flusher
+func (aw *AsyncWriter) Flush() {
+	flushed := make(chan bool, 1)
+	aw.closeMu.RLock()
+	if aw.closed {
+		aw.closeMu.RUnlock()
+		return
+	}
+	select {
+	case aw.queue <- asyncMsg{flush: flushed}:
+	case <-aw.done:
+		aw.closeMu.RUnlock()
+		return
+	}
+	aw.closeMu.RUnlock()
+	select {
+	case <-flushed:
+	case <-aw.done:
+	}
+}
+
+// Writes out whatever is still queued, then closes the child.  Messages
+// after that are dropped and counted.
+func (aw *AsyncWriter) Close() {
+	aw.closeMu.Lock()
+	if !aw.closed {
+		aw.closed = true
+		close(aw.queue)
+	}
+	aw.closeMu.Unlock()
+	<-aw.done
+}
@@ -0,0 +1,156 @@
+package timber
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// blocks every write until released
+type stallWriter struct {
+	release chan bool
+	mw      *MemoryWriter
+}
+
+func (sw *stallWriter) LogWrite(msg string) {
+	<-sw.release
+	sw.mw.LogWrite(msg)
+}
+
+func (sw *stallWriter) Close() {}
+
+func TestAsyncWriterDrop(t *testing.T) {
+	sw := &stallWriter{release: make(chan bool), mw: NewMemoryWriter(10)}
+	aw := NewAsyncWriter(sw, 2, OverflowDrop)
+	aw.LogWrite("a")
+	time.Sleep(10 * time.Millisecond) // let the loop pick up a and stall
+	for _, msg := range []string{"b", "c", "d"} {
+		aw.LogWrite(msg)
+	}
+	if n := aw.Dropped(); n != 1 {
+		t.Errorf("expected 1 dropped got %d", n)
+	}
+	close(sw.release)
+	aw.Close()
+	if got := sw.mw.Messages(); !reflect.DeepEqual(got, []string{"a", "b", "c"}) {
+		t.Errorf("expected a b c got %q", got)
+	}
+}
+
+func TestAsyncWriterDropOldest(t *testing.T) {
+	sw := &stallWriter{release: make(chan bool), mw: NewMemoryWriter(10)}
+	aw := NewAsyncWriter(sw, 2, OverflowDropOldest)
+	aw.LogWrite("a")
+	time.Sleep(10 * time.Millisecond) // let the loop pick up a and stall
+	for _, msg := range []string{"b", "c", "d", "e"} {
+		aw.LogWrite(msg)
+	}
+	if n := aw.Dropped(); n != 2 {
+		t.Errorf("expected 2 dropped got %d", n)
+	}
+	close(sw.release)
+	aw.Close()
+	if got := sw.mw.Messages(); !reflect.DeepEqual(got, []string{"a", "d", "e"}) {
+		t.Errorf("expected a d e got %q", got)
+	}
+}
+
+func TestAsyncWriterDropOldestUnbuffered(t *testing.T) {
+	sw := &stallWriter{release: make(chan bool), mw: NewMemoryWriter(10)}
+	aw := NewAsyncWriter(sw, 0, OverflowDropOldest)
+	aw.LogWrite("a")
+	time.Sleep(10 * time.Millisecond) // let the loop pick up a and stall
+	done := make(chan bool)
+	go func() {
+		defer close(done)
+		aw.LogWrite("b")
+		aw.LogWrite("c")
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("expected the writes to drop instead of spinning")
+	}
+	close(sw.release)
+	aw.Close()
+	if got := sw.mw.Messages(); !reflect.DeepEqual(got, []string{"a", "c"}) || aw.Dropped() != 1 {
+		t.Errorf("expected a c and 1 dropped got %q and %d", got, aw.Dropped())
+	}
+}
+
+func TestAsyncWriterWaterMarks(t *testing.T) {
+	sw := &stallWriter{release: make(chan bool), mw: NewMemoryWriter(10)}
+	aw := NewAsyncWriter(sw, 4, OverflowBlock)
+	highs, lows := make(chan int, 4), make(chan int, 4)
+	aw.SetWaterMarks(0.75, 0.25, time.Millisecond,
+		func(depth, capacity int) { highs <- depth },
+		func(depth, capacity int) { lows <- depth })
+	for _, msg := range []string{"a", "b", "c", "d"} {
+		aw.LogWrite(msg)
+	}
+	select {
+	case depth := <-highs:
+		if depth < 3 {
+			t.Errorf("high water fired at depth %d", depth)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("high water never fired")
+	}
+	close(sw.release)
+	aw.Flush()
+	select {
+	case depth := <-lows:
+		if depth > 1 {
+			t.Errorf("low water fired at depth %d", depth)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("low water never fired")
+	}
+	aw.Close()
+	if len(highs) != 0 {
+		t.Error("high water fired more than once")
+	}
+}
+
+func TestAsyncWriterContext(t *testing.T) {
+	sw := &stallWriter{release: make(chan bool), mw: NewMemoryWriter(10)}
+	ctx, cancel := context.WithCancel(context.Background())
+	aw := NewAsyncWriterContext(ctx, sw, 4, OverflowBlock)
+	aw.LogWrite("a")
+	aw.LogWrite("b")
+	close(sw.release)
+	cancel()
+	select {
+	case <-aw.done:
+	case <-time.After(time.Second):
+		t.Fatal("cancelling the context didn't stop the writer")
+	}
+	aw.LogWrite("late")
+	aw.Flush()
+	aw.Close()
+	if got := sw.mw.Messages(); !reflect.DeepEqual(got, []string{"a", "b"}) {
+		t.Errorf("expected a b got %q", got)
+	}
+	if n := aw.Dropped(); n != 1 {
+		t.Errorf("expected the late message dropped got %d", n)
+	}
+}
+
+func TestAsyncWriterAfterClose(t *testing.T) {
+	for _, policy := range []OverflowPolicy{OverflowBlock, OverflowDrop, OverflowDropOldest} {
+		mw := NewMemoryWriter(10)
+		aw := NewAsyncWriter(mw, 2, policy)
+		aw.LogWrite("a")
+		aw.Close()
+		aw.LogWrite("late")
+		if err := aw.LogWriteChecked(&LogRecord{}, "checked"); err == nil {
+			t.Errorf("policy %d: expected an error after Close", policy)
+		}
+		aw.Flush()
+		aw.Close()
+		if got := mw.Messages(); !reflect.DeepEqual(got, []string{"a"}) || aw.Dropped() != 2 {
+			t.Errorf("policy %d: expected a and 2 dropped got %q and %d", policy, got, aw.Dropped())
+		}
+	}
+}
@@ -0,0 +1,185 @@
+package timber
+
+import (
+	"bufio"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// What an AuditWriter puts between a record and its sequence number and MAC
+const auditMarker = " audit="
+
+// Makes a log tamper-evident for security events: each record is written
+// as one line ending in " audit=SEQ:MAC", SEQ counting from 1 and MAC an
+// HMAC-SHA256 with key over the previous record's MAC, SEQ and the line.
+// Changing, removing or reordering a line breaks the chain from there on,
+// which VerifyAuditLog finds; control characters in the message are
+// escaped so a record can't pass for two lines.  A chain can only be
+// checked from its first record, so concatenate rotated files in order.
+// Records cut off the end of the log leave a valid chain, compare the
+// last sequence number with one kept somewhere else to catch that.
+//
+// The audit_key property of a filter puts one in front of its writer; a
+// file filter continues the chain already in the file.  Records reach the
+// child already formatted, a StructuredWriter gets them with LogWrite.
+type AuditWriter struct {
+	writer LogWriter
+	key    []byte
+
+	mu  sync.Mutex
+	seq uint64
+	mac []byte // of the last record, nil before the first
+}
+
+func NewAuditWriter(writer LogWriter, key []byte) *AuditWriter {
+	return &AuditWriter{writer: writer, key: append([]byte(nil), key...)}
+}
+
+// Continues the chain of a log written before, e.g. by the last run of
+// the program, from its last complete line.  The log isn't checked, run
+// VerifyAuditLog for that.  Call it before the writer is used.
+func (aw *AuditWriter) Resume(r io.Reader) error {
+	var seq uint64
+	var mac []byte
+	err := readAuditLines(r, func(line string, complete bool) error {
+		if _, s, m, ok := parseAuditLine(line); ok && complete {
+			seq, mac = s, m
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("TIMBER! Can't resume the audit log: %v", err)
+	}
+	aw.mu.Lock()
+	aw.seq, aw.mac = seq, mac
+	aw.mu.Unlock()
+	return nil
+}
+
+// The sequence number of the last record written, 0 before the first
+func (aw *AuditWriter) Seq() uint64 {
+	aw.mu.Lock()
+	defer aw.mu.Unlock()
+	return aw.seq
+}
+
+func (aw *AuditWriter) LogWrite(msg string) {
+	aw.LogWriteRecord(&LogRecord{Level: INFO, Message: msg, Timestamp: currentTime(), raw: true}, msg)
+}
+
+// RecordWriter interface, the record is passed on if the child wants it
+func (aw *AuditWriter) LogWriteRecord(rec *LogRecord, msg string) {
+	aw.mu.Lock()
+	defer aw.mu.Unlock()
+	payload := escapeControl(strings.TrimRight(msg, "\n"))
+	aw.seq++
+	aw.mac = auditMAC(aw.key, aw.mac, aw.seq, payload)
+	line := payload + auditMarker + strconv.FormatUint(aw.seq, 10) + ":" + hex.EncodeToString(aw.mac)
+	if rw, ok := aw.writer.(RecordWriter); ok {
+		rw.LogWriteRecord(rec, line)
+	} else {
+		aw.writer.LogWrite(line)
+	}
+}
+
+func auditMAC(key, prev []byte, seq uint64, payload string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write(prev)
+	mac.Write(strconv.AppendUint(nil, seq, 10))
+	mac.Write([]byte{':'})
+	mac.Write([]byte(payload))
+	return mac.Sum(nil)
+}
+
+// Splits a line into the record, its sequence number and MAC
+func parseAuditLine(line string) (string, uint64, []byte, bool) {
+	i := strings.LastIndex(line, auditMarker)
+	if i < 0 {
+		return "", 0, nil, false
+	}
+	seqStr, macStr, ok := strings.Cut(line[i+len(auditMarker):], ":")
+	if !ok {
+		return "", 0, nil, false
+	}
+	seq, err := strconv.ParseUint(seqStr, 10, 64)
+	if err != nil {
+		return "", 0, nil, false
+	}
+	mac, err := hex.DecodeString(macStr)
+	if err != nil || len(mac) != sha256.Size {
+		return "", 0, nil, false
+	}
+	return line[:i], seq, mac, true
+}
+
+// Calls fn with each line of r without its newline, complete is false for
+// a last line that has none
+func readAuditLines(r io.Reader, fn func(line string, complete bool) error) error {
+	reader := bufio.NewReader(r)
+	for {
+		line, err := reader.ReadString('\n')
+		if len(line) > 0 {
+			complete := strings.HasSuffix(line, "\n")
+			if ferr := fn(strings.TrimSuffix(strings.TrimSuffix(line, "\n"), "\r"), complete); ferr != nil {
+				return ferr
+			}
+		}
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// Checks a log an AuditWriter wrote with key, from its first record.
+// Returns the sequence number of the last record that checked out, so a
+// caller that keeps it elsewhere can tell the log was cut short, and an
+// error naming the first line that was changed, removed, reordered or
+// isn't a record.
+func VerifyAuditLog(r io.Reader, key []byte) (uint64, error) {
+	var seq uint64
+	var prev []byte
+	lineNo := 0
+	err := readAuditLines(r, func(line string, complete bool) error {
+		lineNo++
+		payload, lineSeq, mac, ok := parseAuditLine(line)
+		switch {
+		case !ok:
+			return fmt.Errorf("TIMBER! Audit log line %d isn't an audit record", lineNo)
+		case !complete:
+			return fmt.Errorf("TIMBER! Audit log line %d is cut short", lineNo)
+		case lineSeq != seq+1:
+			return fmt.Errorf("TIMBER! Audit log line %d is record %d, expected %d", lineNo, lineSeq, seq+1)
+		case !hmac.Equal(mac, auditMAC(key, prev, lineSeq, payload)):
+			return fmt.Errorf("TIMBER! Audit log line %d (record %d) was modified", lineNo, lineSeq)
+		}
+		seq, prev = lineSeq, mac
+		return nil
+	})
+	return seq, err
+}
+
+func (aw *AuditWriter) Flush() {
+	if fl, ok := aw.writer.(Flusher); ok {
+		fl.Flush()
+	}
+}
+
+func (aw *AuditWriter) Reopen() error {
+	if ro, ok := aw.writer.(Reopener); ok {
+		return ro.Reopen()
+	}
+	return nil
+}
+
+func (aw *AuditWriter) Close() {
+	aw.writer.Close()
+}
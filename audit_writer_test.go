@@ -0,0 +1,83 @@
+package timber
+
+import (
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestAuditWriter(t *testing.T) {
+	key := []byte("s3cret")
+	mw := NewMemoryWriter(10)
+	aw := NewAuditWriter(mw, key)
+	for _, msg := range []string{"login alice", "sudo alice\nfake audit=9:00", "logout alice"} {
+		aw.LogWriteRecord(&LogRecord{Level: INFO, Message: msg}, msg)
+	}
+	lines := mw.Messages()
+	if len(lines) != 3 || !strings.HasPrefix(lines[1], `sudo alice\nfake audit=9:00 audit=2:`) {
+		t.Fatalf("unexpected lines %q", lines)
+	}
+	log := strings.Join(lines, "\n") + "\n"
+	if last, err := VerifyAuditLog(strings.NewReader(log), key); err != nil || last != 3 {
+		t.Errorf("expected a good log of 3 got %d %v", last, err)
+	}
+	if aw.Seq() != 3 {
+		t.Errorf("expected seq 3 got %d", aw.Seq())
+	}
+
+	for name, tampered := range map[string]string{
+		"modified":  strings.Replace(log, "alice", "bob", 1),
+		"removed":   lines[0] + "\n" + lines[2] + "\n",
+		"reordered": lines[1] + "\n" + lines[0] + "\n" + lines[2] + "\n",
+		"cut short": log[:len(log)-1],
+		"inserted":  lines[0] + "\njust a line\n" + lines[1] + "\n",
+	} {
+		if _, err := VerifyAuditLog(strings.NewReader(tampered), key); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+	if _, err := VerifyAuditLog(strings.NewReader(log), []byte("guess")); err == nil {
+		t.Error("expected an error with the wrong key")
+	}
+
+	// a new writer carries on from the last run's log
+	mw2 := NewMemoryWriter(10)
+	aw2 := NewAuditWriter(mw2, key)
+	if err := aw2.Resume(strings.NewReader(log)); err != nil {
+		t.Fatal(err)
+	}
+	aw2.LogWrite("restarted")
+	log += mw2.Messages()[0] + "\n"
+	if last, err := VerifyAuditLog(strings.NewReader(log), key); err != nil || last != 4 {
+		t.Errorf("expected a good log of 4 got %d %v", last, err)
+	}
+}
+
+func TestAuditConfig(t *testing.T) {
+	name := t.TempDir() + "/audit.log"
+	filter := JSONFilter{Enabled: true, Type: "file", Level: "INFO", Format: JSONProperty{"pattern", "%L %M"},
+		Properties: []JSONProperty{{"filename", name}, {"audit_key", "k"}}}
+	for run := 0; run < 2; run++ {
+		cLog, _, err := buildLogger(nil, filter)
+		if err != nil {
+			t.Fatal(err)
+		}
+		aw, ok := cLog.LogWriter.(*AuditWriter)
+		if !ok {
+			t.Fatalf("expected an AuditWriter got %T", cLog.LogWriter)
+		}
+		if aw.Seq() != uint64(run) {
+			t.Errorf("run %d: expected to resume at %d got %d", run, run, aw.Seq())
+		}
+		sendToLoggers([]ConfigLogger{cLog}, &LogRecord{Level: WARNING, Message: "event"})
+		cLog.LogWriter.Close()
+	}
+	file, err := os.Open(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	if last, err := VerifyAuditLog(file, []byte("k")); err != nil || last != 2 {
+		t.Errorf("expected a good log of 2 got %d %v", last, err)
+	}
+}
@@ -0,0 +1,52 @@
+package timber
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// Flush triggers shared by the writers that send records in batches.  A
+// batch goes out before it would pass Count records or Bytes bytes,
+// whichever comes first, and at least every Interval.  Zero Count or Bytes
+// means no limit of that kind.
+type BatchConfig struct {
+	Count    int
+	Bytes    int
+	Interval time.Duration
+}
+
+// Reports whether a batch of count records adding up to bytes is within
+// the limits
+func (bc BatchConfig) Fits(count, bytes int) bool {
+	return (bc.Count <= 0 || count <= bc.Count) && (bc.Bytes <= 0 || bytes <= bc.Bytes)
+}
+
+// Reads the batch_count (records), batch_bytes (e.g. 512KB) and
+// flush_interval (a duration) properties, keeping the writer's defaults for
+// any that aren't set
+func (filter JSONFilter) BatchConfig(defaults BatchConfig) (BatchConfig, error) {
+	bc := defaults
+	if count := filter.property("batch_count"); count != "" {
+		n, err := strconv.Atoi(count)
+		if err != nil || n < 1 {
+			return bc, fmt.Errorf("TIMBER! Bad batch_count %q, expected a number of records", count)
+		}
+		bc.Count = n
+	}
+	if bytes := filter.property("batch_bytes"); bytes != "" {
+		n, err := parseSize(bytes)
+		if err != nil {
+			return bc, fmt.Errorf("TIMBER! Bad batch_bytes %q", bytes)
+		}
+		bc.Bytes = int(n)
+	}
+	if interval := filter.property("flush_interval"); interval != "" {
+		d, err := time.ParseDuration(interval)
+		if err != nil || d <= 0 {
+			return bc, fmt.Errorf("TIMBER! Bad flush_interval %q", interval)
+		}
+		bc.Interval = d
+	}
+	return bc, nil
+}
@@ -0,0 +1,195 @@
+package timber
+
+import (
+	"bytes"
+	"compress/gzip"
+	"sync"
+	"time"
+)
+
+// The limits of a BatchingWriter made with a zero BatchConfig Interval,
+// and of the batch filter property for the ones that aren't set
+var DefaultBatch = BatchConfig{Count: 1000, Bytes: 1 << 20, Interval: time.Second}
+
+// Collects formatted messages and hands them to the writer as one
+// LogWrite, so a remote writer sends one packet or request where it
+// would have sent hundreds.  A batch goes out when the BatchConfig says
+// so, on Flush and on Close.  The messages of a batch are joined by
+// newlines, the writer ends the last one the way it ends any message.
+// With SetGzip the batch is compressed instead, each one a gzip member
+// of its own with every message on a line, so the writer has to pass it
+// on untouched: a socket wants framing none.  The records themselves
+// aren't passed on, so a writer that looks at them (the level, a key
+// field) sees plain messages.
+type BatchingWriter struct {
+	writer LogWriter
+	limits BatchConfig
+	gzip   bool
+
+	mu     sync.Mutex
+	buf    bytes.Buffer
+	count  int
+	zbuf   bytes.Buffer
+	zw     *gzip.Writer
+	stats  BatchStats
+	closed bool
+	ticker *time.Ticker
+	stop   chan bool
+}
+
+// What a BatchingWriter has sent so far
+type BatchStats struct {
+	Batches    uint64
+	Records    uint64
+	Bytes      uint64 // of the messages, before compression
+	Sent       uint64 // handed to the writer, after compression
+	MaxRecords int    // in one batch
+	MaxBytes   int    // of one batch before compression
+}
+
+// Records per batch on average
+func (bs BatchStats) AvgRecords() float64 {
+	if bs.Batches == 0 {
+		return 0
+	}
+	return float64(bs.Records) / float64(bs.Batches)
+}
+
+// Sent as a fraction of Bytes, 1 without compression
+func (bs BatchStats) Ratio() float64 {
+	if bs.Bytes == 0 {
+		return 1
+	}
+	return float64(bs.Sent) / float64(bs.Bytes)
+}
+
+// Batches up to bc's limits for writer; an Interval of zero or less is
+// DefaultBatch's
+func NewBatchingWriter(writer LogWriter, bc BatchConfig) *BatchingWriter {
+	if bc.Interval <= 0 {
+		bc.Interval = DefaultBatch.Interval
+	}
+	bw := &BatchingWriter{writer: writer, limits: bc, ticker: time.NewTicker(bc.Interval), stop: make(chan bool)}
+	go bw.flushLoop()
+	return bw
+}
+
+// Turns on gzip compression of the batches.  Call it before the writer
+// is used.
+func (bw *BatchingWriter) SetGzip(on bool) {
+	bw.gzip = on
+}
+
+func (bw *BatchingWriter) flushLoop() {
+	for {
+		select {
+		case <-bw.ticker.C:
+			bw.mu.Lock()
+			bw.send()
+			bw.mu.Unlock()
+		case <-bw.stop:
+			return
+		}
+	}
+}
+
+func (bw *BatchingWriter) LogWrite(msg string) {
+	bw.mu.Lock()
+	defer bw.mu.Unlock()
+	if bw.closed {
+		return
+	}
+	// the newline in front of it
+	size := len(msg) + 1
+	if bw.count > 0 && !bw.limits.Fits(bw.count+1, bw.buf.Len()+size) {
+		bw.send()
+	}
+	if bw.count > 0 {
+		bw.buf.WriteByte('\n')
+	}
+	bw.buf.WriteString(msg)
+	bw.count++
+	// full, or a message too big for a batch of its own
+	if !bw.limits.Fits(bw.count+1, bw.buf.Len()+1) {
+		bw.send()
+	}
+}
+
+// Hands the batch to the writer, must hold mu
+func (bw *BatchingWriter) send() {
+	if bw.count == 0 {
+		return
+	}
+	batch := bw.buf.Bytes()
+	bw.stats.Batches++
+	bw.stats.Records += uint64(bw.count)
+	bw.stats.Bytes += uint64(len(batch))
+	if bw.count > bw.stats.MaxRecords {
+		bw.stats.MaxRecords = bw.count
+	}
+	if len(batch) > bw.stats.MaxBytes {
+		bw.stats.MaxBytes = len(batch)
+	}
+	if bw.gzip {
+		bw.zbuf.Reset()
+		if bw.zw == nil {
+			bw.zw = gzip.NewWriter(&bw.zbuf)
+		} else {
+			bw.zw.Reset(&bw.zbuf)
+		}
+		bw.zw.Write(batch)
+		bw.zw.Write([]byte{'\n'})
+		if err := bw.zw.Close(); err != nil {
+			reportWriteError(bw, err)
+		}
+		batch = bw.zbuf.Bytes()
+	}
+	bw.stats.Sent += uint64(len(batch))
+	bw.writer.LogWrite(string(batch))
+	bw.buf.Reset()
+	bw.count = 0
+}
+
+// The counts so far
+func (bw *BatchingWriter) Stats() BatchStats {
+	bw.mu.Lock()
+	defer bw.mu.Unlock()
+	return bw.stats
+}
+
+// Sends the batch then flushes the writer if it implements Flusher
+func (bw *BatchingWriter) Flush() {
+	bw.mu.Lock()
+	bw.send()
+	bw.mu.Unlock()
+	if fl, ok := bw.writer.(Flusher); ok {
+		fl.Flush()
+	}
+}
+
+// Sends the batch then reopens the writer if it implements Reopener, so
+// the batch goes to the old file
+func (bw *BatchingWriter) Reopen() error {
+	bw.mu.Lock()
+	bw.send()
+	bw.mu.Unlock()
+	if ro, ok := bw.writer.(Reopener); ok {
+		return ro.Reopen()
+	}
+	return nil
+}
+
+// Sends the batch and closes the writer; messages after that are dropped
+func (bw *BatchingWriter) Close() {
+	bw.mu.Lock()
+	if bw.closed {
+		bw.mu.Unlock()
+		return
+	}
+	bw.closed = true
+	bw.send()
+	bw.mu.Unlock()
+	bw.ticker.Stop()
+	close(bw.stop)
+	bw.writer.Close()
+}
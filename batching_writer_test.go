@@ -0,0 +1,137 @@
+package timber
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestBatchingWriter(t *testing.T) {
+	mem := NewMemoryWriter(10)
+	bw := NewBatchingWriter(mem, BatchConfig{Count: 3, Bytes: 12, Interval: time.Hour})
+	for _, msg := range []string{"one", "two", "three", "four", "a message over the limit", "five"} {
+		bw.LogWrite(msg)
+	}
+	bw.Close()
+	bw.LogWrite("after close")
+	expected := []string{"one\ntwo", "three\nfour", "a message over the limit", "five"}
+	if got := mem.Messages(); !reflect.DeepEqual(got, expected) {
+		t.Errorf("expected %q got %q", expected, got)
+	}
+	stats := bw.Stats()
+	if stats.Batches != 4 || stats.Records != 6 || stats.MaxRecords != 2 || stats.MaxBytes != 24 || stats.Sent != stats.Bytes {
+		t.Errorf("unexpected stats %+v", stats)
+	}
+	if stats.AvgRecords() != 1.5 || stats.Ratio() != 1 {
+		t.Errorf("expected 1.5 records a batch and a ratio of 1, got %v and %v", stats.AvgRecords(), stats.Ratio())
+	}
+}
+
+func TestBatchingWriterInterval(t *testing.T) {
+	mem := NewMemoryWriter(10)
+	bw := NewBatchingWriter(mem, BatchConfig{Interval: 10 * time.Millisecond})
+	defer bw.Close()
+	bw.LogWrite("one")
+	bw.LogWrite("two")
+	for deadline := time.Now().Add(time.Second); len(mem.Messages()) == 0 && time.Now().Before(deadline); {
+		time.Sleep(5 * time.Millisecond)
+	}
+	if got := mem.Messages(); !reflect.DeepEqual(got, []string{"one\ntwo"}) {
+		t.Errorf("expected the batch after the interval, got %q", got)
+	}
+}
+
+func TestBatchingWriterGzip(t *testing.T) {
+	mem := NewMemoryWriter(10)
+	bw := NewBatchingWriter(mem, BatchConfig{Count: 100, Interval: time.Hour})
+	bw.SetGzip(true)
+	for i := 0; i < 100; i++ {
+		bw.LogWrite(fmt.Sprintf("the same old line %d", i))
+	}
+	bw.LogWrite("last")
+	bw.Flush()
+	var all bytes.Buffer
+	for _, batch := range mem.Messages() {
+		all.WriteString(batch)
+	}
+	// the batches are gzip members, together one gzip stream
+	zr, err := gzip.NewReader(&all)
+	if err != nil {
+		t.Fatal(err)
+	}
+	text, err := io.ReadAll(zr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := bytes.Split(bytes.TrimSuffix(text, []byte("\n")), []byte("\n"))
+	if len(lines) != 101 || string(lines[99]) != "the same old line 99" || string(lines[100]) != "last" {
+		t.Errorf("unexpected lines %q", lines)
+	}
+	console := NewBatchingWriter(&ConsoleWriter{Stream: os.Stderr}, BatchConfig{})
+	console.SetGzip(true)
+	if desc := describeWriter(console); desc != "console stderr (gzip batches)" {
+		t.Errorf("unexpected description %q", desc)
+	}
+	console.Close()
+	if stats := bw.Stats(); stats.Batches != 2 || stats.Ratio() >= 0.5 {
+		t.Errorf("expected 2 compressed batches, got %+v", stats)
+	}
+	bw.Close()
+}
+
+func TestBatchingWriterReopen(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "batch.log")
+	fw, err := NewFileWriter(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	bw := NewBatchingWriter(fw, BatchConfig{Count: 100, Interval: time.Hour})
+	defer bw.Close()
+	bw.LogWrite("before")
+	if err := os.Rename(name, name+".1"); err != nil {
+		t.Fatal(err)
+	}
+	if err := bw.Reopen(); err != nil {
+		t.Fatal(err)
+	}
+	bw.LogWrite("after")
+	bw.Flush()
+	if got, _ := os.ReadFile(name + ".1"); string(got) != "before\n" {
+		t.Errorf("expected the batch in the rotated file, got %q", got)
+	}
+	if got, _ := os.ReadFile(name); string(got) != "after\n" {
+		t.Errorf("expected the reopened file, got %q", got)
+	}
+	if err := NewBatchingWriter(NewMemoryWriter(1), BatchConfig{}).Reopen(); err != nil {
+		t.Errorf("expected nil without a Reopener, got %v", err)
+	}
+}
+
+func TestBatchConfigFilter(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "batch.log")
+	config := fmt.Sprintf(`{"Filters": [{"Enabled": true, "Type": "file", "Format": {"Name": "pattern", "Value": "%%M"},
+		"Properties": [{"Name": "filename", "Value": %q}, {"Name": "batch", "Value": "true"},
+			{"Name": "batch_count", "Value": "2"}, {"Name": "flush_interval", "Value": "1h"}]}]}`, name)
+	logger := NewTimber()
+	if err := logger.LoadJSONConfigString(config); err != nil {
+		t.Fatal(err)
+	}
+	logger.Info("one")
+	logger.Info("two")
+	logger.Info("three")
+	logger.Close()
+	if got, _ := os.ReadFile(name); string(got) != "one\ntwo\nthree\n" {
+		t.Errorf("expected the batches in the file, got %q", got)
+	}
+
+	bad := `{"Filters": [{"Enabled": true, "Type": "console", "Properties": [{"Name": "batch", "Value": "sometimes"}]}]}`
+	if err := NewTimber().LoadJSONConfigString(bad); err == nil {
+		t.Errorf("expected an error for a bad batch")
+	}
+}
@@ -2,48 +2,89 @@ package timber
 
 import (
 	"bufio"
-	"fmt"
 	"io"
+	"os"
+	"sync"
 	"time"
 )
 
-// Use this of you need some buffering, or not
+// Use this of you need some buffering, or not.  Each message is written
+// as a line.
 type BufferedWriter struct {
 	buf    *bufio.Writer
 	writer io.WriteCloser
 	mc     chan string
-	fc     chan int
+	fc     chan chan int
+	rc     chan chan error
 	autoFlush *time.Ticker
+	everyWrite bool
+	done       chan bool // closed once the buffer is written and the writer closed
+	closeOne   sync.Once
 }
 
+// The buffer is flushed once a second
 func NewBufferedWriter(writer io.WriteCloser) (*BufferedWriter, error) {
+	return NewBufferedWriterInterval(writer, time.Second)
+}
+
+// The buffer is flushed every interval, zero or less flushes after every
+// write.  It's always flushed on Flush and Close.
+func NewBufferedWriterInterval(writer io.WriteCloser, interval time.Duration) (*BufferedWriter, error) {
 	bw := new(BufferedWriter)
 	bw.writer = writer
 	bw.buf = bufio.NewWriter(writer)
 	bw.mc = make(chan string)
-	bw.fc = make(chan int)
-	bw.autoFlush = time.NewTicker(time.Second)
+	bw.fc = make(chan chan int)
+	bw.rc = make(chan chan error)
+	bw.done = make(chan bool)
+	if interval > 0 {
+		bw.autoFlush = time.NewTicker(interval)
+	} else {
+		bw.everyWrite = true
+	}
 	go bw.writeLoop()
 	return bw, nil
 }
 
 func (bw *BufferedWriter) writeLoop() {
+	var tick <-chan time.Time
+	if bw.autoFlush != nil {
+		tick = bw.autoFlush.C
+	}
 	for {
 		select {
 		case msg, ok := <-bw.mc:
 			if !ok {
+				if bw.autoFlush != nil {
+					bw.autoFlush.Stop()
+				}
 				bw.buf.Flush()
 				bw.writer.Close()
+				close(bw.done)
 				return
 			}
-			_, err := bw.buf.Write([]byte(msg))
+			_, err := bw.buf.WriteString(msg)
+			if err == nil {
+				err = bw.buf.WriteByte('\n')
+			}
+			if err == nil && bw.everyWrite {
+				err = bw.buf.Flush()
+			}
 			if err != nil {
 				// uh-oh... what do i do if logging fails; punt!
-				fmt.Printf("TIMBER! epic fail: %v", err)
+				reportWriteError(bw, err)
 			}
-		case <-bw.fc:
+		case done := <-bw.fc:
+			bw.buf.Flush()
+			done <- 1
+		case done := <-bw.rc:
 			bw.buf.Flush()
-		case <-bw.autoFlush.C:
+			var err error
+			if ro, ok := bw.writer.(Reopener); ok {
+				err = ro.Reopen()
+			}
+			done <- err
+		case <-tick:
 			bw.buf.Flush()
 		}
 	}
@@ -53,11 +94,42 @@ func (bw *BufferedWriter) LogWrite(msg string) {
 	bw.mc <- msg
 }
 
-// Force flush the buffer
+// Allows formatters to write directly to the buffer.  The bytes are
+// copied since the write happens asynchronously
+func (bw *BufferedWriter) Write(p []byte) (int, error) {
+	bw.mc <- string(p)
+	return len(p), nil
+}
+
+// Force flush the buffer, blocks until the flush is done.  Does nothing
+// once the writer is closed.
 func (bw *BufferedWriter) Flush() {
-	bw.fc <- 1
+	done := make(chan int)
+	select {
+	case bw.fc <- done:
+		<-done
+	case <-bw.done:
+	}
+}
+
+// Flushes the buffer then reopens the underlying writer if it's a
+// Reopener; otherwise this only flushes
+func (bw *BufferedWriter) Reopen() error {
+	done := make(chan error)
+	select {
+	case bw.rc <- done:
+		return <-done
+	case <-bw.done:
+		return os.ErrClosed
+	}
 }
 
+// Blocks until the buffer is written out and the underlying writer
+// closed, so nothing is lost if the program exits right after.  Safe to
+// call more than once.
 func (bw *BufferedWriter) Close() {
-	close(bw.mc)
+	bw.closeOne.Do(func() {
+		close(bw.mc)
+	})
+	<-bw.done
 }
@@ -0,0 +1,96 @@
+package timber
+
+import (
+	"runtime"
+	"sync"
+)
+
+// Optional interface for a LogFormatter, LogWriter or RecordFilter that
+// can tell whether it looks at the caller of a record: SourceFile,
+// SourceLine, FuncPath or PackagePath.  The caller is only looked up on
+// a log call when a logger needs it, see Timber.SourceNeeded.  A
+// formatter without the method is taken to use the caller, as are a
+// RecordWriter, a StructuredWriter and a filter without it; a plain
+// LogWriter only sees the formatted string.
+type SourceUser interface {
+	UsesSource() bool
+}
+
+// What FuncForPC says about a pc, looked up once
+type callerInfo struct {
+	file        string
+	line        int
+	funcPath    string
+	packagePath string
+}
+
+var unknownCaller = callerInfo{funcPath: "_", packagePath: "_"}
+
+// by pc; there's no more of them than call sites so it doesn't need a bound
+var callerCache sync.Map
+
+// The caller at pc, a return address from runtime.Callers
+func callerFor(pc uintptr) *callerInfo {
+	if cached, ok := callerCache.Load(pc); ok {
+		return cached.(*callerInfo)
+	}
+	info := &callerInfo{funcPath: "_", packagePath: "_"}
+	// the pc is a return address so back up into the call
+	if me := runtime.FuncForPC(pc - 1); me != nil {
+		info.file, info.line = me.FileLine(pc - 1)
+		info.funcPath = me.Name()
+		info.packagePath = splitPackage(info.funcPath)
+	}
+	callerCache.Store(pc, info)
+	return info
+}
+
+// True if one of the loggers looks at the callers of the records
+func loggersNeedSource(loggers []ConfigLogger) bool {
+	for _, cLog := range loggers {
+		if cLog.needsSource() {
+			return true
+		}
+	}
+	return false
+}
+
+func (cLog ConfigLogger) needsSource() bool {
+	if len(cLog.Granulars) > 0 || len(cLog.GranularMax) > 0 {
+		return true
+	}
+	for _, filter := range cLog.Filters {
+		if usesSource(filter, true) {
+			return true
+		}
+	}
+	for _, formatters := range []map[string]LogFormatter{cLog.FieldFormatters, cLog.GranularFormatters} {
+		for _, formatter := range formatters {
+			if formatterUsesSource(formatter) {
+				return true
+			}
+		}
+	}
+	return formatterUsesSource(cLog.Formatter) || writerUsesSource(cLog.LogWriter)
+}
+
+// The SourceUser's answer, otherwise the default
+func usesSource(v interface{}, otherwise bool) bool {
+	if su, ok := v.(SourceUser); ok {
+		return su.UsesSource()
+	}
+	return otherwise
+}
+
+func formatterUsesSource(formatter LogFormatter) bool {
+	return formatter != nil && usesSource(formatter, true)
+}
+
+func writerUsesSource(w LogWriter) bool {
+	if su, ok := w.(SourceUser); ok {
+		return su.UsesSource()
+	}
+	_, structured := w.(StructuredWriter)
+	_, records := w.(RecordWriter)
+	return structured || records
+}
@@ -0,0 +1,111 @@
+package timber
+
+import (
+	"reflect"
+	"regexp"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func TestSourceNeeded(t *testing.T) {
+	logger := NewTimber()
+	if !logger.SourceNeeded() {
+		t.Errorf("expected the caller to be looked up before there are loggers")
+	}
+	mw := NewMemoryWriter(10)
+	logger.AddLogger(ConfigLogger{LogWriter: mw, Level: INFO, Tag: "plain",
+		Formatter: NewLevelFormatter(NewMultilineFormatter(NewPatFormatter("%L %M"), MultilineIndent))})
+	if logger.SourceNeeded() {
+		t.Errorf("expected no lookups for a pattern without source directives")
+	}
+	for _, pattern := range []string{"%l", "%F", "%-10f"} {
+		if !NewPatFormatter(pattern).UsesSource() {
+			t.Errorf("expected %q to use the source", pattern)
+		}
+	}
+	logger.Info("no source")
+	logger.AddLogger(ConfigLogger{LogWriter: NewMemoryWriter(10), Level: INFO, Formatter: NewPatFormatter("%M"),
+		Filters: []RecordFilter{FromPackages("github.com/me/app")}})
+	if !logger.SourceNeeded() {
+		t.Errorf("expected lookups for a package filter")
+	}
+	logger.Close()
+
+	fielded := NewMemoryWriter(10)
+	logger = NewTimber()
+	logger.AddLogger(ConfigLogger{LogWriter: fielded, Level: INFO, Formatter: NewPatFormatter("%M"),
+		FieldFormatters: map[string]LogFormatter{"kind": NewPatFormatter("%s %M")}})
+	if !logger.SourceNeeded() {
+		t.Errorf("expected lookups for a field formatter with a source directive")
+	}
+	logger.Infow("withfield", Fields{FormatField: "kind"})
+	logger.Close()
+	if got := fielded.Messages(); len(got) != 1 || strings.HasPrefix(got[0], unknownSource) {
+		t.Errorf("expected the caller from the field formatter, got %q", got)
+	}
+
+	logger = NewTimber()
+	logger.AddLogger(ConfigLogger{LogWriter: NewMemoryWriter(10), Level: INFO, Formatter: NewPatFormatter("%M"),
+		Filters: []RecordFilter{Not(MessageMatches(regexp.MustCompile(`^GET /healthz`)))}, Granulars: map[string]Level{"github.com/me/app": DEBUG}})
+	if !logger.SourceNeeded() {
+		t.Errorf("expected lookups for granulars")
+	}
+	logger.Close()
+	if got, expected := mw.Messages(), []string{"INFO no source"}; !reflect.DeepEqual(got, expected) {
+		t.Errorf("expected %q got %q", expected, got)
+	}
+}
+
+func TestSkippedSourceIsEmpty(t *testing.T) {
+	logger := NewTimber()
+	var got *LogRecord
+	logger.AddLogger(ConfigLogger{LogWriter: NewMemoryWriter(10), Level: INFO, Formatter: NewPatFormatter("%M")})
+	logger.AddLogger(ConfigLogger{LogWriter: &sourceBlind{rec: &got}, Level: INFO, Formatter: NewPatFormatter("%M")})
+	logger.Info("hello")
+	logger.Close()
+	if got == nil || got.SourceFile != "" || got.FuncPath != "_" || got.PackagePath != "_" {
+		t.Errorf("expected a record without a caller, got %+v", got)
+	}
+}
+
+// a RecordWriter that says it doesn't look at the caller
+type sourceBlind struct {
+	rec **LogRecord
+}
+
+func (sb *sourceBlind) LogWrite(msg string) {}
+func (sb *sourceBlind) LogWriteRecord(rec *LogRecord, msg string) {
+	copied := *rec
+	*sb.rec = &copied
+}
+func (sb *sourceBlind) UsesSource() bool { return false }
+func (sb *sourceBlind) Close()           {}
+
+func wrappedInfo(fl *FieldLogger, msg string) {
+	fl.Info(msg)
+}
+
+func TestWithCallDepth(t *testing.T) {
+	logger := NewTimber()
+	mw := NewMemoryWriter(10)
+	logger.AddLogger(ConfigLogger{LogWriter: mw, Level: INFO, Formatter: NewPatFormatter("%P %M")})
+	wrappedInfo(logger.WithCallDepth(0), "the wrapper")
+	wrappedInfo(logger.WithCallDepth(1).WithFields(Fields{"k": "v"}), "the caller")
+	logger.GetLogger("app").WithCallDepth(1).Info("above the test")
+	logger.Close()
+	expected := []string{"github.com/smw1218/timber.wrappedInfo the wrapper",
+		"github.com/smw1218/timber.TestWithCallDepth the caller", "testing.tRunner above the test"}
+	if got := mw.Messages(); !reflect.DeepEqual(got, expected) {
+		t.Errorf("expected %q got %q", expected, got)
+	}
+}
+
+func TestCallerCache(t *testing.T) {
+	var pcs [1]uintptr
+	runtime.Callers(1, pcs[:])
+	first, second := callerFor(pcs[0]), callerFor(pcs[0])
+	if first != second || first.funcPath != "github.com/smw1218/timber.TestCallerCache" {
+		t.Errorf("expected one cached lookup of the test, got %+v and %+v", first, second)
+	}
+}
@@ -0,0 +1,320 @@
+// Package cloudwatch provides a timber LogWriter that ships messages to
+// AWS CloudWatch Logs.  It lives in its own package so that only
+// applications that use it pull in the AWS specific code.  Importing it
+// registers the cloudwatch filter type with the config loaders:
+//   import _ "github.com/smw1218/timber/cloudwatch"
+package cloudwatch
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/smw1218/timber"
+)
+
+// CloudWatch limits for a single PutLogEvents call
+const (
+	maxBatchEvents = 10000
+	maxBatchBytes  = 1048576
+	eventOverhead  = 26 // counted against maxBatchBytes for each event
+	maxEventBytes  = 256*1024 - eventOverhead
+)
+
+// the most a single call allows, sent at least once a second
+var defaultBatch = timber.BatchConfig{Count: maxBatchEvents, Bytes: maxBatchBytes, Interval: time.Second}
+
+// Batches messages into PutLogEvents calls.  Like the BufferedWriter the
+// batch is sent at least once a second, when Flush is called, or when it
+// reaches the CloudWatch size limits; SetBatch can make any of those
+// smaller.  Close sends whatever is left, LogWrite and Flush after that
+// do nothing.  Errors are sent to timber.WriteErrorHandler.
+type CloudWatchWriter struct {
+	group         string
+	stream        string
+	region        string
+	endpoint      string
+	client        *http.Client
+	creds         *credentialProvider
+	sequenceToken string
+	events        []logEvent
+	batchBytes    int
+	batch         timber.BatchConfig
+	mc            chan logEvent
+	fc            chan chan int
+	done          chan int
+	autoFlush     *time.Ticker
+
+	// set by Close under the write lock, LogWrite and Flush hold the read
+	// lock to send
+	closeMu sync.RWMutex
+	closed  bool
+}
+
+type logEvent struct {
+	Timestamp int64  `json:"timestamp"`
+	Message   string `json:"message"`
+}
+
+type putLogEventsInput struct {
+	LogGroupName  string     `json:"logGroupName"`
+	LogStreamName string     `json:"logStreamName"`
+	LogEvents     []logEvent `json:"logEvents"`
+	SequenceToken string     `json:"sequenceToken,omitempty"`
+}
+
+type awsError struct {
+	Type                  string `json:"__type"`
+	Message               string `json:"message"`
+	ExpectedSequenceToken string `json:"expectedSequenceToken"`
+}
+
+func (e *awsError) Error() string {
+	return fmt.Sprintf("TIMBER! cloudwatch %s: %s", e.Type, e.Message)
+}
+
+// the __type is prefixed with a namespace e.g. com.amazonaws.logs#ResourceAlreadyExistsException
+func (e *awsError) is(errType string) bool {
+	return strings.HasSuffix(e.Type, errType)
+}
+
+// Creates the log group and stream if they don't exist yet.  Credentials
+// come from the environment or the ECS task role.
+func NewCloudWatchWriter(group, stream, region string) (*CloudWatchWriter, error) {
+	return newCloudWatchWriter(group, stream, region, "https://logs."+region+".amazonaws.com")
+}
+
+func newCloudWatchWriter(group, stream, region, endpoint string) (*CloudWatchWriter, error) {
+	client := &http.Client{Timeout: 10 * time.Second}
+	cw := &CloudWatchWriter{
+		group:    group,
+		stream:   stream,
+		region:   region,
+		endpoint: endpoint,
+		client:   client,
+		creds:    &credentialProvider{client: client},
+		mc:       make(chan logEvent),
+		fc:       make(chan chan int),
+		done:     make(chan int),
+		batch:    defaultBatch,
+	}
+	if err := cw.createIfMissing("CreateLogGroup", map[string]string{"logGroupName": group}); err != nil {
+		return nil, err
+	}
+	if err := cw.createIfMissing("CreateLogStream", map[string]string{"logGroupName": group, "logStreamName": stream}); err != nil {
+		return nil, err
+	}
+	cw.autoFlush = time.NewTicker(cw.batch.Interval)
+	go cw.writeLoop()
+	return cw, nil
+}
+
+func init() {
+	timber.RegisterWriterFactory("cloudwatch", NewFromFilter)
+}
+
+// The writer factory for filters of type cloudwatch.  Reads the group,
+// stream and region properties, region defaults to the AWS_REGION
+// environment variable.  The batch_count, batch_bytes and flush_interval
+// properties are passed to SetBatch.
+func NewFromFilter(filter timber.JSONFilter) (timber.LogWriter, error) {
+	var group, stream, region string
+	for _, prop := range filter.Properties {
+		switch prop.Name {
+		case "group":
+			group = prop.Value
+		case "stream":
+			stream = prop.Value
+		case "region":
+			region = prop.Value
+		}
+	}
+	if region == "" {
+		region = os.Getenv("AWS_REGION")
+	}
+	if group == "" || stream == "" || region == "" {
+		return nil, fmt.Errorf("TIMBER! Missing group, stream or region for cloudwatch log writer")
+	}
+	bc, err := filter.BatchConfig(defaultBatch)
+	if err != nil {
+		return nil, err
+	}
+	cw, err := NewCloudWatchWriter(group, stream, region)
+	if err != nil {
+		return nil, err
+	}
+	cw.SetBatch(bc)
+	return cw, nil
+}
+
+// Lowers the batch limits, anything over the CloudWatch limits is capped
+// at them.  Call it before the writer is used.
+func (cw *CloudWatchWriter) SetBatch(bc timber.BatchConfig) {
+	if bc.Count <= 0 || bc.Count > maxBatchEvents {
+		bc.Count = maxBatchEvents
+	}
+	if bc.Bytes <= 0 || bc.Bytes > maxBatchBytes {
+		bc.Bytes = maxBatchBytes
+	}
+	if bc.Interval <= 0 {
+		bc.Interval = time.Second
+	}
+	cw.batch = bc
+	cw.autoFlush.Reset(bc.Interval)
+}
+
+func (cw *CloudWatchWriter) LogWrite(msg string) {
+	cw.closeMu.RLock()
+	defer cw.closeMu.RUnlock()
+	if cw.closed {
+		return
+	}
+	cw.mc <- logEvent{time.Now().UnixNano() / int64(time.Millisecond), truncateEvent(msg)}
+}
+
+// Cuts msg to the most an event can hold, on a rune boundary
+func truncateEvent(msg string) string {
+	if len(msg) <= maxEventBytes {
+		return msg
+	}
+	cut := maxEventBytes
+	for cut > 0 && !utf8.RuneStart(msg[cut]) {
+		cut--
+	}
+	return msg[:cut]
+}
+
+// Sends the current batch, blocks until it's done
+func (cw *CloudWatchWriter) Flush() {
+	done := make(chan int)
+	cw.closeMu.RLock()
+	if cw.closed {
+		cw.closeMu.RUnlock()
+		return
+	}
+	cw.fc <- done
+	cw.closeMu.RUnlock()
+	<-done
+}
+
+// Sends any remaining messages, blocks until they're sent
+func (cw *CloudWatchWriter) Close() {
+	cw.closeMu.Lock()
+	if !cw.closed {
+		cw.closed = true
+		close(cw.mc)
+	}
+	cw.closeMu.Unlock()
+	<-cw.done
+}
+
+func (cw *CloudWatchWriter) writeLoop() {
+	defer close(cw.done)
+	for {
+		select {
+		case event, ok := <-cw.mc:
+			if !ok {
+				cw.autoFlush.Stop()
+				cw.flush()
+				return
+			}
+			size := len(event.Message) + eventOverhead
+			if !cw.batch.Fits(len(cw.events)+1, cw.batchBytes+size) {
+				cw.flush()
+			}
+			cw.events = append(cw.events, event)
+			cw.batchBytes += size
+		case done := <-cw.fc:
+			cw.flush()
+			done <- 1
+		case <-cw.autoFlush.C:
+			cw.flush()
+		}
+	}
+}
+
+func (cw *CloudWatchWriter) flush() {
+	if len(cw.events) == 0 {
+		return
+	}
+	err := cw.putLogEvents()
+	if awsErr, ok := err.(*awsError); ok {
+		switch {
+		case awsErr.is("InvalidSequenceTokenException"):
+			cw.sequenceToken = awsErr.ExpectedSequenceToken
+			err = cw.putLogEvents()
+		case awsErr.is("DataAlreadyAcceptedException"):
+			cw.sequenceToken = awsErr.ExpectedSequenceToken
+			err = nil
+		}
+	}
+	if err != nil {
+		if handler := timber.WriteErrorHandler; handler != nil {
+			handler(cw, err)
+		}
+	}
+	cw.events = cw.events[:0]
+	cw.batchBytes = 0
+}
+
+func (cw *CloudWatchWriter) putLogEvents() error {
+	input := putLogEventsInput{cw.group, cw.stream, cw.events, cw.sequenceToken}
+	var output struct {
+		NextSequenceToken string `json:"nextSequenceToken"`
+	}
+	if err := cw.call("PutLogEvents", input, &output); err != nil {
+		return err
+	}
+	cw.sequenceToken = output.NextSequenceToken
+	return nil
+}
+
+func (cw *CloudWatchWriter) createIfMissing(action string, input interface{}) error {
+	err := cw.call(action, input, nil)
+	if awsErr, ok := err.(*awsError); ok && awsErr.is("ResourceAlreadyExistsException") {
+		return nil
+	}
+	return err
+}
+
+// Makes a signed CloudWatch Logs JSON API call
+func (cw *CloudWatchWriter) call(action string, input, output interface{}) error {
+	body, err := json.Marshal(input)
+	if err != nil {
+		return err
+	}
+	creds, err := cw.creds.get()
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest("POST", cw.endpoint+"/", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-amz-json-1.1")
+	req.Header.Set("X-Amz-Target", "Logs_20140328."+action)
+	signRequest(req, body, creds, cw.region, time.Now())
+
+	resp, err := cw.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("TIMBER! cloudwatch %s failed: %v", action, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		awsErr := &awsError{}
+		if json.NewDecoder(resp.Body).Decode(awsErr) != nil || awsErr.Type == "" {
+			return fmt.Errorf("TIMBER! cloudwatch %s failed: %v", action, resp.Status)
+		}
+		return awsErr
+	}
+	if output != nil {
+		return json.NewDecoder(resp.Body).Decode(output)
+	}
+	return nil
+}
@@ -0,0 +1,91 @@
+package cloudwatch
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Example from the AWS SigV4 documentation
+func TestSigningKey(t *testing.T) {
+	key := signingKey("wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY", "20150830", "us-east-1", "iam")
+	expected := "c4afb1cc5771d871763a393e44b703571b55cc28424d1a5e86da6ed3c154a4b9"
+	if got := hex.EncodeToString(key); got != expected {
+		t.Errorf("signing key %s != %s", got, expected)
+	}
+}
+
+func TestCloudWatchWriter(t *testing.T) {
+	os.Setenv("AWS_ACCESS_KEY_ID", "AKIDEXAMPLE")
+	os.Setenv("AWS_SECRET_ACCESS_KEY", "secret")
+	defer os.Unsetenv("AWS_ACCESS_KEY_ID")
+	defer os.Unsetenv("AWS_SECRET_ACCESS_KEY")
+
+	puts := make(chan putLogEventsInput, 10)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !strings.HasPrefix(r.Header.Get("Authorization"), sigV4Algorithm+" Credential=AKIDEXAMPLE/") {
+			t.Errorf("request not signed: %v", r.Header.Get("Authorization"))
+		}
+		switch r.Header.Get("X-Amz-Target") {
+		case "Logs_20140328.CreateLogGroup":
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"__type":"com.amazonaws.logs#ResourceAlreadyExistsException","message":"exists"}`))
+		case "Logs_20140328.PutLogEvents":
+			var input putLogEventsInput
+			json.NewDecoder(r.Body).Decode(&input)
+			puts <- input
+			w.Write([]byte(`{"nextSequenceToken":"next"}`))
+		default:
+			w.Write([]byte(`{}`))
+		}
+	}))
+	defer server.Close()
+
+	cw, err := newCloudWatchWriter("group", "stream", "us-east-1", server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cw.LogWrite("one")
+	cw.LogWrite("two")
+	cw.Close()
+	input := <-puts
+	if len(input.LogEvents) != 2 || input.LogEvents[1].Message != "two" {
+		t.Errorf("unexpected batch %+v", input)
+	}
+	if cw.sequenceToken != "next" {
+		t.Errorf("sequence token not saved")
+	}
+
+	// after Close everything is a no-op
+	done := make(chan bool)
+	go func() {
+		defer close(done)
+		cw.LogWrite("late")
+		cw.Flush()
+		cw.Close()
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("expected LogWrite, Flush and Close after Close to return")
+	}
+	if len(puts) != 0 {
+		t.Errorf("expected nothing sent after Close, got %+v", <-puts)
+	}
+}
+
+func TestTruncateEvent(t *testing.T) {
+	// a 3 byte rune across the limit goes whole
+	msg := strings.Repeat("a", maxEventBytes-1) + "€" + "tail"
+	if got := truncateEvent(msg); got != strings.Repeat("a", maxEventBytes-1) {
+		t.Errorf("expected the cut before the rune, got %d bytes ending %q", len(got), got[len(got)-3:])
+	}
+	if got := truncateEvent("short"); got != "short" {
+		t.Errorf("expected a short message untouched, got %q", got)
+	}
+}
@@ -0,0 +1,63 @@
+package cloudwatch
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+)
+
+// Where ECS serves the task role credentials
+const ecsCredentialsHost = "http://169.254.170.2"
+
+type credentials struct {
+	AccessKeyID     string    `json:"AccessKeyId"`
+	SecretAccessKey string    `json:"SecretAccessKey"`
+	SessionToken    string    `json:"Token"`
+	Expiration      time.Time `json:"Expiration"`
+}
+
+// Finds credentials the same way the SDK does for the common cases:
+// the AWS_ACCESS_KEY_ID/AWS_SECRET_ACCESS_KEY environment variables, then
+// the ECS task role endpoint.  ECS credentials are refreshed before they expire.
+type credentialProvider struct {
+	mu     sync.Mutex
+	creds  credentials
+	client *http.Client
+}
+
+func (cp *credentialProvider) get() (credentials, error) {
+	if id := os.Getenv("AWS_ACCESS_KEY_ID"); id != "" {
+		return credentials{
+			AccessKeyID:     id,
+			SecretAccessKey: os.Getenv("AWS_SECRET_ACCESS_KEY"),
+			SessionToken:    os.Getenv("AWS_SESSION_TOKEN"),
+		}, nil
+	}
+
+	cp.mu.Lock()
+	defer cp.mu.Unlock()
+	if cp.creds.AccessKeyID != "" && time.Now().Add(5*time.Minute).Before(cp.creds.Expiration) {
+		return cp.creds, nil
+	}
+	uri := os.Getenv("AWS_CONTAINER_CREDENTIALS_RELATIVE_URI")
+	if uri == "" {
+		return credentials{}, fmt.Errorf("TIMBER! No AWS credentials found for cloudwatch")
+	}
+	resp, err := cp.client.Get(ecsCredentialsHost + uri)
+	if err != nil {
+		return credentials{}, fmt.Errorf("TIMBER! Can't get ECS credentials: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return credentials{}, fmt.Errorf("TIMBER! Can't get ECS credentials: %v", resp.Status)
+	}
+	var creds credentials
+	if err = json.NewDecoder(resp.Body).Decode(&creds); err != nil {
+		return credentials{}, fmt.Errorf("TIMBER! Can't parse ECS credentials: %v", err)
+	}
+	cp.creds = creds
+	return creds, nil
+}
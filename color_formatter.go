@@ -0,0 +1,158 @@
+package timber
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// The ANSI SGR codes ColorFormatter starts with, e.g. "1;31" is bold red.
+// An empty code leaves the level uncolored.
+var DefaultColors = map[Level]string{
+	FINEST:   "90",
+	FINE:     "90",
+	DEBUG:    "36",
+	TRACE:    "34",
+	INFO:     "",
+	WARNING:  "33",
+	ERROR:    "31",
+	CRITICAL: "1;31",
+}
+
+// Colors the output of another formatter by level for reading in a
+// terminal.  Don't use it for files or sockets, the escape codes go
+// along with the text; ColorEnabled tells whether the console is a
+// terminal.  With LevelOnly just the level name is colored, the first
+// place the formatter wrote it as %L or in full; lines without it stay
+// plain.
+type ColorFormatter struct {
+	Formatter LogFormatter
+	Colors    map[Level]string
+	LevelOnly bool
+}
+
+// True when w is a terminal and the NO_COLOR environment variable isn't
+// set (see no-color.org), so escape codes written to it show as colors
+func ColorEnabled(w io.Writer) bool {
+	if os.Getenv("NO_COLOR") != "" {
+		return false
+	}
+	f, ok := w.(*os.File)
+	if !ok {
+		return false
+	}
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+// Starts out with a copy of DefaultColors
+func NewColorFormatter(formatter LogFormatter) *ColorFormatter {
+	colors := make(map[Level]string, len(DefaultColors))
+	for lvl, code := range DefaultColors {
+		colors[lvl] = code
+	}
+	return &ColorFormatter{Formatter: formatter, Colors: colors}
+}
+
+// Overrides the code for one level, "" or "0" turns color off for it.
+// Only digits and semicolons make sense in an SGR code.
+func (cf *ColorFormatter) SetColor(lvl Level, code string) error {
+	if strings.Trim(code, "0123456789;") != "" {
+		return fmt.Errorf("TIMBER! Bad color %q for %v, expected an ANSI code like 1;31", code, LongLevelStrings[lvl])
+	}
+	if code == "0" {
+		code = ""
+	}
+	cf.Colors[lvl] = code
+	return nil
+}
+
+// LogFormatter interface
+func (cf *ColorFormatter) Format(rec *LogRecord) string {
+	msg := cf.Formatter.Format(rec)
+	code := cf.Colors[rec.Level]
+	if code == "" {
+		return msg
+	}
+	if cf.LevelOnly {
+		return colorLevel(msg, rec.Level, code)
+	}
+	return "\x1b[" + code + "m" + msg + "\x1b[0m"
+}
+
+// SourceUser interface
+func (cf *ColorFormatter) UsesSource() bool {
+	return formatterUsesSource(cf.Formatter)
+}
+
+// Colors the first of the short or long name of lvl in msg
+func colorLevel(msg string, lvl Level, code string) string {
+	start, name := -1, ""
+	for _, candidate := range [...]string{LevelStrings[lvl], LongLevelStrings[lvl]} {
+		if i := strings.Index(msg, candidate); i >= 0 && (start < 0 || i < start || (i == start && len(candidate) > len(name))) {
+			start, name = i, candidate
+		}
+	}
+	if start < 0 {
+		return msg
+	}
+	end := start + len(name)
+	return msg[:start] + "\x1b[" + code + "m" + name + "\x1b[0m" + msg[end:]
+}
+
+// Reads the color (on, off or auto) and color_<level> properties of a
+// console filter, nil when there aren't any
+func colorsFromFilter(filter JSONFilter) (map[Level]string, error) {
+	on, _ := strconv.ParseBool(filter.property("color"))
+	if strings.EqualFold(filter.property("color"), "auto") {
+		on = true
+	}
+	cf := NewColorFormatter(nil)
+	for _, prop := range filter.Properties {
+		name := strings.TrimPrefix(prop.Name, "color_")
+		if name == prop.Name || name == "part" {
+			continue
+		}
+		lvl, err := ParseLevel(name)
+		if strings.EqualFold(name, "warn") {
+			lvl, err = WARNING, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("TIMBER! Unknown level in %s: %v", prop.Name, err)
+		}
+		if err := cf.SetColor(lvl, strings.TrimSpace(prop.Value)); err != nil {
+			return nil, err
+		}
+		on = true
+	}
+	if !on {
+		return nil, nil
+	}
+	return cf.Colors, nil
+}
+
+// Whether a console filter's colors go out to stream: never with NO_COLOR,
+// only to a terminal with color auto
+func colorWanted(filter JSONFilter, stream io.Writer) bool {
+	if os.Getenv("NO_COLOR") != "" {
+		return false
+	}
+	if strings.EqualFold(filter.property("color"), "auto") {
+		return ColorEnabled(stream)
+	}
+	return true
+}
+
+// color_part is line (the default) or level, see ColorFormatter.LevelOnly
+func colorLevelOnly(filter JSONFilter) (bool, error) {
+	switch part := filter.property("color_part"); part {
+	case "", "line":
+		return false, nil
+	case "level":
+		return true, nil
+	default:
+		return false, fmt.Errorf("TIMBER! Unknown color_part %q, expected line or level", part)
+	}
+}
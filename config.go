@@ -1,10 +1,53 @@
 package timber
 
 import (
+	"errors"
+	"fmt"
+	"io"
 	"log"
+	"os"
 	"path"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
 )
 
+var defaultPattern atomic.Value // string
+
+// Sets the pattern used by config filters that don't have a format.  Out
+// of the box it's "%M", just the message.  It only affects config loaded
+// after the call.
+func SetDefaultFormat(pattern string) {
+	defaultPattern.Store(pattern)
+}
+
+// set by StrictConfig, accessed atomically
+var strictConfig int32
+
+// In strict mode the config loaders check the whole config with
+// ValidateJSONConfig before adding any loggers and return an error for an
+// unknown filter type or level name instead of warning and carrying on.
+// A logger whose tag is already taken is rejected rather than replacing
+// the old one, see AddLogger.  Missing required properties are always an
+// error.  The default is lenient.
+func StrictConfig(strict bool) {
+	var val int32
+	if strict {
+		val = 1
+	}
+	atomic.StoreInt32(&strictConfig, val)
+}
+
+func defaultFormat() string {
+	if pattern, ok := defaultPattern.Load().(string); ok && pattern != "" {
+		return pattern
+	}
+	return "%M"
+}
+
 func (t *Timber) LoadConfig(filename string) {
 	if len(filename) <= 0 {
 		return
@@ -19,7 +62,881 @@ func (t *Timber) LoadConfig(filename string) {
 	case "json":
 		t.LoadJSONConfig(filename)
 		break
+	case "yaml", "yml":
+		t.LoadYAMLConfig(filename)
+	case "toml":
+		t.LoadTOMLConfig(filename)
+	default:
+		log.Printf("TIMBER! Unknown config file type %v, only XML, JSON, YAML and TOML are supported types\n", ext)
+	}
+}
+
+// Loads the config in filename by its extension: .xml, .json, .yaml (or
+// .yml) or .toml.  Unlike LoadConfig the errors come back, an unknown
+// extension included.
+func (t *Timber) LoadConfigAuto(filename string) error {
+	config, err := readConfigFile(filename)
+	if err != nil {
+		return err
+	}
+	if err = t.loadFilters(config); err != nil {
+		return fmt.Errorf("%v (%s)", err, filename)
+	}
+	return nil
+}
+
+// Adds a logger for each enabled filter, shared by all the config loaders
+func (t *Timber) loadFilters(config JSONConfig) error {
+	if dryRun() {
+		return dryRunError(config)
+	}
+	config, err := prepareConfig(config)
+	if err != nil {
+		return err
+	}
+	for _, filter := range config.Filters {
+		if !t.takesFilter(filter) {
+			continue
+		}
+		configLogger, ok, err := buildLogger(config.Defaults, filter)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			continue
+		}
+		if _, err := t.addLogger(configLogger); err != nil {
+			configLogger.LogWriter.Close()
+			return err
+		}
+	}
+	t.ReleaseStartup()
+	return nil
+}
+
+// All of ValidateJSONConfig's complaints in strict mode, nil otherwise
+func checkStrict(config JSONConfig) error {
+	if atomic.LoadInt32(&strictConfig) == 1 {
+		return errors.Join(ValidateJSONConfig(config)...)
+	}
+	return nil
+}
+
+// The logger for one filter, false if the filter is disabled or of a
+// type nobody knows.  The writer is open on success.
+func buildLogger(defaults *JSONDefaults, filter JSONFilter) (ConfigLogger, bool, error) {
+	return buildFilterLogger(defaults, filter, true)
+}
+
+// buildLogger, or without open everything but the writer, which stays
+// nil, for a dry run
+func buildFilterLogger(defaults *JSONDefaults, filter JSONFilter, open bool) (ConfigLogger, bool, error) {
+	if !filter.enabled() {
+		return ConfigLogger{}, false, nil
+	}
+	if filter.Type == AccessLogType {
+		var err error
+		if filter, err = accessLogFilter(filter); err != nil {
+			return ConfigLogger{}, false, err
+		}
+		defaults = nil
+	}
+	filter = defaults.applyTo(filter)
+	level := getLevel(filter.Level)
+	formatter, err := getJSONFormatter(filter)
+	if err != nil {
+		return ConfigLogger{}, false, err
+	}
+	escape, _ := strconv.ParseBool(filter.property("escape"))
+	durationFormat, err := ParseDurationFormat(filter.property("duration_format"))
+	if err != nil {
+		return ConfigLogger{}, false, err
+	}
+	tags := staticTagsFromFilter(filter)
+	newPatFormatter := func(format string) *PatFormatter {
+		pf := NewPatFormatter(format)
+		pf.SetEscape(escape)
+		pf.SetDurationFormat(durationFormat)
+		if tags != nil {
+			pf.SetTags(tags)
+		}
+		return pf
+	}
+	switch f := formatter.(type) {
+	case *PatFormatter:
+		f.SetEscape(escape)
+		f.SetDurationFormat(durationFormat)
+		if tags != nil {
+			f.SetTags(tags)
+		}
+	case *JSONFormatter:
+		f.SetDurationFormat(durationFormat)
+		if indent, _ := strconv.ParseBool(filter.property("indent")); indent {
+			f.indent = "  "
+		}
+	case *LogfmtFormatter:
+		f.SetDurationFormat(durationFormat)
+	}
+	if len(filter.Formats) > 0 {
+		lf := NewLevelFormatter(formatter)
+		for _, format := range filter.Formats {
+			lf.Levels[getLevel(format.Level)] = newPatFormatter(format.Format)
+		}
+		formatter = lf
+	}
+	if masks := filter.properties("mask"); len(masks) > 0 {
+		mf := NewMaskFormatter(formatter)
+		for _, mask := range masks {
+			pattern, replacement, err := parseMaskRule(mask)
+			if err == nil {
+				err = mf.AddRule(pattern, replacement)
+			}
+			if err != nil {
+				return ConfigLogger{}, false, err
+			}
+		}
+		formatter = mf
+	}
+	if multiline := filter.property("multiline"); multiline != "" {
+		mode, err := ParseMultilineMode(multiline)
+		if err != nil {
+			return ConfigLogger{}, false, err
+		}
+		formatter = &MultilineFormatter{Formatter: formatter, Mode: mode, Indent: filter.property("multiline_indent")}
+	}
+	if maxLength := filter.property("max_length"); maxLength != "" {
+		max, err := strconv.Atoi(maxLength)
+		if err != nil {
+			return ConfigLogger{}, false, fmt.Errorf("TIMBER! Bad max_length %q: %v", maxLength, err)
+		}
+		formatter = NewTruncateFormatter(formatter, max)
+	}
+	granulars := make(map[string]Level)
+	granFormatters := make(map[string]LogFormatter)
+	var granMax map[string]Level
+	for _, granular := range filter.Granulars {
+		granulars[granular.Path] = getLevel(granular.Level)
+		if granular.Format != "" {
+			granFormatters[granular.Path] = newPatFormatter(granular.Format)
+		}
+		if granular.MaxLevel != "" {
+			if granMax == nil {
+				granMax = make(map[string]Level)
+			}
+			granMax[granular.Path] = getLevel(granular.MaxLevel)
+		}
+	}
+	configLogger := ConfigLogger{Level: level, MaxLevel: getLevel(filter.MaxLevel), Formatter: formatter, Granulars: granulars,
+		GranularFormatters: granFormatters, GranularMax: granMax, Tag: filter.Tag,
+		Prefix: filter.property("prefix"), Suffix: filter.property("suffix")}
+	configLogger.TimeWrites, _ = strconv.ParseBool(filter.property("time_writes"))
+	for _, name := range filter.properties("record_formatter") {
+		if configLogger.FieldFormatters == nil {
+			configLogger.FieldFormatters = make(map[string]LogFormatter)
+		}
+		if configLogger.FieldFormatters[name], err = namedFormatter(name, filter); err != nil {
+			return ConfigLogger{}, false, err
+		}
+	}
+	if configLogger.Sampler, err = samplerFromFilter(filter); err != nil {
+		return ConfigLogger{}, false, err
+	}
+	if configLogger.Filters, err = recordFiltersFromFilter(filter); err != nil {
+		return ConfigLogger{}, false, err
+	}
+	if configLogger.Redactors, err = redactorsFromFilter(filter); err != nil {
+		return ConfigLogger{}, false, err
+	}
+	var dedupWindow time.Duration
+	if window := filter.property("dedup_window"); window != "" {
+		if dedupWindow, err = time.ParseDuration(window); err != nil || dedupWindow <= 0 {
+			return ConfigLogger{}, false, fmt.Errorf("TIMBER! Bad dedup_window %q, expected a duration", window)
+		}
+	}
+	if !open {
+		return configLogger, true, nil
+	}
+
+	switch filter.Type {
+	case "console":
+		if configLogger.LogWriter, err = getConsoleWriter(filter); err != nil {
+			return ConfigLogger{}, false, err
+		}
+		colors, err := colorsFromFilter(filter)
+		if err != nil {
+			return ConfigLogger{}, false, err
+		}
+		levelOnly, err := colorLevelOnly(filter)
+		if err != nil {
+			return ConfigLogger{}, false, err
+		}
+		if colors != nil && colorWanted(filter, consoleStream(configLogger.LogWriter)) {
+			configLogger.Formatter = &ColorFormatter{Formatter: configLogger.Formatter, Colors: colors, LevelOnly: levelOnly}
+			for path, gFormatter := range granFormatters {
+				granFormatters[path] = &ColorFormatter{Formatter: gFormatter, Colors: colors, LevelOnly: levelOnly}
+			}
+		}
+	case "socket":
+		if configLogger.LogWriter, err = getJSONSocketWriter(filter); err != nil {
+			return ConfigLogger{}, false, err
+		}
+	case "file":
+		if configLogger.LogWriter, err = getJSONFileWriter(filter); err != nil {
+			return ConfigLogger{}, false, err
+		}
+	case "null":
+		configLogger.LogWriter = NullWriter{}
+	case "redis":
+		if configLogger.LogWriter, err = getRedisWriter(filter); err != nil {
+			return ConfigLogger{}, false, err
+		}
+	case "syslog":
+		if configLogger.LogWriter, err = getSyslogWriter(filter); err != nil {
+			return ConfigLogger{}, false, err
+		}
+	case "gelf":
+		if configLogger.LogWriter, err = getGELFWriter(filter); err != nil {
+			return ConfigLogger{}, false, err
+		}
+	case "tee":
+		if configLogger.LogWriter, err = getTeeWriter(filter); err != nil {
+			return ConfigLogger{}, false, err
+		}
+	case "journald":
+		if configLogger.LogWriter, err = NewJournaldWriter(filter.property("socket"), filter.property("identifier")); err != nil {
+			return ConfigLogger{}, false, err
+		}
+	case "eventlog":
+		source := filter.property("source")
+		if source == "" {
+			return ConfigLogger{}, false, fmt.Errorf("TIMBER! Missing source for eventlog log writer")
+		}
+		if configLogger.LogWriter, err = NewEventLogWriter(source); err != nil {
+			return ConfigLogger{}, false, err
+		}
+	default:
+		factory, ok := writerFactory(filter.Type)
+		if !ok {
+			log.Printf("TIMBER! Warning unrecognized filter in config file: %v\n", filter.Tag)
+			return ConfigLogger{}, false, nil
+		}
+		if configLogger.LogWriter, err = factory(filter); err != nil {
+			return ConfigLogger{}, false, err
+		}
+	}
+	if configLogger.LogWriter, err = batchFromFilter(filter, configLogger.LogWriter); err != nil {
+		return ConfigLogger{}, false, err
+	}
+	if configLogger.LogWriter, err = auditFromFilter(filter, configLogger.LogWriter); err != nil {
+		return ConfigLogger{}, false, err
+	}
+	if configLogger.LogWriter, err = fallbackFromFilter(filter, configLogger.LogWriter, configLogger.Formatter); err != nil {
+		return ConfigLogger{}, false, err
+	}
+	if dedupWindow > 0 {
+		configLogger.LogWriter = NewDedupWriter(configLogger.LogWriter, configLogger.Formatter, dedupWindow)
+	}
+
+	return configLogger, true, nil
+}
+
+// Puts a BatchingWriter in front of writer for the batch property, true
+// or gzip, with the limits of batch_count, batch_bytes and flush_interval.
+// A socket's gzip batches go out unframed unless framing says otherwise.
+// writer is closed on an error.
+func batchFromFilter(filter JSONFilter, writer LogWriter) (LogWriter, error) {
+	mode := filter.property("batch")
+	if mode == "" {
+		return writer, nil
+	}
+	compress := mode == "gzip"
+	if !compress {
+		on, err := strconv.ParseBool(mode)
+		if err != nil {
+			writer.Close()
+			return nil, fmt.Errorf("TIMBER! Bad batch %q, expected true, false or gzip", mode)
+		}
+		if !on {
+			return writer, nil
+		}
+	}
+	bc, err := filter.BatchConfig(DefaultBatch)
+	if err != nil {
+		writer.Close()
+		return nil, err
+	}
+	if sw, ok := writer.(*SocketWriter); ok && compress && filter.property("framing") == "" {
+		sw.SetFraming("")
+	}
+	bw := NewBatchingWriter(writer, bc)
+	bw.SetGzip(compress)
+	return bw, nil
+}
+
+// Puts an AuditWriter in front of writer for the audit_key property,
+// continuing the chain in the file of a file filter.  writer is closed on
+// an error.
+func auditFromFilter(filter JSONFilter, writer LogWriter) (LogWriter, error) {
+	key := filter.property("audit_key")
+	if key == "" {
+		return writer, nil
+	}
+	aw := NewAuditWriter(writer, []byte(key))
+	if filter.Type == "file" {
+		file, err := os.Open(filter.property("filename"))
+		if err == nil {
+			err = aw.Resume(file)
+			file.Close()
+		} else if os.IsNotExist(err) {
+			err = nil
+		}
+		if err != nil {
+			writer.Close()
+			return nil, err
+		}
+	}
+	return aw, nil
+}
+
+// Puts a FallbackWriter in front of primary for the fallback properties,
+// in order, each stdout, stderr or the name of a file shared with other
+// processes, and fallback_retry, a duration.  primary is closed on an
+// error.
+func fallbackFromFilter(filter JSONFilter, primary LogWriter, formatter LogFormatter) (LogWriter, error) {
+	names := filter.properties("fallback")
+	if len(names) == 0 {
+		return primary, nil
+	}
+	retry := DefaultFallbackRetry
+	var err error
+	if value := filter.property("fallback_retry"); value != "" {
+		if retry, err = time.ParseDuration(value); err != nil || retry < 0 {
+			primary.Close()
+			return nil, fmt.Errorf("TIMBER! Bad fallback_retry %q, expected a duration", value)
+		}
+	}
+	var fallbacks []LogWriter
+	for _, name := range names {
+		var fallback LogWriter
+		switch name {
+		case "stderr":
+			fallback = &ConsoleWriter{Stream: os.Stderr}
+		case "stdout":
+			fallback = &ConsoleWriter{Stream: os.Stdout}
+		default:
+			if fallback, err = NewSharedFileWriter(name); err != nil {
+				primary.Close()
+				closeWriters(fallbacks)
+				return nil, err
+			}
+		}
+		fallbacks = append(fallbacks, fallback)
+	}
+	fw := NewFallbackWriter(formatter, primary, fallbacks...)
+	fw.SetRetryInterval(retry)
+	return fw, nil
+}
+
+func closeWriters(writers []LogWriter) {
+	for _, w := range writers {
+		w.Close()
+	}
+}
+
+// The sampler for the sample and sample_keep_above properties, nil
+// without sample
+func samplerFromFilter(filter JSONFilter) (*Sampler, error) {
+	rate := filter.property("sample")
+	if rate == "" {
+		return nil, nil
+	}
+	n, err := strconv.Atoi(rate)
+	if err != nil || n < 1 {
+		return nil, fmt.Errorf("TIMBER! Bad sample %q, expected a whole number of 1 or more", rate)
+	}
+	sampler := NewSampler(n)
+	if keepAbove := filter.property("sample_keep_above"); keepAbove != "" {
+		if sampler.KeepAbove, err = ParseLevel(keepAbove); err != nil {
+			return nil, err
+		}
+	}
+	return sampler, nil
+}
+
+// The match filters of matchFiltersFromFilter, then a MessageSampler for
+// sample_identical (keep 1 of N, below sample_keep_above) and a RateLimiter for rate_limit
+// (records a second) with rate_burst and rate_by (level or package, one
+// bucket for everything without)
+func recordFiltersFromFilter(filter JSONFilter) ([]RecordFilter, error) {
+	filters, err := matchFiltersFromFilter(filter)
+	if err != nil {
+		return nil, err
+	}
+	if identical := filter.property("sample_identical"); identical != "" {
+		n, err := strconv.Atoi(identical)
+		if err != nil || n < 1 {
+			return nil, fmt.Errorf("TIMBER! Bad sample_identical %q, expected a whole number of 1 or more", identical)
+		}
+		sampler := NewMessageSampler(n)
+		if keepAbove := filter.property("sample_keep_above"); keepAbove != "" {
+			if sampler.KeepAbove, err = ParseLevel(keepAbove); err != nil {
+				return nil, err
+			}
+		}
+		filters = append(filters, sampler)
+	}
+	if limit := filter.property("rate_limit"); limit != "" {
+		rate, err := strconv.ParseFloat(limit, 64)
+		if err != nil || rate <= 0 {
+			return nil, fmt.Errorf("TIMBER! Bad rate_limit %q, expected records a second", limit)
+		}
+		burst := 0
+		if value := filter.property("rate_burst"); value != "" {
+			if burst, err = strconv.Atoi(value); err != nil {
+				return nil, fmt.Errorf("TIMBER! Bad rate_burst %q: %v", value, err)
+			}
+		}
+		var key RateKey
+		switch by := filter.property("rate_by"); by {
+		case "":
+		case "level":
+			key = RateByLevel
+		case "package":
+			key = RateByPackage
+		default:
+			return nil, fmt.Errorf("TIMBER! Unknown rate_by %q, expected level or package", by)
+		}
+		filters = append(filters, NewRateLimiter(rate, burst, key))
+	}
+	return filters, nil
+}
+
+// Routing by what's in the record, each property may repeat: a record
+// drop_message or drop_field (name=regexp) matches, or that's from a
+// drop_package, is dropped; then it has to match every keep_message and
+// keep_field and come from one of the keep_package paths (comma
+// separated), or just one of the keep_ conditions with keep_any true
+func matchFiltersFromFilter(filter JSONFilter) ([]RecordFilter, error) {
+	var drops, keeps []RecordFilter
+	for _, kind := range []struct {
+		name string
+		to   *[]RecordFilter
+	}{{"drop_", &drops}, {"keep_", &keeps}} {
+		for _, pattern := range filter.properties(kind.name + "message") {
+			re, err := regexp.Compile(pattern)
+			if err != nil {
+				return nil, fmt.Errorf("TIMBER! Bad %smessage %q: %v", kind.name, pattern, err)
+			}
+			*kind.to = append(*kind.to, MessageMatches(re))
+		}
+		for _, spec := range filter.properties(kind.name + "field") {
+			name, pattern, ok := strings.Cut(spec, "=")
+			if !ok || name == "" {
+				return nil, fmt.Errorf("TIMBER! Bad %sfield %q, expected name=regexp", kind.name, spec)
+			}
+			re, err := regexp.Compile(pattern)
+			if err != nil {
+				return nil, fmt.Errorf("TIMBER! Bad %sfield %q: %v", kind.name, spec, err)
+			}
+			*kind.to = append(*kind.to, FieldMatches(strings.TrimSpace(name), re))
+		}
+		var paths []string
+		for _, list := range filter.properties(kind.name + "package") {
+			for _, pkg := range strings.Split(list, ",") {
+				if pkg = strings.TrimSpace(pkg); pkg != "" {
+					paths = append(paths, pkg)
+				}
+			}
+		}
+		if len(paths) > 0 {
+			*kind.to = append(*kind.to, FromPackages(paths...))
+		}
+	}
+	var filters []RecordFilter
+	if len(drops) > 0 {
+		filters = append(filters, Not(AnyOf(drops...)))
+	}
+	if keepAny, _ := strconv.ParseBool(filter.property("keep_any")); keepAny && len(keeps) > 1 {
+		filters = append(filters, AnyOf(keeps...))
+	} else {
+		filters = append(filters, keeps...)
+	}
+	return filters, nil
+}
+
+// The static tags of a pattern from the tag_<name> properties and app,
+// nil if there are none
+func staticTagsFromFilter(filter JSONFilter) map[string]string {
+	var tags map[string]string
+	for _, prop := range filter.Properties {
+		name := strings.TrimPrefix(prop.Name, "tag_")
+		if prop.Name == "app" {
+			name = "app"
+		} else if name == prop.Name || name == "" {
+			continue
+		}
+		if tags == nil {
+			tags = make(map[string]string)
+		}
+		tags[name] = prop.Value
+	}
+	return tags
+}
+
+// Returns the value of the named property or "" if it's not set
+func (filter JSONFilter) property(name string) string {
+	for _, prop := range filter.Properties {
+		if prop.Name == name {
+			return prop.Value
+		}
+	}
+	return ""
+}
+
+// Returns the values of every property with the given name, for the ones
+// that can be repeated
+func (filter JSONFilter) properties(name string) []string {
+	var values []string
+	for _, prop := range filter.Properties {
+		if prop.Name == name {
+			values = append(values, prop.Value)
+		}
+	}
+	return values
+}
+
+// Enabled unless the enabled_env property names an environment variable
+// that's set to a boolean (1, true, 0, false...); then the variable wins.
+// One config can serve several environments this way.
+func (filter JSONFilter) enabled() bool {
+	if name := filter.property("enabled_env"); name != "" {
+		if val, set := os.LookupEnv(name); set {
+			if on, err := strconv.ParseBool(strings.TrimSpace(val)); err == nil {
+				return on
+			}
+		}
+	}
+	return filter.Enabled
+}
+
+// The optional stream property is stdout or stderr (the default)
+func getConsoleWriter(filter JSONFilter) (LogWriter, error) {
+	switch stream := filter.property("stream"); stream {
+	case "", "stderr":
+		return &ConsoleWriter{Stream: os.Stderr}, nil
+	case "stdout":
+		return &ConsoleWriter{Stream: os.Stdout}, nil
+	case "split":
+		threshold := ERROR
+		if value := filter.property("split_level"); value != "" {
+			lvl, err := ParseLevel(value)
+			if err != nil {
+				return nil, fmt.Errorf("TIMBER! Bad split_level for console log writer: %v", err)
+			}
+			threshold = lvl
+		}
+		return NewSplitConsoleWriter(threshold), nil
+	default:
+		return nil, fmt.Errorf("TIMBER! Unknown stream %q for console log writer, expected stdout, stderr or split", stream)
+	}
+}
+
+// The stream a console writer's color is decided by, stdout for a split one
+func consoleStream(w LogWriter) io.Writer {
+	if sw, ok := w.(*SplitConsoleWriter); ok {
+		return sw.out()
+	}
+	return w.(*ConsoleWriter).out()
+}
+
+func getRedisWriter(filter JSONFilter) (LogWriter, error) {
+	address, key := filter.property("address"), filter.property("key")
+	if address == "" || key == "" {
+		return nil, fmt.Errorf("TIMBER! Missing address or key for redis log writer")
+	}
+	return NewRedisWriter(address, key, filter.property("mode"),
+		filter.property("username"), filter.property("password"))
+}
+
+// network and address default to the local daemon, facility to user and
+// rfc (3164 or 5424) to 3164
+func getSyslogWriter(filter JSONFilter) (LogWriter, error) {
+	facility := 1
+	if name := filter.property("facility"); name != "" {
+		var err error
+		if facility, err = ParseSyslogFacility(name); err != nil {
+			return nil, err
+		}
+	}
+	format := SyslogRFC3164
+	switch rfc := filter.property("rfc"); rfc {
+	case "", "3164":
+	case "5424":
+		format = SyslogRFC5424
 	default:
-		log.Printf("TIMBER! Unknown config file type %v, only XML and JSON are supported types\n", ext)
+		return nil, fmt.Errorf("TIMBER! Unknown rfc %q for syslog log writer, expected 3164 or 5424", rfc)
+	}
+	w, err := NewSyslogWriter(filter.property("network"), filter.property("address"), facility, filter.property("tag"))
+	if err != nil {
+		return nil, err
+	}
+	w.SetFormat(format)
+	return w, nil
+}
+
+// A GELFWriter to the endpoint over protocol, udp unless it's set, with
+// compress (none, gzip or zlib), chunk_size (bytes, wan or lan) and host
+func getGELFWriter(filter JSONFilter) (LogWriter, error) {
+	endpoint := filter.property("endpoint")
+	if endpoint == "" {
+		return nil, fmt.Errorf("TIMBER! Missing endpoint for gelf log writer")
+	}
+	protocol := filter.property("protocol")
+	if protocol == "" {
+		protocol = "udp"
+	}
+	compression, err := ParseGELFCompression(filter.property("compress"))
+	if err != nil {
+		return nil, err
+	}
+	chunkSize := 0
+	switch size := filter.property("chunk_size"); size {
+	case "", "wan":
+		chunkSize = GELFChunkWAN
+	case "lan":
+		chunkSize = GELFChunkLAN
+	default:
+		if chunkSize, err = strconv.Atoi(size); err != nil || chunkSize <= 0 {
+			return nil, fmt.Errorf("TIMBER! Bad chunk_size %q for gelf log writer, expected bytes, wan or lan", size)
+		}
+	}
+	w, err := NewGELFWriter(protocol, endpoint, compression)
+	if err != nil {
+		return nil, err
+	}
+	w.SetChunkSize(chunkSize)
+	if host := filter.property("host"); host != "" {
+		w.SetHost(host)
+	}
+	return w, nil
+}
+
+// A TeeWriter with a branch for each of the filter's outputs.  An output
+// is a filter of its own without the tag or granulars: its type and
+// properties make the writer, its level is the lowest the branch takes
+// and its format, if it has one, the branch's formatter.  An output
+// without a format writes the message the filter's format made.  The
+// writers opened so far are closed on an error.
+func getTeeWriter(filter JSONFilter) (LogWriter, error) {
+	if len(filter.Outputs) == 0 {
+		return nil, fmt.Errorf("TIMBER! Missing outputs for tee log writer")
+	}
+	tw := NewTeeWriter()
+	for i, output := range filter.Outputs {
+		output = teeOutput(filter, output)
+		if _, registered := writerFactory(output.Type); !knownFilterTypes[output.Type] && !registered {
+			tw.Close()
+			return nil, fmt.Errorf("TIMBER! Unknown type %q in tee output %d", output.Type, i)
+		}
+		cLog, ok, err := buildFilterLogger(nil, output, true)
+		if err != nil {
+			tw.Close()
+			return nil, fmt.Errorf("%v in tee output %d", err, i)
+		}
+		if !ok {
+			// turned off by its enabled_env
+			continue
+		}
+		var formatter LogFormatter
+		if output.hasFormat() {
+			formatter = cLog.Formatter
+		}
+		tw.AddBranch(formatter, cLog.LogWriter, getLevel(output.Level))
+	}
+	return tw, nil
+}
+
+// The output as a filter to build: always enabled, with the tee's tag
+// and nothing that only a logger uses
+func teeOutput(tee, output JSONFilter) JSONFilter {
+	output.Enabled = true
+	output.Tag = tee.Tag
+	output.MaxLevel = ""
+	output.Granulars = nil
+	return output
+}
+
+// True if the filter sets a format of its own
+func (filter JSONFilter) hasFormat() bool {
+	return filter.Format != (JSONProperty{}) || filter.property("format") != "" || filter.property("formatter") != "" ||
+		len(filter.Formats) > 0
+}
+
+// Fills in any of level, format and granulars that the filter doesn't set
+func (defaults *JSONDefaults) applyTo(filter JSONFilter) JSONFilter {
+	if defaults == nil {
+		return filter
+	}
+	if filter.Level == "" {
+		filter.Level = defaults.Level
+	}
+	if filter.Format == (JSONProperty{}) && filter.property("format") == "" {
+		filter.Format = defaults.Format
+	}
+	if len(filter.Granulars) == 0 {
+		filter.Granulars = defaults.Granulars
+	}
+	return filter
+}
+
+// the filter types loadFilters knows how to build
+var knownFilterTypes = map[string]bool{"console": true, "socket": true, "file": true,
+	"redis": true, "eventlog": true, "null": true, "syslog": true, "journald": true, "gelf": true, "tee": true,
+	AccessLogType: true}
+
+// Builds the LogWriter for a filter from its properties
+type WriterFactory func(filter JSONFilter) (LogWriter, error)
+
+var writerFactories = struct {
+	sync.RWMutex
+	m map[string]WriterFactory
+}{m: make(map[string]WriterFactory)}
+
+// Makes a filter type available to the config loaders, e.g. for a writer
+// that lives outside of timber.  The built-in types always win, so
+// registering one of those has no effect; registering a name twice
+// replaces the first factory.
+func RegisterWriterFactory(typeName string, factory WriterFactory) {
+	if factory == nil {
+		panic("TIMBER! RegisterWriterFactory factory is nil for " + typeName)
+	}
+	writerFactories.Lock()
+	writerFactories.m[typeName] = factory
+	writerFactories.Unlock()
+}
+
+// Builds a LogFormatter for a filter from its properties
+type FormatterFactory func(filter JSONFilter) (LogFormatter, error)
+
+var formatterFactories = struct {
+	sync.RWMutex
+	m map[string]FormatterFactory
+}{m: make(map[string]FormatterFactory)}
+
+// Makes a formatter available to the config loaders under name; a filter
+// selects it with a formatter property.  Registering a name twice
+// replaces the first factory.
+func RegisterFormatterFactory(name string, factory FormatterFactory) {
+	if factory == nil {
+		panic("TIMBER! RegisterFormatterFactory factory is nil for " + name)
+	}
+	formatterFactories.Lock()
+	formatterFactories.m[name] = factory
+	formatterFactories.Unlock()
+}
+
+// Builds the registered formatter called name for filter; "json" is the
+// JSONFormatter unless something else is registered under that name
+func namedFormatter(name string, filter JSONFilter) (LogFormatter, error) {
+	if factory, ok := formatterFactory(name); ok {
+		return factory(filter)
+	}
+	switch name {
+	case "json":
+		return NewJSONFormatter(), nil
+	case "logfmt":
+		return NewLogfmtFormatter(), nil
+	}
+	return nil, fmt.Errorf("TIMBER! Unknown formatter %q", name)
+}
+
+func formatterFactory(name string) (FormatterFactory, bool) {
+	formatterFactories.RLock()
+	defer formatterFactories.RUnlock()
+	factory, ok := formatterFactories.m[name]
+	return factory, ok
+}
+
+func writerFactory(typeName string) (WriterFactory, bool) {
+	writerFactories.RLock()
+	defer writerFactories.RUnlock()
+	factory, ok := writerFactories.m[typeName]
+	return factory, ok
+}
+
+// Checks a parsed config for the mistakes the loaders would otherwise
+// quietly work around: level names that don't parse (they'd become NONE
+// and log everything), filter types that would be skipped, granular
+// regexps that don't compile and tags used by more than one filter (the
+// last one would win).  Empty
+// levels and disabled filters are fine.  Returns every problem found, nil if there are none.
+func ValidateJSONConfig(config JSONConfig) []error {
+	var errs []error
+	for _, problem := range configProblems(config) {
+		errs = append(errs, problem.err)
+	}
+	return errs
+}
+
+// A problem ValidateJSONConfig finds and the filter it's in, -1 for the
+// defaults
+type configProblem struct {
+	filter int
+	tag    string
+	err    error
+}
+
+func configProblems(config JSONConfig) []configProblem {
+	var problems []configProblem
+	filterIndex, filterTag := -1, ""
+	add := func(err error) {
+		problems = append(problems, configProblem{filter: filterIndex, tag: filterTag, err: err})
+	}
+	checkLevel := func(where, lvl string) {
+		if strings.TrimSpace(lvl) == "" {
+			return
+		}
+		if _, err := ParseLevel(lvl); err != nil {
+			add(fmt.Errorf("%v in %s", err, where))
+		}
+	}
+	if config.Defaults != nil {
+		checkLevel("defaults", config.Defaults.Level)
+		for _, granular := range config.Defaults.Granulars {
+			checkLevel("defaults granular "+granular.Path, granular.Level)
+			if err := CheckGranularPath(granular.Path); err != nil {
+				add(fmt.Errorf("%v in defaults", err))
+			}
+		}
+	}
+	tags := make(map[string]bool)
+	for i, filter := range config.Filters {
+		if !filter.enabled() {
+			continue
+		}
+		filterIndex, filterTag = i, filter.Tag
+		where := fmt.Sprintf("filter %d (%s)", i, filter.Tag)
+		if filter.Tag != "" {
+			if tags[filter.Tag] {
+				add(fmt.Errorf("TIMBER! Duplicate logger tag %q in %s", filter.Tag, where))
+			}
+			tags[filter.Tag] = true
+		}
+		if _, registered := writerFactory(filter.Type); !knownFilterTypes[filter.Type] && !registered {
+			add(fmt.Errorf("TIMBER! Unknown filter type %q in %s", filter.Type, where))
+		}
+		checkLevel(where, filter.Level)
+		checkLevel(where+" max", filter.MaxLevel)
+		for _, format := range filter.Formats {
+			checkLevel(where+" level format", format.Level)
+		}
+		for _, granular := range filter.Granulars {
+			checkLevel(where+" granular "+granular.Path, granular.Level)
+			checkLevel(where+" granular "+granular.Path+" max", granular.MaxLevel)
+			if err := CheckGranularPath(granular.Path); err != nil {
+				add(fmt.Errorf("%v in %s", err, where))
+			}
+		}
 	}
+	return problems
 }
@@ -0,0 +1,178 @@
+package timber
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// Builds a config in code with the same filters, properties and checks as
+// the config files, e.g.
+//
+//	err := timber.NewConfig().
+//		Console(timber.INFO, "%T %L %M").
+//		File(timber.DEBUG, "app.log", timber.WithRotation(timber.Rotation{Period: timber.RotateDaily})).
+//		Granular("github.com/me/app/db", timber.TRACE).
+//		Apply(timber.Global)
+//
+// Each filter method adds a logger; the options change that one and
+// Granular changes them all.  Nothing is opened until Apply.
+type ConfigBuilder struct {
+	filters   []JSONFilter
+	granulars []JSONGranular
+}
+
+// Changes the filter a ConfigBuilder method is adding
+type FilterOption func(filter *JSONFilter)
+
+func NewConfig() *ConfigBuilder {
+	return new(ConfigBuilder)
+}
+
+// A console logger writing format patterns, to stderr unless WithProperty
+// sets stream to stdout
+func (b *ConfigBuilder) Console(lvl Level, format string, opts ...FilterOption) *ConfigBuilder {
+	return b.Filter("console", lvl, append([]FilterOption{WithFormat(format)}, opts...)...)
+}
+
+// A file logger, see WithRotation for the rotating kind
+func (b *ConfigBuilder) File(lvl Level, filename string, opts ...FilterOption) *ConfigBuilder {
+	return b.Filter("file", lvl, append([]FilterOption{WithProperty("filename", filename)}, opts...)...)
+}
+
+// A socket logger, network is tcp, udp, unix, unixgram or tls
+func (b *ConfigBuilder) Socket(lvl Level, network, endpoint string, opts ...FilterOption) *ConfigBuilder {
+	return b.Filter("socket", lvl, append([]FilterOption{WithProperty("protocol", network),
+		WithProperty("endpoint", endpoint)}, opts...)...)
+}
+
+// A logger of any filter type the config files take, the ones that
+// RegisterWriterFactory added included; the options set its properties
+func (b *ConfigBuilder) Filter(typeName string, lvl Level, opts ...FilterOption) *ConfigBuilder {
+	filter := JSONFilter{Enabled: true, Type: typeName, Level: levelName(lvl)}
+	for _, opt := range opts {
+		opt(&filter)
+	}
+	b.filters = append(b.filters, filter)
+	return b
+}
+
+// A granular level for every logger, the ones added after it too.  Use
+// WithGranular for a single logger.
+func (b *ConfigBuilder) Granular(path string, lvl Level) *ConfigBuilder {
+	b.granulars = append(b.granulars, JSONGranular{Path: path, Level: levelName(lvl)})
+	return b
+}
+
+// The config the builder made, e.g. for ValidateJSONConfig
+func (b *ConfigBuilder) Config() JSONConfig {
+	config := JSONConfig{}
+	for _, filter := range b.filters {
+		if len(b.granulars) > 0 {
+			filter.Granulars = append([]JSONGranular(nil), filter.Granulars...)
+			for _, granular := range b.granulars {
+				filter.Granulars = setGranular(filter.Granulars, granular)
+			}
+		}
+		config.Filters = append(config.Filters, filter)
+	}
+	return config
+}
+
+// Adds the loggers to t, like loading a config file with the same
+// filters.  The config is always checked as StrictConfig would, so a bad
+// level or an unknown filter type is an error and nothing is added.
+func (b *ConfigBuilder) Apply(t *Timber) error {
+	config := b.Config()
+	if errs := ValidateJSONConfig(config); len(errs) > 0 {
+		return errors.Join(errs...)
+	}
+	return t.loadFilters(config)
+}
+
+// The name a config file would use, one ParseLevel rejects for a level
+// that doesn't exist
+func levelName(lvl Level) string {
+	if lvl < 0 || int(lvl) >= len(LongLevelStrings) {
+		return fmt.Sprintf("Level(%d)", int(lvl))
+	}
+	return LongLevelStrings[lvl]
+}
+
+// A granular replaces one for the same path
+func setGranular(granulars []JSONGranular, granular JSONGranular) []JSONGranular {
+	for i := range granulars {
+		if granulars[i].Path == granular.Path {
+			granulars[i] = granular
+			return granulars
+		}
+	}
+	return append(granulars, granular)
+}
+
+// The pattern for the logger's records
+func WithFormat(pattern string) FilterOption {
+	return func(filter *JSONFilter) { filter.Format = JSONProperty{Name: "pattern", Value: pattern} }
+}
+
+// One of the filter type's properties, as in a config file.  Properties
+// that repeat, like mask, can be given more than once.
+func WithProperty(name, value string) FilterOption {
+	return func(filter *JSONFilter) {
+		filter.Properties = append(filter.Properties, JSONProperty{Name: name, Value: value})
+	}
+}
+
+// The tag for SetLevelFor and friends
+func WithTag(tag string) FilterOption {
+	return func(filter *JSONFilter) { filter.Tag = tag }
+}
+
+// The highest level the logger takes, see ConfigLogger.MaxLevel
+func WithMaxLevel(lvl Level) FilterOption {
+	return func(filter *JSONFilter) { filter.MaxLevel = levelName(lvl) }
+}
+
+// A granular level for just this logger
+func WithGranular(path string, lvl Level) FilterOption {
+	return func(filter *JSONFilter) {
+		filter.Granulars = setGranular(filter.Granulars, JSONGranular{Path: path, Level: levelName(lvl)})
+	}
+}
+
+// How a file logger rotates, the file config's max_size, rotate,
+// max_backups, max_age and compress properties
+type Rotation struct {
+	MaxSize    int64 // bytes, 0 for no size limit
+	Period     RotatePeriod
+	MaxBackups int
+	MaxAge     time.Duration
+	Compress   bool
+}
+
+func WithRotation(r Rotation) FilterOption {
+	return func(filter *JSONFilter) {
+		set := func(name, value string) {
+			filter.Properties = append(filter.Properties, JSONProperty{Name: name, Value: value})
+		}
+		if r.MaxSize > 0 {
+			set("max_size", strconv.FormatInt(r.MaxSize, 10))
+		}
+		switch r.Period {
+		case RotateHourly:
+			set("rotate", "hourly")
+		case RotateDaily:
+			set("rotate", "daily")
+		}
+		if r.MaxBackups > 0 {
+			set("max_backups", strconv.Itoa(r.MaxBackups))
+		}
+		if r.MaxAge > 0 {
+			set("max_age", r.MaxAge.String())
+		}
+		if r.Compress {
+			set("compress", "true")
+		}
+	}
+}
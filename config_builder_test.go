@@ -0,0 +1,46 @@
+package timber
+
+import (
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestConfigBuilder(t *testing.T) {
+	b := NewConfig().
+		Console(INFO, "%T %L %M", WithTag("stderr"), WithMaxLevel(ERROR)).
+		File(DEBUG, "app.log", WithRotation(Rotation{MaxSize: 1 << 20, Period: RotateDaily, MaxAge: time.Hour, Compress: true}),
+			WithGranular("pkg/db", FINE)).
+		Granular("pkg/db", TRACE).
+		Granular("pkg/api", WARNING)
+	expected := JSONConfig{Filters: []JSONFilter{
+		{Enabled: true, Type: "console", Tag: "stderr", Level: "INFO", MaxLevel: "ERROR",
+			Format:    JSONProperty{Name: "pattern", Value: "%T %L %M"},
+			Granulars: []JSONGranular{{Path: "pkg/db", Level: "TRACE"}, {Path: "pkg/api", Level: "WARNING"}}},
+		{Enabled: true, Type: "file", Level: "DEBUG",
+			Properties: []JSONProperty{{Name: "filename", Value: "app.log"}, {Name: "max_size", Value: "1048576"},
+				{Name: "rotate", Value: "daily"}, {Name: "max_age", Value: "1h0m0s"}, {Name: "compress", Value: "true"}},
+			Granulars: []JSONGranular{{Path: "pkg/db", Level: "TRACE"}, {Path: "pkg/api", Level: "WARNING"}}},
+	}}
+	if config := b.Config(); !reflect.DeepEqual(config, expected) {
+		t.Errorf("expected %+v got %+v", expected, config)
+	}
+
+	log := NewTimber()
+	name := filepath.Join(t.TempDir(), "built.log")
+	if err := NewConfig().File(INFO, name, WithTag("file")).Granular("pkg/db", TRACE).Apply(log); err != nil {
+		t.Fatal(err)
+	}
+	levels := log.Levels()
+	if len(levels) != 1 || levels[0].Tag != "file" || levels[0].Level != INFO || levels[0].Granulars["pkg/db"] != TRACE {
+		t.Errorf("unexpected loggers %+v", levels)
+	}
+	log.Close()
+
+	err := NewConfig().Console(Level(42), "%M").Filter("carrier-pigeon", INFO).Apply(NewTimber())
+	if err == nil || !strings.Contains(err.Error(), "Level(42)") || !strings.Contains(err.Error(), "carrier-pigeon") {
+		t.Errorf("expected errors for the level and the type got %v", err)
+	}
+}
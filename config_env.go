@@ -0,0 +1,194 @@
+package timber
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+)
+
+// Environment variables that change a config after it's read, for
+// deployments where the file is baked into an image:
+//
+//	TIMBER_LEVEL=DEBUG                          every filter's level
+//	TIMBER_GRANULAR_github.com/me/app/db=FINE   a granular in every filter
+//
+// A granular path from the environment replaces the filter's granular for
+// the same path, or the defaults' if the filter has none of its own.
+const (
+	EnvLevel          = "TIMBER_LEVEL"
+	EnvGranularPrefix = "TIMBER_GRANULAR_"
+)
+
+// What every config loader does between reading the file and building the
+// loggers: ${VAR} expansion, the strict checks then the overrides
+func prepareConfig(config JSONConfig) (JSONConfig, error) {
+	config = expandConfigEnv(config)
+	if err := checkStrict(config); err != nil {
+		return config, err
+	}
+	return applyEnvOverrides(config, os.Environ())
+}
+
+// Expands ${VAR} in the property values, formats and levels of config.
+// ${VAR:-default} gives default when VAR isn't set or is empty.  A
+// variable that isn't set and has no default is left as ${VAR}, so a
+// mask replacement like ${1} or ${user} keeps working, and $${ is a
+// literal ${.
+func expandConfigEnv(config JSONConfig) JSONConfig {
+	if config.Defaults != nil {
+		defaults := *config.Defaults
+		defaults.Level = expandEnv(defaults.Level)
+		defaults.Format.Value = expandEnv(defaults.Format.Value)
+		defaults.Granulars = expandGranularsEnv(defaults.Granulars)
+		config.Defaults = &defaults
+	}
+	config.Filters = expandFiltersEnv(config.Filters)
+	return config
+}
+
+// Copies of the filters, their tee outputs included, expanded
+func expandFiltersEnv(filters []JSONFilter) []JSONFilter {
+	if filters == nil {
+		return nil
+	}
+	expanded := make([]JSONFilter, len(filters))
+	for i, filter := range filters {
+		filter.Level = expandEnv(filter.Level)
+		filter.MaxLevel = expandEnv(filter.MaxLevel)
+		filter.Format.Value = expandEnv(filter.Format.Value)
+		filter.Formats = append([]JSONLevelFormat(nil), filter.Formats...)
+		for j := range filter.Formats {
+			filter.Formats[j].Level = expandEnv(filter.Formats[j].Level)
+			filter.Formats[j].Format = expandEnv(filter.Formats[j].Format)
+		}
+		filter.Properties = append([]JSONProperty(nil), filter.Properties...)
+		for j := range filter.Properties {
+			filter.Properties[j].Value = expandEnv(filter.Properties[j].Value)
+		}
+		filter.Granulars = expandGranularsEnv(filter.Granulars)
+		filter.Outputs = expandFiltersEnv(filter.Outputs)
+		expanded[i] = filter
+	}
+	return expanded
+}
+
+func expandGranularsEnv(granulars []JSONGranular) []JSONGranular {
+	granulars = append([]JSONGranular(nil), granulars...)
+	for i := range granulars {
+		granulars[i].Level = expandEnv(granulars[i].Level)
+		granulars[i].MaxLevel = expandEnv(granulars[i].MaxLevel)
+		granulars[i].Format = expandEnv(granulars[i].Format)
+	}
+	return granulars
+}
+
+func expandEnv(s string) string {
+	if !strings.Contains(s, "${") {
+		return s
+	}
+	var b strings.Builder
+	for {
+		idx := strings.Index(s, "${")
+		if idx < 0 {
+			b.WriteString(s)
+			return b.String()
+		}
+		if idx > 0 && s[idx-1] == '$' {
+			b.WriteString(s[:idx-1])
+			b.WriteString("${")
+			s = s[idx+2:]
+			continue
+		}
+		b.WriteString(s[:idx])
+		end := strings.IndexByte(s[idx:], '}')
+		if end < 0 {
+			b.WriteString(s[idx:])
+			return b.String()
+		}
+		ref := s[idx+2 : idx+end]
+		name, def, hasDefault := strings.Cut(ref, ":-")
+		if val, set := os.LookupEnv(name); set && isEnvName(name) && (val != "" || !hasDefault) {
+			b.WriteString(val)
+		} else if hasDefault && isEnvName(name) {
+			b.WriteString(def)
+		} else {
+			b.WriteString(s[idx : idx+end+1])
+		}
+		s = s[idx+end+1:]
+	}
+}
+
+// Names like the shell's, which leaves out regexp groups like ${1}
+func isEnvName(name string) bool {
+	for i, c := range name {
+		if !(c == '_' || c >= 'A' && c <= 'Z' || c >= 'a' && c <= 'z' || i > 0 && c >= '0' && c <= '9') {
+			return false
+		}
+	}
+	return name != ""
+}
+
+// Applies EnvLevel and the EnvGranularPrefix variables in environ to
+// every filter.  A bad level is an error whatever StrictConfig says, a
+// typo in the environment shouldn't quietly log everything.
+func applyEnvOverrides(config JSONConfig, environ []string) (JSONConfig, error) {
+	var level string
+	granulars := make(map[string]string)
+	for _, kv := range environ {
+		name, val, _ := strings.Cut(kv, "=")
+		if val == "" {
+			continue
+		}
+		switch {
+		case name == EnvLevel:
+			level = val
+		case strings.HasPrefix(name, EnvGranularPrefix) && len(name) > len(EnvGranularPrefix):
+			granulars[name[len(EnvGranularPrefix):]] = val
+		default:
+			continue
+		}
+		if _, err := ParseLevel(val); err != nil {
+			return config, fmt.Errorf("%v in %s", err, name)
+		}
+	}
+	if level == "" && len(granulars) == 0 {
+		return config, nil
+	}
+	paths := make([]string, 0, len(granulars))
+	for path := range granulars {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	filters := make([]JSONFilter, len(config.Filters))
+	for i, filter := range config.Filters {
+		if level != "" {
+			filter.Level = level
+		}
+		if len(paths) > 0 {
+			if len(filter.Granulars) == 0 && config.Defaults != nil {
+				filter.Granulars = config.Defaults.Granulars
+			}
+			filter.Granulars = overrideGranulars(filter.Granulars, paths, granulars)
+		}
+		filters[i] = filter
+	}
+	config.Filters = filters
+	return config, nil
+}
+
+func overrideGranulars(current []JSONGranular, paths []string, levels map[string]string) []JSONGranular {
+	granulars := append([]JSONGranular(nil), current...)
+	for _, path := range paths {
+		found := false
+		for i := range granulars {
+			if granulars[i].Path == path {
+				granulars[i].Level, found = levels[path], true
+			}
+		}
+		if !found {
+			granulars = append(granulars, JSONGranular{Path: path, Level: levels[path]})
+		}
+	}
+	return granulars
+}
@@ -0,0 +1,76 @@
+package timber
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestExpandEnv(t *testing.T) {
+	t.Setenv("TIMBER_TEST_DIR", "/var/log")
+	t.Setenv("TIMBER_TEST_EMPTY", "")
+	for in, expected := range map[string]string{
+		"${TIMBER_TEST_DIR}/app.log":     "/var/log/app.log",
+		"${TIMBER_TEST_UNSET:-INFO}":     "INFO",
+		"${TIMBER_TEST_EMPTY:-WARNING}":  "WARNING",
+		"${TIMBER_TEST_EMPTY}x":          "x",
+		"${TIMBER_TEST_UNSET}":           "${TIMBER_TEST_UNSET}",
+		"user=${1} ${user}":              "user=${1} ${user}",
+		"$${TIMBER_TEST_DIR} ${unclosed": "${TIMBER_TEST_DIR} ${unclosed",
+	} {
+		if got := expandEnv(in); got != expected {
+			t.Errorf("expected %q for %q got %q", expected, in, got)
+		}
+	}
+}
+
+func TestConfigEnv(t *testing.T) {
+	t.Setenv("TIMBER_TEST_LEVEL", "ERROR")
+	t.Setenv("TIMBER_TEST_FILE", "env.log")
+	config := JSONConfig{Filters: []JSONFilter{{Enabled: true, Type: "null", Level: "${TIMBER_TEST_LEVEL}",
+		Properties: []JSONProperty{{Name: "filename", Value: "/tmp/${TIMBER_TEST_FILE}"}}}}}
+	prepared, err := prepareConfig(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	filter := prepared.Filters[0]
+	if filter.Level != "ERROR" || filter.property("filename") != "/tmp/env.log" {
+		t.Errorf("expected the variables expanded got %+v", filter)
+	}
+	if config.Filters[0].Properties[0].Value != "/tmp/${TIMBER_TEST_FILE}" {
+		t.Error("expected the original config left alone")
+	}
+
+	config = JSONConfig{
+		Defaults: &JSONDefaults{Granulars: []JSONGranular{{Path: "a", Level: "INFO"}, {Path: "b", Level: "INFO"}}},
+		Filters:  []JSONFilter{{Level: "INFO"}, {Granulars: []JSONGranular{{Path: "c", Level: "FINE"}}}},
+	}
+	overridden, err := applyEnvOverrides(config, []string{"PATH=/bin", "TIMBER_LEVEL=debug",
+		"TIMBER_GRANULAR_b=ERROR", "TIMBER_GRANULAR_github.com/foo/bar=WARNING", "TIMBER_GRANULAR_=INFO"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []JSONFilter{
+		{Level: "debug", Granulars: []JSONGranular{{Path: "a", Level: "INFO"}, {Path: "b", Level: "ERROR"},
+			{Path: "github.com/foo/bar", Level: "WARNING"}}},
+		{Level: "debug", Granulars: []JSONGranular{{Path: "c", Level: "FINE"}, {Path: "b", Level: "ERROR"},
+			{Path: "github.com/foo/bar", Level: "WARNING"}}},
+	}
+	if !reflect.DeepEqual(overridden.Filters, expected) {
+		t.Errorf("expected %+v got %+v", expected, overridden.Filters)
+	}
+	if _, err := applyEnvOverrides(config, []string{"TIMBER_LEVEL=LOUD"}); err == nil || !strings.Contains(err.Error(), "TIMBER_LEVEL") {
+		t.Errorf("expected a bad level error got %v", err)
+	}
+
+	// through a loader
+	t.Setenv(EnvLevel, "CRITICAL")
+	log := NewTimber()
+	if err := log.LoadJSONConfigString(`{"filters": [{"enabled": true, "type": "null", "level": "INFO"}]}`); err != nil {
+		t.Fatal(err)
+	}
+	if levels := log.Levels(); len(levels) != 1 || levels[0].Level != CRITICAL {
+		t.Errorf("expected the environment level got %+v", levels)
+	}
+	log.Close()
+}
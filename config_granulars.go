@@ -0,0 +1,79 @@
+package timber
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+)
+
+// Loads a file of granular overrides and merges them into every logger
+// that's already been added, e.g. to turn on debug logging for a
+// package during an investigation without touching the main config.
+// The file is a JSON object from package path (or package path +
+// function name, or a pattern like github.com/me/app/...) to level:
+//
+//	{
+//	  "github.com/me/app/db": "DEBUG",
+//	  "github.com/me/app/api.(*Server).handle": "FINEST"
+//	}
+//
+// Paths in the file replace the loggers' granulars for the same path and
+// the rest are left alone, so calling it again after editing the file
+// applies the changes.  An entry removed from the file stays in effect
+// until it's set to another level.  Nothing is applied if any level is
+// unknown or any pattern doesn't compile.
+func (t *Timber) LoadGranulars(filename string) error {
+	file, err := os.Open(filename)
+	if err != nil {
+		return fmt.Errorf("TIMBER! Can't load granulars file: %s %v", filename, err)
+	}
+	defer file.Close()
+
+	if err = t.LoadGranularsReader(file); err != nil {
+		return fmt.Errorf("%v (%s)", err, filename)
+	}
+	return nil
+}
+
+// Same as LoadGranulars from any reader
+func (t *Timber) LoadGranularsReader(r io.Reader) error {
+	entries := make(map[string]string)
+	if err := json.NewDecoder(r).Decode(&entries); err != nil {
+		return fmt.Errorf("TIMBER! Can't parse granulars: %v", err)
+	}
+	granulars := make(map[string]Level, len(entries))
+	for path, lvlString := range entries {
+		lvl, err := ParseLevel(lvlString)
+		if err != nil {
+			return fmt.Errorf("%v for granular %s", err, path)
+		}
+		if err := CheckGranularPath(path); err != nil {
+			return err
+		}
+		granulars[path] = lvl
+	}
+	tcChan := make(chan int, 1)
+	if !t.configure(timberConfig{Action: actionGranulars, Granulars: granulars, Ret: tcChan}) {
+		return fmt.Errorf("TIMBER! Can't load granulars, the logger is closed")
+	}
+	<-tcChan
+	return nil
+}
+
+// Merges granulars into each logger's own.  The maps are copied since
+// they may be shared with the caller that added the logger.
+func mergeGranulars(cls []ConfigLogger, granulars map[string]Level) {
+	for i := range cls {
+		merged := make(map[string]Level, len(cls[i].Granulars)+len(granulars))
+		for path, lvl := range cls[i].Granulars {
+			merged[path] = lvl
+		}
+		for path, lvl := range granulars {
+			merged[path] = lvl
+		}
+		cls[i].Granulars = merged
+	}
+}
+
+func LoadGranulars(filename string) error { return global().LoadGranulars(filename) }
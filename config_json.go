@@ -1,18 +1,30 @@
 package timber
 
 import (
+	"bytes"
+	"compress/gzip"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
 	"encoding/json"
 	"fmt"
-	"log"
+	"io"
 	"os"
 	"reflect"
+	"strconv"
+	"strings"
+	"time"
 )
 
 // Granulars are overriding levels that can be either
-// package paths or package path + function name
+// package paths or package path + function name.  Format optionally
+// overrides the filter's pattern for records matching the path and
+// MaxLevel drops the ones above it, so Level and MaxLevel make a range.
 type JSONGranular struct {
-	Level string `xml:"level"`
-	Path  string `xml:"path"`
+	Level    string `xml:"level"`
+	Path     string `xml:"path"`
+	Format   string `xml:"format"`
+	MaxLevel string `xml:"maxlevel"`
 }
 
 type JSONProperty struct {
@@ -20,77 +32,144 @@ type JSONProperty struct {
 	Value string `xml:"value"`
 }
 
+// A pattern used instead of the filter format for records at Level
+type JSONLevelFormat struct {
+	Level  string
+	Format string
+}
+
 type JSONFilter struct {
 	Enabled    bool
 	Tag        string
 	Type       string
 	Level      string
+	MaxLevel   string
 	Format     JSONProperty
+	Formats    []JSONLevelFormat
 	Properties []JSONProperty
 	Granulars  []JSONGranular
+	// the formatters and writers of a tee filter, see getTeeWriter
+	Outputs []JSONFilter
+}
+
+// Values inherited by every filter that doesn't set its own
+type JSONDefaults struct {
+	Level     string
+	Format    JSONProperty
+	Granulars []JSONGranular
 }
 
 type JSONConfig struct {
-	Filters []JSONFilter
+	Defaults *JSONDefaults
+	Filters  []JSONFilter
 }
 
 // Loads the configuration from an JSON file (as you were probably expecting)
 func (t *Timber) LoadJSONConfig(filename string) (error) {
+	config, err := readJSONConfigFile(filename)
+	if err != nil {
+		return err
+	}
+	if err = t.loadFilters(config); err != nil {
+		return fmt.Errorf("%v (%s)", err, filename)
+	}
+	return nil
+}
+
+func readJSONConfigFile(filename string) (JSONConfig, error) {
 	if len(filename) <= 0 {
-		return fmt.Errorf("Empty filename")
+		return JSONConfig{}, fmt.Errorf("Empty filename")
 	}
 
 	file, err := os.Open(filename)
 	if err != nil {
-		return fmt.Errorf("TIMBER! Can't load json config file: %s %v", filename, err)
+		return JSONConfig{}, fmt.Errorf("TIMBER! Can't load json config file: %s %v", filename, err)
 	}
 	defer file.Close()
 
+	config, err := readJSONConfig(file)
+	if err != nil {
+		return config, fmt.Errorf("%v (%s)", err, filename)
+	}
+	return config, nil
+}
+
+// Loads JSON configuration from any reader, e.g. an embedded or generated
+// config.  The config may be gzip compressed, base64 encoded or both
+// (base64 of the gzip bytes); that's detected from the content.
+func (t *Timber) LoadJSONConfigReader(r io.Reader) error {
+	config, err := readJSONConfig(r)
+	if err != nil {
+		return err
+	}
+	return t.loadFilters(config)
+}
+
+func readJSONConfig(r io.Reader) (JSONConfig, error) {
 	config := JSONConfig{}
-	err = json.NewDecoder(file).Decode(&config)
+	data, err := io.ReadAll(r)
 	if err != nil {
-		return fmt.Errorf("TIMBER! Can't parse json config file: %s %v", filename, err)
+		return config, fmt.Errorf("TIMBER! Can't read json config: %v", err)
 	}
+	if data, err = decodeConfig(data); err != nil {
+		return config, err
+	}
+	if err := json.NewDecoder(bytes.NewReader(data)).Decode(&config); err != nil {
+		return config, fmt.Errorf("TIMBER! Can't parse json config: %v", err)
+	}
+	return config, nil
+}
 
-	for _, filter := range config.Filters {
-		if !filter.Enabled {
-			continue
-		}
-		level := getLevel(filter.Level)
-		formatter := getJSONFormatter(filter)
-		if err != nil {
-			return err
-		}
-		granulars := make(map[string]Level)
-		for _, granular := range filter.Granulars {
-			granulars[granular.Path] = getLevel(granular.Level)
-		}
-		configLogger := ConfigLogger{Level: level, Formatter: formatter, Granulars: granulars}
+var gzipMagic = []byte{0x1f, 0x8b}
 
-		switch filter.Type {
-		case "console":
-			configLogger.LogWriter = new(ConsoleWriter)
-		case "socket":
-			configLogger.LogWriter, err = getJSONSocketWriter(filter)
-			if err != nil {
-				return err
-			}
-		case "file":
-			configLogger.LogWriter, err = getJSONFileWriter(filter)
-			if err != nil {
-				return err
-			}
-		default:
-			log.Printf("TIMBER! Warning unrecognized filter in config file: %v\n", filter.Tag)
-			continue
+// Unwraps gzip compressed and base64 encoded config.  Anything that
+// doesn't start like a JSON object and isn't valid base64 comes back as
+// is for the JSON decoder to complain about.
+func decodeConfig(data []byte) ([]byte, error) {
+	trimmed := bytes.TrimSpace(data)
+	if len(trimmed) > 0 && trimmed[0] != '{' && !bytes.HasPrefix(data, gzipMagic) {
+		if decoded, err := base64.StdEncoding.DecodeString(string(trimmed)); err == nil {
+			data = decoded
 		}
-
-		t.AddLogger(configLogger)
 	}
-	return nil
+	if !bytes.HasPrefix(data, gzipMagic) {
+		return data, nil
+	}
+	zr, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("TIMBER! Can't decompress json config: %v", err)
+	}
+	defer zr.Close()
+	if data, err = io.ReadAll(zr); err != nil {
+		return nil, fmt.Errorf("TIMBER! Can't decompress json config: %v", err)
+	}
+	return data, nil
+}
+
+// Loads JSON configuration held in a string
+func (t *Timber) LoadJSONConfigString(config string) error {
+	return t.LoadJSONConfigReader(strings.NewReader(config))
 }
 
-func getJSONFormatter(filter JSONFilter) LogFormatter {
+// Loads JSON configuration held in a byte slice
+func (t *Timber) LoadJSONConfigBytes(config []byte) error {
+	return t.LoadJSONConfigReader(bytes.NewReader(config))
+}
+
+// A filter's formatter property or a format name other than pattern
+// picks a registered formatter, the JSONFormatter or the LogfmtFormatter,
+// otherwise it gets a pattern formatter
+func getJSONFormatter(filter JSONFilter) (LogFormatter, error) {
+	if name := filter.property("formatter"); name != "" {
+		factory, ok := formatterFactory(name)
+		if !ok {
+			return nil, fmt.Errorf("TIMBER! Unknown formatter %q", name)
+		}
+		return factory(filter)
+	}
+	if name := filter.Format.Name; name != "" && name != "pattern" {
+		return namedFormatter(name, filter)
+	}
 	format := ""
 	property := JSONProperty{}
 
@@ -106,11 +185,12 @@ func getJSONFormatter(filter JSONFilter) LogFormatter {
 		}
 	}
 
-	// If empty format set the default as just the message
+	// If empty format use the package default, just the message unless
+	// SetDefaultFormat was called
 	if format == "" {
-		format = "%M"
+		format = defaultFormat()
 	}
-	return NewPatFormatter(format)
+	return NewPatFormatter(format), nil
 }
 
 func getJSONSocketWriter(filter JSONFilter) (LogWriter, error) {
@@ -127,7 +207,124 @@ func getJSONSocketWriter(filter JSONFilter) (LogWriter, error) {
 	if protocol == "" || endpoint == "" {
 		return nil, fmt.Errorf("TIMBER! Missing protocol or endpoint for socket log writer")
 	}
-	return NewSocketWriter(protocol, endpoint)
+	var format WireFormat
+	wire := filter.property("wire")
+	if wire != "" {
+		var err error
+		if format, err = ParseWireFormat(wire); err != nil {
+			return nil, err
+		}
+		if filter.property("fallback_file") != "" {
+			return nil, fmt.Errorf("TIMBER! fallback_file doesn't go with wire for socket log writer, use reconnect_buffer")
+		}
+	}
+	compress, _ := strconv.ParseBool(filter.property("compress"))
+	lazy, _ := strconv.ParseBool(filter.property("lazy_connect"))
+	var tlsConfig *tls.Config
+	if protocol == "tls" {
+		var err error
+		if tlsConfig, err = tlsConfigFromFilter(filter); err != nil {
+			return nil, err
+		}
+	}
+	sw, err := newSocketWriter(protocol, endpoint, compress, lazy, tlsConfig)
+	if err != nil {
+		return nil, err
+	}
+	if sw.gz != nil {
+		bc, err := filter.BatchConfig(sw.batch)
+		if err != nil {
+			sw.Close()
+			return nil, err
+		}
+		sw.SetBatch(bc)
+	}
+	switch framing := filter.property("framing"); framing {
+	case "":
+	case "newline":
+		sw.SetFraming("\n")
+	case "null":
+		sw.SetFraming("\x00")
+	case "none":
+		sw.SetFraming("")
+	default:
+		sw.Close()
+		return nil, fmt.Errorf("TIMBER! Unknown framing %q for socket log writer, expected newline, null or none", framing)
+	}
+	if idle := filter.property("idle_timeout"); idle != "" {
+		timeout, err := time.ParseDuration(idle)
+		if err != nil {
+			sw.Close()
+			return nil, fmt.Errorf("TIMBER! Bad idle_timeout for socket log writer: %v", err)
+		}
+		sw.SetIdleTimeout(timeout)
+	}
+	if fallback := filter.property("fallback_file"); fallback != "" {
+		replay, _ := strconv.ParseBool(filter.property("fallback_replay"))
+		if err := sw.SetFallbackFile(fallback, replay); err != nil {
+			sw.Close()
+			return nil, err
+		}
+	}
+	if size := filter.property("reconnect_buffer"); size != "" {
+		n, err := strconv.Atoi(size)
+		if err != nil {
+			sw.Close()
+			return nil, fmt.Errorf("TIMBER! Bad reconnect_buffer for socket log writer: %v", err)
+		}
+		sw.SetReconnectBuffer(n)
+	}
+	retryMin, retryMax := DefaultReconnectMin, DefaultReconnectMax
+	for name, d := range map[string]*time.Duration{"reconnect_min": &retryMin, "reconnect_max": &retryMax} {
+		if value := filter.property(name); value != "" {
+			var err error
+			if *d, err = time.ParseDuration(value); err != nil {
+				sw.Close()
+				return nil, fmt.Errorf("TIMBER! Bad %s for socket log writer: %v", name, err)
+			}
+		}
+	}
+	sw.SetReconnectBackoff(retryMin, retryMax)
+	if wire != "" {
+		return NewWireWriter(sw, format), nil
+	}
+	return sw, nil
+}
+
+// The TLS settings of a tls socket: tls_cert and tls_key name PEM files
+// for a client certificate, tls_ca a PEM file of CAs to use instead of the
+// system roots, tls_server_name the name to verify and
+// tls_insecure_skip_verify turns verification off, for testing only
+func tlsConfigFromFilter(filter JSONFilter) (*tls.Config, error) {
+	cfg := &tls.Config{ServerName: filter.property("tls_server_name")}
+	cert, key := filter.property("tls_cert"), filter.property("tls_key")
+	if (cert == "") != (key == "") {
+		return nil, fmt.Errorf("TIMBER! tls_cert and tls_key go together for socket log writer")
+	}
+	if cert != "" {
+		pair, err := tls.LoadX509KeyPair(cert, key)
+		if err != nil {
+			return nil, fmt.Errorf("TIMBER! Can't load the TLS certificate: %v", err)
+		}
+		cfg.Certificates = []tls.Certificate{pair}
+	}
+	if ca := filter.property("tls_ca"); ca != "" {
+		pem, err := os.ReadFile(ca)
+		if err != nil {
+			return nil, fmt.Errorf("TIMBER! Can't read tls_ca: %v", err)
+		}
+		cfg.RootCAs = x509.NewCertPool()
+		if !cfg.RootCAs.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("TIMBER! No certificates in tls_ca %s", ca)
+		}
+	}
+	if skip := filter.property("tls_insecure_skip_verify"); skip != "" {
+		var err error
+		if cfg.InsecureSkipVerify, err = strconv.ParseBool(skip); err != nil {
+			return nil, fmt.Errorf("TIMBER! Bad tls_insecure_skip_verify %q: %v", skip, err)
+		}
+	}
+	return cfg, nil
 }
 
 func getJSONFileWriter(filter JSONFilter) (LogWriter, error) {
@@ -141,5 +338,115 @@ func getJSONFileWriter(filter JSONFilter) (LogWriter, error) {
 	if filename == "" {
 		return nil, fmt.Errorf("TIMBER! Missing filename for file log writer")
 	}
-	return NewFileWriter(filename)
+	maxSize, rotate := rotationProperty(filter, "max_size"), filter.property("rotate")
+	if shared := filter.property("shared"); shared != "" {
+		on, err := strconv.ParseBool(shared)
+		if err != nil {
+			return nil, fmt.Errorf("TIMBER! Bad shared for file log writer: %v", err)
+		}
+		if on {
+			if maxSize != "" || rotate != "" {
+				return nil, fmt.Errorf("TIMBER! A shared file can't have max_size or rotate, every process would rotate it; use logrotate")
+			}
+			return getSharedFileWriter(filename, filter)
+		}
+	}
+	if maxSize == "" && rotate == "" {
+		interval := time.Second
+		if flush := filter.property("flush_interval"); flush != "" {
+			var err error
+			if interval, err = time.ParseDuration(flush); err != nil {
+				return nil, fmt.Errorf("TIMBER! Bad flush_interval for file log writer: %v", err)
+			}
+		}
+		return NewFileWriterInterval(filename, interval)
+	}
+	return getRotatingFileWriter(filename, filter)
+}
+
+// A file filter with shared true gets a SharedFileWriter, with lock for
+// the flock and reopen_check, a duration, for how often to look for a
+// rotation.  It's unbuffered so flush_interval doesn't apply.
+func getSharedFileWriter(filename string, filter JSONFilter) (LogWriter, error) {
+	sw, err := NewSharedFileWriter(filename)
+	if err != nil {
+		return nil, err
+	}
+	if value := filter.property("lock"); value != "" {
+		lock, err := strconv.ParseBool(value)
+		if err == nil {
+			err = sw.SetLocking(lock)
+		}
+		if err != nil {
+			sw.Close()
+			return nil, fmt.Errorf("TIMBER! Bad lock for file log writer: %v", err)
+		}
+	}
+	if value := filter.property("reopen_check"); value != "" {
+		interval, err := time.ParseDuration(value)
+		if err != nil {
+			sw.Close()
+			return nil, fmt.Errorf("TIMBER! Bad reopen_check for file log writer: %v", err)
+		}
+		sw.SetCheckInterval(interval)
+	}
+	return sw, nil
+}
+
+// A file filter with max_size and/or rotate (hourly or daily) gets a
+// RotatingFileWriter, with max_backups and max_age (a duration) for
+// retention and compress to gzip the rotated files.  It's unbuffered so
+// flush_interval doesn't apply.
+func getRotatingFileWriter(filename string, filter JSONFilter) (LogWriter, error) {
+	var size int64
+	var err error
+	if maxSize := rotationProperty(filter, "max_size"); maxSize != "" {
+		if size, err = parseSize(maxSize); err != nil {
+			return nil, err
+		}
+	}
+	period := RotateNever
+	switch rotate := filter.property("rotate"); rotate {
+	case "":
+	case "hourly":
+		period = RotateHourly
+	case "daily":
+		period = RotateDaily
+	default:
+		return nil, fmt.Errorf("TIMBER! Unknown rotate %q, expected hourly or daily", rotate)
+	}
+	backups := 0
+	if maxBackups := rotationProperty(filter, "max_backups"); maxBackups != "" {
+		if backups, err = strconv.Atoi(maxBackups); err != nil {
+			return nil, fmt.Errorf("TIMBER! Bad max_backups for file log writer: %v", err)
+		}
+	}
+	var age time.Duration
+	if maxAge := rotationProperty(filter, "max_age"); maxAge != "" {
+		if age, err = time.ParseDuration(maxAge); err != nil {
+			return nil, fmt.Errorf("TIMBER! Bad max_age for file log writer: %v", err)
+		}
+	}
+	compress := false
+	if value := filter.property("compress"); value != "" {
+		if compress, err = strconv.ParseBool(value); err != nil {
+			return nil, fmt.Errorf("TIMBER! Bad compress for file log writer: %v", err)
+		}
+	}
+	rw, err := NewRotatingFileWriter(filename, size, period)
+	if err != nil {
+		return nil, err
+	}
+	rw.SetRetention(backups, age)
+	rw.SetCompress(compress)
+	return rw, nil
+}
+
+// The size and retention properties also go by the names other rotation
+// tools use, without the underscore: maxsize, maxbackups and maxage
+func rotationProperty(filter JSONFilter, name string) string {
+	if value := filter.property(name); value != "" {
+		return value
+	}
+	return filter.property(strings.Replace(name, "_", "", -1))
 }
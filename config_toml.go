@@ -0,0 +1,372 @@
+package timber
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// Loads the configuration from a TOML file with the same schema as the
+// JSON one, filters and granulars as arrays of tables:
+//
+//	[[filters]]
+//	enabled = true
+//	type = "console"
+//	level = "INFO"
+//	format = { name = "pattern", value = "[%D %T] %L %M" }
+//
+//	[[filters.granulars]]
+//	path = "github.com/me/app/db"
+//	level = "DEBUG"
+//
+// Multi-line strings and dates aren't understood, a config has no use
+// for them.
+func (t *Timber) LoadTOMLConfig(filename string) error {
+	config, err := readTOMLConfig(filename)
+	if err != nil {
+		return err
+	}
+	if err = t.loadFilters(config); err != nil {
+		return fmt.Errorf("%v (%s)", err, filename)
+	}
+	return nil
+}
+
+func readTOMLConfig(filename string) (JSONConfig, error) {
+	if len(filename) <= 0 {
+		return JSONConfig{}, fmt.Errorf("Empty filename")
+	}
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return JSONConfig{}, fmt.Errorf("TIMBER! Can't load toml config file: %s %v", filename, err)
+	}
+	config, err := parseTOMLConfig(data)
+	if err != nil {
+		return config, fmt.Errorf("%v (%s)", err, filename)
+	}
+	return config, nil
+}
+
+func parseTOMLConfig(data []byte) (JSONConfig, error) {
+	tree, err := parseTOML(data)
+	if err == nil {
+		var config JSONConfig
+		if config, err = decodeConfigTree(tree); err == nil {
+			return config, nil
+		}
+	}
+	return JSONConfig{}, fmt.Errorf("TIMBER! Can't parse toml config: %v", err)
+}
+
+type tomlParser struct {
+	src  string
+	pos  int
+	line int
+}
+
+func parseTOML(data []byte) (map[string]interface{}, error) {
+	p := &tomlParser{src: string(data), line: 1}
+	root := make(map[string]interface{})
+	table := root
+	for {
+		p.skipSpace(true)
+		if p.pos >= len(p.src) {
+			return root, nil
+		}
+		var err error
+		if p.src[p.pos] == '[' {
+			table, err = p.parseHeader(root)
+		} else {
+			err = p.parseKeyValue(table)
+		}
+		if err == nil {
+			err = p.endOfLine()
+		}
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %v", p.line, err)
+		}
+	}
+}
+
+// [a.b] or [[a.b]], returns the table the keys that follow go in
+func (p *tomlParser) parseHeader(root map[string]interface{}) (map[string]interface{}, error) {
+	array := strings.HasPrefix(p.src[p.pos:], "[[")
+	if array {
+		p.pos += 2
+	} else {
+		p.pos++
+	}
+	keys, err := p.parseKey()
+	if err != nil {
+		return nil, err
+	}
+	closing := "]"
+	if array {
+		closing = "]]"
+	}
+	if !strings.HasPrefix(p.src[p.pos:], closing) {
+		return nil, fmt.Errorf("expected %s", closing)
+	}
+	p.pos += len(closing)
+	parent, err := tomlTable(root, keys[:len(keys)-1])
+	if err != nil {
+		return nil, err
+	}
+	last := keys[len(keys)-1]
+	if !array {
+		return tomlTable(parent, keys[len(keys)-1:])
+	}
+	table := make(map[string]interface{})
+	switch existing := parent[last].(type) {
+	case nil:
+		parent[last] = []interface{}{table}
+	case []interface{}:
+		parent[last] = append(existing, table)
+	default:
+		return nil, fmt.Errorf("%s is already a value", last)
+	}
+	return table, nil
+}
+
+// Walks down keys from table, making the tables that don't exist yet.
+// An array of tables stands for its last table, as in the spec.
+func tomlTable(table map[string]interface{}, keys []string) (map[string]interface{}, error) {
+	for _, key := range keys {
+		switch next := table[key].(type) {
+		case nil:
+			child := make(map[string]interface{})
+			table[key] = child
+			table = child
+		case map[string]interface{}:
+			table = next
+		case []interface{}:
+			if len(next) == 0 {
+				return nil, fmt.Errorf("%s is an empty array, not a table", key)
+			}
+			child, ok := next[len(next)-1].(map[string]interface{})
+			if !ok {
+				return nil, fmt.Errorf("%s is already a value", key)
+			}
+			table = child
+		default:
+			return nil, fmt.Errorf("%s is already a value", key)
+		}
+	}
+	return table, nil
+}
+
+func (p *tomlParser) parseKeyValue(table map[string]interface{}) error {
+	keys, err := p.parseKey()
+	if err != nil {
+		return err
+	}
+	if p.pos >= len(p.src) || p.src[p.pos] != '=' {
+		return fmt.Errorf("expected = after %s", strings.Join(keys, "."))
+	}
+	p.pos++
+	p.skipSpace(false)
+	val, err := p.parseValue()
+	if err != nil {
+		return err
+	}
+	if table, err = tomlTable(table, keys[:len(keys)-1]); err != nil {
+		return err
+	}
+	last := keys[len(keys)-1]
+	if _, dup := table[last]; dup {
+		return fmt.Errorf("duplicate key %s", last)
+	}
+	table[last] = val
+	return nil
+}
+
+// A dotted key of bare or quoted parts
+func (p *tomlParser) parseKey() ([]string, error) {
+	var keys []string
+	for {
+		p.skipSpace(false)
+		var key string
+		if p.pos < len(p.src) && (p.src[p.pos] == '"' || p.src[p.pos] == '\'') {
+			var err error
+			if key, err = p.parseString(); err != nil {
+				return nil, err
+			}
+		} else {
+			start := p.pos
+			for p.pos < len(p.src) && isTOMLBareKey(p.src[p.pos]) {
+				p.pos++
+			}
+			if key = p.src[start:p.pos]; key == "" {
+				return nil, fmt.Errorf("expected a key")
+			}
+		}
+		keys = append(keys, key)
+		p.skipSpace(false)
+		if p.pos >= len(p.src) || p.src[p.pos] != '.' {
+			return keys, nil
+		}
+		p.pos++
+	}
+}
+
+func isTOMLBareKey(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '_' || c == '-'
+}
+
+func (p *tomlParser) parseValue() (interface{}, error) {
+	if p.pos >= len(p.src) {
+		return nil, fmt.Errorf("expected a value")
+	}
+	switch c := p.src[p.pos]; {
+	case c == '"' || c == '\'':
+		return p.parseString()
+	case c == '[':
+		return p.parseArray()
+	case c == '{':
+		return p.parseInlineTable()
+	}
+	start := p.pos
+	for p.pos < len(p.src) && (isTOMLBareKey(p.src[p.pos]) || strings.IndexByte("+.:", p.src[p.pos]) >= 0) {
+		p.pos++
+	}
+	word := p.src[start:p.pos]
+	switch word {
+	case "true":
+		return true, nil
+	case "false":
+		return false, nil
+	case "":
+		return nil, fmt.Errorf("expected a value")
+	}
+	if n, err := strconv.ParseInt(word, 0, 64); err == nil {
+		return n, nil
+	}
+	if f, err := strconv.ParseFloat(strings.ReplaceAll(word, "_", ""), 64); err == nil {
+		return f, nil
+	}
+	return nil, fmt.Errorf("unsupported value %q", word)
+}
+
+func (p *tomlParser) parseString() (string, error) {
+	quote := p.src[p.pos]
+	if strings.HasPrefix(p.src[p.pos:], strings.Repeat(string(quote), 3)) {
+		return "", fmt.Errorf("multi-line strings are not supported")
+	}
+	for end := p.pos + 1; end < len(p.src) && p.src[end] != '\n'; end++ {
+		switch p.src[end] {
+		case '\\':
+			if quote == '"' {
+				end++
+			}
+		case quote:
+			s := p.src[p.pos+1 : end]
+			p.pos = end + 1
+			if quote == '\'' {
+				return s, nil
+			}
+			// TOML's escapes are a subset of Go's
+			unquoted, err := strconv.Unquote(`"` + s + `"`)
+			if err != nil {
+				return "", fmt.Errorf("bad string \"%s\"", s)
+			}
+			return unquoted, nil
+		}
+	}
+	return "", fmt.Errorf("unterminated string")
+}
+
+// Arrays may run over several lines, with comments and a trailing comma
+func (p *tomlParser) parseArray() (interface{}, error) {
+	p.pos++
+	list := []interface{}{}
+	for {
+		p.skipSpace(true)
+		if p.pos < len(p.src) && p.src[p.pos] == ']' {
+			p.pos++
+			return list, nil
+		}
+		val, err := p.parseValue()
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, val)
+		p.skipSpace(true)
+		if p.pos < len(p.src) && p.src[p.pos] == ',' {
+			p.pos++
+		} else if p.pos >= len(p.src) || p.src[p.pos] != ']' {
+			return nil, fmt.Errorf("expected , or ] in array")
+		}
+	}
+}
+
+func (p *tomlParser) parseInlineTable() (interface{}, error) {
+	p.pos++
+	table := make(map[string]interface{})
+	p.skipSpace(false)
+	if p.pos < len(p.src) && p.src[p.pos] == '}' {
+		p.pos++
+		return table, nil
+	}
+	for {
+		if err := p.parseKeyValue(table); err != nil {
+			return nil, err
+		}
+		p.skipSpace(false)
+		if p.pos >= len(p.src) {
+			return nil, fmt.Errorf("unterminated inline table")
+		}
+		switch p.src[p.pos] {
+		case ',':
+			p.pos++
+		case '}':
+			p.pos++
+			return table, nil
+		default:
+			return nil, fmt.Errorf("expected , or } in inline table")
+		}
+	}
+}
+
+// Skips spaces and tabs, with newlines also comments and the newlines
+func (p *tomlParser) skipSpace(newlines bool) {
+	for p.pos < len(p.src) {
+		switch p.src[p.pos] {
+		case ' ', '\t', '\r':
+		case '\n':
+			if !newlines {
+				return
+			}
+			p.line++
+		case '#':
+			if !newlines {
+				return
+			}
+			for p.pos < len(p.src) && p.src[p.pos] != '\n' {
+				p.pos++
+			}
+			continue
+		default:
+			return
+		}
+		p.pos++
+	}
+}
+
+// Only a comment can follow a key/value or a header on its line
+func (p *tomlParser) endOfLine() error {
+	p.skipSpace(false)
+	if p.pos < len(p.src) && p.src[p.pos] == '#' {
+		for p.pos < len(p.src) && p.src[p.pos] != '\n' {
+			p.pos++
+		}
+	}
+	if p.pos >= len(p.src) || p.src[p.pos] == '\n' {
+		return nil
+	}
+	rest := p.src[p.pos:]
+	if end := strings.IndexByte(rest, '\n'); end >= 0 {
+		rest = rest[:end]
+	}
+	return fmt.Errorf("unexpected %q", rest)
+}
@@ -0,0 +1,54 @@
+package timber
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestTOMLConfig(t *testing.T) {
+	expected, err := readJSONConfigFile("timber.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	config, err := readConfigFile("timber.toml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(config, expected) {
+		t.Errorf("expected the timber.json config got %+v", config)
+	}
+
+	config, err = parseTOMLConfig([]byte(`
+defaults.level = 'WARNING'
+
+[[filters]]
+enabled = true
+type = "socket"
+properties = [
+  {name = "endpoint", value = "localhost:9500"}, # a comment
+  {name = "port", value = 9500},
+]
+granulars = [{path = "a\tb", "level" = "DEBUG"}]
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	filter := config.Filters[0]
+	if config.Defaults.Level != "WARNING" || !filter.Enabled || filter.property("port") != "9500" ||
+		filter.Granulars[0].Path != "a\tb" || filter.Granulars[0].Level != "DEBUG" {
+		t.Errorf("unexpected config %+v", config)
+	}
+
+	for doc, msg := range map[string]string{
+		"[[filters]]\nenabled = 1\n":          "filters[0].enabled wants a boolean",
+		"[[filters]]\ntype = \"a\" type\n":    "line 2: unexpected",
+		"[[filters]]\ntype = 'a'\ntype = 'b'": "line 3: duplicate key type",
+		"filters = \"\"\"x\"\"\"\n":           "multi-line strings",
+		"a = []\n[a.b]\n":                     "a is an empty array",
+	} {
+		if _, err := parseTOMLConfig([]byte(doc)); err == nil || !strings.Contains(err.Error(), msg) {
+			t.Errorf("expected %q for %q got %v", msg, doc, err)
+		}
+	}
+}
@@ -0,0 +1,91 @@
+package timber
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// The YAML and TOML loaders parse into a tree of map[string]interface{},
+// []interface{} and scalars then decode it into a JSONConfig here, so
+// every format shares the JSON schema and loading code.  Keys match the
+// field names ignoring case as encoding/json does and unknown keys are
+// ignored.  Scalars are converted to the field's type, a YAML scalar is
+// always a string and a TOML port number is fine for a property value.
+func decodeConfigTree(tree interface{}) (JSONConfig, error) {
+	config := JSONConfig{}
+	err := setConfigValue("", tree, reflect.ValueOf(&config).Elem())
+	return config, err
+}
+
+func setConfigValue(where string, node interface{}, v reflect.Value) error {
+	if node == nil {
+		return nil
+	}
+	switch v.Kind() {
+	case reflect.Ptr:
+		if v.IsNil() {
+			v.Set(reflect.New(v.Type().Elem()))
+		}
+		return setConfigValue(where, node, v.Elem())
+	case reflect.Struct:
+		m, ok := node.(map[string]interface{})
+		if !ok {
+			return fmt.Errorf("%s wants a table got %v", configWhere(where), node)
+		}
+		for key, val := range m {
+			field := v.FieldByNameFunc(func(name string) bool { return strings.EqualFold(name, key) })
+			if !field.IsValid() || !field.CanSet() {
+				continue
+			}
+			if err := setConfigValue(where+"."+key, val, field); err != nil {
+				return err
+			}
+		}
+		return nil
+	case reflect.Slice:
+		list, ok := node.([]interface{})
+		if !ok {
+			return fmt.Errorf("%s wants a list got %v", configWhere(where), node)
+		}
+		slice := reflect.MakeSlice(v.Type(), len(list), len(list))
+		for i, val := range list {
+			if err := setConfigValue(fmt.Sprintf("%s[%d]", where, i), val, slice.Index(i)); err != nil {
+				return err
+			}
+		}
+		v.Set(slice)
+		return nil
+	case reflect.String:
+		switch s := node.(type) {
+		case string:
+			v.SetString(s)
+		case bool, int64, float64:
+			v.SetString(fmt.Sprint(s))
+		default:
+			return fmt.Errorf("%s wants a string got %v", configWhere(where), node)
+		}
+		return nil
+	case reflect.Bool:
+		switch b := node.(type) {
+		case bool:
+			v.SetBool(b)
+			return nil
+		case string:
+			if on, err := strconv.ParseBool(b); err == nil {
+				v.SetBool(on)
+				return nil
+			}
+		}
+		return fmt.Errorf("%s wants a boolean got %v", configWhere(where), node)
+	}
+	return fmt.Errorf("%s can't be set from a config file", configWhere(where))
+}
+
+func configWhere(where string) string {
+	if where == "" {
+		return "the config"
+	}
+	return strings.TrimPrefix(where, ".")
+}
@@ -0,0 +1,296 @@
+package timber
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// How long ValidateConfig waits for an endpoint to answer
+const configProbeTimeout = 2 * time.Second
+
+// A problem in a config that wouldn't stop it loading but that the
+// loaders would quietly work around, or that will bite later: a level
+// that doesn't parse, a filter type nobody knows, a pattern directive
+// that would be written out as is, an endpoint that can't be reached.
+type Warning struct {
+	Filter  int    // index of the filter in the config, -1 for the defaults
+	Tag     string // the filter's tag
+	Message string
+}
+
+func (w Warning) String() string {
+	return w.Message
+}
+
+// set by DryRun, accessed atomically
+var dryRunConfig int32
+
+// In a dry run the config loaders, ReloadConfig included, check the
+// config as ValidateConfig does and return its errors and warnings as
+// one error, nil for a clean config, without adding or replacing any
+// loggers.  Files aren't created and sockets are only probed.
+func DryRun(dry bool) {
+	var val int32
+	if dry {
+		val = 1
+	}
+	atomic.StoreInt32(&dryRunConfig, val)
+}
+
+func dryRun() bool {
+	return atomic.LoadInt32(&dryRunConfig) == 1
+}
+
+// Checks the config in filename, any type LoadConfigAuto takes, without
+// loading it.  The error is for a file that can't be read or a config
+// that wouldn't load, with every filter that fails in it; the warnings
+// are the rest of the problems, see Warning.  The filters' writers aren't
+// opened: a file's directory has to exist and socket, redis and syslog
+// endpoints are dialed and hung up on.
+func ValidateConfig(filename string) ([]Warning, error) {
+	config, err := readConfigFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	warnings, err := validateConfig(config)
+	if err != nil {
+		return warnings, fmt.Errorf("%v (%s)", err, filename)
+	}
+	return warnings, nil
+}
+
+func validateConfig(config JSONConfig) ([]Warning, error) {
+	config, err := applyEnvOverrides(expandConfigEnv(config), os.Environ())
+	if err != nil {
+		return nil, err
+	}
+	var warnings []Warning
+	for _, problem := range configProblems(config) {
+		warnings = append(warnings, Warning{Filter: problem.filter, Tag: problem.tag, Message: problem.err.Error()})
+	}
+	var errs []error
+	for i, filter := range config.Filters {
+		if !filter.enabled() {
+			continue
+		}
+		where := fmt.Sprintf("filter %d (%s)", i, filter.Tag)
+		warn := func(msg string) {
+			warnings = append(warnings, Warning{Filter: i, Tag: filter.Tag, Message: "TIMBER! " + msg + " in " + where})
+		}
+		if _, _, err := buildFilterLogger(config.Defaults, filter, false); err != nil {
+			errs = append(errs, fmt.Errorf("%v in %s", err, where))
+			continue
+		}
+		filter = config.Defaults.applyTo(filter)
+		tags := staticTagsFromFilter(filter)
+		for _, pattern := range filterPatterns(filter) {
+			if err := checkPattern(pattern, tags); err != nil {
+				warn(err.Error())
+			}
+		}
+		if err := checkFilterWriter(filter, warn); err != nil {
+			errs = append(errs, fmt.Errorf("%v in %s", err, where))
+		}
+	}
+	return warnings, errors.Join(errs...)
+}
+
+// The error of a dry run: the errors, then the warnings
+func dryRunError(config JSONConfig) error {
+	warnings, err := validateConfig(config)
+	errs := []error{err}
+	for _, warning := range warnings {
+		errs = append(errs, errors.New(warning.Message))
+	}
+	return errors.Join(errs...)
+}
+
+// The patterns of a filter's pattern formatters
+func filterPatterns(filter JSONFilter) []string {
+	var patterns []string
+	if filter.Format.Name == "" || filter.Format.Name == "pattern" {
+		if filter.Format.Value != "" {
+			patterns = append(patterns, filter.Format.Value)
+		} else if format := filter.property("format"); format != "" {
+			patterns = append(patterns, format)
+		}
+	}
+	for _, format := range filter.Formats {
+		patterns = append(patterns, format.Format)
+	}
+	for _, granular := range filter.Granulars {
+		if granular.Format != "" {
+			patterns = append(patterns, granular.Format)
+		}
+	}
+	return patterns
+}
+
+// The letters after a % that mean something to a PatFormatter
+const patternDirectives = "TtDdLSslxMPpFfrnCNGEcKH"
+
+// An error for the first directive of pattern a PatFormatter wouldn't
+// understand and would write out as it is
+func checkPattern(pattern string, tags map[string]string) error {
+	for i := 0; i < len(pattern); i++ {
+		if pattern[i] != '%' {
+			continue
+		}
+		rest := pattern[i+1:]
+		if strings.HasPrefix(rest, "%") {
+			i++
+			continue
+		}
+		rest = rest[len(prefixRegexp.FindString(rest)):]
+		switch {
+		case rest == "":
+			return fmt.Errorf("Pattern %q ends in a lone %%", pattern)
+		case rest[0] == '{':
+			if _, _, ok := braceDirective(rest, tags); !ok {
+				end := strings.IndexByte(rest, '}')
+				if end < 0 {
+					return fmt.Errorf("Unclosed %%{ in pattern %q", pattern)
+				}
+				return fmt.Errorf("Unknown directive %%%s in pattern %q", rest[:end+1], pattern)
+			}
+		case strings.IndexByte(patternDirectives, rest[0]) < 0:
+			return fmt.Errorf("Unknown directive %%%c in pattern %q", rest[0], pattern)
+		}
+	}
+	return nil
+}
+
+// Checks what the filter's writer needs without opening it: an error for
+// a required property that's missing or bad, a warning for an endpoint
+// that doesn't answer or a directory that isn't there
+func checkFilterWriter(filter JSONFilter, warn func(msg string)) error {
+	switch filter.Type {
+	case "console":
+		if _, err := getConsoleWriter(filter); err != nil {
+			return err
+		}
+	case "file":
+		filename := filter.property("filename")
+		if filename == "" {
+			return fmt.Errorf("TIMBER! Missing filename for file log writer")
+		}
+		if info, err := os.Stat(filepath.Dir(filename)); err != nil || !info.IsDir() {
+			warn(fmt.Sprintf("The directory of %s doesn't exist", filename))
+		}
+	case "socket":
+		protocol, endpoint := filter.property("protocol"), filter.property("endpoint")
+		if protocol == "" || endpoint == "" {
+			return fmt.Errorf("TIMBER! Missing protocol or endpoint for socket log writer")
+		}
+		if protocol == "tls" {
+			protocol = "tcp"
+		}
+		probeEndpoint(protocol, endpoint, warn)
+	case "redis":
+		if filter.property("address") == "" || filter.property("key") == "" {
+			return fmt.Errorf("TIMBER! Missing address or key for redis log writer")
+		}
+		probeEndpoint("tcp", filter.property("address"), warn)
+	case "syslog":
+		if name := filter.property("facility"); name != "" {
+			if _, err := ParseSyslogFacility(name); err != nil {
+				return err
+			}
+		}
+		if rfc := filter.property("rfc"); rfc != "" && rfc != "3164" && rfc != "5424" {
+			return fmt.Errorf("TIMBER! Unknown rfc %q for syslog log writer, expected 3164 or 5424", rfc)
+		}
+		if network := filter.property("network"); network != "" {
+			probeEndpoint(network, filter.property("address"), warn)
+		}
+	case "gelf":
+		endpoint := filter.property("endpoint")
+		if endpoint == "" {
+			return fmt.Errorf("TIMBER! Missing endpoint for gelf log writer")
+		}
+		if _, err := ParseGELFCompression(filter.property("compress")); err != nil {
+			return err
+		}
+		if protocol := filter.property("protocol"); strings.HasPrefix(protocol, "tcp") {
+			probeEndpoint(protocol, endpoint, warn)
+		}
+	case AccessLogType:
+		writerFilter, err := accessLogFilter(filter)
+		if err != nil {
+			return err
+		}
+		return checkFilterWriter(writerFilter, warn)
+	case "tee":
+		if len(filter.Outputs) == 0 {
+			return fmt.Errorf("TIMBER! Missing outputs for tee log writer")
+		}
+		for i, output := range filter.Outputs {
+			if err := checkTeeOutput(teeOutput(filter, output), warn); err != nil {
+				return fmt.Errorf("%v in tee output %d", err, i)
+			}
+		}
+	case "journald":
+		socket := filter.property("socket")
+		if socket == "" {
+			socket = JournaldSocket
+		}
+		if _, err := os.Stat(socket); err != nil {
+			warn(fmt.Sprintf("No journal at %s", socket))
+		}
+	case "eventlog":
+		if filter.property("source") == "" {
+			return fmt.Errorf("TIMBER! Missing source for eventlog log writer")
+		}
+	}
+	if retry := filter.property("fallback_retry"); retry != "" {
+		if d, err := time.ParseDuration(retry); err != nil || d < 0 {
+			return fmt.Errorf("TIMBER! Bad fallback_retry %q, expected a duration", retry)
+		}
+	}
+	if mode := filter.property("batch"); mode != "" {
+		if _, err := strconv.ParseBool(mode); err != nil && mode != "gzip" {
+			return fmt.Errorf("TIMBER! Bad batch %q, expected true, false or gzip", mode)
+		}
+		if _, err := filter.BatchConfig(DefaultBatch); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// What validateConfig checks for a filter, for an output of a tee
+func checkTeeOutput(output JSONFilter, warn func(msg string)) error {
+	if _, registered := writerFactory(output.Type); !knownFilterTypes[output.Type] && !registered {
+		return fmt.Errorf("TIMBER! Unknown type %q", output.Type)
+	}
+	if _, err := ParseLevel(output.Level); output.Level != "" && err != nil {
+		warn(fmt.Sprintf("Unknown level %q in a tee output", output.Level))
+	}
+	if _, _, err := buildFilterLogger(nil, output, false); err != nil {
+		return err
+	}
+	tags := staticTagsFromFilter(output)
+	for _, pattern := range filterPatterns(output) {
+		if err := checkPattern(pattern, tags); err != nil {
+			warn(err.Error())
+		}
+	}
+	return checkFilterWriter(output, warn)
+}
+
+// Dials the endpoint and hangs up, a warning if that fails
+func probeEndpoint(network, address string, warn func(msg string)) {
+	conn, err := net.DialTimeout(network, address, configProbeTimeout)
+	if err != nil {
+		warn(fmt.Sprintf("Can't reach %s://%s: %v", network, address, err))
+		return
+	}
+	conn.Close()
+}
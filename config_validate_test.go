@@ -0,0 +1,84 @@
+package timber
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestValidateConfig(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	closed, _ := net.Listen("tcp", "127.0.0.1:0")
+	closedAddr := closed.Addr().String()
+	closed.Close()
+	dir := t.TempDir()
+	config := fmt.Sprintf(`{"filters": [
+	{"enabled": true, "tag": "up", "type": "socket", "level": "INFO", "format": {"name": "pattern", "value": "%%L %%M"},
+		"properties": [{"name": "protocol", "value": "tcp"}, {"name": "endpoint", "value": %q}]},
+	{"enabled": true, "tag": "down", "type": "socket", "level": "LOUD",
+		"properties": [{"name": "protocol", "value": "tcp"}, {"name": "endpoint", "value": %q}]},
+	{"enabled": true, "tag": "file", "type": "file", "format": {"name": "pattern", "value": "%%Q %%{nope} %%M"},
+		"properties": [{"name": "filename", "value": %q}]},
+	{"enabled": true, "tag": "odd", "type": "carrier-pigeon"},
+	{"enabled": true, "tag": "nofile", "type": "file"},
+	{"enabled": true, "tag": "mask", "type": "console", "properties": [{"name": "mask", "value": "no arrow"}]}
+]}`, ln.Addr().String(), closedAddr, filepath.Join(dir, "missing", "app.log"))
+	name := filepath.Join(dir, "timber.json")
+	if err := os.WriteFile(name, []byte(config), 0644); err != nil {
+		t.Fatal(err)
+	}
+	warnings, err := ValidateConfig(name)
+	var messages []string
+	for _, w := range warnings {
+		messages = append(messages, fmt.Sprintf("%d %s: %s", w.Filter, w.Tag, w))
+	}
+	all := strings.Join(messages, "\n")
+	for _, expected := range []string{"1 down: TIMBER! Unknown level", "1 down: TIMBER! Can't reach tcp://",
+		"2 file: TIMBER! Unknown directive %Q", "2 file: TIMBER! The directory of", "3 odd: TIMBER! Unknown filter type"} {
+		if !strings.Contains(all, expected) {
+			t.Errorf("expected a warning %q in:\n%s", expected, all)
+		}
+	}
+	if len(warnings) != 5 {
+		t.Errorf("expected 5 warnings got:\n%s", all)
+	}
+	if err == nil || !strings.Contains(err.Error(), "Missing filename") || !strings.Contains(err.Error(), "Bad mask") {
+		t.Errorf("expected errors for the file without a name and the mask, got %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "missing")); !os.IsNotExist(err) {
+		t.Error("expected nothing to be created")
+	}
+	if _, err := ValidateConfig(filepath.Join(dir, "absent.json")); err == nil {
+		t.Error("expected an error for a file that isn't there")
+	}
+}
+
+func TestDryRun(t *testing.T) {
+	DryRun(true)
+	defer DryRun(false)
+	log := NewTimber()
+	defer log.Close()
+	name := filepath.Join(t.TempDir(), "dry.log")
+	err := log.LoadJSONConfigString(fmt.Sprintf(`{"filters": [{"enabled": true, "type": "file", "level": "INFO",
+		"properties": [{"name": "filename", "value": %q}]}]}`, name))
+	if err != nil {
+		t.Errorf("expected a clean config, got %v", err)
+	}
+	if n := len(log.Metrics()); n != 0 {
+		t.Errorf("expected no loggers in a dry run, got %d", n)
+	}
+	if _, err := os.Stat(name); !os.IsNotExist(err) {
+		t.Error("expected the file not to be created")
+	}
+	err = log.LoadJSONConfigString(`{"filters": [{"enabled": true, "type": "console", "level": "LOUD"}]}`)
+	if err == nil || !strings.Contains(err.Error(), "Unknown level") {
+		t.Errorf("expected the warning as an error, got %v", err)
+	}
+}
@@ -3,16 +3,16 @@ package timber
 import (
 	"encoding/xml"
 	"fmt"
-	"log"
 	"os"
-	"reflect"
 )
 
 // Granulars are overriding levels that can be either
 // package paths or package path + function name
 type XMLGranular struct {
-	Level string `xml:"level"`
-	Path  string `xml:"path"`
+	Level    string `xml:"level"`
+	Path     string `xml:"path"`
+	Format   string `xml:"format"`
+	MaxLevel string `xml:"maxlevel"`
 }
 
 // match the log4go structure so i don't have to change my configs
@@ -20,124 +20,124 @@ type XMLProperty struct {
 	Name  string `xml:"name,attr"`
 	Value string `xml:",chardata"`
 }
+
+// <levelformat level="DEBUG">[%D %T] %S %M</levelformat>
+type XMLLevelFormat struct {
+	Level  string `xml:"level,attr"`
+	Format string `xml:",chardata"`
+}
+
 type XMLFilter struct {
-	XMLName    xml.Name      `xml:"filter"`
-	Enabled    bool          `xml:"enabled,attr"`
-	Tag        string        `xml:"tag"`
-	Type       string        `xml:"type"`
-	Level      string        `xml:"level"`
-	Format     XMLProperty   `xml:"format"`
-	Properties []XMLProperty `xml:"property"`
-	Granulars  []XMLGranular `xml:"granular"`
+	XMLName    xml.Name         `xml:"filter"`
+	Enabled    bool             `xml:"enabled,attr"`
+	Tag        string           `xml:"tag"`
+	Type       string           `xml:"type"`
+	Level      string           `xml:"level"`
+	MaxLevel   string           `xml:"maxlevel"`
+	Format     XMLProperty      `xml:"format"`
+	Formats    []XMLLevelFormat `xml:"levelformat"`
+	Properties []XMLProperty    `xml:"property"`
+	Granulars  []XMLGranular    `xml:"granular"`
+	Outputs    []XMLOutput      `xml:"output"`
+}
+
+// <output> in a tee filter: the writer and, optionally, the format of a
+// branch
+type XMLOutput struct {
+	Type       string           `xml:"type"`
+	Level      string           `xml:"level"`
+	Format     XMLProperty      `xml:"format"`
+	Formats    []XMLLevelFormat `xml:"levelformat"`
+	Properties []XMLProperty    `xml:"property"`
+}
+
+// Values inherited by every filter that doesn't set its own
+type XMLDefaults struct {
+	Level     string        `xml:"level"`
+	Format    XMLProperty   `xml:"format"`
+	Granulars []XMLGranular `xml:"granular"`
 }
 
 type XMLConfig struct {
-	XMLName xml.Name    `xml:"logging"`
-	Filters []XMLFilter `xml:"filter"`
+	XMLName  xml.Name     `xml:"logging"`
+	Defaults *XMLDefaults `xml:"defaults"`
+	Filters  []XMLFilter  `xml:"filter"`
 }
 
 // Loads the configuration from an XML file (as you were probably expecting)
 func (t *Timber) LoadXMLConfig(filename string) error {
+	config, err := readXMLConfig(filename)
+	if err != nil {
+		return err
+	}
+	return t.loadFilters(config)
+}
+
+func readXMLConfig(filename string) (JSONConfig, error) {
 	if len(filename) <= 0 {
-		return fmt.Errorf("Empty filename")
+		return JSONConfig{}, fmt.Errorf("Empty filename")
 	}
 
 	file, err := os.Open(filename)
 	if err != nil {
-		return fmt.Errorf("TIMBER! Can't load xml config file: %s %v", filename, err)
+		return JSONConfig{}, fmt.Errorf("TIMBER! Can't load xml config file: %s %v", filename, err)
 	}
 	defer file.Close()
 
 	config := XMLConfig{}
 	err = xml.NewDecoder(file).Decode(&config)
 	if err != nil {
-		return fmt.Errorf("TIMBER! Can't parse xml config file: %s %v", filename, err)
-	}
-
-	for _, filter := range config.Filters {
-		if !filter.Enabled {
-			continue
-		}
-		level := getLevel(filter.Level)
-		formatter := getXMLFormatter(filter)
-		granulars := make(map[string]Level)
-		for _, granular := range filter.Granulars {
-			granulars[granular.Path] = getLevel(granular.Level)
-		}
-		configLogger := ConfigLogger{Level: level, Formatter: formatter, Granulars: granulars}
-
-		var err error
-		switch filter.Type {
-		case "console":
-			configLogger.LogWriter = new(ConsoleWriter)
-		case "socket":
-			if configLogger.LogWriter, err = getXMLSocketWriter(filter); err != nil {
-				return err
-			}
-		case "file":
-			if configLogger.LogWriter, err = getXMLFileWriter(filter); err != nil {
-				return err
-			}
-		default:
-			log.Printf("TIMBER! Warning unrecognized filter in config file: %v\n", filter.Tag)
-			continue
-		}
-
-		t.AddLogger(configLogger)
+		return JSONConfig{}, fmt.Errorf("TIMBER! Can't parse xml config file: %s %v", filename, err)
 	}
-	return nil
+	return config.toJSON(), nil
 }
 
-func getXMLFormatter(filter XMLFilter) LogFormatter {
-	format := ""
-	property := XMLProperty{}
-
-	// If format field is set then use it's value, otherwise
-	// attempt to get the format field from the filters properties
-	if !reflect.DeepEqual(filter.Format, property) {
-		format = filter.Format.Value
-	} else {
-		for _, prop := range filter.Properties {
-			if prop.Name == "format" {
-				format = prop.Value
-			}
+// The XML and JSON configs share the same loading code
+func (config XMLConfig) toJSON() JSONConfig {
+	jc := JSONConfig{}
+	if config.Defaults != nil {
+		jc.Defaults = &JSONDefaults{Level: config.Defaults.Level, Format: JSONProperty(config.Defaults.Format)}
+		for _, gran := range config.Defaults.Granulars {
+			jc.Defaults.Granulars = append(jc.Defaults.Granulars, JSONGranular(gran))
 		}
 	}
-
-	// If empty format set the default as just the message
-	if format == "" {
-		format = "%M"
+	for _, filter := range config.Filters {
+		jc.Filters = append(jc.Filters, filter.toJSON())
 	}
-	return NewPatFormatter(format)
+	return jc
 }
 
-func getXMLSocketWriter(filter XMLFilter) (LogWriter, error) {
-	var protocol, endpoint string
-
-	for _, property := range filter.Properties {
-		if property.Name == "protocol" {
-			protocol = property.Value
-		} else if property.Name == "endpoint" {
-			endpoint = property.Value
-		}
+func (filter XMLFilter) toJSON() JSONFilter {
+	jf := JSONFilter{
+		Enabled:  filter.Enabled,
+		Tag:      filter.Tag,
+		Type:     filter.Type,
+		Level:    filter.Level,
+		MaxLevel: filter.MaxLevel,
+		Format:   JSONProperty(filter.Format),
 	}
-
-	if protocol == "" || endpoint == "" {
-		return nil, fmt.Errorf("TIMBER! Missing protocol or endpoint for socket log writer")
+	for _, prop := range filter.Properties {
+		jf.Properties = append(jf.Properties, JSONProperty(prop))
+	}
+	for _, lf := range filter.Formats {
+		jf.Formats = append(jf.Formats, JSONLevelFormat(lf))
+	}
+	for _, gran := range filter.Granulars {
+		jf.Granulars = append(jf.Granulars, JSONGranular(gran))
 	}
-	return NewSocketWriter(protocol, endpoint)
+	for _, output := range filter.Outputs {
+		jf.Outputs = append(jf.Outputs, output.toJSON())
+	}
+	return jf
 }
 
-func getXMLFileWriter(filter XMLFilter) (LogWriter, error) {
-	filename := ""
-
-	for _, property := range filter.Properties {
-		if property.Name == "filename" {
-			filename = property.Value
-		}
+func (output XMLOutput) toJSON() JSONFilter {
+	jf := JSONFilter{Type: output.Type, Level: output.Level, Format: JSONProperty(output.Format)}
+	for _, prop := range output.Properties {
+		jf.Properties = append(jf.Properties, JSONProperty(prop))
 	}
-	if filename == "" {
-		return nil, fmt.Errorf("TIMBER! Missing filename for file log writer")
+	for _, lf := range output.Formats {
+		jf.Formats = append(jf.Formats, JSONLevelFormat(lf))
 	}
-	return NewFileWriter(filename)
+	return jf
 }
@@ -0,0 +1,301 @@
+package timber
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// Loads the configuration from a YAML file with the same schema as the
+// JSON one:
+//
+//	filters:
+//	  - enabled: true
+//	    type: console
+//	    level: INFO
+//	    format:
+//	      name: pattern
+//	      value: "[%D %T] %L %M"
+//	    granulars:
+//	      - path: github.com/me/app/db
+//	        level: DEBUG
+//
+// Only the block style YAML a config needs is understood: mappings,
+// sequences, plain and quoted scalars, comments and one line flow lists
+// like [a, b].  Anchors, tags and | or > block scalars are not.
+func (t *Timber) LoadYAMLConfig(filename string) error {
+	config, err := readYAMLConfig(filename)
+	if err != nil {
+		return err
+	}
+	if err = t.loadFilters(config); err != nil {
+		return fmt.Errorf("%v (%s)", err, filename)
+	}
+	return nil
+}
+
+func readYAMLConfig(filename string) (JSONConfig, error) {
+	if len(filename) <= 0 {
+		return JSONConfig{}, fmt.Errorf("Empty filename")
+	}
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return JSONConfig{}, fmt.Errorf("TIMBER! Can't load yaml config file: %s %v", filename, err)
+	}
+	config, err := parseYAMLConfig(data)
+	if err != nil {
+		return config, fmt.Errorf("%v (%s)", err, filename)
+	}
+	return config, nil
+}
+
+func parseYAMLConfig(data []byte) (JSONConfig, error) {
+	tree, err := parseYAML(data)
+	if err == nil {
+		var config JSONConfig
+		if config, err = decodeConfigTree(tree); err == nil {
+			return config, nil
+		}
+	}
+	return JSONConfig{}, fmt.Errorf("TIMBER! Can't parse yaml config: %v", err)
+}
+
+// A line with its comment and indentation taken off
+type yamlLine struct {
+	num    int
+	indent int
+	text   string
+}
+
+type yamlParser struct {
+	lines []yamlLine
+	pos   int
+}
+
+func parseYAML(data []byte) (interface{}, error) {
+	p := &yamlParser{}
+	for i, raw := range strings.Split(string(data), "\n") {
+		raw = strings.TrimRight(stripYAMLComment(raw), " \t\r")
+		text := strings.TrimLeft(raw, " ")
+		if text == "" || (len(p.lines) == 0 && text == "---") {
+			continue
+		}
+		if text == "..." || (text == "---" && len(p.lines) > 0) {
+			break // one document only
+		}
+		if text[0] == '\t' {
+			return nil, fmt.Errorf("line %d: tabs can't indent", i+1)
+		}
+		p.lines = append(p.lines, yamlLine{num: i + 1, indent: len(raw) - len(text), text: text})
+	}
+	if len(p.lines) == 0 {
+		return nil, nil
+	}
+	node, err := p.parseNode(p.lines[0].indent)
+	if err == nil && p.pos < len(p.lines) {
+		err = fmt.Errorf("line %d: bad indentation", p.lines[p.pos].num)
+	}
+	return node, err
+}
+
+// The block starting at the current line, if it's indented by indent or more
+func (p *yamlParser) parseNode(indent int) (interface{}, error) {
+	if p.pos >= len(p.lines) || p.lines[p.pos].indent < indent {
+		return nil, nil
+	}
+	line := p.lines[p.pos]
+	if isYAMLSeqItem(line.text) {
+		return p.parseSeq(line.indent)
+	}
+	if _, _, ok := splitYAMLKey(line.text); ok {
+		return p.parseMap(line.indent)
+	}
+	p.pos++
+	return parseYAMLScalar(line)
+}
+
+func (p *yamlParser) parseSeq(indent int) (interface{}, error) {
+	list := []interface{}{}
+	for p.pos < len(p.lines) && p.lines[p.pos].indent == indent && isYAMLSeqItem(p.lines[p.pos].text) {
+		line := p.lines[p.pos]
+		rest := strings.TrimLeft(line.text[1:], " ")
+		if rest == "" {
+			p.pos++
+			item, err := p.parseNode(indent + 1)
+			if err != nil {
+				return nil, err
+			}
+			list = append(list, item)
+			continue
+		}
+		// "- key: value" starts a mapping indented where key is, so the
+		// line is parsed again as if the dash were a space
+		p.lines[p.pos] = yamlLine{num: line.num, indent: indent + len(line.text) - len(rest), text: rest}
+		item, err := p.parseNode(indent + 1)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, item)
+	}
+	return list, nil
+}
+
+func (p *yamlParser) parseMap(indent int) (interface{}, error) {
+	m := make(map[string]interface{})
+	for p.pos < len(p.lines) && p.lines[p.pos].indent == indent {
+		line := p.lines[p.pos]
+		key, value, ok := splitYAMLKey(line.text)
+		if !ok {
+			return nil, fmt.Errorf("line %d: expected key: value got %q", line.num, line.text)
+		}
+		if _, dup := m[key]; dup {
+			return nil, fmt.Errorf("line %d: duplicate key %q", line.num, key)
+		}
+		p.pos++
+		var err error
+		switch {
+		case value != "":
+			m[key], err = parseYAMLScalar(yamlLine{num: line.num, text: value})
+		case p.pos < len(p.lines) && p.lines[p.pos].indent == indent && isYAMLSeqItem(p.lines[p.pos].text):
+			// a list may sit at the same indentation as its key
+			m[key], err = p.parseSeq(indent)
+		default:
+			m[key], err = p.parseNode(indent + 1)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	if p.pos < len(p.lines) && p.lines[p.pos].indent > indent {
+		return nil, fmt.Errorf("line %d: bad indentation", p.lines[p.pos].num)
+	}
+	return m, nil
+}
+
+func isYAMLSeqItem(text string) bool {
+	return text == "-" || strings.HasPrefix(text, "- ")
+}
+
+// Splits "key: value" at the first colon outside quotes that's followed
+// by a space or ends the line, so a value like localhost:9500 is safe
+func splitYAMLKey(text string) (string, string, bool) {
+	var quote byte
+	for i := 0; i < len(text); i++ {
+		c := text[i]
+		switch {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case (c == '"' || c == '\'') && i == 0:
+			quote = c
+		case c == ':' && (i+1 == len(text) || text[i+1] == ' '):
+			key := strings.TrimSpace(text[:i])
+			if unquoted, err := unquoteYAML(key); err == nil {
+				key = unquoted
+			}
+			return key, strings.TrimSpace(text[i+1:]), key != ""
+		}
+	}
+	return "", "", false
+}
+
+func parseYAMLScalar(line yamlLine) (interface{}, error) {
+	text := line.text
+	switch {
+	case text == "" || text == "~" || text == "null" || text == "Null" || text == "NULL":
+		return nil, nil
+	case text == "{}":
+		return map[string]interface{}{}, nil
+	case strings.HasPrefix(text, "["):
+		if !strings.HasSuffix(text, "]") {
+			return nil, fmt.Errorf("line %d: only one line flow lists are supported", line.num)
+		}
+		list := []interface{}{}
+		for _, item := range splitYAMLFlow(text[1 : len(text)-1]) {
+			val, err := parseYAMLScalar(yamlLine{num: line.num, text: item})
+			if err != nil {
+				return nil, err
+			}
+			list = append(list, val)
+		}
+		return list, nil
+	case text[0] == '{' || text[0] == '|' || text[0] == '>' || text[0] == '&' || text[0] == '*' || text[0] == '!':
+		return nil, fmt.Errorf("line %d: unsupported yaml %q", line.num, text)
+	}
+	s, err := unquoteYAML(text)
+	if err != nil {
+		return nil, fmt.Errorf("line %d: %v", line.num, err)
+	}
+	return s, nil
+}
+
+// The items of a flow list, split at the commas outside quotes
+func splitYAMLFlow(text string) []string {
+	var items []string
+	var quote byte
+	start := 0
+	for i := 0; i < len(text); i++ {
+		c := text[i]
+		switch {
+		case quote != 0:
+			if c == '\\' && quote == '"' {
+				i++
+			} else if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			quote = c
+		case c == ',':
+			items = append(items, strings.TrimSpace(text[start:i]))
+			start = i + 1
+		}
+	}
+	if last := strings.TrimSpace(text[start:]); last != "" || len(items) > 0 {
+		items = append(items, last)
+	}
+	return items
+}
+
+// Double quoted scalars take Go's escapes, which cover YAML's common
+// ones, and single quoted ones escape a quote by doubling it
+func unquoteYAML(text string) (string, error) {
+	if len(text) >= 2 && text[0] == '"' && text[len(text)-1] == '"' {
+		s, err := strconv.Unquote(text)
+		if err != nil {
+			return "", fmt.Errorf("bad quoted string %s", text)
+		}
+		return s, nil
+	}
+	if len(text) >= 2 && text[0] == '\'' && text[len(text)-1] == '\'' {
+		return strings.ReplaceAll(text[1:len(text)-1], "''", "'"), nil
+	}
+	if text != "" && (text[0] == '"' || text[0] == '\'') {
+		return "", fmt.Errorf("unterminated string %s", text)
+	}
+	return text, nil
+}
+
+// Cuts a # comment that starts the line or follows a space, outside quotes
+func stripYAMLComment(line string) string {
+	var quote byte
+	for i := 0; i < len(line); i++ {
+		c := line[i]
+		switch {
+		case quote != 0:
+			if c == '\\' && quote == '"' {
+				i++
+			} else if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			if i == 0 || line[i-1] == ' ' || line[i-1] == '-' || line[i-1] == '[' || line[i-1] == ',' {
+				quote = c
+			}
+		case c == '#' && (i == 0 || line[i-1] == ' ' || line[i-1] == '\t'):
+			return line[:i]
+		}
+	}
+	return line
+}
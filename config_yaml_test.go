@@ -0,0 +1,65 @@
+package timber
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestYAMLConfig(t *testing.T) {
+	expected, err := readJSONConfigFile("timber.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	config, err := readConfigFile("timber.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(config, expected) {
+		t.Errorf("expected the timber.json config got %+v", config)
+	}
+
+	config, err = parseYAMLConfig([]byte(`
+defaults:
+  level: WARNING
+filters:
+- enabled: "true"
+  type: 'console'
+  maxlevel: ERROR
+  properties: []
+  formats:
+    - level: DEBUG
+      format: "%L\t%M # not a comment"
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	filter := config.Filters[0]
+	if config.Defaults.Level != "WARNING" || !filter.Enabled || filter.Type != "console" || filter.MaxLevel != "ERROR" ||
+		filter.Formats[0].Format != "%L\t%M # not a comment" {
+		t.Errorf("unexpected config %+v", config)
+	}
+
+	for doc, msg := range map[string]string{
+		"filters:\n  - enabled: maybe\n":                "filters[0].enabled wants a boolean",
+		"filters:\n  - type: console\n   level: INFO\n": "line 3: bad indentation",
+		"filters: !!seq\n":                              "unsupported yaml",
+		"filters:\n  type: console\n":                   "filters wants a list",
+	} {
+		if _, err := parseYAMLConfig([]byte(doc)); err == nil || !strings.Contains(err.Error(), msg) {
+			t.Errorf("expected %q for %q got %v", msg, doc, err)
+		}
+	}
+}
+
+func TestLoadConfigAuto(t *testing.T) {
+	log := NewTimber()
+	if err := log.LoadConfigAuto("timber.toml"); err != nil {
+		t.Error(err)
+	}
+	log.Info("Message to TOML loggers")
+	log.Close()
+	if err := NewTimber().LoadConfigAuto("timber.ini"); err == nil || !strings.Contains(err.Error(), "Unknown config file type") {
+		t.Errorf("expected an unknown type error got %v", err)
+	}
+}
@@ -1,17 +1,97 @@
 package timber
 
 import (
-	"fmt"
+	"io"
 	"os"
 )
 
-// This uses the standard go logger to write the messages
-type ConsoleWriter func(string)
+// Writes the messages to the console, one per line.  Stream picks where
+// they go; left nil it's os.Stderr, same as the standard go logger.
+type ConsoleWriter struct {
+	Stream io.Writer
+}
+
+func (c ConsoleWriter) out() io.Writer {
+	if c.Stream == nil {
+		return os.Stderr
+	}
+	return c.Stream
+}
+
+var newline = []byte{'\n'}
 
 func (c ConsoleWriter) LogWrite(msg string) {
-	fmt.Fprint(os.Stderr, msg)
+	c.Write([]byte(msg))
+}
+
+// Allows formatters to write directly to the console
+func (c ConsoleWriter) Write(p []byte) (int, error) {
+	return writeTerminated(c.out(), p, newline)
 }
 
 func (c ConsoleWriter) Close() {
 	// Nothing
 }
+
+// Writes records at Threshold and above to Err and the rest to Out, for
+// container platforms that tell severity by the stream.  Left nil Out is
+// os.Stdout and Err os.Stderr; a zero Threshold is ERROR.  Messages
+// without a record, e.g. from LogWrite, go to Out.
+type SplitConsoleWriter struct {
+	Out       io.Writer
+	Err       io.Writer
+	Threshold Level
+}
+
+// Records at threshold and above to stderr, the rest to stdout
+func NewSplitConsoleWriter(threshold Level) *SplitConsoleWriter {
+	return &SplitConsoleWriter{Out: os.Stdout, Err: os.Stderr, Threshold: threshold}
+}
+
+func (c *SplitConsoleWriter) out() io.Writer {
+	if c.Out == nil {
+		return os.Stdout
+	}
+	return c.Out
+}
+
+func (c *SplitConsoleWriter) err() io.Writer {
+	if c.Err == nil {
+		return os.Stderr
+	}
+	return c.Err
+}
+
+// The lowest level that goes to Err
+func (c *SplitConsoleWriter) threshold() Level {
+	if c.Threshold == 0 {
+		return ERROR
+	}
+	return c.Threshold
+}
+
+// The stream records at lvl go to
+func (c *SplitConsoleWriter) streamFor(lvl Level) io.Writer {
+	if lvl >= c.threshold() {
+		return c.err()
+	}
+	return c.out()
+}
+
+func (c *SplitConsoleWriter) LogWrite(msg string) {
+	writeTerminated(c.out(), []byte(msg), newline)
+}
+
+// RecordWriter interface, the record's level picks the stream
+func (c *SplitConsoleWriter) LogWriteRecord(rec *LogRecord, msg string) {
+	writeTerminated(c.streamFor(rec.Level), []byte(msg), newline)
+}
+
+// SourceUser interface, the record only picks the stream
+func (c *SplitConsoleWriter) UsesSource() bool {
+	return false
+}
+
+func (c *SplitConsoleWriter) Close() {
+	// Nothing
+}
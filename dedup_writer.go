@@ -0,0 +1,117 @@
+package timber
+
+import (
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Collapses a run of the same record, same level and message, into its
+// first record and a "last message repeated N times" line the way syslog
+// does, so a tight retry loop writes two lines instead of gigabytes.  A
+// run ends at a different record, at Flush or Close, or at the first
+// repeat once window has passed since the run started; that repeat is
+// written and starts a new run.  The summary is the run's last record
+// with the message replaced and no fields, formatted with formatter.
+// Since nothing is written between records, the summary for a run that
+// stops waits for the next record or flush.  The dedup_window property
+// of a filter puts one in front of its writer.
+type DedupWriter struct {
+	writer    LogWriter
+	formatter LogFormatter
+	window    time.Duration
+
+	mu         sync.Mutex
+	started    bool      // there's a run
+	first      time.Time // when the run started
+	last       LogRecord // the run's latest record
+	repeats    int
+	suppressed uint64 // accessed atomically
+}
+
+// A nil formatter writes the summary's bare message
+func NewDedupWriter(writer LogWriter, formatter LogFormatter, window time.Duration) *DedupWriter {
+	return &DedupWriter{writer: writer, formatter: formatter, window: window}
+}
+
+// Records without the record can't be compared, they end the run
+func (dw *DedupWriter) LogWrite(msg string) {
+	dw.mu.Lock()
+	defer dw.mu.Unlock()
+	dw.endRun()
+	dw.started = false
+	dw.writer.LogWrite(msg)
+}
+
+// RecordWriter interface, the record is passed on if the child wants it
+func (dw *DedupWriter) LogWriteRecord(rec *LogRecord, msg string) {
+	dw.mu.Lock()
+	defer dw.mu.Unlock()
+	if dw.started && !rec.raw && rec.Level == dw.last.Level && rec.Message == dw.last.Message &&
+		rec.Timestamp.Sub(dw.first) < dw.window {
+		dw.repeats++
+		dw.last = *rec
+		atomic.AddUint64(&dw.suppressed, 1)
+		return
+	}
+	dw.endRun()
+	writeRecord(dw.writer, rec, msg)
+	dw.started, dw.first, dw.last = !rec.raw, rec.Timestamp, *rec
+}
+
+// SourceUser interface
+func (dw *DedupWriter) UsesSource() bool {
+	return formatterUsesSource(dw.formatter) || writerUsesSource(dw.writer)
+}
+
+// must hold mu; writes the summary if the run had repeats
+func (dw *DedupWriter) endRun() {
+	if dw.repeats == 0 {
+		return
+	}
+	summary := dw.last
+	summary.Message = "last message repeated once"
+	if dw.repeats > 1 {
+		summary.Message = fmt.Sprintf("last message repeated %d times", dw.repeats)
+	}
+	summary.Fields = nil
+	msg := summary.Message
+	if dw.formatter != nil {
+		msg = dw.formatter.Format(&summary)
+	}
+	writeRecord(dw.writer, &summary, msg)
+	dw.repeats = 0
+}
+
+// How many repeats have been collapsed
+func (dw *DedupWriter) Suppressed() uint64 {
+	return atomic.LoadUint64(&dw.suppressed)
+}
+
+// Writes the summary of the current run then flushes the child if it's a
+// Flusher.  The run carries on, later repeats are counted from zero.
+func (dw *DedupWriter) Flush() {
+	dw.mu.Lock()
+	dw.endRun()
+	dw.mu.Unlock()
+	if fl, ok := dw.writer.(Flusher); ok {
+		fl.Flush()
+	}
+}
+
+func (dw *DedupWriter) Reopen() error {
+	if ro, ok := dw.writer.(Reopener); ok {
+		return ro.Reopen()
+	}
+	return nil
+}
+
+// Writes the summary of the current run, then closes the child
+func (dw *DedupWriter) Close() {
+	dw.mu.Lock()
+	dw.endRun()
+	dw.started = false
+	dw.mu.Unlock()
+	dw.writer.Close()
+}
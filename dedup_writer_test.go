@@ -0,0 +1,54 @@
+package timber
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestDedupWriter(t *testing.T) {
+	now := time.Date(2012, 3, 4, 5, 6, 7, 0, time.UTC)
+	mw := NewMemoryWriter(20)
+	dw := NewDedupWriter(mw, NewPatFormatter("%L %M"), time.Minute)
+	write := func(lvl Level, msg string) {
+		rec := &LogRecord{Level: lvl, Message: msg, Timestamp: now}
+		dw.LogWriteRecord(rec, LevelStrings[lvl]+" "+msg)
+	}
+	for i := 0; i < 4; i++ {
+		write(ERROR, "disk full")
+	}
+	write(WARNING, "disk full") // another level is another record
+	write(ERROR, "disk full")
+	write(ERROR, "disk full")
+	now = now.Add(time.Minute)
+	write(ERROR, "disk full") // the window is over
+	write(ERROR, "disk full")
+	dw.Flush()
+	write(ERROR, "disk full")
+	write(INFO, "ok")
+	dw.Close()
+	expected := []string{"EROR disk full", "EROR last message repeated 3 times", "WARN disk full",
+		"EROR disk full", "EROR last message repeated once", "EROR disk full",
+		"EROR last message repeated once", "EROR last message repeated once", "INFO ok"}
+	if got := mw.Messages(); !reflect.DeepEqual(got, expected) {
+		t.Errorf("expected %q got %q", expected, got)
+	}
+	if n := dw.Suppressed(); n != 6 {
+		t.Errorf("expected 6 suppressed got %d", n)
+	}
+}
+
+func TestDedupConfig(t *testing.T) {
+	cLog, _, err := buildLogger(nil, JSONFilter{Enabled: true, Type: "null", Level: "INFO",
+		Format: JSONProperty{"pattern", "%M"}, Properties: []JSONProperty{{"dedup_window", "10s"}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if dw, ok := cLog.LogWriter.(*DedupWriter); !ok || dw.window != 10*time.Second {
+		t.Errorf("expected a DedupWriter got %T", cLog.LogWriter)
+	}
+	if _, _, err := buildLogger(nil, JSONFilter{Enabled: true, Type: "null", Level: "INFO",
+		Properties: []JSONProperty{{"dedup_window", "often"}}}); err == nil {
+		t.Error("expected an error for a bad dedup_window")
+	}
+}
@@ -0,0 +1,144 @@
+package timber
+
+import (
+	"bytes"
+	"runtime"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// What LogRecord.Delta (the %E directive) is measured from
+type DeltaScope int32
+
+const (
+	DeltaGlobal    DeltaScope = iota // the previous record from anywhere in the process
+	DeltaGoroutine                   // the previous record from the same goroutine
+)
+
+// accessed atomically, see SetDeltaScope
+var deltaScope int32
+
+// unix nanos of the last record for DeltaGlobal, accessed atomically
+var deltaLast int64
+
+// unix nanos of the last record by goroutine id for DeltaGoroutine
+var deltaByGoroutine sync.Map
+
+// entries in deltaByGoroutine and the count that has it swept, accessed
+// atomically
+var deltaGoroutines, deltaSweepAt int64 = 0, minGoroutineSweep
+
+// one sweep at a time
+var deltaSweep sync.Mutex
+
+// The fewest entries a map by goroutine id is swept at
+const minGoroutineSweep = 1024
+
+// Picks what the delta of each record is measured from, DeltaGlobal to
+// start with.  DeltaGoroutine has to look up the goroutine id on every log
+// call, which costs a stack read, and remembers a timestamp for each
+// goroutine that has logged.  Goroutine ids aren't reused so the ones
+// that have exited are swept out once there are twice as many as the
+// last sweep left, which dumps every stack; keep DeltaGoroutine to
+// debugging.
+func SetDeltaScope(scope DeltaScope) {
+	atomic.StoreInt32(&deltaScope, int32(scope))
+}
+
+// The time from the previous record in the current scope to now, 0 for
+// the first one
+func nextDelta(now time.Time) time.Duration {
+	nanos := now.UnixNano()
+	var prev int64
+	if DeltaScope(atomic.LoadInt32(&deltaScope)) == DeltaGoroutine {
+		if last, loaded := deltaByGoroutine.Swap(goroutineID(), nanos); loaded {
+			prev = last.(int64)
+		} else if atomic.AddInt64(&deltaGoroutines, 1) > atomic.LoadInt64(&deltaSweepAt) {
+			sweepDeltas()
+		}
+	} else {
+		prev = atomic.SwapInt64(&deltaLast, nanos)
+	}
+	if prev == 0 || prev > nanos {
+		return 0
+	}
+	return time.Duration(nanos - prev)
+}
+
+// Forgets the goroutines that have exited.  A sweep already going is
+// enough, the others don't wait for it.
+func sweepDeltas() {
+	if !deltaSweep.TryLock() {
+		return
+	}
+	defer deltaSweep.Unlock()
+	live := liveGoroutines()
+	deltaByGoroutine.Range(func(id, _ interface{}) bool {
+		if !live[id.(uint64)] {
+			if _, loaded := deltaByGoroutine.LoadAndDelete(id); loaded {
+				atomic.AddInt64(&deltaGoroutines, -1)
+			}
+		}
+		return true
+	})
+	atomic.StoreInt64(&deltaSweepAt, nextGoroutineSweep(int(atomic.LoadInt64(&deltaGoroutines))))
+}
+
+// The size a map by goroutine id that has size entries left after a
+// sweep is swept again at: double, so sweeps get rarer as it grows
+func nextGoroutineSweep(size int) int64 {
+	if size < minGoroutineSweep/2 {
+		return minGoroutineSweep
+	}
+	return 2 * int64(size)
+}
+
+// The ids of the goroutines that are running, from a dump of all the
+// stacks.  That stops the world while it runs so it's only for sweeps.
+func liveGoroutines() map[uint64]bool {
+	buf := make([]byte, 64<<10)
+	for {
+		n := runtime.Stack(buf, true)
+		if n < len(buf) {
+			buf = buf[:n]
+			break
+		}
+		buf = make([]byte, 2*len(buf))
+	}
+	live := make(map[uint64]bool)
+	for _, stack := range bytes.Split(buf, []byte("\n\n")) {
+		live[parseGoroutineID(stack)] = true
+	}
+	return live
+}
+
+// The calling goroutine's id
+func goroutineID() uint64 {
+	var buf [64]byte
+	return parseGoroutineID(buf[:runtime.Stack(buf[:], false)])
+}
+
+// Parses the id out of the "goroutine 18 [running]:" header of a stack
+func parseGoroutineID(b []byte) uint64 {
+	b = bytes.TrimPrefix(b, []byte("goroutine "))
+	if i := bytes.IndexByte(b, ' '); i > 0 {
+		b = b[:i]
+	}
+	id, _ := strconv.ParseUint(string(b), 10, 64)
+	return id
+}
+
+// Renders a delta like +12.3ms, rounded to a tenth of its unit
+func formatDelta(d time.Duration) string {
+	switch {
+	case d >= time.Second:
+		d = d.Round(100 * time.Millisecond)
+	case d >= time.Millisecond:
+		d = d.Round(100 * time.Microsecond)
+	case d >= time.Microsecond:
+		d = d.Round(100 * time.Nanosecond)
+	}
+	return "+" + d.String()
+}
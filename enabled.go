@@ -0,0 +1,116 @@
+package timber
+
+import (
+	"runtime"
+	"sync/atomic"
+)
+
+// What Enabled needs of the loggers, published by the dispatch goroutine
+// whenever their levels change
+type levelSnapshot struct {
+	loggers []ConfigLogger // just the levels, granulars and maxes
+	// no granulars or maxes anywhere, minLevel answers for every caller
+	simple bool
+}
+
+// Publishes the levels of loggers for skip and Enabled and compiles their
+// granular patterns, only called from the dispatch goroutine
+func (t *Timber) storeLevels(loggers []ConfigLogger) {
+	atomic.StoreInt32(&t.minLevel, int32(minLoggerLevel(loggers)))
+	var unused int32
+	if len(loggers) > 0 && !loggersNeedSource(loggers) {
+		unused = 1
+	}
+	atomic.StoreInt32(&t.sourceUnused, unused)
+	snap := &levelSnapshot{loggers: make([]ConfigLogger, len(loggers)), simple: len(loggers) > 0}
+	for i := range loggers {
+		loggers[i].granularPats = compileGranulars(loggers[i].Granulars)
+		cLog := loggers[i]
+		snap.loggers[i] = ConfigLogger{Level: cLog.Level, MaxLevel: cLog.MaxLevel,
+			Granulars: cLog.Granulars, GranularMax: cLog.GranularMax, granularPats: cLog.granularPats}
+		if len(cLog.Granulars) > 0 || cLog.MaxLevel != 0 {
+			snap.simple = false
+		}
+	}
+	t.levels.Store(snap)
+}
+
+// True if a record at lvl logged from here would get past some logger's
+// levels, granulars and max level included, as a guard for messages that
+// are expensive to build:
+//
+//	if log.Enabled(timber.DEBUG) {
+//		log.Debug("state: %s", dumpState())
+//	}
+//
+// The caller's package and function are only looked up when a logger has
+// granulars.  Samplers and record filters aren't taken into account; a
+// ContextWithDebug context isn't either.
+func (t *Timber) Enabled(lvl Level) bool {
+	return t.enabledAt(lvl, "", t.FileDepth)
+}
+
+func (t *Timber) FinestEnabled() bool { return t.enabledAt(FINEST, "", t.FileDepth) }
+func (t *Timber) FineEnabled() bool   { return t.enabledAt(FINE, "", t.FileDepth) }
+func (t *Timber) DebugEnabled() bool  { return t.enabledAt(DEBUG, "", t.FileDepth) }
+func (t *Timber) TraceEnabled() bool  { return t.enabledAt(TRACE, "", t.FileDepth) }
+func (t *Timber) InfoEnabled() bool   { return t.enabledAt(INFO, "", t.FileDepth) }
+
+// Same as Enabled for a record from path, a package path, package path +
+// function name or NamedLogger name as a granular would match it, rather
+// than from the caller
+func (t *Timber) EnabledFor(path string, lvl Level) bool {
+	if t.skip(lvl) {
+		return false
+	}
+	rec := LogRecord{Level: lvl, FuncPath: path, PackagePath: splitPackage(path), LoggerName: path}
+	return t.takes(&rec)
+}
+
+// depth counts frames like prepare; name is the NamedLogger's, if any
+func (t *Timber) enabledAt(lvl Level, name string, depth int) bool {
+	if t.skip(lvl) {
+		return false
+	}
+	if snap, _ := t.levels.Load().(*levelSnapshot); snap == nil || snap.simple {
+		return snap != nil
+	}
+	rec := LogRecord{Level: lvl, FuncPath: "_", PackagePath: "_", LoggerName: name}
+	var pcs [1]uintptr
+	if runtime.Callers(depth+1, pcs[:]) > 0 {
+		if me := runtime.FuncForPC(pcs[0] - 1); me != nil {
+			rec.FuncPath = me.Name()
+			rec.PackagePath = splitPackage(rec.FuncPath)
+		}
+	}
+	return t.takes(&rec)
+}
+
+// sendToLoggers' level checks without the sending
+func (t *Timber) takes(rec *LogRecord) bool {
+	snap, _ := t.levels.Load().(*levelSnapshot)
+	if snap == nil {
+		return false
+	}
+	for _, cLog := range snap.loggers {
+		level, max := cLog.Level, cLog.MaxLevel
+		if path, gLevel, ok := cLog.granularFor(rec); ok {
+			level = gLevel
+			if gMax, ok := cLog.GranularMax[path]; ok {
+				max = gMax
+			}
+		}
+		if (rec.Level >= level || level == 0) && !aboveMax(rec, max) {
+			return true
+		}
+	}
+	return false
+}
+
+func Enabled(lvl Level) bool                 { return global().Enabled(lvl) }
+func FinestEnabled() bool                    { return global().FinestEnabled() }
+func FineEnabled() bool                      { return global().FineEnabled() }
+func DebugEnabled() bool                     { return global().DebugEnabled() }
+func TraceEnabled() bool                     { return global().TraceEnabled() }
+func InfoEnabled() bool                      { return global().InfoEnabled() }
+func EnabledFor(path string, lvl Level) bool { return global().EnabledFor(path, lvl) }
@@ -0,0 +1,46 @@
+package timber
+
+import "testing"
+
+func TestEnabled(t *testing.T) {
+	log := NewTimber()
+	if log.InfoEnabled() {
+		t.Error("expected nothing enabled without loggers")
+	}
+	log.AddLogger(ConfigLogger{LogWriter: NewMemoryWriter(1), Level: INFO})
+	if !log.InfoEnabled() || !log.Enabled(ERROR) || log.DebugEnabled() || log.FineEnabled() {
+		t.Error("expected INFO and up enabled")
+	}
+	log.AddLogger(ConfigLogger{LogWriter: NewMemoryWriter(1), Level: WARNING, MaxLevel: ERROR,
+		Granulars: map[string]Level{
+			"github.com/smw1218/timber":                   FINEST,
+			"github.com/smw1218/timber.TestEnabled.func1": ERROR,
+			"myapp.db": DEBUG,
+		}})
+	log.FileDepth = DefaultFileDepth - 1 // called on log directly, not through Global
+	if !log.FinestEnabled() {
+		t.Error("expected the package granular to enable FINEST")
+	}
+	func() {
+		if log.TraceEnabled() || !log.Enabled(CRITICAL) {
+			t.Error("expected the first logger's INFO and no ceiling for the function")
+		}
+	}()
+	if log.EnabledFor("other/pkg", DEBUG) || !log.EnabledFor("other/pkg", INFO) {
+		t.Error("expected INFO for another package")
+	}
+	if log.EnabledFor("myapp.db", FINE) || !log.EnabledFor("myapp.db.pool", DEBUG) {
+		t.Error("expected the name granular for myapp.db and its children")
+	}
+	named := log.GetLogger("myapp.db.pool")
+	if !named.DebugEnabled() || named.Enabled(FINE) {
+		t.Error("expected DEBUG for myapp.db.pool")
+	}
+	if err := log.SetGranular("myapp.db", FINE); err != nil {
+		t.Fatal(err)
+	}
+	if !named.Enabled(FINE) {
+		t.Error("expected SetGranular to change Enabled")
+	}
+	log.Close()
+}
@@ -0,0 +1,20 @@
+//go:build !windows
+
+package timber
+
+import (
+	"fmt"
+	"runtime"
+)
+
+// The Windows Event Log writer, only available on windows
+type EventLogWriter struct{}
+
+// Always fails since there's no Event Log outside of windows
+func NewEventLogWriter(source string) (*EventLogWriter, error) {
+	return nil, fmt.Errorf("TIMBER! The Windows Event Log is not supported on %s", runtime.GOOS)
+}
+
+func (ew *EventLogWriter) LogWrite(msg string) {}
+
+func (ew *EventLogWriter) Close() {}
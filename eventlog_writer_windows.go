@@ -0,0 +1,96 @@
+//go:build windows
+
+package timber
+
+import (
+	"sync"
+	"syscall"
+	"unsafe"
+)
+
+var (
+	advapi32                  = syscall.NewLazyDLL("advapi32.dll")
+	procRegisterEventSource   = advapi32.NewProc("RegisterEventSourceW")
+	procDeregisterEventSource = advapi32.NewProc("DeregisterEventSource")
+	procReportEvent           = advapi32.NewProc("ReportEventW")
+)
+
+// Event Log types
+const (
+	eventlogErrorType       = 0x0001
+	eventlogWarningType     = 0x0002
+	eventlogInformationType = 0x0004
+)
+
+// Writes messages to the Windows Event Log (Application) using source.
+// The event source is registered on the first write.  ERROR and CRITICAL
+// are logged as Error events, WARNING as Warning, everything else as
+// Information.
+type EventLogWriter struct {
+	source  string
+	handle  uintptr
+	regOnce sync.Once
+	regErr  error
+}
+
+func NewEventLogWriter(source string) (*EventLogWriter, error) {
+	return &EventLogWriter{source: source}, nil
+}
+
+func (ew *EventLogWriter) register() error {
+	ew.regOnce.Do(func() {
+		src, err := syscall.UTF16PtrFromString(ew.source)
+		if err != nil {
+			ew.regErr = err
+			return
+		}
+		handle, _, err := procRegisterEventSource.Call(0, uintptr(unsafe.Pointer(src)))
+		if handle == 0 {
+			ew.regErr = err
+			return
+		}
+		ew.handle = handle
+	})
+	return ew.regErr
+}
+
+func (ew *EventLogWriter) LogWrite(msg string) {
+	ew.report(eventlogInformationType, msg)
+}
+
+// RecordWriter interface, picks the event type from the level
+func (ew *EventLogWriter) LogWriteRecord(rec *LogRecord, msg string) {
+	switch {
+	case rec.Level >= ERROR:
+		ew.report(eventlogErrorType, msg)
+	case rec.Level == WARNING:
+		ew.report(eventlogWarningType, msg)
+	default:
+		ew.report(eventlogInformationType, msg)
+	}
+}
+
+func (ew *EventLogWriter) report(eventType uint16, msg string) {
+	if err := ew.register(); err != nil {
+		reportWriteError(ew, err)
+		return
+	}
+	str, err := syscall.UTF16PtrFromString(msg)
+	if err != nil {
+		reportWriteError(ew, err)
+		return
+	}
+	strs := []*uint16{str}
+	ret, _, err := procReportEvent.Call(ew.handle, uintptr(eventType), 0, 1, 0, 1, 0,
+		uintptr(unsafe.Pointer(&strs[0])), 0)
+	if ret == 0 {
+		reportWriteError(ew, err)
+	}
+}
+
+func (ew *EventLogWriter) Close() {
+	if ew.handle != 0 {
+		procDeregisterEventSource.Call(ew.handle)
+		ew.handle = 0
+	}
+}
@@ -0,0 +1,183 @@
+package timber
+
+import (
+	"fmt"
+	"io"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// How long a FallbackWriter leaves a failed writer alone before trying it
+// again, see SetRetryInterval
+const DefaultFallbackRetry = 30 * time.Second
+
+// Optional interface for a LogWriter that can tell it didn't take a
+// record, so a FallbackWriter can send it somewhere else.  A nil error
+// means the record was written or queued.
+type CheckedWriter interface {
+	LogWriteChecked(rec *LogRecord, msg string) error
+}
+
+// Writes to the first writer of a chain that works, e.g. a socket, then a
+// local file, then the console, so records aren't lost while a remote
+// collector is down.  A writer has failed when its LogWriteChecked or,
+// for an io.Writer, its Write returns an error; an AsyncWriter is a
+// CheckedWriter that fails when OverflowDrop drops.  Other writers can't
+// tell and always count as working, a BufferedWriter included since it
+// writes later.  A failed writer is skipped until the retry interval has
+// passed, then the next record tries it again.  The last writer is
+// always tried.
+//
+// Failing over and coming back are logged in the chain as a WARNING
+// record, formatted with the formatter, naming the writers: the failover
+// goes to the writer taking over and the recovery to the writer that's
+// back, each right after the record that found out.  The fallback
+// property of a filter, repeated for a longer chain, puts one in front of
+// its writer.
+type FallbackWriter struct {
+	writers   []LogWriter
+	formatter LogFormatter
+
+	mu        sync.Mutex
+	retry     time.Duration
+	down      []time.Time      // when each writer last failed, zero while it works
+	failovers uint64           // accessed atomically
+	now       func() time.Time // swapped out by the tests
+}
+
+// A nil formatter writes the failover records' bare message
+func NewFallbackWriter(formatter LogFormatter, primary LogWriter, fallbacks ...LogWriter) *FallbackWriter {
+	writers := append([]LogWriter{primary}, fallbacks...)
+	return &FallbackWriter{writers: writers, formatter: formatter, retry: DefaultFallbackRetry,
+		down: make([]time.Time, len(writers)), now: time.Now}
+}
+
+func (fw *FallbackWriter) SetRetryInterval(interval time.Duration) {
+	fw.mu.Lock()
+	fw.retry = interval
+	fw.mu.Unlock()
+}
+
+// The number of times a writer failed and the chain moved past it
+func (fw *FallbackWriter) Failovers() uint64 {
+	return atomic.LoadUint64(&fw.failovers)
+}
+
+func (fw *FallbackWriter) LogWrite(msg string) {
+	fw.LogWriteRecord(&LogRecord{Level: INFO, Message: msg, Timestamp: currentTime(), raw: true}, msg)
+}
+
+// RecordWriter interface, the record is passed on if the writer wants it
+func (fw *FallbackWriter) LogWriteRecord(rec *LogRecord, msg string) {
+	fw.mu.Lock()
+	defer fw.mu.Unlock()
+	if err := fw.write(rec, msg); err != nil {
+		reportWriteError(fw, err)
+	}
+}
+
+// CheckedWriter interface so chains can nest, fails when the last writer
+// did
+func (fw *FallbackWriter) LogWriteChecked(rec *LogRecord, msg string) error {
+	fw.mu.Lock()
+	defer fw.mu.Unlock()
+	return fw.write(rec, msg)
+}
+
+// must hold mu
+func (fw *FallbackWriter) write(rec *LogRecord, msg string) error {
+	now := fw.now()
+	var failed []string // the writers that failed just now
+	var lastErr error
+	for i, w := range fw.writers {
+		last := i == len(fw.writers)-1
+		wasDown := !fw.down[i].IsZero()
+		if wasDown && !last && now.Sub(fw.down[i]) < fw.retry {
+			continue
+		}
+		err := checkedWrite(w, rec, msg)
+		if err == nil {
+			if len(failed) > 0 {
+				fw.event(w, rec, fmt.Sprintf("TIMBER! Writing to %s, %s failed: %v", describeWriter(w),
+					joinNames(failed), lastErr))
+			}
+			if wasDown {
+				fw.down[i] = time.Time{}
+				fw.event(w, rec, fmt.Sprintf("TIMBER! %s is working again", describeWriter(w)))
+			}
+			return nil
+		}
+		lastErr = err
+		if !wasDown {
+			failed = append(failed, describeWriter(w))
+			if !last {
+				atomic.AddUint64(&fw.failovers, 1)
+			}
+		}
+		fw.down[i] = now
+	}
+	return fmt.Errorf("TIMBER! Every writer failed, the last with: %v", lastErr)
+}
+
+// Writes a failover record about rec to w, never checked since there's
+// nothing to do if it fails too
+func (fw *FallbackWriter) event(w LogWriter, rec *LogRecord, message string) {
+	event := LogRecord{Level: WARNING, Timestamp: rec.Timestamp, Message: message, FuncPath: "_",
+		PackagePath: "_", LoggerTag: rec.LoggerTag}
+	msg := message
+	if fw.formatter != nil {
+		msg = fw.formatter.Format(&event)
+	}
+	writeRecord(w, &event, msg)
+}
+
+func joinNames(names []string) string {
+	joined := names[0]
+	for _, name := range names[1:] {
+		joined += " and " + name
+	}
+	return joined
+}
+
+// Sends rec to w with the error when w can give one
+func checkedWrite(w LogWriter, rec *LogRecord, msg string) error {
+	if cw, ok := w.(CheckedWriter); ok {
+		return cw.LogWriteChecked(rec, msg)
+	}
+	_, structured := w.(StructuredWriter)
+	_, recorded := w.(RecordWriter)
+	if iw, ok := w.(io.Writer); ok && !structured && !recorded {
+		_, err := iw.Write([]byte(msg))
+		return err
+	}
+	writeRecord(w, rec, msg)
+	return nil
+}
+
+func (fw *FallbackWriter) Flush() {
+	for _, w := range fw.writers {
+		if fl, ok := w.(Flusher); ok {
+			fl.Flush()
+		}
+	}
+}
+
+// Reopens the writers that are Reopeners, returns the first error
+func (fw *FallbackWriter) Reopen() error {
+	var first error
+	for _, w := range fw.writers {
+		if ro, ok := w.(Reopener); ok {
+			if err := ro.Reopen(); err != nil && first == nil {
+				first = err
+			}
+		}
+	}
+	return first
+}
+
+func (fw *FallbackWriter) Close() {
+	for _, w := range fw.writers {
+		w.Close()
+	}
+}
@@ -0,0 +1,99 @@
+package timber
+
+import (
+	"errors"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// fails every write while down is set
+type flakyWriter struct {
+	down bool
+	msgs []string
+}
+
+func (fw *flakyWriter) LogWrite(msg string) { fw.Write([]byte(msg)) }
+func (fw *flakyWriter) Write(p []byte) (int, error) {
+	if fw.down {
+		return 0, errors.New("connection refused")
+	}
+	fw.msgs = append(fw.msgs, string(p))
+	return len(p), nil
+}
+func (fw *flakyWriter) Close()         {}
+func (fw *flakyWriter) String() string { return "flaky" }
+
+func TestFallbackWriter(t *testing.T) {
+	now := time.Date(2012, 3, 4, 5, 6, 7, 0, time.UTC)
+	primary, second := &flakyWriter{}, &flakyWriter{}
+	last := NewMemoryWriter(10)
+	fw := NewFallbackWriter(NewPatFormatter("%L %M"), primary, second, last)
+	fw.now = func() time.Time { return now }
+	write := func(msg string) { fw.LogWriteRecord(&LogRecord{Level: INFO, Message: msg, Timestamp: now}, msg) }
+
+	write("one")
+	primary.down = true
+	write("two")
+	write("three")
+	second.down = true
+	write("four")
+	primary.down, second.down = false, false
+	now = now.Add(10 * time.Second)
+	write("too soon")
+	now = now.Add(DefaultFallbackRetry)
+	write("five")
+
+	if expected := []string{"one", "five", "WARN TIMBER! flaky is working again"}; !reflect.DeepEqual(primary.msgs, expected) {
+		t.Errorf("expected %q got %q", expected, primary.msgs)
+	}
+	expected := []string{"two", "WARN TIMBER! Writing to flaky, flaky failed: connection refused", "three"}
+	if !reflect.DeepEqual(second.msgs, expected) {
+		t.Errorf("expected %q got %q", expected, second.msgs)
+	}
+	expected = []string{"four", "WARN TIMBER! Writing to *timber.MemoryWriter, flaky failed: connection refused", "too soon"}
+	if got := last.Messages(); !reflect.DeepEqual(got, expected) {
+		t.Errorf("expected %q got %q", expected, got)
+	}
+	if n := fw.Failovers(); n != 2 {
+		t.Errorf("expected 2 failovers got %d", n)
+	}
+}
+
+func TestFallbackAsyncFull(t *testing.T) {
+	sw := &stallWriter{release: make(chan bool), mw: NewMemoryWriter(10)}
+	aw := NewAsyncWriter(sw, 1, OverflowDrop)
+	mw := NewMemoryWriter(10)
+	fw := NewFallbackWriter(nil, aw, mw)
+	fw.LogWrite("a")
+	time.Sleep(10 * time.Millisecond) // let the loop pick up a and stall
+	fw.LogWrite("b")
+	fw.LogWrite("c")
+	close(sw.release)
+	aw.Close()
+	expected := []string{"c", "TIMBER! Writing to *timber.MemoryWriter, *timber.stallWriter (async) failed: " + ErrQueueFull.Error()}
+	if got := mw.Messages(); !reflect.DeepEqual(got, expected) {
+		t.Errorf("expected %q got %q", expected, got)
+	}
+	if got := sw.mw.Messages(); !reflect.DeepEqual(got, []string{"a", "b"}) {
+		t.Errorf("expected a b got %q", got)
+	}
+}
+
+func TestFallbackConfig(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "fallback.log")
+	cLog, _, err := buildLogger(nil, JSONFilter{Enabled: true, Type: "null", Level: "INFO",
+		Properties: []JSONProperty{{"fallback", name}, {"fallback", "stderr"}, {"fallback_retry", "5s"}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cLog.LogWriter.Close()
+	fw, ok := cLog.LogWriter.(*FallbackWriter)
+	if !ok || len(fw.writers) != 3 || fw.retry != 5*time.Second {
+		t.Fatalf("expected a chain of 3 got %T", cLog.LogWriter)
+	}
+	if desc := describeWriter(fw); desc != "nowhere (falling back to shared file "+name+", then console stderr)" {
+		t.Errorf("unexpected description %q", desc)
+	}
+}
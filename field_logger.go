@@ -0,0 +1,130 @@
+package timber
+
+import (
+	"context"
+	"errors"
+)
+
+// A Timber with fields attached to every record it logs, from
+// WithFields.  It's cheap to make one per request and safe to share.
+type FieldLogger struct {
+	t      *Timber
+	fields Fields
+	depth  int // frames to skip past FileDepth, see WithCallDepth
+}
+
+// Returns a logger that adds fields to every record, e.g.
+//
+//	timber.WithFields(timber.Fields{"user_id": 42}).Info("login ok")
+//
+// The messages are still printf style.  fields is copied so changing it
+// afterwards doesn't change the logger.
+func (t *Timber) WithFields(fields Fields) *FieldLogger {
+	return &FieldLogger{t: t, fields: mergeFields(nil, fields)}
+}
+
+func WithFields(fields Fields) *FieldLogger { return global().WithFields(fields) }
+
+// Returns a logger with the fields of ctx, from NewContext and the
+// registered extractors, for code that has the context but logs with the
+// plain methods
+func (t *Timber) FromContext(ctx context.Context) *FieldLogger {
+	return t.WithFields(fieldsFromContext(ctx))
+}
+
+func FromContext(ctx context.Context) *FieldLogger { return global().FromContext(ctx) }
+
+// Returns a logger with fields added to the ones this one has; fields
+// wins on duplicate keys
+func (fl *FieldLogger) WithFields(fields Fields) *FieldLogger {
+	return &FieldLogger{t: fl.t, fields: mergeFields(fl.fields, fields), depth: fl.depth}
+}
+
+// Returns a logger that looks n more frames up the stack for the caller,
+// for a wrapper library whose own n functions sit between the call site
+// and timber, so %s and the granulars see the wrapper's caller:
+//
+//	var log = timber.WithCallDepth(1)
+//	func Infof(format string, args ...interface{}) { log.Info(format, args...) }
+//
+// Unlike changing FileDepth it leaves the other callers of the Timber alone.
+func (t *Timber) WithCallDepth(n int) *FieldLogger {
+	return &FieldLogger{t: t, depth: n}
+}
+
+func WithCallDepth(n int) *FieldLogger { return global().WithCallDepth(n) }
+
+// Returns this logger looking n more frames up the stack, see
+// Timber.WithCallDepth
+func (fl *FieldLogger) WithCallDepth(n int) *FieldLogger {
+	return &FieldLogger{t: fl.t, fields: fl.fields, depth: fl.depth + n}
+}
+
+func mergeFields(base, extra Fields) Fields {
+	merged := make(Fields, len(base)+len(extra))
+	for k, v := range base {
+		merged[k] = v
+	}
+	for k, v := range extra {
+		merged[k] = v
+	}
+	return merged
+}
+
+func (fl *FieldLogger) send(lvl Level, msg string) {
+	// this frame stands in for the package function DefaultFileDepth
+	// counts on, so FileDepth still lands on the caller
+	fl.t.prepareAndSendFields(lvl, msg, fl.fields, fl.t.FileDepth+fl.depth)
+}
+
+func (fl *FieldLogger) Finest(arg0 interface{}, args ...interface{}) {
+	if fl.t.skip(FINEST) {
+		return
+	}
+	fl.send(FINEST, sprintf(arg0, args))
+}
+func (fl *FieldLogger) Fine(arg0 interface{}, args ...interface{}) {
+	if fl.t.skip(FINE) {
+		return
+	}
+	fl.send(FINE, sprintf(arg0, args))
+}
+func (fl *FieldLogger) Debug(arg0 interface{}, args ...interface{}) {
+	if fl.t.skip(DEBUG) {
+		return
+	}
+	fl.send(DEBUG, sprintf(arg0, args))
+}
+func (fl *FieldLogger) Trace(arg0 interface{}, args ...interface{}) {
+	if fl.t.skip(TRACE) {
+		return
+	}
+	fl.send(TRACE, sprintf(arg0, args))
+}
+func (fl *FieldLogger) Info(arg0 interface{}, args ...interface{}) {
+	if fl.t.skip(INFO) {
+		return
+	}
+	fl.send(INFO, sprintf(arg0, args))
+}
+func (fl *FieldLogger) Warn(arg0 interface{}, args ...interface{}) error {
+	msg := sprintf(arg0, args)
+	fl.send(WARNING, msg)
+	return errors.New(msg)
+}
+func (fl *FieldLogger) Error(arg0 interface{}, args ...interface{}) error {
+	msg := sprintf(arg0, args)
+	fl.send(ERROR, msg)
+	return errors.New(msg)
+}
+func (fl *FieldLogger) Critical(arg0 interface{}, args ...interface{}) error {
+	msg := sprintf(arg0, args)
+	fl.send(CRITICAL, msg)
+	return errors.New(msg)
+}
+func (fl *FieldLogger) Log(lvl Level, arg0 interface{}, args ...interface{}) {
+	if fl.t.skip(lvl) {
+		return
+	}
+	fl.send(lvl, sprintf(arg0, args))
+}
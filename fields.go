@@ -0,0 +1,165 @@
+package timber
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Structured key/value data attached to a LogRecord
+type Fields map[string]interface{}
+
+// Well known field names for distributed tracing.  The %r and %n pattern
+// directives render these, and a TraceProvider registered with
+// RegisterTraceProvider (see trace.go) puts the trace and span ids on log
+// lines emitted within a span.
+const (
+	TraceIDField = "trace_id"
+	SpanIDField  = "span_id"
+)
+
+// A record with this field set to a name in ConfigLogger.FieldFormatters
+// is formatted by that formatter instead of the logger's own, e.g.
+// Fields{FormatField: "json"}.  The JSON formatter leaves it out.
+const FormatField = "_format"
+
+// Pulls fields out of a context for the ...Context logging methods, e.g.
+// trace ids stored under well known keys.  Return nil if there's nothing.
+type ContextExtractor func(ctx context.Context) Fields
+
+var (
+	extractorLock sync.RWMutex
+	// pointers so unregister can find its own
+	contextExtractors []*ContextExtractor
+)
+
+// Registers an extractor that is run on the context of every ...Context
+// logging call.  Fields from all the extractors are merged in
+// registration order so later extractors win on duplicate keys.  The
+// returned func removes it again, e.g. in a test's Cleanup.
+func RegisterContextExtractor(extractor ContextExtractor) (unregister func()) {
+	registered := &extractor
+	extractorLock.Lock()
+	contextExtractors = append(contextExtractors, registered)
+	extractorLock.Unlock()
+	return func() {
+		extractorLock.Lock()
+		defer extractorLock.Unlock()
+		for i, e := range contextExtractors {
+			if e == registered {
+				contextExtractors = append(contextExtractors[:i:i], contextExtractors[i+1:]...)
+				return
+			}
+		}
+	}
+}
+
+type verbosityKey struct{}
+
+// Returns a context that makes the ...Context logging methods log at
+// DEBUG and above for it, whatever the loggers' levels, e.g. for one
+// request flagged for debugging in production.  Every logger gets the
+// extra records, granulars included; the other contexts are unaffected.
+func ContextWithDebug(ctx context.Context) context.Context {
+	return ContextWithLevel(ctx, DEBUG)
+}
+
+// Same as ContextWithDebug for any level
+func ContextWithLevel(ctx context.Context, lvl Level) context.Context {
+	return context.WithValue(ctx, verbosityKey{}, lvl)
+}
+
+// True if ctx came from ContextWithLevel with a level at or below lvl
+func contextElevates(ctx context.Context, lvl Level) bool {
+	if ctx == nil {
+		return false
+	}
+	verbosity, ok := ctx.Value(verbosityKey{}).(Level)
+	return ok && lvl >= verbosity
+}
+
+type fieldsKey struct{}
+
+// Returns a context that carries fields, added to any ctx already has, so
+// fields set once (say a request id in middleware) are on every record
+// logged with it: the ...Context logging methods pick them up and
+// FromContext makes a FieldLogger with them.  fields is copied.
+func NewContext(ctx context.Context, fields Fields) context.Context {
+	return context.WithValue(ctx, fieldsKey{}, mergeFields(contextFields(ctx), fields))
+}
+
+func contextFields(ctx context.Context) Fields {
+	fields, _ := ctx.Value(fieldsKey{}).(Fields)
+	return fields
+}
+
+// The fields from the extractors then the ones from NewContext, which win
+// on duplicate keys
+func fieldsFromContext(ctx context.Context) Fields {
+	if ctx == nil {
+		return nil
+	}
+	extractorLock.RLock()
+	defer extractorLock.RUnlock()
+	var fields Fields
+	for _, extractor := range contextExtractors {
+		for k, v := range (*extractor)(ctx) {
+			if fields == nil {
+				fields = make(Fields)
+			}
+			fields[k] = v
+		}
+	}
+	if fields == nil {
+		// never changed once it's in the context, so records can share it
+		return contextFields(ctx)
+	}
+	for k, v := range contextFields(ctx) {
+		fields[k] = v
+	}
+	return fields
+}
+
+// How time.Duration fields are rendered, see SetDurationFormat on the
+// formatters
+type DurationFormat int
+
+const (
+	DurationString  DurationFormat = iota // Duration.String, e.g. 1.5s
+	DurationMillis                        // a number of milliseconds, e.g. 1500
+	DurationSeconds                       // a number of seconds, e.g. 1.5
+)
+
+// Parses the duration_format property: string, ms or s
+func ParseDurationFormat(s string) (DurationFormat, error) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "", "string":
+		return DurationString, nil
+	case "ms":
+		return DurationMillis, nil
+	case "s":
+		return DurationSeconds, nil
+	}
+	return DurationString, fmt.Errorf("TIMBER! Unknown duration_format %q, expected string, ms or s", s)
+}
+
+// Converts durations and times in a field to what the formatters write.
+// Times get the same layout as the record timestamp in JSON and
+// SetTimePrecision applies to them too.  Anything else comes back as is.
+func (df DurationFormat) fieldValue(val interface{}) interface{} {
+	switch v := val.(type) {
+	case time.Duration:
+		switch df {
+		case DurationMillis:
+			return float64(v) / float64(time.Millisecond)
+		case DurationSeconds:
+			return v.Seconds()
+		}
+		return v.String()
+	case time.Time:
+		return truncateTime(v).Format(time.RFC3339Nano)
+	}
+	return val
+}
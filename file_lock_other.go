@@ -0,0 +1,18 @@
+//go:build !(linux || darwin || freebsd || netbsd || openbsd || dragonfly)
+
+package timber
+
+import (
+	"fmt"
+	"os"
+	"runtime"
+)
+
+// No flock here, SharedFileWriter.SetLocking fails
+const canLockFiles = false
+
+func lockFile(f *os.File) error {
+	return fmt.Errorf("TIMBER! File locking is not supported on %s", runtime.GOOS)
+}
+
+func unlockFile(f *os.File) error { return nil }
@@ -3,6 +3,8 @@ package timber
 import (
 	"fmt"
 	"os"
+	"sync"
+	"time"
 )
 
 /* unbuffered impl
@@ -28,12 +30,74 @@ func (fw *FileWriter) Close() {
 }
 */
 
-// This writer has a buffer that I don't ever bother to flush, so it may take a while
+// This writer has a buffer that's flushed once a second, so it may take a while
 // to see messages
 func NewFileWriter(name string) (LogWriter, error) {
-	file, err := os.OpenFile(name, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0666)
+	return NewFileWriterInterval(name, time.Second)
+}
+
+// Same as NewFileWriter but the buffer is flushed every interval; zero
+// flushes after every write.  The writer is a Reopener so it works with
+// InstallSignalReopen.
+func NewFileWriterInterval(name string, interval time.Duration) (LogWriter, error) {
+	file, err := openReopenFile(name)
+	if err != nil {
+		return nil, err
+	}
+	return NewBufferedWriterInterval(file, interval)
+}
+
+// An append-only file that can be reopened by name
+type reopenFile struct {
+	name string
+	mu   sync.Mutex
+	file *os.File
+}
+
+func openReopenFile(name string) (*reopenFile, error) {
+	rf := &reopenFile{name: name}
+	if err := rf.open(); err != nil {
+		return nil, err
+	}
+	return rf, nil
+}
+
+// must hold mu
+func (rf *reopenFile) open() error {
+	file, err := os.OpenFile(rf.name, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0666)
 	if err != nil {
-		return nil, fmt.Errorf("TIMBER! Can't open %v: %v", name, err)
+		return fmt.Errorf("TIMBER! Can't open %v: %v", rf.name, err)
+	}
+	rf.file = file
+	return nil
+}
+
+func (rf *reopenFile) Write(p []byte) (int, error) {
+	rf.mu.Lock()
+	defer rf.mu.Unlock()
+	if rf.file == nil {
+		return 0, fmt.Errorf("TIMBER! %v is not open", rf.name)
+	}
+	return rf.file.Write(p)
+}
+
+func (rf *reopenFile) Reopen() error {
+	rf.mu.Lock()
+	defer rf.mu.Unlock()
+	if rf.file != nil {
+		rf.file.Close()
+		rf.file = nil
+	}
+	return rf.open()
+}
+
+func (rf *reopenFile) Close() error {
+	rf.mu.Lock()
+	defer rf.mu.Unlock()
+	if rf.file == nil {
+		return nil
 	}
-	return NewBufferedWriter(file)
+	err := rf.file.Close()
+	rf.file = nil
+	return err
 }
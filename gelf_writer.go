@@ -0,0 +1,312 @@
+package timber
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"encoding/binary"
+	"fmt"
+	"net"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// How a GELFWriter compresses what it sends over UDP
+type GELFCompression int
+
+const (
+	GELFNone GELFCompression = iota
+	GELFGzip
+	GELFZlib
+)
+
+// Parses the compress property: none, gzip or zlib
+func ParseGELFCompression(s string) (GELFCompression, error) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "", "none":
+		return GELFNone, nil
+	case "gzip":
+		return GELFGzip, nil
+	case "zlib":
+		return GELFZlib, nil
+	}
+	return GELFNone, fmt.Errorf("TIMBER! Unknown compress %q for gelf log writer, expected none, gzip or zlib", s)
+}
+
+// Chunk sizes for UDP: what fits a datagram across the internet and on a
+// LAN, the sizes the Graylog libraries use
+const (
+	GELFChunkWAN = 1420
+	GELFChunkLAN = 8154
+)
+
+// GELF allows no more chunks than this for one message
+const gelfMaxChunks = 128
+
+var gelfChunkMagic = []byte{0x1e, 0x0f}
+
+// Sends each record to Graylog as a GELF 1.1 message, over UDP in chunks
+// when it's too big for one datagram, compressed if asked, or over TCP
+// ended by a null byte.  The formatted record is the short_message, the
+// first line of it when there's more than one and the whole of it is the
+// full_message.  level is the record's SyslogSeverity; _file, _line,
+// _func, _level (the timber level) and _logger come from the record and
+// each field is an additional field of its own, _user_id for user_id,
+// with characters GELF doesn't allow turned into underscores and id,
+// which GELF reserves, sent as _id_.  Numbers stay numbers, everything
+// else is sent as a string.  Leave the time and level out of the
+// pattern since the message has them.
+type GELFWriter struct {
+	network   string
+	addr      string
+	host      string
+	compress  GELFCompression
+	chunkSize int
+	msgID     uint64 // accessed atomically
+
+	mu     sync.Mutex
+	udp    net.Conn      // for udp, nil after Close
+	stream *SocketWriter // for tcp
+	buf    []byte
+	zbuf   bytes.Buffer
+}
+
+// network is udp or tcp; compression only goes with udp, GELF over TCP
+// isn't compressed.  The host of the messages is the machine's name.
+func NewGELFWriter(network, addr string, compression GELFCompression) (*GELFWriter, error) {
+	gw := &GELFWriter{network: network, addr: addr, host: processHostname, compress: compression,
+		chunkSize: GELFChunkWAN, msgID: uint64(time.Now().UnixNano()) ^ uint64(os.Getpid())<<48}
+	switch network {
+	case "udp", "udp4", "udp6":
+		conn, err := net.Dial(network, addr)
+		if err != nil {
+			return nil, fmt.Errorf("TIMBER! Can't connect to %s://%s: %v", network, addr, err)
+		}
+		gw.udp = conn
+	case "tcp", "tcp4", "tcp6":
+		if compression != GELFNone {
+			return nil, fmt.Errorf("TIMBER! GELF over TCP can't be compressed")
+		}
+		sw, err := NewSocketWriter(network, addr)
+		if err != nil {
+			return nil, err
+		}
+		sw.SetFraming("\x00")
+		gw.stream = sw
+	default:
+		return nil, fmt.Errorf("TIMBER! Unknown network %q for gelf log writer, expected udp or tcp", network)
+	}
+	return gw, nil
+}
+
+// Sets the host of the messages instead of the machine's name.  Call it
+// before the writer is used.
+func (gw *GELFWriter) SetHost(host string) {
+	gw.host = host
+}
+
+// Sets the largest datagram, GELFChunkWAN unless it's set; a message
+// that needs more than 128 chunks is dropped.  Call it before the writer
+// is used.
+func (gw *GELFWriter) SetChunkSize(n int) {
+	if n <= len(gelfChunkMagic)+10 {
+		n = GELFChunkWAN
+	}
+	gw.chunkSize = n
+}
+
+// Without a record the message goes out as informational
+func (gw *GELFWriter) LogWrite(msg string) {
+	gw.LogWriteRecord(&LogRecord{Level: INFO, Message: msg, Timestamp: currentTime(), raw: true}, msg)
+}
+
+func (gw *GELFWriter) LogWriteRecord(rec *LogRecord, msg string) {
+	gw.mu.Lock()
+	defer gw.mu.Unlock()
+	if gw.udp == nil && gw.stream == nil {
+		return
+	}
+	gw.buf = appendGELFMessage(gw.buf[:0], rec, msg, gw.host)
+	if gw.stream != nil {
+		gw.stream.Write(gw.buf)
+		return
+	}
+	if err := gw.sendUDP(gw.buf); err != nil {
+		reportWriteError(gw, err)
+	}
+}
+
+// must hold mu
+func (gw *GELFWriter) sendUDP(msg []byte) error {
+	switch gw.compress {
+	case GELFGzip, GELFZlib:
+		gw.zbuf.Reset()
+		var err error
+		if gw.compress == GELFGzip {
+			zw := gzip.NewWriter(&gw.zbuf)
+			if _, err = zw.Write(msg); err == nil {
+				err = zw.Close()
+			}
+		} else {
+			zw := zlib.NewWriter(&gw.zbuf)
+			if _, err = zw.Write(msg); err == nil {
+				err = zw.Close()
+			}
+		}
+		if err != nil {
+			return err
+		}
+		msg = gw.zbuf.Bytes()
+	}
+	if len(msg) <= gw.chunkSize {
+		_, err := gw.udp.Write(msg)
+		return err
+	}
+	// magic, 8 byte message id, sequence number and count
+	dataSize := gw.chunkSize - len(gelfChunkMagic) - 10
+	count := (len(msg) + dataSize - 1) / dataSize
+	if count > gelfMaxChunks {
+		return fmt.Errorf("TIMBER! GELF message of %d bytes needs more than %d chunks", len(msg), gelfMaxChunks)
+	}
+	id := atomic.AddUint64(&gw.msgID, 1)
+	chunk := make([]byte, 0, gw.chunkSize)
+	for seq := 0; seq < count; seq++ {
+		data := msg[seq*dataSize:]
+		if len(data) > dataSize {
+			data = data[:dataSize]
+		}
+		chunk = append(chunk[:0], gelfChunkMagic...)
+		chunk = binary.BigEndian.AppendUint64(chunk, id)
+		chunk = append(chunk, byte(seq), byte(count))
+		chunk = append(chunk, data...)
+		if _, err := gw.udp.Write(chunk); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// The GELF 1.1 JSON of the record
+func appendGELFMessage(buf []byte, rec *LogRecord, msg, host string) []byte {
+	msg = strings.TrimRight(msg, "\r\n")
+	short := msg
+	if i := strings.IndexAny(msg, "\r\n"); i >= 0 {
+		short = msg[:i]
+	}
+	if short == "" {
+		// GELF wants one
+		short = "-"
+	}
+	buf = append(buf, `{"version":"1.1","host":`...)
+	buf = appendJSONValue(buf, host)
+	buf = append(buf, `,"short_message":`...)
+	buf = appendJSONValue(buf, short)
+	if short != msg {
+		buf = append(buf, `,"full_message":`...)
+		buf = appendJSONValue(buf, msg)
+	}
+	timestamp := rec.Timestamp
+	if timestamp.IsZero() {
+		timestamp = currentTime()
+	}
+	buf = append(buf, `,"timestamp":`...)
+	buf = strconv.AppendFloat(buf, float64(timestamp.UnixNano()/int64(time.Millisecond))/1000, 'f', 3, 64)
+	buf = append(buf, `,"level":`...)
+	buf = strconv.AppendInt(buf, int64(SyslogSeverity(rec.Level)), 10)
+	if rec.raw {
+		return append(buf, '}')
+	}
+	buf = append(buf, `,"_level":`...)
+	buf = appendJSONValue(buf, LongLevelStrings[rec.Level])
+	if rec.SourceFile != "" {
+		buf = append(buf, `,"_file":`...)
+		buf = appendJSONValue(buf, rec.SourceFile)
+		buf = append(buf, `,"_line":`...)
+		buf = strconv.AppendInt(buf, int64(rec.SourceLine), 10)
+	}
+	if rec.FuncPath != "" && rec.FuncPath != "_" {
+		buf = append(buf, `,"_func":`...)
+		buf = appendJSONValue(buf, rec.FuncPath)
+	}
+	if rec.LoggerName != "" {
+		buf = append(buf, `,"_logger":`...)
+		buf = appendJSONValue(buf, rec.LoggerName)
+	}
+	keys := make([]string, 0, len(rec.Fields))
+	for key := range rec.Fields {
+		if key != FormatField && rec.Fields[key] != nil {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		name := gelfFieldName(key)
+		if gelfOwnFields[name] {
+			continue
+		}
+		buf = append(buf, ',')
+		buf = appendJSONValue(buf, name)
+		buf = append(buf, ':')
+		buf = appendJSONValue(buf, gelfValue(rec.Fields[key]))
+	}
+	return append(buf, '}')
+}
+
+// The additional fields the writer sets itself, a record field doesn't
+// add a second one
+var gelfOwnFields = map[string]bool{"_level": true, "_file": true, "_line": true, "_func": true, "_logger": true}
+
+// _ and the key, with what GELF doesn't allow in a field name, anything
+// but letters, digits, _, . and -, turned into _
+func gelfFieldName(key string) string {
+	if key == "id" {
+		return "_id_"
+	}
+	name := make([]byte, 0, len(key)+1)
+	name = append(name, '_')
+	for i := 0; i < len(key); i++ {
+		c := key[i]
+		switch {
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9', c == '_', c == '.', c == '-':
+		default:
+			c = '_'
+		}
+		name = append(name, c)
+	}
+	return string(name)
+}
+
+// A number for the number types, a string for the rest
+func gelfValue(val interface{}) interface{} {
+	switch v := val.(type) {
+	case string, int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64, float32, float64:
+		return v
+	case error:
+		return v.Error()
+	}
+	return fmt.Sprint(val)
+}
+
+func (gw *GELFWriter) Flush() {
+	if gw.stream != nil {
+		gw.stream.Flush()
+	}
+}
+
+func (gw *GELFWriter) Close() {
+	gw.mu.Lock()
+	defer gw.mu.Unlock()
+	if gw.udp != nil {
+		gw.udp.Close()
+		gw.udp = nil
+	}
+	if gw.stream != nil {
+		gw.stream.Close()
+		gw.stream = nil
+	}
+}
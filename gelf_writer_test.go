@@ -0,0 +1,160 @@
+package timber
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"encoding/json"
+	"io"
+	"net"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestGELFMessage(t *testing.T) {
+	rec := &LogRecord{Level: ERROR, Timestamp: time.Unix(1700000000, 123456789), SourceFile: "/src/app/main.go",
+		SourceLine: 42, FuncPath: "app/main.run", LoggerName: "app.db", Message: "query failed",
+		Fields: Fields{"user_id": 7, "id": "abc", "bad key!": "x", "err": io.EOF, "took": time.Second, FormatField: "skip"}}
+	var got map[string]interface{}
+	if err := json.Unmarshal(appendGELFMessage(nil, rec, "query failed\nat line 3\n", "web-1"), &got); err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string]interface{}{"version": "1.1", "host": "web-1", "short_message": "query failed",
+		"full_message": "query failed\nat line 3", "timestamp": 1700000000.123, "level": float64(SeverityError),
+		"_level": "ERROR", "_file": "/src/app/main.go", "_line": float64(42), "_func": "app/main.run",
+		"_logger": "app.db", "_user_id": float64(7), "_id_": "abc", "_bad_key_": "x", "_err": "EOF", "_took": "1s"}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("expected %v got %v", expected, got)
+	}
+}
+
+func TestGELFWriterUDP(t *testing.T) {
+	for _, compression := range []GELFCompression{GELFNone, GELFGzip, GELFZlib} {
+		conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+		if err != nil {
+			t.Fatal(err)
+		}
+		gw, err := NewGELFWriter("udp", conn.LocalAddr().String(), compression)
+		if err != nil {
+			t.Fatal(err)
+		}
+		gw.SetChunkSize(100)
+		long := strings.Repeat("0123456789", 60)
+		gw.LogWriteRecord(&LogRecord{Level: INFO, Message: long}, long)
+		gw.Close()
+
+		// reassemble the chunks in the order of their sequence numbers
+		conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+		var chunks [][]byte
+		var msg []byte
+		received := 0
+		for {
+			packet := make([]byte, 200)
+			n, _, err := conn.ReadFrom(packet)
+			if err != nil {
+				t.Fatalf("%v: %v", compression, err)
+			}
+			packet = packet[:n]
+			if !bytes.HasPrefix(packet, gelfChunkMagic) {
+				msg = packet
+				break
+			}
+			if n > 100 {
+				t.Errorf("%v: chunk of %d bytes", compression, n)
+			}
+			seq, count := packet[10], packet[11]
+			if chunks == nil {
+				chunks = make([][]byte, count)
+			}
+			chunks[seq] = packet[12:]
+			if received++; received == len(chunks) {
+				msg = bytes.Join(chunks, nil)
+				break
+			}
+		}
+		conn.Close()
+		var r io.Reader = bytes.NewReader(msg)
+		switch compression {
+		case GELFGzip:
+			r, _ = gzip.NewReader(r)
+		case GELFZlib:
+			r, _ = zlib.NewReader(r)
+		}
+		var got map[string]interface{}
+		if err := json.NewDecoder(r).Decode(&got); err != nil {
+			t.Fatalf("%v: %v", compression, err)
+		}
+		if got["short_message"] != long || got["level"] != float64(SeverityInformational) {
+			t.Errorf("%v: expected the long message at informational, got %v", compression, got)
+		}
+	}
+}
+
+func TestGELFWriterTCP(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	if _, err := NewGELFWriter("tcp", ln.Addr().String(), GELFGzip); err == nil {
+		t.Errorf("expected an error for compressed GELF over TCP")
+	}
+	received := make(chan []string, 1)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		var msgs []string
+		r := bufio.NewReader(conn)
+		for {
+			msg, err := r.ReadString(0)
+			if err != nil {
+				break
+			}
+			msgs = append(msgs, msg)
+		}
+		received <- msgs
+	}()
+	logger := NewTimber()
+	w, err := getGELFWriter(JSONFilter{Properties: []JSONProperty{{"protocol", "tcp"}, {"endpoint", ln.Addr().String()}, {"host", "box"}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	logger.AddLogger(ConfigLogger{LogWriter: w, Level: INFO, Formatter: NewPatFormatter("%M")})
+	logger.Warnw("one", Fields{"k": "v"})
+	logger.Info("two")
+	logger.Close()
+	msgs := <-received
+	if len(msgs) != 2 || !strings.HasSuffix(msgs[0], "}\x00") ||
+		!strings.Contains(msgs[0], `"host":"box","short_message":"one"`) || !strings.Contains(msgs[0], `"_k":"v"`) {
+		t.Errorf("expected 2 null terminated messages, got %q", msgs)
+	}
+}
+
+func TestGELFConfig(t *testing.T) {
+	for _, props := range [][]JSONProperty{
+		{{"protocol", "udp"}},
+		{{"endpoint", "127.0.0.1:12201"}, {"compress", "lz4"}},
+		{{"endpoint", "127.0.0.1:12201"}, {"chunk_size", "huge"}},
+		{{"endpoint", "127.0.0.1:12201"}, {"protocol", "sctp"}},
+	} {
+		if _, err := getGELFWriter(JSONFilter{Properties: props}); err == nil {
+			t.Errorf("expected an error for %v", props)
+		}
+	}
+	w, err := getGELFWriter(JSONFilter{Properties: []JSONProperty{
+		{"endpoint", "127.0.0.1:12201"}, {"compress", "zlib"}, {"chunk_size", "lan"}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	gw := w.(*GELFWriter)
+	if gw.network != "udp" || gw.compress != GELFZlib || gw.chunkSize != GELFChunkLAN {
+		t.Errorf("expected a zlib udp writer with LAN chunks, got %+v", gw)
+	}
+	gw.Close()
+}
@@ -0,0 +1,133 @@
+package timber
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Granular paths that aren't an exact package or function:
+//
+//	github.com/acme/svc/...        the package and all of its sub-packages
+//	github.com/acme/*/db           a glob on the package or function path, * and ?
+//	                               stop at a slash and ... matches anything
+//	re:\.\(\*Server\)\.Handle      a regexp on the function path, unanchored
+//
+// An exact path beats the longest matching ... prefix, which beats the
+// globs and regexps; of those the longest pattern wins.
+const GranularRegexpPrefix = "re:"
+
+type granularPattern struct {
+	path   string         // the key in Granulars
+	prefix string         // for a ... prefix, the package path before it
+	re     *regexp.Regexp // for a glob or a regexp
+	funcs  bool           // re only matches the function path
+}
+
+// The patterns of a logger's granulars, compiled when the loggers change
+type granularIndex struct {
+	prefixes []granularPattern // longest first
+	patterns []granularPattern // longest first
+}
+
+// Only exact paths, nothing to match
+var noGranularPatterns = new(granularIndex)
+
+func isGranularPattern(path string) bool {
+	return strings.HasPrefix(path, GranularRegexpPrefix) || strings.ContainsAny(path, "*?") ||
+		strings.Contains(path, "...")
+}
+
+// Compiles a granular path that is a pattern, an error if it doesn't
+// compile.  False for an exact path.
+func compileGranular(path string) (granularPattern, bool, error) {
+	gp := granularPattern{path: path}
+	switch {
+	case strings.HasPrefix(path, GranularRegexpPrefix):
+		re, err := regexp.Compile(path[len(GranularRegexpPrefix):])
+		if err != nil {
+			return gp, true, fmt.Errorf("TIMBER! Bad granular regexp %q: %v", path, err)
+		}
+		gp.re, gp.funcs = re, true
+	case strings.HasSuffix(path, "/...") && !strings.ContainsAny(path, "*?") &&
+		!strings.Contains(path[:len(path)-4], "..."):
+		gp.prefix = path[:len(path)-4]
+	case isGranularPattern(path):
+		gp.re = regexp.MustCompile("^" + globRegexp(path) + "$")
+	default:
+		return gp, false, nil
+	}
+	return gp, true, nil
+}
+
+func globRegexp(glob string) string {
+	var b strings.Builder
+	for i := 0; i < len(glob); i++ {
+		switch {
+		case strings.HasPrefix(glob[i:], "..."):
+			b.WriteString(".*")
+			i += 2
+		case glob[i] == '*':
+			b.WriteString("[^/]*")
+		case glob[i] == '?':
+			b.WriteString("[^/]")
+		default:
+			b.WriteString(regexp.QuoteMeta(glob[i : i+1]))
+		}
+	}
+	return b.String()
+}
+
+// An error if path is a granular pattern that doesn't compile, for
+// checking a path before SetGranular
+func CheckGranularPath(path string) error {
+	_, _, err := compileGranular(path)
+	return err
+}
+
+// The patterns among granulars, the ones that don't compile are left out;
+// SetGranular and the config loaders report those
+func compileGranulars(granulars map[string]Level) *granularIndex {
+	var idx granularIndex
+	for path := range granulars {
+		gp, ok, err := compileGranular(path)
+		if !ok || err != nil {
+			continue
+		}
+		if gp.re == nil {
+			idx.prefixes = append(idx.prefixes, gp)
+		} else {
+			idx.patterns = append(idx.patterns, gp)
+		}
+	}
+	if len(idx.prefixes) == 0 && len(idx.patterns) == 0 {
+		return noGranularPatterns
+	}
+	longestFirst := func(gps []granularPattern) {
+		sort.Slice(gps, func(i, j int) bool {
+			if len(gps[i].path) != len(gps[j].path) {
+				return len(gps[i].path) > len(gps[j].path)
+			}
+			return gps[i].path < gps[j].path
+		})
+	}
+	longestFirst(idx.prefixes)
+	longestFirst(idx.patterns)
+	return &idx
+}
+
+// The first pattern that matches the record's source
+func (idx *granularIndex) match(rec *LogRecord) (string, bool) {
+	for _, gp := range idx.prefixes {
+		if rec.PackagePath == gp.prefix || strings.HasPrefix(rec.PackagePath, gp.prefix+"/") {
+			return gp.path, true
+		}
+	}
+	for _, gp := range idx.patterns {
+		if gp.re.MatchString(rec.FuncPath) || (!gp.funcs && gp.re.MatchString(rec.PackagePath)) {
+			return gp.path, true
+		}
+	}
+	return "", false
+}
@@ -0,0 +1,461 @@
+// Package httplog provides net/http middleware that writes an access log
+// line for every request through a timber logger of its own, so the
+// access log gets the same writers, rotation and shipping as the
+// application's logs.  The loggers come from the accesslog filters of
+// the application's config file, which the application's Timber skips:
+//
+//	timber.LoadConfigAuto("timber.json")
+//	httplog.LoadConfig("timber.json")
+//	http.ListenAndServe(":8080", httplog.Handler(nil, mux))
+//
+// with a filter like
+//
+//	{"Enabled": true, "Type": "accesslog", "Level": "INFO",
+//	 "Format": {"Name": "combined"},
+//	 "Properties": [{"Name": "writer", "Value": "file"},
+//	                {"Name": "filename", "Value": "/var/log/app/access.log"},
+//	                {"Name": "rotate", "Value": "daily"}]}
+//
+// Each request is logged at INFO once the handler returns.  The message
+// is the line in the Apache combined log format and the fields are
+// method, uri, proto, status, bytes, duration, remote_addr, host, user,
+// referer and user_agent, so a json format ships them as attributes.
+// Importing the package registers three formatters with the config
+// loaders: combined, common and accesslog, which takes a pattern of
+// Apache directives in the format's value, see NewFormatter.
+package httplog
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/smw1218/timber"
+)
+
+// The Apache log formats
+const (
+	CommonLogFormat   = `%h %l %u %t "%r" %>s %b`
+	CombinedLogFormat = CommonLogFormat + ` "%{Referer}i" "%{User-Agent}i"`
+)
+
+// The Timber a Handler given nil logs to.  It takes the accesslog filters
+// of a config, see LoadConfig.
+var Default = NewTimber()
+
+// A Timber for access logs: its config loaders build the accesslog
+// filters of a config and skip the rest, see timber.AccessLogType
+func NewTimber() *timber.Timber {
+	t := timber.NewTimber()
+	t.TakeAccessLogs()
+	return t
+}
+
+// Loads the accesslog filters in filename, any type
+// timber.LoadConfigAuto takes, into Default
+func LoadConfig(filename string) error {
+	return Default.LoadConfigAuto(filename)
+}
+
+func init() {
+	timber.RegisterFormatterFactory("combined", func(timber.JSONFilter) (timber.LogFormatter, error) {
+		return NewFormatter(CombinedLogFormat), nil
+	})
+	timber.RegisterFormatterFactory("common", func(timber.JSONFilter) (timber.LogFormatter, error) {
+		return NewFormatter(CommonLogFormat), nil
+	})
+	timber.RegisterFormatterFactory("accesslog", func(filter timber.JSONFilter) (timber.LogFormatter, error) {
+		if filter.Format.Value == "" {
+			return nil, fmt.Errorf("TIMBER! Missing pattern for the accesslog formatter")
+		}
+		return NewFormatter(filter.Format.Value), nil
+	})
+}
+
+// Logs each request next serves to t, Default if t is nil
+func Handler(t *timber.Timber, next http.Handler) http.Handler {
+	return &handler{t: t, next: next}
+}
+
+// Handler as the func(http.Handler) http.Handler routers chain
+func Middleware(t *timber.Timber) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return Handler(t, next)
+	}
+}
+
+type handler struct {
+	t    *timber.Timber
+	next http.Handler
+}
+
+func (h *handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	t := h.t
+	if t == nil {
+		t = Default
+	}
+	if !t.InfoEnabled() {
+		h.next.ServeHTTP(w, r)
+		return
+	}
+	start := time.Now()
+	rw := &responseWriter{ResponseWriter: w}
+	// deferred so a request whose handler panics is logged too, as a 500
+	// unless it got as far as the header
+	returned := false
+	defer func() {
+		if !returned && rw.status == 0 {
+			rw.status = http.StatusInternalServerError
+		}
+		e := newEntry(r, rw, start)
+		t.Infow(e.format(combinedParts), e.fields())
+	}()
+	h.next.ServeHTTP(rw, r)
+	returned = true
+}
+
+// What's logged about a request
+type entry struct {
+	start      time.Time
+	method     string
+	uri        string
+	proto      string
+	status     int
+	bytes      int64
+	duration   time.Duration
+	remoteAddr string
+	host       string
+	user       string
+	referer    string
+	userAgent  string
+}
+
+func newEntry(r *http.Request, rw *responseWriter, start time.Time) *entry {
+	e := &entry{start: start, method: r.Method, uri: r.RequestURI, proto: r.Proto, status: rw.status,
+		bytes: rw.bytes, duration: time.Since(start), remoteAddr: r.RemoteAddr, host: r.Host,
+		referer: r.Referer(), userAgent: r.UserAgent()}
+	if e.uri == "" {
+		e.uri = r.URL.RequestURI()
+	}
+	if host, _, err := net.SplitHostPort(e.remoteAddr); err == nil {
+		e.remoteAddr = host
+	}
+	if user, _, ok := r.BasicAuth(); ok {
+		e.user = user
+	}
+	if e.status == 0 {
+		// what net/http sends for a handler that writes nothing
+		e.status = http.StatusOK
+		if rw.hijacked {
+			e.status = http.StatusSwitchingProtocols
+		}
+	}
+	return e
+}
+
+func (e *entry) fields() timber.Fields {
+	return timber.Fields{"method": e.method, "uri": e.uri, "proto": e.proto, "status": e.status,
+		"bytes": e.bytes, "duration": e.duration, "remote_addr": e.remoteAddr, "host": e.host,
+		"user": e.user, "referer": e.referer, "user_agent": e.userAgent}
+}
+
+// The entry a record from Handler was made from; the start is taken back
+// from the record's time
+func entryFromRecord(rec *timber.LogRecord) *entry {
+	e := &entry{}
+	str := func(key string) string {
+		s, _ := rec.Fields[key].(string)
+		return s
+	}
+	e.method, e.uri, e.proto = str("method"), str("uri"), str("proto")
+	e.remoteAddr, e.host, e.user = str("remote_addr"), str("host"), str("user")
+	e.referer, e.userAgent = str("referer"), str("user_agent")
+	status, _ := number(rec.Fields["status"])
+	e.status = int(status)
+	e.bytes, _ = number(rec.Fields["bytes"])
+	if d, ok := rec.Fields["duration"].(time.Duration); ok {
+		e.duration = d
+	} else {
+		nanos, _ := number(rec.Fields["duration"])
+		e.duration = time.Duration(nanos)
+	}
+	e.start = rec.Timestamp.Add(-e.duration)
+	return e
+}
+
+// Formats the records of Handler with a pattern of Apache log format
+// directives:
+//
+//	%h  the client's address
+//	%l  always -
+//	%u  the basic auth user, - without one
+//	%t  when the request came in, [10/Oct/2000:13:55:36 -0700]
+//	%r  the request line, GET /index.html?q=1 HTTP/1.1
+//	%m  %U  %q  %H  the method, path, query string (with its ?) and protocol
+//	%s  %>s  the status
+//	%b  the bytes of the body, - for none;  %B  the same with 0 for none
+//	%D  %T  how long the request took in microseconds and in seconds
+//	%v  the Host header
+//	%{Referer}i  %{User-Agent}i  those request headers, - when empty
+//	%%  a %
+//
+// Anything else is written as it is.  Records that didn't come from a
+// Handler are written as their message.
+type Formatter struct {
+	parts []part
+}
+
+// One literal or directive of a pattern
+type part struct {
+	literal   string
+	directive string // without the %, "" for a literal
+}
+
+var combinedParts = parsePattern(CombinedLogFormat)
+
+func NewFormatter(pattern string) *Formatter {
+	return &Formatter{parts: parsePattern(pattern)}
+}
+
+func parsePattern(pattern string) []part {
+	var parts []part
+	literal := ""
+	for i := 0; i < len(pattern); i++ {
+		if pattern[i] != '%' || i == len(pattern)-1 {
+			literal += pattern[i : i+1]
+			continue
+		}
+		rest := pattern[i+1:]
+		directive := ""
+		switch {
+		case rest[0] == '%':
+			literal += "%"
+			i++
+			continue
+		case rest[0] == '{':
+			end := strings.IndexByte(rest, '}')
+			if end < 0 || end == len(rest)-1 {
+				literal += "%"
+				continue
+			}
+			directive = rest[:end+2]
+		case strings.HasPrefix(rest, ">s"):
+			directive = "s"
+			i++
+		default:
+			directive = rest[:1]
+		}
+		if !knownDirective(directive) {
+			literal += "%"
+			continue
+		}
+		if literal != "" {
+			parts = append(parts, part{literal: literal})
+			literal = ""
+		}
+		parts = append(parts, part{directive: directive})
+		i += len(directive)
+	}
+	if literal != "" {
+		parts = append(parts, part{literal: literal})
+	}
+	return parts
+}
+
+func knownDirective(directive string) bool {
+	if len(directive) == 1 {
+		return strings.Contains("hlutrmUqHsbBDTv", directive)
+	}
+	switch strings.ToLower(directive) {
+	case "{referer}i", "{user-agent}i":
+		return true
+	}
+	return false
+}
+
+// A field as a number; a record that went through a socket may not have
+// the type Handler gave it
+func number(val interface{}) (int64, bool) {
+	switch n := val.(type) {
+	case int:
+		return int64(n), true
+	case int64:
+		return n, true
+	case float64:
+		return int64(n), true
+	}
+	return 0, false
+}
+
+func (f *Formatter) Format(rec *timber.LogRecord) string {
+	if _, ok := number(rec.Fields["status"]); !ok {
+		return rec.Message
+	}
+	return entryFromRecord(rec).format(f.parts)
+}
+
+// The formatter only looks at the fields and the time
+func (f *Formatter) UsesSource() bool {
+	return false
+}
+
+func (e *entry) format(parts []part) string {
+	var sb strings.Builder
+	for _, p := range parts {
+		if p.directive == "" {
+			sb.WriteString(p.literal)
+			continue
+		}
+		switch p.directive {
+		case "h":
+			sb.WriteString(dash(e.remoteAddr))
+		case "l":
+			sb.WriteByte('-')
+		case "u":
+			sb.WriteString(dash(escape(e.user)))
+		case "t":
+			sb.WriteString(e.start.Format("[02/Jan/2006:15:04:05 -0700]"))
+		case "r":
+			sb.WriteString(escape(e.method + " " + e.uri + " " + e.proto))
+		case "m":
+			sb.WriteString(escape(e.method))
+		case "U":
+			path := e.uri
+			if i := strings.IndexByte(path, '?'); i >= 0 {
+				path = path[:i]
+			}
+			sb.WriteString(escape(path))
+		case "q":
+			if i := strings.IndexByte(e.uri, '?'); i >= 0 {
+				sb.WriteString(escape(e.uri[i:]))
+			}
+		case "H":
+			sb.WriteString(escape(e.proto))
+		case "s":
+			sb.WriteString(strconv.Itoa(e.status))
+		case "b":
+			if e.bytes == 0 {
+				sb.WriteByte('-')
+			} else {
+				sb.WriteString(strconv.FormatInt(e.bytes, 10))
+			}
+		case "B":
+			sb.WriteString(strconv.FormatInt(e.bytes, 10))
+		case "D":
+			sb.WriteString(strconv.FormatInt(int64(e.duration/time.Microsecond), 10))
+		case "T":
+			sb.WriteString(strconv.FormatInt(int64(e.duration/time.Second), 10))
+		case "v":
+			sb.WriteString(dash(escape(e.host)))
+		default:
+			switch strings.ToLower(p.directive) {
+			case "{referer}i":
+				sb.WriteString(dash(escape(e.referer)))
+			case "{user-agent}i":
+				sb.WriteString(dash(escape(e.userAgent)))
+			}
+		}
+	}
+	return sb.String()
+}
+
+func dash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
+
+// Escapes quotes, backslashes and control characters the way Apache does
+// so a request can't forge a log line
+func escape(s string) string {
+	clean := true
+	for i := 0; i < len(s); i++ {
+		if c := s[i]; c == '"' || c == '\\' || c < 0x20 || c == 0x7f {
+			clean = false
+			break
+		}
+	}
+	if clean {
+		return s
+	}
+	var sb strings.Builder
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; {
+		case c == '"' || c == '\\':
+			sb.WriteByte('\\')
+			sb.WriteByte(c)
+		case c < 0x20 || c == 0x7f:
+			fmt.Fprintf(&sb, "\\x%02x", c)
+		default:
+			sb.WriteByte(c)
+		}
+	}
+	return sb.String()
+}
+
+// Keeps the status and the size of the body for the log line
+type responseWriter struct {
+	http.ResponseWriter
+	status   int
+	bytes    int64
+	hijacked bool
+}
+
+func (rw *responseWriter) WriteHeader(status int) {
+	if rw.status == 0 {
+		rw.status = status
+	}
+	rw.ResponseWriter.WriteHeader(status)
+}
+
+func (rw *responseWriter) Write(p []byte) (int, error) {
+	if rw.status == 0 {
+		rw.status = http.StatusOK
+	}
+	n, err := rw.ResponseWriter.Write(p)
+	rw.bytes += int64(n)
+	return n, err
+}
+
+// Keeps sendfile working for io.Copy
+func (rw *responseWriter) ReadFrom(r io.Reader) (int64, error) {
+	rf, ok := rw.ResponseWriter.(io.ReaderFrom)
+	if !ok {
+		return io.Copy(struct{ io.Writer }{rw}, r)
+	}
+	if rw.status == 0 {
+		rw.status = http.StatusOK
+	}
+	n, err := rf.ReadFrom(r)
+	rw.bytes += n
+	return n, err
+}
+
+func (rw *responseWriter) Flush() {
+	if fl, ok := rw.ResponseWriter.(http.Flusher); ok {
+		if rw.status == 0 {
+			rw.status = http.StatusOK
+		}
+		fl.Flush()
+	}
+}
+
+func (rw *responseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	hj, ok := rw.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, fmt.Errorf("httplog: %T can't be hijacked", rw.ResponseWriter)
+	}
+	rw.hijacked = true
+	return hj.Hijack()
+}
+
+// For http.ResponseController
+func (rw *responseWriter) Unwrap() http.ResponseWriter {
+	return rw.ResponseWriter
+}
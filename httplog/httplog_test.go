@@ -0,0 +1,102 @@
+package httplog
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/smw1218/timber"
+)
+
+func serve(t *timber.Timber, h http.HandlerFunc, r *http.Request) {
+	Handler(t, h).ServeHTTP(httptest.NewRecorder(), r)
+}
+
+func TestHandler(t *testing.T) {
+	logger := timber.NewTimber()
+	combined, custom := timber.NewMemoryWriter(10), timber.NewMemoryWriter(10)
+	logger.AddLogger(timber.ConfigLogger{LogWriter: combined, Level: timber.INFO, Formatter: timber.NewPatFormatter("%M")})
+	logger.AddLogger(timber.ConfigLogger{LogWriter: custom, Level: timber.INFO,
+		Formatter: NewFormatter(`%m %U%q %H %>s %B %v %{X-Other}i 100%% %Z`)})
+
+	r := httptest.NewRequest("POST", "/things?id=7", nil)
+	r.SetBasicAuth("ann", "secret")
+	r.Header.Set("Referer", "https://example.com/")
+	r.Header.Set("User-Agent", `curl/8.0 "quoted"`)
+	serve(logger, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusCreated)
+		fmt.Fprint(w, "hello")
+	}, r)
+	serve(logger, func(w http.ResponseWriter, r *http.Request) {}, httptest.NewRequest("GET", "/empty", nil))
+	func() {
+		defer func() { recover() }()
+		serve(logger, func(w http.ResponseWriter, r *http.Request) { panic("boom") }, httptest.NewRequest("GET", "/panic", nil))
+	}()
+	logger.Close()
+
+	lines := combined.Messages()
+	if len(lines) != 3 {
+		t.Fatalf("expected 3 lines got %q", lines)
+	}
+	for i, expected := range []string{
+		`^192\.0\.2\.1 - ann \[\d\d/\w{3}/\d{4}:\d\d:\d\d:\d\d [-+]\d{4}\] "POST /things\?id=7 HTTP/1\.1" 201 5 "https://example\.com/" "curl/8\.0 \\"quoted\\""$`,
+		`^192\.0\.2\.1 - - \[[^]]+\] "GET /empty HTTP/1\.1" 200 - "-" "-"$`,
+		`"GET /panic HTTP/1\.1" 500 -`,
+	} {
+		if !regexp.MustCompile(expected).MatchString(lines[i]) {
+			t.Errorf("expected %s got %q", expected, lines[i])
+		}
+	}
+	if got, expected := custom.Messages()[0], "POST /things?id=7 HTTP/1.1 201 5 example.com %{X-Other}i 100% %Z"; got != expected {
+		t.Errorf("expected %q got %q", expected, got)
+	}
+}
+
+func TestFormatterFields(t *testing.T) {
+	rec := &timber.LogRecord{Timestamp: time.Date(2000, 10, 10, 13, 55, 37, 0, time.FixedZone("", -7*3600)),
+		Fields: timber.Fields{"method": "GET", "uri": "/a.gif", "proto": "HTTP/1.0", "status": int64(404),
+			"bytes": float64(2326), "duration": int64(1500 * time.Millisecond), "remote_addr": "10.0.0.1"}}
+	expected := `10.0.0.1 - - [10/Oct/2000:13:55:35 -0700] "GET /a.gif HTTP/1.0" 404 2326 1500000 1`
+	if got := NewFormatter(CommonLogFormat + " %D %T").Format(rec); got != expected {
+		t.Errorf("expected %q got %q", expected, got)
+	}
+	if got := NewFormatter(CommonLogFormat).Format(&timber.LogRecord{Message: "not a request"}); got != "not a request" {
+		t.Errorf("expected the message of a record without a status, got %q", got)
+	}
+}
+
+func TestConfig(t *testing.T) {
+	dir := t.TempDir()
+	appLog, accessLog := filepath.Join(dir, "app.log"), filepath.Join(dir, "access.log")
+	config := fmt.Sprintf(`{"Defaults": {"Level": "WARNING", "Format": {"Name": "pattern", "Value": "%%L %%M"}},
+	"Filters": [
+		{"Enabled": true, "Type": "file", "Properties": [{"Name": "filename", "Value": %q}]},
+		{"Enabled": true, "Type": "accesslog", "Format": {"Name": "accesslog", "Value": "%%m %%U %%>s"},
+			"Properties": [{"Name": "filename", "Value": %q}]}
+	]}`, appLog, accessLog)
+	name := filepath.Join(dir, "timber.json")
+	if err := os.WriteFile(name, []byte(config), 0644); err != nil {
+		t.Fatal(err)
+	}
+	app, access := timber.NewTimber(), NewTimber()
+	if err := app.LoadConfigAuto(name); err != nil {
+		t.Fatal(err)
+	}
+	if err := access.LoadConfigAuto(name); err != nil {
+		t.Fatal(err)
+	}
+	app.Warn("app line")
+	serve(access, func(w http.ResponseWriter, r *http.Request) { http.NotFound(w, r) }, httptest.NewRequest("GET", "/missing", nil))
+	app.Close()
+	access.Close()
+	for file, expected := range map[string]string{appLog: "WARN app line\n", accessLog: "GET /missing 404\n"} {
+		if got, _ := os.ReadFile(file); string(got) != expected {
+			t.Errorf("%s: expected %q got %q", filepath.Base(file), expected, got)
+		}
+	}
+}
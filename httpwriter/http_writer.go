@@ -0,0 +1,465 @@
+// Package httpwriter provides a timber LogWriter that POSTs records to an
+// HTTP endpoint, e.g. a webhook, a vendor intake like Datadog's or an
+// internal collector.  It's a separate package so applications that don't
+// ship logs over HTTP don't carry it.  Importing it registers the http
+// filter type with the config loaders:
+//   import _ "github.com/smw1218/timber/httpwriter"
+package httpwriter
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/smw1218/timber"
+)
+
+// Defaults for the HTTPWriter
+const (
+	DefaultQueueSize  = 1000
+	DefaultRetries    = 3
+	DefaultRetryMin   = 500 * time.Millisecond
+	DefaultRetryMax   = 30 * time.Second
+	DefaultTimeout    = 10 * time.Second
+	maxBatchMessages  = 500
+	maxBatchBytes     = 1000000
+	maxErrorBodyBytes = 512
+)
+
+var defaultBatch = timber.BatchConfig{Count: maxBatchMessages, Bytes: maxBatchBytes, Interval: time.Second}
+
+// How the messages of a batch are laid out in the request body
+type BodyFormat int
+
+const (
+	// One message per line, application/x-ndjson.  Works with any
+	// formatter; with the json formatter it's NDJSON proper.
+	BodyNDJSON BodyFormat = iota
+	// A JSON array, application/json.  Messages that aren't JSON already
+	// (use the json formatter) go in as strings.
+	BodyJSONArray
+)
+
+// Returns the BodyFormat for ndjson or json
+func ParseBodyFormat(name string) (BodyFormat, error) {
+	switch strings.ToLower(strings.TrimSpace(name)) {
+	case "", "ndjson":
+		return BodyNDJSON, nil
+	case "json", "array":
+		return BodyJSONArray, nil
+	}
+	return BodyNDJSON, fmt.Errorf("TIMBER! Unknown body %q for http log writer, expected ndjson or json", name)
+}
+
+// POSTs messages to a URL in batches of up to 500 messages or 1MB at least
+// once a second (see SetBatch; a batch_count of 1 posts every record on
+// its own).  Messages are queued in a bounded buffer and sent from a
+// goroutine; when the buffer is full new messages are dropped and counted
+// (see Dropped).  A request that fails to connect or gets a 429 or 5xx is
+// retried with exponential backoff (see SetRetry), the queue keeps filling
+// meanwhile.  Other responses outside 2xx drop the batch.  Close sends
+// whatever is still queued, LogWrite and Flush after that do nothing.
+// Errors are sent to timber.WriteErrorHandler.
+type HTTPWriter struct {
+	url       string
+	client    *http.Client
+	header    http.Header
+	body      BodyFormat
+	gzip      bool
+	retries   int
+	retryMin  time.Duration
+	retryMax  time.Duration
+	queue     chan []byte
+	dropped   uint64 // accessed atomically
+	batch     [][]byte
+	batchSize int // bytes in batch
+	limits    timber.BatchConfig
+	sleep     func(time.Duration) // swapped out by the tests
+	fc        chan chan int
+	done      chan int
+	autoFlush *time.Ticker
+
+	// set by Close under the write lock, LogWrite and Flush hold the read
+	// lock to send
+	closeMu sync.RWMutex
+	closed  bool
+}
+
+// Nothing is sent until the first batch, a bad URL shows up as write
+// errors then.  A queueSize of zero or less is DefaultQueueSize.
+func NewHTTPWriter(url string, queueSize int) *HTTPWriter {
+	if queueSize <= 0 {
+		queueSize = DefaultQueueSize
+	}
+	hw := &HTTPWriter{
+		url:      url,
+		client:   &http.Client{Timeout: DefaultTimeout},
+		header:   make(http.Header),
+		retries:  DefaultRetries,
+		retryMin: DefaultRetryMin,
+		retryMax: DefaultRetryMax,
+		queue:    make(chan []byte, queueSize),
+		limits:   defaultBatch,
+		sleep:    time.Sleep,
+		fc:       make(chan chan int),
+		done:     make(chan int),
+	}
+	hw.autoFlush = time.NewTicker(hw.limits.Interval)
+	go hw.writeLoop()
+	return hw
+}
+
+func init() {
+	timber.RegisterWriterFactory("http", NewFromFilter)
+}
+
+// The writer factory for filters of type http.  Reads the url, queue (the
+// buffer size), body (ndjson or json), gzip, timeout, retries, retry_min
+// and retry_max properties and any number of header properties like
+// "Authorization: Bearer xyz", plus batch_count, batch_bytes and
+// flush_interval for SetBatch.
+func NewFromFilter(filter timber.JSONFilter) (timber.LogWriter, error) {
+	var url, queue, body, gz, timeout, retries, retryMin, retryMax string
+	var headers []string
+	for _, prop := range filter.Properties {
+		switch prop.Name {
+		case "url":
+			url = prop.Value
+		case "queue":
+			queue = prop.Value
+		case "body":
+			body = prop.Value
+		case "gzip":
+			gz = prop.Value
+		case "timeout":
+			timeout = prop.Value
+		case "retries":
+			retries = prop.Value
+		case "retry_min":
+			retryMin = prop.Value
+		case "retry_max":
+			retryMax = prop.Value
+		case "header":
+			headers = append(headers, prop.Value)
+		}
+	}
+	if url == "" {
+		return nil, fmt.Errorf("TIMBER! Missing url for http log writer")
+	}
+	queueSize := 0
+	if queue != "" {
+		var err error
+		if queueSize, err = strconv.Atoi(queue); err != nil {
+			return nil, fmt.Errorf("TIMBER! Bad queue for http log writer: %v", err)
+		}
+	}
+	bodyFormat, err := ParseBodyFormat(body)
+	if err != nil {
+		return nil, err
+	}
+	compress := false
+	if gz != "" {
+		if compress, err = strconv.ParseBool(gz); err != nil {
+			return nil, fmt.Errorf("TIMBER! Bad gzip for http log writer: %v", err)
+		}
+	}
+	clientTimeout, err := parseDuration("timeout", timeout, DefaultTimeout)
+	if err != nil {
+		return nil, err
+	}
+	maxRetries := DefaultRetries
+	if retries != "" {
+		if maxRetries, err = strconv.Atoi(retries); err != nil {
+			return nil, fmt.Errorf("TIMBER! Bad retries for http log writer: %v", err)
+		}
+	}
+	minBackoff, err := parseDuration("retry_min", retryMin, DefaultRetryMin)
+	if err != nil {
+		return nil, err
+	}
+	maxBackoff, err := parseDuration("retry_max", retryMax, DefaultRetryMax)
+	if err != nil {
+		return nil, err
+	}
+	header := make(http.Header)
+	for _, h := range headers {
+		name, value, ok := strings.Cut(h, ":")
+		if !ok || strings.TrimSpace(name) == "" {
+			return nil, fmt.Errorf("TIMBER! Bad header %q for http log writer, expected Name: value", h)
+		}
+		header.Add(strings.TrimSpace(name), strings.TrimSpace(value))
+	}
+	bc, err := filter.BatchConfig(defaultBatch)
+	if err != nil {
+		return nil, err
+	}
+	hw := NewHTTPWriter(url, queueSize)
+	hw.header = header
+	hw.SetBody(bodyFormat)
+	hw.SetGzip(compress)
+	hw.SetRetry(maxRetries, minBackoff, maxBackoff)
+	hw.client.Timeout = clientTimeout
+	hw.SetBatch(bc)
+	return hw, nil
+}
+
+func parseDuration(name, value string, def time.Duration) (time.Duration, error) {
+	if value == "" {
+		return def, nil
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		return 0, fmt.Errorf("TIMBER! Bad %s for http log writer: %v", name, err)
+	}
+	return d, nil
+}
+
+// Changes when batches are sent; zero or negative values keep the
+// defaults.  Call it before the writer is used.
+func (hw *HTTPWriter) SetBatch(bc timber.BatchConfig) {
+	if bc.Count <= 0 {
+		bc.Count = maxBatchMessages
+	}
+	if bc.Bytes <= 0 {
+		bc.Bytes = maxBatchBytes
+	}
+	if bc.Interval <= 0 {
+		bc.Interval = time.Second
+	}
+	hw.limits = bc
+	hw.autoFlush.Reset(bc.Interval)
+}
+
+// Adds a header to every request, e.g. an API key.  Call it before the
+// writer is used.
+func (hw *HTTPWriter) SetHeader(name, value string) {
+	hw.header.Add(name, value)
+}
+
+// Call it before the writer is used
+func (hw *HTTPWriter) SetBody(body BodyFormat) {
+	hw.body = body
+}
+
+// Gzips the request bodies, with Content-Encoding: gzip.  Call it before
+// the writer is used.
+func (hw *HTTPWriter) SetGzip(compress bool) {
+	hw.gzip = compress
+}
+
+// Sets how many times a failed batch is sent again, waiting min before
+// the first retry and doubling up to max.  Call it before the writer is
+// used.
+func (hw *HTTPWriter) SetRetry(retries int, min, max time.Duration) {
+	if min <= 0 {
+		min = DefaultRetryMin
+	}
+	if max < min {
+		max = min
+	}
+	hw.retries, hw.retryMin, hw.retryMax = retries, min, max
+}
+
+// Replaces the http.Client, e.g. for TLS settings or a proxy.  Call it
+// before the writer is used.
+func (hw *HTTPWriter) SetClient(client *http.Client) {
+	hw.client = client
+}
+
+func (hw *HTTPWriter) LogWrite(msg string) {
+	hw.closeMu.RLock()
+	defer hw.closeMu.RUnlock()
+	if hw.closed {
+		return
+	}
+	select {
+	case hw.queue <- []byte(msg):
+	default:
+		atomic.AddUint64(&hw.dropped, 1)
+	}
+}
+
+// How many messages have been dropped because the queue was full
+func (hw *HTTPWriter) Dropped() uint64 {
+	return atomic.LoadUint64(&hw.dropped)
+}
+
+// Sends everything queued so far, blocks until it's done
+func (hw *HTTPWriter) Flush() {
+	done := make(chan int)
+	hw.closeMu.RLock()
+	if hw.closed {
+		hw.closeMu.RUnlock()
+		return
+	}
+	hw.fc <- done
+	hw.closeMu.RUnlock()
+	<-done
+}
+
+// Sends whatever is still queued, retries included, then returns
+func (hw *HTTPWriter) Close() {
+	hw.closeMu.Lock()
+	if !hw.closed {
+		hw.closed = true
+		close(hw.queue)
+	}
+	hw.closeMu.Unlock()
+	<-hw.done
+}
+
+func (hw *HTTPWriter) writeLoop() {
+	defer close(hw.done)
+	for {
+		select {
+		case msg, ok := <-hw.queue:
+			if !ok {
+				hw.autoFlush.Stop()
+				hw.flush()
+				return
+			}
+			hw.add(msg)
+		case done := <-hw.fc:
+			hw.drain()
+			hw.flush()
+			done <- 1
+		case <-hw.autoFlush.C:
+			hw.flush()
+		}
+	}
+}
+
+// sends the batch first if msg would take it over the limits
+func (hw *HTTPWriter) add(msg []byte) {
+	if len(hw.batch) > 0 && !hw.limits.Fits(len(hw.batch)+1, hw.batchSize+len(msg)) {
+		hw.flush()
+	}
+	hw.batch = append(hw.batch, msg)
+	hw.batchSize += len(msg)
+	if len(hw.batch) >= hw.limits.Count {
+		hw.flush()
+	}
+}
+
+// moves whatever is queued into the batch without blocking
+func (hw *HTTPWriter) drain() {
+	for {
+		select {
+		case msg, ok := <-hw.queue:
+			if !ok {
+				return
+			}
+			hw.add(msg)
+		default:
+			return
+		}
+	}
+}
+
+func (hw *HTTPWriter) flush() {
+	if len(hw.batch) == 0 {
+		return
+	}
+	body, err := hw.encode(hw.batch)
+	if err == nil {
+		err = hw.post(body)
+	}
+	if err != nil {
+		if handler := timber.WriteErrorHandler; handler != nil {
+			handler(hw, err)
+		}
+	}
+	hw.batch = hw.batch[:0]
+	hw.batchSize = 0
+}
+
+func (hw *HTTPWriter) encode(msgs [][]byte) ([]byte, error) {
+	var buf bytes.Buffer
+	var w io.Writer = &buf
+	var gz *gzip.Writer
+	if hw.gzip {
+		gz = gzip.NewWriter(&buf)
+		w = gz
+	}
+	if hw.body == BodyJSONArray {
+		io.WriteString(w, "[")
+		for i, msg := range msgs {
+			if i > 0 {
+				io.WriteString(w, ",")
+			}
+			if !json.Valid(msg) {
+				msg, _ = json.Marshal(string(msg))
+			}
+			w.Write(msg)
+		}
+		io.WriteString(w, "]")
+	} else {
+		for _, msg := range msgs {
+			w.Write(msg)
+			io.WriteString(w, "\n")
+		}
+	}
+	if gz != nil {
+		if err := gz.Close(); err != nil {
+			return nil, err
+		}
+	}
+	return buf.Bytes(), nil
+}
+
+// Sends body, retrying the failures that might go away
+func (hw *HTTPWriter) post(body []byte) error {
+	delay := hw.retryMin
+	for attempt := 0; ; attempt++ {
+		retry, err := hw.send(body)
+		if err == nil || !retry || attempt >= hw.retries {
+			return err
+		}
+		hw.sleep(delay)
+		if delay *= 2; delay > hw.retryMax {
+			delay = hw.retryMax
+		}
+	}
+}
+
+// One request, retry is true when the error is worth trying again
+func (hw *HTTPWriter) send(body []byte) (bool, error) {
+	req, err := http.NewRequest("POST", hw.url, bytes.NewReader(body))
+	if err != nil {
+		return false, fmt.Errorf("TIMBER! Bad http log writer request: %v", err)
+	}
+	for name, values := range hw.header {
+		req.Header[name] = values
+	}
+	if req.Header.Get("Content-Type") == "" {
+		if hw.body == BodyJSONArray {
+			req.Header.Set("Content-Type", "application/json")
+		} else {
+			req.Header.Set("Content-Type", "application/x-ndjson")
+		}
+	}
+	if hw.gzip {
+		req.Header.Set("Content-Encoding", "gzip")
+	}
+	resp, err := hw.client.Do(req)
+	if err != nil {
+		return true, fmt.Errorf("TIMBER! http log writer POST failed: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		io.Copy(io.Discard, resp.Body)
+		return false, nil
+	}
+	msg, _ := io.ReadAll(io.LimitReader(resp.Body, maxErrorBodyBytes))
+	retry := resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500
+	return retry, fmt.Errorf("TIMBER! http log writer POST to %s failed: %s %s", hw.url, resp.Status,
+		strings.TrimSpace(string(msg)))
+}
@@ -0,0 +1,158 @@
+package httpwriter
+
+import (
+	"compress/gzip"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/smw1218/timber"
+)
+
+// Records the bodies it gets, answering with the statuses in order then 200
+type collector struct {
+	mu       sync.Mutex
+	statuses []int
+	bodies   []string
+	headers  []http.Header
+}
+
+func (c *collector) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var body io.Reader = r.Body
+	if r.Header.Get("Content-Encoding") == "gzip" {
+		gz, err := gzip.NewReader(r.Body)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		body = gz
+	}
+	b, _ := io.ReadAll(body)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.bodies = append(c.bodies, string(b))
+	c.headers = append(c.headers, r.Header)
+	if len(c.statuses) > 0 {
+		status := c.statuses[0]
+		c.statuses = c.statuses[1:]
+		http.Error(w, "try later", status)
+	}
+}
+
+func (c *collector) got() ([]string, []http.Header) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]string(nil), c.bodies...), c.headers
+}
+
+func TestHTTPWriterNDJSON(t *testing.T) {
+	c := &collector{}
+	ts := httptest.NewServer(c)
+	defer ts.Close()
+	writer, err := NewFromFilter(timber.JSONFilter{Properties: []timber.JSONProperty{
+		{Name: "url", Value: ts.URL}, {Name: "gzip", Value: "true"},
+		{Name: "header", Value: "Authorization: Bearer xyz"}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	writer.LogWrite(`{"msg":"one"}`)
+	writer.LogWrite(`{"msg":"two"}`)
+	writer.Close()
+	bodies, headers := c.got()
+	if expected := []string{"{\"msg\":\"one\"}\n{\"msg\":\"two\"}\n"}; !reflect.DeepEqual(bodies, expected) {
+		t.Errorf("expected %q got %q", expected, bodies)
+	}
+	h := headers[0]
+	if h.Get("Authorization") != "Bearer xyz" || h.Get("Content-Type") != "application/x-ndjson" {
+		t.Errorf("unexpected headers %v", h)
+	}
+}
+
+func TestHTTPWriterJSONArray(t *testing.T) {
+	c := &collector{}
+	ts := httptest.NewServer(c)
+	defer ts.Close()
+	hw := NewHTTPWriter(ts.URL, 0)
+	hw.SetBody(BodyJSONArray)
+	hw.SetBatch(timber.BatchConfig{Count: 2})
+	hw.LogWrite(`{"msg":"one"}`)
+	hw.LogWrite("plain text")
+	hw.LogWrite(`{"msg":"three"}`)
+	hw.Close()
+	expected := []string{`[{"msg":"one"},"plain text"]`, `[{"msg":"three"}]`}
+	if bodies, _ := c.got(); !reflect.DeepEqual(bodies, expected) {
+		t.Errorf("expected %q got %q", expected, bodies)
+	}
+}
+
+func TestHTTPWriterRetry(t *testing.T) {
+	c := &collector{statuses: []int{http.StatusServiceUnavailable, http.StatusTooManyRequests}}
+	ts := httptest.NewServer(c)
+	defer ts.Close()
+	var errs []error
+	defer func(handler func(timber.LogWriter, error)) { timber.WriteErrorHandler = handler }(timber.WriteErrorHandler)
+	timber.WriteErrorHandler = func(_ timber.LogWriter, err error) { errs = append(errs, err) }
+
+	hw := NewHTTPWriter(ts.URL, 0)
+	var delays []time.Duration
+	hw.sleep = func(d time.Duration) { delays = append(delays, d) }
+	hw.SetRetry(3, time.Second, 10*time.Second)
+	hw.LogWrite("retried")
+	hw.Flush()
+	if bodies, _ := c.got(); len(bodies) != 3 || len(errs) != 0 {
+		t.Errorf("expected 2 retries then success got %q, %v", bodies, errs)
+	}
+	if expected := []time.Duration{time.Second, 2 * time.Second}; !reflect.DeepEqual(delays, expected) {
+		t.Errorf("expected backoff %v got %v", expected, delays)
+	}
+
+	// a client error isn't retried
+	c.mu.Lock()
+	c.statuses = []int{http.StatusBadRequest}
+	c.mu.Unlock()
+	hw.LogWrite("rejected")
+	hw.Close()
+	if bodies, _ := c.got(); len(bodies) != 4 || len(errs) != 1 {
+		t.Errorf("expected a single failed attempt got %q, %v", bodies, errs)
+	}
+}
+
+func TestHTTPWriterAfterClose(t *testing.T) {
+	c := &collector{}
+	ts := httptest.NewServer(c)
+	defer ts.Close()
+	hw := NewHTTPWriter(ts.URL, 0)
+	hw.LogWrite("one")
+	hw.Close()
+	done := make(chan bool)
+	go func() {
+		defer close(done)
+		hw.LogWrite("late")
+		hw.Flush()
+		hw.Close()
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("expected LogWrite, Flush and Close after Close to return")
+	}
+	if bodies, _ := c.got(); !reflect.DeepEqual(bodies, []string{"one\n"}) {
+		t.Errorf("expected only the batch from before Close got %q", bodies)
+	}
+}
+
+func TestHTTPWriterBadConfig(t *testing.T) {
+	for _, props := range [][]timber.JSONProperty{
+		{},
+		{{Name: "url", Value: "http://localhost"}, {Name: "body", Value: "xml"}},
+		{{Name: "url", Value: "http://localhost"}, {Name: "header", Value: "no colon"}},
+	} {
+		if _, err := NewFromFilter(timber.JSONFilter{Properties: props}); err == nil {
+			t.Errorf("expected an error for %v", props)
+		}
+	}
+}
@@ -0,0 +1,32 @@
+//go:build linux
+
+package timber
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"syscall"
+)
+
+// True for the errors of a datagram too big for the socket
+func journalEntryTooBig(err error) bool {
+	return errors.Is(err, syscall.EMSGSIZE) || errors.Is(err, syscall.ENOBUFS)
+}
+
+// Passes an entry too big for a datagram in an unlinked file in /dev/shm,
+// sending journald the descriptor
+func sendJournalFile(conn *net.UnixConn, addr *net.UnixAddr, entry []byte) error {
+	file, err := os.CreateTemp("/dev/shm", "timber-journal-")
+	if err != nil {
+		return fmt.Errorf("TIMBER! Can't pass a large entry to the journal: %v", err)
+	}
+	defer file.Close()
+	os.Remove(file.Name())
+	if _, err := file.Write(entry); err != nil {
+		return fmt.Errorf("TIMBER! Can't pass a large entry to the journal: %v", err)
+	}
+	_, _, err = conn.WriteMsgUnix(nil, syscall.UnixRights(int(file.Fd())), addr)
+	return err
+}
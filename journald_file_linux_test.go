@@ -0,0 +1,45 @@
+//go:build linux
+
+package timber
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestJournaldWriterLargeEntry(t *testing.T) {
+	conn, path := listenJournal(t)
+	w, err := NewJournaldWriter(path, "app")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+	big := strings.Repeat("x", 4<<20)
+	go w.LogWriteRecord(&LogRecord{Level: INFO}, big)
+	oob := make([]byte, syscall.CmsgSpace(4))
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	_, oobn, _, _, err := conn.ReadMsgUnix(nil, oob)
+	if err != nil {
+		t.Fatal(err)
+	}
+	msgs, err := syscall.ParseSocketControlMessage(oob[:oobn])
+	if err != nil || len(msgs) != 1 {
+		t.Fatalf("expected a descriptor, got %v %v", msgs, err)
+	}
+	fds, err := syscall.ParseUnixRights(&msgs[0])
+	if err != nil || len(fds) != 1 {
+		t.Fatalf("expected a descriptor, got %v %v", fds, err)
+	}
+	file := os.NewFile(uintptr(fds[0]), "entry")
+	defer file.Close()
+	file.Seek(0, io.SeekStart)
+	entry, _ := io.ReadAll(file)
+	if !bytes.HasPrefix(entry, []byte("MESSAGE="+big+"\nPRIORITY=6\n")) {
+		t.Errorf("unexpected entry of %d bytes", len(entry))
+	}
+}
@@ -0,0 +1,19 @@
+//go:build !linux
+
+package timber
+
+import (
+	"fmt"
+	"net"
+	"runtime"
+)
+
+// Without sendJournalFile a big entry fails like any other
+func journalEntryTooBig(err error) bool {
+	return false
+}
+
+// There's no journal to pass a file to outside of linux
+func sendJournalFile(conn *net.UnixConn, addr *net.UnixAddr, entry []byte) error {
+	return fmt.Errorf("TIMBER! Can't pass a large entry to the journal on %s", runtime.GOOS)
+}
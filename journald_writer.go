@@ -0,0 +1,173 @@
+package timber
+
+import (
+	"encoding/binary"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// The socket journald reads its native protocol from
+const JournaldSocket = "/run/systemd/journal/socket"
+
+// Sends each record to the systemd journal over its native protocol, so
+// the metadata that's lost going through stdout is kept: PRIORITY from
+// SyslogSeverity, SYSLOG_IDENTIFIER, CODE_FILE, CODE_LINE and CODE_FUNC
+// from the record's source, TIMBER_LEVEL and TIMBER_LOGGER, and each of
+// the record's fields with its name in upper case, e.g. TRACE_ID.  The
+// formatter only makes MESSAGE, leave the time and level out of the
+// pattern since the journal has them.  A record too big for a datagram is
+// passed to journald in a file the way sd_journal_send does.
+type JournaldWriter struct {
+	path       string
+	identifier string
+	mu         sync.Mutex
+	addr       *net.UnixAddr
+	conn       *net.UnixConn // unconnected so it can pass a file, nil after Close
+	buf        []byte
+}
+
+// An empty path is JournaldSocket and the identifier defaults to the
+// program name
+func NewJournaldWriter(path, identifier string) (*JournaldWriter, error) {
+	if path == "" {
+		path = JournaldSocket
+	}
+	if identifier == "" {
+		identifier = filepath.Base(os.Args[0])
+	}
+	if _, err := os.Stat(path); err != nil {
+		return nil, fmt.Errorf("TIMBER! Can't connect to the journal at %s: %v", path, err)
+	}
+	conn, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Net: "unixgram"})
+	if err != nil {
+		return nil, fmt.Errorf("TIMBER! Can't connect to the journal at %s: %v", path, err)
+	}
+	return &JournaldWriter{path: path, identifier: identifier, conn: conn,
+		addr: &net.UnixAddr{Name: path, Net: "unixgram"}}, nil
+}
+
+// Without a record the message goes out as informational
+func (jw *JournaldWriter) LogWrite(msg string) {
+	jw.LogWriteRecord(&LogRecord{Level: INFO, Message: msg, raw: true}, msg)
+}
+
+func (jw *JournaldWriter) LogWriteRecord(rec *LogRecord, msg string) {
+	jw.mu.Lock()
+	defer jw.mu.Unlock()
+	if jw.conn == nil {
+		return
+	}
+	jw.buf = appendJournalEntry(jw.buf[:0], rec, msg, jw.identifier)
+	if err := jw.send(jw.buf); err != nil {
+		reportWriteError(jw, err)
+	}
+}
+
+// must hold mu
+func (jw *JournaldWriter) send(entry []byte) error {
+	_, err := jw.conn.WriteToUnix(entry, jw.addr)
+	if journalEntryTooBig(err) {
+		return sendJournalFile(jw.conn, jw.addr, entry)
+	}
+	return err
+}
+
+// One entry of the native protocol: KEY=value lines, and for values with
+// a newline the key, a newline, the 64 bit little endian length and the
+// value
+func appendJournalEntry(buf []byte, rec *LogRecord, msg, identifier string) []byte {
+	buf = appendJournalField(buf, "MESSAGE", strings.TrimRight(msg, "\n"))
+	buf = appendJournalField(buf, "PRIORITY", strconv.Itoa(SyslogSeverity(rec.Level)))
+	buf = appendJournalField(buf, "SYSLOG_IDENTIFIER", identifier)
+	if rec.raw {
+		return buf
+	}
+	buf = appendJournalField(buf, "TIMBER_LEVEL", LongLevelStrings[rec.Level])
+	if rec.SourceFile != "" {
+		buf = appendJournalField(buf, "CODE_FILE", rec.SourceFile)
+		buf = appendJournalField(buf, "CODE_LINE", strconv.Itoa(rec.SourceLine))
+	}
+	if rec.FuncPath != "" && rec.FuncPath != "_" {
+		buf = appendJournalField(buf, "CODE_FUNC", rec.FuncPath)
+	}
+	if rec.LoggerName != "" {
+		buf = appendJournalField(buf, "TIMBER_LOGGER", rec.LoggerName)
+	}
+	keys := make([]string, 0, len(rec.Fields))
+	for key := range rec.Fields {
+		if key != FormatField {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		name := journalFieldName(key)
+		if name == "" || journalOwnFields[name] || rec.Fields[key] == nil {
+			continue
+		}
+		val, ok := rec.Fields[key].(string)
+		if !ok {
+			val = fmt.Sprint(rec.Fields[key])
+		}
+		buf = appendJournalField(buf, name, val)
+	}
+	return buf
+}
+
+// The fields the writer sets itself, a record field doesn't add a second
+// value
+var journalOwnFields = map[string]bool{"MESSAGE": true, "PRIORITY": true, "SYSLOG_IDENTIFIER": true,
+	"CODE_FILE": true, "CODE_LINE": true, "CODE_FUNC": true, "TIMBER_LEVEL": true, "TIMBER_LOGGER": true}
+
+func appendJournalField(buf []byte, name, val string) []byte {
+	buf = append(buf, name...)
+	if strings.IndexByte(val, '\n') < 0 {
+		buf = append(append(buf, '='), val...)
+		return append(buf, '\n')
+	}
+	buf = append(buf, '\n')
+	buf = binary.LittleEndian.AppendUint64(buf, uint64(len(val)))
+	buf = append(buf, val...)
+	return append(buf, '\n')
+}
+
+// A field name journald takes: upper case letters, digits and
+// underscores, not starting with an underscore (those are journald's
+// own) or a digit, at most 64 long.  Empty if nothing is left.
+func journalFieldName(key string) string {
+	name := make([]byte, 0, len(key))
+	for i := 0; i < len(key) && len(name) < 64; i++ {
+		c := key[i]
+		switch {
+		case c >= 'a' && c <= 'z':
+			c -= 'a' - 'A'
+		case c >= 'A' && c <= 'Z', c == '_':
+		case c >= '0' && c <= '9':
+			if len(name) == 0 {
+				continue
+			}
+		default:
+			c = '_'
+		}
+		if c == '_' && len(name) == 0 {
+			continue
+		}
+		name = append(name, c)
+	}
+	return string(name)
+}
+
+func (jw *JournaldWriter) Close() {
+	jw.mu.Lock()
+	defer jw.mu.Unlock()
+	if jw.conn != nil {
+		jw.conn.Close()
+		jw.conn = nil
+	}
+}
@@ -0,0 +1,56 @@
+package timber
+
+import (
+	"bytes"
+	"encoding/binary"
+	"net"
+	"testing"
+	"time"
+)
+
+func listenJournal(t *testing.T) (*net.UnixConn, string) {
+	path := t.TempDir() + "/journal"
+	conn, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: path, Net: "unixgram"})
+	if err != nil {
+		t.Skip("no unixgram sockets: ", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return conn, path
+}
+
+func TestJournaldWriter(t *testing.T) {
+	conn, path := listenJournal(t)
+	w, err := NewJournaldWriter(path, "app")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+	w.LogWriteRecord(&LogRecord{Level: ERROR, SourceFile: "/src/app/db.go", SourceLine: 42, FuncPath: "app/db.Query",
+		LoggerName: "app.db", Fields: Fields{TraceIDField: "4bf92f35", "9lives": 9, "_hidden": "x", "user-id": 7,
+			"message": "dup", FormatField: "json", "sql": "SELECT 1\nFROM t"}}, "query failed\n")
+	buf := make([]byte, 1024)
+	conn.SetReadDeadline(time.Now().Add(time.Second))
+	n, err := conn.Read(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var sql bytes.Buffer
+	sql.WriteString("SQL\n")
+	binary.Write(&sql, binary.LittleEndian, uint64(len("SELECT 1\nFROM t")))
+	sql.WriteString("SELECT 1\nFROM t\n")
+	expected := "MESSAGE=query failed\nPRIORITY=3\nSYSLOG_IDENTIFIER=app\nTIMBER_LEVEL=ERROR\n" +
+		"CODE_FILE=/src/app/db.go\nCODE_LINE=42\nCODE_FUNC=app/db.Query\nTIMBER_LOGGER=app.db\n" +
+		"LIVES=9\nHIDDEN=x\n" + sql.String() + "TRACE_ID=4bf92f35\nUSER_ID=7\n"
+	if got := string(buf[:n]); got != expected {
+		t.Errorf("expected %q got %q", expected, got)
+	}
+
+	w.LogWrite("raw")
+	conn.SetReadDeadline(time.Now().Add(time.Second))
+	if n, err = conn.Read(buf); err != nil || string(buf[:n]) != "MESSAGE=raw\nPRIORITY=6\nSYSLOG_IDENTIFIER=app\n" {
+		t.Errorf("unexpected raw entry %q %v", buf[:n], err)
+	}
+	if got := describeWriter(w); got != "journal "+path {
+		t.Errorf("unexpected description %q", got)
+	}
+}
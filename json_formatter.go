@@ -0,0 +1,144 @@
+package timber
+
+import (
+	"encoding/json"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Formats each record as a single line JSON object (without the newline,
+// see LogWriter).  The standard keys
+// always come first in the same order: time, level, message and source
+// (when the source is known).  The source is an object so it can be
+// queried by part: {"file":"server.go","line":42,"func":"handle",
+// "package":"github.com/me/app"}, where func and package are left out if
+// the caller couldn't be found.  Fields follow,
+// sorted by key so the output is stable from record to record.  A field
+// that has the same name as a standard key is written as "fields.<key>".
+// Duration and time fields are written the same way every time, see
+// SetDurationFormat.  In the config files <format name="json"/> (or
+// "format": {"name": "json"}) picks it.
+type JSONFormatter struct {
+	durationFormat DurationFormat
+	indent         string // multi-line output when set, see NewPrettyJSONFormatter
+}
+
+func NewJSONFormatter() *JSONFormatter {
+	return new(JSONFormatter)
+}
+
+// Same keys and values as NewJSONFormatter but spread over several lines
+// and indented for reading during development.  Keep the compact one for
+// anything that parses the logs line by line, e.g. NDJSON files.
+func NewPrettyJSONFormatter() *JSONFormatter {
+	return &JSONFormatter{indent: "  "}
+}
+
+var jsonStandardKeys = map[string]bool{"time": true, "level": true, "message": true, "source": true}
+
+// Sets how time.Duration fields are written, as a string (the default)
+// or a number of milliseconds or seconds for metrics pipelines.  Call it
+// before the formatter is used.
+func (jf *JSONFormatter) SetDurationFormat(df DurationFormat) {
+	jf.durationFormat = df
+}
+
+// LogFormatter interface
+func (jf *JSONFormatter) Format(rec *LogRecord) string {
+	pb := getPatBuffer()
+	jf.render(pb, rec)
+	msg := pb.buf.String()
+	putPatBuffer(pb)
+	return msg
+}
+
+// LogFormatterTo interface
+func (jf *JSONFormatter) FormatTo(w io.Writer, rec *LogRecord) (int, error) {
+	pb := getPatBuffer()
+	jf.render(pb, rec)
+	n, err := w.Write(pb.buf.Bytes())
+	putPatBuffer(pb)
+	return n, err
+}
+
+func (jf *JSONFormatter) render(pb *patBuffer, rec *LogRecord) {
+	if jf.indent == "" {
+		pb.buf.Write(jf.appendRecord(pb.buf.AvailableBuffer(), rec))
+		return
+	}
+	// Indent can't work in place, the compact copy gets its own buffer
+	json.Indent(&pb.buf, jf.appendRecord(nil, rec), "", jf.indent)
+}
+
+func (jf *JSONFormatter) appendRecord(buf []byte, rec *LogRecord) []byte {
+	buf = append(buf, `{"time":`...)
+	buf = strconv.AppendQuote(buf, truncateTime(rec.Timestamp).Format(time.RFC3339Nano))
+	buf = append(buf, `,"level":`...)
+	buf = strconv.AppendQuote(buf, LongLevelStrings[rec.Level])
+	buf = append(buf, `,"message":`...)
+	buf = appendJSONValue(buf, rec.Message)
+	if rec.SourceFile != "" {
+		buf = appendJSONSource(buf, rec)
+	}
+
+	keys := make([]string, 0, len(rec.Fields))
+	for k := range rec.Fields {
+		if k != FormatField {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		name := k
+		if jsonStandardKeys[k] {
+			name = "fields." + k
+		}
+		buf = append(buf, ',')
+		buf = appendJSONValue(buf, name)
+		buf = append(buf, ':')
+		buf = appendJSONValue(buf, jf.durationFormat.fieldValue(rec.Fields[k]))
+	}
+	return append(buf, '}')
+}
+
+func appendJSONSource(buf []byte, rec *LogRecord) []byte {
+	buf = append(buf, `,"source":{"file":`...)
+	buf = appendJSONValue(buf, rec.SourceFile[strings.LastIndex(rec.SourceFile, "/")+1:])
+	buf = append(buf, `,"line":`...)
+	buf = strconv.AppendInt(buf, int64(rec.SourceLine), 10)
+	if fn := funcName(rec.FuncPath); fn != "" {
+		buf = append(buf, `,"func":`...)
+		buf = appendJSONValue(buf, fn)
+	}
+	if rec.PackagePath != "" && rec.PackagePath != "_" {
+		buf = append(buf, `,"package":`...)
+		buf = appendJSONValue(buf, rec.PackagePath)
+	}
+	return append(buf, '}')
+}
+
+// The function part of a FuncPath, e.g. (*T).Method of pkg.(*T).Method;
+// empty when the caller wasn't found
+func funcName(funcPath string) string {
+	pkg := splitPackage(funcPath)
+	if len(funcPath) <= len(pkg)+1 {
+		return ""
+	}
+	return funcPath[len(pkg)+1:]
+}
+
+// json.Marshal but falls back to the quoted error for things that can't be
+// encoded so a bad field doesn't lose the whole record
+func appendJSONValue(buf []byte, val interface{}) []byte {
+	if err, ok := val.(error); ok {
+		val = err.Error()
+	}
+	encoded, err := json.Marshal(val)
+	if err != nil {
+		encoded, _ = json.Marshal("!JSON(" + err.Error() + ")")
+	}
+	return append(buf, encoded...)
+}
@@ -0,0 +1,61 @@
+package timber
+
+import (
+	"testing"
+	"time"
+)
+
+func TestJSONFormatter(t *testing.T) {
+	rec := *lr
+	rec.Fields = Fields{"zebra": 1, "apple": "a", "level": "mine", TraceIDField: "4bf92f35"}
+	expected := `{"time":"2011-10-20T15:39:07.383485-07:00","level":"INFO","message":"hellooooo nurse!",` +
+		`"source":{"file":"some_file.go","line":7,"func":"Zoot","package":"hi"},"apple":"a","fields.level":"mine","trace_id":"4bf92f35","zebra":1}`
+	jf := NewJSONFormatter()
+	for i := 0; i < 5; i++ { // map order changes between runs, the output shouldn't
+		verify(t, "json", jf.Format(&rec), expected)
+	}
+
+	rec.Fields = nil
+	rec.FuncPath = "_"
+	verify(t, "json no func", jf.Format(&rec), `{"time":"2011-10-20T15:39:07.383485-07:00","level":"INFO",`+
+		`"message":"hellooooo nurse!","source":{"file":"some_file.go","line":7,"package":"hi"}}`)
+}
+
+func TestPrettyJSONFormatter(t *testing.T) {
+	rec := *lr
+	rec.Fields = Fields{"apple": "a"}
+	expected := `{
+  "time": "2011-10-20T15:39:07.383485-07:00",
+  "level": "INFO",
+  "message": "hellooooo nurse!",
+  "source": {
+    "file": "some_file.go",
+    "line": 7,
+    "func": "Zoot",
+    "package": "hi"
+  },
+  "apple": "a"
+}`
+	verify(t, "pretty", NewPrettyJSONFormatter().Format(&rec), expected)
+}
+
+func TestJSONDurationFormat(t *testing.T) {
+	rec := *lr
+	rec.SourceFile = ""
+	rec.Fields = Fields{"took": 1500 * time.Millisecond, "at": time.Unix(0, 1319150347383485000)}
+	jf := NewJSONFormatter()
+	prefix := `{"time":"2011-10-20T15:39:07.383485-07:00","level":"INFO","message":"hellooooo nurse!",` +
+		`"at":"2011-10-20T15:39:07.383485-07:00","took":`
+	verify(t, "string", jf.Format(&rec), prefix+`"1.5s"}`)
+	jf.SetDurationFormat(DurationMillis)
+	verify(t, "ms", jf.Format(&rec), prefix+`1500}`)
+	jf.SetDurationFormat(DurationSeconds)
+	verify(t, "s", jf.Format(&rec), prefix+`1.5}`)
+
+	if df, err := ParseDurationFormat("MS"); err != nil || df != DurationMillis {
+		t.Errorf("expected ms got %v, %v", df, err)
+	}
+	if _, err := ParseDurationFormat("hours"); err == nil {
+		t.Error("expected an error for an unknown format")
+	}
+}
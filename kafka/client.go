@@ -0,0 +1,257 @@
+package kafka
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"time"
+)
+
+const clientID = "timber"
+
+// Bigger responses are taken for garbage rather than allocated; a producer
+// only reads metadata and produce acks
+const maxResponseSize = 64 << 20
+
+// A minimal producer client.  It's only used from the KafkaWriter's
+// goroutine so there's no locking.
+type client struct {
+	bootstrap   []string
+	addrs       map[int32]string      // broker node id -> host:port
+	conns       map[int32]*brokerConn // broker node id -> connection
+	leaders     map[string][]int32    // topic -> leader node id for each partition
+	acks        int16
+	compression int16
+	timeout     time.Duration
+	correlation int32
+}
+
+type brokerConn struct {
+	conn net.Conn
+	r    *bufio.Reader
+}
+
+func newClient(brokers []string) *client {
+	return &client{
+		bootstrap: brokers,
+		addrs:     make(map[int32]string),
+		conns:     make(map[int32]*brokerConn),
+		leaders:   make(map[string][]int32),
+		acks:      1,
+		timeout:   10 * time.Second,
+	}
+}
+
+func dialBroker(addr string, timeout time.Duration) (*brokerConn, error) {
+	conn, err := net.DialTimeout("tcp", addr, timeout)
+	if err != nil {
+		return nil, err
+	}
+	return &brokerConn{conn, bufio.NewReader(conn)}, nil
+}
+
+// Sends a request and reads the response body (without the correlation id)
+func (c *client) roundTrip(bc *brokerConn, apiKey, version int16, body []byte, expectResponse bool) ([]byte, error) {
+	c.correlation++
+	var req encoder
+	req = req.int32(0) // size, filled in below
+	req = req.int16(apiKey)
+	req = req.int16(version)
+	req = req.int32(c.correlation)
+	req = req.string(clientID)
+	req = append(req, body...)
+	size := len(req) - 4
+	req[0], req[1], req[2], req[3] = byte(size>>24), byte(size>>16), byte(size>>8), byte(size)
+
+	bc.conn.SetDeadline(time.Now().Add(c.timeout))
+	if _, err := bc.conn.Write(req); err != nil {
+		return nil, err
+	}
+	if !expectResponse {
+		return nil, nil
+	}
+	header := make([]byte, 8)
+	if _, err := io.ReadFull(bc.r, header); err != nil {
+		return nil, err
+	}
+	d := &decoder{buf: header}
+	respSize, correlation := d.int32(), d.int32()
+	if correlation != c.correlation {
+		return nil, fmt.Errorf("TIMBER! kafka correlation id mismatch %d != %d", correlation, c.correlation)
+	}
+	if respSize < 4 || respSize > maxResponseSize {
+		return nil, fmt.Errorf("TIMBER! Bad kafka response size %d", respSize)
+	}
+	resp := make([]byte, respSize-4)
+	if _, err := io.ReadFull(bc.r, resp); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+// Gets fresh partition leaders for topic from any of the known brokers
+func (c *client) refreshMetadata(topic string) error {
+	var body encoder
+	body = body.int32(1)
+	body = body.string(topic)
+	body = body.int8(1) // allow auto topic creation
+
+	var lastErr error
+	for _, addr := range c.candidates() {
+		bc, err := dialBroker(addr, c.timeout)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		resp, err := c.roundTrip(bc, apiMetadata, metadataVersion, body, true)
+		bc.conn.Close()
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		return c.parseMetadata(topic, resp)
+	}
+	return fmt.Errorf("TIMBER! Can't get kafka metadata: %v", lastErr)
+}
+
+// bootstrap brokers first then any others we've learned about
+func (c *client) candidates() []string {
+	addrs := append([]string(nil), c.bootstrap...)
+	for _, addr := range c.addrs {
+		addrs = append(addrs, addr)
+	}
+	return addrs
+}
+
+func (c *client) parseMetadata(topic string, resp []byte) error {
+	d := &decoder{buf: resp}
+	d.int32() // throttle time
+	for n := d.int32(); n > 0; n-- {
+		node := d.int32()
+		host := d.string()
+		port := d.int32()
+		d.string() // rack
+		c.addrs[node] = net.JoinHostPort(host, strconv.Itoa(int(port)))
+	}
+	d.string() // cluster id
+	d.int32()  // controller id
+	for n := d.int32(); n > 0; n-- {
+		errCode := d.int16()
+		name := d.string()
+		d.int8() // is internal
+		var leaders []int32
+		partitions := d.int32()
+		for p := partitions; p > 0 && d.err == nil; p-- {
+			d.int16() // partition error
+			index := d.int32()
+			leader := d.int32()
+			for r := d.int32(); r > 0; r-- {
+				d.int32() // replicas
+			}
+			for r := d.int32(); r > 0; r-- {
+				d.int32() // isr
+			}
+			if index < 0 || index >= partitions {
+				return fmt.Errorf("TIMBER! Bad kafka partition %d of %d for topic %s", index, partitions, name)
+			}
+			for int(index) >= len(leaders) {
+				leaders = append(leaders, -1)
+			}
+			leaders[index] = leader
+		}
+		if name != topic {
+			continue
+		}
+		if errCode != 0 {
+			return fmt.Errorf("TIMBER! kafka metadata error %d for topic %s", errCode, topic)
+		}
+		c.leaders[topic] = leaders
+	}
+	if d.err != nil {
+		return d.err
+	}
+	if len(c.leaders[topic]) == 0 {
+		return fmt.Errorf("TIMBER! No kafka partitions for topic %s", topic)
+	}
+	return nil
+}
+
+func (c *client) numPartitions(topic string) (int, error) {
+	if len(c.leaders[topic]) == 0 {
+		if err := c.refreshMetadata(topic); err != nil {
+			return 0, err
+		}
+	}
+	return len(c.leaders[topic]), nil
+}
+
+func (c *client) leaderConn(topic string, partition int) (*brokerConn, int32, error) {
+	node := c.leaders[topic][partition]
+	if bc, ok := c.conns[node]; ok {
+		return bc, node, nil
+	}
+	addr, ok := c.addrs[node]
+	if !ok {
+		return nil, node, fmt.Errorf("TIMBER! No kafka leader for %s/%d", topic, partition)
+	}
+	bc, err := dialBroker(addr, c.timeout)
+	if err != nil {
+		return nil, node, err
+	}
+	c.conns[node] = bc
+	return bc, node, nil
+}
+
+// Sends one partition's messages to its leader
+func (c *client) produce(topic string, partition int, msgs []message) error {
+	bc, node, err := c.leaderConn(topic, partition)
+	if err != nil {
+		return err
+	}
+	var body encoder
+	body = body.nullString() // transactional id
+	body = body.int16(c.acks)
+	body = body.int32(int32(c.timeout / time.Millisecond))
+	body = body.int32(1)
+	body = body.string(topic)
+	body = body.int32(1)
+	body = body.int32(int32(partition))
+	body = body.bytes(encodeRecordBatch(msgs, c.compression))
+
+	resp, err := c.roundTrip(bc, apiProduce, produceVersion, body, c.acks != 0)
+	if err != nil {
+		c.dropConn(node)
+		return err
+	}
+	if resp == nil {
+		return nil
+	}
+	d := &decoder{buf: resp}
+	for n := d.int32(); n > 0; n-- {
+		d.string()
+		for p := d.int32(); p > 0; p-- {
+			d.int32() // partition
+			if errCode := d.int16(); errCode != 0 && d.err == nil {
+				return fmt.Errorf("TIMBER! kafka produce error %d for %s/%d", errCode, topic, partition)
+			}
+			d.int64() // base offset
+			d.int64() // log append time
+		}
+	}
+	return d.err
+}
+
+func (c *client) dropConn(node int32) {
+	if bc, ok := c.conns[node]; ok {
+		bc.conn.Close()
+		delete(c.conns, node)
+	}
+}
+
+func (c *client) close() {
+	for node := range c.conns {
+		c.dropConn(node)
+	}
+}
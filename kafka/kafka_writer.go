@@ -0,0 +1,358 @@
+// Package kafka provides a timber LogWriter that produces messages to a
+// Kafka topic.  It's a separate package so applications that don't ship
+// logs to Kafka don't carry the client code.  Importing it registers the
+// kafka filter type with the config loaders:
+//   import _ "github.com/smw1218/timber/kafka"
+package kafka
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/smw1218/timber"
+)
+
+// Defaults for the KafkaWriter
+const (
+	DefaultBufferSize = 1000
+	maxBatchMessages  = 500
+	maxBatchBytes     = 1000000 // the broker's default message.max.bytes
+)
+
+var defaultBatch = timber.BatchConfig{Count: maxBatchMessages, Bytes: maxBatchBytes, Interval: time.Second}
+
+// Produces each message to a topic asynchronously.  Messages are queued in
+// a bounded buffer and sent in batches of up to 500 messages or 1MB at
+// least once a second (see SetBatch); when the buffer is full new messages
+// are dropped and counted (see Dropped).  Batches wait for the partition
+// leader's ack and aren't compressed, see SetAcks and SetCompression.
+// Close sends whatever is still queued, writes and Flush after that do
+// nothing.  Errors are sent to timber.WriteErrorHandler.
+//
+// If keyField is set, the record field with that name is used as the
+// message key so records with the same value land in the same partition,
+// otherwise messages are spread round robin.
+type KafkaWriter struct {
+	topic     string
+	keyField  string
+	client    *client
+	queue     chan message
+	dropped   uint64 // accessed atomically
+	batch     []message
+	batchSize int // bytes in batch
+	limits    timber.BatchConfig
+	next      int // round robin partition
+	fc        chan chan int
+	done      chan int
+	autoFlush *time.Ticker
+
+	// set by Close under the write lock, enqueue and Flush hold the read
+	// lock to send
+	closeMu sync.RWMutex
+	closed  bool
+}
+
+// Connects to the brokers to look up the topic before returning
+func NewKafkaWriter(brokers []string, topic, keyField string, bufferSize int) (*KafkaWriter, error) {
+	if bufferSize <= 0 {
+		bufferSize = DefaultBufferSize
+	}
+	kw := &KafkaWriter{
+		topic:    topic,
+		keyField: keyField,
+		client:   newClient(brokers),
+		queue:    make(chan message, bufferSize),
+		fc:       make(chan chan int),
+		done:     make(chan int),
+		limits:   defaultBatch,
+	}
+	if err := kw.client.refreshMetadata(topic); err != nil {
+		return nil, err
+	}
+	kw.autoFlush = time.NewTicker(kw.limits.Interval)
+	go kw.writeLoop()
+	return kw, nil
+}
+
+func init() {
+	timber.RegisterWriterFactory("kafka", NewFromFilter)
+}
+
+// The writer factory for filters of type kafka.  Reads the brokers (comma
+// separated), topic, key_field and buffer properties, acks (0, 1 or all)
+// and compression (none or gzip), plus batch_count, batch_bytes and
+// flush_interval for SetBatch.
+func NewFromFilter(filter timber.JSONFilter) (timber.LogWriter, error) {
+	var brokers, topic, keyField, buffer, acks, compression string
+	for _, prop := range filter.Properties {
+		switch prop.Name {
+		case "brokers":
+			brokers = prop.Value
+		case "topic":
+			topic = prop.Value
+		case "key_field":
+			keyField = prop.Value
+		case "buffer":
+			buffer = prop.Value
+		case "acks":
+			acks = prop.Value
+		case "compression":
+			compression = prop.Value
+		}
+	}
+	if brokers == "" || topic == "" {
+		return nil, fmt.Errorf("TIMBER! Missing brokers or topic for kafka log writer")
+	}
+	bufferSize := 0
+	if buffer != "" {
+		var err error
+		if bufferSize, err = strconv.Atoi(buffer); err != nil {
+			return nil, fmt.Errorf("TIMBER! Bad buffer for kafka log writer: %v", err)
+		}
+	}
+	bc, err := filter.BatchConfig(defaultBatch)
+	if err != nil {
+		return nil, err
+	}
+	ackLevel := 1
+	if acks != "" {
+		if ackLevel, err = ParseAcks(acks); err != nil {
+			return nil, err
+		}
+	}
+	codec, err := parseCompression(compression)
+	if err != nil {
+		return nil, err
+	}
+	kw, err := NewKafkaWriter(strings.Split(brokers, ","), topic, keyField, bufferSize)
+	if err != nil {
+		return nil, err
+	}
+	kw.SetBatch(bc)
+	kw.SetAcks(ackLevel)
+	kw.client.compression = codec
+	return kw, nil
+}
+
+// Returns the acks for 0, 1 or all (also -1)
+func ParseAcks(acks string) (int, error) {
+	switch strings.ToLower(strings.TrimSpace(acks)) {
+	case "0":
+		return 0, nil
+	case "1":
+		return 1, nil
+	case "all", "-1":
+		return -1, nil
+	}
+	return 0, fmt.Errorf("TIMBER! Bad acks %q for kafka log writer, expected 0, 1 or all", acks)
+}
+
+func parseCompression(name string) (int16, error) {
+	switch strings.ToLower(name) {
+	case "", "none":
+		return compressionNone, nil
+	case "gzip":
+		return compressionGzip, nil
+	}
+	return 0, fmt.Errorf("TIMBER! Unsupported compression %q for kafka log writer, expected none or gzip", name)
+}
+
+// Sets how many replicas have to have a batch before the broker answers:
+// 1 (the default) is the partition leader, -1 is all the in sync
+// replicas and 0 doesn't wait for an answer at all, so errors go
+// unnoticed.  Anything else is taken as all.  Call it before the writer
+// is used.
+func (kw *KafkaWriter) SetAcks(acks int) {
+	if acks < -1 || acks > 1 {
+		acks = -1
+	}
+	kw.client.acks = int16(acks)
+}
+
+// Compresses each batch, none or gzip; the other codecs Kafka knows
+// (snappy, lz4, zstd) aren't supported.  Call it before the writer is used.
+func (kw *KafkaWriter) SetCompression(name string) error {
+	codec, err := parseCompression(name)
+	if err != nil {
+		return err
+	}
+	kw.client.compression = codec
+	return nil
+}
+
+// Changes when batches are sent; zero or negative values keep the
+// defaults.  Call it before the writer is used.
+func (kw *KafkaWriter) SetBatch(bc timber.BatchConfig) {
+	if bc.Count <= 0 {
+		bc.Count = maxBatchMessages
+	}
+	if bc.Bytes <= 0 {
+		bc.Bytes = maxBatchBytes
+	}
+	if bc.Interval <= 0 {
+		bc.Interval = time.Second
+	}
+	kw.limits = bc
+	kw.autoFlush.Reset(bc.Interval)
+}
+
+func (kw *KafkaWriter) LogWrite(msg string) {
+	kw.enqueue(message{value: []byte(msg), time: time.Now()})
+}
+
+// RecordWriter interface, used to get the key field
+func (kw *KafkaWriter) LogWriteRecord(rec *timber.LogRecord, msg string) {
+	m := message{value: []byte(msg), time: rec.Timestamp}
+	if kw.keyField != "" {
+		if key, ok := rec.Fields[kw.keyField]; ok && key != nil {
+			m.key = []byte(fmt.Sprint(key))
+		}
+	}
+	kw.enqueue(m)
+}
+
+func (kw *KafkaWriter) enqueue(m message) {
+	kw.closeMu.RLock()
+	defer kw.closeMu.RUnlock()
+	if kw.closed {
+		return
+	}
+	select {
+	case kw.queue <- m:
+	default:
+		atomic.AddUint64(&kw.dropped, 1)
+	}
+}
+
+// How many messages have been dropped because the buffer was full
+func (kw *KafkaWriter) Dropped() uint64 {
+	return atomic.LoadUint64(&kw.dropped)
+}
+
+// Sends everything queued so far, blocks until it's done
+func (kw *KafkaWriter) Flush() {
+	done := make(chan int)
+	kw.closeMu.RLock()
+	if kw.closed {
+		kw.closeMu.RUnlock()
+		return
+	}
+	kw.fc <- done
+	kw.closeMu.RUnlock()
+	<-done
+}
+
+// Sends whatever is still queued then closes the broker connections
+func (kw *KafkaWriter) Close() {
+	kw.closeMu.Lock()
+	if !kw.closed {
+		kw.closed = true
+		close(kw.queue)
+	}
+	kw.closeMu.Unlock()
+	<-kw.done
+}
+
+func (kw *KafkaWriter) writeLoop() {
+	defer close(kw.done)
+	for {
+		select {
+		case m, ok := <-kw.queue:
+			if !ok {
+				kw.autoFlush.Stop()
+				kw.flush()
+				kw.client.close()
+				return
+			}
+			kw.add(m)
+		case done := <-kw.fc:
+			kw.drain()
+			kw.flush()
+			done <- 1
+		case <-kw.autoFlush.C:
+			kw.flush()
+		}
+	}
+}
+
+// sends the batch first if m would take it over the limits
+func (kw *KafkaWriter) add(m message) {
+	size := len(m.key) + len(m.value)
+	if len(kw.batch) > 0 && !kw.limits.Fits(len(kw.batch)+1, kw.batchSize+size) {
+		kw.flush()
+	}
+	kw.batch = append(kw.batch, m)
+	kw.batchSize += size
+}
+
+// moves whatever is queued into the batch without blocking
+func (kw *KafkaWriter) drain() {
+	for {
+		select {
+		case m, ok := <-kw.queue:
+			if !ok {
+				return
+			}
+			kw.add(m)
+		default:
+			return
+		}
+	}
+}
+
+func (kw *KafkaWriter) flush() {
+	if len(kw.batch) == 0 {
+		return
+	}
+	unsent, err := kw.send(kw.batch)
+	if err != nil {
+		// leaders may have moved, try again with fresh metadata
+		if err = kw.client.refreshMetadata(kw.topic); err == nil {
+			_, err = kw.send(unsent)
+		}
+	}
+	if err != nil {
+		if handler := timber.WriteErrorHandler; handler != nil {
+			handler(kw, err)
+		}
+	}
+	kw.batch = kw.batch[:0]
+	kw.batchSize = 0
+}
+
+// Produces msgs grouped by partition, returns the messages that weren't
+// sent if there's an error
+func (kw *KafkaWriter) send(msgs []message) ([]message, error) {
+	numPartitions, err := kw.client.numPartitions(kw.topic)
+	if err != nil {
+		return msgs, err
+	}
+	byPartition := make([][]message, numPartitions)
+	for _, m := range msgs {
+		var partition int
+		if m.key != nil {
+			partition = partitionFor(m.key, numPartitions)
+		} else {
+			partition = kw.next % numPartitions
+			kw.next++
+		}
+		byPartition[partition] = append(byPartition[partition], m)
+	}
+	for partition, pmsgs := range byPartition {
+		if len(pmsgs) == 0 {
+			continue
+		}
+		if err := kw.client.produce(kw.topic, partition, pmsgs); err != nil {
+			var unsent []message
+			for _, rest := range byPartition[partition:] {
+				unsent = append(unsent, rest...)
+			}
+			return unsent, err
+		}
+	}
+	return nil, nil
+}
@@ -0,0 +1,243 @@
+package kafka
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"hash/crc32"
+	"io"
+	"net"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/smw1218/timber"
+)
+
+// Values from the java client's murmur2 tests
+func TestMurmur2(t *testing.T) {
+	tests := map[string]int32{
+		"21":                         -973932308,
+		"foobar":                     -790332482,
+		"a-little-bit-long-string":   -985981536,
+		"a-little-bit-longer-string": -1486304829,
+		"lkjh234lh9fiuh90y23oiuhsafujhadof229phr9h19h89h8": -58897971,
+	}
+	for in, expected := range tests {
+		if got := murmur2([]byte(in)); got != expected {
+			t.Errorf("murmur2(%s) = %d expected %d", in, got, expected)
+		}
+	}
+}
+
+// Serves metadata for a single partition topic and sends the values of
+// every produced record on values
+func fakeBroker(t *testing.T, topic string, values chan string) net.Listener {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	host, portStr, _ := net.SplitHostPort(ln.Addr().String())
+	port, _ := strconv.Atoi(portStr)
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go serveBroker(conn, host, int32(port), topic, values)
+		}
+	}()
+	return ln
+}
+
+func serveBroker(conn net.Conn, host string, port int32, topic string, values chan string) {
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+	for {
+		sizeBuf := make([]byte, 4)
+		if _, err := io.ReadFull(r, sizeBuf); err != nil {
+			return
+		}
+		req := make([]byte, binary.BigEndian.Uint32(sizeBuf))
+		if _, err := io.ReadFull(r, req); err != nil {
+			return
+		}
+		d := &decoder{buf: req}
+		apiKey, _, correlation := d.int16(), d.int16(), d.int32()
+		d.string() // client id
+
+		var resp encoder
+		switch apiKey {
+		case apiMetadata:
+			resp = resp.int32(0) // throttle
+			resp = resp.int32(1).int32(1).string(host).int32(port).nullString()
+			resp = resp.nullString().int32(1) // cluster, controller
+			resp = resp.int32(1).int16(0).string(topic).int8(0)
+			resp = resp.int32(1).int16(0).int32(0).int32(1) // partition 0 leader 1
+			resp = resp.int32(1).int32(1).int32(1).int32(1) // replicas, isr
+		case apiProduce:
+			d.string() // transactional id
+			d.int16()  // acks
+			d.int32()  // timeout
+			d.int32()  // topics
+			d.string()
+			d.int32() // partitions
+			d.int32()
+			batch := d.next(int(d.int32()))
+			for _, v := range decodeBatch(batch) {
+				values <- v
+			}
+			resp = resp.int32(1).string(topic).int32(1).int32(0).int16(0).int64(0).int64(-1)
+			resp = resp.int32(0) // throttle
+		}
+		out := encoder(nil).int32(int32(len(resp) + 4)).int32(correlation)
+		conn.Write(append(out, resp...))
+	}
+}
+
+func decodeBatch(batch []byte) []string {
+	d := &decoder{buf: batch}
+	d.int64() // base offset
+	d.int32() // length
+	d.int32() // leader epoch
+	d.int8()  // magic
+	crc := uint32(d.int32())
+	if crc32.Checksum(d.buf, castagnoli) != crc {
+		return []string{"bad crc"}
+	}
+	codec := d.int16()
+	d.next(4 + 8 + 8 + 8 + 2 + 4)
+	count := d.int32()
+	if codec == compressionGzip {
+		gz, err := gzip.NewReader(bytes.NewReader(d.buf))
+		if err != nil {
+			return []string{err.Error()}
+		}
+		d.buf, _ = io.ReadAll(gz)
+	}
+	var values []string
+	for i := int32(0); i < count; i++ {
+		length, n := binary.Varint(d.buf)
+		rec := d.buf[n : n+int(length)]
+		d.buf = d.buf[n+int(length):]
+		rec = rec[1:]            // attributes
+		for j := 0; j < 2; j++ { // timestamp and offset deltas
+			_, n = binary.Varint(rec)
+			rec = rec[n:]
+		}
+		keyLen, n := binary.Varint(rec)
+		rec = rec[n:]
+		if keyLen > 0 {
+			rec = rec[keyLen:]
+		}
+		valueLen, n := binary.Varint(rec)
+		values = append(values, string(rec[n:n+int(valueLen)]))
+	}
+	return values
+}
+
+func TestKafkaCompressionAndAcks(t *testing.T) {
+	values := make(chan string, 10)
+	ln := fakeBroker(t, "logs", values)
+	defer ln.Close()
+	writer, err := NewFromFilter(filterWith(ln, "compression", "gzip", "acks", "all"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	kw := writer.(*KafkaWriter)
+	if kw.client.acks != -1 {
+		t.Errorf("expected acks -1 got %d", kw.client.acks)
+	}
+	kw.LogWrite("squeezed")
+	kw.LogWrite("twice")
+	kw.Close()
+	for _, expected := range []string{"squeezed", "twice"} {
+		if got := <-values; got != expected {
+			t.Errorf("expected %s got %s", expected, got)
+		}
+	}
+
+	for _, bad := range [][2]string{{"compression", "snappy"}, {"acks", "2"}} {
+		if _, err := NewFromFilter(filterWith(ln, bad[0], bad[1])); err == nil {
+			t.Errorf("expected an error for %v", bad)
+		}
+	}
+}
+
+// A kafka filter for the broker and the logs topic plus the name, value
+// pairs in props
+func filterWith(ln net.Listener, props ...string) timber.JSONFilter {
+	props = append([]string{"brokers", ln.Addr().String(), "topic", "logs"}, props...)
+	var filter timber.JSONFilter
+	for i := 0; i+1 < len(props); i += 2 {
+		filter.Properties = append(filter.Properties, timber.JSONProperty{Name: props[i], Value: props[i+1]})
+	}
+	return filter
+}
+
+func TestKafkaWriter(t *testing.T) {
+	values := make(chan string, 10)
+	ln := fakeBroker(t, "logs", values)
+	defer ln.Close()
+	kw, err := NewKafkaWriter([]string{ln.Addr().String()}, "logs", "", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	kw.LogWrite("one")
+	kw.LogWrite("two")
+	kw.Close()
+	for _, expected := range []string{"one", "two"} {
+		if got := <-values; got != expected {
+			t.Errorf("expected %s got %s", expected, got)
+		}
+	}
+
+	// after Close everything is a no-op
+	done := make(chan bool)
+	go func() {
+		defer close(done)
+		kw.LogWrite("late")
+		kw.LogWriteRecord(&timber.LogRecord{}, "late record")
+		kw.Flush()
+		kw.Close()
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("expected writes, Flush and Close after Close to return")
+	}
+	if len(values) != 0 || kw.Dropped() != 0 {
+		t.Errorf("expected nothing sent or dropped after Close, %d sent %d dropped", len(values), kw.Dropped())
+	}
+}
+
+func TestBadResponses(t *testing.T) {
+	c := newClient(nil)
+	for _, index := range []int32{-1, 5, 1 << 30} {
+		var resp encoder
+		resp = resp.int32(0).int32(0).nullString().int32(1) // no brokers
+		resp = resp.int32(1).int16(0).string("logs").int8(0)
+		resp = resp.int32(1).int16(0).int32(index).int32(1).int32(0).int32(0)
+		if err := c.parseMetadata("logs", resp); err == nil {
+			t.Errorf("expected an error for partition %d", index)
+		}
+	}
+
+	for _, size := range []int32{0, 3, -8, maxResponseSize + 1} {
+		client, server := net.Pipe()
+		go func() {
+			io.Copy(io.Discard, server)
+		}()
+		header := encoder(nil).int32(size).int32(c.correlation + 1)
+		go func() {
+			server.Write(header)
+		}()
+		if _, err := c.roundTrip(&brokerConn{client, bufio.NewReader(client)}, apiMetadata, metadataVersion, nil, true); err == nil {
+			t.Errorf("expected an error for a response of %d bytes", size)
+		}
+		client.Close()
+		server.Close()
+	}
+}
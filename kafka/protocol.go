@@ -0,0 +1,204 @@
+package kafka
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"time"
+)
+
+// Just the bits of the Kafka protocol needed to produce messages
+const (
+	apiProduce       = 0
+	apiMetadata      = 3
+	produceVersion   = 3
+	metadataVersion  = 4
+	recordBatchMagic = 2
+)
+
+// The compression codecs, the low bits of the batch attributes
+const (
+	compressionNone int16 = 0
+	compressionGzip int16 = 1
+)
+
+var castagnoli = crc32.MakeTable(crc32.Castagnoli)
+
+var errShortResponse = errors.New("TIMBER! Short response from kafka")
+
+// Appends kafka's big endian primitive types
+type encoder []byte
+
+func (e encoder) int8(v int8) encoder   { return append(e, byte(v)) }
+func (e encoder) int16(v int16) encoder { return binary.BigEndian.AppendUint16(e, uint16(v)) }
+func (e encoder) int32(v int32) encoder { return binary.BigEndian.AppendUint32(e, uint32(v)) }
+func (e encoder) int64(v int64) encoder { return binary.BigEndian.AppendUint64(e, uint64(v)) }
+func (e encoder) varint(v int64) encoder {
+	return binary.AppendVarint(e, v) // zigzag, same as kafka
+}
+
+func (e encoder) string(s string) encoder {
+	return append(e.int16(int16(len(s))), s...)
+}
+
+func (e encoder) nullString() encoder {
+	return e.int16(-1)
+}
+
+func (e encoder) bytes(b []byte) encoder {
+	return append(e.int32(int32(len(b))), b...)
+}
+
+// Reads kafka's big endian primitive types, once an error occurs all the
+// reads return zero values
+type decoder struct {
+	buf []byte
+	err error
+}
+
+func (d *decoder) next(n int) []byte {
+	if d.err != nil || len(d.buf) < n {
+		d.err = errShortResponse
+		return nil
+	}
+	b := d.buf[:n]
+	d.buf = d.buf[n:]
+	return b
+}
+
+func (d *decoder) int8() int8 {
+	if b := d.next(1); b != nil {
+		return int8(b[0])
+	}
+	return 0
+}
+
+func (d *decoder) int16() int16 {
+	if b := d.next(2); b != nil {
+		return int16(binary.BigEndian.Uint16(b))
+	}
+	return 0
+}
+
+func (d *decoder) int32() int32 {
+	if b := d.next(4); b != nil {
+		return int32(binary.BigEndian.Uint32(b))
+	}
+	return 0
+}
+
+func (d *decoder) int64() int64 {
+	if b := d.next(8); b != nil {
+		return int64(binary.BigEndian.Uint64(b))
+	}
+	return 0
+}
+
+func (d *decoder) string() string {
+	n := d.int16()
+	if n < 0 {
+		return ""
+	}
+	return string(d.next(int(n)))
+}
+
+type message struct {
+	key   []byte
+	value []byte
+	time  time.Time
+}
+
+// Encodes messages as a v2 RecordBatch, the records compressed with codec
+func encodeRecordBatch(msgs []message, codec int16) []byte {
+	base := msgs[0].time.UnixNano() / int64(time.Millisecond)
+	maxTs := base
+	var records encoder
+	for i, msg := range msgs {
+		ts := msg.time.UnixNano() / int64(time.Millisecond)
+		if ts > maxTs {
+			maxTs = ts
+		}
+		var rec encoder
+		rec = rec.int8(0) // attributes
+		rec = rec.varint(ts - base)
+		rec = rec.varint(int64(i))
+		if msg.key == nil {
+			rec = rec.varint(-1)
+		} else {
+			rec = append(rec.varint(int64(len(msg.key))), msg.key...)
+		}
+		rec = append(rec.varint(int64(len(msg.value))), msg.value...)
+		rec = rec.varint(0) // no headers
+		records = append(records.varint(int64(len(rec))), rec...)
+	}
+
+	if codec == compressionGzip {
+		var buf bytes.Buffer
+		gz := gzip.NewWriter(&buf)
+		gz.Write(records)
+		gz.Close()
+		records = buf.Bytes()
+	}
+
+	// everything after the crc is covered by it
+	var crcd encoder
+	crcd = crcd.int16(codec) // attributes: the codec, create time
+	crcd = crcd.int32(int32(len(msgs) - 1))
+	crcd = crcd.int64(base)
+	crcd = crcd.int64(maxTs)
+	crcd = crcd.int64(-1) // producer id
+	crcd = crcd.int16(-1) // producer epoch
+	crcd = crcd.int32(-1) // base sequence
+	crcd = crcd.int32(int32(len(msgs)))
+	crcd = append(crcd, records...)
+
+	var batch encoder
+	batch = batch.int64(0) // base offset
+	batch = batch.int32(int32(4 + 1 + 4 + len(crcd)))
+	batch = batch.int32(-1) // partition leader epoch
+	batch = batch.int8(recordBatchMagic)
+	batch = batch.int32(int32(crc32.Checksum(crcd, castagnoli)))
+	return append(batch, crcd...)
+}
+
+// The default partitioner in the java client, so keyed messages land in
+// the same partition no matter which client produced them
+func murmur2(data []byte) int32 {
+	const (
+		seed uint32 = 0x9747b28c
+		m    uint32 = 0x5bd1e995
+		r           = 24
+	)
+	length := len(data)
+	h := seed ^ uint32(length)
+	for i := 0; i+4 <= length; i += 4 {
+		k := binary.LittleEndian.Uint32(data[i:])
+		k *= m
+		k ^= k >> r
+		k *= m
+		h *= m
+		h ^= k
+	}
+	tail := data[length&^3:]
+	switch len(tail) {
+	case 3:
+		h ^= uint32(tail[2]) << 16
+		fallthrough
+	case 2:
+		h ^= uint32(tail[1]) << 8
+		fallthrough
+	case 1:
+		h ^= uint32(tail[0])
+		h *= m
+	}
+	h ^= h >> 13
+	h *= m
+	h ^= h >> 15
+	return int32(h)
+}
+
+func partitionFor(key []byte, numPartitions int) int {
+	return int(murmur2(key)&0x7fffffff) % numPartitions
+}
@@ -0,0 +1,47 @@
+package timber
+
+import (
+	"io"
+)
+
+// Picks the formatter by record level so e.g. DEBUG can be verbose while
+// INFO and up stay terse.  Levels without an entry use Default.
+type LevelFormatter struct {
+	Default LogFormatter
+	Levels  map[Level]LogFormatter
+}
+
+func NewLevelFormatter(defaultFormatter LogFormatter) *LevelFormatter {
+	return &LevelFormatter{Default: defaultFormatter, Levels: make(map[Level]LogFormatter)}
+}
+
+func (lf *LevelFormatter) formatterFor(lvl Level) LogFormatter {
+	if formatter, ok := lf.Levels[lvl]; ok {
+		return formatter
+	}
+	return lf.Default
+}
+
+// LogFormatter interface
+func (lf *LevelFormatter) Format(rec *LogRecord) string {
+	return lf.formatterFor(rec.Level).Format(rec)
+}
+
+// LogFormatterTo interface
+func (lf *LevelFormatter) FormatTo(w io.Writer, rec *LogRecord) (int, error) {
+	formatter := lf.formatterFor(rec.Level)
+	if fmtTo, ok := formatter.(LogFormatterTo); ok {
+		return fmtTo.FormatTo(w, rec)
+	}
+	return io.WriteString(w, formatter.Format(rec))
+}
+
+// SourceUser interface
+func (lf *LevelFormatter) UsesSource() bool {
+	for _, formatter := range lf.Levels {
+		if formatterUsesSource(formatter) {
+			return true
+		}
+	}
+	return formatterUsesSource(lf.Default)
+}
@@ -0,0 +1,103 @@
+package timber
+
+import (
+	"bytes"
+	"io"
+	"runtime"
+	"sync"
+)
+
+// The longest line a WriterAt writer holds on to waiting for its newline,
+// longer ones are logged in pieces this long
+const maxLineWriterLine = 64 * 1024
+
+// Returns an io.Writer that logs each line written to it through t at
+// lvl, for output that isn't logged with timber: a subprocess's
+// exec.Cmd.Stdout and Stderr, http.Server.ErrorLog with
+// log.New(w, "", 0), or grpclog.NewLoggerV2.  Records come from package
+// prefixPkg, so granulars for it set the level and format, e.g.
+// "exec/ffmpeg" or "net/http"; with an empty prefixPkg they come from
+// the caller of WriterAt.  Blank lines are dropped.  A line without its
+// newline waits for the next Write; the writer is also an io.Closer and
+// Close logs it.  It's safe to use from several goroutines.
+func (t *Timber) WriterAt(lvl Level, prefixPkg string) io.Writer {
+	return t.writerAt(lvl, prefixPkg)
+}
+
+// Same as WriterAt for the Global logger
+func WriterAt(lvl Level, prefixPkg string) io.Writer {
+	return global().writerAt(lvl, prefixPkg)
+}
+
+// Called straight from WriterAt, the caller's caller is the source
+func (t *Timber) writerAt(lvl Level, prefixPkg string) *lineWriter {
+	lw := &lineWriter{t: t, lvl: lvl, pkg: prefixPkg}
+	if prefixPkg == "" {
+		var pcs [1]uintptr
+		// skip Callers, this and WriterAt
+		if runtime.Callers(3, pcs[:]) == 1 {
+			lw.pc = pcs[0]
+		}
+	}
+	return lw
+}
+
+type lineWriter struct {
+	t   *Timber
+	lvl Level
+	pkg string
+	pc  uintptr // the source of the records when pkg is empty
+
+	mu      sync.Mutex
+	partial []byte
+}
+
+func (lw *lineWriter) Write(p []byte) (int, error) {
+	lw.mu.Lock()
+	defer lw.mu.Unlock()
+	n := len(p)
+	for len(p) > 0 {
+		i := bytes.IndexByte(p, '\n')
+		if i < 0 {
+			lw.partial = append(lw.partial, p...)
+			for len(lw.partial) >= maxLineWriterLine {
+				lw.logLine(lw.partial[:maxLineWriterLine])
+				lw.partial = append(lw.partial[:0], lw.partial[maxLineWriterLine:]...)
+			}
+			break
+		}
+		if len(lw.partial) > 0 {
+			lw.partial = append(lw.partial, p[:i]...)
+			lw.logLine(lw.partial)
+			lw.partial = lw.partial[:0]
+		} else {
+			lw.logLine(p[:i])
+		}
+		p = p[i+1:]
+	}
+	return n, nil
+}
+
+// Logs what's left of a line that never got its newline
+func (lw *lineWriter) Close() error {
+	lw.mu.Lock()
+	defer lw.mu.Unlock()
+	if len(lw.partial) > 0 {
+		lw.logLine(lw.partial)
+		lw.partial = nil
+	}
+	return nil
+}
+
+// must hold mu
+func (lw *lineWriter) logLine(line []byte) {
+	line = bytes.TrimSuffix(line, []byte{'\r'})
+	if len(bytes.TrimSpace(line)) == 0 || lw.t.skip(lw.lvl) {
+		return
+	}
+	rec := prepareAt(lw.lvl, string(line), currentTime(), lw.pc)
+	if lw.pkg != "" {
+		rec.FuncPath, rec.PackagePath = lw.pkg, lw.pkg
+	}
+	lw.t.send(rec)
+}
@@ -0,0 +1,42 @@
+package timber
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestWriterAt(t *testing.T) {
+	logger := NewTimber()
+	mw := NewMemoryWriter(10)
+	logger.AddLogger(ConfigLogger{LogWriter: mw, Level: INFO, Formatter: NewPatFormatter("%L %p %M"),
+		Granulars: map[string]Level{"exec/quiet": ERROR}})
+	w := logger.WriterAt(WARNING, "exec/tool")
+	fmt.Fprint(w, "first line\nsecond ")
+	fmt.Fprint(w, "half\r\n\n  \nunfinished")
+	w.(io.Closer).Close()
+	fmt.Fprintln(logger.WriterAt(WARNING, "exec/quiet"), "dropped by the granular")
+	log.New(logger.WriterAt(ERROR, ""), "", 0).Printf("from the log package")
+	logger.Close()
+	expected := []string{"WARN exec/tool first line", "WARN exec/tool second half", "WARN exec/tool unfinished",
+		"EROR github.com/smw1218/timber from the log package"}
+	if got := mw.Messages(); !reflect.DeepEqual(got, expected) {
+		t.Errorf("expected %q got %q", expected, got)
+	}
+}
+
+func TestWriterAtLongLine(t *testing.T) {
+	logger := NewTimber()
+	mw := NewMemoryWriter(10)
+	logger.AddLogger(ConfigLogger{LogWriter: mw, Level: INFO, Formatter: NewPatFormatter("%M")})
+	w := logger.WriterAt(INFO, "exec/tool")
+	w.Write([]byte(strings.Repeat("x", maxLineWriterLine+10)))
+	logger.Close()
+	got := mw.Messages()
+	if len(got) != 1 || len(got[0]) != maxLineWriterLine {
+		t.Errorf("expected one line of %d bytes, got %d lines", maxLineWriterLine, len(got))
+	}
+}
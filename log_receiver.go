@@ -0,0 +1,165 @@
+package timber
+
+import (
+	"bufio"
+	"compress/gzip"
+	"errors"
+	"io"
+	"log"
+	"net"
+	"sync"
+)
+
+// Takes the records a LogReceiver decodes; a *Timber is one
+type RecordHandler interface {
+	HandleRecord(rec *LogRecord)
+}
+
+type RecordHandlerFunc func(rec *LogRecord)
+
+func (fn RecordHandlerFunc) HandleRecord(rec *LogRecord) {
+	fn(rec)
+}
+
+// The server end of a WireWriter: it accepts connections on a listener,
+// decodes the frames of each, gob or protobuf, compressed or not, and
+// hands the records to a handler, so a central process can log what its
+// clients send with nothing lost to formatting:
+//
+//	ln, _ := net.Listen("tcp", ":5140")
+//	receiver := timber.NewLogReceiver(ln, timber.NewTimber())
+//
+// A connection that sends something that isn't a frame is hung up on.
+type LogReceiver struct {
+	listener net.Listener
+	handler  RecordHandler
+	mu       sync.Mutex
+	conns    map[net.Conn]bool
+	closed   bool
+	wg       sync.WaitGroup
+}
+
+// Starts accepting on listener, which the receiver closes with Close
+func NewLogReceiver(listener net.Listener, handler RecordHandler) *LogReceiver {
+	lr := &LogReceiver{listener: listener, handler: handler, conns: make(map[net.Conn]bool)}
+	lr.wg.Add(1)
+	go lr.accept()
+	return lr
+}
+
+// The address the receiver listens on
+func (lr *LogReceiver) Addr() net.Addr {
+	return lr.listener.Addr()
+}
+
+func (lr *LogReceiver) accept() {
+	defer lr.wg.Done()
+	for {
+		conn, err := lr.listener.Accept()
+		if err != nil {
+			var ne net.Error
+			if errors.As(err, &ne) && ne.Timeout() {
+				continue
+			}
+			lr.mu.Lock()
+			closed := lr.closed
+			lr.mu.Unlock()
+			if !closed {
+				log.Printf("TIMBER! Log receiver stopped accepting: %v\n", err)
+			}
+			return
+		}
+		lr.mu.Lock()
+		if lr.closed {
+			lr.mu.Unlock()
+			conn.Close()
+			return
+		}
+		lr.conns[conn] = true
+		lr.wg.Add(1)
+		lr.mu.Unlock()
+		go lr.serve(conn)
+	}
+}
+
+func (lr *LogReceiver) serve(conn net.Conn) {
+	defer lr.wg.Done()
+	defer func() {
+		lr.mu.Lock()
+		delete(lr.conns, conn)
+		lr.mu.Unlock()
+		conn.Close()
+	}()
+	var r io.Reader = bufio.NewReader(conn)
+	// a compressed socket writer sends one gzip stream per connection
+	if magic, err := r.(*bufio.Reader).Peek(2); err == nil && magic[0] == 0x1f && magic[1] == 0x8b {
+		gz, err := gzip.NewReader(r)
+		if err != nil {
+			lr.dropped(conn, err)
+			return
+		}
+		r = gz
+	}
+	var buf []byte
+	for {
+		var wr *WireRecord
+		var err error
+		if wr, buf, err = readWireFrame(r, buf); err != nil {
+			if err != io.EOF && err != io.ErrUnexpectedEOF && !lr.isClosed() {
+				lr.dropped(conn, err)
+			}
+			return
+		}
+		lr.handler.HandleRecord(wr.logRecord())
+	}
+}
+
+func (lr *LogReceiver) dropped(conn net.Conn, err error) {
+	log.Printf("TIMBER! Log receiver hung up on %v: %v\n", conn.RemoteAddr(), err)
+}
+
+func (lr *LogReceiver) isClosed() bool {
+	lr.mu.Lock()
+	defer lr.mu.Unlock()
+	return lr.closed
+}
+
+// Stops accepting, hangs up on the clients and waits for the records
+// already read to be handled
+func (lr *LogReceiver) Close() error {
+	lr.mu.Lock()
+	if lr.closed {
+		lr.mu.Unlock()
+		return nil
+	}
+	lr.closed = true
+	err := lr.listener.Close()
+	for conn := range lr.conns {
+		conn.Close()
+	}
+	lr.mu.Unlock()
+	lr.wg.Wait()
+	return err
+}
+
+// Logs a record from somewhere else, e.g. a LogReceiver, through the
+// loggers as if it were logged here, with its own level, time, source and
+// fields.  The counts %C, %d and the delta are this process's.
+func (t *Timber) HandleRecord(rec *LogRecord) {
+	if t.skip(rec.Level) {
+		return
+	}
+	out := getRecord()
+	*out = *rec
+	out.raw, out.elevated, out.skipMDC = false, false, false
+	if out.Timestamp.IsZero() {
+		out.Timestamp = currentTime()
+	}
+	out.LevelCount = nextLevelCount(out.Level)
+	out.Monotonic = nextMonotonic()
+	out.Delta = nextDelta(out.Timestamp)
+	if rec.Fields != nil {
+		out.Fields = mergeFields(rec.Fields, nil)
+	}
+	t.send(out)
+}
@@ -0,0 +1,104 @@
+package timber
+
+import (
+	"net"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestLogReceiver(t *testing.T) {
+	for _, compress := range []bool{false, true} {
+		for _, format := range []WireFormat{WireGob, WireProtobuf} {
+			ln, err := net.Listen("tcp", "127.0.0.1:0")
+			if err != nil {
+				t.Fatal(err)
+			}
+			central := NewTimber()
+			mw := NewMemoryWriter(10)
+			central.AddLogger(ConfigLogger{LogWriter: mw, Level: INFO, Formatter: NewPatFormatter("%L %{field:id} %M")})
+			receiver := NewLogReceiver(ln, central)
+
+			sw, err := newSocketWriter("tcp", receiver.Addr().String(), compress, false, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			client := NewTimber()
+			client.AddLogger(ConfigLogger{LogWriter: NewWireWriter(sw, format), Level: DEBUG})
+			client.Debugw("not taken centrally", Fields{"id": 1})
+			client.Warnw("disk nearly full", Fields{"id": 2})
+			client.Close()
+
+			expected := []string{"WARN 2 disk nearly full"}
+			deadline := time.Now().Add(5 * time.Second)
+			for len(mw.Messages()) < len(expected) && time.Now().Before(deadline) {
+				time.Sleep(10 * time.Millisecond)
+			}
+			receiver.Close()
+			central.Close()
+			if got := mw.Messages(); !reflect.DeepEqual(got, expected) {
+				t.Errorf("%v compress %v: expected %q got %q", format, compress, expected, got)
+			}
+		}
+	}
+}
+
+func TestLogReceiverHandlerFunc(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var mu sync.Mutex
+	var got []*LogRecord
+	receiver := NewLogReceiver(ln, RecordHandlerFunc(func(rec *LogRecord) {
+		mu.Lock()
+		got = append(got, rec)
+		mu.Unlock()
+	}))
+	conn, err := net.Dial("tcp", receiver.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	frame, _ := appendWireFrame(nil, WireGob, &WireRecord{Level: ERROR, Message: "one", LoggerName: "app.db"})
+	frame, _ = appendWireFrame(frame, WireProtobuf, &WireRecord{Level: INFO, Message: "two"})
+	conn.Write(frame)
+	conn.Write([]byte("not a frame at all"))
+	conn.Close()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		mu.Lock()
+		n := len(got)
+		mu.Unlock()
+		if n >= 2 || time.Now().After(deadline) {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	receiver.Close()
+	if len(got) != 2 || got[0].Message != "one" || got[0].LoggerName != "app.db" || got[1].Message != "two" {
+		t.Errorf("expected the two framed records, got %+v", got)
+	}
+}
+
+func TestConfigWire(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	filter := JSONFilter{Type: "socket", Properties: []JSONProperty{{"protocol", "tcp"},
+		{"endpoint", ln.Addr().String()}, {"wire", "protobuf"}}}
+	w, err := getJSONSocketWriter(filter)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ww, ok := w.(*WireWriter); !ok || ww.format != WireProtobuf {
+		t.Errorf("expected a protobuf WireWriter, got %T", w)
+	}
+	w.(*WireWriter).Close()
+	filter.Properties = append(filter.Properties, JSONProperty{"fallback_file", "spill.log"})
+	if _, err := getJSONSocketWriter(filter); err == nil {
+		t.Errorf("expected an error for a fallback_file with wire")
+	}
+}
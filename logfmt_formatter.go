@@ -0,0 +1,126 @@
+package timber
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// Formats each record as a logfmt line, e.g.
+//
+//	ts=2024-05-01T12:00:00Z level=info pkg=github.com/me/app msg="login ok" user_id=42
+//
+// The standard keys come first in that order, pkg only when the caller is
+// known, then the fields sorted by key.  Values with spaces, quotes, = or
+// control characters are quoted with Go escapes, as are empty values.
+// A field named like a standard key is written as "fields.<key>", like
+// the JSONFormatter.  In the config files <format name="logfmt"/> (or
+// "format": {"name": "logfmt"}) picks it.
+type LogfmtFormatter struct {
+	durationFormat DurationFormat
+}
+
+func NewLogfmtFormatter() *LogfmtFormatter {
+	return new(LogfmtFormatter)
+}
+
+var logfmtStandardKeys = map[string]bool{"ts": true, "level": true, "pkg": true, "msg": true}
+
+// Sets how time.Duration fields are written, see JSONFormatter.  Call it
+// before the formatter is used.
+func (lf *LogfmtFormatter) SetDurationFormat(df DurationFormat) {
+	lf.durationFormat = df
+}
+
+// LogFormatter interface
+func (lf *LogfmtFormatter) Format(rec *LogRecord) string {
+	pb := getPatBuffer()
+	pb.buf.Write(lf.appendRecord(pb.buf.AvailableBuffer(), rec))
+	msg := pb.buf.String()
+	putPatBuffer(pb)
+	return msg
+}
+
+// LogFormatterTo interface
+func (lf *LogfmtFormatter) FormatTo(w io.Writer, rec *LogRecord) (int, error) {
+	pb := getPatBuffer()
+	pb.buf.Write(lf.appendRecord(pb.buf.AvailableBuffer(), rec))
+	n, err := w.Write(pb.buf.Bytes())
+	putPatBuffer(pb)
+	return n, err
+}
+
+func (lf *LogfmtFormatter) appendRecord(buf []byte, rec *LogRecord) []byte {
+	buf = append(buf, "ts="...)
+	buf = append(buf, truncateTime(rec.Timestamp).Format(time.RFC3339Nano)...)
+	buf = append(buf, " level="...)
+	buf = append(buf, strings.ToLower(LongLevelStrings[rec.Level])...)
+	if rec.PackagePath != "" && rec.PackagePath != "_" {
+		buf = append(buf, " pkg="...)
+		buf = appendLogfmtValue(buf, rec.PackagePath)
+	}
+	buf = append(buf, " msg="...)
+	buf = appendLogfmtValue(buf, rec.Message)
+
+	keys := make([]string, 0, len(rec.Fields))
+	for k := range rec.Fields {
+		if k != FormatField {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		name := k
+		if logfmtStandardKeys[k] {
+			name = "fields." + k
+		}
+		buf = append(buf, ' ')
+		buf = appendLogfmtKey(buf, name)
+		buf = append(buf, '=')
+		buf = appendLogfmtValue(buf, logfmtString(lf.durationFormat.fieldValue(rec.Fields[k])))
+	}
+	return buf
+}
+
+func logfmtString(val interface{}) string {
+	switch v := val.(type) {
+	case string:
+		return v
+	case error:
+		return v.Error()
+	case nil:
+		return "nil"
+	case float64:
+		return strconv.FormatFloat(v, 'g', -1, 64)
+	}
+	return fmt.Sprint(val)
+}
+
+// Keys can't be quoted, the characters that would need it become _
+func appendLogfmtKey(buf []byte, key string) []byte {
+	if key == "" {
+		return append(buf, '_')
+	}
+	for _, r := range key {
+		if r <= ' ' || r == '=' || r == '"' || r == utf8.RuneError {
+			r = '_'
+		}
+		buf = utf8.AppendRune(buf, r)
+	}
+	return buf
+}
+
+func appendLogfmtValue(buf []byte, val string) []byte {
+	if val != "" && !strings.ContainsFunc(val, needsLogfmtQuote) {
+		return append(buf, val...)
+	}
+	return strconv.AppendQuote(buf, val)
+}
+
+func needsLogfmtQuote(r rune) bool {
+	return r <= ' ' || r == '=' || r == '"' || r == '\\' || r == 0x7f || r == utf8.RuneError
+}
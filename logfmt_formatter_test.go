@@ -0,0 +1,31 @@
+package timber
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestLogfmtFormatter(t *testing.T) {
+	rec := *lr
+	rec.Message = `said "hi" a=b`
+	rec.Fields = Fields{"user id": 42, "empty": "", "err": errors.New("no way"), "took": 1500 * time.Millisecond,
+		"msg": "mine", "path": `C:\tmp`}
+	expected := `ts=2011-10-20T15:39:07.383485-07:00 level=info pkg=hi msg="said \"hi\" a=b" empty="" ` +
+		`err="no way" fields.msg=mine path="C:\\tmp" took=1.5s user_id=42`
+	lf := NewLogfmtFormatter()
+	for i := 0; i < 5; i++ { // map order changes between runs, the output shouldn't
+		verify(t, "logfmt", lf.Format(&rec), expected)
+	}
+
+	rec.Fields = Fields{"took": 1500 * time.Millisecond}
+	rec.PackagePath = "_"
+	rec.Message = "plain"
+	lf.SetDurationFormat(DurationMillis)
+	verify(t, "logfmt no pkg", lf.Format(&rec), `ts=2011-10-20T15:39:07.383485-07:00 level=info msg=plain took=1500`)
+
+	formatter, err := getJSONFormatter(JSONFilter{Format: JSONProperty{Name: "logfmt"}})
+	if _, ok := formatter.(*LogfmtFormatter); !ok || err != nil {
+		t.Errorf("expected a LogfmtFormatter got %T, %v", formatter, err)
+	}
+}
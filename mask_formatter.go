@@ -0,0 +1,60 @@
+package timber
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// A regexp and what to replace its matches with.  The replacement can use
+// $1 style group references (see regexp.ReplaceAllString).
+type MaskRule struct {
+	Pattern     *regexp.Regexp
+	Replacement string
+}
+
+// Rewrites the formatted output of another formatter so things like email
+// addresses or card numbers in free-form messages never reach a writer.
+// The rules run in order over the whole formatted line.  Only filters that
+// configure rules pay for the regexp scanning.
+type MaskFormatter struct {
+	Formatter LogFormatter
+	Rules     []MaskRule
+}
+
+func NewMaskFormatter(formatter LogFormatter, rules ...MaskRule) *MaskFormatter {
+	return &MaskFormatter{Formatter: formatter, Rules: rules}
+}
+
+// Compiles pattern and appends it to the rules
+func (mf *MaskFormatter) AddRule(pattern, replacement string) error {
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return fmt.Errorf("TIMBER! Bad mask pattern %q: %v", pattern, err)
+	}
+	mf.Rules = append(mf.Rules, MaskRule{Pattern: re, Replacement: replacement})
+	return nil
+}
+
+// LogFormatter interface
+func (mf *MaskFormatter) Format(rec *LogRecord) string {
+	msg := mf.Formatter.Format(rec)
+	for _, rule := range mf.Rules {
+		msg = rule.Pattern.ReplaceAllString(msg, rule.Replacement)
+	}
+	return msg
+}
+
+// SourceUser interface
+func (mf *MaskFormatter) UsesSource() bool {
+	return formatterUsesSource(mf.Formatter)
+}
+
+// Parses a config mask of the form "pattern=>replacement"
+func parseMaskRule(mask string) (pattern, replacement string, err error) {
+	idx := strings.Index(mask, "=>")
+	if idx < 0 {
+		return "", "", fmt.Errorf("TIMBER! Bad mask %q, expected pattern=>replacement", mask)
+	}
+	return mask[:idx], mask[idx+2:], nil
+}
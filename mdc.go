@@ -0,0 +1,179 @@
+package timber
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+)
+
+// The mapped diagnostic context: fields kept per goroutine and added to
+// every record the goroutine logs, like log4j's MDC, so middleware can
+// tag everything a request logs without passing a logger down.  Where a
+// context can be passed, prefer it: NewContext or ContextWithMDC and the
+// ...Context methods cost nothing per goroutine.  Go has no goroutine
+// locals, so the values are kept by goroutine id and must be cleared when
+// the goroutine is done with them, with ClearMDC or by closing the
+// MDCScope of PushMDC:
+//
+//	defer timber.PushMDC(timber.Fields{"request_id": id}).Close()
+//
+// While any goroutine has an MDC every log call reads its goroutine id
+// off the stack.  Goroutine ids aren't reused, so the entries of the ones
+// that exit without clearing are swept out once there are twice as many
+// as the last sweep left, which dumps every stack.
+//
+// The fields a log call passes itself win on duplicate keys.  They print
+// with %{mdc:key} like any field and the JSON and logfmt formatters
+// write them with the rest.  A goroutine started by the request doesn't
+// inherit them, hand it ContextWithMDC(ctx) and log with the ...Context
+// methods, which skip the goroutine's MDC for such a context, or push
+// them again there with PushMDC(MDCFromContext(ctx)).
+var mdc struct {
+	sync.RWMutex
+	byGoroutine map[uint64]Fields
+	sweepAt     int // the size byGoroutine is swept at, see nextGoroutineSweep
+}
+
+// goroutines with an MDC, accessed atomically; zero skips goroutineID
+var mdcGoroutines int32
+
+// Sets key in the calling goroutine's MDC
+func SetMDC(key string, value interface{}) {
+	updateMDC(func(fields Fields) { fields[key] = value })
+}
+
+// Takes key out of the calling goroutine's MDC
+func RemoveMDC(key string) {
+	updateMDC(func(fields Fields) { delete(fields, key) })
+}
+
+// Empties the calling goroutine's MDC
+func ClearMDC() {
+	updateMDC(func(fields Fields) {
+		for key := range fields {
+			delete(fields, key)
+		}
+	})
+}
+
+// A copy of the calling goroutine's MDC, nil if it's empty
+func GetMDC() Fields {
+	if atomic.LoadInt32(&mdcGoroutines) == 0 {
+		return nil
+	}
+	id := goroutineID()
+	mdc.RLock()
+	defer mdc.RUnlock()
+	if len(mdc.byGoroutine[id]) == 0 {
+		return nil
+	}
+	return mergeFields(mdc.byGoroutine[id], nil)
+}
+
+// Changes the goroutine's fields with fn, dropping them once they're empty
+func updateMDC(fn func(fields Fields)) {
+	id := goroutineID()
+	mdc.Lock()
+	defer mdc.Unlock()
+	fields, ok := mdc.byGoroutine[id]
+	if !ok {
+		fields = make(Fields)
+	}
+	fn(fields)
+	switch {
+	case len(fields) == 0 && ok:
+		delete(mdc.byGoroutine, id)
+		atomic.AddInt32(&mdcGoroutines, -1)
+	case len(fields) > 0 && !ok:
+		if mdc.byGoroutine == nil {
+			mdc.byGoroutine = make(map[uint64]Fields)
+			mdc.sweepAt = minGoroutineSweep
+		}
+		mdc.byGoroutine[id] = fields
+		atomic.AddInt32(&mdcGoroutines, 1)
+		if len(mdc.byGoroutine) > mdc.sweepAt {
+			sweepMDC()
+		}
+	}
+}
+
+// Drops the MDC of the goroutines that have exited, must hold mdc
+func sweepMDC() {
+	live := liveGoroutines()
+	for id := range mdc.byGoroutine {
+		if !live[id] {
+			delete(mdc.byGoroutine, id)
+			atomic.AddInt32(&mdcGoroutines, -1)
+		}
+	}
+	mdc.sweepAt = int(nextGoroutineSweep(len(mdc.byGoroutine)))
+}
+
+// Fields pushed onto the MDC by PushMDC, Close puts back what was there
+// before
+type MDCScope struct {
+	prev Fields // the values replaced, nil for a key that wasn't set
+}
+
+// Adds fields to the calling goroutine's MDC until the scope is closed,
+// which has to happen on the same goroutine, e.g. with defer.  Scopes
+// nest: closing one restores the values it replaced.
+func PushMDC(fields Fields) *MDCScope {
+	scope := &MDCScope{prev: make(Fields, len(fields))}
+	updateMDC(func(current Fields) {
+		for key, value := range fields {
+			scope.prev[key] = current[key]
+			current[key] = value
+		}
+	})
+	return scope
+}
+
+func (scope *MDCScope) Close() {
+	updateMDC(func(current Fields) {
+		for key, value := range scope.prev {
+			if value == nil {
+				delete(current, key)
+			} else {
+				current[key] = value
+			}
+		}
+	})
+}
+
+type mdcKey struct{}
+
+// Returns a context carrying the calling goroutine's MDC as fields, as
+// NewContext does, for the goroutines a request starts
+func ContextWithMDC(ctx context.Context) context.Context {
+	fields := GetMDC()
+	ctx = context.WithValue(ctx, mdcKey{}, fields)
+	if fields == nil {
+		return ctx
+	}
+	return NewContext(ctx, fields)
+}
+
+// The MDC ContextWithMDC put in ctx, nil if there isn't one
+func MDCFromContext(ctx context.Context) Fields {
+	fields, _ := ctx.Value(mdcKey{}).(Fields)
+	return fields
+}
+
+// Whether ctx came from ContextWithMDC, so its fields have the MDC
+func hasMDC(ctx context.Context) bool {
+	_, ok := ctx.Value(mdcKey{}).(Fields)
+	return ok
+}
+
+// The record's fields with the calling goroutine's MDC under them
+func withMDC(fields Fields) Fields {
+	current := GetMDC()
+	if current == nil {
+		return fields
+	}
+	for key, value := range fields {
+		current[key] = value
+	}
+	return current
+}
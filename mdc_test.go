@@ -0,0 +1,90 @@
+package timber
+
+import (
+	"context"
+	"reflect"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestMDC(t *testing.T) {
+	log := NewTimber()
+	mw := NewMemoryWriter(10)
+	log.AddLogger(ConfigLogger{LogWriter: mw, Level: INFO, Formatter: NewPatFormatter("%{mdc:request_id} %{mdc:user} %M")})
+	SetMDC("request_id", "r1")
+	scope := PushMDC(Fields{"user": "ann", "request_id": "r2"})
+	log.Info("nested")
+	log.Infow("own wins", Fields{"user": "bob"})
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		log.Info("other goroutine")
+	}()
+	wg.Wait()
+	scope.Close()
+	log.Info("popped")
+	ctx := ContextWithMDC(context.Background())
+	ClearMDC()
+	log.Info("cleared")
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		log.InfoContext(ctx, "from the context")
+		defer PushMDC(MDCFromContext(ctx)).Close()
+		log.Info("pushed again")
+	}()
+	wg.Wait()
+	log.Close()
+	expected := []string{"r2 ann nested", "r2 bob own wins", "  other goroutine", "r1  popped", "  cleared",
+		"r1  from the context", "r1  pushed again"}
+	if got := mw.Messages(); !reflect.DeepEqual(got, expected) {
+		t.Errorf("expected %q got %q", expected, got)
+	}
+	if GetMDC() != nil || mdcGoroutines != 0 {
+		t.Errorf("expected the MDC to be gone, %d goroutines have one", mdcGoroutines)
+	}
+}
+
+func TestMDCSweep(t *testing.T) {
+	// goroutines that exit without clearing their MDC
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			SetMDC("request_id", "leaked")
+		}()
+	}
+	wg.Wait()
+	defer PushMDC(Fields{"user": "kept"}).Close()
+	for deadline := time.Now().Add(time.Second); ; time.Sleep(5 * time.Millisecond) {
+		mdc.Lock()
+		sweepMDC()
+		n := len(mdc.byGoroutine)
+		mdc.Unlock()
+		if n == 1 || time.Now().After(deadline) {
+			break
+		}
+	}
+	if atomic.LoadInt32(&mdcGoroutines) != 1 || GetMDC()["user"] != "kept" {
+		t.Errorf("expected only this goroutine's MDC after the sweep, %d goroutines have one", mdcGoroutines)
+	}
+	if mdc.sweepAt != minGoroutineSweep {
+		t.Errorf("expected the next sweep at %d got %d", minGoroutineSweep, mdc.sweepAt)
+	}
+
+	// a ContextWithMDC context has the MDC already
+	log := NewTimber()
+	mw := NewMemoryWriter(10)
+	log.AddLogger(ConfigLogger{LogWriter: mw, Level: INFO, Formatter: NewPatFormatter("%{mdc:user} %M")})
+	ctx := ContextWithMDC(context.Background())
+	SetMDC("user", "changed")
+	log.InfoContext(ctx, "from the context")
+	log.Close()
+	if got := mw.Messages(); !reflect.DeepEqual(got, []string{"kept from the context"}) {
+		t.Errorf("expected the context's MDC got %q", got)
+	}
+}
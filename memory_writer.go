@@ -0,0 +1,82 @@
+package timber
+
+import (
+	"io"
+	"os"
+	"sync"
+)
+
+// Keeps the last N messages in a ring buffer.  Useful to hang on to recent
+// context that can be dumped when something goes wrong; see DumpOnPanic.
+type MemoryWriter struct {
+	mu   sync.Mutex
+	msgs []string
+	next int
+	full bool
+}
+
+// The MemoryWriter that DumpOnPanic writes out.  It still has to be added
+// to a logger with AddLogger to receive any messages.
+var PanicDump *MemoryWriter
+
+func NewMemoryWriter(size int) *MemoryWriter {
+	if size <= 0 {
+		size = 1
+	}
+	return &MemoryWriter{msgs: make([]string, size)}
+}
+
+func (mw *MemoryWriter) LogWrite(msg string) {
+	mw.mu.Lock()
+	mw.msgs[mw.next] = msg
+	mw.next++
+	if mw.next == len(mw.msgs) {
+		mw.next = 0
+		mw.full = true
+	}
+	mw.mu.Unlock()
+}
+
+// Returns a copy of the buffered messages, oldest first
+func (mw *MemoryWriter) Messages() []string {
+	mw.mu.Lock()
+	defer mw.mu.Unlock()
+	if !mw.full {
+		return append([]string(nil), mw.msgs[:mw.next]...)
+	}
+	ret := make([]string, 0, len(mw.msgs))
+	ret = append(ret, mw.msgs[mw.next:]...)
+	return append(ret, mw.msgs[:mw.next]...)
+}
+
+// Writes the buffered messages to w, oldest first, one per line
+func (mw *MemoryWriter) WriteTo(w io.Writer) (int64, error) {
+	var total int64
+	for _, msg := range mw.Messages() {
+		n, err := io.WriteString(w, msg+"\n")
+		total += int64(n)
+		if err != nil {
+			return total, err
+		}
+	}
+	return total, nil
+}
+
+func (mw *MemoryWriter) Close() {
+	// Nothing, the messages stay available
+}
+
+// Use as the first defer in main or a goroutine:
+//   defer timber.DumpOnPanic()
+// When a panic occurs the messages queued on Global are sent, then the
+// contents of PanicDump are written to stderr and the panic continues.
+func DumpOnPanic() {
+	if r := recover(); r != nil {
+		global().Flush()
+		if PanicDump != nil {
+			os.Stderr.WriteString("TIMBER! recent log messages before panic:\n")
+			PanicDump.WriteTo(os.Stderr)
+		}
+		panic(r)
+	}
+}
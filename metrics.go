@@ -0,0 +1,121 @@
+package timber
+
+import (
+	"sync/atomic"
+)
+
+// What happened to the records of one level that reached a logger
+type LevelCounts struct {
+	Emitted  uint64 // handed to the writer
+	Filtered uint64 // turned away by the levels, the Sampler or the Filters
+	Dropped  uint64 // thrown away by a full AsyncWriter queue
+	Errors   uint64 // failed in the writer
+}
+
+// The counts of one logger as returned by Metrics.  Records below the
+// lowest level any logger takes never get as far as the loggers and
+// aren't counted.  Dropped and Errors only count what the writer says
+// about each record: a StructuredWriter's error, a CheckedWriter (an
+// AsyncWriter or a FallbackWriter), or a formatter writing straight into
+// an io.Writer.
+type LoggerMetrics struct {
+	Index  int                   // as AddLogger returned
+	Tag    string                // the logger's Tag, may be empty
+	Writer string                // a description of the writer
+	Levels map[Level]LevelCounts // only the levels something happened at
+}
+
+// The counts of every level added up
+func (lm LoggerMetrics) Total() LevelCounts {
+	var total LevelCounts
+	for _, counts := range lm.Levels {
+		total.Emitted += counts.Emitted
+		total.Filtered += counts.Filtered
+		total.Dropped += counts.Dropped
+		total.Errors += counts.Errors
+	}
+	return total
+}
+
+// The counters of a logger, shared by the copies of its ConfigLogger and
+// updated by the dispatch goroutine
+type loggerCounts struct {
+	levels [CRITICAL + 1]struct {
+		emitted, filtered, dropped, errors uint64 // accessed atomically
+	}
+}
+
+func (lc *loggerCounts) level(lvl Level) int {
+	switch {
+	case lvl > CRITICAL:
+		return int(CRITICAL)
+	case lvl < NONE:
+		return int(NONE)
+	}
+	return int(lvl)
+}
+
+func (lc *loggerCounts) emitted(lvl Level) {
+	if lc != nil {
+		atomic.AddUint64(&lc.levels[lc.level(lvl)].emitted, 1)
+	}
+}
+
+func (lc *loggerCounts) filtered(lvl Level) {
+	if lc != nil {
+		atomic.AddUint64(&lc.levels[lc.level(lvl)].filtered, 1)
+	}
+}
+
+// Counts the outcome of a write that told what happened
+func (lc *loggerCounts) failed(lvl Level, err error) {
+	if lc == nil || err == nil {
+		return
+	}
+	if err == ErrQueueFull {
+		atomic.AddUint64(&lc.levels[lc.level(lvl)].dropped, 1)
+	} else {
+		atomic.AddUint64(&lc.levels[lc.level(lvl)].errors, 1)
+	}
+}
+
+func (lc *loggerCounts) snapshot() map[Level]LevelCounts {
+	levels := make(map[Level]LevelCounts)
+	if lc == nil {
+		return levels
+	}
+	for lvl := range lc.levels {
+		counts := LevelCounts{
+			Emitted:  atomic.LoadUint64(&lc.levels[lvl].emitted),
+			Filtered: atomic.LoadUint64(&lc.levels[lvl].filtered),
+			Dropped:  atomic.LoadUint64(&lc.levels[lvl].dropped),
+			Errors:   atomic.LoadUint64(&lc.levels[lvl].errors),
+		}
+		if counts != (LevelCounts{}) {
+			levels[Level(lvl)] = counts
+		}
+	}
+	return levels
+}
+
+// Returns the counts of each logger, in the order they were added, so
+// dropped and failing records show up; see the metrics package for
+// publishing them.  Returns nil once the Timber is closed.
+func (t *Timber) Metrics() []LoggerMetrics {
+	metricsChan := make(chan []LoggerMetrics, 1)
+	if !t.configure(timberConfig{Action: actionMetrics, Metrics: metricsChan}) {
+		return nil
+	}
+	return <-metricsChan
+}
+
+func collectMetrics(cls []ConfigLogger) []LoggerMetrics {
+	metrics := make([]LoggerMetrics, len(cls))
+	for i, cLog := range cls {
+		metrics[i] = LoggerMetrics{Index: i, Tag: cLog.Tag, Writer: describeWriter(cLog.LogWriter),
+			Levels: cLog.counts.snapshot()}
+	}
+	return metrics
+}
+
+func Metrics() []LoggerMetrics { return global().Metrics() }
@@ -0,0 +1,125 @@
+// Package metrics publishes the counts of timber.Timber.Metrics, the
+// records each logger emitted, filtered, dropped and failed to write, so
+// a writer that's losing messages shows up on a dashboard.  It's a
+// separate package so only applications that want it carry net/http.
+//
+//	metrics.Publish("timber", nil)                       // in /debug/vars
+//	http.Handle("/metrics/timber", metrics.Handler(nil)) // for Prometheus
+//
+// Handler serves the Prometheus text format, so Prometheus scrapes it
+// without a client library.  To add the counts to a registry of your own
+// instead, build with the prometheus tag and register Collector:
+//
+//	prometheus.MustRegister(metrics.Collector(nil)) // go build -tags prometheus
+package metrics
+
+import (
+	"expvar"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/smw1218/timber"
+)
+
+// The name of the counter Handler serves
+const Name = "timber_records_total"
+
+// Its HELP text
+const Help = "Records that reached a timber logger, by level and what happened to them."
+
+// What happened to the records, the outcome label
+var Outcomes = []string{"emitted", "filtered", "dropped", "errored"}
+
+// One counter: the records of one level that had one outcome at a logger
+type Sample struct {
+	Logger  string // the logger's tag, or its index when it has none
+	Level   string // the long level name, e.g. WARNING
+	Outcome string // one of Outcomes
+	Value   float64
+}
+
+func timberOrGlobal(t *timber.Timber) *timber.Timber {
+	if t == nil {
+		return timber.GetGlobal()
+	}
+	return t
+}
+
+// The counters of t, the Global logger if t is nil, ordered by logger,
+// level and outcome.  Only the levels something happened at are there.
+func Samples(t *timber.Timber) []Sample {
+	var samples []Sample
+	for _, lm := range timberOrGlobal(t).Metrics() {
+		logger := lm.Tag
+		if logger == "" {
+			logger = strconv.Itoa(lm.Index)
+		}
+		levels := make([]timber.Level, 0, len(lm.Levels))
+		for lvl := range lm.Levels {
+			levels = append(levels, lvl)
+		}
+		sort.Slice(levels, func(i, j int) bool { return levels[i] < levels[j] })
+		for _, lvl := range levels {
+			counts := lm.Levels[lvl]
+			for i, value := range []uint64{counts.Emitted, counts.Filtered, counts.Dropped, counts.Errors} {
+				samples = append(samples, Sample{Logger: logger, Level: timber.LongLevelStrings[lvl],
+					Outcome: Outcomes[i], Value: float64(value)})
+			}
+		}
+	}
+	return samples
+}
+
+// The expvar form, by logger then level:
+//
+//	{"file": {"INFO": {"emitted": 12, "filtered": 0, "dropped": 0, "errored": 0}}}
+func Vars(t *timber.Timber) map[string]map[string]map[string]uint64 {
+	vars := make(map[string]map[string]map[string]uint64)
+	for _, s := range Samples(t) {
+		if vars[s.Logger] == nil {
+			vars[s.Logger] = make(map[string]map[string]uint64)
+		}
+		if vars[s.Logger][s.Level] == nil {
+			vars[s.Logger][s.Level] = make(map[string]uint64)
+		}
+		vars[s.Logger][s.Level][s.Outcome] = uint64(s.Value)
+	}
+	return vars
+}
+
+// Publishes Vars of t under name in expvar, read each time /debug/vars
+// is.  Like expvar.Publish it panics if name is taken.
+func Publish(name string, t *timber.Timber) {
+	expvar.Publish(name, expvar.Func(func() interface{} { return Vars(t) }))
+}
+
+// Serves Samples of t, the Global logger if t is nil, in the Prometheus
+// text format
+func Handler(t *timber.Timber) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		WriteText(w, t)
+	})
+}
+
+// Writes Samples of t in the Prometheus text format
+func WriteText(w io.Writer, t *timber.Timber) error {
+	var b strings.Builder
+	fmt.Fprintf(&b, "# HELP %s %s\n", Name, Help)
+	fmt.Fprintf(&b, "# TYPE %s counter\n", Name)
+	for _, s := range Samples(t) {
+		fmt.Fprintf(&b, "%s{logger=%s,level=%s,outcome=%s} %s\n", Name, labelValue(s.Logger), labelValue(s.Level),
+			labelValue(s.Outcome), strconv.FormatFloat(s.Value, 'f', -1, 64))
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// Quoted with the escapes the text format takes: backslash, quote and newline
+func labelValue(s string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(s) + `"`
+}
@@ -0,0 +1,59 @@
+package metrics
+
+import (
+	"encoding/json"
+	"expvar"
+	"net/http/httptest"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/smw1218/timber"
+)
+
+// the runs of TestHandler that have published
+var published int
+
+func TestHandler(t *testing.T) {
+	log := timber.NewTimber()
+	defer log.Close()
+	log.AddLogger(timber.ConfigLogger{LogWriter: timber.NullWriter{}, Level: timber.INFO,
+		Formatter: timber.NewPatFormatter("%M"), Tag: `a "null"`})
+	log.AddLogger(timber.ConfigLogger{LogWriter: timber.NullWriter{}, Level: timber.DEBUG,
+		Formatter: timber.NewPatFormatter("%M")})
+	log.Debug("one")
+	log.Warn("two")
+	log.Flush()
+
+	rec := httptest.NewRecorder()
+	Handler(log).ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	body := rec.Body.String()
+	for _, line := range []string{
+		"# TYPE timber_records_total counter",
+		`timber_records_total{logger="a \"null\"",level="DEBUG",outcome="filtered"} 1`,
+		`timber_records_total{logger="a \"null\"",level="WARNING",outcome="emitted"} 1`,
+		`timber_records_total{logger="1",level="DEBUG",outcome="emitted"} 1`,
+		`timber_records_total{logger="1",level="WARNING",outcome="dropped"} 0`,
+	} {
+		if !strings.Contains(body, line+"\n") {
+			t.Errorf("expected %q in:\n%s", line, body)
+		}
+	}
+	if ct := rec.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/plain; version=0.0.4") {
+		t.Errorf("unexpected content type %q", ct)
+	}
+
+	// expvar names can't be published twice, e.g. with -count=2
+	published++
+	name := "timber_test_" + strconv.Itoa(published)
+	Publish(name, log)
+	var vars map[string]map[string]map[string]uint64
+	if err := json.Unmarshal([]byte(expvar.Get(name).String()), &vars); err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string]uint64{"emitted": 1, "filtered": 0, "dropped": 0, "errored": 0}
+	if got := vars["1"]["WARNING"]; !reflect.DeepEqual(got, expected) {
+		t.Errorf("expected %v got %v", expected, got)
+	}
+}
@@ -0,0 +1,36 @@
+//go:build prometheus
+
+package metrics
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/smw1218/timber"
+)
+
+var desc = prometheus.NewDesc(Name, Help, []string{"logger", "level", "outcome"}, nil)
+
+// A prometheus.Collector of Samples of t, the Global logger if t is nil,
+// for a registry of your own:
+//
+//	prometheus.MustRegister(metrics.Collector(nil))
+//
+// It's only built with the prometheus tag so the package doesn't pull in
+// client_golang for everyone else.
+func Collector(t *timber.Timber) prometheus.Collector {
+	return collector{t: t}
+}
+
+type collector struct {
+	t *timber.Timber
+}
+
+func (c collector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- desc
+}
+
+func (c collector) Collect(ch chan<- prometheus.Metric) {
+	for _, s := range Samples(c.t) {
+		ch <- prometheus.MustNewConstMetric(desc, prometheus.CounterValue, s.Value, s.Logger, s.Level, s.Outcome)
+	}
+}
@@ -0,0 +1,40 @@
+//go:build prometheus
+
+package metrics
+
+import (
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/smw1218/timber"
+)
+
+func TestCollector(t *testing.T) {
+	log := timber.NewTimber()
+	defer log.Close()
+	log.AddLogger(timber.ConfigLogger{LogWriter: timber.NullWriter{}, Level: timber.INFO,
+		Formatter: timber.NewPatFormatter("%M"), Tag: "null"})
+	log.Warn("one")
+	log.Flush()
+
+	c := Collector(log)
+	descs := make(chan *prometheus.Desc, 1)
+	c.Describe(descs)
+	if got := <-descs; got != desc {
+		t.Errorf("unexpected desc %v", got)
+	}
+	metrics := make(chan prometheus.Metric, 10)
+	c.Collect(metrics)
+	close(metrics)
+	n := 0
+	for m := range metrics {
+		if m.Desc() != desc {
+			t.Errorf("unexpected desc %v", m.Desc())
+		}
+		n++
+	}
+	if expected := len(Samples(log)); n != expected || n == 0 {
+		t.Errorf("expected %d metrics got %d", expected, n)
+	}
+}
@@ -0,0 +1,59 @@
+package timber
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestMetrics(t *testing.T) {
+	sw := &stallWriter{release: make(chan bool), mw: NewMemoryWriter(10)}
+	aw := NewAsyncWriter(sw, 1, OverflowDrop)
+	formatter := NewPatFormatter("%L %M")
+	loggers := []ConfigLogger{
+		{LogWriter: NewMemoryWriter(10), Level: INFO, MaxLevel: WARNING, Formatter: formatter, Tag: "mem"},
+		{LogWriter: &flakyWriter{down: true}, Level: ERROR, Formatter: formatter, Tag: "flaky"},
+		{LogWriter: aw, Level: DEBUG, Formatter: formatter},
+	}
+	for i := range loggers {
+		loggers[i].counts = new(loggerCounts)
+	}
+	send := func(lvl Level) { sendToLoggers(loggers, &LogRecord{Level: lvl, Message: "m", Timestamp: time.Now()}) }
+	send(DEBUG)
+	time.Sleep(10 * time.Millisecond) // let the async loop pick it up and stall
+	send(INFO)                        // queued
+	send(ERROR)                       // the queue is full
+	close(sw.release)
+	aw.Close()
+
+	metrics := collectMetrics(loggers)
+	expected := []map[Level]LevelCounts{
+		{DEBUG: {Filtered: 1}, INFO: {Emitted: 1}, ERROR: {Filtered: 1}},
+		{DEBUG: {Filtered: 1}, INFO: {Filtered: 1}, ERROR: {Emitted: 1, Errors: 1}},
+		{DEBUG: {Emitted: 1}, INFO: {Emitted: 1}, ERROR: {Emitted: 1, Dropped: 1}},
+	}
+	for i, lm := range metrics {
+		if !reflect.DeepEqual(lm.Levels, expected[i]) {
+			t.Errorf("logger %d: expected %+v got %+v", i, expected[i], lm.Levels)
+		}
+	}
+	if metrics[1].Tag != "flaky" || metrics[1].Writer != "flaky" || metrics[2].Index != 2 {
+		t.Errorf("unexpected logger %+v", metrics[1])
+	}
+	if total := metrics[2].Total(); total != (LevelCounts{Emitted: 3, Dropped: 1}) {
+		t.Errorf("unexpected total %+v", total)
+	}
+
+	log := NewTimber()
+	log.AddLogger(ConfigLogger{LogWriter: NewMemoryWriter(10), Level: INFO, Formatter: formatter})
+	log.Info("one")
+	log.Warn("two")
+	log.Flush()
+	if got := log.Metrics(); len(got) != 1 || got[0].Total() != (LevelCounts{Emitted: 2}) {
+		t.Errorf("unexpected metrics %+v", got)
+	}
+	log.Close()
+	if got := log.Metrics(); got != nil {
+		t.Errorf("expected nil once closed got %+v", got)
+	}
+}
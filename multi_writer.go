@@ -0,0 +1,73 @@
+package timber
+
+// Sends each message to several writers.  Each child may have its own
+// minimum level, checked after the ConfigLogger level and granulars; a
+// child added with NONE gets everything that reaches the MultiWriter.
+type MultiWriter struct {
+	children []multiChild
+}
+
+type multiChild struct {
+	writer LogWriter
+	level  Level
+}
+
+// Children added here get every message
+func NewMultiWriter(writers ...LogWriter) *MultiWriter {
+	mw := new(MultiWriter)
+	for _, w := range writers {
+		mw.AddWriter(w, NONE)
+	}
+	return mw
+}
+
+// Adds a child that only gets messages at level or above.  Not safe to
+// call once the MultiWriter is in use by a logger.
+func (mw *MultiWriter) AddWriter(writer LogWriter, level Level) {
+	mw.children = append(mw.children, multiChild{writer, level})
+}
+
+// Without the record there's no level, so every child gets the message
+func (mw *MultiWriter) LogWrite(msg string) {
+	for _, child := range mw.children {
+		child.writer.LogWrite(msg)
+	}
+}
+
+// RecordWriter interface
+func (mw *MultiWriter) LogWriteRecord(rec *LogRecord, msg string) {
+	for _, child := range mw.children {
+		if rec.Level < child.level {
+			continue
+		}
+		writeRecord(child.writer, rec, msg)
+	}
+}
+
+// Flushes the children that implement Flusher
+func (mw *MultiWriter) Flush() {
+	for _, child := range mw.children {
+		if fl, ok := child.writer.(Flusher); ok {
+			fl.Flush()
+		}
+	}
+}
+
+// Reopens the children that implement Reopener, returns the first error
+func (mw *MultiWriter) Reopen() error {
+	var first error
+	for _, child := range mw.children {
+		if ro, ok := child.writer.(Reopener); ok {
+			if err := ro.Reopen(); err != nil && first == nil {
+				first = err
+			}
+		}
+	}
+	return first
+}
+
+func (mw *MultiWriter) Close() {
+	for _, child := range mw.children {
+		child.writer.Close()
+	}
+}
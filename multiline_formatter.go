@@ -0,0 +1,95 @@
+package timber
+
+import (
+	"fmt"
+	"strings"
+)
+
+// What a MultilineFormatter does with the lines after the first
+type MultilineMode int
+
+const (
+	MultilineIndent MultilineMode = iota // start them with Indent
+	MultilinePrefix                      // start them with the header, what comes before the message
+	MultilineEscape                      // escape the newlines so the record is one line
+)
+
+// Parses the multiline property: indent, prefix or escape
+func ParseMultilineMode(s string) (MultilineMode, error) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "indent":
+		return MultilineIndent, nil
+	case "prefix":
+		return MultilinePrefix, nil
+	case "escape":
+		return MultilineEscape, nil
+	}
+	return MultilineIndent, fmt.Errorf("TIMBER! Unknown multiline %q, expected indent, prefix or escape", s)
+}
+
+// Keeps records with newlines in them, stack traces or pretty printed
+// JSON, parseable by shippers that read a line at a time.  With
+// MultilineIndent the continuation lines start with Indent, a tab if
+// empty, for a shipper that joins indented lines onto the one before;
+// MultilinePrefix repeats the header so each line stands alone, or
+// indents if the message can't be found in the output; MultilineEscape
+// writes the newlines and other control characters as escapes.  Newlines
+// at the end of the output are dropped.
+type MultilineFormatter struct {
+	Formatter LogFormatter
+	Mode      MultilineMode
+	Indent    string
+}
+
+func NewMultilineFormatter(formatter LogFormatter, mode MultilineMode) *MultilineFormatter {
+	return &MultilineFormatter{Formatter: formatter, Mode: mode}
+}
+
+// LogFormatter interface
+func (mf *MultilineFormatter) Format(rec *LogRecord) string {
+	msg := mf.Formatter.Format(rec)
+	if strings.IndexAny(msg, "\r\n") < 0 {
+		return msg
+	}
+	msg = strings.TrimRight(msg, "\r\n")
+	if mf.Mode == MultilineEscape {
+		return escapeControl(msg)
+	}
+	lead := mf.Indent
+	if lead == "" {
+		lead = "\t"
+	}
+	if mf.Mode == MultilinePrefix {
+		if header, ok := messageHeader(msg, rec.Message); ok {
+			lead = header
+		}
+	}
+	lines := strings.Split(strings.ReplaceAll(msg, "\r\n", "\n"), "\n")
+	var b strings.Builder
+	b.Grow(len(msg) + len(lead)*(len(lines)-1))
+	b.WriteString(lines[0])
+	for _, line := range lines[1:] {
+		b.WriteByte('\n')
+		b.WriteString(lead)
+		b.WriteString(line)
+	}
+	return b.String()
+}
+
+// SourceUser interface
+func (mf *MultilineFormatter) UsesSource() bool {
+	return formatterUsesSource(mf.Formatter)
+}
+
+// What the formatter wrote on the line before the message
+func messageHeader(formatted, message string) (string, bool) {
+	first := message
+	if i := strings.IndexAny(first, "\r\n"); i >= 0 {
+		first = first[:i]
+	}
+	i := strings.Index(formatted, first)
+	if first == "" || i < 0 || strings.IndexAny(formatted[:i], "\r\n") >= 0 {
+		return "", false
+	}
+	return formatted[:i], true
+}
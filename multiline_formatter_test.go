@@ -0,0 +1,43 @@
+package timber
+
+import (
+	"testing"
+)
+
+func TestMultilineFormatter(t *testing.T) {
+	rec := LogRecord{Level: ERROR, Message: "panic: boom\ngoroutine 1:\r\n\tmain.go:12\n"}
+	pf := NewPatFormatter("%L [svc] %M (%{field:id})")
+	rec.Fields = Fields{"id": "7"}
+	cases := []struct {
+		mode     MultilineMode
+		indent   string
+		expected string
+	}{
+		{MultilineIndent, "", "EROR [svc] panic: boom\n\tgoroutine 1:\n\t\tmain.go:12\n\t (7)"},
+		{MultilineIndent, "  | ", "EROR [svc] panic: boom\n  | goroutine 1:\n  | \tmain.go:12\n  |  (7)"},
+		{MultilinePrefix, "", "EROR [svc] panic: boom\nEROR [svc] goroutine 1:\nEROR [svc] \tmain.go:12\nEROR [svc]  (7)"},
+		{MultilineEscape, "", `EROR [svc] panic: boom\ngoroutine 1:\r\n\tmain.go:12\n (7)`},
+	}
+	for _, c := range cases {
+		mf := &MultilineFormatter{Formatter: pf, Mode: c.mode, Indent: c.indent}
+		verify(t, "multiline", mf.Format(&rec), c.expected)
+	}
+	single := LogRecord{Level: INFO, Message: "one line"}
+	verify(t, "single line", NewMultilineFormatter(pf, MultilinePrefix).Format(&single), "INFO [svc] one line ()")
+	leading := LogRecord{Level: INFO, Message: "\nbody"}
+	verify(t, "no header", NewMultilineFormatter(NewPatFormatter("%L %M"), MultilinePrefix).Format(&leading), "INFO \n\tbody")
+}
+
+func TestConfigMultiline(t *testing.T) {
+	cl, _, err := buildLogger(nil, JSONFilter{Enabled: true, Type: "null", Format: JSONProperty{"pattern", "%L %M"},
+		Properties: []JSONProperty{{"multiline", "prefix"}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	rec := LogRecord{Level: WARNING, Message: "a\nb"}
+	verify(t, "config", cl.Formatter.Format(&rec), "WARN a\nWARN b")
+	if _, _, err := buildLogger(nil, JSONFilter{Enabled: true, Type: "null",
+		Properties: []JSONProperty{{"multiline", "fold"}}}); err == nil {
+		t.Error("expected an error for an unknown multiline mode")
+	}
+}
@@ -0,0 +1,135 @@
+package timber
+
+import (
+	"errors"
+	"strings"
+)
+
+// A Timber logging under a component name from GetLogger, independent of
+// the package making the call.  Names are dotted, e.g. myapp.db.pool, and
+// a granular whose path is the name or one of its ancestors (myapp.db,
+// myapp) sets the level, the most specific one winning, so a whole
+// component can be turned up at once.  A name granular beats the
+// package and function granulars; with none the logger's level applies.
+type NamedLogger struct {
+	t     *Timber
+	name  string
+	depth int // frames to skip past FileDepth, see WithCallDepth
+}
+
+// Returns the logger for name, e.g.
+//
+//	pool := timber.GetLogger("myapp.db.pool")
+//	pool.Debug("%d connections", n)
+//
+// Loggers are cheap to make and hold no state besides the name, so two
+// with the same name behave the same.
+func (t *Timber) GetLogger(name string) *NamedLogger {
+	return &NamedLogger{t: t, name: name}
+}
+
+func GetLogger(name string) *NamedLogger { return global().GetLogger(name) }
+
+// The dotted name, rendered by %c
+func (nl *NamedLogger) Name() string {
+	return nl.name
+}
+
+// Returns the logger for a component under this one, name.child
+func (nl *NamedLogger) GetLogger(child string) *NamedLogger {
+	return &NamedLogger{t: nl.t, name: nl.name + "." + child, depth: nl.depth}
+}
+
+// Returns this logger looking n more frames up the stack for the caller,
+// see Timber.WithCallDepth
+func (nl *NamedLogger) WithCallDepth(n int) *NamedLogger {
+	return &NamedLogger{t: nl.t, name: nl.name, depth: nl.depth + n}
+}
+
+// The longest granular path that is name or a dotted ancestor of it
+func namedGranular(granulars map[string]Level, name string) (string, Level, bool) {
+	for {
+		if gLevel, ok := granulars[name]; ok {
+			return name, gLevel, true
+		}
+		i := strings.LastIndexByte(name, '.')
+		if i < 0 {
+			return "", 0, false
+		}
+		name = name[:i]
+	}
+}
+
+func (nl *NamedLogger) send(lvl Level, msg string) {
+	// like FieldLogger.send this frame stands in for the package function
+	rec := nl.t.prepare(lvl, msg, nl.t.FileDepth+nl.depth)
+	rec.LoggerName = nl.name
+	nl.t.send(rec)
+}
+
+func (nl *NamedLogger) Finest(arg0 interface{}, args ...interface{}) {
+	if nl.t.skip(FINEST) {
+		return
+	}
+	nl.send(FINEST, sprintf(arg0, args))
+}
+func (nl *NamedLogger) Fine(arg0 interface{}, args ...interface{}) {
+	if nl.t.skip(FINE) {
+		return
+	}
+	nl.send(FINE, sprintf(arg0, args))
+}
+func (nl *NamedLogger) Debug(arg0 interface{}, args ...interface{}) {
+	if nl.t.skip(DEBUG) {
+		return
+	}
+	nl.send(DEBUG, sprintf(arg0, args))
+}
+func (nl *NamedLogger) Trace(arg0 interface{}, args ...interface{}) {
+	if nl.t.skip(TRACE) {
+		return
+	}
+	nl.send(TRACE, sprintf(arg0, args))
+}
+func (nl *NamedLogger) Info(arg0 interface{}, args ...interface{}) {
+	if nl.t.skip(INFO) {
+		return
+	}
+	nl.send(INFO, sprintf(arg0, args))
+}
+func (nl *NamedLogger) Warn(arg0 interface{}, args ...interface{}) error {
+	msg := sprintf(arg0, args)
+	if !nl.t.skip(WARNING) {
+		nl.send(WARNING, msg)
+	}
+	return errors.New(msg)
+}
+func (nl *NamedLogger) Error(arg0 interface{}, args ...interface{}) error {
+	msg := sprintf(arg0, args)
+	if !nl.t.skip(ERROR) {
+		nl.send(ERROR, msg)
+	}
+	return errors.New(msg)
+}
+func (nl *NamedLogger) Critical(arg0 interface{}, args ...interface{}) error {
+	msg := sprintf(arg0, args)
+	if !nl.t.skip(CRITICAL) {
+		nl.send(CRITICAL, msg)
+	}
+	return errors.New(msg)
+}
+func (nl *NamedLogger) Log(lvl Level, arg0 interface{}, args ...interface{}) {
+	if nl.t.skip(lvl) {
+		return
+	}
+	nl.send(lvl, sprintf(arg0, args))
+}
+
+// Timber.Enabled for this logger's records, name granulars included
+func (nl *NamedLogger) Enabled(lvl Level) bool { return nl.enabled(lvl) }
+func (nl *NamedLogger) DebugEnabled() bool     { return nl.enabled(DEBUG) }
+
+// like send this frame stands in for the package function
+func (nl *NamedLogger) enabled(lvl Level) bool {
+	return nl.t.enabledAt(lvl, nl.name, nl.t.FileDepth+nl.depth)
+}
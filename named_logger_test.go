@@ -0,0 +1,50 @@
+package timber
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestNamedLoggerInheritance(t *testing.T) {
+	mw := NewMemoryWriter(10)
+	log := NewTimber()
+	log.AddLogger(ConfigLogger{LogWriter: mw, Level: WARNING, Formatter: NewPatFormatter("%c %L %M"),
+		Granulars: map[string]Level{"myapp.db": DEBUG, "myapp.db.pool": ERROR}})
+	db := log.GetLogger("myapp.db")
+	db.Debug("query")
+	db.GetLogger("conn").Debug("dial %d", 1)
+	log.GetLogger("myapp.db.pool").Warn("dropped")
+	log.GetLogger("myapp.db.pool.idle").Error("closed")
+	log.GetLogger("myapp.dbx").Info("not a child")
+	log.GetLogger("other").Warn("default")
+	log.Close()
+	expected := []string{"myapp.db DEBG query", "myapp.db.conn DEBG dial 1", "myapp.db.pool.idle EROR closed",
+		"other WARN default"}
+	if got := mw.Messages(); !reflect.DeepEqual(got, expected) {
+		t.Errorf("expected %q got %q", expected, got)
+	}
+}
+
+func TestNamedLoggerConfig(t *testing.T) {
+	cLog, _, err := buildLogger(nil, JSONFilter{Enabled: true, Type: "console", Level: "INFO",
+		Format:    JSONProperty{"pattern", "%c %x %M"},
+		Granulars: []JSONGranular{{Level: "FINE", Path: "myapp"}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	cLog.LogWriter.Close()
+	mw := NewMemoryWriter(10)
+	cLog.LogWriter = mw
+	log := NewTimber()
+	log.AddLogger(cLog)
+	log.GetLogger("myapp.cache").Fine("miss")
+	log.Fine("plain")
+	if err := log.SetGranular("myapp.cache", WARNING); err != nil {
+		t.Fatal(err)
+	}
+	log.GetLogger("myapp.cache").Info("set at runtime")
+	log.Close()
+	if expected := []string{"myapp.cache named_logger_test miss"}; !reflect.DeepEqual(mw.Messages(), expected) {
+		t.Errorf("expected %q got %q", expected, mw.Messages())
+	}
+}
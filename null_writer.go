@@ -0,0 +1,14 @@
+package timber
+
+// Throws everything away.  Switching a filter's type to null turns the
+// destination off without taking the filter out of the config.
+type NullWriter struct{}
+
+func (nw NullWriter) LogWrite(msg string) {}
+
+// Lets formatters write straight into the void without building a string
+func (nw NullWriter) Write(p []byte) (int, error) {
+	return len(p), nil
+}
+
+func (nw NullWriter) Close() {}
@@ -0,0 +1,239 @@
+package timber
+
+import (
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// One directive of a pattern for the append path, with the literal text
+// that comes before it.  A part with dyn 0 is just trailing text.
+type patPart struct {
+	lit   string
+	dyn   byte
+	left  bool // - flag
+	width int
+	prec  int       // -1 for none
+	brace braceSpec // for Y, a %{...}
+}
+
+// Splits the pattern the way compileForLevel does into parts that render
+// without fmt.  False if it uses something only fmt does the same way:
+// a 0 or + flag, or a precision on a number.
+func compileParts(format string, tags map[string]string) ([]patPart, bool) {
+	var parts []patPart
+	lit := ""
+	for i, part := range strings.Split(format, "%") {
+		if i == 0 {
+			lit = part
+			continue
+		}
+		p := patPart{prec: -1}
+		if num := prefixRegexp.FindString(part); num != "" {
+			if num[0] == '+' || num[0] == '0' {
+				return nil, false
+			}
+			part = part[len(num):]
+			if num[0] == '-' {
+				p.left, num = true, num[1:]
+			}
+			width, prec, hasPrec := num, "", false
+			for j := 0; j < len(num); j++ {
+				if num[j] == '.' {
+					width, prec, hasPrec = num[:j], num[j+1:], true
+				}
+			}
+			if width != "" && width[0] == '0' {
+				return nil, false
+			}
+			p.width, _ = strconv.Atoi(width)
+			if hasPrec {
+				p.prec, _ = strconv.Atoi(prec)
+			}
+		}
+		if strings.HasPrefix(part, "H") {
+			p.dyn, p.brace, p.lit = 'Y', hostnameSpec(tags), lit
+			parts = append(parts, p)
+			lit = part[1:]
+			continue
+		}
+		if ts, n, ok := braceDirective(part, tags); ok {
+			p.dyn, p.brace, p.lit = 'Y', ts, lit
+			parts = append(parts, p)
+			lit = part[n:]
+			continue
+		}
+		switch dyn := part[0]; dyn {
+		case 'T', 't', 'D', 'd', 'L', 'S', 's', 'x', 'M', 'P', 'p', 'F', 'f', 'r', 'n', 'G', 'E', 'c', 'K':
+			p.dyn = dyn
+		case 'C', 'N', 'l':
+			if p.prec >= 0 {
+				return nil, false
+			}
+			p.dyn = dyn
+		case '%':
+			return nil, false
+		default:
+			// an unknown directive is dropped, its text kept
+			lit += part
+			continue
+		}
+		p.lit = lit
+		parts = append(parts, p)
+		lit = part[1:]
+	}
+	if lit != "" {
+		parts = append(parts, patPart{lit: lit})
+	}
+	return parts, true
+}
+
+// Renders rec onto buf with the compiled parts, the same output as the
+// Sprintf format without boxing every value
+func (pf *PatFormatter) appendParts(buf []byte, rec *LogRecord) []byte {
+	var tm time.Time
+	for _, p := range pf.parts {
+		buf = append(buf, p.lit...)
+		switch p.dyn {
+		case 'T', 't', 'D', 'd':
+			if tm.IsZero() {
+				tm = truncateTime(rec.Timestamp)
+			}
+			// a width pads an empty string in front, like the Sprintf format
+			for i := 0; i < p.width; i++ {
+				buf = append(buf, ' ')
+			}
+			buf = appendPatTime(buf, p.dyn, tm)
+		case 'L':
+			buf = p.appendString(buf, LevelStrings[rec.Level])
+		case 'S':
+			if rec.SourceFile == "" {
+				buf = p.appendString(buf, unknownSource)
+			} else if p.width == 0 && p.prec < 0 {
+				buf = strconv.AppendInt(append(append(buf, rec.SourceFile...), ':'), int64(rec.SourceLine), 10)
+			} else {
+				buf = p.appendString(buf, parseSourceLong(rec.SourceFile, rec.SourceLine))
+			}
+		case 's':
+			if rec.SourceFile == "" {
+				buf = p.appendString(buf, unknownSource)
+			} else if p.width == 0 && p.prec < 0 {
+				file := rec.SourceFile[strings.LastIndex(rec.SourceFile, "/")+1:]
+				buf = strconv.AppendInt(append(append(buf, file...), ':'), int64(rec.SourceLine), 10)
+			} else {
+				buf = p.appendString(buf, parseSourceShort(rec.SourceFile, rec.SourceLine))
+			}
+		case 'l':
+			buf = p.appendNumber(buf, uint64(rec.SourceLine))
+		case 'x':
+			buf = p.appendString(buf, parseSourceXShort(rec.SourceFile))
+		case 'M':
+			buf = p.appendString(buf, pf.message(rec))
+		case 'P':
+			buf = p.appendString(buf, rec.FuncPath)
+		case 'p':
+			buf = p.appendString(buf, rec.PackagePath)
+		case 'F':
+			buf = p.appendString(buf, funcFull(rec.FuncPath))
+		case 'f':
+			buf = p.appendString(buf, funcShort(rec.FuncPath))
+		case 'r':
+			buf = p.appendString(buf, pf.fieldString(rec.Fields, TraceIDField))
+		case 'n':
+			buf = p.appendString(buf, pf.fieldString(rec.Fields, SpanIDField))
+		case 'C':
+			buf = p.appendNumber(buf, rec.LevelCount)
+		case 'N':
+			buf = p.appendNumber(buf, uint64(rec.Monotonic))
+		case 'G':
+			buf = p.appendString(buf, rec.LoggerTag)
+		case 'E':
+			buf = p.appendString(buf, formatDelta(rec.Delta))
+		case 'c':
+			buf = p.appendString(buf, rec.LoggerName)
+		case 'K':
+			buf = p.appendString(buf, pf.fieldString(rec.Fields, StackField))
+		case 'Y':
+			if tm.IsZero() {
+				tm = truncateTime(rec.Timestamp)
+			}
+			if p.width == 0 && p.prec < 0 {
+				buf = pf.appendBrace(buf, p.brace, rec, tm)
+			} else {
+				buf = p.appendString(buf, string(pf.appendBrace(nil, p.brace, rec, tm)))
+			}
+		}
+	}
+	return buf
+}
+
+// %s with the part's width and precision, counted in runes as fmt does
+func (p patPart) appendString(buf []byte, s string) []byte {
+	if p.prec >= 0 && utf8.RuneCountInString(s) > p.prec {
+		n := 0
+		for i := range s {
+			if n == p.prec {
+				s = s[:i]
+				break
+			}
+			n++
+		}
+	}
+	pad := p.width - utf8.RuneCountInString(s)
+	if !p.left {
+		buf = appendSpaces(buf, pad)
+	}
+	buf = append(buf, s...)
+	if p.left {
+		buf = appendSpaces(buf, pad)
+	}
+	return buf
+}
+
+// %d with the part's width
+func (p patPart) appendNumber(buf []byte, n uint64) []byte {
+	var digits [20]byte
+	num := strconv.AppendUint(digits[:0], n, 10)
+	if !p.left {
+		buf = appendSpaces(buf, p.width-len(num))
+	}
+	buf = append(buf, num...)
+	if p.left {
+		buf = appendSpaces(buf, p.width-len(num))
+	}
+	return buf
+}
+
+func appendSpaces(buf []byte, n int) []byte {
+	for ; n > 0; n-- {
+		buf = append(buf, ' ')
+	}
+	return buf
+}
+
+func appendPatTime(buf []byte, dyn byte, tm time.Time) []byte {
+	switch dyn {
+	case 'T', 't':
+		buf = appendTwoDigits(buf, tm.Hour())
+		buf = appendTwoDigits(append(buf, ':'), tm.Minute())
+		buf = appendTwoDigits(append(buf, ':'), tm.Second())
+		if dyn == 'T' {
+			ms := tm.Nanosecond() / 1e6
+			buf = append(buf, '.', byte('0'+ms/100), byte('0'+ms/10%10), byte('0'+ms%10))
+		}
+	case 'D', 'd':
+		sep := byte('-')
+		if dyn == 'd' {
+			sep = '/'
+		}
+		buf = strconv.AppendInt(buf, int64(tm.Year()), 10)
+		buf = appendTwoDigits(append(buf, sep), int(tm.Month()))
+		buf = appendTwoDigits(append(buf, sep), tm.Day())
+	}
+	return buf
+}
+
+func appendTwoDigits(buf []byte, n int) []byte {
+	return append(buf, byte('0'+n/10), byte('0'+n%10))
+}
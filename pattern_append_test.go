@@ -0,0 +1,44 @@
+package timber
+
+import (
+	"strings"
+	"testing"
+)
+
+// The append path has to match the Sprintf format it replaces
+func TestAppendPartsMatchSprintf(t *testing.T) {
+	rec := *lr
+	rec.LoggerName, rec.Message = "myapp.db", "héllo wörld"
+	rec.Fields = Fields{TraceIDField: "4bf92f35", StackField: "main.main()\n\tmain.go:3"}
+	for _, pattern := range []string{
+		"[%D %T] [%L] %-10x %M",
+		"short:[%d %t] good:[%D %T] levelPadded:[%-10L] long:%S short:%s xs:%10x Msg:%M Fnc:%P Pkg:%p",
+		"%r/%n %C %N %-8C|%12N %G %E %c %K",
+		"%l %-4l| %F %-6f|%.3F",
+		"%-20.20s|%.3M|%8.4P|%-6.2p|%5|%z %q",
+		"%10T %-3D %.2t",
+		"no directives at all",
+		"%M",
+		"",
+	} {
+		pf := NewPatFormatter(pattern)
+		if !pf.direct {
+			t.Errorf("expected the append path for %q", pattern)
+			continue
+		}
+		fast := pf.Format(&rec)
+		pf.direct = false
+		if slow := pf.Format(&rec); fast != slow {
+			t.Errorf("for %q expected %q got %q", pattern, slow, fast)
+		}
+	}
+	for _, pattern := range []string{"%+5M", "%05C", "%-05L", "%.3C", "%.3l"} {
+		if NewPatFormatter(pattern).direct {
+			t.Errorf("expected Sprintf for %q", pattern)
+		}
+	}
+	rec.SourceFile = ""
+	if got := NewPatFormatter("%S %s %x").Format(&rec); got != strings.TrimSpace(strings.Repeat(unknownSource+" ", 3)) {
+		t.Errorf("unexpected unknown source %q", got)
+	}
+}
@@ -0,0 +1,149 @@
+package timber
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Looked up once, for %H, %{hostname}, %{pid} and %{app}
+var (
+	processHostname = hostname()
+	processPID      = strconv.Itoa(os.Getpid())
+	processApp      = filepath.Base(os.Args[0])
+)
+
+func hostname() string {
+	if name, err := os.Hostname(); err == nil && name != "" {
+		return name
+	}
+	return "unknown"
+}
+
+// Layouts that %{time:name} and %{utc:name} take by name
+var timeLayouts = map[string]string{
+	"iso8601":      "2006-01-02T15:04:05.000Z07:00",
+	"iso8601micro": "2006-01-02T15:04:05.000000Z07:00",
+	"rfc3339":      time.RFC3339,
+	"rfc3339nano":  time.RFC3339Nano,
+}
+
+var timeUnits = map[string]time.Duration{"unix": time.Second, "unixms": time.Millisecond,
+	"unixus": time.Microsecond, "unixnano": time.Nanosecond}
+
+// A %{...} directive of a pattern: a field of the record, the timestamp
+// in a Go layout or as a count since the Unix epoch, or text that's the
+// same for every record
+type braceSpec struct {
+	field  string // for a field, empty for the time ones
+	layout string
+	utc    bool
+	unit   time.Duration // for the unix ones, zero for a layout
+	static bool          // text is all there is to it
+	text   string
+}
+
+func staticSpec(text string) braceSpec {
+	return braceSpec{static: true, text: text}
+}
+
+// Parses what's between the braces:
+//
+//	time            ISO 8601 with milliseconds, local time
+//	time:LAYOUT     a Go layout like 15:04:05.000000 or one of timeLayouts
+//	utc, utc:LAYOUT the same in UTC
+//	unix, unixms, unixus, unixnano   seconds, milliseconds, microseconds
+//	                or nanoseconds since the epoch
+//	trace_id, span_id   the TraceIDField and SpanIDField, like %r and %n
+//	field:NAME      any field, empty if the record doesn't have it
+//	mdc:NAME        the same, for a field set with SetMDC
+//	hostname, pid, app  the machine's name, the process id and the
+//	                program name; a hostname or app tag replaces them
+//	tag:NAME        the formatter's static tag, empty if it isn't set
+//	NAME            the same for a tag that's set
+func parseBraceSpec(spec string, tags map[string]string) (braceSpec, bool) {
+	name, layout, hasLayout := strings.Cut(spec, ":")
+	switch name {
+	case "time", "utc":
+		if !hasLayout || layout == "" {
+			layout = "iso8601"
+		}
+		if named, ok := timeLayouts[layout]; ok {
+			layout = named
+		}
+		return braceSpec{layout: layout, utc: name == "utc"}, true
+	case TraceIDField, SpanIDField:
+		if !hasLayout {
+			return braceSpec{field: name}, true
+		}
+	case "field", "mdc":
+		if layout != "" {
+			return braceSpec{field: layout}, true
+		}
+	case "hostname", "app":
+		if hasLayout {
+			break
+		}
+		if tag, ok := tags[name]; ok {
+			return staticSpec(tag), true
+		}
+		if name == "hostname" {
+			return staticSpec(processHostname), true
+		}
+		return staticSpec(processApp), true
+	case "pid":
+		if !hasLayout {
+			return staticSpec(processPID), true
+		}
+	case "tag":
+		if layout != "" {
+			return staticSpec(tags[layout]), true
+		}
+	}
+	if unit, ok := timeUnits[name]; ok && !hasLayout {
+		return braceSpec{unit: unit}, true
+	}
+	if tag, ok := tags[spec]; ok {
+		return staticSpec(tag), true
+	}
+	return braceSpec{}, false
+}
+
+// What %H writes, the same as %{hostname}
+func hostnameSpec(tags map[string]string) braceSpec {
+	ts, _ := parseBraceSpec("hostname", tags)
+	return ts
+}
+
+// The directive at the start of part if it's a {...} one, with the
+// length of it including the braces
+func braceDirective(part string, tags map[string]string) (braceSpec, int, bool) {
+	if !strings.HasPrefix(part, "{") {
+		return braceSpec{}, 0, false
+	}
+	end := strings.IndexByte(part, '}')
+	if end < 0 {
+		return braceSpec{}, 0, false
+	}
+	ts, ok := parseBraceSpec(part[1:end], tags)
+	return ts, end + 1, ok
+}
+
+// Renders the directive for rec onto buf, tm being its truncated timestamp
+func (pf *PatFormatter) appendBrace(buf []byte, bs braceSpec, rec *LogRecord, tm time.Time) []byte {
+	if bs.static {
+		return append(buf, bs.text...)
+	}
+	if bs.field != "" {
+		return append(buf, pf.fieldString(rec.Fields, bs.field)...)
+	}
+	if bs.unit > 0 {
+		return strconv.AppendInt(buf, tm.UnixNano()/int64(bs.unit), 10)
+	}
+	if bs.utc {
+		tm = tm.UTC()
+	}
+	return tm.AppendFormat(buf, bs.layout)
+}
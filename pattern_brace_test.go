@@ -0,0 +1,86 @@
+package timber
+
+import (
+	"testing"
+)
+
+func TestTimeDirectives(t *testing.T) {
+	for _, tt := range []struct{ in, out string }{
+		{"%{time}", "2011-10-20T15:39:07.383-07:00"},
+		{"%{time:iso8601micro}", "2011-10-20T15:39:07.383485-07:00"},
+		{"%{time:15:04:05.000000}", "15:39:07.383485"},
+		{"%{utc}", "2011-10-20T22:39:07.383Z"},
+		{"%{utc:rfc3339}", "2011-10-20T22:39:07Z"},
+		{"%{utc:2006-01-02 15:04:05.000}", "2011-10-20 22:39:07.383"},
+		{"%{unix}", "1319150347"},
+		{"%{unixms}", "1319150347383"},
+		{"%{unixus}", "1319150347383485"},
+		{"%{unixnano}", "1319150347383485000"},
+		{"[%{unixms}] %L %M", "[1319150347383] INFO hellooooo nurse!"},
+		{"%15{unix}|%-5.4{utc:2006}|%{unix}s", "     1319150347|2011 |1319150347s"},
+		// not directives, the text stays
+		{"%{bogus} %{unix:2006} %{time", "{bogus} {unix:2006} {time"},
+	} {
+		pf := NewPatFormatter(tt.in)
+		if got := pf.Format(lr); got != tt.out {
+			t.Errorf("for %q expected %q got %q", tt.in, tt.out, got)
+		}
+		pf.direct = false
+		if got := pf.Format(lr); got != tt.out {
+			t.Errorf("Sprintf for %q expected %q got %q", tt.in, tt.out, got)
+		}
+	}
+}
+
+func TestFieldDirectives(t *testing.T) {
+	rec := *lr
+	rec.Fields = Fields{TraceIDField: "4bf92f35", SpanIDField: "00f067aa", "user": 42}
+	for _, tt := range []struct{ in, out string }{
+		{"%{trace_id} %{span_id}", "4bf92f35 00f067aa"},
+		{"%{field:user}|%{field:missing}|%-6{field:user}|", "42||42    |"},
+		{"%{field:} %{trace_id:x}", "{field:} {trace_id:x}"},
+	} {
+		pf := NewPatFormatter(tt.in)
+		if got := pf.Format(&rec); got != tt.out {
+			t.Errorf("for %q expected %q got %q", tt.in, tt.out, got)
+		}
+		pf.direct = false
+		if got := pf.Format(&rec); got != tt.out {
+			t.Errorf("Sprintf for %q expected %q got %q", tt.in, tt.out, got)
+		}
+	}
+}
+
+func TestStaticDirectives(t *testing.T) {
+	host, pid := processHostname, processPID
+	for _, tt := range []struct {
+		in, out string
+		tags    map[string]string
+	}{
+		{"%H %{pid} %M", host + " " + pid + " hellooooo nurse!", nil},
+		{"[%{env}] [%{tag:region}] %{bogus}", "[prod] [] {bogus}", map[string]string{"env": "prod"}},
+		{"%-8{env}|%{app}|%H", "prod    |billing|box-1", map[string]string{"env": "prod", "app": "billing", "hostname": "box-1"}},
+		{"%{app}", processApp, nil},
+	} {
+		pf := NewPatFormatter(tt.in)
+		if tt.tags != nil {
+			pf.SetTags(tt.tags)
+		}
+		if got := pf.Format(lr); got != tt.out {
+			t.Errorf("for %q expected %q got %q", tt.in, tt.out, got)
+		}
+		pf.direct = false
+		if got := pf.Format(lr); got != tt.out {
+			t.Errorf("Sprintf for %q expected %q got %q", tt.in, tt.out, got)
+		}
+	}
+}
+
+func TestConfigStaticTags(t *testing.T) {
+	cl, _, err := buildLogger(nil, JSONFilter{Enabled: true, Type: "null", Format: JSONProperty{"pattern", "%{app} %{env} %M"},
+		Properties: []JSONProperty{{"tag_env", "staging"}, {"app", "api"}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	verify(t, "config tags", cl.Formatter.Format(lr), "api staging hellooooo nurse!")
+}
@@ -3,23 +3,89 @@ package timber
 import (
 	"bytes"
 	"fmt"
+	"io"
 	"regexp"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 )
 
-var prefixRegexp = regexp.MustCompile(`^[\-+]?[0-9]+`)
+// printf style width and precision, e.g. -10 or 8.3
+var prefixRegexp = regexp.MustCompile(`^[\-+]?([0-9]+(\.[0-9]+)?|\.[0-9]+)`)
+
+// in nanoseconds, accessed atomically; see SetTimePrecision
+var timePrecision int64
+
+// Truncates every rendered timestamp to a multiple of d, e.g. time.Second
+// or time.Millisecond.  This applies to all the time and date directives
+// of every formatter.  Zero or less turns truncation off (the default).
+func SetTimePrecision(d time.Duration) {
+	atomic.StoreInt64(&timePrecision, int64(d))
+}
+
+func truncateTime(t time.Time) time.Time {
+	if d := time.Duration(atomic.LoadInt64(&timePrecision)); d > 0 {
+		return t.Truncate(d)
+	}
+	return t
+}
+
+// Scratch space for a single Format call.  These are pooled so the hot
+// path doesn't allocate a new buffer and argument slice for every record.
+type patBuffer struct {
+	buf  bytes.Buffer
+	args []interface{}
+}
+
+var patBufferPool = sync.Pool{
+	New: func() interface{} {
+		return &patBuffer{args: make([]interface{}, 0, 16)}
+	},
+}
+
+func getPatBuffer() *patBuffer {
+	return patBufferPool.Get().(*patBuffer)
+}
+
+func putPatBuffer(pb *patBuffer) {
+	pb.buf.Reset()
+	for i := range pb.args {
+		pb.args[i] = nil // don't hang on to old records
+	}
+	pb.args = pb.args[:0]
+	patBufferPool.Put(pb)
+}
 
 type PatFormatter struct {
-	format        string
-	formatCompile string
-	formatDynamic []byte
+	format         string
+	formatCompile  string
+	formatDynamic  []byte
+	escape         bool
+	durationFormat DurationFormat
+	// set when the only directive is an unpadded %M, then the message is
+	// written between these without going through Sprintf
+	msgOnly              bool
+	msgPrefix, msgSuffix string
+	// the pattern for appendParts, unless direct is false and only the
+	// Sprintf format renders it right
+	parts  []patPart
+	direct bool
+	// the %{...} directives in order, one for each Y in formatDynamic
+	braceSpecs []braceSpec
+	// static tags for %{NAME}, see SetTags
+	tags map[string]string
 }
 
-// Split a full package.function into just the package component.
+// Split a full package.function into just the package component.  The
+// package ends at the first dot after the last slash so dots in the
+// import path (github.com) and methods (pkg.(*T).Method) are handled.
 func splitPackage(pkg string) string {
-	split := strings.Split(pkg, ".")
-	return strings.Join(split[:len(split)-1], "")
+	lastSlash := strings.LastIndex(pkg, "/")
+	if dot := strings.Index(pkg[lastSlash+1:], "."); dot >= 0 {
+		return pkg[:lastSlash+1+dot]
+	}
+	return pkg
 }
 
 // Format codes:
@@ -30,18 +96,90 @@ func splitPackage(pkg string) string {
 //   %L - Level (FNST, FINE, DEBG, TRAC, WARN, EROR, CRIT)
 //   %S - Source: full runtime.Caller line
 //   %s - Short Source: just file and line number
+//   %l - Line: just the line number, 0 if unknown
 //   %x - Extra Short Source: just file without .go suffix
 //   %M - Message
 //   %% - Percent sign
 // 	 %P - Caller Path: package path + calling function name
 // 	 %p - Caller Path: package path
+//   %F - Function: full function name, e.g. github.com/me/app.(*T).Method
+//   %f - Short Function: just the function name, e.g. Method
+//   %r - Trace ID: the TraceIDField of the record, empty if not set
+//   %n - Span ID: the SpanIDField of the record, empty if not set
+//   %C - Count: records logged at this level so far, see ResetLevelCounts
+//   %N - Order: monotonic nanoseconds since the process started, unique per record
+//   %G - Logger: the Tag of the logger writing the record
+//   %E - Elapsed: time since the previous record, e.g. +12.3ms; see SetDeltaScope
+//   %c - Category: the name of the NamedLogger, empty for the other log calls
+//   %K - Stack: the StackField of the record from ErrorWithStack or RecoverAndLog, empty if not set
+//   %{time} - Timestamp: ISO 8601 with milliseconds, 2011-12-25T17:24:05.333-08:00
+//   %{time:layout} - Timestamp in a Go time layout, e.g. %{time:15:04:05.000000}, or one of
+//                    iso8601, iso8601micro, rfc3339 and rfc3339nano
+//   %{utc} and %{utc:layout} - the same in UTC
+//   %{unix}, %{unixms}, %{unixus}, %{unixnano} - Epoch: seconds, ms, µs or ns since 1970
+//   %{trace_id}, %{span_id} - the same as %r and %n
+//   %{field:name} - Field: the named field of the record, empty if not set
+//   %{mdc:name} - MDC: the same for a field from SetMDC or PushMDC
+//   %H or %{hostname} - Hostname: the machine's name, looked up once
+//   %{pid} - Process ID
+//   %{app} - Application: the program name, or the app tag
+//   %{name} or %{tag:name} - Tag: a static tag from SetTags, e.g. %{env}
 // the string number prefixes are allowed e.g.: %10s will pad the source field to 10 spaces
+// and a minus left justifies like printf, so %-7L keeps the messages after the level in a column;
+// a precision cuts strings down, %-20.20s is always 20 wide
+// The output has no trailing newline, the writers end the line; see LogWriter
 func NewPatFormatter(format string) *PatFormatter {
 	pf := new(PatFormatter)
 	pf.format = format
+	pf.compile()
+	return pf
+}
+
+func (pf *PatFormatter) compile() {
 	pf.formatDynamic = make([]byte, 0, 9)            // there are only 9 format codes so this is probably enough
+	pf.braceSpecs = nil
 	pf.formatCompile = string(pf.compileForLevel(0)) // TODO figure out if I really want to cache each level
-	return pf
+	pf.msgOnly = false
+	if string(pf.formatDynamic) == "M" {
+		parts := strings.Split(pf.formatCompile, "%s")
+		if len(parts) == 2 && !strings.Contains(pf.formatCompile, "%%") &&
+			strings.Count(pf.formatCompile, "%") == 1 {
+			pf.msgOnly, pf.msgPrefix, pf.msgSuffix = true, parts[0], parts[1]
+		}
+	}
+	pf.parts, pf.direct = compileParts(pf.format, pf.tags)
+}
+
+// Sets the static tags %{name} and %{tag:name} write, e.g. env=prod or
+// region=us-east-1, worked into the pattern once so they cost no more
+// than literal text.  Tags named hostname and app replace what %H,
+// %{hostname} and %{app} would write.  Call it before the formatter is
+// used.
+func (pf *PatFormatter) SetTags(tags map[string]string) {
+	pf.tags = make(map[string]string, len(tags))
+	for name, value := range tags {
+		pf.tags[name] = value
+	}
+	pf.compile()
+}
+
+// SourceUser interface, true for a pattern with %S, %s, %l, %x, %P, %p,
+// %F or %f
+func (pf *PatFormatter) UsesSource() bool {
+	return bytes.ContainsAny(pf.formatDynamic, "SslxPpFf")
+}
+
+// Escapes control characters in the message (newline as \n, tab as \t,
+// others as \xNN) so every record stays on one line.  Call it before the
+// formatter is used.
+func (pf *PatFormatter) SetEscape(escape bool) {
+	pf.escape = escape
+}
+
+// Sets how time.Duration fields are rendered, see DurationFormat.  Call it
+// before the formatter is used.
+func (pf *PatFormatter) SetDurationFormat(df DurationFormat) {
+	pf.durationFormat = df
 }
 
 func (pf *PatFormatter) precompileLevels() {
@@ -135,6 +273,14 @@ func (pf *PatFormatter) compileForLevel(level int) []byte {
 			sprintfFmt = append(sprintfFmt, 's')
 			sprintfFmt = append(sprintfFmt, fmt_str[1:]...)
 			pf.formatDynamic = append(pf.formatDynamic, 's')
+		case 'l':
+			sprintfFmt = append(sprintfFmt, '%')
+			if num != nil {
+				sprintfFmt = append(sprintfFmt, num...)
+			}
+			sprintfFmt = append(sprintfFmt, 'd')
+			sprintfFmt = append(sprintfFmt, fmt_str[1:]...)
+			pf.formatDynamic = append(pf.formatDynamic, 'l')
 		case 'x':
 			sprintfFmt = append(sprintfFmt, '%')
 			if num != nil {
@@ -170,76 +316,292 @@ func (pf *PatFormatter) compileForLevel(level int) []byte {
 			sprintfFmt = append(sprintfFmt, 's')
 			sprintfFmt = append(sprintfFmt, fmt_str[1:]...)
 			pf.formatDynamic = append(pf.formatDynamic, 'p')
+		case 'F':
+			sprintfFmt = append(sprintfFmt, '%')
+			if num != nil {
+				sprintfFmt = append(sprintfFmt, num...)
+			}
+			sprintfFmt = append(sprintfFmt, 's')
+			sprintfFmt = append(sprintfFmt, fmt_str[1:]...)
+			pf.formatDynamic = append(pf.formatDynamic, 'F')
+		case 'f':
+			sprintfFmt = append(sprintfFmt, '%')
+			if num != nil {
+				sprintfFmt = append(sprintfFmt, num...)
+			}
+			sprintfFmt = append(sprintfFmt, 's')
+			sprintfFmt = append(sprintfFmt, fmt_str[1:]...)
+			pf.formatDynamic = append(pf.formatDynamic, 'f')
+		case 'r':
+			sprintfFmt = append(sprintfFmt, '%')
+			if num != nil {
+				sprintfFmt = append(sprintfFmt, num...)
+			}
+			sprintfFmt = append(sprintfFmt, 's')
+			sprintfFmt = append(sprintfFmt, fmt_str[1:]...)
+			pf.formatDynamic = append(pf.formatDynamic, 'r')
+		case 'n':
+			sprintfFmt = append(sprintfFmt, '%')
+			if num != nil {
+				sprintfFmt = append(sprintfFmt, num...)
+			}
+			sprintfFmt = append(sprintfFmt, 's')
+			sprintfFmt = append(sprintfFmt, fmt_str[1:]...)
+			pf.formatDynamic = append(pf.formatDynamic, 'n')
+		case 'C':
+			sprintfFmt = append(sprintfFmt, '%')
+			if num != nil {
+				sprintfFmt = append(sprintfFmt, num...)
+			}
+			sprintfFmt = append(sprintfFmt, 'd')
+			sprintfFmt = append(sprintfFmt, fmt_str[1:]...)
+			pf.formatDynamic = append(pf.formatDynamic, 'C')
+		case 'N':
+			sprintfFmt = append(sprintfFmt, '%')
+			if num != nil {
+				sprintfFmt = append(sprintfFmt, num...)
+			}
+			sprintfFmt = append(sprintfFmt, 'd')
+			sprintfFmt = append(sprintfFmt, fmt_str[1:]...)
+			pf.formatDynamic = append(pf.formatDynamic, 'N')
+		case 'G':
+			sprintfFmt = append(sprintfFmt, '%')
+			if num != nil {
+				sprintfFmt = append(sprintfFmt, num...)
+			}
+			sprintfFmt = append(sprintfFmt, 's')
+			sprintfFmt = append(sprintfFmt, fmt_str[1:]...)
+			pf.formatDynamic = append(pf.formatDynamic, 'G')
+		case 'E':
+			sprintfFmt = append(sprintfFmt, '%')
+			if num != nil {
+				sprintfFmt = append(sprintfFmt, num...)
+			}
+			sprintfFmt = append(sprintfFmt, 's')
+			sprintfFmt = append(sprintfFmt, fmt_str[1:]...)
+			pf.formatDynamic = append(pf.formatDynamic, 'E')
+		case 'c':
+			sprintfFmt = append(sprintfFmt, '%')
+			if num != nil {
+				sprintfFmt = append(sprintfFmt, num...)
+			}
+			sprintfFmt = append(sprintfFmt, 's')
+			sprintfFmt = append(sprintfFmt, fmt_str[1:]...)
+			pf.formatDynamic = append(pf.formatDynamic, 'c')
+		case 'K':
+			sprintfFmt = append(sprintfFmt, '%')
+			if num != nil {
+				sprintfFmt = append(sprintfFmt, num...)
+			}
+			sprintfFmt = append(sprintfFmt, 's')
+			sprintfFmt = append(sprintfFmt, fmt_str[1:]...)
+			pf.formatDynamic = append(pf.formatDynamic, 'K')
+		case '{', 'H':
+			ts, n, ok := hostnameSpec(pf.tags), 1, true
+			if fmt_str[0] == '{' {
+				ts, n, ok = braceDirective(string(fmt_str), pf.tags)
+			}
+			if !ok {
+				sprintfFmt = append(sprintfFmt, fmt_str...)
+				break
+			}
+			sprintfFmt = append(sprintfFmt, '%')
+			if num != nil {
+				sprintfFmt = append(sprintfFmt, num...)
+			}
+			sprintfFmt = append(sprintfFmt, 's')
+			sprintfFmt = append(sprintfFmt, fmt_str[n:]...)
+			pf.formatDynamic = append(pf.formatDynamic, 'Y')
+			pf.braceSpecs = append(pf.braceSpecs, ts)
 		default:
 			sprintfFmt = append(sprintfFmt, fmt_str...)
 		} // end switch
 
 	} // end for
-	sprintfFmt = append(sprintfFmt, '\n')
 	//fmt.Printf("%s", string(sprintfFmt))
 	return sprintfFmt
 }
 
 // LogFormatter interface
 func (pf *PatFormatter) Format(rec *LogRecord) string {
-	data := pf.getDynamic(rec)
-	//fmt.Printf("%v", data)
-	return fmt.Sprintf(pf.formatCompile, data...)
+	if pf.msgOnly && pf.msgPrefix == "" && pf.msgSuffix == "" {
+		return pf.message(rec)
+	}
+	pb := getPatBuffer()
+	pf.render(pb, rec)
+	msg := pb.buf.String()
+	putPatBuffer(pb)
+	return msg
+}
+
+// LogFormatterTo interface
+func (pf *PatFormatter) FormatTo(w io.Writer, rec *LogRecord) (int, error) {
+	pb := getPatBuffer()
+	pf.render(pb, rec)
+	n, err := w.Write(pb.buf.Bytes())
+	putPatBuffer(pb)
+	return n, err
 }
 
-func (pf *PatFormatter) getDynamic(rec *LogRecord) []interface{} {
-	tm := rec.Timestamp
-	ret := make([]interface{}, 0, 10)
+func (pf *PatFormatter) render(pb *patBuffer, rec *LogRecord) {
+	if pf.msgOnly {
+		// boxing the message for Sprintf would allocate
+		pb.buf.WriteString(pf.msgPrefix)
+		pb.buf.WriteString(pf.message(rec))
+		pb.buf.WriteString(pf.msgSuffix)
+		return
+	}
+	if pf.direct {
+		pb.buf.Write(pf.appendParts(pb.buf.AvailableBuffer(), rec))
+		return
+	}
+	pb.args = pf.getDynamic(rec, pb.args)
+	fmt.Fprintf(&pb.buf, pf.formatCompile, pb.args...)
+}
+
+func (pf *PatFormatter) message(rec *LogRecord) string {
+	if pf.escape {
+		return escapeControl(rec.Message)
+	}
+	return rec.Message
+}
+
+// appends the values for the compiled format to ret
+func (pf *PatFormatter) getDynamic(rec *LogRecord, ret []interface{}) []interface{} {
+	tm := truncateTime(rec.Timestamp)
+	specs := pf.braceSpecs
 	for _, dyn := range pf.formatDynamic {
 		switch dyn {
 		case 'e':
 			ret = append(ret, "")
 		case 'T':
-			ret = append(ret, parseTimeMs(tm)...)
+			ret = append(ret, tm.Hour(), tm.Minute(), tm.Second(), tm.Nanosecond()/1e6)
 		case 't':
-			ret = append(ret, parseTime(tm)...)
+			ret = append(ret, tm.Hour(), tm.Minute(), tm.Second())
 		case 'D', 'd':
-			ret = append(ret, parseDate(tm)...)
+			ret = append(ret, tm.Year(), tm.Month(), tm.Day())
 		case 'L':
 			ret = append(ret, LevelStrings[rec.Level])
 		case 'S':
 			ret = append(ret, parseSourceLong(rec.SourceFile, rec.SourceLine))
 		case 's':
 			ret = append(ret, parseSourceShort(rec.SourceFile, rec.SourceLine))
+		case 'l':
+			ret = append(ret, rec.SourceLine)
 		case 'x':
 			ret = append(ret, parseSourceXShort(rec.SourceFile))
 		case 'M':
-			ret = append(ret, rec.Message)
+			ret = append(ret, pf.message(rec))
 		case 'P':
 			ret = append(ret, rec.FuncPath)
 		case 'p':
 			ret = append(ret, rec.PackagePath)
+		case 'F':
+			ret = append(ret, funcFull(rec.FuncPath))
+		case 'f':
+			ret = append(ret, funcShort(rec.FuncPath))
+		case 'r':
+			ret = append(ret, pf.fieldString(rec.Fields, TraceIDField))
+		case 'n':
+			ret = append(ret, pf.fieldString(rec.Fields, SpanIDField))
+		case 'C':
+			ret = append(ret, rec.LevelCount)
+		case 'N':
+			ret = append(ret, rec.Monotonic)
+		case 'G':
+			ret = append(ret, rec.LoggerTag)
+		case 'E':
+			ret = append(ret, formatDelta(rec.Delta))
+		case 'c':
+			ret = append(ret, rec.LoggerName)
+		case 'K':
+			ret = append(ret, pf.fieldString(rec.Fields, StackField))
+		case 'Y':
+			ret = append(ret, string(pf.appendBrace(nil, specs[0], rec, tm)))
+			specs = specs[1:]
 		}
 	}
 	return ret
 }
 
-func parseSourceLong(file string, line int) string {
-	return fmt.Sprintf("%s:%d", file, line)
+// Replaces the control characters in s with escapes, s comes back as is
+// when there aren't any
+func escapeControl(s string) string {
+	i := strings.IndexFunc(s, isControl)
+	if i < 0 {
+		return s
+	}
+	var b strings.Builder
+	b.Grow(len(s) + 8)
+	b.WriteString(s[:i])
+	for _, r := range s[i:] {
+		switch {
+		case r == '\n':
+			b.WriteString(`\n`)
+		case r == '\r':
+			b.WriteString(`\r`)
+		case r == '\t':
+			b.WriteString(`\t`)
+		case isControl(r):
+			fmt.Fprintf(&b, `\x%02x`, r)
+		default:
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
 }
 
-func parseSourceShort(file string, line int) string {
-	just_file := file[strings.LastIndex(file, "/")+1:]
-	return fmt.Sprintf("%s:%d", just_file, line)
+func isControl(r rune) bool {
+	return r < 0x20 || r == 0x7f
 }
 
-func parseSourceXShort(file string) string {
-	return file[strings.LastIndex(file, "/")+1 : (len(file) - 3)]
+// renders a field as a string, empty if missing
+func (pf *PatFormatter) fieldString(fields Fields, key string) string {
+	val, ok := fields[key]
+	if !ok || val == nil {
+		return ""
+	}
+	if str, ok := val.(string); ok {
+		return str
+	}
+	return fmt.Sprint(pf.durationFormat.fieldValue(val))
 }
 
-func parseDate(t time.Time) []interface{} {
-	return []interface{}{t.Year(), t.Month(), t.Day()}
+// what the source directives render when the caller isn't known
+const unknownSource = "???"
+
+// the function name from runtime.FuncForPC, ??? if there wasn't one
+func funcFull(funcPath string) string {
+	if funcPath == "" || funcPath == "_" {
+		return unknownSource
+	}
+	return funcPath
 }
 
-func parseTime(t time.Time) []interface{} {
-	return []interface{}{t.Hour(), t.Minute(), t.Second()}
+// the function name without its package and receiver
+func funcShort(funcPath string) string {
+	full := funcFull(funcPath)
+	return full[strings.LastIndex(full, ".")+1:]
+}
+
+func parseSourceLong(file string, line int) string {
+	if file == "" {
+		return unknownSource
+	}
+	return fmt.Sprintf("%s:%d", file, line)
 }
 
-func parseTimeMs(t time.Time) []interface{} {
-	return []interface{}{t.Hour(), t.Minute(), t.Second(), t.Nanosecond() / 1e6}
+func parseSourceShort(file string, line int) string {
+	if file == "" {
+		return unknownSource
+	}
+	just_file := file[strings.LastIndex(file, "/")+1:]
+	return fmt.Sprintf("%s:%d", just_file, line)
+}
+
+func parseSourceXShort(file string) string {
+	if file == "" {
+		return unknownSource
+	}
+	return strings.TrimSuffix(file[strings.LastIndex(file, "/")+1:], ".go")
 }
@@ -1,7 +1,9 @@
 package timber
 
 import (
+	"bytes"
 	"fmt"
+	"io"
 	"testing"
 	"time"
 )
@@ -14,24 +16,63 @@ var lr = &LogRecord{
 	Message:     "hellooooo nurse!",
 	FuncPath:    "hi.Zoot",
 	PackagePath: "hi",
+	Fields:      Fields{TraceIDField: "4bf92f35", SpanIDField: "00f067aa"},
+	LevelCount:  1043,
+	Monotonic:   8675309,
+	LoggerTag:   "file",
+	Delta:       12345678,
 }
 
 var optiontests = []struct {
 	in  string
 	out string
 }{
-	{"%T", "15:39:07.383\n"},
-	{"%t", "15:39:07\n"},
-	{"%D", "2011-10-20\n"},
-	{"%d", "2011/10/20\n"},
-	{"%-10L", "INFO      \n"},
-	{"%S", "/blah/der/some_file.go:7\n"},
-	{"%s", "some_file.go:7\n"},
-	{"%x", "some_file\n"},
-	{"%M", "hellooooo nurse!\n"},
-	//{"%%", "%\n"}, // TODO fix
-	{"%P", "hi.Zoot\n"},
-	{"%p", "hi\n"},
+	{"%T", "15:39:07.383"},
+	{"%t", "15:39:07"},
+	{"%D", "2011-10-20"},
+	{"%d", "2011/10/20"},
+	{"%-10L", "INFO      "},
+	{"%S", "/blah/der/some_file.go:7"},
+	{"%s", "some_file.go:7"},
+	{"%l", "7"},
+	{"%4l|", "   7|"},
+	{"%x", "some_file"},
+	{"%M", "hellooooo nurse!"},
+	//{"%%", "%"}, // TODO fix
+	{"%P", "hi.Zoot"},
+	{"%p", "hi"},
+	{"%F", "hi.Zoot"},
+	{"%-6f|", "Zoot  |"},
+	{"%r", "4bf92f35"},
+	{"%n", "00f067aa"},
+	{"%C", "1043"},
+	{"%6C", "  1043"},
+	{"%N", "8675309"},
+	{"%-6G|", "file  |"},
+	{"%E", "+12.3ms"},
+}
+
+func TestPadding(t *testing.T) {
+	pf := NewPatFormatter("[%-7L] [%7L] [%-16s] [%12x] %M")
+	verify(t, "padding", pf.Format(lr), "[INFO   ] [   INFO] [some_file.go:7  ] [   some_file] hellooooo nurse!")
+	pf = NewPatFormatter("%6.2L|")
+	verify(t, "precision", pf.Format(lr), "    IN|")
+}
+
+func TestTraceWithoutSpan(t *testing.T) {
+	noSpan := *lr
+	noSpan.Fields = nil
+	pf := NewPatFormatter("[%r/%n] %M")
+	verify(t, "[%r/%n] %M", pf.Format(&noSpan), "[/] hellooooo nurse!")
+}
+
+func TestFunctionNames(t *testing.T) {
+	pf := NewPatFormatter("%F %f")
+	method := *lr
+	method.FuncPath = "github.com/me/app.(*T).Method"
+	verify(t, "%F %f", pf.Format(&method), "github.com/me/app.(*T).Method Method")
+	method.FuncPath = "_"
+	verify(t, "%F %f", pf.Format(&method), "??? ???")
 }
 
 func verify(t *testing.T, input, output, expected string) {
@@ -50,20 +91,21 @@ func TestOptions(t *testing.T) {
 func TestWorstPatternFormat(t *testing.T) {
 	in := "short:[%d %t] good:[%D %T] levelPadded:[%-10L] long:%S short:%s xs:%10x Msg:%M Fnc:%P Pkg:%p"
 	out := "short:[2011/10/20 15:39:07] good:[2011-10-20 15:39:07.383] levelPadded:[INFO      ] " +
-		"long:/blah/der/some_file.go:7 short:some_file.go:7 xs: some_file Msg:hellooooo nurse! Fnc:hi.Zoot Pkg:hi\n"
+		"long:/blah/der/some_file.go:7 short:some_file.go:7 xs: some_file Msg:hellooooo nurse! Fnc:hi.Zoot Pkg:hi"
 	pf := NewPatFormatter(in)
 	verify(t, in, pf.Format(lr), out)
 }
 
 func TestRealPatternFormat(t *testing.T) {
 	in := "[%D %T] [%L] %-10x %M"
-	out := "[2011-10-20 15:39:07.383] [INFO] some_file  hellooooo nurse!\n"
+	out := "[2011-10-20 15:39:07.383] [INFO] some_file  hellooooo nurse!"
 	pf := NewPatFormatter(in)
 	verify(t, in, pf.Format(lr), out)
 }
 
 func BenchmarkWorstPatternFormat(b *testing.B) {
 	pf := NewPatFormatter("short:[%d %t] good:[%D %T] levelPadded:[%-10L] long:%S short:%s xs:%10x Msg:%M Fnc:%P Pkg:%p")
+	b.ReportAllocs()
 	for i := 0; i < b.N; i++ {
 		pf.Format(lr)
 	}
@@ -71,21 +113,175 @@ func BenchmarkWorstPatternFormat(b *testing.B) {
 
 func BenchmarkWorstJustSprintf(b *testing.B) {
 	for i := 0; i < b.N; i++ {
-		fmt.Sprintf("short:[%d/%02d/%02d %02d:%02d:%02d] good:[%d-%02d-%02d %02d:%02d:%02d.%03d] "+
-			"levelPadded:[%-10s] long:%s short:%s xs:%10s Msg:%s Fnc:%s Pkg:%s\n", 2011, 10, 20, 15, 39, 7,
+		_ = fmt.Sprintf("short:[%d/%02d/%02d %02d:%02d:%02d] good:[%d-%02d-%02d %02d:%02d:%02d.%03d] "+
+			"levelPadded:[%-10s] long:%s short:%s xs:%10s Msg:%s Fnc:%s Pkg:%s", 2011, 10, 20, 15, 39, 7,
 			2011, 10, 20, 15, 39, 7, 383, "INFO", "/blah/der/some_file.go:7", "some_file.go:7", "some_file", "hellooooo nurse!", "hi.Zoot", "hi")
 	}
 }
 
 func BenchmarkRealPatternFormat(b *testing.B) {
 	pf := NewPatFormatter("[%D %T] [%L] %-10x %M")
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		pf.Format(lr)
+	}
+}
+
+// The same pattern through the Sprintf format the append path replaced,
+// for comparing with BenchmarkRealPatternFormat
+func BenchmarkRealPatternFormatSprintf(b *testing.B) {
+	pf := NewPatFormatter("[%D %T] [%L] %-10x %M")
+	pf.direct = false
+	b.ReportAllocs()
 	for i := 0; i < b.N; i++ {
 		pf.Format(lr)
 	}
 }
 
+// Compare allocs/op with BenchmarkRealPatternFormat to see the
+// savings from skipping the intermediate string
+func BenchmarkRealPatternFormatTo(b *testing.B) {
+	pf := NewPatFormatter("[%D %T] [%L] %-10x %M")
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		pf.FormatTo(io.Discard, lr)
+	}
+}
+
 func BenchmarkReallJustSprintf(b *testing.B) {
 	for i := 0; i < b.N; i++ {
-		fmt.Sprintf("[%d-%02d-%02d %02d:%02d:%02d.%03d] [%s] %-10s %s\n", 2011, 10, 20, 15, 39, 7, 383, "INFO", "some_file", "hellooooo nurse!")
+		_ = fmt.Sprintf("[%d-%02d-%02d %02d:%02d:%02d.%03d] [%s] %-10s %s", 2011, 10, 20, 15, 39, 7, 383, "INFO", "some_file", "hellooooo nurse!")
+	}
+}
+
+func TestFormatTo(t *testing.T) {
+	in := "[%D %T] [%L] %-10x %M"
+	pf := NewPatFormatter(in)
+	buf := new(bytes.Buffer)
+	n, err := pf.FormatTo(buf, lr)
+	if err != nil || n != buf.Len() {
+		t.Errorf("FormatTo returned %d, %v for %d bytes", n, err, buf.Len())
+	}
+	verify(t, in, buf.String(), pf.Format(lr))
+}
+
+func TestTimePrecision(t *testing.T) {
+	SetTimePrecision(time.Second)
+	defer SetTimePrecision(0)
+	pf := NewPatFormatter("%T")
+	verify(t, "%T", pf.Format(lr), "15:39:07.000")
+}
+
+func TestEscape(t *testing.T) {
+	rec := *lr
+	rec.Message = "line one\nline\ttwo\r\x00 ünïcode"
+	pf := NewPatFormatter("[%L] %M")
+	pf.SetEscape(true)
+	verify(t, "escape", pf.Format(&rec), `[INFO] line one\nline\ttwo\r\x00 ünïcode`)
+	verify(t, "nothing to escape", pf.Format(lr), "[INFO] hellooooo nurse!")
+}
+
+func TestTruncateFormatter(t *testing.T) {
+	rec := *lr
+	rec.Message = "héllo wörld"
+	tf := NewTruncateFormatter(NewPatFormatter("%M"), 2)
+	// the é is 2 bytes, don't split it
+	verify(t, "truncate", tf.Format(&rec), "h…(truncated 12 bytes)")
+	tf.MaxLength = 8
+	verify(t, "truncate", tf.Format(&rec), "héllo w…(truncated 5 bytes)")
+	tf.MaxLength = 100
+	verify(t, "short enough", tf.Format(&rec), "héllo wörld")
+	tf.MaxLength = 0
+	verify(t, "off", tf.Format(&rec), "héllo wörld")
+}
+
+func TestColorFormatter(t *testing.T) {
+	cf := NewColorFormatter(NewPatFormatter("%L %M"))
+	verify(t, "info", cf.Format(lr), "INFO hellooooo nurse!")
+	rec := *lr
+	rec.Level = ERROR
+	verify(t, "error", cf.Format(&rec), "\x1b[31mEROR hellooooo nurse!\x1b[0m")
+
+	colors, err := colorsFromFilter(JSONFilter{Properties: []JSONProperty{
+		{Name: "color_error", Value: "1;35"}, {Name: "color_warn", Value: "0"}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if colors[ERROR] != "1;35" || colors[WARNING] != "" || colors[DEBUG] != DefaultColors[DEBUG] {
+		t.Errorf("unexpected colors %v", colors)
+	}
+	if colors, _ := colorsFromFilter(JSONFilter{}); colors != nil {
+		t.Errorf("expected no colors without properties got %v", colors)
+	}
+	if _, err := colorsFromFilter(JSONFilter{Properties: []JSONProperty{{Name: "color_loud", Value: "31"}}}); err == nil {
+		t.Error("expected an error for an unknown level")
+	}
+	if err := cf.SetColor(INFO, "red"); err == nil {
+		t.Error("expected an error for a bad code")
+	}
+
+	cf.LevelOnly = true
+	verify(t, "level only", cf.Format(&rec), "\x1b[31mEROR\x1b[0m hellooooo nurse!")
+	rec.Level = WARNING
+	cf.Formatter = NewPatFormatter("[WARNING] %M")
+	verify(t, "long level", cf.Format(&rec), "[\x1b[33mWARNING\x1b[0m] hellooooo nurse!")
+	cf.Formatter = NewPatFormatter("%M")
+	verify(t, "no level", cf.Format(&rec), "hellooooo nurse!")
+}
+
+func TestColorAuto(t *testing.T) {
+	t.Setenv("NO_COLOR", "")
+	auto := JSONFilter{Properties: []JSONProperty{{Name: "color", Value: "auto"}}}
+	if colors, _ := colorsFromFilter(auto); colors == nil {
+		t.Error("expected colors for auto")
+	}
+	var buf bytes.Buffer
+	if colorWanted(auto, &buf) || ColorEnabled(&buf) {
+		t.Error("expected no color for a buffer")
+	}
+	on := JSONFilter{Properties: []JSONProperty{{Name: "color", Value: "true"}}}
+	if !colorWanted(on, &buf) {
+		t.Error("expected color when it's on")
+	}
+	t.Setenv("NO_COLOR", "1")
+	if colorWanted(on, &buf) {
+		t.Error("expected NO_COLOR to turn color off")
+	}
+	if _, err := colorLevelOnly(JSONFilter{Properties: []JSONProperty{{Name: "color_part", Value: "word"}}}); err == nil {
+		t.Error("expected an error for an unknown color_part")
+	}
+}
+
+func TestMaskFormatter(t *testing.T) {
+	rec := *lr
+	rec.Message = "signup bob@example.com card 4111 1111 1111 1111"
+	mf := NewMaskFormatter(NewPatFormatter("%M"))
+	if err := mf.AddRule(`[\w.]+@[\w.]+`, "***@***"); err != nil {
+		t.Fatal(err)
+	}
+	if err := mf.AddRule(`(\d{4} ){3}(\d{4})`, "**** $2"); err != nil {
+		t.Fatal(err)
+	}
+	verify(t, "mask", mf.Format(&rec), "signup ***@*** card **** 1111")
+	if err := mf.AddRule("(", ""); err == nil {
+		t.Error("expected an error for a bad pattern")
+	}
+}
+
+func TestLevelFormatter(t *testing.T) {
+	lf := NewLevelFormatter(NewPatFormatter("%M"))
+	lf.Levels[DEBUG] = NewPatFormatter("%s %M")
+	verify(t, "info", lf.Format(lr), "hellooooo nurse!")
+	debug := *lr
+	debug.Level = DEBUG
+	verify(t, "debug", lf.Format(&debug), "some_file.go:7 hellooooo nurse!")
+}
+
+func BenchmarkMaskFormatter(b *testing.B) {
+	b.ReportAllocs()
+	mf := NewMaskFormatter(NewPatFormatter("[%D %T] [%L] %-10x %M"))
+	mf.AddRule(`[\w.]+@[\w.]+`, "***@***")
+	for i := 0; i < b.N; i++ {
+		_ = mf.Format(lr)
 	}
 }
@@ -0,0 +1,257 @@
+package timber
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Decides whether a logger writes a record its levels let through, set
+// in ConfigLogger.Filters.  The filters run in order and the first one
+// that says no wins.  Records a ContextWithLevel context let through
+// skip them.
+type RecordFilter interface {
+	Keep(rec *LogRecord) bool
+}
+
+// A RecordFilter from a function, true keeps the record
+type FilterFunc func(rec *LogRecord) bool
+
+func (fn FilterFunc) Keep(rec *LogRecord) bool {
+	return fn(rec)
+}
+
+// The filters made here, which know whether they look at the caller
+type builtinFilter struct {
+	keep   func(rec *LogRecord) bool
+	source bool
+}
+
+func (bf builtinFilter) Keep(rec *LogRecord) bool {
+	return bf.keep(rec)
+}
+
+// SourceUser interface
+func (bf builtinFilter) UsesSource() bool {
+	return bf.source
+}
+
+func anyUsesSource(filters []RecordFilter) bool {
+	for _, filter := range filters {
+		if usesSource(filter, true) {
+			return true
+		}
+	}
+	return false
+}
+
+// Keeps a record every one of filters keeps, and all records if there
+// are none.  The filters after the first that says no don't run, so a
+// sampler or rate limiter late in the list only counts what got that far.
+func AllOf(filters ...RecordFilter) RecordFilter {
+	return builtinFilter{func(rec *LogRecord) bool {
+		for _, filter := range filters {
+			if !filter.Keep(rec) {
+				return false
+			}
+		}
+		return true
+	}, anyUsesSource(filters)}
+}
+
+// Keeps a record one of filters keeps, none if there are none
+func AnyOf(filters ...RecordFilter) RecordFilter {
+	return builtinFilter{func(rec *LogRecord) bool {
+		for _, filter := range filters {
+			if filter.Keep(rec) {
+				return true
+			}
+		}
+		return false
+	}, anyUsesSource(filters)}
+}
+
+// Keeps what filter drops, e.g. Not(MessageMatches(healthCheck))
+func Not(filter RecordFilter) RecordFilter {
+	return builtinFilter{func(rec *LogRecord) bool { return !filter.Keep(rec) }, usesSource(filter, true)}
+}
+
+// Keeps the records with a message re matches
+func MessageMatches(re *regexp.Regexp) RecordFilter {
+	return builtinFilter{func(rec *LogRecord) bool { return re.MatchString(rec.Message) }, false}
+}
+
+// Keeps the records logged from one of the packages or the packages
+// under them, by the same paths granulars use: "github.com/me/app/db"
+// takes github.com/me/app/db/migrate but not github.com/me/app/dbx
+func FromPackages(paths ...string) RecordFilter {
+	return builtinFilter{func(rec *LogRecord) bool {
+		for _, path := range paths {
+			if rec.PackagePath == path || strings.HasPrefix(rec.PackagePath, path) && rec.PackagePath[len(path)] == '/' {
+				return true
+			}
+		}
+		return false
+	}, true}
+}
+
+// Keeps the records with a field name whose value, a string or what
+// fmt.Sprint makes of it, re matches
+func FieldMatches(name string, re *regexp.Regexp) RecordFilter {
+	return builtinFilter{func(rec *LogRecord) bool {
+		value, ok := rec.Fields[name]
+		if !ok {
+			return false
+		}
+		str, isString := value.(string)
+		if !isString {
+			str = fmt.Sprint(value)
+		}
+		return re.MatchString(str)
+	}, false}
+}
+
+// How many distinct messages or buckets a filter tracks before it starts
+// over, so a stream of unique messages can't grow it without limit
+const maxFilterKeys = 10000
+
+// Keeps 1 of every N records with the same message, so one warning
+// repeated in a loop is cut down while every distinct message still gets
+// through at least once.  Records at or above KeepAbove skip sampling, as
+// with a Sampler.
+type MessageSampler struct {
+	N         int
+	KeepAbove Level
+	mu        sync.Mutex
+	seen      map[string]int
+	dropped   uint64
+}
+
+// Keeps 1 of every n identical messages below ERROR
+func NewMessageSampler(n int) *MessageSampler {
+	return &MessageSampler{N: n, KeepAbove: ERROR}
+}
+
+func (ms *MessageSampler) Keep(rec *LogRecord) bool {
+	if ms.N <= 1 || rec.Level >= ms.KeepAbove {
+		return true
+	}
+	ms.mu.Lock()
+	if ms.seen == nil || len(ms.seen) >= maxFilterKeys {
+		ms.seen = make(map[string]int)
+	}
+	n := ms.seen[rec.Message]
+	ms.seen[rec.Message] = n + 1
+	ms.mu.Unlock()
+	if n%ms.N == 0 {
+		return true
+	}
+	atomic.AddUint64(&ms.dropped, 1)
+	return false
+}
+
+// SourceUser interface
+func (ms *MessageSampler) UsesSource() bool {
+	return false
+}
+
+// How many records have been sampled away
+func (ms *MessageSampler) Dropped() uint64 {
+	return atomic.LoadUint64(&ms.dropped)
+}
+
+// Picks the bucket a RateLimiter charges a record to
+type RateKey func(rec *LogRecord) string
+
+// One bucket for each level
+func RateByLevel(rec *LogRecord) string {
+	return strconv.Itoa(int(rec.Level))
+}
+
+// One bucket for each package, the same paths granulars use
+func RateByPackage(rec *LogRecord) string {
+	return rec.PackagePath
+}
+
+// A token bucket: records are let through at Rate a second on average
+// with bursts of up to Burst.  With a Key each bucket is separate, e.g.
+// RateByLevel so an error storm can't use up the budget for warnings;
+// with a nil Key everything shares one bucket.
+type RateLimiter struct {
+	Rate    float64
+	Burst   int
+	Key     RateKey
+	mu      sync.Mutex
+	buckets map[string]*tokenBucket
+	dropped uint64
+	now     func() time.Time // swapped out by the tests
+}
+
+type tokenBucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// A burst of zero or less is one second's worth of records
+func NewRateLimiter(rate float64, burst int, key RateKey) *RateLimiter {
+	if burst <= 0 {
+		burst = int(math.Ceil(rate))
+	}
+	return &RateLimiter{Rate: rate, Burst: burst, Key: key, now: time.Now}
+}
+
+func (rl *RateLimiter) Keep(rec *LogRecord) bool {
+	key := ""
+	if rl.Key != nil {
+		key = rl.Key(rec)
+	}
+	rl.mu.Lock()
+	now := rl.now()
+	if rl.buckets == nil || len(rl.buckets) >= maxFilterKeys {
+		rl.buckets = make(map[string]*tokenBucket)
+	}
+	bucket, ok := rl.buckets[key]
+	if !ok {
+		bucket = &tokenBucket{tokens: float64(rl.Burst), last: now}
+		rl.buckets[key] = bucket
+	}
+	bucket.tokens = math.Min(float64(rl.Burst), bucket.tokens+now.Sub(bucket.last).Seconds()*rl.Rate)
+	bucket.last = now
+	keep := bucket.tokens >= 1
+	if keep {
+		bucket.tokens--
+	}
+	rl.mu.Unlock()
+	if !keep {
+		atomic.AddUint64(&rl.dropped, 1)
+	}
+	return keep
+}
+
+// SourceUser interface, a Key may bucket by package
+func (rl *RateLimiter) UsesSource() bool {
+	return rl.Key != nil
+}
+
+// How many records went over the limit
+func (rl *RateLimiter) Suppressed() uint64 {
+	return atomic.LoadUint64(&rl.dropped)
+}
+
+// false if one of the logger's filters throws rec out
+func (cLog ConfigLogger) keep(rec *LogRecord) bool {
+	if rec.elevated {
+		return true
+	}
+	for _, filter := range cLog.Filters {
+		if !filter.Keep(rec) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,137 @@
+package timber
+
+import (
+	"reflect"
+	"regexp"
+	"testing"
+	"time"
+)
+
+func TestMessageSampler(t *testing.T) {
+	log := NewTimber()
+	mw := NewMemoryWriter(20)
+	sampler := NewMessageSampler(3)
+	log.AddLogger(ConfigLogger{LogWriter: mw, Level: INFO, Formatter: NewPatFormatter("%M"),
+		Filters: []RecordFilter{sampler}})
+	for i := 0; i < 7; i++ {
+		log.Warn("disk full")
+		if i < 2 {
+			log.Warn("other")
+			log.Error("errors are kept")
+		}
+	}
+	log.Close()
+	expected := []string{"disk full", "other", "errors are kept", "errors are kept", "disk full", "disk full"}
+	if got := mw.Messages(); !reflect.DeepEqual(got, expected) {
+		t.Errorf("expected %q got %q", expected, got)
+	}
+	if n := sampler.Dropped(); n != 5 {
+		t.Errorf("expected 5 dropped got %d", n)
+	}
+}
+
+func TestRateLimiter(t *testing.T) {
+	now := time.Date(2012, 3, 4, 5, 6, 7, 0, time.UTC)
+	rl := NewRateLimiter(2, 0, RateByLevel)
+	rl.now = func() time.Time { return now }
+	kept := func(lvl Level, n int) int {
+		count := 0
+		for i := 0; i < n; i++ {
+			if rl.Keep(&LogRecord{Level: lvl}) {
+				count++
+			}
+		}
+		return count
+	}
+	if got := kept(ERROR, 5); got != 2 {
+		t.Errorf("expected a burst of 2 errors got %d", got)
+	}
+	if got := kept(WARNING, 5); got != 2 {
+		t.Errorf("expected warnings to have their own bucket, got %d", got)
+	}
+	now = now.Add(time.Second)
+	if got := kept(ERROR, 5); got != 2 {
+		t.Errorf("expected 2 more errors after a second got %d", got)
+	}
+	now = now.Add(250 * time.Millisecond)
+	if got := kept(ERROR, 5); got != 0 {
+		t.Errorf("expected no errors after a quarter second got %d", got)
+	}
+	if n := rl.Suppressed(); n != 14 {
+		t.Errorf("expected 14 suppressed got %d", n)
+	}
+}
+
+func TestRecordFilterConfig(t *testing.T) {
+	filters, err := recordFiltersFromFilter(JSONFilter{Properties: []JSONProperty{
+		{"sample_identical", "10"}, {"sample_keep_above", "WARNING"}, {"rate_limit", "0.5"}, {"rate_burst", "3"}, {"rate_by", "package"}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(filters) != 2 {
+		t.Fatalf("expected 2 filters got %v", filters)
+	}
+	if ms, ok := filters[0].(*MessageSampler); !ok || ms.N != 10 || ms.KeepAbove != WARNING {
+		t.Errorf("expected a 1 in 10 message sampler below WARNING got %#v", filters[0])
+	}
+	if rl, ok := filters[1].(*RateLimiter); !ok || rl.Rate != 0.5 || rl.Burst != 3 || rl.Key == nil {
+		t.Errorf("expected a per package rate limiter got %#v", filters[1])
+	}
+	for _, bad := range []JSONProperty{{"sample_identical", "0"}, {"rate_limit", "fast"}, {"rate_by", "moon"}} {
+		props := []JSONProperty{bad}
+		if bad.Name == "rate_by" {
+			props = append(props, JSONProperty{"rate_limit", "1"})
+		}
+		if _, err := recordFiltersFromFilter(JSONFilter{Properties: props}); err == nil {
+			t.Errorf("expected an error for %v", bad)
+		}
+	}
+}
+
+func TestPredicateFilters(t *testing.T) {
+	health := regexp.MustCompile(`^GET /healthz`)
+	keep := AllOf(Not(MessageMatches(health)),
+		AnyOf(FromPackages("github.com/me/app/db"), FieldMatches("status", regexp.MustCompile(`^5`))))
+	records := map[*LogRecord]bool{
+		{Message: "GET /healthz 200", PackagePath: "github.com/me/app/db"}:            false,
+		{Message: "slow query", PackagePath: "github.com/me/app/db/migrate"}:          true,
+		{Message: "slow query", PackagePath: "github.com/me/app/dbx"}:                 false,
+		{Message: "GET /users", Fields: Fields{"status": 503}}:                        true,
+		{Message: "GET /users", Fields: Fields{"status": "200"}}:                      false,
+		{Message: "GET /healthz 500", Fields: Fields{"status": 500}, PackagePath: ""}: false,
+	}
+	for rec, expected := range records {
+		if got := keep.Keep(rec); got != expected {
+			t.Errorf("%q from %q %v: expected %v got %v", rec.Message, rec.PackagePath, rec.Fields, expected, got)
+		}
+	}
+	if !AllOf().Keep(&LogRecord{}) || AnyOf().Keep(&LogRecord{}) {
+		t.Errorf("expected AllOf() to keep everything and AnyOf() nothing")
+	}
+}
+
+func TestMatchFilterConfig(t *testing.T) {
+	logger := NewTimber()
+	mw := NewMemoryWriter(10)
+	filters, err := recordFiltersFromFilter(JSONFilter{Properties: []JSONProperty{
+		{"drop_message", "^GET /healthz"}, {"drop_field", "user=^bot-"},
+		{"keep_package", "github.com/me/app/db, github.com/me/app/api"}, {"keep_field", "status=^5"}, {"keep_any", "true"}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	logger.AddLogger(ConfigLogger{LogWriter: mw, Level: INFO, Formatter: NewPatFormatter("%M"), Filters: filters})
+	logger.Infow("GET /healthz", Fields{"status": 500})
+	logger.Infow("crawled", Fields{"status": 500, "user": "bot-7"})
+	logger.Infow("GET /users failed", Fields{"status": 502})
+	logger.Infow("GET /users", Fields{"status": 200})
+	logger.Close()
+	expected := []string{"GET /users failed"}
+	if got := mw.Messages(); !reflect.DeepEqual(got, expected) {
+		t.Errorf("expected %q got %q", expected, got)
+	}
+	for _, bad := range []JSONProperty{{"drop_message", "("}, {"keep_field", "status"}, {"keep_field", "status=("}} {
+		if _, err := recordFiltersFromFilter(JSONFilter{Properties: []JSONProperty{bad}}); err == nil {
+			t.Errorf("expected an error for %v", bad)
+		}
+	}
+}
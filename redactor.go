@@ -0,0 +1,203 @@
+package timber
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// Rewrites the sensitive parts of a record before a logger formats or
+// writes it, set in ConfigLogger.Redactors.  The redactors run in order
+// over the message and over every string, error and fmt.Stringer field;
+// field is the field's name, "" for the message.  A logger with
+// redactors works on a copy, so other loggers still get the record as it
+// was logged.  Unlike a MaskFormatter the fields are redacted before a
+// JSONFormatter, logfmt or a StructuredWriter sees them.
+type Redactor interface {
+	Redact(field, value string) string
+}
+
+// Adapts a function to a Redactor
+type RedactorFunc func(field, value string) string
+
+func (f RedactorFunc) Redact(field, value string) string {
+	return f(field, value)
+}
+
+// Replaces the matches of a regexp in the message and every field
+type RegexpRedactor struct {
+	Pattern *regexp.Regexp
+	// What a match becomes, $1 style group references work as in
+	// regexp.ReplaceAllString
+	Replacement string
+	// Optional, replaces Replacement with what it returns for each match,
+	// e.g. to leave a match that isn't what it looks like alone
+	ReplaceFunc func(match string) string
+}
+
+func NewRegexpRedactor(pattern, replacement string) (*RegexpRedactor, error) {
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, fmt.Errorf("TIMBER! Bad redact pattern %q: %v", pattern, err)
+	}
+	return &RegexpRedactor{Pattern: re, Replacement: replacement}, nil
+}
+
+func (rr *RegexpRedactor) Redact(field, value string) string {
+	if rr.ReplaceFunc != nil {
+		return rr.Pattern.ReplaceAllStringFunc(value, rr.ReplaceFunc)
+	}
+	return rr.Pattern.ReplaceAllString(value, rr.Replacement)
+}
+
+// Replaces the whole value of the named fields, e.g. password, and
+// leaves the message and the other fields alone
+type FieldRedactor struct {
+	Fields      map[string]bool
+	Replacement string
+}
+
+// The fields' values become RedactedText
+func NewFieldRedactor(fields ...string) *FieldRedactor {
+	fr := &FieldRedactor{Fields: make(map[string]bool), Replacement: RedactedText}
+	for _, field := range fields {
+		fr.Fields[field] = true
+	}
+	return fr
+}
+
+func (fr *FieldRedactor) Redact(field, value string) string {
+	if field != "" && fr.Fields[field] {
+		return fr.Replacement
+	}
+	return value
+}
+
+// What the built in redactors put in place of what they remove
+const RedactedText = "[REDACTED]"
+
+// The built in redactors, by the names the redact property takes
+var (
+	// Card numbers of 13 to 19 digits, with or without spaces or dashes,
+	// that pass the Luhn check; the last four digits are kept
+	CreditCardRedactor = &RegexpRedactor{Pattern: regexp.MustCompile(`\b\d(?:[ -]?\d){12,18}\b`),
+		ReplaceFunc: redactCardNumber}
+	// The token of an Authorization: Bearer header or the like
+	BearerTokenRedactor = &RegexpRedactor{Pattern: regexp.MustCompile(`(?i)\b(bearer[ \t]+)[A-Za-z0-9\-._~+/]+=*`),
+		Replacement: "${1}" + RedactedText}
+	EmailRedactor = &RegexpRedactor{Pattern: regexp.MustCompile(`[A-Za-z0-9._%+\-]+@[A-Za-z0-9\-]+(?:\.[A-Za-z0-9\-]+)*\.[A-Za-z]{2,}`),
+		Replacement: RedactedText}
+
+	BuiltinRedactors = map[string]Redactor{
+		"credit_card":  CreditCardRedactor,
+		"bearer_token": BearerTokenRedactor,
+		"email":        EmailRedactor,
+	}
+)
+
+func redactCardNumber(match string) string {
+	digits := make([]byte, 0, len(match))
+	for i := 0; i < len(match); i++ {
+		if match[i] >= '0' && match[i] <= '9' {
+			digits = append(digits, match[i])
+		}
+	}
+	if !luhnValid(digits) {
+		return match
+	}
+	return "****" + string(digits[len(digits)-4:])
+}
+
+func luhnValid(digits []byte) bool {
+	sum := 0
+	for i := len(digits) - 1; i >= 0; i-- {
+		d := int(digits[i] - '0')
+		if (len(digits)-i)%2 == 0 {
+			if d *= 2; d > 9 {
+				d -= 9
+			}
+		}
+		sum += d
+	}
+	return sum%10 == 0
+}
+
+// Runs the redactors over value
+func redact(redactors []Redactor, field, value string) string {
+	for _, redactor := range redactors {
+		value = redactor.Redact(field, value)
+	}
+	return value
+}
+
+// A copy of rec with the message and fields redacted.  A field that
+// isn't text is redacted as fmt.Sprint prints it, so a named field goes
+// whatever its type.  One nothing was redacted from is kept as it is, the
+// rest become strings.
+func redactRecord(rec *LogRecord, redactors []Redactor) *LogRecord {
+	redacted := *rec
+	redacted.Message = redact(redactors, "", rec.Message)
+	if len(rec.Fields) > 0 {
+		redacted.Fields = make(Fields, len(rec.Fields))
+		for key, val := range rec.Fields {
+			redacted.Fields[key] = redactField(redactors, key, val)
+		}
+	}
+	return &redacted
+}
+
+func redactField(redactors []Redactor, key string, val interface{}) interface{} {
+	if key == FormatField {
+		return val
+	}
+	var s string
+	switch v := val.(type) {
+	case string:
+		s = v
+	case error:
+		s = v.Error()
+	case fmt.Stringer:
+		s = v.String()
+	default:
+		s = fmt.Sprint(v)
+	}
+	if r := redact(redactors, key, s); r != s {
+		return r
+	}
+	return val
+}
+
+// The redactors set by a filter's redact properties, each a built in
+// name or pattern=>replacement, and its redact_field properties, each a
+// comma separated list of fields whose values are replaced whole
+func redactorsFromFilter(filter JSONFilter) ([]Redactor, error) {
+	var redactors []Redactor
+	for _, spec := range filter.properties("redact") {
+		spec = strings.TrimSpace(spec)
+		if redactor, ok := BuiltinRedactors[spec]; ok {
+			redactors = append(redactors, redactor)
+			continue
+		}
+		if !strings.Contains(spec, "=>") {
+			return nil, fmt.Errorf("TIMBER! Unknown redactor %q, expected credit_card, bearer_token, email or pattern=>replacement", spec)
+		}
+		pattern, replacement, _ := parseMaskRule(spec)
+		redactor, err := NewRegexpRedactor(pattern, replacement)
+		if err != nil {
+			return nil, err
+		}
+		redactors = append(redactors, redactor)
+	}
+	var fields []string
+	for _, list := range filter.properties("redact_field") {
+		for _, field := range strings.Split(list, ",") {
+			if field = strings.TrimSpace(field); field != "" {
+				fields = append(fields, field)
+			}
+		}
+	}
+	if len(fields) > 0 {
+		redactors = append(redactors, NewFieldRedactor(fields...))
+	}
+	return redactors, nil
+}
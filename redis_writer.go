@@ -0,0 +1,169 @@
+package timber
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"time"
+)
+
+// How many idle connections the RedisWriter keeps around
+const redisPoolSize = 4
+
+// How long the RedisWriter waits to connect and for each command
+const redisTimeout = 5 * time.Second
+
+// Writes each message to redis, either RPUSHed onto a list or PUBLISHed
+// to a channel.  Connections are pooled and redialed when they fail.
+// Errors are sent to WriteErrorHandler.
+type RedisWriter struct {
+	address  string
+	key      string
+	command  string
+	username string
+	password string
+	timeout  time.Duration
+	pool     chan *redisConn
+}
+
+type redisConn struct {
+	conn    net.Conn
+	r       *bufio.Reader
+	timeout time.Duration
+}
+
+// mode is "list" (the default) or "pubsub".  username and password are
+// optional and are sent with AUTH on each new connection.
+func NewRedisWriter(address, key, mode, username, password string) (*RedisWriter, error) {
+	rw := &RedisWriter{
+		address:  address,
+		key:      key,
+		username: username,
+		password: password,
+		timeout:  redisTimeout,
+		pool:     make(chan *redisConn, redisPoolSize),
+	}
+	switch mode {
+	case "", "list":
+		rw.command = "RPUSH"
+	case "pubsub":
+		rw.command = "PUBLISH"
+	default:
+		return nil, fmt.Errorf("TIMBER! Unknown redis mode %v, expected list or pubsub", mode)
+	}
+	// fail early if redis isn't there
+	rc, err := rw.dial()
+	if err != nil {
+		return nil, err
+	}
+	rw.put(rc)
+	return rw, nil
+}
+
+func (rw *RedisWriter) LogWrite(msg string) {
+	err := rw.send(msg)
+	if err != nil {
+		// the pooled connection may have gone stale, try once more
+		err = rw.send(msg)
+	}
+	if err != nil {
+		reportWriteError(rw, err)
+	}
+}
+
+func (rw *RedisWriter) send(msg string) error {
+	rc, err := rw.get()
+	if err != nil {
+		return err
+	}
+	if _, err = rc.do(rw.command, rw.key, msg); err != nil {
+		rc.conn.Close()
+		return err
+	}
+	rw.put(rc)
+	return nil
+}
+
+func (rw *RedisWriter) get() (*redisConn, error) {
+	select {
+	case rc := <-rw.pool:
+		return rc, nil
+	default:
+		return rw.dial()
+	}
+}
+
+func (rw *RedisWriter) put(rc *redisConn) {
+	select {
+	case rw.pool <- rc:
+	default:
+		rc.conn.Close()
+	}
+}
+
+func (rw *RedisWriter) dial() (*redisConn, error) {
+	conn, err := net.DialTimeout("tcp", rw.address, rw.timeout)
+	if err != nil {
+		return nil, err
+	}
+	rc := &redisConn{conn, bufio.NewReader(conn), rw.timeout}
+	if rw.password != "" {
+		if rw.username != "" {
+			_, err = rc.do("AUTH", rw.username, rw.password)
+		} else {
+			_, err = rc.do("AUTH", rw.password)
+		}
+		if err != nil {
+			conn.Close()
+			return nil, err
+		}
+	}
+	return rc, nil
+}
+
+func (rw *RedisWriter) Close() {
+	for {
+		select {
+		case rc := <-rw.pool:
+			rc.conn.Close()
+		default:
+			return
+		}
+	}
+}
+
+// Sends a command using the RESP protocol and reads a simple reply, a
+// redis that stops answering is an error after the timeout
+func (rc *redisConn) do(args ...string) (string, error) {
+	if err := rc.conn.SetDeadline(time.Now().Add(rc.timeout)); err != nil {
+		return "", err
+	}
+	buf := make([]byte, 0, 64)
+	buf = append(buf, '*')
+	buf = strconv.AppendInt(buf, int64(len(args)), 10)
+	buf = append(buf, "\r\n"...)
+	for _, arg := range args {
+		buf = append(buf, '$')
+		buf = strconv.AppendInt(buf, int64(len(arg)), 10)
+		buf = append(buf, "\r\n"...)
+		buf = append(buf, arg...)
+		buf = append(buf, "\r\n"...)
+	}
+	if _, err := rc.conn.Write(buf); err != nil {
+		return "", err
+	}
+	line, err := rc.r.ReadString('\n')
+	if err != nil {
+		return "", err
+	}
+	if len(line) < 3 {
+		return "", errors.New("TIMBER! Bad reply from redis")
+	}
+	line = line[:len(line)-2]
+	if line[0] == '-' {
+		return "", fmt.Errorf("TIMBER! redis error: %s", line[1:])
+	}
+	return line[1:], nil
+}
@@ -0,0 +1,80 @@
+package timber
+
+import (
+	"bufio"
+	"net"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Accepts one connection and sends each command it receives on cmds
+func fakeRedis(t *testing.T, cmds chan []string) net.Listener {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		r := bufio.NewReader(conn)
+		for {
+			line, err := r.ReadString('\n')
+			if err != nil {
+				return
+			}
+			n, _ := strconv.Atoi(strings.TrimSpace(line[1:]))
+			cmd := make([]string, 0, n)
+			for i := 0; i < n; i++ {
+				r.ReadString('\n') // length
+				arg, _ := r.ReadString('\n')
+				cmd = append(cmd, strings.TrimSuffix(arg, "\r\n"))
+			}
+			conn.Write([]byte(":1\r\n"))
+			cmds <- cmd
+		}
+	}()
+	return ln
+}
+
+func TestRedisWriter(t *testing.T) {
+	cmds := make(chan []string, 1)
+	ln := fakeRedis(t, cmds)
+	defer ln.Close()
+	rw, err := NewRedisWriter(ln.Addr().String(), "logs", "list", "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	rw.LogWrite("hello redis")
+	if cmd := <-cmds; strings.Join(cmd, " ") != "RPUSH logs hello redis" {
+		t.Errorf("unexpected command %q", cmd)
+	}
+	rw.Close()
+}
+
+func TestRedisWriterTimeout(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	// accepts and never answers
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		time.Sleep(time.Second)
+	}()
+	rw := &RedisWriter{address: ln.Addr().String(), key: "logs", command: "RPUSH", timeout: 50 * time.Millisecond,
+		pool: make(chan *redisConn, redisPoolSize)}
+	err = rw.send("nobody listens")
+	if ne, ok := err.(net.Error); !ok || !ne.Timeout() {
+		t.Errorf("expected a timeout, got %v", err)
+	}
+}
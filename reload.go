@@ -0,0 +1,129 @@
+package timber
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path"
+	"time"
+)
+
+// How often WatchConfig checks the config file for changes
+const DefaultWatchInterval = time.Second
+
+// Reads the config in filename again, any type LoadConfigAuto takes, and
+// swaps its loggers in for all of t's current ones, including any that
+// were added in code.
+// Records logged before the swap are written by the old loggers so
+// nothing in flight is lost, then the old writers are closed.  If the
+// file can't be read or any filter in it fails the current loggers carry
+// on untouched.
+func (t *Timber) ReloadConfig(filename string) error {
+	config, err := readConfigFile(filename)
+	if err != nil {
+		return err
+	}
+	if dryRun() {
+		if err = dryRunError(config); err != nil {
+			return fmt.Errorf("%v (%s)", err, filename)
+		}
+		return nil
+	}
+	if config, err = prepareConfig(config); err != nil {
+		return fmt.Errorf("%v (%s)", err, filename)
+	}
+	var loggers []ConfigLogger
+	for _, filter := range config.Filters {
+		if !t.takesFilter(filter) {
+			continue
+		}
+		configLogger, ok, err := buildLogger(config.Defaults, filter)
+		if err != nil {
+			closeAllWriters(loggers)
+			return fmt.Errorf("%v (%s)", err, filename)
+		}
+		if !ok {
+			continue
+		}
+		// a repeated tag replaces the earlier logger, like AddLogger
+		if idx := tagIndex(loggers, configLogger.Tag); idx >= 0 {
+			loggers[idx].LogWriter.Close()
+			loggers[idx] = configLogger
+			continue
+		}
+		loggers = append(loggers, configLogger)
+	}
+	tcChan := make(chan int, 1) // buffered
+	if !t.configure(timberConfig{Action: actionReplace, Loggers: loggers, Ret: tcChan}) {
+		closeAllWriters(loggers)
+		return fmt.Errorf("TIMBER! Can't reload the config, the logger is closed")
+	}
+	<-tcChan
+	return nil
+}
+
+// Same as ReloadConfig for the Global logger
+func ReloadConfig(filename string) error { return global().ReloadConfig(filename) }
+
+func readConfigFile(filename string) (JSONConfig, error) {
+	switch ext := path.Ext(filename); ext {
+	case ".xml":
+		return readXMLConfig(filename)
+	case ".json":
+		return readJSONConfigFile(filename)
+	case ".yaml", ".yml":
+		return readYAMLConfig(filename)
+	case ".toml":
+		return readTOMLConfig(filename)
+	default:
+		return JSONConfig{}, fmt.Errorf("TIMBER! Unknown config file type %v, only XML, JSON, YAML and TOML are supported types", ext)
+	}
+}
+
+// Calls ReloadConfig whenever filename changes, so a level can be turned
+// up without a restart.  The file is polled for a new modification time
+// or size every DefaultWatchInterval.  Load the file first, the watch
+// only picks up changes made after it starts.  A change that doesn't load
+// (say the file was caught half written) is reported to the standard
+// logger and the current loggers are kept until the next change.
+// Watching stops when t is closed.
+func (t *Timber) WatchConfig(filename string) error {
+	return t.WatchConfigInterval(filename, DefaultWatchInterval)
+}
+
+// Same as WatchConfig but checks the file every interval
+func (t *Timber) WatchConfigInterval(filename string, interval time.Duration) error {
+	if interval <= 0 {
+		return fmt.Errorf("TIMBER! Bad watch interval %v", interval)
+	}
+	info, err := os.Stat(filename)
+	if err != nil {
+		return fmt.Errorf("TIMBER! Can't watch config file: %s %v", filename, err)
+	}
+	go t.watchConfig(filename, interval, info)
+	return nil
+}
+
+// Same as WatchConfig for the Global logger
+func WatchConfig(filename string) error { return global().WatchConfig(filename) }
+
+func (t *Timber) watchConfig(filename string, interval time.Duration, last os.FileInfo) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for range ticker.C {
+		t.closeSync.RLock()
+		closed := t.closed
+		t.closeSync.RUnlock()
+		if closed {
+			return
+		}
+		info, err := os.Stat(filename)
+		if err != nil || (info.ModTime().Equal(last.ModTime()) && info.Size() == last.Size()) {
+			continue
+		}
+		last = info
+		if err := t.ReloadConfig(filename); err != nil {
+			log.Printf("TIMBER! Keeping the current config: %v\n", err)
+		}
+	}
+}
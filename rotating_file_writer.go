@@ -0,0 +1,299 @@
+package timber
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// How often a RotatingFileWriter starts a new file regardless of size
+type RotatePeriod int
+
+const (
+	RotateNever RotatePeriod = iota
+	RotateHourly
+	RotateDaily
+)
+
+// Writes records, one per line, to a file that's rotated when it would
+// grow past a size limit or when an hour/day boundary is crossed,
+// whichever comes first.  Rotation is only checked between records so
+// a record is never split across files; with a JSONFormatter every file
+// is clean newline delimited JSON for a shipper to tail.  Rotated
+// files are named after the period they cover, e.g. server.log.2012-03-04,
+// with .1, .2 and so on added when a size rotation happens more than once
+// in the same period, and .gz when SetCompress is on.  Writes are
+// unbuffered; put an AsyncWriter in front if the disk is slow.
+type RotatingFileWriter struct {
+	name    string
+	maxSize int64 // 0 means no size limit
+	period  RotatePeriod
+
+	// retention, see SetRetention
+	maxBackups int
+	maxAge     time.Duration
+	compress   bool // gzip rotated files, see SetCompress
+
+	mu          sync.Mutex
+	file        *os.File
+	size        int64
+	periodStart time.Time
+	now         func() time.Time                    // swapped out by the tests
+	openFile    func(name string) (*os.File, error) // same
+}
+
+func NewRotatingFileWriter(name string, maxSize int64, period RotatePeriod) (*RotatingFileWriter, error) {
+	rw := &RotatingFileWriter{name: name, maxSize: maxSize, period: period, now: time.Now, openFile: openAppend}
+	if err := rw.open(); err != nil {
+		return nil, err
+	}
+	return rw, nil
+}
+
+// Keeps at most maxBackups rotated files and none older than maxAge; zero
+// turns either limit off.  Old files are removed after each rotation.
+func (rw *RotatingFileWriter) SetRetention(maxBackups int, maxAge time.Duration) {
+	rw.mu.Lock()
+	rw.maxBackups, rw.maxAge = maxBackups, maxAge
+	rw.mu.Unlock()
+}
+
+// Gzips each file as it's rotated out, server.log.2012-03-04 becomes
+// server.log.2012-03-04.gz.  The compression happens during the write
+// that triggered the rotation.
+func (rw *RotatingFileWriter) SetCompress(compress bool) {
+	rw.mu.Lock()
+	rw.compress = compress
+	rw.mu.Unlock()
+}
+
+// must hold mu
+func (rw *RotatingFileWriter) open() error {
+	file, err := rw.openFile(rw.name)
+	if err != nil {
+		return fmt.Errorf("TIMBER! Can't open %v: %v", rw.name, err)
+	}
+	rw.file = file
+	rw.size = 0
+	rw.periodStart = rw.startOf(rw.now())
+	if info, err := file.Stat(); err == nil {
+		rw.size = info.Size()
+		if rw.size > 0 {
+			// an existing file belongs to the period it was last written in
+			rw.periodStart = rw.startOf(info.ModTime())
+		}
+	}
+	return nil
+}
+
+func (rw *RotatingFileWriter) startOf(t time.Time) time.Time {
+	switch rw.period {
+	case RotateHourly:
+		return time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), 0, 0, 0, t.Location())
+	default:
+		return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+	}
+}
+
+func (rw *RotatingFileWriter) suffix() string {
+	if rw.period == RotateHourly {
+		return rw.periodStart.Format("2006-01-02-15")
+	}
+	return rw.periodStart.Format("2006-01-02")
+}
+
+func (rw *RotatingFileWriter) LogWrite(msg string) {
+	rw.Write([]byte(msg))
+}
+
+// Allows formatters to write directly to the file
+func (rw *RotatingFileWriter) Write(p []byte) (int, error) {
+	rw.mu.Lock()
+	defer rw.mu.Unlock()
+	if rw.file == nil {
+		return 0, os.ErrClosed
+	}
+	if rw.shouldRotate(len(p) + 1) {
+		rw.rotate()
+	}
+	n, err := writeTerminated(rw.file, p, newline)
+	if err == nil {
+		rw.size += int64(n) + 1
+	} else {
+		rw.size += int64(n)
+	}
+	if err != nil {
+		reportWriteError(rw, err)
+	}
+	return n, err
+}
+
+func (rw *RotatingFileWriter) shouldRotate(next int) bool {
+	if rw.maxSize > 0 && rw.size > 0 && rw.size+int64(next) > rw.maxSize {
+		return true
+	}
+	return rw.period != RotateNever && !rw.startOf(rw.now()).Equal(rw.periodStart)
+}
+
+func openAppend(name string) (*os.File, error) {
+	return os.OpenFile(name, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0666)
+}
+
+// must hold mu; on failure it keeps writing to the current file, renamed
+// or not, and tries again on the next write
+func (rw *RotatingFileWriter) rotate() {
+	// closed first, some systems can't rename an open file
+	rw.file.Close()
+	backup := rw.backupName()
+	current := backup
+	err := os.Rename(rw.name, backup)
+	if err != nil {
+		reportWriteError(rw, fmt.Errorf("TIMBER! Can't rotate %v: %v", rw.name, err))
+		current = rw.name
+	}
+	if openErr := rw.open(); openErr != nil {
+		reportWriteError(rw, openErr)
+		if file, reopenErr := rw.openFile(current); reopenErr == nil {
+			rw.file = file
+		}
+		return
+	}
+	if err == nil && rw.compress {
+		if err := gzipFile(backup); err != nil {
+			reportWriteError(rw, err)
+		}
+	}
+	rw.prune()
+}
+
+// the first unused name for the current period, compressed or not
+func (rw *RotatingFileWriter) backupName() string {
+	base := rw.name + "." + rw.suffix()
+	name := base
+	for i := 1; ; i++ {
+		if !exists(name) && !exists(name+".gz") {
+			return name
+		}
+		name = base + "." + strconv.Itoa(i)
+	}
+}
+
+func exists(name string) bool {
+	_, err := os.Stat(name)
+	return !os.IsNotExist(err)
+}
+
+// Replaces name with name.gz; on failure the uncompressed file is left
+func gzipFile(name string) error {
+	in, err := os.Open(name)
+	if err != nil {
+		return fmt.Errorf("TIMBER! Can't compress %v: %v", name, err)
+	}
+	defer in.Close()
+	out, err := os.OpenFile(name+".gz", os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0666)
+	if err != nil {
+		return fmt.Errorf("TIMBER! Can't compress %v: %v", name, err)
+	}
+	gz := gzip.NewWriter(out)
+	_, err = io.Copy(gz, in)
+	if err == nil {
+		err = gz.Close()
+	}
+	if closeErr := out.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(name + ".gz")
+		return fmt.Errorf("TIMBER! Can't compress %v: %v", name, err)
+	}
+	in.Close()
+	return os.Remove(name)
+}
+
+type rotatedFile struct {
+	name    string
+	modTime time.Time
+}
+
+// must hold mu
+func (rw *RotatingFileWriter) prune() {
+	if rw.maxBackups <= 0 && rw.maxAge <= 0 {
+		return
+	}
+	matches, err := filepath.Glob(rw.name + ".*")
+	if err != nil {
+		return
+	}
+	var backups []rotatedFile
+	for _, match := range matches {
+		// only names this writer made, which all start with the date
+		rest := strings.TrimPrefix(match, rw.name+".")
+		if rest == match || rest == "" || rest[0] < '0' || rest[0] > '9' {
+			continue
+		}
+		if info, err := os.Stat(match); err == nil && info.Mode().IsRegular() {
+			backups = append(backups, rotatedFile{match, info.ModTime()})
+		}
+	}
+	// newest first
+	sort.Slice(backups, func(i, j int) bool {
+		if backups[i].modTime.Equal(backups[j].modTime) {
+			return backups[i].name > backups[j].name
+		}
+		return backups[i].modTime.After(backups[j].modTime)
+	})
+	cutoff := rw.now().Add(-rw.maxAge)
+	for i, backup := range backups {
+		if (rw.maxBackups > 0 && i >= rw.maxBackups) || (rw.maxAge > 0 && backup.modTime.Before(cutoff)) {
+			os.Remove(backup.name)
+		}
+	}
+}
+
+// Closes the current file and opens name again, see Reopener
+func (rw *RotatingFileWriter) Reopen() error {
+	rw.mu.Lock()
+	defer rw.mu.Unlock()
+	if rw.file != nil {
+		rw.file.Close()
+		rw.file = nil
+	}
+	return rw.open()
+}
+
+func (rw *RotatingFileWriter) Close() {
+	rw.mu.Lock()
+	defer rw.mu.Unlock()
+	if rw.file != nil {
+		rw.file.Close()
+		rw.file = nil
+	}
+}
+
+// Parses a byte count with an optional KB, MB or GB suffix (powers of 1024)
+func parseSize(size string) (int64, error) {
+	size = strings.ToUpper(strings.TrimSpace(size))
+	mult := int64(1)
+	for _, unit := range []struct {
+		suffix string
+		mult   int64
+	}{{"KB", 1 << 10}, {"MB", 1 << 20}, {"GB", 1 << 30}, {"B", 1}} {
+		if strings.HasSuffix(size, unit.suffix) {
+			size = strings.TrimSpace(strings.TrimSuffix(size, unit.suffix))
+			mult = unit.mult
+			break
+		}
+	}
+	n, err := strconv.ParseInt(size, 10, 64)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("TIMBER! Bad size %q", size)
+	}
+	return n * mult, nil
+}
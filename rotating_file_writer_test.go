@@ -0,0 +1,196 @@
+package timber
+
+import (
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func rotatedNames(t *testing.T, name string) []string {
+	matches, err := filepath.Glob(name + ".*")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := range matches {
+		matches[i] = filepath.Base(matches[i])
+	}
+	sort.Strings(matches)
+	return matches
+}
+
+func TestRotatingFileWriter(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "app.log")
+	now := time.Date(2012, 3, 4, 10, 0, 0, 0, time.Local)
+	rw, err := NewRotatingFileWriter(name, 10, RotateDaily)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rw.now = func() time.Time { return now }
+	rw.periodStart = rw.startOf(now)
+
+	rw.LogWrite("12345678")
+	rw.LogWrite("12345678") // size
+	rw.LogWrite("12345678") // size again, same day
+	now = now.Add(24 * time.Hour)
+	rw.LogWrite("day two") // time
+	rw.Close()
+
+	expected := []string{"app.log.2012-03-04", "app.log.2012-03-04.1", "app.log.2012-03-04.2"}
+	got := rotatedNames(t, name)
+	if len(got) != len(expected) {
+		t.Fatalf("expected %v got %v", expected, got)
+	}
+	for i := range expected {
+		if got[i] != expected[i] {
+			t.Errorf("expected %v got %v", expected, got)
+		}
+	}
+	if data, _ := os.ReadFile(name); string(data) != "day two\n" {
+		t.Errorf("expected just day two in the current file, got %q", data)
+	}
+}
+
+func TestRotatingFileWriterOpenFails(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "app.log")
+	now := time.Date(2012, 3, 4, 10, 0, 0, 0, time.Local)
+	rw, err := NewRotatingFileWriter(name, 10, RotateNever)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rw.Close()
+	rw.now = func() time.Time { return now }
+	rw.periodStart = rw.startOf(now)
+	fail := true
+	rw.openFile = func(n string) (*os.File, error) {
+		if fail && n == name {
+			return nil, errors.New("disk gone")
+		}
+		return openAppend(n)
+	}
+	rw.LogWrite("12345678")
+	// the rotation can't open a new file, so this goes on in the old one
+	if _, err := rw.Write([]byte("abcdefgh")); err != nil {
+		t.Fatalf("expected the write to go to the old file, got %v", err)
+	}
+	fail = false
+	rw.LogWrite("third")
+	if data, _ := os.ReadFile(name + ".2012-03-04"); string(data) != "12345678\nabcdefgh\n" {
+		t.Errorf("expected both records in the rotated file, got %q", data)
+	}
+	if data, _ := os.ReadFile(name); string(data) != "third\n" {
+		t.Errorf("expected the retried rotation to open a new file, got %q", data)
+	}
+}
+
+func TestRotatingNDJSON(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "app.ndjson")
+	rw, err := NewRotatingFileWriter(name, 300, RotateNever)
+	if err != nil {
+		t.Fatal(err)
+	}
+	log := NewTimber()
+	log.AddLogger(ConfigLogger{LogWriter: rw, Level: INFO, Formatter: NewJSONFormatter()})
+	for i := 0; i < 20; i++ {
+		log.InfoContext(context.Background(), "record %d %s", i, strings.Repeat("x", i*5))
+	}
+	log.Close()
+
+	files := append(rotatedNames(t, name), filepath.Base(name))
+	if len(files) < 3 {
+		t.Fatalf("expected several rotations got %v", files)
+	}
+	records := 0
+	for _, file := range files {
+		b, err := os.ReadFile(filepath.Join(filepath.Dir(name), file))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(b) > 0 && b[len(b)-1] != '\n' {
+			t.Errorf("%s doesn't end with a newline", file)
+		}
+		for _, line := range strings.Split(strings.TrimSuffix(string(b), "\n"), "\n") {
+			if !json.Valid([]byte(line)) {
+				t.Errorf("%s has a partial record: %q", file, line)
+			}
+			records++
+		}
+	}
+	if records != 20 {
+		t.Errorf("expected 20 records got %d", records)
+	}
+}
+
+func TestRotatingFileWriterRetention(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "app.log")
+	rw, err := NewRotatingFileWriter(name, 5, RotateNever)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rw.SetRetention(2, 0)
+	for i := 0; i < 5; i++ {
+		rw.LogWrite("1234")
+	}
+	rw.Close()
+	if got := rotatedNames(t, name); len(got) != 2 {
+		t.Errorf("expected 2 backups got %v", got)
+	}
+}
+
+func TestRotatingFileWriterCompress(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "app.log")
+	writer, err := getJSONFileWriter(JSONFilter{Properties: []JSONProperty{
+		{"filename", name}, {"maxsize", "5"}, {"maxbackups", "2"}, {"compress", "true"}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 4; i++ {
+		writer.LogWrite(strconv.Itoa(i) + "234")
+	}
+	writer.Close()
+
+	got := rotatedNames(t, name)
+	if len(got) != 2 {
+		t.Fatalf("expected 2 backups got %v", got)
+	}
+	for _, backup := range got {
+		if !strings.HasSuffix(backup, ".gz") {
+			t.Errorf("expected %s to be compressed", backup)
+		}
+	}
+	file, err := os.Open(filepath.Join(filepath.Dir(name), got[len(got)-1]))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	gz, err := gzip.NewReader(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := io.ReadAll(gz); string(data) != "2234\n" {
+		t.Errorf("expected the newest backup to hold 2234, got %q", data)
+	}
+	if _, err = getJSONFileWriter(JSONFilter{Properties: []JSONProperty{
+		{"filename", name}, {"max_size", "5"}, {"compress", "maybe"}}}); err == nil {
+		t.Error("expected an error for a bad compress")
+	}
+}
+
+func TestParseSize(t *testing.T) {
+	for in, expected := range map[string]int64{"100": 100, "4KB": 4096, "100MB": 100 << 20, " 1 gb": 1 << 30} {
+		if got, err := parseSize(in); err != nil || got != expected {
+			t.Errorf("%q: expected %d got %d %v", in, expected, got, err)
+		}
+	}
+	if _, err := parseSize("lots"); err == nil {
+		t.Error("expected an error")
+	}
+}
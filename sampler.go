@@ -0,0 +1,39 @@
+package timber
+
+import (
+	"sync/atomic"
+)
+
+// Thins out a logger's records by keeping 1 of every N.  Records at or
+// above KeepAbove skip sampling so errors are never lost while the
+// debug and info firehose is cut down.  Set it as ConfigLogger.Sampler;
+// with the config files use the sample and sample_keep_above properties.
+type Sampler struct {
+	N         int
+	KeepAbove Level
+	seen      uint64
+	dropped   uint64
+}
+
+// Keeps 1 of every n records below ERROR
+func NewSampler(n int) *Sampler {
+	return &Sampler{N: n, KeepAbove: ERROR}
+}
+
+// True if rec should be written.  Only records below KeepAbove are
+// counted, so the first of them is always kept.
+func (s *Sampler) keep(rec *LogRecord) bool {
+	if s.N <= 1 || rec.Level >= s.KeepAbove {
+		return true
+	}
+	if (atomic.AddUint64(&s.seen, 1)-1)%uint64(s.N) == 0 {
+		return true
+	}
+	atomic.AddUint64(&s.dropped, 1)
+	return false
+}
+
+// How many records have been sampled away
+func (s *Sampler) Dropped() uint64 {
+	return atomic.LoadUint64(&s.dropped)
+}
@@ -0,0 +1,303 @@
+// Package sentry provides a timber LogWriter that turns high severity
+// records into Sentry events.  It's a separate package so only
+// applications that report to Sentry carry it.  Importing it registers
+// the sentry filter type with the config loaders:
+//   import _ "github.com/smw1218/timber/sentry"
+package sentry
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/smw1218/timber"
+)
+
+// Defaults for the SentryWriter
+const (
+	DefaultFlushTimeout = 2 * time.Second
+	maxBreadcrumbs      = 100
+	queueSize           = 100
+)
+
+// Records at or above the level are sent to Sentry as events (with the
+// record fields as extra data and the call site as the stack trace);
+// lower levels are kept as breadcrumbs and attached to the next event.
+// Events are sent asynchronously, Close waits up to the flush timeout for
+// the queue to drain and records after it are dropped.  Errors are sent
+// to timber.WriteErrorHandler.
+type SentryWriter struct {
+	level        timber.Level
+	flushTimeout time.Duration
+	endpoint     string
+	auth         string
+	client       *http.Client
+	breadcrumbs  []breadcrumb
+	queue        chan []byte
+	done         chan int
+
+	// set by Close under the write lock, LogWriteRecord holds the read
+	// lock to queue
+	closeMu sync.RWMutex
+	closed  bool
+}
+
+type breadcrumb struct {
+	Timestamp float64 `json:"timestamp"`
+	Level     string  `json:"level"`
+	Message   string  `json:"message"`
+	Category  string  `json:"category,omitempty"`
+}
+
+type frame struct {
+	Filename string `json:"filename"`
+	Function string `json:"function"`
+	Module   string `json:"module,omitempty"`
+	Lineno   int    `json:"lineno"`
+}
+
+type event struct {
+	EventID     string                  `json:"event_id"`
+	Timestamp   float64                 `json:"timestamp"`
+	Level       string                  `json:"level"`
+	Logger      string                  `json:"logger"`
+	Platform    string                  `json:"platform"`
+	Message     map[string]string       `json:"message"`
+	Culprit     string                  `json:"culprit,omitempty"`
+	Extra       map[string]interface{}  `json:"extra,omitempty"`
+	Breadcrumbs map[string][]breadcrumb `json:"breadcrumbs,omitempty"`
+	Stacktrace  map[string][]frame      `json:"stacktrace,omitempty"`
+}
+
+// dsn is the project DSN from Sentry e.g. https://key@o0.ingest.sentry.io/42
+func NewSentryWriter(dsn string, level timber.Level, flushTimeout time.Duration) (*SentryWriter, error) {
+	u, err := url.Parse(dsn)
+	if err != nil || u.User == nil || u.Host == "" {
+		return nil, fmt.Errorf("TIMBER! Bad sentry dsn %q", dsn)
+	}
+	path := strings.TrimSuffix(u.Path, "/")
+	slash := strings.LastIndex(path, "/")
+	project := path[slash+1:]
+	if project == "" {
+		return nil, fmt.Errorf("TIMBER! No project in sentry dsn %q", dsn)
+	}
+	if flushTimeout <= 0 {
+		flushTimeout = DefaultFlushTimeout
+	}
+	sw := &SentryWriter{
+		level:        level,
+		flushTimeout: flushTimeout,
+		endpoint:     u.Scheme + "://" + u.Host + path[:slash] + "/api/" + project + "/envelope/",
+		auth:         "Sentry sentry_version=7, sentry_client=timber/1.0, sentry_key=" + u.User.Username(),
+		client:       &http.Client{Timeout: 10 * time.Second},
+		queue:        make(chan []byte, queueSize),
+		done:         make(chan int),
+	}
+	go sw.sendLoop()
+	return sw, nil
+}
+
+func init() {
+	timber.RegisterWriterFactory("sentry", NewFromFilter)
+}
+
+// The writer factory for filters of type sentry.  Reads the dsn, level
+// (default ERROR) and flush_timeout properties.
+func NewFromFilter(filter timber.JSONFilter) (timber.LogWriter, error) {
+	var dsn string
+	level := timber.ERROR
+	var flushTimeout time.Duration
+	for _, prop := range filter.Properties {
+		switch prop.Name {
+		case "dsn":
+			dsn = prop.Value
+		case "level":
+			var err error
+			if level, err = timber.ParseLevel(prop.Value); err != nil {
+				return nil, err
+			}
+		case "flush_timeout":
+			var err error
+			if flushTimeout, err = time.ParseDuration(prop.Value); err != nil {
+				return nil, fmt.Errorf("TIMBER! Bad flush_timeout for sentry log writer: %v", err)
+			}
+		}
+	}
+	if dsn == "" {
+		return nil, fmt.Errorf("TIMBER! Missing dsn for sentry log writer")
+	}
+	return NewSentryWriter(dsn, level, flushTimeout)
+}
+
+// Without a record there's no level so the message is only a breadcrumb
+func (sw *SentryWriter) LogWrite(msg string) {
+	sw.addBreadcrumb(time.Now(), timber.NONE, msg)
+}
+
+// RecordWriter interface
+func (sw *SentryWriter) LogWriteRecord(rec *timber.LogRecord, msg string) {
+	if rec.Level < sw.level {
+		sw.addBreadcrumb(rec.Timestamp, rec.Level, rec.Message)
+		return
+	}
+	ev := event{
+		EventID:   newEventID(),
+		Timestamp: unixSeconds(rec.Timestamp),
+		Level:     sentryLevel(rec.Level),
+		Logger:    rec.PackagePath,
+		Platform:  "go",
+		Message:   map[string]string{"formatted": strings.TrimSpace(msg)},
+		Culprit:   rec.FuncPath,
+	}
+	if len(rec.Fields) > 0 {
+		ev.Extra = make(map[string]interface{}, len(rec.Fields))
+		for k, v := range rec.Fields {
+			ev.Extra[k] = extraValue(v)
+		}
+	}
+	if rec.SourceFile != "" {
+		ev.Stacktrace = map[string][]frame{"frames": {{
+			Filename: rec.SourceFile,
+			Function: rec.FuncPath,
+			Module:   rec.PackagePath,
+			Lineno:   rec.SourceLine,
+		}}}
+	}
+	if len(sw.breadcrumbs) > 0 {
+		ev.Breadcrumbs = map[string][]breadcrumb{"values": sw.breadcrumbs}
+		sw.breadcrumbs = nil
+	}
+	envelope, err := sw.envelope(ev)
+	if err != nil {
+		sw.reportError(err)
+		return
+	}
+	sw.closeMu.RLock()
+	defer sw.closeMu.RUnlock()
+	if sw.closed {
+		return
+	}
+	select {
+	case sw.queue <- envelope:
+	default:
+		sw.reportError(fmt.Errorf("TIMBER! sentry queue full, dropped event"))
+	}
+}
+
+// A field as something json can marshal and Sentry can show: the text
+// of errors and Stringers, fmt.Sprint of anything but plain values
+func extraValue(val interface{}) interface{} {
+	switch v := val.(type) {
+	case nil, string, bool, int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64, float32, float64:
+		return v
+	case error:
+		return v.Error()
+	case fmt.Stringer:
+		return v.String()
+	}
+	return fmt.Sprint(val)
+}
+
+func (sw *SentryWriter) addBreadcrumb(ts time.Time, level timber.Level, msg string) {
+	if len(sw.breadcrumbs) == maxBreadcrumbs {
+		sw.breadcrumbs = sw.breadcrumbs[1:]
+	}
+	sw.breadcrumbs = append(sw.breadcrumbs, breadcrumb{
+		Timestamp: unixSeconds(ts),
+		Level:     sentryLevel(level),
+		Message:   strings.TrimSpace(msg),
+		Category:  "log",
+	})
+}
+
+// Waits up to the flush timeout for queued events to be sent
+func (sw *SentryWriter) Close() {
+	sw.closeMu.Lock()
+	if !sw.closed {
+		sw.closed = true
+		close(sw.queue)
+	}
+	sw.closeMu.Unlock()
+	select {
+	case <-sw.done:
+	case <-time.After(sw.flushTimeout):
+		sw.reportError(fmt.Errorf("TIMBER! sentry flush timed out, %d events not sent", len(sw.queue)))
+	}
+}
+
+func (sw *SentryWriter) sendLoop() {
+	defer close(sw.done)
+	for envelope := range sw.queue {
+		if err := sw.send(envelope); err != nil {
+			sw.reportError(err)
+		}
+	}
+}
+
+func (sw *SentryWriter) send(envelope []byte) error {
+	req, err := http.NewRequest("POST", sw.endpoint, bytes.NewReader(envelope))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-sentry-envelope")
+	req.Header.Set("X-Sentry-Auth", sw.auth)
+	resp, err := sw.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("TIMBER! sentry send failed: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("TIMBER! sentry send failed: %v", resp.Status)
+	}
+	return nil
+}
+
+func (sw *SentryWriter) envelope(ev event) ([]byte, error) {
+	payload, err := json.Marshal(ev)
+	if err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "{\"event_id\":%q}\n{\"type\":\"event\",\"length\":%d}\n", ev.EventID, len(payload))
+	buf.Write(payload)
+	buf.WriteByte('\n')
+	return buf.Bytes(), nil
+}
+
+func (sw *SentryWriter) reportError(err error) {
+	if handler := timber.WriteErrorHandler; handler != nil {
+		handler(sw, err)
+	}
+}
+
+func sentryLevel(level timber.Level) string {
+	switch {
+	case level >= timber.CRITICAL:
+		return "fatal"
+	case level == timber.ERROR:
+		return "error"
+	case level == timber.WARNING:
+		return "warning"
+	case level >= timber.TRACE || level == timber.NONE:
+		return "info"
+	default:
+		return "debug"
+	}
+}
+
+func unixSeconds(t time.Time) float64 {
+	return float64(t.UnixNano()) / float64(time.Second)
+}
+
+func newEventID() string {
+	id := make([]byte, 16)
+	rand.Read(id)
+	return hex.EncodeToString(id)
+}
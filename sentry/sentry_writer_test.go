@@ -0,0 +1,55 @@
+package sentry
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/smw1218/timber"
+)
+
+func TestSentryWriter(t *testing.T) {
+	events := make(chan event, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/42/envelope/" || !strings.Contains(r.Header.Get("X-Sentry-Auth"), "sentry_key=public") {
+			t.Errorf("unexpected request %v %v", r.URL.Path, r.Header.Get("X-Sentry-Auth"))
+		}
+		scanner := bufio.NewScanner(r.Body)
+		scanner.Scan() // envelope header
+		scanner.Scan() // item header
+		scanner.Scan()
+		var ev event
+		json.Unmarshal(scanner.Bytes(), &ev)
+		events <- ev
+	}))
+	defer server.Close()
+
+	dsn := strings.Replace(server.URL, "://", "://public@", 1) + "/42"
+	sw, err := NewSentryWriter(dsn, timber.ERROR, time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sw.LogWriteRecord(&timber.LogRecord{Level: timber.INFO, Message: "before"}, "before\n")
+	sw.LogWriteRecord(&timber.LogRecord{Level: timber.ERROR, Message: "broken",
+		Fields: timber.Fields{"user": "bob", "err": errors.New("no disk"), "ch": make(chan int), "n": 3}}, "broken\n")
+	sw.Close()
+	// dropped, not a panic
+	sw.LogWriteRecord(&timber.LogRecord{Level: timber.ERROR, Message: "late"}, "late\n")
+	sw.Close()
+
+	ev := <-events
+	if ev.Level != "error" || ev.Message["formatted"] != "broken" || ev.Extra["user"] != "bob" {
+		t.Errorf("unexpected event %+v", ev)
+	}
+	if ch, _ := ev.Extra["ch"].(string); ev.Extra["err"] != "no disk" || ev.Extra["n"] != 3.0 || !strings.HasPrefix(ch, "0x") {
+		t.Errorf("expected the fields as json values, got %+v", ev.Extra)
+	}
+	if crumbs := ev.Breadcrumbs["values"]; len(crumbs) != 1 || crumbs[0].Message != "before" {
+		t.Errorf("unexpected breadcrumbs %+v", crumbs)
+	}
+}
@@ -0,0 +1,57 @@
+package timber
+
+// Syslog severities from RFC 5424, shared by the writers and formatters
+// that need one (syslog, GELF, journald...) so they all agree
+const (
+	SeverityEmergency = iota
+	SeverityAlert
+	SeverityCritical
+	SeverityError
+	SeverityWarning
+	SeverityNotice
+	SeverityInformational
+	SeverityDebug
+)
+
+var levelSeverities = [...]int{
+	NONE:     SeverityInformational,
+	FINEST:   SeverityDebug,
+	FINE:     SeverityDebug,
+	DEBUG:    SeverityDebug,
+	TRACE:    SeverityInformational,
+	INFO:     SeverityInformational,
+	WARNING:  SeverityWarning,
+	ERROR:    SeverityError,
+	CRITICAL: SeverityCritical,
+}
+
+// Returns the syslog severity for lvl.  Levels above CRITICAL are
+// critical and unknown levels below are informational.
+func SyslogSeverity(lvl Level) int {
+	switch {
+	case lvl > CRITICAL:
+		return SeverityCritical
+	case lvl < NONE:
+		return SeverityInformational
+	}
+	return levelSeverities[lvl]
+}
+
+// The inverse of SyslogSeverity: returns the Level for a syslog
+// severity.  Emergency and alert have no level of their own and come back
+// as CRITICAL, notice as INFO; anything out of range is NONE.
+func SeverityLevel(sev int) Level {
+	switch sev {
+	case SeverityEmergency, SeverityAlert, SeverityCritical:
+		return CRITICAL
+	case SeverityError:
+		return ERROR
+	case SeverityWarning:
+		return WARNING
+	case SeverityNotice, SeverityInformational:
+		return INFO
+	case SeverityDebug:
+		return DEBUG
+	}
+	return NONE
+}
@@ -0,0 +1,159 @@
+package timber
+
+import (
+	"fmt"
+	"os"
+	"runtime"
+	"sync"
+	"time"
+)
+
+// Writes records, one per line, to a file that other processes append to
+// as well, e.g. the workers of a preforking server sharing one log.  Each
+// record goes out with its newline in a single write on an O_APPEND file,
+// which a local filesystem keeps whole, so lines from different processes
+// never mix the way the NewFileWriter buffer's 4k flushes do.  SetLocking
+// adds an flock around every write for filesystems that don't append
+// atomically, NFS most of all.
+//
+// The writer notices when the file is rotated under it: the name is
+// checked at most once per SetCheckInterval and the file reopened when it
+// has been moved or removed, so logrotate works without copytruncate or a
+// signal.  It's also a Reopener for InstallSignalReopen.  When the file
+// can't be opened again the writes fail and the open is retried at most
+// once per sharedFileRetry.  Writes are unbuffered; put an AsyncWriter in
+// front if the disk is slow.
+type SharedFileWriter struct {
+	name string
+
+	mu         sync.Mutex
+	file       *os.File
+	info       os.FileInfo // of file, to tell when name is another file
+	lock       bool
+	checkEvery time.Duration
+	checked    time.Time
+	retried    time.Time // the last failed open
+	closed     bool
+	now        func() time.Time // swapped out by the tests
+}
+
+// How often a SharedFileWriter whose file couldn't be reopened tries again
+const sharedFileRetry = time.Second
+
+func NewSharedFileWriter(name string) (*SharedFileWriter, error) {
+	sw := &SharedFileWriter{name: name, checkEvery: time.Second, now: time.Now}
+	if err := sw.open(); err != nil {
+		return nil, err
+	}
+	return sw, nil
+}
+
+// Takes an exclusive flock on the file for every write.  Only the
+// processes that lock honor it, so turn it on in all of them.  Fails
+// where there's no flock.
+func (sw *SharedFileWriter) SetLocking(lock bool) error {
+	if lock && !canLockFiles {
+		return fmt.Errorf("TIMBER! File locking is not supported on %s", runtime.GOOS)
+	}
+	sw.mu.Lock()
+	sw.lock = lock
+	sw.mu.Unlock()
+	return nil
+}
+
+// How often the name is checked for a rotation, a second by default.
+// Zero checks before every write, less than zero never does.
+func (sw *SharedFileWriter) SetCheckInterval(interval time.Duration) {
+	sw.mu.Lock()
+	sw.checkEvery = interval
+	sw.mu.Unlock()
+}
+
+// must hold mu
+func (sw *SharedFileWriter) open() error {
+	file, err := os.OpenFile(sw.name, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0666)
+	if err != nil {
+		sw.retried = sw.now()
+		return fmt.Errorf("TIMBER! Can't open %v: %v", sw.name, err)
+	}
+	sw.file, sw.info = file, nil
+	if info, err := file.Stat(); err == nil {
+		sw.info = info
+	}
+	sw.checked = sw.now()
+	return nil
+}
+
+// must hold mu; reopens when name is no longer the open file
+func (sw *SharedFileWriter) checkRotated() {
+	if sw.checkEvery < 0 || sw.info == nil {
+		return
+	}
+	now := sw.now()
+	if sw.checkEvery > 0 && now.Sub(sw.checked) < sw.checkEvery {
+		return
+	}
+	sw.checked = now
+	if info, err := os.Stat(sw.name); err == nil && os.SameFile(info, sw.info) {
+		return
+	}
+	sw.file.Close()
+	sw.file = nil
+	if err := sw.open(); err != nil {
+		reportWriteError(sw, err)
+	}
+}
+
+func (sw *SharedFileWriter) LogWrite(msg string) {
+	sw.Write([]byte(msg))
+}
+
+// Allows formatters to write directly to the file
+func (sw *SharedFileWriter) Write(p []byte) (int, error) {
+	sw.mu.Lock()
+	defer sw.mu.Unlock()
+	if sw.file != nil {
+		sw.checkRotated()
+	} else if !sw.closed && sw.now().Sub(sw.retried) >= sharedFileRetry {
+		if err := sw.open(); err != nil {
+			reportWriteError(sw, err)
+		}
+	}
+	if sw.file == nil {
+		return 0, os.ErrClosed
+	}
+	if sw.lock {
+		if err := lockFile(sw.file); err != nil {
+			err = fmt.Errorf("TIMBER! Can't lock %v: %v", sw.name, err)
+			reportWriteError(sw, err)
+			return 0, err
+		}
+		defer unlockFile(sw.file)
+	}
+	n, err := writeTerminated(sw.file, p, newline)
+	if err != nil {
+		reportWriteError(sw, err)
+	}
+	return n, err
+}
+
+// Closes the current file and opens name again, see Reopener
+func (sw *SharedFileWriter) Reopen() error {
+	sw.mu.Lock()
+	defer sw.mu.Unlock()
+	if sw.file != nil {
+		sw.file.Close()
+		sw.file = nil
+	}
+	return sw.open()
+}
+
+func (sw *SharedFileWriter) Close() {
+	sw.mu.Lock()
+	defer sw.mu.Unlock()
+	sw.closed = true
+	if sw.file != nil {
+		sw.file.Close()
+		sw.file = nil
+	}
+}
@@ -0,0 +1,145 @@
+package timber
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func readLines(t *testing.T, name string) []string {
+	data, err := os.ReadFile(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return strings.Split(strings.TrimSuffix(string(data), "\n"), "\n")
+}
+
+// Writers of their own on the same file stand in for the processes
+func TestSharedFileWriterInterleaving(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "app.log")
+	record := strings.Repeat("x", 6000) // more than a bufio flush
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		sw, err := NewSharedFileWriter(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := sw.SetLocking(i%2 == 0 && canLockFiles); err != nil {
+			t.Fatal(err)
+		}
+		wg.Add(1)
+		go func(sw *SharedFileWriter, id string) {
+			defer wg.Done()
+			defer sw.Close()
+			for j := 0; j < 50; j++ {
+				sw.LogWrite(id + record)
+			}
+		}(sw, string(rune('a'+i)))
+	}
+	wg.Wait()
+	lines := readLines(t, name)
+	if len(lines) != 200 {
+		t.Fatalf("expected 200 lines got %d", len(lines))
+	}
+	for i, line := range lines {
+		if len(line) != len(record)+1 || strings.Trim(line[1:], "x") != "" {
+			t.Fatalf("line %d is mixed up: %.40q", i, line)
+		}
+	}
+}
+
+func TestSharedFileWriterRotated(t *testing.T) {
+	dir := t.TempDir()
+	name := filepath.Join(dir, "app.log")
+	now := time.Date(2012, 3, 4, 10, 0, 0, 0, time.Local)
+	sw, err := NewSharedFileWriter(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sw.now = func() time.Time { return now }
+	sw.checked = now
+	sw.LogWrite("before")
+	// what logrotate does without copytruncate
+	if err := os.Rename(name, name+".1"); err != nil {
+		t.Fatal(err)
+	}
+	sw.LogWrite("not checked yet")
+	now = now.Add(time.Second)
+	sw.LogWrite("after")
+	if got := readLines(t, name); strings.Join(got, ",") != "after" {
+		t.Errorf("expected a new file after the check, got %q", got)
+	}
+	if err := os.Remove(name); err != nil {
+		t.Fatal(err)
+	}
+	sw.SetCheckInterval(0)
+	sw.LogWrite("removed")
+	sw.Close()
+	if got := readLines(t, name+".1"); strings.Join(got, ",") != "before,not checked yet" {
+		t.Errorf("expected the old file to get the records until the check, got %q", got)
+	}
+	if got := readLines(t, name); strings.Join(got, ",") != "removed" {
+		t.Errorf("expected a new file after the remove, got %q", got)
+	}
+}
+
+func TestSharedFileWriterRetry(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "logs")
+	if err := os.Mkdir(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	name := filepath.Join(dir, "app.log")
+	now := time.Date(2012, 3, 4, 10, 0, 0, 0, time.Local)
+	sw, err := NewSharedFileWriter(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sw.Close()
+	sw.now = func() time.Time { return now }
+	sw.SetCheckInterval(0)
+	// the directory goes away, so the reopen fails
+	if err := os.Rename(dir, dir+".old"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := sw.Write([]byte("lost")); err == nil {
+		t.Fatal("expected the write to fail without the directory")
+	}
+	if err := os.Mkdir(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := sw.Write([]byte("too soon")); err == nil {
+		t.Error("expected no retry before sharedFileRetry")
+	}
+	now = now.Add(sharedFileRetry)
+	if _, err := sw.Write([]byte("retried")); err != nil {
+		t.Errorf("expected the retry to open the file, got %v", err)
+	}
+	if got := readLines(t, name); strings.Join(got, ",") != "retried" {
+		t.Errorf("expected the record after the retry, got %q", got)
+	}
+}
+
+func TestSharedFileConfig(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "app.log")
+	writer, err := getJSONFileWriter(JSONFilter{Properties: []JSONProperty{{"filename", name}, {"shared", "true"},
+		{"reopen_check", "0"}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sw, ok := writer.(*SharedFileWriter)
+	if !ok || sw.checkEvery != 0 {
+		t.Fatalf("expected a SharedFileWriter checking every write got %T", writer)
+	}
+	sw.Close()
+	if _, err := getJSONFileWriter(JSONFilter{Properties: []JSONProperty{{"filename", name}, {"shared", "true"},
+		{"rotate", "daily"}}}); err == nil {
+		t.Error("expected an error for a shared file that rotates")
+	}
+	if _, err := getJSONFileWriter(JSONFilter{Properties: []JSONProperty{{"filename", name}, {"shared", "true"},
+		{"lock", "sometimes"}}}); err == nil {
+		t.Error("expected an error for a bad lock")
+	}
+}
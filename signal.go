@@ -0,0 +1,117 @@
+package timber
+
+import (
+	"log"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+)
+
+// Flushes the Global logger when one of sigs arrives then lets the
+// default action for the signal proceed.  Defaults to SIGINT and SIGTERM.
+// This is opt-in since it takes over the signal; don't use it if your
+// application handles these signals itself (call Flush or Close instead).
+// The package functions act on whichever logger is Global when the
+// signal arrives, see SetGlobal.
+func InstallSignalFlush(sigs ...os.Signal) { installSignalHandler(func() { global().Flush() }, sigs) }
+
+// Same as InstallSignalFlush but closes the Global logger and all of its writers
+func InstallSignalClose(sigs ...os.Signal) { installSignalHandler(func() { global().Close() }, sigs) }
+
+func (t *Timber) InstallSignalFlush(sigs ...os.Signal) {
+	installSignalHandler(t.Flush, sigs)
+}
+
+func (t *Timber) InstallSignalClose(sigs ...os.Signal) {
+	installSignalHandler(t.Close, sigs)
+}
+
+func installSignalHandler(handler func(), sigs []os.Signal) {
+	if len(sigs) == 0 {
+		sigs = []os.Signal{os.Interrupt, syscall.SIGTERM}
+	}
+	sigChan := make(chan os.Signal, 1)
+	signal.Notify(sigChan, sigs...)
+	go func() {
+		sig := <-sigChan
+		handler()
+		// restore the default behavior and send the signal again
+		signal.Reset(sigs...)
+		if p, err := os.FindProcess(os.Getpid()); err == nil {
+			p.Signal(sig)
+		}
+	}()
+}
+
+// Reopens the files of the Global logger's writers every time one of sigs
+// arrives, defaults to SIGHUP.  This is the usual hookup for logrotate:
+// it moves the file away then signals the process to start a new one.
+// Unlike the others this keeps handling the signal; the package function
+// stops once the Global logger it finds is closed, the method when the
+// Timber is closed.  Where there's no SIGHUP (js, plan9, wasip1) sigs has
+// to be given.
+func InstallSignalReopen(sigs ...os.Signal) {
+	installSignalLoop(global, sigs, (*Timber).Reopen)
+}
+
+func (t *Timber) InstallSignalReopen(sigs ...os.Signal) {
+	t.OnClose(installSignalLoop(func() *Timber { return t }, sigs, (*Timber).Reopen))
+}
+
+// Reloads the Global logger's config from filename every time one of
+// sigs arrives, defaults to SIGHUP.  See ReloadConfig; a config that
+// doesn't load is reported to the standard logger and the current loggers
+// are kept.  It keeps handling the signal until the logger is closed, as
+// InstallSignalReopen does.
+func InstallSignalReload(filename string, sigs ...os.Signal) {
+	installSignalLoop(global, sigs, reloader(filename))
+}
+
+func (t *Timber) InstallSignalReload(filename string, sigs ...os.Signal) {
+	t.OnClose(installSignalLoop(func() *Timber { return t }, sigs, reloader(filename)))
+}
+
+func reloader(filename string) func(t *Timber) {
+	return func(t *Timber) {
+		if err := t.ReloadConfig(filename); err != nil {
+			log.Printf("TIMBER! Keeping the current config: %v\n", err)
+		}
+	}
+}
+
+// Calls action with the current Timber every time one of sigs arrives,
+// hangupSignals without any, until that Timber is closed or the returned
+// func is called
+func installSignalLoop(current func() *Timber, sigs []os.Signal, action func(t *Timber)) (stop func()) {
+	if len(sigs) == 0 {
+		sigs = hangupSignals
+	}
+	if len(sigs) == 0 {
+		// Notify without signals would take all of them
+		return func() {}
+	}
+	sigChan := make(chan os.Signal, 1)
+	done := make(chan bool)
+	signal.Notify(sigChan, sigs...)
+	go func() {
+		defer signal.Stop(sigChan)
+		for {
+			select {
+			case <-sigChan:
+			case <-done:
+				return
+			}
+			t := current()
+			t.closeSync.RLock()
+			closed := t.closed
+			t.closeSync.RUnlock()
+			if closed {
+				return
+			}
+			action(t)
+		}
+	}()
+	var once sync.Once
+	return func() { once.Do(func() { close(done) }) }
+}
@@ -0,0 +1,11 @@
+//go:build !(js || plan9 || wasip1)
+
+package timber
+
+import (
+	"os"
+	"syscall"
+)
+
+// What InstallSignalReopen and InstallSignalReload take without signals
+var hangupSignals = []os.Signal{syscall.SIGHUP}
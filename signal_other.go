@@ -0,0 +1,9 @@
+//go:build js || plan9 || wasip1
+
+package timber
+
+import "os"
+
+// There's no SIGHUP here, InstallSignalReopen and InstallSignalReload
+// need their signals given
+var hangupSignals []os.Signal
@@ -0,0 +1,40 @@
+package timber
+
+import (
+	"os"
+	"runtime"
+	"testing"
+	"time"
+)
+
+func TestSignalLoop(t *testing.T) {
+	if len(hangupSignals) == 0 || runtime.GOOS == "windows" {
+		t.Skip("no SIGHUP to send")
+	}
+	log := NewTimber()
+	reopened := make(chan bool, 1)
+	stop := installSignalLoop(func() *Timber { return log }, nil, func(*Timber) { reopened <- true })
+	defer stop()
+	p, _ := os.FindProcess(os.Getpid())
+	p.Signal(hangupSignals[0])
+	select {
+	case <-reopened:
+	case <-time.After(time.Second):
+		t.Fatal("expected the action on SIGHUP")
+	}
+
+	// Close stops the loops of the methods without waiting for a signal
+	before := runtime.NumGoroutine()
+	log.InstallSignalReopen()
+	log.InstallSignalReload("nowhere.json")
+	if runtime.NumGoroutine() != before+2 {
+		t.Fatalf("expected 2 more goroutines than %d, got %d", before, runtime.NumGoroutine())
+	}
+	log.Close()
+	for deadline := time.Now().Add(time.Second); runtime.NumGoroutine() >= before && time.Now().Before(deadline); {
+		time.Sleep(5 * time.Millisecond)
+	}
+	if n := runtime.NumGoroutine(); n >= before {
+		t.Errorf("expected the signal loops gone after Close, %d goroutines from %d", n, before)
+	}
+}
@@ -0,0 +1,135 @@
+package timber
+
+import (
+	"context"
+	"log/slog"
+)
+
+// A log/slog Handler that sends records through a Timber, so code that
+// logs with slog gets the same writers, formatters and granulars as the
+// rest of the program:
+//
+//	slog.SetDefault(slog.New(timber.NewSlogHandler(nil)))
+//
+// Attrs become record Fields, after any from the context (see
+// RegisterContextExtractor) so the attrs win.  Groups are flattened into the
+// keys with dots, e.g. req.method.  The source is the slog call site, so
+// granulars match the package that called slog.  The slog levels map to
+// DEBUG, INFO, WARNING and ERROR; lower levels are FINE and FINEST, the
+// ones between debug and info are TRACE and 12 and up is CRITICAL.
+type SlogHandler struct {
+	t      *Timber
+	attrs  Fields // from WithAttrs, already prefixed
+	prefix string // the open groups, "a.b." for WithGroup("a").WithGroup("b")
+}
+
+// The handler for t, the Global logger if t is nil
+func NewSlogHandler(t *Timber) *SlogHandler {
+	return &SlogHandler{t: t}
+}
+
+func (h *SlogHandler) timber() *Timber {
+	if h.t == nil {
+		return global()
+	}
+	return h.t
+}
+
+func slogLevel(l slog.Level) Level {
+	switch {
+	case l >= slog.LevelError+4:
+		return CRITICAL
+	case l >= slog.LevelError:
+		return ERROR
+	case l >= slog.LevelWarn:
+		return WARNING
+	case l >= slog.LevelInfo:
+		return INFO
+	case l >= slog.LevelDebug+2:
+		return TRACE
+	case l >= slog.LevelDebug:
+		return DEBUG
+	case l >= slog.LevelDebug-4:
+		return FINE
+	}
+	return FINEST
+}
+
+func (h *SlogHandler) Enabled(ctx context.Context, l slog.Level) bool {
+	return !h.timber().skipContext(ctx, slogLevel(l))
+}
+
+func (h *SlogHandler) Handle(ctx context.Context, r slog.Record) error {
+	t := h.timber()
+	lvl := slogLevel(r.Level)
+	if t.skipContext(ctx, lvl) {
+		return nil
+	}
+	now := r.Time
+	if now.IsZero() {
+		now = currentTime()
+	}
+	rec := prepareAt(lvl, r.Message, now, r.PC)
+	rec.elevated = contextElevates(ctx, lvl)
+	rec.skipMDC = hasMDC(ctx)
+	fields := make(Fields, len(h.attrs)+r.NumAttrs())
+	for key, value := range fieldsFromContext(ctx) {
+		fields[key] = value
+	}
+	for key, value := range h.attrs {
+		fields[key] = value
+	}
+	r.Attrs(func(a slog.Attr) bool {
+		addSlogAttr(fields, h.prefix, a)
+		return true
+	})
+	if len(fields) > 0 {
+		rec.Fields = fields
+	}
+	t.send(rec)
+	return nil
+}
+
+func (h *SlogHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	if len(attrs) == 0 {
+		return h
+	}
+	fields := make(Fields, len(h.attrs)+len(attrs))
+	for key, value := range h.attrs {
+		fields[key] = value
+	}
+	for _, a := range attrs {
+		addSlogAttr(fields, h.prefix, a)
+	}
+	return &SlogHandler{t: h.t, attrs: fields, prefix: h.prefix}
+}
+
+func (h *SlogHandler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+	return &SlogHandler{t: h.t, attrs: h.attrs, prefix: h.prefix + name + "."}
+}
+
+// Follows the slog.Handler rules: empty attrs are dropped, groups are
+// flattened and a group with no key is inlined
+func addSlogAttr(fields Fields, prefix string, a slog.Attr) {
+	value := a.Value.Resolve()
+	if value.Kind() == slog.KindGroup {
+		group := value.Group()
+		if len(group) == 0 {
+			return
+		}
+		if a.Key != "" {
+			prefix += a.Key + "."
+		}
+		for _, ga := range group {
+			addSlogAttr(fields, prefix, ga)
+		}
+		return
+	}
+	if a.Key == "" {
+		return
+	}
+	fields[prefix+a.Key] = value.Any()
+}
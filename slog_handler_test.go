@@ -0,0 +1,46 @@
+package timber
+
+import (
+	"context"
+	"log/slog"
+	"reflect"
+	"testing"
+)
+
+func TestSlogHandler(t *testing.T) {
+	log := NewTimber()
+	mw := NewMemoryWriter(10)
+	log.AddLogger(ConfigLogger{LogWriter: mw, Level: INFO, Formatter: NewPatFormatter("%L %s %M"),
+		Granulars: map[string]Level{"github.com/smw1218/timber": DEBUG}})
+	other := NewMemoryWriter(10)
+	log.AddLogger(ConfigLogger{LogWriter: other, Level: WARNING, Formatter: &fieldsFormatter{}})
+
+	logger := slog.New(NewSlogHandler(log))
+	if !logger.Enabled(context.Background(), slog.LevelDebug) || logger.Enabled(context.Background(), slog.LevelDebug-8) {
+		t.Error("expected debug enabled through the granular and finest not")
+	}
+	logger.Debug("from slog") // the granular lets it through the first logger
+	logger.With("user", "ann").WithGroup("req").Warn("slow", "ms", 250, slog.Group("client", "ip", "10.0.0.1"))
+	logger.Log(context.Background(), slog.LevelDebug-8, "too fine")
+	log.Close()
+
+	expected := []string{"DEBG slog_handler_test.go:22 from slog", "WARN slog_handler_test.go:23 slow"}
+	if got := mw.Messages(); !reflect.DeepEqual(got, expected) {
+		t.Errorf("expected %q got %q", expected, got)
+	}
+	expectedFields := []string{"WARN slow map[req.client.ip:10.0.0.1 req.ms:250 user:ann]"}
+	if got := other.Messages(); !reflect.DeepEqual(got, expectedFields) {
+		t.Errorf("expected %q got %q", expectedFields, got)
+	}
+}
+
+func TestSlogLevels(t *testing.T) {
+	for l, expected := range map[slog.Level]Level{
+		slog.LevelDebug - 8: FINEST, slog.LevelDebug - 4: FINE, slog.LevelDebug: DEBUG, slog.LevelDebug + 2: TRACE,
+		slog.LevelInfo: INFO, slog.LevelWarn: WARNING, slog.LevelError: ERROR, slog.LevelError + 4: CRITICAL,
+	} {
+		if got := slogLevel(l); got != expected {
+			t.Errorf("%v: expected %v got %v", l, LongLevelStrings[expected], LongLevelStrings[got])
+		}
+	}
+}
@@ -1,55 +1,580 @@
 package timber
 
 import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"crypto/tls"
+	"errors"
 	"fmt"
+	"io"
 	"net"
+	"os"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
-// This should write to anything that you can write to with net.Dial
+var errNotConnected = errors.New("TIMBER! Socket not connected")
+
+// How long a SocketWriter waits before the first reconnect attempt and
+// the most it backs off to, see SetReconnectBackoff
+const (
+	DefaultReconnectMin = 100 * time.Millisecond
+	DefaultReconnectMax = 30 * time.Second
+)
+
+// This should write to anything that you can write to with net.Dial,
+// including a local collector on a unix or unixgram socket (the address
+// is then the socket's path).  The network tls is TCP with TLS on top,
+// see NewTLSSocketWriter for anything but the system roots.
+// Stream sockets end each record with a newline and datagram sockets send
+// each record as is; see SetFraming.
+// A failed write starts reconnecting in the background, backing off
+// exponentially; records written meanwhile are dropped unless there's a
+// SetReconnectBuffer or SetFallbackFile to hold them.
 type SocketWriter struct {
-	conn        net.Conn
-	network     string
-	addr        string
-	connSync    *sync.RWMutex
-	restartOnce *sync.Once
+	conn         net.Conn // nil while disconnected, see NewLazySocketWriter and SetIdleTimeout
+	network      string
+	addr         string
+	tlsConfig    *tls.Config
+	framing      []byte
+	connSync     *sync.RWMutex
+	reconnecting bool          // guarded by connSync
+	closed       bool          // guarded by connSync
+	done         chan struct{} // closed by Close to cut the backoff short
+	retryMin     time.Duration // guarded by connSync
+	retryMax     time.Duration // guarded by connSync
+	// only set for compressed sockets
+	gz        *gzip.Writer
+	gzSync    *sync.Mutex
+	autoFlush *time.Ticker
+	stopFlush chan bool
+	batch     BatchConfig
+	pending   int // writes since the last gzip flush, guarded by gzSync
+	pendingSz int // bytes since the last gzip flush, guarded by gzSync
+	// only set with SetIdleTimeout
+	idleTimeout time.Duration
+	idleTimer   *time.Timer
+	lastWrite   int64 // unix nanos, accessed atomically
+	// only set with SetFallbackFile, the pointers are guarded by connSync
+	// and the file by fallbackSync
+	fallback       *os.File
+	fallbackReplay bool
+	fallbackSync   sync.Mutex
+	// only set with SetReconnectBuffer, the pointer is guarded by connSync
+	// and the contents by fallbackSync
+	ring *messageRing
 }
 
 func NewSocketWriter(network, addr string) (*SocketWriter, error) {
-	conn, err := net.Dial(network, addr)
+	return newSocketWriter(network, addr, false, false, nil)
+}
+
+// A tls socket with cfg for the certificates, e.g. a client certificate
+// or the CA of a private collector.  A nil cfg is the same as
+// NewSocketWriter("tls", addr).  Without a ServerName in cfg the host of
+// addr is verified.
+func NewTLSSocketWriter(addr string, cfg *tls.Config) (*SocketWriter, error) {
+	return newSocketWriter("tls", addr, false, false, cfg)
+}
+
+// Same as NewSocketWriter but the stream is gzip compressed.  The
+// collector has to know to expect gzip, there's no negotiation.  The gzip
+// stream is flushed once a second and on Flush so messages are never held
+// for long; see SetBatch to change that.  Each reconnect starts a new gzip
+// stream.  Only stream networks (tcp, unix) are supported.
+func NewCompressedSocketWriter(network, addr string) (*SocketWriter, error) {
+	return newSocketWriter(network, addr, true, false, nil)
+}
+
+// Doesn't dial up front, the writer starts out disconnected and connects
+// in the background the same way it reconnects after a failure.  Messages
+// written before the connection is up are reported to WriteErrorHandler
+// and dropped, unless there's a reconnect buffer or fallback file.  A
+// collector that's down at startup doesn't stop the app from starting.
+func NewLazySocketWriter(network, addr string) *SocketWriter {
+	sw, _ := newSocketWriter(network, addr, false, true, nil)
+	return sw
+}
+
+// The compressed version of NewLazySocketWriter
+func NewLazyCompressedSocketWriter(network, addr string) (*SocketWriter, error) {
+	return newSocketWriter(network, addr, true, true, nil)
+}
+
+func isDatagram(network string) bool {
+	return strings.HasPrefix(network, "udp") || network == "unixgram" || strings.HasPrefix(network, "ip")
+}
+
+func newSocketWriter(network, addr string, compress, lazy bool, tlsConfig *tls.Config) (*SocketWriter, error) {
+	datagram := isDatagram(network)
+	if compress && datagram {
+		return nil, fmt.Errorf("TIMBER! Can't compress a %s socket, only stream sockets are supported", network)
+	}
+	sw := &SocketWriter{network: network, addr: addr, tlsConfig: tlsConfig, connSync: &sync.RWMutex{},
+		done: make(chan struct{}), retryMin: DefaultReconnectMin, retryMax: DefaultReconnectMax}
+	if !datagram {
+		sw.framing = newline
+	}
+	if !lazy {
+		conn, err := sw.dial()
+		if err != nil {
+			return nil, err
+		}
+		sw.conn = conn
+	}
+	if compress {
+		sw.gz = gzip.NewWriter(sw.conn) // reset on connect when lazy
+		sw.gzSync = &sync.Mutex{}
+		sw.batch = BatchConfig{Interval: time.Second}
+		sw.autoFlush = time.NewTicker(sw.batch.Interval)
+		sw.stopFlush = make(chan bool)
+		go sw.flushLoop()
+	}
+	if lazy {
+		sw.reconnecting = true
+		go sw.reconnect()
+	}
+	return sw, nil
+}
+
+func (sw *SocketWriter) dial() (net.Conn, error) {
+	if sw.network == "tls" {
+		return tls.Dial("tcp", sw.addr, sw.tlsConfig)
+	}
+	return net.Dial(sw.network, sw.addr)
+}
+
+func (sw *SocketWriter) flushLoop() {
+	for {
+		select {
+		case <-sw.autoFlush.C:
+			sw.Flush()
+		case <-sw.stopFlush:
+			return
+		}
+	}
+}
+
+// Sets what's written after each record, e.g. "\x00" for GELF over TCP or
+// "" when the collector frames messages some other way.  Call it before
+// the writer is used.
+func (sw *SocketWriter) SetFraming(delim string) {
+	sw.framing = []byte(delim)
+}
+
+// Sets when a compressed socket flushes the gzip stream: before the
+// writes or bytes since the last flush would pass the limits and at least
+// every Interval.  It has no effect on an uncompressed socket, every write
+// goes straight out.  Call it before the writer is used.
+func (sw *SocketWriter) SetBatch(bc BatchConfig) {
+	if sw.gz == nil {
+		return
+	}
+	if bc.Interval <= 0 {
+		bc.Interval = time.Second
+	}
+	sw.gzSync.Lock()
+	sw.batch = bc
+	sw.gzSync.Unlock()
+	sw.autoFlush.Reset(bc.Interval)
+}
+
+// Closes the connection when nothing has been written for d; the next
+// write dials again.  Call it before the writer is used.
+func (sw *SocketWriter) SetIdleTimeout(d time.Duration) {
+	sw.idleTimeout = d
+	atomic.StoreInt64(&sw.lastWrite, time.Now().UnixNano())
+	sw.idleTimer = time.AfterFunc(d, sw.closeIdle)
+}
+
+// While the socket is down records go to the file called name, one per
+// line, instead of being dropped.  With replay the file is sent to the
+// collector and emptied as soon as the connection is back, ahead of any
+// new records; a connection that fails part way through the replay sends
+// the whole file again next time.  Without replay the file is left for
+// something else to ship.  Call it before the writer is used.
+func (sw *SocketWriter) SetFallbackFile(name string, replay bool) error {
+	file, err := os.OpenFile(name, os.O_RDWR|os.O_APPEND|os.O_CREATE, 0666)
 	if err != nil {
-		return nil, err
+		return fmt.Errorf("TIMBER! Can't open %v: %v", name, err)
+	}
+	sw.connSync.Lock()
+	sw.fallback, sw.fallbackReplay = file, replay
+	sw.connSync.Unlock()
+	return nil
+}
+
+// Sets how long the background reconnect waits after the first failed
+// attempt, doubling up to max after each one after that.  The defaults
+// are DefaultReconnectMin and DefaultReconnectMax.
+func (sw *SocketWriter) SetReconnectBackoff(min, max time.Duration) {
+	if min <= 0 {
+		min = DefaultReconnectMin
+	}
+	if max < min {
+		max = min
 	}
-	return &SocketWriter{conn, network, addr, &sync.RWMutex{}, &sync.Once{}}, nil
+	sw.connSync.Lock()
+	sw.retryMin, sw.retryMax = min, max
+	sw.connSync.Unlock()
+}
+
+// While the socket is down the last n records are kept in memory and
+// sent as soon as the connection is back, ahead of any new records.  When
+// more than n are written the oldest are dropped.  A fallback file wins
+// over the buffer if there's both.  Call it before the writer is used.
+func (sw *SocketWriter) SetReconnectBuffer(n int) {
+	sw.connSync.Lock()
+	if n > 0 {
+		sw.ring = &messageRing{msgs: make([][]byte, n)}
+	} else {
+		sw.ring = nil
+	}
+	sw.connSync.Unlock()
+}
+
+// How many records the reconnect buffer has dropped because it was full
+func (sw *SocketWriter) BufferDropped() uint64 {
+	sw.connSync.RLock()
+	ring := sw.ring
+	sw.connSync.RUnlock()
+	if ring == nil {
+		return 0
+	}
+	sw.fallbackSync.Lock()
+	defer sw.fallbackSync.Unlock()
+	return ring.dropped
 }
 
 func (sw *SocketWriter) LogWrite(msg string) {
+	sw.Write([]byte(msg))
+}
+
+// Allows formatters to write directly to the connection.  Errors are
+// handled the same as LogWrite, the returned error is just informational
+func (sw *SocketWriter) Write(p []byte) (int, error) {
+	sw.connSync.RLock()
+	for sw.conn == nil {
+		sw.connSync.RUnlock()
+		if err := sw.redial(); err != nil {
+			spilled := sw.spill(p)
+			// errNotConnected means the reconnect is already running
+			if err != errNotConnected || !spilled {
+				sw.writeFailed(err)
+			}
+			if spilled {
+				return len(p), nil
+			}
+			return 0, err
+		}
+		sw.connSync.RLock()
+	}
+	if sw.reconnecting && (sw.fallback != nil || sw.ring != nil) {
+		// the connection is known to be bad, don't bother with it
+		sw.connSync.RUnlock()
+		sw.spill(p)
+		return len(p), nil
+	}
+	n, err := sw.writeConn(p)
+	sw.connSync.RUnlock()
+	if sw.idleTimeout > 0 {
+		atomic.StoreInt64(&sw.lastWrite, time.Now().UnixNano())
+	}
+	if err != nil {
+		sw.writeFailed(err)
+		if sw.spill(p) {
+			return len(p), nil
+		}
+	}
+	return n, err
+}
+
+// Sends one record on the current connection, must hold connSync
+func (sw *SocketWriter) writeConn(p []byte) (int, error) {
+	var n int
+	var err error
+	if sw.gz != nil {
+		sw.gzSync.Lock()
+		if !sw.batch.Fits(sw.pending+1, sw.pendingSz+len(p)+len(sw.framing)) {
+			err = sw.flushGz()
+		}
+		if err == nil {
+			n, err = sw.gz.Write(p)
+			if err == nil {
+				_, err = sw.gz.Write(sw.framing)
+			}
+			sw.pending++
+			sw.pendingSz += n + len(sw.framing)
+		}
+		sw.gzSync.Unlock()
+	} else {
+		n, err = writeTerminated(sw.conn, p, sw.framing)
+	}
+	return n, err
+}
+
+// Appends the record to the fallback file or the reconnect buffer, false
+// if there's neither
+func (sw *SocketWriter) spill(p []byte) bool {
+	sw.connSync.RLock()
+	file, ring := sw.fallback, sw.ring
+	sw.connSync.RUnlock()
+	if file == nil && ring == nil {
+		return false
+	}
+	sw.fallbackSync.Lock()
+	var err error
+	if file != nil {
+		_, err = writeTerminated(file, p, newline)
+	} else {
+		ring.push(p)
+	}
+	sw.fallbackSync.Unlock()
+	if err != nil {
+		reportWriteError(sw, err)
+	}
+	return true
+}
+
+// Sends what the reconnect buffer held while the socket was down, oldest
+// first.  Must hold the connSync write lock.
+func (sw *SocketWriter) replayRing() {
+	if sw.ring == nil {
+		return
+	}
+	sw.fallbackSync.Lock()
+	defer sw.fallbackSync.Unlock()
+	for sw.ring.n > 0 {
+		if _, err := sw.writeConn(sw.ring.first()); err != nil {
+			// the rest wait for the next connection
+			reportWriteError(sw, err)
+			return
+		}
+		sw.ring.pop()
+	}
+}
+
+// A fixed number of records, the oldest one overwritten when it's full
+type messageRing struct {
+	msgs     [][]byte
+	start, n int
+	dropped  uint64
+}
+
+func (r *messageRing) push(p []byte) {
+	msg := append([]byte(nil), p...)
+	if r.n < len(r.msgs) {
+		r.msgs[(r.start+r.n)%len(r.msgs)] = msg
+		r.n++
+		return
+	}
+	r.msgs[r.start] = msg
+	r.start = (r.start + 1) % len(r.msgs)
+	r.dropped++
+}
+
+func (r *messageRing) first() []byte {
+	return r.msgs[r.start]
+}
+
+func (r *messageRing) pop() {
+	r.msgs[r.start] = nil
+	r.start = (r.start + 1) % len(r.msgs)
+	r.n--
+}
+
+// Sends what was spilled while the socket was down and empties the file.
+// Must hold the connSync write lock.
+func (sw *SocketWriter) replayFallback() {
+	if sw.fallback == nil || !sw.fallbackReplay {
+		return
+	}
+	sw.fallbackSync.Lock()
+	defer sw.fallbackSync.Unlock()
+	if _, err := sw.fallback.Seek(0, io.SeekStart); err != nil {
+		reportWriteError(sw, err)
+		return
+	}
+	reader := bufio.NewReader(sw.fallback)
+	for {
+		line, err := reader.ReadBytes('\n')
+		if len(line) > 0 {
+			if _, werr := sw.writeConn(bytes.TrimSuffix(line, newline)); werr != nil {
+				// keep the file for the next connection
+				reportWriteError(sw, werr)
+				return
+			}
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			reportWriteError(sw, err)
+			return
+		}
+	}
+	if err := sw.fallback.Truncate(0); err != nil {
+		reportWriteError(sw, err)
+	}
+}
+
+// Sends any compressed data that's still buffered; a no-op for
+// uncompressed sockets
+func (sw *SocketWriter) Flush() {
+	if sw.gz == nil {
+		return
+	}
 	sw.connSync.RLock()
-	_, err := sw.conn.Write([]byte(msg))
+	var err error
+	if sw.conn != nil {
+		sw.gzSync.Lock()
+		err = sw.flushGz()
+		sw.gzSync.Unlock()
+	}
 	sw.connSync.RUnlock()
 	if err != nil {
-		fmt.Printf("Socket logging error: %v", err)
-		sw.restartOnce.Do(func() {
-			go sw.reconnect()
-		})
+		sw.writeFailed(err)
+	}
+}
+
+// must hold gzSync
+func (sw *SocketWriter) flushGz() error {
+	sw.pending, sw.pendingSz = 0, 0
+	return sw.gz.Flush()
+}
+
+// Dials after an idle close unless the background reconnect is already trying
+func (sw *SocketWriter) redial() error {
+	sw.connSync.Lock()
+	defer sw.connSync.Unlock()
+	if sw.conn != nil {
+		return nil
+	}
+	if sw.reconnecting || sw.closed {
+		return errNotConnected
+	}
+	conn, err := sw.dial()
+	if err != nil {
+		return err
+	}
+	sw.setConn(conn)
+	sw.replayRing()
+	return nil
+}
+
+// must hold the connSync write lock
+func (sw *SocketWriter) setConn(conn net.Conn) {
+	sw.conn = conn
+	if sw.gz != nil {
+		sw.gzSync.Lock()
+		sw.gz.Reset(conn)
+		sw.pending, sw.pendingSz = 0, 0
+		sw.gzSync.Unlock()
+	}
+	if sw.idleTimer != nil {
+		sw.idleTimer.Reset(sw.idleTimeout)
 	}
 }
 
+func (sw *SocketWriter) closeIdle() {
+	sw.connSync.Lock()
+	defer sw.connSync.Unlock()
+	if sw.conn == nil {
+		return
+	}
+	idle := time.Since(time.Unix(0, atomic.LoadInt64(&sw.lastWrite)))
+	if idle < sw.idleTimeout {
+		sw.idleTimer.Reset(sw.idleTimeout - idle)
+		return
+	}
+	if sw.gz != nil {
+		sw.gzSync.Lock()
+		sw.gz.Close()
+		sw.gzSync.Unlock()
+	}
+	sw.conn.Close()
+	sw.conn = nil
+}
+
+func (sw *SocketWriter) writeFailed(err error) {
+	reportWriteError(sw, err)
+	sw.connSync.Lock()
+	start := !sw.reconnecting
+	sw.reconnecting = true
+	sw.connSync.Unlock()
+	if start {
+		go sw.reconnect()
+	}
+}
+
+// keeps dialing until it connects or the writer is closed, backing off
+// from retryMin to retryMax between attempts
 func (sw *SocketWriter) reconnect() {
+	var delay time.Duration
 	for {
-		conn, err := net.Dial(sw.network, sw.addr)
+		conn, err := sw.dial()
+		sw.connSync.Lock()
+		if sw.closed {
+			sw.connSync.Unlock()
+			if err == nil {
+				conn.Close()
+			}
+			return
+		}
 		if err == nil {
-			sw.connSync.Lock()
-			sw.conn = conn
-			sw.restartOnce = &sync.Once{}
+			if sw.conn != nil {
+				sw.conn.Close()
+			}
+			sw.setConn(conn)
+			sw.replayFallback()
+			sw.replayRing()
+			sw.reconnecting = false
 			sw.connSync.Unlock()
 			return
 		}
-		time.Sleep(100 * time.Millisecond)
+		if delay *= 2; delay < sw.retryMin {
+			delay = sw.retryMin
+		} else if delay > sw.retryMax {
+			delay = sw.retryMax
+		}
+		sw.connSync.Unlock()
+		select {
+		case <-time.After(delay):
+		case <-sw.done:
+		}
 	}
 }
 
 func (sw *SocketWriter) Close() {
-	sw.conn.Close()
+	if sw.idleTimer != nil {
+		sw.idleTimer.Stop()
+	}
+	sw.connSync.Lock()
+	defer sw.connSync.Unlock()
+	if sw.closed {
+		return
+	}
+	sw.closed = true
+	close(sw.done)
+	if sw.gz != nil {
+		sw.autoFlush.Stop()
+		close(sw.stopFlush)
+		if sw.conn != nil {
+			sw.gzSync.Lock()
+			sw.gz.Close()
+			sw.gzSync.Unlock()
+		}
+	}
+	if sw.conn != nil {
+		sw.conn.Close()
+		sw.conn = nil
+	}
+	if sw.fallback != nil {
+		sw.fallbackSync.Lock()
+		sw.fallback.Close()
+		sw.fallbackSync.Unlock()
+	}
 }
@@ -0,0 +1,362 @@
+package timber
+
+import (
+	"bufio"
+	"compress/gzip"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"io"
+	"net"
+	"net/http/httptest"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCompressedSocketWriter(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	received := make(chan string, 1)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		gz, err := gzip.NewReader(conn)
+		if err != nil {
+			received <- err.Error()
+			return
+		}
+		buf := make([]byte, 6)
+		io.ReadFull(gz, buf)
+		received <- string(buf)
+	}()
+
+	sw, err := NewCompressedSocketWriter("tcp", ln.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	sw.LogWrite("hello")
+	sw.Flush()
+	if got := <-received; got != "hello\n" {
+		t.Errorf("expected hello got %q", got)
+	}
+	sw.Close()
+}
+
+func TestCompressedDatagram(t *testing.T) {
+	if _, err := NewCompressedSocketWriter("udp", "127.0.0.1:9"); err == nil {
+		t.Errorf("expected an error compressing udp")
+	}
+}
+
+func TestSocketIdleTimeout(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	received := make(chan string, 2)
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			msg, _ := io.ReadAll(conn) // until the writer closes
+			received <- string(msg)
+		}
+	}()
+
+	sw, err := NewSocketWriter("tcp", ln.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	sw.SetIdleTimeout(20 * time.Millisecond)
+	sw.LogWrite("first")
+	if got := <-received; got != "first\n" {
+		t.Errorf("expected first got %q", got)
+	}
+	sw.LogWrite("second") // redials
+	sw.Close()
+	if got := <-received; got != "second\n" {
+		t.Errorf("expected second got %q", got)
+	}
+}
+
+func TestLazySocketWriter(t *testing.T) {
+	// grab a free port then let it go so the first dials fail
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := ln.Addr().String()
+	ln.Close()
+
+	sw := NewLazySocketWriter("tcp", addr)
+	time.Sleep(150 * time.Millisecond) // at least one failed dial
+	if ln, err = net.Listen("tcp", addr); err != nil {
+		t.Skip("couldn't get the port back: ", err)
+	}
+	defer ln.Close()
+	conn, err := ln.Accept()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for connected := false; !connected; time.Sleep(time.Millisecond) {
+		sw.connSync.RLock()
+		connected = sw.conn != nil
+		sw.connSync.RUnlock()
+	}
+	sw.LogWrite("connected")
+	sw.Close()
+	msg, _ := io.ReadAll(conn)
+	if string(msg) != "connected\n" {
+		t.Errorf("expected connected got %q", msg)
+	}
+}
+
+func TestSocketFallbackFile(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := ln.Addr().String()
+	ln.Close()
+
+	fallback := t.TempDir() + "/spill.log"
+	sw := NewLazySocketWriter("tcp", addr)
+	if err := sw.SetFallbackFile(fallback, true); err != nil {
+		t.Fatal(err)
+	}
+	sw.LogWrite("one")
+	sw.LogWrite("two")
+	if b, _ := os.ReadFile(fallback); string(b) != "one\ntwo\n" {
+		t.Errorf("expected the records in the fallback file got %q", b)
+	}
+	if ln, err = net.Listen("tcp", addr); err != nil {
+		t.Skip("couldn't get the port back: ", err)
+	}
+	defer ln.Close()
+	conn, err := ln.Accept()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for replayed := false; !replayed; time.Sleep(time.Millisecond) {
+		sw.connSync.RLock()
+		replayed = sw.conn != nil && !sw.reconnecting
+		sw.connSync.RUnlock()
+	}
+	sw.LogWrite("three")
+	sw.Close()
+	msg, _ := io.ReadAll(conn)
+	if string(msg) != "one\ntwo\nthree\n" {
+		t.Errorf("expected the replay first got %q", msg)
+	}
+	if b, _ := os.ReadFile(fallback); len(b) != 0 {
+		t.Errorf("expected an empty fallback file got %q", b)
+	}
+}
+
+func TestUnixSocketWriter(t *testing.T) {
+	path := t.TempDir() + "/collector.sock"
+	ln, err := net.Listen("unix", path)
+	if err != nil {
+		t.Skip("no unix sockets: ", err)
+	}
+	sw, err := NewSocketWriter("unix", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn, err := ln.Accept()
+	if err != nil {
+		t.Fatal(err)
+	}
+	sw.LogWrite("first")
+	buf := make([]byte, 6)
+	if _, err := io.ReadFull(conn, buf); err != nil || string(buf) != "first\n" {
+		t.Errorf("expected first got %q, %v", buf, err)
+	}
+
+	// the collector restarts, the writer reconnects to the same path
+	conn.Close()
+	ln.Close()
+	os.Remove(path)
+	if ln, err = net.Listen("unix", path); err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	for i := 0; i < 3; i++ {
+		sw.LogWrite("lost") // notices the old connection is gone
+		time.Sleep(10 * time.Millisecond)
+	}
+	if conn, err = ln.Accept(); err != nil {
+		t.Fatal(err)
+	}
+	for connected := false; !connected; time.Sleep(time.Millisecond) {
+		sw.connSync.RLock()
+		connected = !sw.reconnecting
+		sw.connSync.RUnlock()
+	}
+	sw.LogWrite("second")
+	sw.Close()
+	msg, _ := io.ReadAll(conn)
+	// the lost writes that came after the reconnect make it too
+	if !strings.HasSuffix(string(msg), "second\n") {
+		t.Errorf("expected second got %q", msg)
+	}
+}
+
+func TestUnixgramSocketWriter(t *testing.T) {
+	path := t.TempDir() + "/collector.sock"
+	pc, err := net.ListenPacket("unixgram", path)
+	if err != nil {
+		t.Skip("no unixgram sockets: ", err)
+	}
+	defer pc.Close()
+	sw, err := NewSocketWriter("unixgram", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sw.Close()
+	sw.LogWrite("one datagram")
+	buf := make([]byte, 64)
+	pc.SetReadDeadline(time.Now().Add(time.Second))
+	n, _, err := pc.ReadFrom(buf)
+	if err != nil || string(buf[:n]) != "one datagram" {
+		t.Errorf("expected one datagram without framing got %q, %v", buf[:n], err)
+	}
+}
+
+// a TLS listener with the httptest certificate, good for 127.0.0.1
+func testTLSListener(t *testing.T) (net.Listener, *x509.Certificate) {
+	ts := httptest.NewTLSServer(nil)
+	ts.Close()
+	ln, err := tls.Listen("tcp", "127.0.0.1:0", &tls.Config{Certificates: ts.TLS.Certificates})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return ln, ts.Certificate()
+}
+
+// the first line sent on each connection
+func acceptLines(ln net.Listener) chan string {
+	received := make(chan string, 4)
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			line, _ := bufio.NewReader(conn).ReadString('\n')
+			received <- line
+			conn.Close()
+		}
+	}()
+	return received
+}
+
+func TestTLSSocketWriter(t *testing.T) {
+	ln, cert := testTLSListener(t)
+	defer ln.Close()
+	received := acceptLines(ln)
+	if _, err := NewSocketWriter("tls", ln.Addr().String()); err == nil {
+		t.Error("expected the system roots not to trust the test certificate")
+	}
+	pool := x509.NewCertPool()
+	pool.AddCert(cert)
+	sw, err := NewTLSSocketWriter(ln.Addr().String(), &tls.Config{RootCAs: pool})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sw.LogWrite("secret")
+	sw.Close()
+
+	// the same from the config, trusting the CA in a file
+	ca := t.TempDir() + "/ca.pem"
+	if err := os.WriteFile(ca, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert.Raw}), 0600); err != nil {
+		t.Fatal(err)
+	}
+	for _, prop := range []JSONProperty{{"tls_ca", ca}, {"tls_insecure_skip_verify", "true"}} {
+		writer, err := getJSONSocketWriter(JSONFilter{Properties: []JSONProperty{{"protocol", "tls"},
+			{"endpoint", ln.Addr().String()}, prop}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		writer.LogWrite(prop.Name)
+		writer.Close()
+	}
+	var got []string
+	for i := 0; i < 4; i++ {
+		// the rejected connection sends nothing
+		if line := <-received; line != "" {
+			got = append(got, line)
+		}
+	}
+	if expected := []string{"secret\n", "tls_ca\n", "tls_insecure_skip_verify\n"}; !reflect.DeepEqual(got, expected) {
+		t.Errorf("expected %q got %q", expected, got)
+	}
+}
+
+func TestSocketReconnectBuffer(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := ln.Addr().String()
+	ln.Close()
+
+	sw := NewLazySocketWriter("tcp", addr)
+	sw.SetReconnectBuffer(2)
+	for _, msg := range []string{"one", "two", "three"} {
+		sw.LogWrite(msg)
+	}
+	if dropped := sw.BufferDropped(); dropped != 1 {
+		t.Errorf("expected the oldest record dropped got %d", dropped)
+	}
+	if ln, err = net.Listen("tcp", addr); err != nil {
+		t.Skip("couldn't get the port back: ", err)
+	}
+	defer ln.Close()
+	conn, err := ln.Accept()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for replayed := false; !replayed; time.Sleep(time.Millisecond) {
+		sw.connSync.RLock()
+		replayed = sw.conn != nil && !sw.reconnecting
+		sw.connSync.RUnlock()
+	}
+	sw.LogWrite("four")
+	sw.Close()
+	msg, _ := io.ReadAll(conn)
+	if string(msg) != "two\nthree\nfour\n" {
+		t.Errorf("expected the buffer first got %q", msg)
+	}
+}
+
+func TestSocketReconnectBackoff(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := ln.Addr().String()
+	ln.Close()
+
+	sw := NewLazySocketWriter("tcp", addr)
+	sw.SetReconnectBackoff(time.Millisecond, 4*time.Millisecond)
+	time.Sleep(50 * time.Millisecond)
+	start := time.Now()
+	sw.Close()
+	// Close cuts the wait short instead of leaving the goroutine asleep
+	if elapsed := time.Since(start); elapsed > 100*time.Millisecond {
+		t.Errorf("Close took %v", elapsed)
+	}
+}
@@ -0,0 +1,140 @@
+package timber
+
+import (
+	"fmt"
+	"runtime"
+	"strconv"
+	"strings"
+)
+
+// The field ErrorWithStack and the recover helpers put the stack trace
+// in; %K renders it and the JSON formatter writes it like any field
+const StackField = "stack"
+
+// The most frames a captured stack trace goes through
+const maxStackDepth = 64
+
+// Logs err at ERROR with the stack trace of the caller in StackField, so
+// the record shows where the error was handled and how the program got
+// there.  Returns err for return timber.ErrorWithStack(err).
+func (t *Timber) ErrorWithStack(err error) error {
+	return t.errorWithStack(err)
+}
+
+func ErrorWithStack(err error) error { return global().errorWithStack(err) }
+
+func (t *Timber) errorWithStack(err error) error {
+	if err == nil || t.skip(ERROR) {
+		return err
+	}
+	// skip this and the ErrorWithStack above it
+	stack := formatStack(callerFrames(3))
+	t.prepareAndSendFields(ERROR, err.Error(), Fields{StackField: stack}, t.FileDepth)
+	return err
+}
+
+// For a deferred call at the top of a goroutine or handler:
+//
+//	defer timber.RecoverAndLog()
+//
+// A panic is logged at CRITICAL with the stack trace of the panicking
+// goroutine in StackField and the record's source where the panic
+// happened, then the function returns normally, the panic swallowed.  It
+// has to be deferred itself, recover does nothing from further down.
+func (t *Timber) RecoverAndLog() {
+	if r := recover(); r != nil {
+		t.logPanic(r)
+	}
+}
+
+func RecoverAndLog() {
+	if r := recover(); r != nil {
+		global().logPanic(r)
+	}
+}
+
+// Same as RecoverAndLog but the panic carries on once the record has
+// been written, so the program still crashes with its usual trace
+func (t *Timber) RecoverAndRepanic() {
+	if r := recover(); r != nil {
+		t.logPanic(r)
+		t.Flush()
+		panic(r)
+	}
+}
+
+func RecoverAndRepanic() {
+	if r := recover(); r != nil {
+		global().logPanic(r)
+		global().Flush()
+		panic(r)
+	}
+}
+
+func (t *Timber) logPanic(r interface{}) {
+	if t.skip(CRITICAL) {
+		return
+	}
+	// the deferred call runs on top of the panicking frames, what the
+	// program was doing starts after runtime.gopanic
+	frames := panicFrames(callerFrames(3))
+	var pc uintptr
+	if len(frames) > 0 {
+		pc = frames[0].PC + 1 // prepareAt wants the return address
+	}
+	rec := prepareAt(CRITICAL, fmt.Sprintf("panic: %v", r), currentTime(), pc)
+	rec.Fields = Fields{StackField: formatStack(frames)}
+	t.send(rec)
+}
+
+// The frames from skip up, as runtime.Callers counts them
+func callerFrames(skip int) []runtime.Frame {
+	var pcs [maxStackDepth]uintptr
+	n := runtime.Callers(skip+1, pcs[:])
+	frames := runtime.CallersFrames(pcs[:n])
+	var out []runtime.Frame
+	for {
+		frame, more := frames.Next()
+		out = append(out, frame)
+		if !more {
+			return out
+		}
+	}
+}
+
+// Drops the frames up to the panic and the runtime ones right after it,
+// e.g. runtime.sigpanic for a nil dereference.  Without a panic in
+// frames they come back as is.
+func panicFrames(frames []runtime.Frame) []runtime.Frame {
+	for i, frame := range frames {
+		if frame.Function != "runtime.gopanic" {
+			continue
+		}
+		rest := frames[i+1:]
+		for len(rest) > 1 && strings.HasPrefix(rest[0].Function, "runtime.") {
+			rest = rest[1:]
+		}
+		return rest
+	}
+	return frames
+}
+
+// The frames the way a Go traceback writes them, function then file and
+// line on the next line indented with a tab
+func formatStack(frames []runtime.Frame) string {
+	var b strings.Builder
+	for i, frame := range frames {
+		if frame.Function == "runtime.goexit" {
+			break
+		}
+		if i > 0 {
+			b.WriteByte('\n')
+		}
+		b.WriteString(frame.Function)
+		b.WriteString("()\n\t")
+		b.WriteString(frame.File)
+		b.WriteByte(':')
+		b.WriteString(strconv.Itoa(frame.Line))
+	}
+	return b.String()
+}
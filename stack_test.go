@@ -0,0 +1,65 @@
+package timber
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestErrorWithStack(t *testing.T) {
+	mw := NewMemoryWriter(10)
+	log := NewTimber()
+	log.AddLogger(ConfigLogger{LogWriter: mw, Level: INFO, Formatter: NewPatFormatter("%L %s %M\n%K")})
+	err := errors.New("disk full")
+	if got := log.ErrorWithStack(err); got != err {
+		t.Errorf("expected the error back got %v", got)
+	}
+	log.ErrorWithStack(nil)
+	log.Close()
+	msgs := mw.Messages()
+	if len(msgs) != 1 {
+		t.Fatalf("expected one record got %q", msgs)
+	}
+	lines := strings.Split(msgs[0], "\n")
+	if lines[0] != "EROR stack_test.go:14 disk full" {
+		t.Errorf("unexpected first line %q", lines[0])
+	}
+	if !strings.HasSuffix(lines[1], ".TestErrorWithStack()") || !strings.Contains(lines[2], "stack_test.go:14") {
+		t.Errorf("expected the stack to start at the caller got %q", lines[1:])
+	}
+}
+
+func panicky() {
+	var m map[string]int
+	m["boom"] = 1
+}
+
+func TestRecoverAndLog(t *testing.T) {
+	mw := NewMemoryWriter(10)
+	log := NewTimber()
+	log.AddLogger(ConfigLogger{LogWriter: mw, Level: INFO, Formatter: NewPatFormatter("%L %x %M|%K")})
+	func() {
+		defer log.RecoverAndLog()
+		panicky()
+	}()
+	func() {
+		defer func() { recover() }()
+		defer log.RecoverAndRepanic()
+		panic("again")
+	}()
+	log.Close()
+	msgs := mw.Messages()
+	if len(msgs) != 2 {
+		t.Fatalf("expected two records got %q", msgs)
+	}
+	msg, stack, _ := strings.Cut(msgs[0], "|")
+	if msg != "CRIT stack_test panic: assignment to entry in nil map" {
+		t.Errorf("unexpected message %q", msg)
+	}
+	if !strings.HasPrefix(stack, "github.com/smw1218/timber.panicky()\n") {
+		t.Errorf("expected the stack to start at the panic got %q", stack)
+	}
+	if !strings.HasPrefix(msgs[1], "CRIT stack_test panic: again|") {
+		t.Errorf("unexpected repanic record %q", msgs[1])
+	}
+}
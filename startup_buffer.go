@@ -0,0 +1,138 @@
+package timber
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// What BufferStartup does with a record once the buffer is full
+type StartupOverflow int
+
+const (
+	DropOldest StartupOverflow = iota // make room by dropping the oldest record
+	DropNewest                        // keep what's there and drop the new record
+)
+
+// How many records BufferStartup keeps when it's given a size <= 0
+const DefaultStartupBuffer = 1000
+
+// Setting TIMBER_STARTUP_BUFFER to a size, optionally followed by
+// ",newest" to drop the newest records instead of the oldest, turns on
+// BufferStartup for Global before any init function of the program's
+// packages can log
+const EnvStartupBuffer = "TIMBER_STARTUP_BUFFER"
+
+// Records held back until the config is in
+type startupBuffer struct {
+	mu       sync.Mutex
+	recs     []*LogRecord
+	max      int
+	overflow StartupOverflow
+	dropped  int
+	released bool
+}
+
+// Holds on to every record logged from now until a config loader
+// (LoadConfig, LoadJSONConfig and the rest) succeeds or ReleaseStartup is
+// called, then sends them through the loggers in the order they were
+// logged, so what init functions and early setup log ends up where the
+// config says instead of nowhere.  The levels the loaded loggers have
+// decide what's written.  At most max records are kept, what happens
+// to the rest is up to overflow; a WARNING after the replay tells how
+// many were dropped.  Loggers added before the release don't see the
+// held records until then either.  Close releases what's still held.
+func (t *Timber) BufferStartup(max int, overflow StartupOverflow) {
+	if max <= 0 {
+		max = DefaultStartupBuffer
+	}
+	t.startup.Store(&startupBuffer{max: max, overflow: overflow})
+}
+
+// Ends BufferStartup: the records held so far are logged through the
+// current loggers, ahead of anything logged after.  Does nothing if
+// nothing is held.
+func (t *Timber) ReleaseStartup() {
+	sb, _ := t.startup.Load().(*startupBuffer)
+	if sb == nil {
+		return
+	}
+	sb.mu.Lock()
+	defer sb.mu.Unlock()
+	if sb.released {
+		return
+	}
+	// senders wait on the lock so nothing overtakes the replay
+	sb.released = true
+	for _, rec := range sb.recs {
+		t.queue(rec)
+	}
+	sb.recs = nil
+	if sb.dropped > 0 {
+		t.queue(prepareAt(WARNING, fmt.Sprintf("TIMBER! Dropped %d records logged before the config was loaded", sb.dropped), currentTime(), 0))
+	}
+	t.startup.Store((*startupBuffer)(nil))
+}
+
+// True between BufferStartup and the release
+func (t *Timber) holdingStartup() bool {
+	sb, _ := t.startup.Load().(*startupBuffer)
+	return sb != nil
+}
+
+// True if the record was held back
+func (t *Timber) holdStartup(rec *LogRecord) bool {
+	sb, _ := t.startup.Load().(*startupBuffer)
+	if sb == nil {
+		return false
+	}
+	sb.mu.Lock()
+	defer sb.mu.Unlock()
+	if sb.released {
+		return false
+	}
+	if len(sb.recs) < sb.max {
+		sb.recs = append(sb.recs, rec)
+		return true
+	}
+	sb.dropped++
+	if sb.overflow == DropNewest {
+		putRecord(rec)
+		return true
+	}
+	putRecord(sb.recs[0])
+	copy(sb.recs, sb.recs[1:])
+	sb.recs[len(sb.recs)-1] = rec
+	return true
+}
+
+// BufferStartup for Global
+func BufferStartup(max int, overflow StartupOverflow) {
+	global().BufferStartup(max, overflow)
+}
+
+// ReleaseStartup for Global
+func ReleaseStartup() {
+	global().ReleaseStartup()
+}
+
+func init() {
+	value := os.Getenv(EnvStartupBuffer)
+	if value == "" {
+		return
+	}
+	size, policy, _ := strings.Cut(value, ",")
+	max, err := strconv.Atoi(strings.TrimSpace(size))
+	if err != nil {
+		log.Printf("TIMBER! Bad %s %q, expected a size\n", EnvStartupBuffer, value)
+		return
+	}
+	overflow := DropOldest
+	if strings.EqualFold(strings.TrimSpace(policy), "newest") {
+		overflow = DropNewest
+	}
+	global().BufferStartup(max, overflow)
+}
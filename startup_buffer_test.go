@@ -0,0 +1,50 @@
+package timber
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestBufferStartup(t *testing.T) {
+	logger := NewTimber()
+	logger.BufferStartup(10, DropOldest)
+	logger.Debug("too fine for the config")
+	logger.Info("from an init function")
+	logger.Error("before the config")
+
+	mw := NewMemoryWriter(10)
+	RegisterWriterFactory("startup_memory", func(filter JSONFilter) (LogWriter, error) { return mw, nil })
+	config := `{"Filters": [{"Enabled": true, "Type": "startup_memory", "Level": "INFO", "Format": {"Name": "pattern", "Value": "%L %M"}}]}`
+	if err := logger.LoadJSONConfigString(config); err != nil {
+		t.Fatal(err)
+	}
+	logger.Warn("after the config")
+	logger.Close()
+	expected := []string{"INFO from an init function", "EROR before the config", "WARN after the config"}
+	if got := mw.Messages(); !reflect.DeepEqual(got, expected) {
+		t.Errorf("expected %q got %q", expected, got)
+	}
+}
+
+func TestBufferStartupOverflow(t *testing.T) {
+	for overflow, expected := range map[StartupOverflow][]string{
+		DropOldest: {"three", "four", "TIMBER! Dropped 2 records logged before the config was loaded"},
+		DropNewest: {"one", "two", "TIMBER! Dropped 2 records logged before the config was loaded"},
+	} {
+		logger := NewTimber()
+		logger.BufferStartup(2, overflow)
+		for _, msg := range []string{"one", "two", "three", "four"} {
+			logger.Info(msg)
+		}
+		mw := NewMemoryWriter(10)
+		logger.AddLogger(ConfigLogger{LogWriter: mw, Level: INFO, Formatter: NewPatFormatter("%M")})
+		if len(mw.Messages()) != 0 {
+			t.Errorf("expected nothing before the release, got %q", mw.Messages())
+		}
+		// Close releases what's still held
+		logger.Close()
+		if got := mw.Messages(); !reflect.DeepEqual(got, expected) {
+			t.Errorf("%v: expected %q got %q", overflow, expected, got)
+		}
+	}
+}
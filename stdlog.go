@@ -0,0 +1,95 @@
+package timber
+
+import (
+	"log"
+	"runtime"
+	"strings"
+)
+
+// The most stack frames stdLogWriter looks through for the caller of the
+// log package
+const stdLogMaxDepth = 16
+
+// Sends the output of the standard library's log package through t at
+// lvl, so libraries that log with log.Printf end up in the configured
+// destinations.  The date, time, file and prefix the log flags add are
+// taken off each line since the formatters have their own; the source of
+// the record is the caller of the log package, so granulars match the
+// library that logged.  log.SetOutput(os.Stderr) undoes it.
+func (t *Timber) HijackStdLog(lvl Level) {
+	log.SetOutput(&stdLogWriter{t: t, lvl: lvl})
+}
+
+// Same as HijackStdLog for the Global logger, whichever it is when a
+// line is logged, see SetGlobal
+func HijackStdLog(lvl Level) { log.SetOutput(&stdLogWriter{lvl: lvl}) }
+
+type stdLogWriter struct {
+	t   *Timber // nil for the Global logger
+	lvl Level
+}
+
+func (w *stdLogWriter) Write(p []byte) (int, error) {
+	t := w.t
+	if t == nil {
+		t = global()
+	}
+	if t.skip(w.lvl) {
+		return len(p), nil
+	}
+	msg := stripStdLogHeader(string(p), log.Flags(), log.Prefix())
+	rec := prepareAt(w.lvl, msg, currentTime(), stdLogCaller())
+	t.send(rec)
+	return len(p), nil
+}
+
+// The return address in the first frame outside of the log package, 0 if
+// there isn't one
+func stdLogCaller() uintptr {
+	var pcs [stdLogMaxDepth]uintptr
+	// skip Callers, this and Write
+	n := runtime.Callers(3, pcs[:])
+	for _, pc := range pcs[:n] {
+		if fn := runtime.FuncForPC(pc - 1); fn != nil && !strings.HasPrefix(fn.Name(), "log.") {
+			return pc
+		}
+	}
+	return 0
+}
+
+// Takes off what the log flags put in front of the message, in the order
+// log writes them: the prefix (unless Lmsgprefix), date, time, file and
+// line then the prefix with Lmsgprefix
+func stripStdLogHeader(line string, flags int, prefix string) string {
+	line = strings.TrimSuffix(line, "\n")
+	if flags&log.Lmsgprefix == 0 {
+		line = strings.TrimPrefix(line, prefix)
+	}
+	if flags&log.Ldate != 0 {
+		line = cutField(line, len("2009/01/23 "))
+	}
+	if flags&(log.Ltime|log.Lmicroseconds) != 0 {
+		n := len("01:23:23 ")
+		if flags&log.Lmicroseconds != 0 {
+			n += len(".123123")
+		}
+		line = cutField(line, n)
+	}
+	if flags&(log.Lshortfile|log.Llongfile) != 0 {
+		if i := strings.Index(line, ": "); i >= 0 {
+			line = line[i+2:]
+		}
+	}
+	if flags&log.Lmsgprefix != 0 {
+		line = strings.TrimPrefix(line, prefix)
+	}
+	return line
+}
+
+// Drops the first n bytes of line if it's that long
+func cutField(line string, n int) string {
+	if len(line) < n {
+		return line
+	}
+	return line[n:]
+}
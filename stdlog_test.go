@@ -0,0 +1,48 @@
+package timber
+
+import (
+	"log"
+	"os"
+	"reflect"
+	"testing"
+)
+
+func TestHijackStdLog(t *testing.T) {
+	flags, prefix := log.Flags(), log.Prefix()
+	defer func() {
+		log.SetOutput(os.Stderr)
+		log.SetFlags(flags)
+		log.SetPrefix(prefix)
+	}()
+	tl := NewTimber()
+	mw := NewMemoryWriter(10)
+	tl.AddLogger(ConfigLogger{LogWriter: mw, Level: INFO, Formatter: NewPatFormatter("%L %s %M")})
+	tl.HijackStdLog(WARNING)
+	log.SetFlags(log.LstdFlags | log.Lmicroseconds | log.Lshortfile)
+	log.SetPrefix("lib: ")
+	log.Printf("hello %d", 1)
+	log.Println("bye")
+	tl.Close()
+	expected := []string{"WARN stdlog_test.go:23 hello 1", "WARN stdlog_test.go:24 bye"}
+	if got := mw.Messages(); !reflect.DeepEqual(got, expected) {
+		t.Errorf("expected %q got %q", expected, got)
+	}
+}
+
+func TestStripStdLogHeader(t *testing.T) {
+	for _, test := range []struct {
+		line     string
+		flags    int
+		prefix   string
+		expected string
+	}{
+		{"plain\n", 0, "", "plain"},
+		{"2009/01/23 01:23:23 msg\n", log.LstdFlags, "", "msg"},
+		{"p: 2009/01/23 01:23:23.123123 /a/b.go:23: msg: with colon\n", log.LstdFlags | log.Lmicroseconds | log.Llongfile, "p: ", "msg: with colon"},
+		{"01:23:23 p: msg\n", log.Ltime | log.Lmsgprefix, "p: ", "msg"},
+	} {
+		if got := stripStdLogHeader(test.line, test.flags, test.prefix); got != test.expected {
+			t.Errorf("%q: expected %q got %q", test.line, test.expected, got)
+		}
+	}
+}
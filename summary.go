@@ -0,0 +1,123 @@
+package timber
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// Logs a line at INFO for every logger saying what it takes and where it
+// writes, e.g.
+//
+//	timber: logger 0 "stderr" at DEBUG to console stderr
+//	timber: logger 1 "file" at INFO to file /var/log/app.log, 2 granulars
+//
+// The lines go through the loggers themselves, so the ones above INFO
+// won't show them.  Call it after loading the config to check that it
+// took effect.
+func (t *Timber) LogConfigSummary() {
+	linesChan := make(chan []string, 1)
+	if !t.configure(timberConfig{Action: actionSummary, Summary: linesChan}) {
+		return
+	}
+	for _, line := range <-linesChan {
+		t.prepareAndSend(INFO, line, t.FileDepth)
+	}
+}
+
+func LogConfigSummary() { global().LogConfigSummary() }
+
+func summarizeLoggers(cls []ConfigLogger) []string {
+	lines := make([]string, len(cls))
+	for i, cLog := range cls {
+		line := fmt.Sprintf("timber: logger %d", i)
+		if cLog.Tag != "" {
+			line += fmt.Sprintf(" %q", cLog.Tag)
+		}
+		line += fmt.Sprintf(" at %s to %s", LongLevelStrings[cLog.Level], describeWriter(cLog.LogWriter))
+		if n := len(cLog.Granulars); n > 0 {
+			line += fmt.Sprintf(", %d granulars", n)
+		}
+		lines[i] = line
+	}
+	return lines
+}
+
+// Where a writer sends its messages, as best we can tell
+func describeWriter(w interface{}) string {
+	switch w := w.(type) {
+	case ConsoleWriter:
+		return describeConsole(w)
+	case *ConsoleWriter:
+		return describeConsole(*w)
+	case *SplitConsoleWriter:
+		return "console stdout, stderr from " + LongLevelStrings[w.threshold()]
+	case *BufferedWriter:
+		return describeWriter(w.writer)
+	case *reopenFile:
+		return "file " + w.name
+	case *RotatingFileWriter:
+		return "rotating file " + w.name
+	case *SharedFileWriter:
+		return "shared file " + w.name
+	case *SocketWriter:
+		return "socket " + w.network + "://" + w.addr
+	case *WireWriter:
+		return describeWriter(w.socket) + " (" + w.format.String() + ")"
+	case *SyslogWriter:
+		if w.local {
+			return "syslog " + w.sw.addr
+		}
+		return "syslog " + w.sw.network + "://" + w.sw.addr
+	case *GELFWriter:
+		return "gelf " + w.network + "://" + w.addr
+	case *JournaldWriter:
+		return "journal " + w.path
+	case *RedisWriter:
+		return "redis " + w.address + " key " + w.key
+	case NullWriter:
+		return "nowhere"
+	case *AsyncWriter:
+		return describeWriter(w.writer) + " (async)"
+	case *DedupWriter:
+		return describeWriter(w.writer) + " (dedup)"
+	case *BatchingWriter:
+		if w.gzip {
+			return describeWriter(w.writer) + " (gzip batches)"
+		}
+		return describeWriter(w.writer) + " (batched)"
+	case *AuditWriter:
+		return describeWriter(w.writer) + " (audit)"
+	case *TeeWriter:
+		branches := make([]string, len(w.branches))
+		for i, branch := range w.branches {
+			branches[i] = describeWriter(branch.writer)
+		}
+		return "tee [" + strings.Join(branches, ", ") + "]"
+	case *FallbackWriter:
+		names := make([]string, len(w.writers)-1)
+		for i, fallback := range w.writers[1:] {
+			names[i] = describeWriter(fallback)
+		}
+		return describeWriter(w.writers[0]) + " (falling back to " + strings.Join(names, ", then ") + ")"
+	case *MultiWriter:
+		children := make([]string, len(w.children))
+		for i, child := range w.children {
+			children[i] = describeWriter(child.writer)
+		}
+		return "[" + strings.Join(children, ", ") + "]"
+	case fmt.Stringer:
+		return w.String()
+	}
+	return fmt.Sprintf("%T", w)
+}
+
+func describeConsole(c ConsoleWriter) string {
+	switch c.out() {
+	case os.Stderr:
+		return "console stderr"
+	case os.Stdout:
+		return "console stdout"
+	}
+	return "console"
+}
@@ -1,3 +1,5 @@
+//go:build !windows && !plan9
+
 package timber
 
 import (
@@ -7,20 +9,18 @@ import (
 	"time"
 )
 
-// Mapping from the timber levels to the syslog severity
-// If you override this, make sure all the entries are in the map
-// since the syslog.Priority zero value will cause a message at 
-// the Emergency severity
-var DefaultSeverityMap = map[Level]syslog.Priority{
-	NONE:     syslog.LOG_INFO,
-	FINEST:   syslog.LOG_DEBUG,
-	FINE:     syslog.LOG_DEBUG,
-	DEBUG:    syslog.LOG_DEBUG,
-	TRACE:    syslog.LOG_INFO,
-	INFO:     syslog.LOG_INFO,
-	WARNING:  syslog.LOG_WARNING,
-	ERROR:    syslog.LOG_ERR,
-	CRITICAL: syslog.LOG_CRIT,
+// Mapping from the timber levels to the syslog severity, from
+// SyslogSeverity.  If you override this, make sure all the entries are
+// in the map since the syslog.Priority zero value will cause a message
+// at the Emergency severity
+var DefaultSeverityMap = severityMap()
+
+func severityMap() map[Level]syslog.Priority {
+	m := make(map[Level]syslog.Priority, len(levelSeverities))
+	for lvl := range levelSeverities {
+		m[Level(lvl)] = syslog.Priority(SyslogSeverity(Level(lvl)))
+	}
+	return m
 }
 
 // Syslog formatter wraps a PatFormatter but adds the 
@@ -47,7 +47,7 @@ func (sf *SyslogFormatter) Format(rec *LogRecord) string {
 	msg := sf.pf.Format(rec)
 	return fmt.Sprintf("<%d>%.15s %s[%d]: %s",
 		sf.Facility|sf.SeverityMap[rec.Level],
-		rec.Timestamp.Format(time.Stamp),
+		truncateTime(rec.Timestamp).Format(time.Stamp),
 		sf.Tag,
 		sf.pid,
 		msg)
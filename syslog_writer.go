@@ -0,0 +1,163 @@
+package timber
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// How a SyslogWriter lays out the syslog header
+type SyslogFormat int
+
+const (
+	// <PRI>Jan  2 15:04:05 host tag[pid]: msg, what most local daemons expect
+	SyslogRFC3164 SyslogFormat = iota
+	// <PRI>1 2006-01-02T15:04:05.000000Z07:00 host tag pid - - msg
+	SyslogRFC5424
+)
+
+// Facility codes by name, the facility is the code times 8 in PRI
+var syslogFacilities = map[string]int{
+	"kern": 0, "user": 1, "mail": 2, "daemon": 3, "auth": 4, "syslog": 5,
+	"lpr": 6, "news": 7, "uucp": 8, "cron": 9, "authpriv": 10, "ftp": 11,
+	"local0": 16, "local1": 17, "local2": 18, "local3": 19,
+	"local4": 20, "local5": 21, "local6": 22, "local7": 23,
+}
+
+// Returns the facility code for a name like user, daemon or local0,
+// ignoring case
+func ParseSyslogFacility(name string) (int, error) {
+	if code, ok := syslogFacilities[strings.ToLower(strings.TrimSpace(name))]; ok {
+		return code, nil
+	}
+	return 0, fmt.Errorf("TIMBER! Unknown syslog facility %q", name)
+}
+
+// the sockets local syslog daemons listen on, in the order log/syslog
+// tries them
+var syslogLocalPaths = []string{"/dev/log", "/var/run/syslog", "/var/run/log"}
+
+// Sends each record to syslog with the severity from SyslogSeverity.  An
+// empty network means the local daemon (/dev/log or the like), otherwise
+// it's any network SocketWriter takes, e.g. udp or tcp to a remote
+// collector.  RFC 5424 over a stream socket uses octet counting (RFC
+// 6587); everything else goes one message per datagram or line.  The
+// formatter only makes the message part, leave the time and level out of
+// the pattern since the header has them.
+type SyslogWriter struct {
+	sw         *SocketWriter
+	local      bool
+	format     SyslogFormat
+	octetCount bool
+	facility   int
+	tag        string
+	hostname   string
+	pid        string
+}
+
+// The facility is a code from ParseSyslogFacility and tag defaults to the
+// program name.  The writer starts out with RFC 3164 headers, see
+// SetFormat.
+func NewSyslogWriter(network, addr string, facility int, tag string) (*SyslogWriter, error) {
+	if facility < 0 || facility > 23 {
+		return nil, fmt.Errorf("TIMBER! Bad syslog facility %d, expected 0 to 23", facility)
+	}
+	if tag == "" {
+		tag = filepath.Base(os.Args[0])
+	}
+	w := &SyslogWriter{facility: facility, tag: tag, pid: strconv.Itoa(os.Getpid())}
+	w.hostname, _ = os.Hostname()
+	if network != "" {
+		sw, err := NewSocketWriter(network, addr)
+		if err != nil {
+			return nil, fmt.Errorf("TIMBER! Can't connect to syslog at %s://%s: %v", network, addr, err)
+		}
+		w.sw = sw
+		return w, nil
+	}
+	w.local = true
+	paths := syslogLocalPaths
+	if addr != "" {
+		paths = []string{addr}
+	}
+	for _, path := range paths {
+		for _, network := range []string{"unixgram", "unix"} {
+			if sw, err := NewSocketWriter(network, path); err == nil {
+				w.sw = sw
+				return w, nil
+			}
+		}
+	}
+	return nil, fmt.Errorf("TIMBER! Can't connect to the local syslog at %s", strings.Join(paths, ", "))
+}
+
+// Switches between RFC 3164 and RFC 5424 headers.  Call it before the
+// writer is used.
+func (w *SyslogWriter) SetFormat(format SyslogFormat) {
+	w.format = format
+	w.octetCount = format == SyslogRFC5424 && !w.local && !isDatagram(w.sw.network)
+	if w.octetCount {
+		w.sw.SetFraming("")
+	} else if !isDatagram(w.sw.network) {
+		w.sw.SetFraming("\n")
+	}
+}
+
+// Without a record the message goes out as informational
+func (w *SyslogWriter) LogWrite(msg string) {
+	w.write(SeverityInformational, time.Now(), msg)
+}
+
+func (w *SyslogWriter) LogWriteRecord(rec *LogRecord, msg string) {
+	w.write(SyslogSeverity(rec.Level), rec.Timestamp, msg)
+}
+
+func (w *SyslogWriter) write(severity int, ts time.Time, msg string) {
+	w.sw.LogWrite(w.message(severity, ts, msg))
+}
+
+func (w *SyslogWriter) message(severity int, ts time.Time, msg string) string {
+	msg = strings.TrimRight(msg, "\n")
+	pri := w.facility<<3 | severity
+	if w.format == SyslogRFC5424 {
+		line := fmt.Sprintf("<%d>1 %s %s %s %s - - %s", pri, ts.Format("2006-01-02T15:04:05.000000Z07:00"),
+			syslogField(w.hostname, 255), syslogField(w.tag, 48), w.pid, msg)
+		if w.octetCount {
+			line = strconv.Itoa(len(line)) + " " + line
+		}
+		return line
+	}
+	if w.local {
+		// the local daemon adds the hostname
+		return fmt.Sprintf("<%d>%s %s[%s]: %s", pri, ts.Format(time.Stamp), w.tag, w.pid, msg)
+	}
+	return fmt.Sprintf("<%d>%s %s %s[%s]: %s", pri, ts.Format(time.Stamp), w.hostname, w.tag, w.pid, msg)
+}
+
+// RFC 5424 header fields are printable ASCII without spaces, "-" when empty
+func syslogField(s string, max int) string {
+	if s == "" {
+		return "-"
+	}
+	b := []byte(s)
+	for i, c := range b {
+		if c <= ' ' || c > '~' {
+			b[i] = '_'
+		}
+	}
+	if len(b) > max {
+		b = b[:max]
+	}
+	return string(b)
+}
+
+func (w *SyslogWriter) Flush() {
+	w.sw.Flush()
+}
+
+func (w *SyslogWriter) Close() {
+	w.sw.Close()
+}
@@ -0,0 +1,109 @@
+package timber
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+var syslogTime = time.Date(2012, 3, 4, 5, 6, 7, 8000, time.UTC)
+
+func TestSyslogWriterLocal(t *testing.T) {
+	path := t.TempDir() + "/log"
+	pc, err := net.ListenPacket("unixgram", path)
+	if err != nil {
+		t.Skip("no unixgram sockets: ", err)
+	}
+	defer pc.Close()
+	w, err := NewSyslogWriter("", path, 16, "app")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+	w.LogWriteRecord(&LogRecord{Level: ERROR, Timestamp: syslogTime}, "broken")
+	buf := make([]byte, 128)
+	pc.SetReadDeadline(time.Now().Add(time.Second))
+	n, _, err := pc.ReadFrom(buf)
+	// local0 is 16, error is 3: 16*8 + 3
+	expected := fmt.Sprintf("<131>Mar  4 05:06:07 app[%d]: broken", os.Getpid())
+	if err != nil || string(buf[:n]) != expected {
+		t.Errorf("expected %q got %q, %v", expected, buf[:n], err)
+	}
+	if got := describeWriter(w); got != "syslog "+path {
+		t.Errorf("unexpected description %q", got)
+	}
+}
+
+func TestSyslogWriterRFC5424(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	w, err := NewSyslogWriter("tcp", ln.Addr().String(), 1, "my app")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+	w.hostname = "host"
+	w.SetFormat(SyslogRFC5424)
+	conn, err := ln.Accept()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	w.LogWriteRecord(&LogRecord{Level: DEBUG, Timestamp: syslogTime}, "one")
+	w.LogWriteRecord(&LogRecord{Level: WARNING, Timestamp: syslogTime}, "two\n")
+
+	conn.SetReadDeadline(time.Now().Add(time.Second))
+	r := bufio.NewReader(conn)
+	for _, expected := range []string{
+		fmt.Sprintf("<15>1 2012-03-04T05:06:07.000008Z host my_app %d - - one", os.Getpid()),
+		fmt.Sprintf("<12>1 2012-03-04T05:06:07.000008Z host my_app %d - - two", os.Getpid()),
+	} {
+		var length int
+		if _, err := fmt.Fscanf(r, "%d ", &length); err != nil {
+			t.Fatal(err)
+		}
+		msg := make([]byte, length)
+		if _, err := io.ReadFull(r, msg); err != nil || string(msg) != expected {
+			t.Errorf("expected an octet counted %q got %q, %v", expected, msg, err)
+		}
+	}
+}
+
+func TestSyslogConfig(t *testing.T) {
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pc.Close()
+	filter := JSONFilter{Type: "syslog", Properties: []JSONProperty{{"network", "udp"},
+		{"address", pc.LocalAddr().String()}, {"facility", "daemon"}, {"tag", "svc"}, {"rfc", "5424"}}}
+	writer, err := getSyslogWriter(filter)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer writer.Close()
+	writer.LogWrite("raw")
+	buf := make([]byte, 256)
+	pc.SetReadDeadline(time.Now().Add(time.Second))
+	n, _, err := pc.ReadFrom(buf)
+	// daemon is 3, informational is 6
+	if err != nil || !strings.HasPrefix(string(buf[:n]), "<30>1 ") || !strings.HasSuffix(string(buf[:n]), " svc "+
+		fmt.Sprint(os.Getpid())+" - - raw") {
+		t.Errorf("unexpected datagram %q, %v", buf[:n], err)
+	}
+
+	for _, bad := range []JSONProperty{{"facility", "nope"}, {"rfc", "1234"}} {
+		filter.Properties = []JSONProperty{{"network", "udp"}, {"address", pc.LocalAddr().String()}, bad}
+		if _, err := getSyslogWriter(filter); err == nil {
+			t.Errorf("expected an error for %v", bad)
+		}
+	}
+}
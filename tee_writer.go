@@ -0,0 +1,110 @@
+package timber
+
+import (
+	"sync"
+)
+
+// Sends each record to several writers, each with a formatter of its
+// own, so one logger can write text to the console and JSON to a file
+// while its level, granulars and filters are set once.  A branch without
+// a formatter gets the message the logger formatted, with the logger's
+// Prefix and Suffix; the others format the record themselves.  Every
+// branch is formatted, then written, before the next record is taken,
+// so all of them see the records in the same order even when the
+// TeeWriter is shared between loggers.
+type TeeWriter struct {
+	mu       sync.Mutex
+	branches []teeBranch
+	msgs     []string // the messages of the record being written
+}
+
+type teeBranch struct {
+	formatter LogFormatter
+	writer    LogWriter
+	level     Level
+}
+
+// Branches added here get every record as the logger formatted it
+func NewTeeWriter(writers ...LogWriter) *TeeWriter {
+	tw := new(TeeWriter)
+	for _, w := range writers {
+		tw.AddBranch(nil, w, NONE)
+	}
+	return tw
+}
+
+// Adds a branch that formats the records at level or above with
+// formatter, nil for the logger's message, and writes them to writer.
+// Not safe to call once the TeeWriter is in use by a logger.
+func (tw *TeeWriter) AddBranch(formatter LogFormatter, writer LogWriter, level Level) {
+	tw.branches = append(tw.branches, teeBranch{formatter, writer, level})
+	tw.msgs = append(tw.msgs, "")
+}
+
+// Without the record there's nothing to format, so every branch gets msg
+func (tw *TeeWriter) LogWrite(msg string) {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+	for _, branch := range tw.branches {
+		branch.writer.LogWrite(msg)
+	}
+}
+
+// RecordWriter interface
+func (tw *TeeWriter) LogWriteRecord(rec *LogRecord, msg string) {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+	for i, branch := range tw.branches {
+		tw.msgs[i] = msg
+		if branch.formatter == nil || rec.raw || rec.Level < branch.level {
+			continue
+		}
+		if _, ok := branch.writer.(StructuredWriter); !ok {
+			tw.msgs[i] = branch.formatter.Format(rec)
+		}
+	}
+	for i, branch := range tw.branches {
+		if rec.Level >= branch.level {
+			writeRecord(branch.writer, rec, tw.msgs[i])
+		}
+	}
+}
+
+// SourceUser interface: the branches' formatters and writers decide, the
+// logger's formatter is counted with the logger
+func (tw *TeeWriter) UsesSource() bool {
+	for _, branch := range tw.branches {
+		if formatterUsesSource(branch.formatter) || writerUsesSource(branch.writer) {
+			return true
+		}
+	}
+	return false
+}
+
+// Flushes the branches that implement Flusher
+func (tw *TeeWriter) Flush() {
+	for _, branch := range tw.branches {
+		if fl, ok := branch.writer.(Flusher); ok {
+			fl.Flush()
+		}
+	}
+}
+
+// Reopens the branches that implement Reopener, returns the first error
+func (tw *TeeWriter) Reopen() error {
+	var first error
+	for _, branch := range tw.branches {
+		if ro, ok := branch.writer.(Reopener); ok {
+			if err := ro.Reopen(); err != nil && first == nil {
+				first = err
+			}
+		}
+	}
+	return first
+}
+
+func (tw *TeeWriter) Close() {
+	for _, branch := range tw.branches {
+		branch.writer.Close()
+	}
+}
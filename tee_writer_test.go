@@ -0,0 +1,90 @@
+package timber
+
+import (
+	"encoding/xml"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestTeeWriter(t *testing.T) {
+	plain, leveled, warnings := NewMemoryWriter(10), NewMemoryWriter(10), NewMemoryWriter(10)
+	tee := NewTeeWriter(plain)
+	tee.AddBranch(NewPatFormatter("%L %M"), leveled, NONE)
+	tee.AddBranch(NewPatFormatter("%L!"), warnings, WARNING)
+	logger := NewTimber()
+	logger.AddLogger(ConfigLogger{LogWriter: tee, Level: INFO, Formatter: NewPatFormatter("%M"), Prefix: "[svc] "})
+	logger.Debug("dropped")
+	logger.Info("hello")
+	logger.Warn("careful")
+	logger.LogRaw(ERROR, []byte("raw"))
+	logger.Close()
+	for _, check := range []struct {
+		mw       *MemoryWriter
+		expected []string
+	}{
+		{plain, []string{"[svc] hello", "[svc] careful", "raw"}},
+		{leveled, []string{"INFO hello", "WARN careful", "raw"}},
+		{warnings, []string{"WARN!", "raw"}},
+	} {
+		if got := check.mw.Messages(); !reflect.DeepEqual(got, check.expected) {
+			t.Errorf("expected %q got %q", check.expected, got)
+		}
+	}
+	if tee.UsesSource() {
+		t.Errorf("expected no caller lookups for level and message patterns")
+	}
+	tee.AddBranch(NewPatFormatter("%S %M"), NewMemoryWriter(1), NONE)
+	if !tee.UsesSource() {
+		t.Errorf("expected caller lookups for a branch with %%S")
+	}
+}
+
+func TestTeeConfig(t *testing.T) {
+	dir := t.TempDir()
+	text, leveled := filepath.Join(dir, "text.log"), filepath.Join(dir, "leveled.log")
+	config := fmt.Sprintf(`{"Filters": [{"Enabled": true, "Tag": "both", "Type": "tee", "Level": "INFO",
+	"Format": {"Name": "pattern", "Value": "%%M"}, "Properties": [{"Name": "prefix", "Value": "> "}],
+	"Outputs": [
+		{"Type": "file", "Properties": [{"Name": "filename", "Value": %q}]},
+		{"Type": "file", "Level": "WARNING", "Format": {"Name": "pattern", "Value": "%%L %%M"},
+			"Properties": [{"Name": "filename", "Value": %q}]}
+	]}]}`, text, leveled)
+	logger := NewTimber()
+	if err := logger.LoadJSONConfigBytes([]byte(config)); err != nil {
+		t.Fatal(err)
+	}
+	logger.Info("one")
+	logger.Error("two")
+	logger.Close()
+	for name, expected := range map[string]string{text: "> one\n> two\n", leveled: "EROR two\n"} {
+		if got, _ := os.ReadFile(name); string(got) != expected {
+			t.Errorf("%s: expected %q got %q", filepath.Base(name), expected, got)
+		}
+	}
+
+	bad := `{"Filters": [{"Enabled": true, "Type": "tee", "Outputs": [{"Type": "carrier-pigeon"}]}]}`
+	if err := NewTimber().LoadJSONConfigBytes([]byte(bad)); err == nil || !strings.Contains(err.Error(), "tee output 0") {
+		t.Errorf("expected an error for the output's type, got %v", err)
+	}
+	if _, err := validateConfig(JSONConfig{Filters: []JSONFilter{{Enabled: true, Type: "tee"}}}); err == nil {
+		t.Errorf("expected an error for a tee without outputs")
+	}
+}
+
+func TestTeeXMLConfig(t *testing.T) {
+	var config XMLConfig
+	err := xml.Unmarshal([]byte(`<logging><filter enabled="true"><type>tee</type>
+		<output><type>console</type><format name="json"></format></output>
+		<output><type>null</type><level>ERROR</level></output></filter></logging>`), &config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []JSONFilter{{Type: "console", Format: JSONProperty{Name: "json"}}, {Type: "null", Level: "ERROR"}}
+	if got := config.toJSON().Filters[0].Outputs; !reflect.DeepEqual(got, expected) {
+		t.Errorf("expected %+v got %+v", expected, got)
+	}
+}
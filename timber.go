@@ -22,6 +22,8 @@
 // It can also be used as the output of the standard logger with
 //   log.SetFlags(0)
 //   log.SetOutput(timber.Global)
+// or with HijackStdLog, which also takes off the log flags' header and
+// picks the level.
 //
 // Configuration in code is also simple:
 //		timber.AddLogger(timber.ConfigLogger{
@@ -79,14 +81,54 @@
 // 		%% - Percent sign
 // 		%P - Caller Path: packagePath.CallingFunctionName
 // 		%p - Caller Path: packagePath
+// 		%r - Trace ID (from the trace_id field, see RegisterContextExtractor)
+// 		%n - Span ID (from the span_id field)
+// 		%C - Count of records at this level since start (or ResetLevelCounts)
+// 		%N - Monotonic nanoseconds since start, orders records with the same timestamp
+// 		%H - Hostname, %{pid} the process ID and %{app} the program name
+// 		%{env} - the static tag env, set by a tag_env property; app sets %{app}
 // the string number prefixes are allowed e.g.: %10s will pad the source field to 10 spaces
 // pattern defaults to %M
+// Any filter can mask sensitive text in its formatted output with one or
+// more mask properties of the form pattern=>replacement:
+//		<property name="mask">[\w.]+@[\w.]+=>***@***</property>
+// The redact properties scrub the message and fields before the record is
+// formatted, so JSON and structured writers are covered too; each is
+// credit_card, bearer_token, email or pattern=>replacement, and
+// redact_field replaces the values of a comma separated list of fields:
+//		<property name="redact">email</property>
+//		<property name="redact_field">password,ssn</property>
+// The multiline property keeps records with newlines apart for shippers
+// that read lines: indent starts the lines after the first with
+// multiline_indent (a tab by default), prefix repeats the header before
+// the message on each and escape writes the newlines as \n.
+// The max_length property cuts each formatted record to that many bytes.
+// The prefix and suffix properties are written around every record as is.
+// duration_format (string, ms or s) sets how time.Duration fields are written.
+// A console filter writes to stderr, or stdout with stream set to stdout;
+// stream split sends ERROR and above (or split_level) to stderr and the
+// rest to stdout.
+// A console filter with color set to true colors each record by level, see
+// DefaultColors; color_<level> properties such as color_error=1;31 change
+// the code for a level (and turn color on).
+// indent set to true spreads a JSONFormatter's output over indented lines.
+// Set the escape property to true to escape newlines, tabs and other
+// control characters in messages so each record stays on one line.
+// The enabled_env property lets an environment variable turn a filter on
+// or off, e.g. with ENABLE_SOCKET_LOG=true set:
+//		<property name="enabled_env">ENABLE_SOCKET_LOG</property>
 // Both log4go synatax of <property name="format"> and new <format name=type> are supported
 // the property syntax will only ever support the pattern formatter
 // To configure granulars:
 //   - Create one or many <granular> within a filter
 //   - Define a <level> and <path> within, where path can be path to package or path to
 //     package.FunctionName. Function name definitions override package paths.
+//     A path ending in /... also takes the sub-packages, * and ? glob and a
+//     path starting with re: is a regexp on package.FunctionName; exact paths
+//     win, then the longest /... prefix, then the longest glob or regexp.
+//     The granular level replaces the filter level for matching records either
+//     way: lower to hear more from a package, higher to quiet a chatty one.
+//   - Optionally add a <format> to use a different pattern for messages matching the path
 //
 // Code Architecture:
 // A MultiLogger <logging> which consists of many ConfigLoggers <filter>. ConfigLoggers have three properties:
@@ -109,11 +151,16 @@ package timber
 
 import (
 	"bytes"
+	"context"
 	"errors"
 	"fmt"
+	"io"
 	"os"
 	"runtime"
+	"strconv"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
@@ -135,6 +182,10 @@ const (
 // Default level passed to runtime.Caller by Timber, add to this if you wrap Timber in your own logging code
 const DefaultFileDepth int = 3
 
+// Records a new Timber can queue for the dispatch goroutine before a log
+// call blocks, see SetDispatchBuffer
+const DefaultDispatchBuffer int = 300
+
 // What gets printed for each Log level
 var LevelStrings = [...]string{"", "FNST", "FINE", "DEBG", "TRAC", "INFO", "WARN", "EROR", "CRIT"}
 
@@ -153,12 +204,21 @@ var LongLevelStrings = []string{
 
 // Return a given level string as the actual Level value
 func getLevel(lvlString string) Level {
+	lvl, _ := ParseLevel(lvlString)
+	return lvl
+}
+
+// Returns the Level for one of the LongLevelStrings, ignoring case and
+// surrounding whitespace, so log4go style names like "finest" and " Fine "
+// work.  Unknown names are an error and return NONE.
+func ParseLevel(lvlString string) (Level, error) {
+	lvlString = strings.TrimSpace(lvlString)
 	for idx, str := range LongLevelStrings {
-		if str == lvlString {
-			return Level(idx)
+		if strings.EqualFold(str, lvlString) {
+			return Level(idx), nil
 		}
 	}
-	return Level(0)
+	return NONE, fmt.Errorf("TIMBER! Unknown level %q", lvlString)
 }
 
 // This explicitly defines the contract for a logger
@@ -201,35 +261,211 @@ type LoggerConfig interface {
 // instead of []byte.
 //
 // TODO: Maybe this should just be a standard io.WriteCloser?
+//
+// Each LogWrite (or Write, for writers that are also an io.Writer) is one
+// formatted record without a line terminator; formatters never add one.
+// The writer adds whatever framing its destination needs: the console and
+// file writers end each record with "\n", sockets use their configured
+// framing and writers with their own message boundaries (redis, kafka,
+// datagram sockets) add nothing.
 type LogWriter interface {
 	LogWrite(msg string)
 	Close()
 }
 
 // This packs up all the message data and metadata. This structure
-// will be passed to the LogFormatter
+// will be passed to the LogFormatter, and to the LogWriter if it's a
+// RecordWriter.  Custom formatters and writers can rely on these fields;
+// they're filled in once per log call and shared by every logger, so
+// treat the record as read only.
 type LogRecord struct {
-	Level       Level
-	Timestamp   time.Time
-	SourceFile  string
-	SourceLine  int
-	Message     string
-	FuncPath    string
-	PackagePath string
+	Level       Level         // level of the log call
+	Timestamp   time.Time     // when the log call was made
+	SourceFile  string        // full path of the calling file, from runtime.Caller
+	SourceLine  int           // line of the log call in SourceFile
+	Message     string        // the message with any arguments already formatted in; never holds the fields
+	FuncPath    string        // package path and function name, e.g. github.com/me/app.(*T).Method
+	PackagePath string        // package path of the caller, used for granulars
+	Fields      Fields        // structured fields, e.g. from the Context or ...w methods; may be nil
+	LevelCount  uint64        // records at Level so far, this one included; see ResetLevelCounts
+	Monotonic   int64         // nanoseconds since the process started, increases with every record
+	LoggerTag   string        // Tag of the logger the record is being formatted for, each tagged logger gets a copy
+	Delta       time.Duration // time since the previous record, see SetDeltaScope
+	LoggerName  string        // dotted name of the NamedLogger that logged it, empty for the rest
+	raw         bool          // from LogRaw, Message goes out as is
+	elevated    bool          // from a ContextWithDebug context, skips the level checks
+	skipMDC     bool          // from a ContextWithMDC context, which has the MDC already
+}
+
+// per-level running counts for LogRecord.LevelCount, accessed atomically
+var levelCounts [CRITICAL + 1]uint64
+
+// Restarts the per-level counts rendered by %C from zero
+func ResetLevelCounts() {
+	for i := range levelCounts {
+		atomic.StoreUint64(&levelCounts[i], 0)
+	}
+}
+
+func nextLevelCount(lvl Level) uint64 {
+	if lvl < 0 || int(lvl) >= len(levelCounts) {
+		return 0
+	}
+	return atomic.AddUint64(&levelCounts[lvl], 1)
+}
+
+// when the process started, the base for LogRecord.Monotonic
+var monoStart = time.Now()
+
+// last Monotonic handed out, accessed atomically
+var monoLast int64
+
+// Reads the monotonic clock but never returns the same value twice so
+// records with the same timestamp still sort in the order they were made
+func nextMonotonic() int64 {
+	now := int64(time.Since(monoStart))
+	for {
+		last := atomic.LoadInt64(&monoLast)
+		next := now
+		if next <= last {
+			next = last + 1
+		}
+		if atomic.CompareAndSwapInt64(&monoLast, last, next) {
+			return next
+		}
+	}
 }
 
-// Format a log message before writing
+// Optional interface for a LogWriter that needs the record (e.g. the level)
+// along with the formatted message.  When implemented it's called instead
+// of LogWrite.  A RecordWriter may keep rec; otherwise records are reused
+// after they've been written.
+type RecordWriter interface {
+	LogWriteRecord(rec *LogRecord, msg string)
+}
+
+// Optional interface for a LogWriter that serializes records itself, e.g.
+// to send the fields as JSON attributes.  When implemented the formatter
+// (and the logger's prefix and suffix) is skipped and LogStructured is
+// called instead of LogWrite; records from LogRaw still go to LogWrite
+// since they're already serialized.  Errors go to WriteErrorHandler.  Like
+// a RecordWriter it may keep rec.
+type StructuredWriter interface {
+	LogStructured(rec *LogRecord) error
+}
+
+// Hands a record that has already been formatted to w the best way w
+// takes it, for writers that wrap other writers
+func writeRecord(w LogWriter, rec *LogRecord, msg string) {
+	if sw, ok := w.(StructuredWriter); ok && !rec.raw {
+		if err := sw.LogStructured(rec); err != nil {
+			reportWriteError(w, err)
+		}
+	} else if rw, ok := w.(RecordWriter); ok {
+		rw.LogWriteRecord(rec, msg)
+	} else {
+		w.LogWrite(msg)
+	}
+}
+
+// Called when a LogWriter fails to write a message since LogWrite has no
+// way to return the error.  Replace it to send the errors somewhere else;
+// it may be called from any goroutine.  The default prints to stderr.
+var WriteErrorHandler = func(writer LogWriter, err error) {
+	fmt.Fprintf(os.Stderr, "TIMBER! %T write failed: %v\n", writer, err)
+}
+
+// sends p with term on the end in a single write so lines from different
+// records can't interleave, returns how much of p was written
+func writeTerminated(w io.Writer, p []byte, term []byte) (int, error) {
+	if len(term) == 0 {
+		return w.Write(p)
+	}
+	pb := getPatBuffer()
+	pb.buf.Write(p)
+	pb.buf.Write(term)
+	n, err := w.Write(pb.buf.Bytes())
+	putPatBuffer(pb)
+	if n > len(p) {
+		n = len(p)
+	}
+	return n, err
+}
+
+func reportWriteError(writer LogWriter, err error) {
+	if handler := WriteErrorHandler; handler != nil {
+		handler(writer, err)
+	}
+}
+
+// Optional interface for a LogWriter that buffers.  Flush should not
+// return until the buffered messages have been written.
+type Flusher interface {
+	Flush()
+}
+
+// Optional interface for a LogWriter backed by a file.  Reopen closes the
+// file and opens it again by name so a file that was moved away by an
+// external tool (logrotate) is replaced with a fresh one.
+type Reopener interface {
+	Reopen() error
+}
+
+// Format a log message before writing.  The record may be reused once
+// Format returns so don't keep it.
 type LogFormatter interface {
 	Format(rec *LogRecord) string
 }
 
+// Optional interface for a LogFormatter that can write the formatted message
+// directly to the destination instead of returning a string.  When both the
+// formatter implements this and the LogWriter is also an io.Writer, timber
+// uses FormatTo to skip the intermediate string allocation.
+type LogFormatterTo interface {
+	FormatTo(w io.Writer, rec *LogRecord) (int, error)
+}
+
 // Container a single log format/destination
 type ConfigLogger struct {
 	LogWriter LogWriter
 	// Messages with level < Level will be ignored.  It's up to the implementor to keep the contract or not
 	Level     Level
+	// Optional highest level taken, with Level that's a range, e.g. only
+	// WARNING to CRITICAL for a pager feed.  Zero means no ceiling.
+	MaxLevel  Level
 	Formatter LogFormatter
+	// Levels that replace Level for records from a package or function,
+	// higher or lower, or from a pattern like pkg/...; see granularFor
 	Granulars map[string]Level
+	// Optional formatters that replace Formatter for records matching a granular path
+	GranularFormatters map[string]LogFormatter
+	// Optional highest level taken for records matching a granular path,
+	// together with Granulars that's a range, e.g. only DEBUG to INFO
+	GranularMax map[string]Level
+	// Optional formatters by name for records that pick one with FormatField;
+	// they win over the granular formatters.  Other records use Formatter.
+	FieldFormatters map[string]LogFormatter
+	// Collect timing for this logger, see Timber.WriteStats.  Off it costs nothing.
+	TimeWrites bool
+	// Optional name for finding the logger later, e.g. with SetLevelFor.
+	// The config loaders use the filter's tag.
+	Tag string
+	// Written verbatim before and after every formatted record whatever
+	// the formatter, e.g. "[svc-auth] ".  Records from LogRaw don't get them.
+	Prefix string
+	Suffix string
+	// Optional, drops some of the records the levels let through
+	Sampler *Sampler
+	// Optional, run after the Sampler, e.g. a MessageSampler, RateLimiter
+	// or a FilterFunc; see AllOf and AnyOf
+	Filters []RecordFilter
+	// Optional, rewrite the message and fields of the records the logger
+	// writes, e.g. EmailRedactor; see Redactor
+	Redactors []Redactor
+	stats   *WriteStats
+	counts  *loggerCounts // see Timber.Metrics
+	// the patterns among Granulars, set when the loggers change
+	granularPats *granularIndex
 }
 
 // Allow logging to multiple places
@@ -264,6 +500,32 @@ type Timber struct {
 	hasLogger        bool
 	closeLatch       *sync.Once
 	blackHole        chan int
+	disabled         int32 // accessed atomically, see Disable
+	// Log calls below this level return right away; it's the lowest level
+	// any logger takes and is kept up to date by the dispatch goroutine.
+	// Accessed atomically.
+	minLevel int32
+	// Log calls hold the read lock while queueing a record and Close takes
+	// the write lock to set closed, so recordChan is never closed under a
+	// sender
+	closeSync *sync.RWMutex
+	closed    bool
+	// pending SetLevelFor reverts by tag
+	revertSync sync.Mutex
+	reverts    map[string]*levelRevert
+	// from OnClose
+	hookSync   sync.Mutex
+	closeHooks []func()
+	// the levels of the loggers for Enabled, a *levelSnapshot set with
+	// minLevel by the dispatch goroutine
+	levels atomic.Value
+	// the records held by BufferStartup, a *startupBuffer
+	startup atomic.Value
+	// 1 when there are loggers and none of them looks at the caller, set
+	// with minLevel.  Accessed atomically.
+	sourceUnused int32
+	// 1 after TakeAccessLogs, accessed atomically
+	accessLogs int32
 	// This value is passed to runtime.Caller to get the file name/line and may require
 	// tweaking if you want to wrap the logger
 	FileDepth int
@@ -274,46 +536,166 @@ type timberAction int
 const (
 	actionAdd timberAction = iota
 	actionModify
+	actionFlush
+	actionStats
+	actionReopen
+	actionBuffer
+	actionSetLevel
+	actionGranulars
+	actionSummary
+	actionLevels
+	actionReplace
+	actionRemove
+	actionMetrics
 	actionQuit
 )
 
 type timberConfig struct {
-	Action timberAction // type of config action
-	Index  int          // only for modify
-	Cfg    ConfigLogger // used for modify or add
-	Ret    chan int     // only used for add
+	Action  timberAction      // type of config action
+	Index   int               // only for modify
+	Cfg     ConfigLogger      // used for modify or add
+	Ret     chan int          // only used for add
+	Stats   chan []WriteStats // only used for stats
+	Records chan *LogRecord   // only used for buffer
+	Tag     string            // only used for set level, remove and flushing one logger
+	Level   Level             // only used for set level and modify
+	Closing *closeProgress    // only used for quit, may be nil
+	// only used for granulars
+	Granulars map[string]Level
+	// only used for summary
+	Summary chan []string
+	// only used for levels
+	Levels chan []LoggerLevels
+	// only used for metrics
+	Metrics chan []LoggerMetrics
+	// only used for replace
+	Loggers []ConfigLogger
 }
 
 // Creates a new Timber logger that is ready to be configured
 // With no subsequent configuration, nothing will be logged
 //
+// The loggers are owned by a single goroutine that does all the
+// formatting and writing; AddLogger, Flush and the config loaders hand
+// their changes to it over a channel, so they're safe to call while other
+// goroutines are logging.
+//
 func NewTimber() *Timber {
 	t := new(Timber)
 	t.writerConfigChan = make(chan timberConfig)
-	t.recordChan = make(chan *LogRecord, 300)
+	t.recordChan = make(chan *LogRecord, DefaultDispatchBuffer)
 	t.FileDepth = DefaultFileDepth
 	t.closeLatch = &sync.Once{}
 	t.blackHole = make(chan int)
+	t.closeSync = &sync.RWMutex{}
 	go t.asyncLumberJack()
 	return t
 }
 
 func (t *Timber) asyncLumberJack() {
 	var loggers []ConfigLogger = make([]ConfigLogger, 0, 2)
+	// SetDispatchBuffer swaps the channel out from under us
+	records := t.recordChan
+	var closing *closeProgress
 	loopIt := true
 	for loopIt {
 		select {
-		case rec := <-t.recordChan:
-			sendToLoggers(loggers, rec)
+		case rec := <-records:
+			dispatchRecord(loggers, rec)
 		case cfg := <-t.writerConfigChan:
 			switch cfg.Action {
 			case actionAdd:
-				loggers = append(loggers, cfg.Cfg)
-				cfg.Ret <- (len(loggers) - 1)
+				if cfg.Cfg.TimeWrites {
+					cfg.Cfg.stats = new(WriteStats)
+				}
+				cfg.Cfg.counts = new(loggerCounts)
+				idx := tagIndex(loggers, cfg.Cfg.Tag)
+				switch {
+				case idx >= 0 && atomic.LoadInt32(&strictConfig) == 1:
+					cfg.Ret <- duplicateTag
+					continue
+				case idx >= 0:
+					// the old writer gets what was logged before the swap
+					drainPending(loggers, records)
+					loggers[idx].LogWriter.Close()
+					loggers[idx] = cfg.Cfg
+				default:
+					loggers = append(loggers, cfg.Cfg)
+					idx = len(loggers) - 1
+				}
+				t.storeLevels(loggers)
+				cfg.Ret <- idx
 			case actionModify:
+				if cfg.Index >= 0 && cfg.Index < len(loggers) {
+					loggers[cfg.Index].Level = cfg.Level
+					t.storeLevels(loggers)
+				}
+				cfg.Ret <- 0
+			case actionFlush:
+				drainPending(loggers, records)
+				if cfg.Tag != "" {
+					cfg.Ret <- flushTagWriters(loggers, cfg.Tag)
+				} else {
+					flushAllWriters(loggers)
+					cfg.Ret <- 0
+				}
+			case actionStats:
+				cfg.Stats <- collectStats(loggers)
+			case actionMetrics:
+				cfg.Metrics <- collectMetrics(loggers)
+			case actionSummary:
+				cfg.Summary <- summarizeLoggers(loggers)
+			case actionLevels:
+				cfg.Levels <- loggerLevels(loggers)
+			case actionReopen:
+				drainPending(loggers, records)
+				reopenAllWriters(loggers)
+				cfg.Ret <- 0
+			case actionSetLevel:
+				prev := setTagLevel(loggers, cfg.Tag, cfg.Level)
+				t.storeLevels(loggers)
+				cfg.Ret <- prev
+			case actionGranulars:
+				mergeGranulars(loggers, cfg.Granulars)
+				t.storeLevels(loggers)
+				cfg.Ret <- 0
+			case actionReplace:
+				// the old writers get what was logged before the swap
+				drainPending(loggers, records)
+				old := loggers
+				loggers = cfg.Loggers
+				for i := range loggers {
+					if loggers[i].TimeWrites {
+						loggers[i].stats = new(WriteStats)
+					}
+					loggers[i].counts = new(loggerCounts)
+				}
+				t.storeLevels(loggers)
+				closeAllWriters(old)
+				cfg.Ret <- 0
+			case actionRemove:
+				idx := tagIndex(loggers, cfg.Tag)
+				if idx >= 0 {
+					// the writer gets what was logged before it goes
+					drainPending(loggers, records)
+					loggers[idx].LogWriter.Close()
+					loggers = append(loggers[:idx:idx], loggers[idx+1:]...)
+					t.storeLevels(loggers)
+				}
+				cfg.Ret <- idx
+			case actionBuffer:
+				// SetDispatchBuffer holds off the senders, so once the
+				// old channel is empty it stays that way
+				drainPending(loggers, records)
+				records = cfg.Records
+				cfg.Ret <- 0
 			case actionQuit:
+				closing = cfg.Closing
+				if closing != nil {
+					closing.start(loggers)
+				}
 				close(t.blackHole)
-				close(t.recordChan)
+				close(records)
 				loopIt = false
 				defer func() {
 					cfg.Ret <- 0
@@ -322,70 +704,626 @@ func (t *Timber) asyncLumberJack() {
 		} // select
 	} // for
 	// drain the log channel before closing
-	for rec := range t.recordChan {
-		sendToLoggers(loggers, rec)
+	for rec := range records {
+		dispatchRecord(loggers, rec)
+	}
+	if closing != nil {
+		closing.closeAll(loggers)
+	} else {
+		closeAllWriters(loggers)
 	}
-	closeAllWriters(loggers)
 }
 
 func sendToLogger(rec *LogRecord, granLevel Level, formatted string, cLog ConfigLogger) bool {
-	if rec.Level >= granLevel || granLevel == 0 {
-		if formatted == "" {
+	if rec.Level >= granLevel || granLevel == 0 || rec.elevated {
+		if cLog.Sampler != nil && !rec.elevated && !cLog.Sampler.keep(rec) {
+			cLog.counts.filtered(rec.Level)
+			return false
+		}
+		if !cLog.keep(rec) {
+			cLog.counts.filtered(rec.Level)
+			return false
+		}
+		if len(cLog.Redactors) > 0 {
+			rec = redactRecord(rec, cLog.Redactors)
+		}
+		if rec.LoggerTag != cLog.Tag {
+			// the record is shared by every logger and may be queued
+			// by the ones before, so the tag goes on a copy
+			tagged := *rec
+			tagged.LoggerTag = cLog.Tag
+			rec = &tagged
+		}
+		if fFormatter := cLog.fieldFormatter(rec); fFormatter != nil {
+			cLog.Formatter = fFormatter
+		}
+		if cLog.stats != nil {
+			defer cLog.stats.record(time.Now())
+		}
+		cLog.counts.emitted(rec.Level)
+		if sw, ok := cLog.LogWriter.(StructuredWriter); ok && !rec.raw {
+			if err := sw.LogStructured(rec); err != nil {
+				cLog.counts.failed(rec.Level, err)
+				reportWriteError(cLog.LogWriter, err)
+			}
+			return true
+		}
+		if rec.raw {
+			formatted = rec.Message
+		} else if cLog.Prefix != "" || cLog.Suffix != "" {
+			formatted = cLog.Prefix + cLog.Formatter.Format(rec) + cLog.Suffix
+		} else if formatted == "" {
+			if fmtTo, ok := cLog.Formatter.(LogFormatterTo); ok {
+				if w, ok := cLog.LogWriter.(io.Writer); ok {
+					// the writer has reported the error already
+					_, err := fmtTo.FormatTo(w, rec)
+					cLog.counts.failed(rec.Level, err)
+					return true
+				}
+			}
 			formatted = cLog.Formatter.Format(rec)
 		}
+		if cw, ok := cLog.LogWriter.(CheckedWriter); ok {
+			err := cw.LogWriteChecked(rec, formatted)
+			cLog.counts.failed(rec.Level, err)
+			if err != nil && err != ErrQueueFull {
+				reportWriteError(cLog.LogWriter, err)
+			}
+			return true
+		}
+		if rw, ok := cLog.LogWriter.(RecordWriter); ok {
+			rw.LogWriteRecord(rec, formatted)
+			return true
+		}
 		cLog.LogWriter.LogWrite(formatted)
 		return true
 	}
+	cLog.counts.filtered(rec.Level)
 	return false
 }
 
+// Writes a record from a log call then recycles it if that's safe
+func dispatchRecord(loggers []ConfigLogger, rec *LogRecord) {
+	sendToLoggers(loggers, rec)
+	if canRecycle(loggers) {
+		putRecord(rec)
+	}
+}
+
 func sendToLoggers(loggers []ConfigLogger, rec *LogRecord) {
 	formatted := ""
 	for _, cLog := range loggers {
-		// Find any function level definitions.
-		gLevel, ok := cLog.Granulars[rec.FuncPath]
-		if ok {
-			sendToLogger(rec, gLevel, formatted, cLog)
+		if path, gLevel, ok := cLog.granularFor(rec); ok {
+			gMax, ok := cLog.GranularMax[path]
+			if !ok {
+				gMax = cLog.MaxLevel
+			}
+			if aboveMax(rec, gMax) {
+				cLog.counts.filtered(rec.Level)
+				continue
+			}
+			sendToLogger(rec, gLevel, formatted, cLog.forGranular(path))
 			continue
 		}
-		// Find any package level definitions.
-		gLevel, ok = cLog.Granulars[rec.PackagePath]
-		if ok {
-			sendToLogger(rec, gLevel, formatted, cLog)
+		// Use default definition
+		if aboveMax(rec, cLog.MaxLevel) {
+			cLog.counts.filtered(rec.Level)
 			continue
 		}
-		// Use default definition
 		sendToLogger(rec, cLog.Level, formatted, cLog)
 	}
 }
 
+// A max of zero is no ceiling, elevated records skip it like the lower bound
+func aboveMax(rec *LogRecord, max Level) bool {
+	return max != 0 && rec.Level > max && !rec.elevated
+}
+
+// Finds the most specific granular that matches the record: the longest
+// matching path wins, so a function definition beats its package.  A
+// package path only matches records from that exact package, not from
+// its sub-packages; the patterns in granular_patterns.go come after the
+// exact paths.  For a record from a NamedLogger the name and its dotted
+// ancestors are tried first and win over the source.
+func (cLog ConfigLogger) granularFor(rec *LogRecord) (string, Level, bool) {
+	if len(cLog.Granulars) == 0 {
+		return "", 0, false
+	}
+	if rec.LoggerName != "" {
+		if path, gLevel, ok := namedGranular(cLog.Granulars, rec.LoggerName); ok {
+			return path, gLevel, true
+		}
+	}
+	var best string
+	var bestLevel Level
+	found := false
+	for _, path := range [...]string{rec.FuncPath, rec.PackagePath} {
+		if gLevel, ok := cLog.Granulars[path]; ok && (!found || len(path) > len(best)) {
+			best, bestLevel, found = path, gLevel, true
+		}
+	}
+	if found {
+		return best, bestLevel, true
+	}
+	idx := cLog.granularPats
+	if idx == nil {
+		// not added to a Timber, e.g. in a test
+		idx = compileGranulars(cLog.Granulars)
+	}
+	if path, ok := idx.match(rec); ok {
+		return path, cLog.Granulars[path], true
+	}
+	return "", 0, false
+}
+
+// send whatever is already queued without blocking for more
+func drainPending(loggers []ConfigLogger, recordChan chan *LogRecord) {
+	for {
+		select {
+		case rec := <-recordChan:
+			dispatchRecord(loggers, rec)
+		default:
+			return
+		}
+	}
+}
+
+func flushAllWriters(cls []ConfigLogger) {
+	for _, cLog := range cls {
+		if fl, ok := cLog.LogWriter.(Flusher); ok {
+			fl.Flush()
+		}
+	}
+}
+
+// Flushes the writers of the loggers tagged tag that implement Flusher
+// and returns how many loggers have the tag
+func flushTagWriters(cls []ConfigLogger, tag string) int {
+	n := 0
+	for _, cLog := range cls {
+		if cLog.Tag != tag {
+			continue
+		}
+		n++
+		if fl, ok := cLog.LogWriter.(Flusher); ok {
+			fl.Flush()
+		}
+	}
+	return n
+}
+
+// Sets the level of the loggers tagged tag and returns the level the
+// first one had, -1 if none of them have the tag
+func setTagLevel(cls []ConfigLogger, tag string, lvl Level) int {
+	prev := -1
+	for i := range cls {
+		if cls[i].Tag != tag {
+			continue
+		}
+		if prev < 0 {
+			prev = int(cls[i].Level)
+		}
+		cls[i].Level = lvl
+	}
+	return prev
+}
+
+func reopenAllWriters(cls []ConfigLogger) {
+	for _, cLog := range cls {
+		if ro, ok := cLog.LogWriter.(Reopener); ok {
+			if err := ro.Reopen(); err != nil {
+				reportWriteError(cLog.LogWriter, err)
+			}
+		}
+	}
+}
+
+// The formatter rec picks with FormatField, nil if it doesn't pick one
+// this logger has
+func (cLog ConfigLogger) fieldFormatter(rec *LogRecord) LogFormatter {
+	if len(cLog.FieldFormatters) == 0 || len(rec.Fields) == 0 {
+		return nil
+	}
+	name, _ := rec.Fields[FormatField].(string)
+	return cLog.FieldFormatters[name]
+}
+
+// Swaps in the granular formatter for path if there is one
+func (cLog ConfigLogger) forGranular(path string) ConfigLogger {
+	if gFormatter, ok := cLog.GranularFormatters[path]; ok {
+		cLog.Formatter = gFormatter
+	}
+	return cLog
+}
+
 func closeAllWriters(cls []ConfigLogger) {
 	for _, cLog := range cls {
 		cLog.LogWriter.Close()
 	}
 }
 
+// Keeps track of the writers CloseWithTimeout is still waiting on
+type closeProgress struct {
+	mu      sync.Mutex
+	started bool
+	pending []string // writer names by logger index, "" once closed
+}
+
+// Called by the dispatch goroutine when it takes the quit
+func (cp *closeProgress) start(cls []ConfigLogger) {
+	cp.mu.Lock()
+	defer cp.mu.Unlock()
+	cp.started = true
+	for _, cLog := range cls {
+		name := fmt.Sprintf("%T", cLog.LogWriter)
+		if cLog.Tag != "" {
+			name += " (" + cLog.Tag + ")"
+		}
+		cp.pending = append(cp.pending, name)
+	}
+}
+
+// Closes the writers side by side so one that hangs doesn't hold up the
+// others, and waits for all of them
+func (cp *closeProgress) closeAll(cls []ConfigLogger) {
+	var wg sync.WaitGroup
+	for i, cLog := range cls {
+		wg.Add(1)
+		go func(i int, w LogWriter) {
+			defer wg.Done()
+			w.Close()
+			cp.mu.Lock()
+			cp.pending[i] = ""
+			cp.mu.Unlock()
+		}(i, cLog.LogWriter)
+	}
+	wg.Wait()
+}
+
+// The writers that haven't finished closing, nil if the dispatch
+// goroutine never got as far as the quit
+func (cp *closeProgress) laggards() []string {
+	cp.mu.Lock()
+	defer cp.mu.Unlock()
+	if !cp.started {
+		return nil
+	}
+	var names []string
+	for _, name := range cp.pending {
+		if name != "" {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// Hands a config action to the dispatch goroutine, false if it has
+// already been closed and nobody is listening
+func (t *Timber) configure(tc timberConfig) bool {
+	select {
+	case t.writerConfigChan <- tc:
+		return true
+	case <-t.blackHole:
+		return false
+	}
+}
+
 // MultiLogger interface
+// Returns -1 without adding the logger once the Timber has been closed.
+//
+// A logger with the same Tag as one that's already there replaces it and
+// takes its index; the old writer gets the messages already queued and
+// is then closed.  In strict mode (see StrictConfig) the new logger is
+// turned away instead and -1 is returned.  Loggers without a tag never
+// clash.
 func (t *Timber) AddLogger(logger ConfigLogger) int {
+	idx, _ := t.addLogger(logger)
+	return idx
+}
+
+// AddLogger with the reason it wasn't added
+func (t *Timber) addLogger(logger ConfigLogger) (int, error) {
 	tcChan := make(chan int, 1) // buffered
 	tc := timberConfig{Action: actionAdd, Cfg: logger, Ret: tcChan}
-	t.writerConfigChan <- tc
-	return <-tcChan
+	if !t.configure(tc) {
+		return -1, fmt.Errorf("TIMBER! Can't add a logger, the logger is closed")
+	}
+	idx := <-tcChan
+	if idx == duplicateTag {
+		return -1, fmt.Errorf("TIMBER! Duplicate logger tag %q", logger.Tag)
+	}
+	return idx, nil
 }
 
-// MultiLogger interface
+// Takes the logger tagged tag out once what's queued has been written to
+// it, then closes its writer.  The loggers after it move down an index.
+// Returns an error if no logger has the tag.
+func (t *Timber) RemoveLogger(tag string) error {
+	tcChan := make(chan int, 1)
+	if tag == "" || !t.configure(timberConfig{Action: actionRemove, Tag: tag, Ret: tcChan}) || <-tcChan < 0 {
+		return fmt.Errorf("TIMBER! No logger tagged %q", tag)
+	}
+	return nil
+}
+
+// from the dispatch goroutine when a strict add clashes with a tag
+const duplicateTag = -2
+
+// The index of the logger tagged tag, -1 if there isn't one
+func tagIndex(cls []ConfigLogger, tag string) int {
+	if tag == "" {
+		return -1
+	}
+	for i, cLog := range cls {
+		if cLog.Tag == tag {
+			return i
+		}
+	}
+	return -1
+}
+
+// MultiLogger interface.  Returns once the queued messages are written
+// and every writer is closed, buffered files included, so it's safe to
+// call right before os.Exit.  See CloseWithTimeout for a writer that may
+// hang.
 func (t *Timber) Close() {
 	t.closeLatch.Do(func() {
-		tcChan := make(chan int)
-		tc := timberConfig{Action: actionQuit, Ret: tcChan}
-		t.writerConfigChan <- tc
-		<-tcChan // block for cloosing
+		t.quit(nil)
+		t.runCloseHooks()
 	})
 }
 
-// Not yet implemented
+// Registers f to run once Close or CloseWithTimeout has written out the
+// queued messages and closed every writer, e.g. to tear down what the
+// writers depend on.  Hooks run in the order they were added on the
+// goroutine that called Close.  They don't run if CloseWithTimeout gives
+// up, or if they're added after the Timber was closed.
+func (t *Timber) OnClose(f func()) {
+	t.hookSync.Lock()
+	t.closeHooks = append(t.closeHooks, f)
+	t.hookSync.Unlock()
+}
+
+func (t *Timber) runCloseHooks() {
+	t.hookSync.Lock()
+	hooks := t.closeHooks
+	t.closeHooks = nil
+	t.hookSync.Unlock()
+	for _, f := range hooks {
+		f()
+	}
+}
+
+// Same as Close but gives up once d has passed, e.g. when a socket writer
+// is stuck reconnecting.  The error names the writers that were still
+// writing or closing; they're abandoned and left to finish in the
+// background.  If the dispatch goroutine itself is stuck on a write, log
+// calls made after the timeout can block.  With d <= 0 it waits as long
+// as Close would.  Returns nil if the Timber was already closed.
+func (t *Timber) CloseWithTimeout(d time.Duration) error {
+	if d <= 0 {
+		t.Close()
+		return nil
+	}
+	closing := new(closeProgress)
+	done := make(chan struct{})
+	first := false
+	t.closeLatch.Do(func() {
+		first = true
+		go func() {
+			t.quit(closing)
+			close(done)
+		}()
+	})
+	if !first {
+		return nil
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-done:
+		t.runCloseHooks()
+		return nil
+	case <-timer.C:
+	}
+	laggards := closing.laggards()
+	if laggards == nil {
+		return fmt.Errorf("TIMBER! Close timed out after %v before the queued messages were written", d)
+	}
+	return fmt.Errorf("TIMBER! Close timed out after %v waiting for %s", d, strings.Join(laggards, ", "))
+}
+
+func (t *Timber) quit(closing *closeProgress) {
+	t.ReleaseStartup()
+	// wait for in flight log calls then turn new ones away
+	t.closeSync.Lock()
+	t.closed = true
+	t.closeSync.Unlock()
+	tcChan := make(chan int)
+	tc := timberConfig{Action: actionQuit, Ret: tcChan, Closing: closing}
+	t.writerConfigChan <- tc
+	<-tcChan // block for cloosing
+}
+
+// Writes out any queued messages and flushes all the writers that
+// implement Flusher.  Blocks until the flush is done.
+func (t *Timber) Flush() {
+	tcChan := make(chan int, 1)
+	if t.configure(timberConfig{Action: actionFlush, Ret: tcChan}) {
+		<-tcChan
+	}
+	// otherwise it's already closed and everything has been flushed
+}
+
+// Flush with the signature zap and friends use, for code that does
+// defer logger.Sync().  Writers report their errors to WriteErrorHandler
+// so it always returns nil, also once the Timber is closed.
+func (t *Timber) Sync() error {
+	t.Flush()
+	return nil
+}
+
+// Same as Flush but only for the loggers tagged tag, e.g. to read back a
+// log file in a test without waiting on network writers.  Queued messages
+// are still written to every logger first.  Returns an error if no logger
+// has the tag; once closed it returns nil since everything has been
+// flushed.
+func (t *Timber) FlushLogger(tag string) error {
+	tcChan := make(chan int, 1)
+	if !t.configure(timberConfig{Action: actionFlush, Tag: tag, Ret: tcChan}) {
+		return nil
+	}
+	if <-tcChan == 0 {
+		return fmt.Errorf("TIMBER! No logger tagged %q", tag)
+	}
+	return nil
+}
+
+// Reopens the files of all the writers that implement Reopener.  Pending
+// messages are written to the old file first.  Blocks until it's done.
+func (t *Timber) Reopen() {
+	tcChan := make(chan int, 1)
+	if t.configure(timberConfig{Action: actionReopen, Ret: tcChan}) {
+		<-tcChan
+	}
+}
+
+// Changes how many records can be queued for the dispatch goroutine
+// before a log call blocks, DefaultDispatchBuffer to start with.  Records
+// already queued are written first.
+//
+// A bigger buffer takes more memory but absorbs bursts without slowing the
+// callers down.  With 0 there's no queue: each log call waits until the
+// dispatch goroutine takes its record, so a slow writer is felt right away
+// but at most one record is in flight (it still doesn't wait for the
+// write).  Either way records from one goroutine are written in the order
+// they were logged and Flush and Close write out whatever is queued.
+func (t *Timber) SetDispatchBuffer(n int) {
+	if n < 0 {
+		n = 0
+	}
+	// hold off new log calls until the new channel is in place
+	t.closeSync.Lock()
+	defer t.closeSync.Unlock()
+	if t.closed {
+		return
+	}
+	records := make(chan *LogRecord, n)
+	tcChan := make(chan int, 1)
+	if t.configure(timberConfig{Action: actionBuffer, Records: records, Ret: tcChan}) {
+		<-tcChan
+		t.recordChan = records
+	}
+}
+
+type levelRevert struct {
+	timer *time.Timer
+	level Level // what the level was before the first SetLevelFor
+}
+
+// Changes the level of the loggers tagged tag for d then puts the level
+// back, e.g. to turn on DEBUG for a few minutes in production.  Calling it
+// again for the same tag before the revert replaces the pending one; the
+// level still goes back to what it was before the first call.  A d of zero
+// or less keeps the new level and drops any pending revert.  Returns an
+// error if no logger has the tag.
+func (t *Timber) SetLevelFor(tag string, lvl Level, d time.Duration) error {
+	t.revertSync.Lock()
+	defer t.revertSync.Unlock()
+	prev := t.setTagLevel(tag, lvl)
+	if prev < 0 {
+		return fmt.Errorf("TIMBER! No logger tagged %q", tag)
+	}
+	original := Level(prev)
+	if pending, ok := t.reverts[tag]; ok {
+		pending.timer.Stop()
+		original = pending.level
+		delete(t.reverts, tag)
+	}
+	if d <= 0 {
+		return nil
+	}
+	revert := &levelRevert{level: original}
+	revert.timer = time.AfterFunc(d, func() {
+		t.revertSync.Lock()
+		defer t.revertSync.Unlock()
+		if t.reverts[tag] == revert {
+			delete(t.reverts, tag)
+			t.setTagLevel(tag, revert.level)
+		}
+	})
+	if t.reverts == nil {
+		t.reverts = make(map[string]*levelRevert)
+	}
+	t.reverts[tag] = revert
+	return nil
+}
+
+// -1 if there's no logger with the tag or the Timber is closed
+func (t *Timber) setTagLevel(tag string, lvl Level) int {
+	tcChan := make(chan int, 1)
+	if !t.configure(timberConfig{Action: actionSetLevel, Tag: tag, Level: lvl, Ret: tcChan}) {
+		return -1
+	}
+	return <-tcChan
+}
+
+// Changes the level of the logger at index, the number AddLogger
+// returned.  Loggers from a config file are numbered in the order of
+// their filters, see Levels.  An index with no logger is ignored.
 func (t *Timber) SetLevel(index int, lvl Level) {
-	// TODO
+	tcChan := make(chan int, 1)
+	if t.configure(timberConfig{Action: actionModify, Index: index, Level: lvl, Ret: tcChan}) {
+		<-tcChan
+	}
+}
+
+// Sets the granular level for path (a package path, package path +
+// function name or a pattern, see granular_patterns.go) on every logger,
+// replacing any level the path already had.  See LoadGranulars for doing
+// the same from a file.
+func (t *Timber) SetGranular(path string, lvl Level) error {
+	if err := CheckGranularPath(path); err != nil {
+		return err
+	}
+	tcChan := make(chan int, 1)
+	if !t.configure(timberConfig{Action: actionGranulars, Granulars: map[string]Level{path: lvl}, Ret: tcChan}) {
+		return fmt.Errorf("TIMBER! Can't set a granular, the logger is closed")
+	}
+	<-tcChan
+	return nil
+}
+
+// The levels of one logger as returned by Levels
+type LoggerLevels struct {
+	Tag       string
+	Level     Level
+	Granulars map[string]Level
+}
+
+// The current levels of every logger, by index, e.g. to show on an admin
+// page next to SetLevel and SetGranular.  Empty once t is closed.
+func (t *Timber) Levels() []LoggerLevels {
+	levels := make(chan []LoggerLevels, 1)
+	if !t.configure(timberConfig{Action: actionLevels, Levels: levels}) {
+		return nil
+	}
+	return <-levels
+}
+
+// The granular maps are copied so the caller can't race the dispatcher
+func loggerLevels(cls []ConfigLogger) []LoggerLevels {
+	levels := make([]LoggerLevels, len(cls))
+	for i, cLog := range cls {
+		levels[i] = LoggerLevels{Tag: cLog.Tag, Level: cLog.Level}
+		if len(cLog.Granulars) > 0 {
+			levels[i].Granulars = make(map[string]Level, len(cLog.Granulars))
+			for path, lvl := range cLog.Granulars {
+				levels[i].Granulars[path] = lvl
+			}
+		}
+	}
+	return levels
 }
 
 // Not yet implemented
@@ -393,38 +1331,269 @@ func (t *Timber) SetFormatter(index int, formatter LogFormatter) {
 	// TODO
 }
 
+// Turns the logger into a no-op; every log call returns right away
+// without formatting or dispatching anything.  Warn, Error, Critical,
+// Panic and Fatal still return, panic or exit as usual.
+func (t *Timber) Disable() {
+	atomic.StoreInt32(&t.disabled, 1)
+}
+
+// Restores logging with the configured loggers after Disable
+func (t *Timber) Enable() {
+	atomic.StoreInt32(&t.disabled, 0)
+}
+
+func (t *Timber) isDisabled() bool {
+	return atomic.LoadInt32(&t.disabled) != 0
+}
+
+// The message for a log call: fmt.Sprintf without the copy when there's
+// nothing to format.  It only runs once the level check has passed, so
+// arguments are evaluated lazily: a func() string or func() interface{}
+// anywhere in args is called here and a fmt.Stringer is only asked for
+// its String then.  arg0 may be such a func too, its result is the
+// format, or the message as is without args; anything else that isn't a
+// string is printed with %v the same way.
+func sprintf(arg0 interface{}, args []interface{}) string {
+	var format string
+	switch a := arg0.(type) {
+	case string:
+		format = a
+	case func() string:
+		if format = a(); len(args) == 0 {
+			return format
+		}
+	default:
+		if format = fmt.Sprint(lazyValue(arg0)); len(args) == 0 {
+			return format
+		}
+	}
+	if len(args) == 0 && strings.IndexByte(format, '%') < 0 {
+		return format
+	}
+	for i, arg := range args {
+		if isLazy(arg) {
+			// don't change the caller's slice, Debug(format, args...) passes it through
+			args = append([]interface{}(nil), args...)
+			for j := i; j < len(args); j++ {
+				args[j] = lazyValue(args[j])
+			}
+			break
+		}
+	}
+	return fmt.Sprintf(format, args...)
+}
+
+func isLazy(arg interface{}) bool {
+	switch arg.(type) {
+	case func() string, func() interface{}:
+		return true
+	}
+	return false
+}
+
+// The value of a lazy argument, any other argument as is
+func lazyValue(arg interface{}) interface{} {
+	switch f := arg.(type) {
+	case func() string:
+		return f()
+	case func() interface{}:
+		return f()
+	}
+	return arg
+}
+
+// True when no logger would take a record at lvl so the log call can
+// return before formatting the message
+func (t *Timber) skip(lvl Level) bool {
+	return t.isDisabled() || int32(lvl) < atomic.LoadInt32(&t.minLevel)
+}
+
+// The lowest level any of the loggers takes, granulars included
+func minLoggerLevel(cls []ConfigLogger) Level {
+	min := NONE
+	for i, cLog := range cls {
+		if i == 0 || cLog.Level < min {
+			min = cLog.Level
+		}
+		for _, gLevel := range cLog.Granulars {
+			if gLevel < min {
+				min = gLevel
+			}
+		}
+	}
+	return min
+}
+
 // Logger interface
 func (t *Timber) prepareAndSend(lvl Level, msg string, depth int) {
-	select {
-	case <-t.blackHole:
-		// the blackHole always blocks until we close
-		// then it always succeeds so we avoid writing
-		// to the closed channel
-	default:
-		t.recordChan <- t.prepare(lvl, msg, depth+1)
+	t.prepareAndSendFields(lvl, msg, nil, depth+1)
+}
+
+func (t *Timber) prepareAndSendFields(lvl Level, msg string, fields Fields, depth int) {
+	if t.skip(lvl) {
+		return
+	}
+	rec := t.prepare(lvl, msg, depth+1)
+	rec.Fields = fields
+	t.send(rec)
+}
+
+// Same as prepareAndSendFields with the fields from ctx.  A context from
+// ContextWithDebug lets the record past the loggers' levels.
+func (t *Timber) prepareAndSendContext(ctx context.Context, lvl Level, msg string, depth int) {
+	elevated := contextElevates(ctx, lvl)
+	if t.isDisabled() || (!elevated && t.skip(lvl)) {
+		return
+	}
+	rec := t.prepare(lvl, msg, depth+1)
+	rec.Fields = fieldsFromContext(ctx)
+	rec.elevated = elevated
+	rec.skipMDC = hasMDC(ctx)
+	t.send(rec)
+}
+
+// skip for the ...Context methods
+func (t *Timber) skipContext(ctx context.Context, lvl Level) bool {
+	return t.skip(lvl) && (t.isDisabled() || !contextElevates(ctx, lvl))
+}
+
+// Same as prepareAndSend but the message skips the formatters
+func (t *Timber) prepareAndSendRaw(lvl Level, msg string, depth int) {
+	if t.skip(lvl) {
+		return
+	}
+	rec := t.prepare(lvl, msg, depth+1)
+	rec.raw = true
+	t.send(rec)
+}
+
+func (t *Timber) send(rec *LogRecord) {
+	if !rec.skipMDC {
+		rec.Fields = withMDC(rec.Fields)
+	}
+	if !t.holdStartup(rec) {
+		t.queue(rec)
 	}
 }
 
+// Hands the record to the dispatch goroutine
+func (t *Timber) queue(rec *LogRecord) {
+	t.closeSync.RLock()
+	if !t.closed {
+		// this may block while the channel is full, the dispatch
+		// goroutine keeps draining it so Close just waits its turn
+		t.recordChan <- rec
+	}
+	t.closeSync.RUnlock()
+}
+
+var timeFunc atomic.Value // func() time.Time
+
+// Replaces time.Now as the source of record timestamps, e.g. to freeze
+// time in tests.  Passing nil goes back to time.Now.
+func SetTimeFunc(now func() time.Time) {
+	if now == nil {
+		now = time.Now
+	}
+	timeFunc.Store(now)
+}
+
+func currentTime() time.Time {
+	if now, ok := timeFunc.Load().(func() time.Time); ok {
+		return now()
+	}
+	return time.Now()
+}
+
+// set by DisableSourceCapture, accessed atomically
+var noSourceCapture int32
+
+// Turns off looking up the caller of every log call, whatever the
+// formatters ask for.  The source directives (%S, %s, %x, %F, %f)
+// render ???, %l renders 0, %P and %p render _ and the records have no
+// caller, so granulars can't match either.  This affects every Timber.
+func DisableSourceCapture() {
+	atomic.StoreInt32(&noSourceCapture, 1)
+}
+
+// Undoes DisableSourceCapture
+func EnableSourceCapture() {
+	atomic.StoreInt32(&noSourceCapture, 0)
+}
+
+// True if the log calls look up their caller: one of the loggers has
+// granulars, a formatter or filter that uses the caller, or a writer that
+// gets the record (see SourceUser), or there are no loggers yet.  When
+// it's false records have no source, as with DisableSourceCapture.
+func (t *Timber) SourceNeeded() bool {
+	if atomic.LoadInt32(&noSourceCapture) == 1 {
+		return false
+	}
+	// the records BufferStartup holds are for loggers that aren't there yet
+	return atomic.LoadInt32(&t.sourceUnused) == 0 || t.holdingStartup()
+}
+
 func (t *Timber) prepare(lvl Level, msg string, depth int) *LogRecord {
-	now := time.Now()
-	pc, file, line, _ := runtime.Caller(depth)
-	funcPath := "_"
-	packagePath := "_"
-	me := runtime.FuncForPC(pc)
-	if me != nil {
-		funcPath = me.Name()
-		packagePath = splitPackage(funcPath)
+	now := currentTime()
+	// runtime.Caller allocates, Callers into an array doesn't
+	var pcs [1]uintptr
+	if t.SourceNeeded() && runtime.Callers(depth+1, pcs[:]) > 0 {
+		return prepareAt(lvl, msg, now, pcs[0])
+	}
+	return prepareAt(lvl, msg, now, 0)
+}
+
+// Same as prepare with the caller's pc from runtime.Callers already in
+// hand, 0 if it's unknown
+func prepareAt(lvl Level, msg string, now time.Time, pc uintptr) *LogRecord {
+	caller := &unknownCaller
+	if pc != 0 && atomic.LoadInt32(&noSourceCapture) == 0 {
+		caller = callerFor(pc)
 	}
 
-	return &LogRecord{
+	rec := getRecord()
+	*rec = LogRecord{
 		Level:       lvl,
 		Timestamp:   now,
-		SourceFile:  file,
-		SourceLine:  line,
+		SourceFile:  caller.file,
+		SourceLine:  caller.line,
 		Message:     msg,
-		FuncPath:    funcPath,
-		PackagePath: packagePath,
+		FuncPath:    caller.funcPath,
+		PackagePath: caller.packagePath,
+		LevelCount:  nextLevelCount(lvl),
+		Monotonic:   nextMonotonic(),
+		Delta:       nextDelta(now),
 	}
+	return rec
+}
+
+// Records are reused once they've been written unless a logger has a
+// RecordWriter or StructuredWriter, which are allowed to keep them
+var recordPool = sync.Pool{
+	New: func() interface{} { return new(LogRecord) },
+}
+
+func getRecord() *LogRecord {
+	return recordPool.Get().(*LogRecord)
+}
+
+func putRecord(rec *LogRecord) {
+	*rec = LogRecord{}
+	recordPool.Put(rec)
+}
+
+// False if any of the writers may hang on to a record after the write
+func canRecycle(cls []ConfigLogger) bool {
+	for _, cLog := range cls {
+		if _, ok := cLog.LogWriter.(RecordWriter); ok {
+			return false
+		}
+		if _, ok := cLog.LogWriter.(StructuredWriter); ok {
+			return false
+		}
+	}
+	return true
 }
 
 // This function allows a Timber instance to be used in the standard library
@@ -436,52 +1605,174 @@ func (t *Timber)Write(p []byte) (n int, err error) {
 }
 
 func (t *Timber) Finest(arg0 interface{}, args ...interface{}) {
-	t.prepareAndSend(FINEST, fmt.Sprintf(arg0.(string), args...), t.FileDepth)
+	if t.skip(FINEST) {
+		return
+	}
+	t.prepareAndSend(FINEST, sprintf(arg0, args), t.FileDepth)
 }
 func (t *Timber) Fine(arg0 interface{}, args ...interface{}) {
-	t.prepareAndSend(FINE, fmt.Sprintf(arg0.(string), args...), t.FileDepth)
+	if t.skip(FINE) {
+		return
+	}
+	t.prepareAndSend(FINE, sprintf(arg0, args), t.FileDepth)
 }
 func (t *Timber) Debug(arg0 interface{}, args ...interface{}) {
-	t.prepareAndSend(DEBUG, fmt.Sprintf(arg0.(string), args...), t.FileDepth)
+	if t.skip(DEBUG) {
+		return
+	}
+	t.prepareAndSend(DEBUG, sprintf(arg0, args), t.FileDepth)
 }
 func (t *Timber) Trace(arg0 interface{}, args ...interface{}) {
-	t.prepareAndSend(TRACE, fmt.Sprintf(arg0.(string), args...), t.FileDepth)
+	if t.skip(TRACE) {
+		return
+	}
+	t.prepareAndSend(TRACE, sprintf(arg0, args), t.FileDepth)
 }
 func (t *Timber) Info(arg0 interface{}, args ...interface{}) {
-	t.prepareAndSend(INFO, fmt.Sprintf(arg0.(string), args...), t.FileDepth)
+	if t.skip(INFO) {
+		return
+	}
+	t.prepareAndSend(INFO, sprintf(arg0, args), t.FileDepth)
 }
 func (t *Timber) Warn(arg0 interface{}, args ...interface{}) error {
-	msg := fmt.Sprintf(arg0.(string), args...)
+	msg := sprintf(arg0, args)
 	t.prepareAndSend(WARNING, msg, t.FileDepth)
 	return errors.New(msg)
 }
 func (t *Timber) Error(arg0 interface{}, args ...interface{}) error {
-	msg := fmt.Sprintf(arg0.(string), args...)
+	msg := sprintf(arg0, args)
 	t.prepareAndSend(ERROR, msg, t.FileDepth)
 	return errors.New(msg)
 }
 func (t *Timber) Critical(arg0 interface{}, args ...interface{}) error {
-	msg := fmt.Sprintf(arg0.(string), args...)
+	msg := sprintf(arg0, args)
 	t.prepareAndSend(CRITICAL, msg, t.FileDepth)
 	return errors.New(msg)
 }
 func (t *Timber) Log(lvl Level, arg0 interface{}, args ...interface{}) {
-	t.prepareAndSend(lvl, fmt.Sprintf(arg0.(string), args...), t.FileDepth)
+	if t.skip(lvl) {
+		return
+	}
+	t.prepareAndSend(lvl, sprintf(arg0, args), t.FileDepth)
+}
+
+// Sends b to the writers of every logger that takes lvl (granulars
+// included) without running it through a formatter, e.g. to pass along
+// records that are already serialized.  The writers still add their line
+// termination.
+func (t *Timber) LogRaw(lvl Level, b []byte) {
+	t.prepareAndSendRaw(lvl, string(b), t.FileDepth)
+}
+
+// The ...Context methods are the same as the plain logging methods but
+// add the fields from any registered ContextExtractor to the record
+func (t *Timber) FinestContext(ctx context.Context, arg0 interface{}, args ...interface{}) {
+	if t.skipContext(ctx, FINEST) {
+		return
+	}
+	t.prepareAndSendContext(ctx, FINEST, sprintf(arg0, args), t.FileDepth)
+}
+func (t *Timber) FineContext(ctx context.Context, arg0 interface{}, args ...interface{}) {
+	if t.skipContext(ctx, FINE) {
+		return
+	}
+	t.prepareAndSendContext(ctx, FINE, sprintf(arg0, args), t.FileDepth)
+}
+func (t *Timber) DebugContext(ctx context.Context, arg0 interface{}, args ...interface{}) {
+	if t.skipContext(ctx, DEBUG) {
+		return
+	}
+	t.prepareAndSendContext(ctx, DEBUG, sprintf(arg0, args), t.FileDepth)
+}
+func (t *Timber) TraceContext(ctx context.Context, arg0 interface{}, args ...interface{}) {
+	if t.skipContext(ctx, TRACE) {
+		return
+	}
+	t.prepareAndSendContext(ctx, TRACE, sprintf(arg0, args), t.FileDepth)
+}
+func (t *Timber) InfoContext(ctx context.Context, arg0 interface{}, args ...interface{}) {
+	if t.skipContext(ctx, INFO) {
+		return
+	}
+	t.prepareAndSendContext(ctx, INFO, sprintf(arg0, args), t.FileDepth)
+}
+func (t *Timber) WarnContext(ctx context.Context, arg0 interface{}, args ...interface{}) error {
+	msg := sprintf(arg0, args)
+	t.prepareAndSendContext(ctx, WARNING, msg, t.FileDepth)
+	return errors.New(msg)
+}
+func (t *Timber) ErrorContext(ctx context.Context, arg0 interface{}, args ...interface{}) error {
+	msg := sprintf(arg0, args)
+	t.prepareAndSendContext(ctx, ERROR, msg, t.FileDepth)
+	return errors.New(msg)
+}
+func (t *Timber) CriticalContext(ctx context.Context, arg0 interface{}, args ...interface{}) error {
+	msg := sprintf(arg0, args)
+	t.prepareAndSendContext(ctx, CRITICAL, msg, t.FileDepth)
+	return errors.New(msg)
+}
+func (t *Timber) LogContext(ctx context.Context, lvl Level, arg0 interface{}, args ...interface{}) {
+	if t.skipContext(ctx, lvl) {
+		return
+	}
+	t.prepareAndSendContext(ctx, lvl, sprintf(arg0, args), t.FileDepth)
+}
+
+// The ...w methods take a plain message (not a format string) and
+// structured fields.  They share the dispatch path with the printf style
+// methods: either way the record carries the final text in Message and
+// any fields in Fields, and the formatter decides how to show both.
+func (t *Timber) Finestw(msg string, fields Fields) {
+	t.prepareAndSendFields(FINEST, msg, fields, t.FileDepth)
+}
+func (t *Timber) Finew(msg string, fields Fields) {
+	t.prepareAndSendFields(FINE, msg, fields, t.FileDepth)
+}
+func (t *Timber) Debugw(msg string, fields Fields) {
+	t.prepareAndSendFields(DEBUG, msg, fields, t.FileDepth)
+}
+func (t *Timber) Tracew(msg string, fields Fields) {
+	t.prepareAndSendFields(TRACE, msg, fields, t.FileDepth)
+}
+func (t *Timber) Infow(msg string, fields Fields) {
+	t.prepareAndSendFields(INFO, msg, fields, t.FileDepth)
+}
+func (t *Timber) Warnw(msg string, fields Fields) error {
+	t.prepareAndSendFields(WARNING, msg, fields, t.FileDepth)
+	return errors.New(msg)
+}
+func (t *Timber) Errorw(msg string, fields Fields) error {
+	t.prepareAndSendFields(ERROR, msg, fields, t.FileDepth)
+	return errors.New(msg)
+}
+func (t *Timber) Criticalw(msg string, fields Fields) error {
+	t.prepareAndSendFields(CRITICAL, msg, fields, t.FileDepth)
+	return errors.New(msg)
+}
+func (t *Timber) Logw(lvl Level, msg string, fields Fields) {
+	t.prepareAndSendFields(lvl, msg, fields, t.FileDepth)
 }
 
-// Print won't work well with a pattern_logger because it explicitly adds
-// its own \n; so you'd have to write your own formatter to remove it
 func (t *Timber) Print(v ...interface{}) {
+	if t.skip(NONE) {
+		return
+	}
 	t.prepareAndSend(NONE, fmt.Sprint(v...), t.FileDepth)
 }
 func (t *Timber) Printf(format string, v ...interface{}) {
+	if t.skip(NONE) {
+		return
+	}
 	t.prepareAndSend(NONE, fmt.Sprintf(format, v...), t.FileDepth)
 }
 
-// Println won't work well either with a pattern_logger because it explicitly adds
-// its own \n; so you'd have to write your own formatter to not have 2 \n's
+// The newline fmt.Sprintln adds is dropped, the writers end the line,
+// here and in Panicln and Fatalln
 func (t *Timber) Println(v ...interface{}) {
-	t.prepareAndSend(NONE, fmt.Sprintln(v...), t.FileDepth)
+	if t.skip(NONE) {
+		return
+	}
+	t.prepareAndSend(NONE, strings.TrimSuffix(fmt.Sprintln(v...), "\n"), t.FileDepth)
 }
 func (t *Timber) Panic(v ...interface{}) {
 	msg := fmt.Sprint(v...)
@@ -494,7 +1785,7 @@ func (t *Timber) Panicf(format string, v ...interface{}) {
 	panic(msg)
 }
 func (t *Timber) Panicln(v ...interface{}) {
-	msg := fmt.Sprintln(v...)
+	msg := strings.TrimSuffix(fmt.Sprintln(v...), "\n")
 	t.prepareAndSend(NONE, msg, t.FileDepth)
 	panic(msg)
 }
@@ -511,7 +1802,7 @@ func (t *Timber) Fatalf(format string, v ...interface{}) {
 	os.Exit(1)
 }
 func (t *Timber) Fatalln(v ...interface{}) {
-	msg := fmt.Sprintln(v...)
+	msg := strings.TrimSuffix(fmt.Sprintln(v...), "\n")
 	t.prepareAndSend(NONE, msg, t.FileDepth)
 	t.Close()
 	os.Exit(1)
@@ -523,32 +1814,139 @@ func (t *Timber) Fatalln(v ...interface{}) {
 //
 //
 
-// Default Timber Instance (used for all the package level function calls)
+// Default Timber Instance (used for all the package level function calls).
+// Replace it with SetGlobal rather than assigning to it.
 var Global = NewTimber()
 
+// what the package functions log to, a *Timber stored by SetGlobal
+var globalTimber = func() *atomic.Value {
+	v := new(atomic.Value)
+	v.Store(Global)
+	return v
+}()
+
+func global() *Timber {
+	return globalTimber.Load().(*Timber)
+}
+
+// The Timber the package functions log to at the moment, Global unless
+// SetGlobal replaced it.  Unlike reading Global it's safe while another
+// goroutine calls SetGlobal.
+func GetGlobal() *Timber {
+	return global()
+}
+
+// Makes t the Timber the package functions log to and returns the one it
+// replaces, nil for a new Timber without loggers.  The swap is atomic:
+// each log call goes to one or the other, and the old one keeps working
+// until it's closed, so configure t first, swap, then Close the old one
+// to write out what it was given.  A log call that picked the old one
+// just before the swap and reaches it after Close is dropped.
+// FieldLoggers, NamedLoggers and watchers made from the package functions
+// stay with the Timber they were made from; the signal handlers and
+// HijackStdLog follow the swap.  The Global variable is set too, for code
+// that reads it, but reading it isn't synchronized with SetGlobal; use
+// GetGlobal where the two can run at once.
+func SetGlobal(t *Timber) *Timber {
+	if t == nil {
+		t = NewTimber()
+	}
+	old := globalTimber.Swap(t).(*Timber)
+	Global = t
+	return old
+}
+
+// Setting TIMBER_DISABLE to a true value (1, t, true) disables Global at startup
+func init() {
+	if disable, _ := strconv.ParseBool(os.Getenv("TIMBER_DISABLE")); disable {
+		global().Disable()
+	}
+}
+
 // Simple wrappers for Logger interface
-func Finest(arg0 interface{}, args ...interface{})         { Global.Finest(arg0, args...) }
-func Fine(arg0 interface{}, args ...interface{})           { Global.Fine(arg0, args...) }
-func Debug(arg0 interface{}, args ...interface{})          { Global.Debug(arg0, args...) }
-func Trace(arg0 interface{}, args ...interface{})          { Global.Trace(arg0, args...) }
-func Info(arg0 interface{}, args ...interface{})           { Global.Info(arg0, args...) }
-func Warn(arg0 interface{}, args ...interface{}) error     { return Global.Warn(arg0, args...) }
-func Error(arg0 interface{}, args ...interface{}) error    { return Global.Error(arg0, args...) }
-func Critical(arg0 interface{}, args ...interface{}) error { return Global.Critical(arg0, args...) }
-func Log(lvl Level, arg0 interface{}, args ...interface{}) { Global.Log(lvl, arg0, args...) }
-func Print(v ...interface{})                               { Global.Print(v...) }
-func Printf(format string, v ...interface{})               { Global.Printf(format, v...) }
-func Println(v ...interface{})                             { Global.Println(v...) }
-func Panic(v ...interface{})                               { Global.Panic(v...) }
-func Panicf(format string, v ...interface{})               { Global.Panicf(format, v...) }
-func Panicln(v ...interface{})                             { Global.Panicln(v...) }
-func Fatal(v ...interface{})                               { Global.Fatal(v...) }
-func Fatalf(format string, v ...interface{})               { Global.Fatalf(format, v...) }
-func Fatalln(v ...interface{})                             { Global.Fatalln(v...) }
-
-func AddLogger(logger ConfigLogger) int { return Global.AddLogger(logger) }
-func Close()                            { Global.Close() }
-
-func LoadConfiguration(filename string)     { Global.LoadConfig(filename) }
-func LoadXMLConfiguration(filename string)  { Global.LoadXMLConfig(filename) }
-func LoadJSONConfiguration(filename string) { Global.LoadJSONConfig(filename) }
+func Finest(arg0 interface{}, args ...interface{})         { global().Finest(arg0, args...) }
+func Fine(arg0 interface{}, args ...interface{})           { global().Fine(arg0, args...) }
+func Debug(arg0 interface{}, args ...interface{})          { global().Debug(arg0, args...) }
+func Trace(arg0 interface{}, args ...interface{})          { global().Trace(arg0, args...) }
+func Info(arg0 interface{}, args ...interface{})           { global().Info(arg0, args...) }
+func Warn(arg0 interface{}, args ...interface{}) error     { return global().Warn(arg0, args...) }
+func Error(arg0 interface{}, args ...interface{}) error    { return global().Error(arg0, args...) }
+func Critical(arg0 interface{}, args ...interface{}) error { return global().Critical(arg0, args...) }
+func Log(lvl Level, arg0 interface{}, args ...interface{}) { global().Log(lvl, arg0, args...) }
+func LogRaw(lvl Level, b []byte)                           { global().LogRaw(lvl, b) }
+func Print(v ...interface{})                               { global().Print(v...) }
+func Printf(format string, v ...interface{})               { global().Printf(format, v...) }
+func Println(v ...interface{})                             { global().Println(v...) }
+func Panic(v ...interface{})                               { global().Panic(v...) }
+func Panicf(format string, v ...interface{})               { global().Panicf(format, v...) }
+func Panicln(v ...interface{})                             { global().Panicln(v...) }
+func Fatal(v ...interface{})                               { global().Fatal(v...) }
+func Fatalf(format string, v ...interface{})               { global().Fatalf(format, v...) }
+func Fatalln(v ...interface{})                             { global().Fatalln(v...) }
+
+func FinestContext(ctx context.Context, arg0 interface{}, args ...interface{}) {
+	global().FinestContext(ctx, arg0, args...)
+}
+func FineContext(ctx context.Context, arg0 interface{}, args ...interface{}) {
+	global().FineContext(ctx, arg0, args...)
+}
+func DebugContext(ctx context.Context, arg0 interface{}, args ...interface{}) {
+	global().DebugContext(ctx, arg0, args...)
+}
+func TraceContext(ctx context.Context, arg0 interface{}, args ...interface{}) {
+	global().TraceContext(ctx, arg0, args...)
+}
+func InfoContext(ctx context.Context, arg0 interface{}, args ...interface{}) {
+	global().InfoContext(ctx, arg0, args...)
+}
+func WarnContext(ctx context.Context, arg0 interface{}, args ...interface{}) error {
+	return global().WarnContext(ctx, arg0, args...)
+}
+func ErrorContext(ctx context.Context, arg0 interface{}, args ...interface{}) error {
+	return global().ErrorContext(ctx, arg0, args...)
+}
+func CriticalContext(ctx context.Context, arg0 interface{}, args ...interface{}) error {
+	return global().CriticalContext(ctx, arg0, args...)
+}
+func LogContext(ctx context.Context, lvl Level, arg0 interface{}, args ...interface{}) {
+	global().LogContext(ctx, lvl, arg0, args...)
+}
+
+func Finestw(msg string, fields Fields)         { global().Finestw(msg, fields) }
+func Finew(msg string, fields Fields)           { global().Finew(msg, fields) }
+func Debugw(msg string, fields Fields)          { global().Debugw(msg, fields) }
+func Tracew(msg string, fields Fields)          { global().Tracew(msg, fields) }
+func Infow(msg string, fields Fields)           { global().Infow(msg, fields) }
+func Warnw(msg string, fields Fields) error     { return global().Warnw(msg, fields) }
+func Errorw(msg string, fields Fields) error    { return global().Errorw(msg, fields) }
+func Criticalw(msg string, fields Fields) error { return global().Criticalw(msg, fields) }
+func Logw(lvl Level, msg string, fields Fields) { global().Logw(lvl, msg, fields) }
+
+func AddLogger(logger ConfigLogger) int { return global().AddLogger(logger) }
+func Flush()                            { global().Flush() }
+func Reopen()                           { global().Reopen() }
+func SetDispatchBuffer(n int)           { global().SetDispatchBuffer(n) }
+func Disable()                          { global().Disable() }
+func Enable()                           { global().Enable() }
+func Close()                            { global().Close() }
+
+func CloseWithTimeout(d time.Duration) error { return global().CloseWithTimeout(d) }
+func OnClose(f func())                       { global().OnClose(f) }
+func FlushLogger(tag string) error           { return global().FlushLogger(tag) }
+func RemoveLogger(tag string) error          { return global().RemoveLogger(tag) }
+func Sync() error                            { return global().Sync() }
+
+func SetLevelFor(tag string, lvl Level, d time.Duration) error {
+	return global().SetLevelFor(tag, lvl, d)
+}
+
+func SetLevel(index int, lvl Level)            { global().SetLevel(index, lvl) }
+func SetGranular(path string, lvl Level) error { return global().SetGranular(path, lvl) }
+func Levels() []LoggerLevels                   { return global().Levels() }
+
+func LoadConfiguration(filename string)     { global().LoadConfig(filename) }
+func LoadXMLConfiguration(filename string)  { global().LoadXMLConfig(filename) }
+func LoadJSONConfiguration(filename string) { global().LoadJSONConfig(filename) }
+func LoadYAMLConfiguration(filename string) { global().LoadYAMLConfig(filename) }
+func LoadTOMLConfiguration(filename string) { global().LoadTOMLConfig(filename) }
+func LoadConfigAuto(filename string) error  { return global().LoadConfigAuto(filename) }
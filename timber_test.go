@@ -1,7 +1,19 @@
 package timber
 
 import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/base64"
+	"encoding/xml"
+	"fmt"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 )
 
 func TestConsole(t *testing.T) {
@@ -43,6 +55,19 @@ func TestJsonConfig(t *testing.T) {
 	log.Close()
 }
 
+func TestJsonConfigString(t *testing.T) {
+	log := NewTimber()
+	err := log.LoadJSONConfigString(`{"Filters": [{"Enabled": true, "Type": "console", "Level": "INFO"}]}`)
+	if err != nil {
+		t.Error(err)
+	}
+	if err = log.LoadJSONConfigBytes([]byte("{not json")); err == nil {
+		t.Error("expected a parse error")
+	}
+	log.Info("Message to string configured loggers")
+	log.Close()
+}
+
 func TestDefaultLogger(t *testing.T) {
 	console := new(ConsoleWriter)
 	formatter := NewPatFormatter("%DT%T %L %-10x %M")
@@ -64,3 +89,1653 @@ func TestDoubleClose(t *testing.T) {
 	log.Close() // call Close twice	
 	log.Warn("Don't panic")
 }
+
+func TestFlush(t *testing.T) {
+	log := NewTimber()
+	writer, _ := NewFileWriter("test.log")
+	log.AddLogger(ConfigLogger{LogWriter: writer,
+		Level:     DEBUG,
+		Formatter: NewPatFormatter("%M")})
+	log.Info("flush me")
+	log.Flush()
+	contents, _ := os.ReadFile("test.log")
+	if !strings.HasSuffix(string(contents), "flush me\n") {
+		t.Errorf("message not flushed to test.log")
+	}
+	log.Close()
+	log.Flush() // flush after close is a no-op
+}
+
+func TestMemoryWriter(t *testing.T) {
+	mw := NewMemoryWriter(3)
+	for _, msg := range []string{"a", "b", "c", "d"} {
+		mw.LogWrite(msg)
+	}
+	if got := strings.Join(mw.Messages(), ""); got != "bcd" {
+		t.Errorf("expected bcd got %s", got)
+	}
+}
+
+func TestGranularFormatter(t *testing.T) {
+	mw := NewMemoryWriter(10)
+	cLog := ConfigLogger{LogWriter: mw,
+		Level:              INFO,
+		Formatter:          NewPatFormatter("%M"),
+		Granulars:          map[string]Level{"hi": DEBUG},
+		GranularFormatters: map[string]LogFormatter{"hi": NewPatFormatter("%p %M")},
+	}
+	other := *lr
+	other.FuncPath = "bye.Zoot"
+	other.PackagePath = "bye"
+	sendToLoggers([]ConfigLogger{cLog}, lr)
+	sendToLoggers([]ConfigLogger{cLog}, &other)
+	expected := []string{"hi hellooooo nurse!", "hellooooo nurse!"}
+	if got := mw.Messages(); !reflect.DeepEqual(got, expected) {
+		t.Errorf("expected %q got %q", expected, got)
+	}
+}
+
+func TestGranularRaisesLevel(t *testing.T) {
+	log := NewTimber()
+	log.FileDepth = DefaultFileDepth - 1 // called directly, not through the package functions
+	mw := NewMemoryWriter(10)
+	log.AddLogger(ConfigLogger{LogWriter: mw, Level: DEBUG, Formatter: NewPatFormatter("%L %M"),
+		Granulars: map[string]Level{"github.com/smw1218/timber": WARNING}})
+	log.Debug("quieted")
+	log.Info("quieted too")
+	log.Warn("loud enough")
+	log.Close()
+	expected := []string{"WARN loud enough"}
+	if got := mw.Messages(); !reflect.DeepEqual(got, expected) {
+		t.Errorf("expected %q got %q", expected, got)
+	}
+
+	// other packages still get the filter level
+	cLog := ConfigLogger{Level: DEBUG, Granulars: map[string]Level{"hi": ERROR}}
+	other := *lr
+	other.Level = DEBUG
+	other.PackagePath, other.FuncPath = "bye", "bye.Zoot"
+	if _, lvl, ok := cLog.granularFor(lr); !ok || lvl != ERROR {
+		t.Errorf("expected the ERROR granular got %v %v", lvl, ok)
+	}
+	if _, _, ok := cLog.granularFor(&other); ok {
+		t.Error("expected no granular for another package")
+	}
+}
+
+func TestGranularRange(t *testing.T) {
+	mw := NewMemoryWriter(10)
+	cLog := ConfigLogger{LogWriter: mw, Level: WARNING, Formatter: NewPatFormatter("%L"),
+		Granulars: map[string]Level{"hi": DEBUG}, GranularMax: map[string]Level{"hi": INFO}}
+	for _, lvl := range []Level{FINE, DEBUG, INFO, WARNING, ERROR} {
+		rec := *lr
+		rec.Level = lvl
+		sendToLoggers([]ConfigLogger{cLog}, &rec)
+	}
+	if got := strings.Join(mw.Messages(), " "); got != "DEBG INFO" {
+		t.Errorf("expected only DEBUG to INFO got %q", got)
+	}
+
+	config := XMLConfig{Filters: []XMLFilter{{Enabled: true, Type: "null",
+		Granulars: []XMLGranular{{Level: "ERROR", Path: "noisy", MaxLevel: "CRITICAL"}}}}}
+	if errs := ValidateJSONConfig(config.toJSON()); len(errs) != 0 {
+		t.Errorf("unexpected errors %v", errs)
+	}
+	config.Filters[0].Granulars[0].MaxLevel = "LOUD"
+	if errs := ValidateJSONConfig(config.toJSON()); len(errs) != 1 {
+		t.Errorf("expected an error for the bad max level got %v", errs)
+	}
+}
+
+func TestLoggerLevelRange(t *testing.T) {
+	file, pager := NewMemoryWriter(10), NewMemoryWriter(10)
+	loggers := []ConfigLogger{
+		{LogWriter: file, Level: DEBUG, Formatter: NewPatFormatter("%L")},
+		{LogWriter: pager, Level: WARNING, MaxLevel: CRITICAL, Formatter: NewPatFormatter("%L")},
+		{LogWriter: NewMemoryWriter(10), Level: DEBUG, MaxLevel: INFO, Formatter: NewPatFormatter("%L"),
+			Granulars: map[string]Level{"hi": DEBUG}},
+	}
+	for _, lvl := range []Level{DEBUG, INFO, WARNING, ERROR, CRITICAL} {
+		rec := *lr
+		rec.Level = lvl
+		sendToLoggers(loggers, &rec)
+	}
+	if got := strings.Join(file.Messages(), " "); got != "DEBG INFO WARN EROR CRIT" {
+		t.Errorf("expected everything in the file got %q", got)
+	}
+	if got := strings.Join(pager.Messages(), " "); got != "WARN EROR CRIT" {
+		t.Errorf("expected WARNING to CRITICAL in the pager got %q", got)
+	}
+	// a granular without its own max keeps the logger's
+	if got := strings.Join(loggers[2].LogWriter.(*MemoryWriter).Messages(), " "); got != "DEBG INFO" {
+		t.Errorf("expected the logger max for the granular got %q", got)
+	}
+
+	config := XMLConfig{Filters: []XMLFilter{{Enabled: true, Type: "null", Level: "WARNING", MaxLevel: "ERROR"}}}
+	if cl, _, err := buildLogger(nil, config.Filters[0].toJSON()); err != nil || cl.MaxLevel != ERROR {
+		t.Errorf("expected an ERROR max got %v %v", cl.MaxLevel, err)
+	}
+	config.Filters[0].MaxLevel = "LOUD"
+	if errs := ValidateJSONConfig(config.toJSON()); len(errs) != 1 {
+		t.Errorf("expected an error for the bad max level got %v", errs)
+	}
+}
+
+func TestDisable(t *testing.T) {
+	log := NewTimber()
+	mw := NewMemoryWriter(10)
+	log.AddLogger(ConfigLogger{LogWriter: mw, Level: DEBUG, Formatter: NewPatFormatter("%M")})
+	log.Disable()
+	log.Info("muted")
+	log.Flush()
+	if n := len(mw.Messages()); n != 0 {
+		t.Errorf("expected no messages while disabled, got %d", n)
+	}
+	log.Enable()
+	log.Info("back")
+	log.Flush()
+	if got := mw.Messages(); len(got) != 1 || got[0] != "back" {
+		t.Errorf("expected just back, got %q", got)
+	}
+	log.Close()
+}
+
+func TestLevelCounts(t *testing.T) {
+	log := NewTimber()
+	mw := NewMemoryWriter(10)
+	log.AddLogger(ConfigLogger{LogWriter: mw, Level: DEBUG, Formatter: NewPatFormatter("%L %C")})
+	ResetLevelCounts()
+	log.Info("one")
+	log.Warn("two")
+	log.Info("three")
+	log.Flush()
+	ResetLevelCounts()
+	log.Info("four")
+	log.Close()
+	expected := []string{"INFO 1", "WARN 1", "INFO 2", "INFO 1"}
+	if got := mw.Messages(); !reflect.DeepEqual(got, expected) {
+		t.Errorf("expected %q got %q", expected, got)
+	}
+}
+
+func TestMonotonicOrder(t *testing.T) {
+	log := NewTimber()
+	mw := NewMemoryWriter(100)
+	log.AddLogger(ConfigLogger{LogWriter: mw, Level: DEBUG, Formatter: NewPatFormatter("%N")})
+	for i := 0; i < 100; i++ {
+		log.Info("same millisecond")
+	}
+	log.Close()
+	var last int64 = -1
+	for _, msg := range mw.Messages() {
+		var n int64
+		fmt.Sscan(msg, &n)
+		if n <= last {
+			t.Fatalf("%d came after %d", n, last)
+		}
+		last = n
+	}
+}
+
+type testCtxKey string
+
+func TestContextExtractors(t *testing.T) {
+	t.Cleanup(RegisterContextExtractor(func(ctx context.Context) Fields {
+		if id, ok := ctx.Value(testCtxKey("trace")).(string); ok {
+			return Fields{"trace_id": id, "source": "first"}
+		}
+		return nil
+	}))
+	second := RegisterContextExtractor(func(ctx context.Context) Fields {
+		return Fields{"source": "second"}
+	})
+	t.Cleanup(second)
+	log := NewTimber()
+	mw := NewMemoryWriter(10)
+	log.AddLogger(ConfigLogger{LogWriter: mw, Level: DEBUG, Formatter: formatterFunc(func(rec *LogRecord) string {
+		return fmt.Sprintf("%v %v", rec.Fields["trace_id"], rec.Fields["source"])
+	})})
+	ctx := context.WithValue(context.Background(), testCtxKey("trace"), "abc")
+	log.InfoContext(ctx, "traced")
+	second()
+	log.InfoContext(ctx, "traced")
+	log.Close()
+	if got := mw.Messages(); !reflect.DeepEqual(got, []string{"abc second", "abc first"}) {
+		t.Errorf("expected extracted fields, then the first extractor's alone, got %q", got)
+	}
+}
+
+type formatterFunc func(rec *LogRecord) string
+
+func (ff formatterFunc) Format(rec *LogRecord) string {
+	return ff(rec)
+}
+
+func TestContextWithDebug(t *testing.T) {
+	log := NewTimber()
+	mw := NewMemoryWriter(10)
+	log.AddLogger(ConfigLogger{LogWriter: mw, Level: INFO, Formatter: NewPatFormatter("%L %M")})
+	flagged := ContextWithDebug(context.Background())
+	log.DebugContext(context.Background(), "normal request")
+	log.DebugContext(flagged, "flagged request")
+	log.FineContext(flagged, "still too fine")
+	log.Debug("no context")
+	log.InfoContext(context.Background(), "info")
+	log.Close()
+	expected := []string{"DEBG flagged request", "INFO info"}
+	if got := mw.Messages(); !reflect.DeepEqual(got, expected) {
+		t.Errorf("expected %q got %q", expected, got)
+	}
+}
+
+// renders the message and fields separately to show what the record carries
+type fieldsFormatter struct{}
+
+func (fieldsFormatter) Format(rec *LogRecord) string {
+	return fmt.Sprintf("%s %s %v", LevelStrings[rec.Level], rec.Message, rec.Fields)
+}
+
+func TestStructuredAndPrintf(t *testing.T) {
+	log := NewTimber()
+	mw := NewMemoryWriter(10)
+	log.AddLogger(ConfigLogger{LogWriter: mw, Level: INFO, Formatter: fieldsFormatter{}})
+	log.Info("user %s logged in", "bob")
+	log.Infow("user logged in", Fields{"user": "bob", "attempt": 2})
+	log.Infow("100% literal", nil)
+	log.Debugw("too fine", Fields{"x": 1})
+	err := log.Errorw("login failed", Fields{"user": "bob"})
+	log.Close()
+	if err == nil || err.Error() != "login failed" {
+		t.Errorf("unexpected error %v", err)
+	}
+	expected := []string{
+		"INFO user bob logged in map[]",
+		"INFO user logged in map[attempt:2 user:bob]",
+		"INFO 100% literal map[]",
+		"EROR login failed map[user:bob]",
+	}
+	if got := mw.Messages(); !reflect.DeepEqual(got, expected) {
+		t.Errorf("expected %q got %q", expected, got)
+	}
+}
+
+// a writer whose Close hangs until release is closed
+type stuckWriter struct {
+	release chan struct{}
+}
+
+func (w stuckWriter) LogWrite(msg string) {}
+func (w stuckWriter) Close()              { <-w.release }
+
+func TestCloseWithTimeout(t *testing.T) {
+	log := NewTimber()
+	mw := NewMemoryWriter(10)
+	log.AddLogger(ConfigLogger{LogWriter: mw, Level: INFO, Formatter: NewPatFormatter("%M")})
+	log.Info("fine")
+	if err := log.CloseWithTimeout(time.Second); err != nil {
+		t.Errorf("unexpected error %v", err)
+	}
+	if got := mw.Messages(); !reflect.DeepEqual(got, []string{"fine"}) {
+		t.Errorf("unexpected messages %q", got)
+	}
+
+	stuck := stuckWriter{make(chan struct{})}
+	defer close(stuck.release)
+	log = NewTimber()
+	mw = NewMemoryWriter(10)
+	log.AddLogger(ConfigLogger{LogWriter: mw, Level: INFO, Formatter: NewPatFormatter("%M")})
+	log.AddLogger(ConfigLogger{LogWriter: stuck, Level: INFO, Formatter: NewPatFormatter("%M"), Tag: "net"})
+	log.OnClose(func() { t.Error("close hook ran after a timeout") })
+	log.Info("stuck")
+	err := log.CloseWithTimeout(50 * time.Millisecond)
+	if err == nil || !strings.Contains(err.Error(), "timber.stuckWriter (net)") {
+		t.Errorf("expected a timeout naming the stuck writer got %v", err)
+	}
+	if strings.Contains(err.Error(), "MemoryWriter") {
+		t.Errorf("memory writer should have closed: %v", err)
+	}
+	if err := log.CloseWithTimeout(time.Second); err != nil {
+		t.Errorf("expected nil on a second close got %v", err)
+	}
+}
+
+// keeps the fields of each record it's given
+type structuredWriter struct {
+	fields []Fields
+	lines  []string
+}
+
+func (sw *structuredWriter) LogWrite(msg string) { sw.lines = append(sw.lines, msg) }
+func (sw *structuredWriter) Close()              {}
+func (sw *structuredWriter) LogStructured(rec *LogRecord) error {
+	sw.fields = append(sw.fields, rec.Fields)
+	return nil
+}
+
+// counts Format calls
+type countingFormatter struct{ n int }
+
+func (cf *countingFormatter) Format(rec *LogRecord) string {
+	cf.n++
+	return rec.Message
+}
+
+func TestStructuredWriter(t *testing.T) {
+	sw := new(structuredWriter)
+	cf := new(countingFormatter)
+	log := NewTimber()
+	log.AddLogger(ConfigLogger{LogWriter: sw, Level: INFO, Formatter: cf})
+	log.Infow("hi", Fields{"a": 1})
+	log.LogRaw(INFO, []byte(`{"already":"json"}`))
+	log.Close()
+	if cf.n != 0 {
+		t.Errorf("formatter shouldn't be called, was called %d times", cf.n)
+	}
+	if !reflect.DeepEqual(sw.fields, []Fields{{"a": 1}}) {
+		t.Errorf("unexpected fields %v", sw.fields)
+	}
+	if !reflect.DeepEqual(sw.lines, []string{`{"already":"json"}`}) {
+		t.Errorf("unexpected raw lines %q", sw.lines)
+	}
+
+	// through a MultiWriter
+	sw = new(structuredWriter)
+	multi := NewMultiWriter(sw)
+	rec := *lr
+	rec.Fields = Fields{"b": 2}
+	sendToLoggers([]ConfigLogger{{LogWriter: multi, Level: INFO, Formatter: NewPatFormatter("%M")}}, &rec)
+	if !reflect.DeepEqual(sw.fields, []Fields{{"b": 2}}) || len(sw.lines) != 0 {
+		t.Errorf("unexpected fields %v lines %q", sw.fields, sw.lines)
+	}
+}
+
+func TestSyslogSeverity(t *testing.T) {
+	for _, lvl := range []Level{DEBUG, INFO, WARNING, ERROR, CRITICAL} {
+		if got := SeverityLevel(SyslogSeverity(lvl)); got != lvl {
+			t.Errorf("%v round tripped to %v", lvl, got)
+		}
+	}
+	if SyslogSeverity(FINEST) != SeverityDebug || SyslogSeverity(TRACE) != SeverityInformational {
+		t.Error("unexpected severity for FINEST or TRACE")
+	}
+	if SyslogSeverity(CRITICAL+1) != SeverityCritical || SeverityLevel(SeverityAlert) != CRITICAL {
+		t.Error("out of range levels should clamp")
+	}
+	if SeverityLevel(8) != NONE {
+		t.Error("unknown severity should be NONE")
+	}
+}
+
+// counts Flush calls
+type flushWriter struct {
+	MemoryWriter
+	flushes int
+}
+
+func (fw *flushWriter) Flush() { fw.flushes++ }
+
+func TestFlushLogger(t *testing.T) {
+	file, net := new(flushWriter), new(flushWriter)
+	log := NewTimber()
+	log.AddLogger(ConfigLogger{LogWriter: file, Level: INFO, Formatter: NewPatFormatter("%M"), Tag: "file"})
+	log.AddLogger(ConfigLogger{LogWriter: net, Level: INFO, Formatter: NewPatFormatter("%M"), Tag: "net"})
+	if err := log.FlushLogger("file"); err != nil {
+		t.Errorf("unexpected error %v", err)
+	}
+	if file.flushes != 1 || net.flushes != 0 {
+		t.Errorf("expected only the file to flush got %d and %d", file.flushes, net.flushes)
+	}
+	if err := log.Sync(); err != nil || file.flushes != 2 || net.flushes != 1 {
+		t.Errorf("expected Sync to flush everything got %v, %d and %d", err, file.flushes, net.flushes)
+	}
+	if err := log.FlushLogger("nope"); err == nil {
+		t.Error("expected an error for an unknown tag")
+	}
+	log.Close()
+	if err := log.FlushLogger("file"); err != nil {
+		t.Errorf("expected nil once closed got %v", err)
+	}
+}
+
+func TestRemoveLogger(t *testing.T) {
+	debug, info := NewMemoryWriter(10), NewMemoryWriter(10)
+	log := NewTimber()
+	log.AddLogger(ConfigLogger{LogWriter: debug, Level: DEBUG, Formatter: NewPatFormatter("%M"), Tag: "debug"})
+	log.AddLogger(ConfigLogger{LogWriter: info, Level: INFO, Formatter: NewPatFormatter("%M"), Tag: "info"})
+	log.Debug("queued")
+	if err := log.RemoveLogger("debug"); err != nil {
+		t.Fatal(err)
+	}
+	if log.DebugEnabled() {
+		t.Error("expected DEBUG off with its logger gone")
+	}
+	log.Info("after")
+	if err := log.RemoveLogger("debug"); err == nil {
+		t.Error("expected an error for a tag that's gone")
+	}
+	if levels := log.Levels(); len(levels) != 1 || levels[0].Tag != "info" {
+		t.Errorf("expected info to move down to 0 got %+v", levels)
+	}
+	log.Close()
+	if got := debug.Messages(); !reflect.DeepEqual(got, []string{"queued"}) {
+		t.Errorf("expected the removed logger to get what was queued, got %q", got)
+	}
+	if got := info.Messages(); !reflect.DeepEqual(got, []string{"after"}) {
+		t.Errorf("expected after got %q", got)
+	}
+}
+
+func TestLoadGranulars(t *testing.T) {
+	log := NewTimber()
+	log.FileDepth = DefaultFileDepth - 1 // called on log directly, not through Global
+	mw := NewMemoryWriter(10)
+	log.AddLogger(ConfigLogger{LogWriter: mw, Level: INFO, Formatter: NewPatFormatter("%L %M"),
+		Granulars: map[string]Level{"other/pkg": WARNING}})
+	log.Debug("before")
+	err := log.LoadGranularsReader(strings.NewReader(`{"github.com/smw1218/timber": "debug"}`))
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	log.Debug("after")
+	log.Fine("too fine")
+	if err := log.LoadGranularsReader(strings.NewReader(`{"x": "LOUD"}`)); err == nil {
+		t.Error("expected an error for an unknown level")
+	}
+	if err := log.LoadGranulars("no-such-file.json"); err == nil {
+		t.Error("expected an error for a missing file")
+	}
+	log.Close()
+	if got := mw.Messages(); !reflect.DeepEqual(got, []string{"DEBG after"}) {
+		t.Errorf("unexpected messages %q", got)
+	}
+	if err := log.LoadGranularsReader(strings.NewReader(`{}`)); err == nil {
+		t.Error("expected an error once closed")
+	}
+}
+
+func TestLoggerTagDirective(t *testing.T) {
+	mw := NewMemoryWriter(10)
+	log := NewTimber()
+	log.AddLogger(ConfigLogger{LogWriter: mw, Level: INFO, Formatter: NewPatFormatter("[%G] %M"), Tag: "app"})
+	log.AddLogger(ConfigLogger{LogWriter: mw, Level: INFO, Formatter: NewPatFormatter("[%G] %M"), Tag: "audit"})
+	log.Info("hi")
+	log.Close()
+	if got := mw.Messages(); !reflect.DeepEqual(got, []string{"[app] hi", "[audit] hi"}) {
+		t.Errorf("unexpected messages %q", got)
+	}
+	rec := &LogRecord{Level: INFO, Message: "shared"}
+	var first, second *LogRecord
+	sendToLoggers([]ConfigLogger{
+		{LogWriter: &sourceBlind{rec: &first}, Level: INFO, Formatter: NewPatFormatter("%M"), Tag: "app"},
+		{LogWriter: &sourceBlind{rec: &second}, Level: INFO, Formatter: NewPatFormatter("%M"), Tag: "audit"},
+	}, rec)
+	if rec.LoggerTag != "" || first.LoggerTag != "app" || second.LoggerTag != "audit" {
+		t.Errorf("expected a tagged copy per logger, got %q, %q and %q", rec.LoggerTag, first.LoggerTag, second.LoggerTag)
+	}
+}
+
+func TestDisableSourceCapture(t *testing.T) {
+	DisableSourceCapture()
+	defer EnableSourceCapture()
+	mw := NewMemoryWriter(10)
+	log := NewTimber()
+	log.AddLogger(ConfigLogger{LogWriter: mw, Level: INFO, Formatter: NewPatFormatter("%S %s %x %p %M")})
+	log.Info("hi")
+	log.Close()
+	if got := mw.Messages(); !reflect.DeepEqual(got, []string{"??? ??? ??? _ hi"}) {
+		t.Errorf("unexpected messages %q", got)
+	}
+}
+
+func TestSamplerKeepsErrors(t *testing.T) {
+	mw := NewMemoryWriter(20)
+	sampler := NewSampler(3)
+	cLog := ConfigLogger{LogWriter: mw, Level: DEBUG, Formatter: NewPatFormatter("%L %M"), Sampler: sampler}
+	for i := 0; i < 6; i++ {
+		for _, lvl := range []Level{INFO, ERROR} {
+			rec := *lr
+			rec.Level = lvl
+			rec.Message = strconv.Itoa(i)
+			sendToLoggers([]ConfigLogger{cLog}, &rec)
+		}
+	}
+	expected := []string{"INFO 0", "EROR 0", "EROR 1", "EROR 2", "INFO 3", "EROR 3", "EROR 4", "EROR 5"}
+	if got := mw.Messages(); !reflect.DeepEqual(got, expected) {
+		t.Errorf("expected %q got %q", expected, got)
+	}
+	if sampler.Dropped() != 4 {
+		t.Errorf("expected 4 dropped got %d", sampler.Dropped())
+	}
+
+	filter := JSONFilter{Properties: []JSONProperty{{"sample", "10"}, {"sample_keep_above", "WARNING"}}}
+	if s, err := samplerFromFilter(filter); err != nil || s.N != 10 || s.KeepAbove != WARNING {
+		t.Errorf("unexpected sampler %+v, %v", s, err)
+	}
+	filter.Properties[0].Value = "0"
+	if _, err := samplerFromFilter(filter); err == nil {
+		t.Error("expected an error for sample 0")
+	}
+}
+
+func TestDuplicateTag(t *testing.T) {
+	first, second := NewMemoryWriter(10), NewMemoryWriter(10)
+	log := NewTimber()
+	pf := NewPatFormatter("%M")
+	idx := log.AddLogger(ConfigLogger{LogWriter: first, Level: INFO, Formatter: pf, Tag: "app"})
+	log.Info("one")
+	if got := log.AddLogger(ConfigLogger{LogWriter: second, Level: INFO, Formatter: pf, Tag: "app"}); got != idx {
+		t.Errorf("expected the replacement at %d got %d", idx, got)
+	}
+	log.Info("two")
+	log.Close()
+	if got := first.Messages(); !reflect.DeepEqual(got, []string{"one"}) {
+		t.Errorf("unexpected messages for the first logger %q", got)
+	}
+	if got := second.Messages(); !reflect.DeepEqual(got, []string{"two"}) {
+		t.Errorf("unexpected messages for the second logger %q", got)
+	}
+
+	StrictConfig(true)
+	defer StrictConfig(false)
+	mw := NewMemoryWriter(10)
+	log = NewTimber()
+	log.AddLogger(ConfigLogger{LogWriter: mw, Level: INFO, Formatter: pf, Tag: "app"})
+	if got := log.AddLogger(ConfigLogger{LogWriter: mw, Level: INFO, Formatter: pf, Tag: "app"}); got != -1 {
+		t.Errorf("expected a strict duplicate to be rejected got %d", got)
+	}
+	log.Info("once")
+	log.Close()
+	if got := mw.Messages(); !reflect.DeepEqual(got, []string{"once"}) {
+		t.Errorf("expected no duplicate lines got %q", got)
+	}
+	config := JSONConfig{Filters: []JSONFilter{
+		{Enabled: true, Tag: "app", Type: "null"}, {Enabled: true, Tag: "app", Type: "null"}}}
+	if errs := ValidateJSONConfig(config); len(errs) != 1 {
+		t.Errorf("expected one duplicate tag error got %v", errs)
+	}
+}
+
+func TestFieldFormatters(t *testing.T) {
+	mw := NewMemoryWriter(10)
+	log := NewTimber()
+	log.AddLogger(ConfigLogger{LogWriter: mw, Level: INFO, Formatter: NewPatFormatter("%L %M"),
+		FieldFormatters: map[string]LogFormatter{"json": NewJSONFormatter()}})
+	log.Info("plain")
+	log.Infow("structured", Fields{FormatField: "json", "user": "bob"})
+	log.Infow("unknown", Fields{FormatField: "xml"})
+	log.Close()
+	got := mw.Messages()
+	if len(got) != 3 || got[0] != "INFO plain" || got[2] != "INFO unknown" {
+		t.Fatalf("unexpected messages %q", got)
+	}
+	if !strings.HasPrefix(got[1], "{") || !strings.Contains(got[1], `"user":"bob"`) ||
+		strings.Contains(got[1], FormatField) {
+		t.Errorf("expected json without the format field got %s", got[1])
+	}
+
+	filter := JSONFilter{Enabled: true, Type: "null", Properties: []JSONProperty{{"record_formatter", "json"}}}
+	log = NewTimber()
+	if err := log.loadFilters(JSONConfig{Filters: []JSONFilter{filter}}); err != nil {
+		t.Errorf("unexpected error %v", err)
+	}
+	log.Close()
+	filter.Properties[0].Value = "nope"
+	log = NewTimber()
+	if err := log.loadFilters(JSONConfig{Filters: []JSONFilter{filter}}); err == nil {
+		t.Error("expected an error for an unknown formatter")
+	}
+	log.Close()
+}
+
+func TestLogConfigSummary(t *testing.T) {
+	dir := t.TempDir()
+	fw, err := NewFileWriter(dir + "/app.log")
+	if err != nil {
+		t.Fatal(err)
+	}
+	mw := NewMemoryWriter(10)
+	log := NewTimber()
+	log.AddLogger(ConfigLogger{LogWriter: mw, Level: INFO, Formatter: NewPatFormatter("%M"), Tag: "mem"})
+	log.AddLogger(ConfigLogger{LogWriter: fw, Level: WARNING, Formatter: NewPatFormatter("%M"),
+		Granulars: map[string]Level{"a": DEBUG}})
+	log.AddLogger(ConfigLogger{LogWriter: NewMultiWriter(ConsoleWriter{Stream: os.Stdout}, NullWriter{}),
+		Level: ERROR, Formatter: NewPatFormatter("%M")})
+	log.LogConfigSummary()
+	log.Close()
+	expected := []string{
+		`timber: logger 0 "mem" at INFO to *timber.MemoryWriter`,
+		"timber: logger 1 at WARNING to file " + dir + "/app.log, 1 granulars",
+		"timber: logger 2 at ERROR to [console stdout, nowhere]",
+	}
+	if got := mw.Messages(); !reflect.DeepEqual(got, expected) {
+		t.Errorf("expected %q got %q", expected, got)
+	}
+}
+
+func TestCompressedConfig(t *testing.T) {
+	config := `{"Filters": [{"Enabled": true, "Tag": "mem", "Type": "null", "Level": "INFO"}]}`
+	var zipped bytes.Buffer
+	zw := gzip.NewWriter(&zipped)
+	zw.Write([]byte(config))
+	zw.Close()
+	inputs := map[string][]byte{
+		"plain":       []byte(config),
+		"gzip":        zipped.Bytes(),
+		"base64":      []byte(base64.StdEncoding.EncodeToString([]byte(config))),
+		"base64 gzip": []byte(base64.StdEncoding.EncodeToString(zipped.Bytes()) + "\n"),
+	}
+	for name, input := range inputs {
+		log := NewTimber()
+		if err := log.LoadJSONConfigBytes(input); err != nil {
+			t.Errorf("%s: unexpected error %v", name, err)
+		} else if err := log.FlushLogger("mem"); err != nil {
+			t.Errorf("%s: logger wasn't loaded: %v", name, err)
+		}
+		log.Close()
+	}
+	log := NewTimber()
+	if err := log.LoadJSONConfigBytes(append([]byte{}, zipped.Bytes()[:10]...)); err == nil {
+		t.Error("expected an error for truncated gzip")
+	}
+	log.Close()
+}
+
+func TestDelta(t *testing.T) {
+	for d, expected := range map[time.Duration]string{
+		0: "+0s", 1500: "+1.5µs", 2*time.Second + 340*time.Millisecond: "+2.3s"} {
+		if got := formatDelta(d); got != expected {
+			t.Errorf("expected %s got %s", expected, got)
+		}
+	}
+
+	now := time.Unix(1000, 0)
+	SetDeltaScope(DeltaGoroutine)
+	defer SetDeltaScope(DeltaGlobal)
+	if d := nextDelta(now); d != 0 {
+		t.Errorf("expected 0 for this goroutine's first record got %v", d)
+	}
+	if d := nextDelta(now.Add(time.Millisecond)); d != time.Millisecond {
+		t.Errorf("expected 1ms got %v", d)
+	}
+	var other time.Duration
+	var otherID uint64
+	done := make(chan bool)
+	go func() {
+		defer close(done)
+		other = nextDelta(now.Add(time.Second))
+		otherID = goroutineID()
+	}()
+	<-done
+	if other != 0 {
+		t.Errorf("expected a new goroutine to start at 0 got %v", other)
+	}
+
+	// the goroutine that exited is swept, this one is kept
+	for deadline := time.Now().Add(time.Second); liveGoroutines()[otherID] && time.Now().Before(deadline); {
+		time.Sleep(5 * time.Millisecond)
+	}
+	sweepDeltas()
+	if _, ok := deltaByGoroutine.Load(otherID); ok {
+		t.Errorf("expected goroutine %d swept", otherID)
+	}
+	if d := nextDelta(now.Add(2 * time.Millisecond)); d != time.Millisecond {
+		t.Errorf("expected this goroutine kept with 1ms got %v", d)
+	}
+	if nextGoroutineSweep(0) != minGoroutineSweep || nextGoroutineSweep(5000) != 10000 {
+		t.Errorf("expected sweeps at double the size left")
+	}
+}
+
+func TestOnClose(t *testing.T) {
+	sw := &stallWriter{release: make(chan bool), mw: NewMemoryWriter(10)}
+	close(sw.release)
+	aw := NewAsyncWriter(sw, 4, OverflowBlock)
+	log := NewTimber()
+	log.AddLogger(ConfigLogger{LogWriter: aw, Level: INFO, Formatter: NewPatFormatter("%M")})
+	var order []string
+	log.OnClose(func() { order = append(order, fmt.Sprint("first ", sw.mw.Messages())) })
+	log.OnClose(func() { order = append(order, "second") })
+	log.Info("written")
+	log.Close()
+	log.Close()
+	if expected := []string{"first [written]", "second"}; !reflect.DeepEqual(order, expected) {
+		t.Errorf("expected %q got %q", expected, order)
+	}
+}
+
+func TestWithFields(t *testing.T) {
+	mw := NewMemoryWriter(10)
+	log := NewTimber()
+	log.AddLogger(ConfigLogger{LogWriter: mw, Level: INFO, Formatter: fieldsFormatter{}})
+	input := Fields{"user_id": 42}
+	fl := log.WithFields(input)
+	input["user_id"] = 0
+	fl.Info("login %s", "ok")
+	fl.WithFields(Fields{"request": "abc"}).Warn("slow")
+	fl.Debug("too fine")
+	log.Close()
+	expected := []string{"INFO login ok map[user_id:42]", "WARN slow map[request:abc user_id:42]"}
+	if got := mw.Messages(); !reflect.DeepEqual(got, expected) {
+		t.Errorf("expected %q got %q", expected, got)
+	}
+
+	mw = NewMemoryWriter(10)
+	log = NewTimber()
+	log.AddLogger(ConfigLogger{LogWriter: mw, Level: INFO, Formatter: NewPatFormatter("%x")})
+	log.WithFields(nil).Info("where")
+	log.Close()
+	if got := mw.Messages(); !reflect.DeepEqual(got, []string{"timber_test"}) {
+		t.Errorf("expected the caller's file got %q", got)
+	}
+}
+
+func TestNewContext(t *testing.T) {
+	mw := NewMemoryWriter(10)
+	log := NewTimber()
+	// only these keys, the extractors other tests register add more
+	log.AddLogger(ConfigLogger{LogWriter: mw, Level: INFO, Formatter: formatterFunc(func(rec *LogRecord) string {
+		return fmt.Sprintf("%s %v %v", rec.Message, rec.Fields["trace_id"], rec.Fields["user_id"])
+	})})
+	input := Fields{"trace_id": "abc"}
+	ctx := NewContext(context.Background(), input)
+	input["trace_id"] = "changed"
+	ctx = NewContext(ctx, Fields{"user_id": 42})
+	log.InfoContext(ctx, "context")
+	log.FromContext(ctx).Info("child")
+	log.FromContext(NewContext(ctx, Fields{"user_id": 7})).Info("override")
+	log.FromContext(context.Background()).Info("none")
+	log.Close()
+	expected := []string{"context abc 42", "child abc 42", "override abc 7", "none <nil> <nil>"}
+	if got := mw.Messages(); !reflect.DeepEqual(got, expected) {
+		t.Errorf("expected %q got %q", expected, got)
+	}
+}
+
+func TestMultiWriterLevels(t *testing.T) {
+	all, warn := NewMemoryWriter(10), NewMemoryWriter(10)
+	multi := NewMultiWriter(all)
+	multi.AddWriter(warn, WARNING)
+	cLog := ConfigLogger{LogWriter: multi, Level: DEBUG, Formatter: NewPatFormatter("%L")}
+	for _, lvl := range []Level{FINE, INFO, WARNING, ERROR} {
+		rec := *lr
+		rec.Level = lvl
+		sendToLoggers([]ConfigLogger{cLog}, &rec)
+	}
+	if got := strings.Join(all.Messages(), " "); got != "INFO WARN EROR" {
+		t.Errorf("unexpected messages for all: %q", got)
+	}
+	if got := strings.Join(warn.Messages(), " "); got != "WARN EROR" {
+		t.Errorf("unexpected messages for warn: %q", got)
+	}
+}
+
+func TestConfigDefaults(t *testing.T) {
+	defaults := &JSONDefaults{Level: "WARNING", Format: JSONProperty{"pattern", "%L %M"},
+		Granulars: []JSONGranular{{Level: "DEBUG", Path: "hi"}}}
+	inherited := defaults.applyTo(JSONFilter{})
+	if inherited.Level != "WARNING" || inherited.Format.Value != "%L %M" || len(inherited.Granulars) != 1 {
+		t.Errorf("defaults not inherited: %+v", inherited)
+	}
+	own := defaults.applyTo(JSONFilter{Level: "ERROR", Properties: []JSONProperty{{"format", "%M"}}})
+	if own.Level != "ERROR" || own.Format.Value != "" {
+		t.Errorf("filter values should win: %+v", own)
+	}
+}
+
+func TestConfigBadMask(t *testing.T) {
+	log := NewTimber()
+	defer log.Close()
+	err := log.loadFilters(JSONConfig{Filters: []JSONFilter{{Enabled: true, Type: "console",
+		Properties: []JSONProperty{{"mask", "no arrow"}}}}})
+	if err == nil {
+		t.Error("expected an error for a mask without =>")
+	}
+}
+
+func TestSetDefaultFormat(t *testing.T) {
+	rec := *lr
+	formatter, _ := getJSONFormatter(JSONFilter{})
+	if got := formatter.Format(&rec); got != "hellooooo nurse!" {
+		t.Errorf("expected message only, got %q", got)
+	}
+	SetDefaultFormat("%L %M")
+	defer SetDefaultFormat("")
+	formatter, _ = getJSONFormatter(JSONFilter{})
+	if got := formatter.Format(&rec); got != "INFO hellooooo nurse!" {
+		t.Errorf("expected the default format, got %q", got)
+	}
+	own := JSONFilter{Properties: []JSONProperty{{"format", "%p"}}}
+	formatter, _ = getJSONFormatter(own)
+	if got := formatter.Format(&rec); got != "hi" {
+		t.Errorf("expected the filter's own format, got %q", got)
+	}
+}
+
+func TestParseLevel(t *testing.T) {
+	for in, expected := range map[string]Level{"finest": FINEST, "Fine": FINE, "DEBUG": DEBUG, "warning": WARNING} {
+		if lvl, err := ParseLevel(in); err != nil || lvl != expected {
+			t.Errorf("%s: expected %v got %v %v", in, expected, lvl, err)
+		}
+	}
+	if !(FINEST < FINE && FINE < DEBUG) {
+		t.Error("verbose levels out of order")
+	}
+	if lvl, err := ParseLevel(" Debug "); err != nil || lvl != DEBUG {
+		t.Errorf("expected DEBUG with whitespace trimmed, got %v %v", lvl, err)
+	}
+	if lvl, err := ParseLevel("loud"); err == nil || lvl != NONE {
+		t.Errorf("expected an error and NONE, got %v %v", lvl, err)
+	}
+}
+
+func TestValidateJSONConfig(t *testing.T) {
+	config := JSONConfig{
+		Defaults: &JSONDefaults{Level: " info "},
+		Filters: []JSONFilter{
+			{Enabled: true, Type: "console", Level: "ERROR", Granulars: []JSONGranular{{Level: "Debug ", Path: "hi"}}},
+			{Enabled: true, Type: "carrier-pigeon", Level: "LOUD"},
+			{Type: "disabled", Level: "LOUD"},
+		},
+	}
+	errs := ValidateJSONConfig(config)
+	if len(errs) != 2 {
+		t.Errorf("expected the bad type and level, got %v", errs)
+	}
+	config.Filters = config.Filters[:1]
+	if errs = ValidateJSONConfig(config); errs != nil {
+		t.Errorf("expected no errors, got %v", errs)
+	}
+}
+
+func TestStrictConfig(t *testing.T) {
+	config := `{"Filters": [{"Enabled": true, "Type": "carrier-pigeon", "Level": "INFO"}]}`
+	log := NewTimber()
+	defer log.Close()
+	if err := log.LoadJSONConfigString(config); err != nil {
+		t.Errorf("lenient config should skip the filter, got %v", err)
+	}
+	StrictConfig(true)
+	defer StrictConfig(false)
+	if err := log.LoadJSONConfigString(config); err == nil {
+		t.Error("expected an error for an unknown filter type")
+	}
+}
+
+func TestConsoleStream(t *testing.T) {
+	var buf strings.Builder
+	cw := ConsoleWriter{Stream: &buf}
+	cw.LogWrite("to the buffer")
+	if buf.String() != "to the buffer\n" {
+		t.Errorf("expected the message in the stream, got %q", buf.String())
+	}
+	w, err := getConsoleWriter(JSONFilter{Properties: []JSONProperty{{"stream", "stdout"}}})
+	if err != nil || w.(*ConsoleWriter).Stream != os.Stdout {
+		t.Errorf("expected a stdout writer, got %v %v", w, err)
+	}
+	if _, err = getConsoleWriter(JSONFilter{Properties: []JSONProperty{{"stream", "printer"}}}); err == nil {
+		t.Error("expected an error for an unknown stream")
+	}
+}
+
+func TestSplitConsole(t *testing.T) {
+	var out, errs strings.Builder
+	log := NewTimber()
+	log.AddLogger(ConfigLogger{LogWriter: &SplitConsoleWriter{Out: &out, Err: &errs}, Level: DEBUG,
+		Formatter: NewPatFormatter("%L %M")})
+	log.Debug("debug")
+	log.Warn("warn")
+	log.Error("error")
+	log.Critical("critical")
+	log.Close()
+	if out.String() != "DEBG debug\nWARN warn\n" {
+		t.Errorf("unexpected stdout %q", out.String())
+	}
+	if errs.String() != "EROR error\nCRIT critical\n" {
+		t.Errorf("unexpected stderr %q", errs.String())
+	}
+	w, err := getConsoleWriter(JSONFilter{Properties: []JSONProperty{{"stream", "split"}, {"split_level", "warning"}}})
+	if err != nil || w.(*SplitConsoleWriter).Threshold != WARNING || w.(*SplitConsoleWriter).Out != os.Stdout {
+		t.Errorf("expected a split writer from WARNING, got %v %v", w, err)
+	}
+	if _, err = getConsoleWriter(JSONFilter{Properties: []JSONProperty{{"stream", "split"}, {"split_level", "loud"}}}); err == nil {
+		t.Error("expected an error for a bad split_level")
+	}
+}
+
+func TestBatchConfig(t *testing.T) {
+	defaults := BatchConfig{Count: 500, Bytes: 1 << 20, Interval: time.Second}
+	filter := JSONFilter{Properties: []JSONProperty{{"batch_count", "10"}, {"flush_interval", "250ms"}}}
+	bc, err := filter.BatchConfig(defaults)
+	if err != nil || bc != (BatchConfig{Count: 10, Bytes: 1 << 20, Interval: 250 * time.Millisecond}) {
+		t.Errorf("unexpected batch config %+v %v", bc, err)
+	}
+	if !bc.Fits(10, 100) || bc.Fits(11, 100) || bc.Fits(1, 2<<20) {
+		t.Error("Fits should respect both the count and the bytes")
+	}
+	if !(BatchConfig{}).Fits(1e6, 1e9) {
+		t.Error("zero limits should always fit")
+	}
+	bad := JSONFilter{Properties: []JSONProperty{{"batch_bytes", "huge"}}}
+	if _, err = bad.BatchConfig(defaults); err == nil {
+		t.Error("expected an error for bad batch_bytes")
+	}
+	for _, count := range []string{"1KB", "0", "-5"} {
+		bad = JSONFilter{Properties: []JSONProperty{{"batch_count", count}}}
+		if _, err = bad.BatchConfig(defaults); err == nil {
+			t.Errorf("expected an error for batch_count %q", count)
+		}
+	}
+}
+
+func TestConcurrentReconfigure(t *testing.T) {
+	log := NewTimber()
+	mw := NewMemoryWriter(100)
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 200; j++ {
+				log.Info("busy %d", j)
+			}
+		}()
+	}
+	for i := 0; i < 4; i++ {
+		log.AddLogger(ConfigLogger{LogWriter: mw, Level: INFO, Formatter: NewPatFormatter("%M")})
+		log.Flush()
+	}
+	// logging that races Close must not panic
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for j := 0; j < 200; j++ {
+			log.Info("closing %d", j)
+		}
+	}()
+	log.Close()
+	wg.Wait()
+	if idx := log.AddLogger(ConfigLogger{LogWriter: mw}); idx != -1 {
+		t.Errorf("expected -1 adding to a closed logger, got %d", idx)
+	}
+	log.Flush()
+	log.Info("after close")
+}
+
+func TestSetTimeFunc(t *testing.T) {
+	frozen := time.Date(2012, 3, 4, 5, 6, 7, 0, time.Local)
+	SetTimeFunc(func() time.Time { return frozen })
+	defer SetTimeFunc(nil)
+	log := NewTimber()
+	mw := NewMemoryWriter(10)
+	log.AddLogger(ConfigLogger{LogWriter: mw, Level: INFO, Formatter: NewPatFormatter("%D %t %M")})
+	log.Info("frozen")
+	log.Close()
+	if got := mw.Messages(); len(got) != 1 || got[0] != "2012-03-04 05:06:07 frozen" {
+		t.Errorf("expected the frozen time, got %q", got)
+	}
+}
+
+func TestRegisterWriterFactory(t *testing.T) {
+	mw := NewMemoryWriter(10)
+	RegisterWriterFactory("test-memory", func(filter JSONFilter) (LogWriter, error) {
+		return mw, nil
+	})
+	log := NewTimber()
+	err := log.LoadJSONConfigString(`{"Filters": [{"Enabled": true, "Type": "test-memory", "Level": "INFO"}]}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	log.Info("registered")
+	log.Close()
+	if got := mw.Messages(); len(got) != 1 || got[0] != "registered" {
+		t.Errorf("expected the message in the registered writer, got %q", got)
+	}
+	if errs := ValidateJSONConfig(JSONConfig{Filters: []JSONFilter{{Enabled: true, Type: "test-memory"}}}); errs != nil {
+		t.Errorf("registered types should validate, got %v", errs)
+	}
+}
+
+func TestRegisterFormatterFactory(t *testing.T) {
+	RegisterFormatterFactory("test-audit", func(filter JSONFilter) (LogFormatter, error) {
+		prefix := filter.property("audit_prefix")
+		return formatterFunc(func(rec *LogRecord) string { return prefix + rec.Message }), nil
+	})
+	filter := JSONFilter{Properties: []JSONProperty{{"formatter", "test-audit"}, {"audit_prefix", "AUDIT "}}}
+	formatter, err := getJSONFormatter(filter)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := formatter.Format(lr); got != "AUDIT hellooooo nurse!" {
+		t.Errorf("expected the registered formatter, got %q", got)
+	}
+	unknown := JSONFilter{Properties: []JSONProperty{{"formatter", "nope"}}}
+	if _, err = getJSONFormatter(unknown); err == nil {
+		t.Error("expected an error for an unregistered formatter")
+	}
+}
+
+func TestJSONFormatName(t *testing.T) {
+	formatter, err := getJSONFormatter(JSONFilter{Format: JSONProperty{Name: "json"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := formatter.(*JSONFormatter); !ok {
+		t.Errorf("expected a JSONFormatter, got %T", formatter)
+	}
+	if formatter, _ = getJSONFormatter(JSONFilter{Format: JSONProperty{Name: "pattern", Value: "%L"}}); formatter.Format(lr) != "INFO" {
+		t.Errorf("expected the pattern formatter, got %T", formatter)
+	}
+	if _, err = getJSONFormatter(JSONFilter{Format: JSONProperty{Name: "nope"}}); err == nil {
+		t.Error("expected an error for an unknown format name")
+	}
+
+	config := XMLConfig{}
+	if err = xml.Unmarshal([]byte(`<logging><filter enabled="true"><format name="json"/></filter></logging>`), &config); err != nil {
+		t.Fatal(err)
+	}
+	if formatter, err = getJSONFormatter(config.toJSON().Filters[0]); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := formatter.(*JSONFormatter); !ok {
+		t.Errorf("expected the xml format to pick a JSONFormatter, got %T", formatter)
+	}
+}
+
+func TestWatchConfig(t *testing.T) {
+	dir := t.TempDir()
+	name, logName := dir+"/watch.json", dir+"/watch.log"
+	writeConfig := func(level string) {
+		config := `{"Filters": [{"Enabled": true, "Type": "file", "Level": "` + level + `", "Properties": [` +
+			`{"Name": "filename", "Value": "` + logName + `"}, {"Name": "flush_interval", "Value": "0"}]}]}`
+		if err := os.WriteFile(name, []byte(config), 0666); err != nil {
+			t.Fatal(err)
+		}
+	}
+	writeConfig("INFO")
+	log := NewTimber()
+	log.FileDepth = DefaultFileDepth - 1
+	if err := log.LoadJSONConfig(name); err != nil {
+		t.Fatal(err)
+	}
+	if err := log.WatchConfigInterval(name, 5*time.Millisecond); err != nil {
+		t.Fatal(err)
+	}
+	log.Info("before")
+	log.Debug("not yet")
+	writeConfig("DEBUG")
+	contents := ""
+	for deadline := time.Now().Add(2 * time.Second); time.Now().Before(deadline); time.Sleep(5 * time.Millisecond) {
+		log.Debug("after")
+		log.Flush()
+		b, _ := os.ReadFile(logName)
+		if contents = string(b); strings.Contains(contents, "after") {
+			break
+		}
+	}
+	if !strings.HasPrefix(contents, "before\nafter\n") {
+		t.Errorf("expected the reloaded DEBUG level to take effect, got %q", contents)
+	}
+
+	os.WriteFile(name, []byte("{not json"), 0666)
+	if err := log.ReloadConfig(name); err == nil {
+		t.Error("expected an error for a bad config")
+	}
+	log.Info("still here")
+	log.Flush()
+	if b, _ := os.ReadFile(logName); !strings.HasSuffix(string(b), "still here\n") {
+		t.Errorf("expected a bad config to keep the current loggers, got %q", b)
+	}
+	log.Close()
+	writeConfig("INFO")
+	if err := log.ReloadConfig(name); err == nil {
+		t.Error("expected an error reloading a closed logger")
+	}
+}
+
+func TestCloseWritesBufferedFile(t *testing.T) {
+	name := t.TempDir() + "/closed.log"
+	writer, err := NewFileWriter(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	log := NewTimber()
+	log.AddLogger(ConfigLogger{LogWriter: writer, Level: INFO, Formatter: NewPatFormatter("%M")})
+	log.Info("right before exit")
+	log.Close()
+	if contents, _ := os.ReadFile(name); string(contents) != "right before exit\n" {
+		t.Errorf("expected Close to write out the buffer, got %q", contents)
+	}
+	// closing again or flushing a closed writer doesn't hang
+	writer.Close()
+	writer.(*BufferedWriter).Flush()
+}
+
+func TestFileFlushEveryWrite(t *testing.T) {
+	name := t.TempDir() + "/every.log"
+	writer, err := getJSONFileWriter(JSONFilter{Properties: []JSONProperty{{"filename", name}, {"flush_interval", "0"}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	writer.LogWrite("straight to disk")
+	var contents []byte
+	for deadline := time.Now().Add(500 * time.Millisecond); time.Now().Before(deadline); time.Sleep(time.Millisecond) {
+		if contents, _ = os.ReadFile(name); len(contents) > 0 {
+			break
+		}
+	}
+	if string(contents) != "straight to disk\n" {
+		t.Errorf("expected the message on disk, got %q", contents)
+	}
+	writer.Close()
+}
+
+func TestWriteStats(t *testing.T) {
+	log := NewTimber()
+	log.AddLogger(ConfigLogger{LogWriter: NewMemoryWriter(10), Level: INFO, Formatter: NewPatFormatter("%M")})
+	log.AddLogger(ConfigLogger{LogWriter: NewMemoryWriter(10), Level: INFO, Formatter: NewPatFormatter("%M"),
+		TimeWrites: true})
+	log.Info("timed")
+	log.Info("timed again")
+	log.Debug("filtered out")
+	log.Flush()
+	stats := log.WriteStats()
+	if len(stats) != 2 || stats[0].Count != 0 || stats[1].Count != 2 {
+		t.Fatalf("unexpected stats %+v", stats)
+	}
+	if stats[1].Min > stats[1].Max || stats[1].Avg() > stats[1].Max || stats[1].Total < stats[1].Max {
+		t.Errorf("inconsistent stats %+v", stats[1])
+	}
+	log.Close()
+	if log.WriteStats() != nil {
+		t.Error("expected nil stats after close")
+	}
+}
+
+func TestLogRaw(t *testing.T) {
+	log := NewTimber()
+	mw := NewMemoryWriter(10)
+	log.AddLogger(ConfigLogger{LogWriter: mw, Level: INFO, Formatter: NewPatFormatter("[%L] %M")})
+	log.LogRaw(INFO, []byte(`{"already":"json"}`))
+	log.LogRaw(DEBUG, []byte("filtered"))
+	log.Info("formatted")
+	log.Close()
+	expected := []string{`{"already":"json"}`, "[INFO] formatted"}
+	if got := mw.Messages(); !reflect.DeepEqual(got, expected) {
+		t.Errorf("expected %q got %q", expected, got)
+	}
+}
+
+func TestPrefixSuffix(t *testing.T) {
+	log := NewTimber()
+	mw := NewMemoryWriter(10)
+	log.AddLogger(ConfigLogger{LogWriter: mw, Level: INFO, Formatter: NewPatFormatter("[%L] %M"),
+		Prefix: "[svc-auth] ", Suffix: " <<"})
+	log.Info("formatted")
+	log.LogRaw(INFO, []byte("raw"))
+	log.Close()
+	expected := []string{"[svc-auth] [INFO] formatted <<", "raw"}
+	if got := mw.Messages(); !reflect.DeepEqual(got, expected) {
+		t.Errorf("expected %q got %q", expected, got)
+	}
+}
+
+func TestGranularPrecedence(t *testing.T) {
+	cLog := ConfigLogger{Level: INFO, Granulars: map[string]Level{
+		"github.com/me/app":                  WARNING,
+		"github.com/me/app/internal":         DEBUG,
+		"github.com/me/app/internal.Handler": ERROR,
+	}}
+	for funcPath, expected := range map[string]Level{
+		"github.com/me/app.main":                  WARNING,
+		"github.com/me/app/internal.Other":        DEBUG,
+		"github.com/me/app/internal.(*T).Method":  DEBUG,
+		"github.com/me/app/internal.Handler":      ERROR,
+		"github.com/me/app/internal/deeper.Thing": NONE, // no granular, the logger level applies
+	} {
+		rec := &LogRecord{FuncPath: funcPath, PackagePath: splitPackage(funcPath)}
+		_, lvl, ok := cLog.granularFor(rec)
+		if expected == NONE {
+			if ok {
+				t.Errorf("%s: expected no granular, got %v", funcPath, lvl)
+			}
+		} else if !ok || lvl != expected {
+			t.Errorf("%s: expected %v got %v", funcPath, expected, lvl)
+		}
+	}
+}
+
+func TestSplitPackage(t *testing.T) {
+	for in, expected := range map[string]string{
+		"hi.Zoot":                       "hi",
+		"github.com/me/app.(*T).Method": "github.com/me/app",
+		"github.com/me/app/v2.main":     "github.com/me/app/v2",
+		"main.main.func1":               "main",
+	} {
+		if got := splitPackage(in); got != expected {
+			t.Errorf("%s: expected %s got %s", in, expected, got)
+		}
+	}
+}
+
+func TestNullFilter(t *testing.T) {
+	log := NewTimber()
+	StrictConfig(true)
+	defer StrictConfig(false)
+	if err := log.LoadJSONConfigString(`{"Filters": [{"Enabled": true, "Type": "null", "Level": "INFO"}]}`); err != nil {
+		t.Error(err)
+	}
+	log.Info("into the void")
+	log.Close()
+}
+
+func TestFileReopen(t *testing.T) {
+	dir := t.TempDir()
+	name := dir + "/reopen.log"
+	fw, err := NewFileWriterInterval(name, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	log := NewTimber()
+	log.AddLogger(ConfigLogger{LogWriter: fw, Level: DEBUG, Formatter: NewPatFormatter("%M")})
+	log.Info("before")
+	// what logrotate does
+	if err := os.Rename(name, name+".1"); err != nil {
+		t.Fatal(err)
+	}
+	log.Reopen()
+	log.Info("after")
+	log.Flush()
+	log.Close()
+
+	if b, _ := os.ReadFile(name + ".1"); string(b) != "before\n" {
+		t.Errorf("moved file has %q", b)
+	}
+	if b, _ := os.ReadFile(name); string(b) != "after\n" {
+		t.Errorf("reopened file has %q", b)
+	}
+}
+
+func TestEnabledEnv(t *testing.T) {
+	filter := JSONFilter{Properties: []JSONProperty{{Name: "enabled_env", Value: "TIMBER_TEST_ENABLE"}}}
+	if filter.enabled() {
+		t.Error("unset variable should leave the filter disabled")
+	}
+	t.Setenv("TIMBER_TEST_ENABLE", "true")
+	if !filter.enabled() {
+		t.Error("expected the variable to enable the filter")
+	}
+	filter.Enabled = true
+	t.Setenv("TIMBER_TEST_ENABLE", "0")
+	if filter.enabled() {
+		t.Error("expected the variable to disable the filter")
+	}
+	t.Setenv("TIMBER_TEST_ENABLE", "maybe")
+	if !filter.enabled() {
+		t.Error("a non-boolean value should be ignored")
+	}
+}
+
+func TestSetDispatchBuffer(t *testing.T) {
+	log := NewTimber()
+	mw := NewMemoryWriter(100)
+	log.AddLogger(ConfigLogger{LogWriter: mw, Level: DEBUG, Formatter: NewPatFormatter("%M")})
+	var expected []string
+	for i, size := range []int{0, 5, DefaultDispatchBuffer} {
+		log.SetDispatchBuffer(size)
+		for j := 0; j < 10; j++ {
+			msg := fmt.Sprintf("%d-%d", i, j)
+			log.Info(msg)
+			expected = append(expected, msg)
+		}
+	}
+	log.Close()
+	log.SetDispatchBuffer(1) // no-op once closed
+	if got := mw.Messages(); !reflect.DeepEqual(got, expected) {
+		t.Errorf("expected %q got %q", expected, got)
+	}
+}
+
+func TestSetLevelFor(t *testing.T) {
+	log := NewTimber()
+	mw := NewMemoryWriter(10)
+	log.AddLogger(ConfigLogger{LogWriter: mw, Level: INFO, Formatter: NewPatFormatter("%M"), Tag: "mem"})
+	if err := log.SetLevelFor("nope", DEBUG, time.Minute); err == nil {
+		t.Error("expected an error for an unknown tag")
+	}
+	if err := log.SetLevelFor("mem", FINE, time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	// replaces the hour, still reverts to INFO
+	if err := log.SetLevelFor("mem", DEBUG, 20*time.Millisecond); err != nil {
+		t.Fatal(err)
+	}
+	log.Debug("bumped")
+	time.Sleep(100 * time.Millisecond)
+	log.Debug("reverted")
+	log.Close()
+	expected := []string{"bumped"}
+	if got := mw.Messages(); !reflect.DeepEqual(got, expected) {
+		t.Errorf("expected %q got %q", expected, got)
+	}
+}
+
+func TestRuntimeLevels(t *testing.T) {
+	log := NewTimber()
+	log.FileDepth = DefaultFileDepth - 1 // called on log directly, not through Global
+	mw := NewMemoryWriter(10)
+	log.AddLogger(ConfigLogger{LogWriter: mw, Level: INFO, Formatter: NewPatFormatter("%M"), Tag: "mem"})
+	log.Debug("too low")
+	log.SetLevel(0, DEBUG)
+	log.SetLevel(5, FINEST) // nothing there
+	log.Debug("set level")
+	log.Fine("too fine")
+	if err := log.SetGranular("github.com/smw1218/timber", FINE); err != nil {
+		t.Fatal(err)
+	}
+	log.Fine("set granular")
+	expectedLevels := []LoggerLevels{{Tag: "mem", Level: DEBUG, Granulars: map[string]Level{"github.com/smw1218/timber": FINE}}}
+	if got := log.Levels(); !reflect.DeepEqual(got, expectedLevels) {
+		t.Errorf("expected %v got %v", expectedLevels, got)
+	}
+	log.Close()
+	expected := []string{"set level", "set granular"}
+	if got := mw.Messages(); !reflect.DeepEqual(got, expected) {
+		t.Errorf("expected %q got %q", expected, got)
+	}
+	if err := log.SetGranular("x", DEBUG); err == nil {
+		t.Error("expected an error once closed")
+	}
+	if got := log.Levels(); got != nil {
+		t.Errorf("expected no levels once closed, got %v", got)
+	}
+}
+
+// The benchmarks log through a whole Timber, dispatch goroutine included,
+// so allocs/op counts everything a log call costs.  Disabled and
+// message-only should stay at 0 allocs/op.
+
+func TestCallerInfo(t *testing.T) {
+	log := NewTimber()
+	// DefaultFileDepth is for the package functions, which add a frame
+	log.FileDepth = DefaultFileDepth - 1
+	mw := NewMemoryWriter(10)
+	log.AddLogger(ConfigLogger{LogWriter: mw, Level: INFO, Formatter: NewPatFormatter("%x %P %p")})
+	log.Info("here")
+	func() { log.Warn("closure") }()
+	log.Close()
+	expected := []string{
+		"timber_test github.com/smw1218/timber.TestCallerInfo github.com/smw1218/timber",
+		"timber_test github.com/smw1218/timber.TestCallerInfo.func1 github.com/smw1218/timber",
+	}
+	if got := mw.Messages(); !reflect.DeepEqual(got, expected) {
+		t.Errorf("expected %q got %q", expected, got)
+	}
+}
+
+func TestHotPathAllocs(t *testing.T) {
+	log := NewTimber()
+	defer log.Close()
+	log.AddLogger(ConfigLogger{LogWriter: NullWriter{}, Level: INFO, Formatter: NewPatFormatter("%M")})
+	if allocs := testing.AllocsPerRun(100, func() { log.Debug("not logged") }); allocs != 0 {
+		t.Errorf("disabled level: %v allocs", allocs)
+	}
+	pf := NewPatFormatter("msg: %M!")
+	if allocs := testing.AllocsPerRun(100, func() { pf.FormatTo(NullWriter{}, lr) }); allocs != 0 {
+		t.Errorf("message only format: %v allocs", allocs)
+	}
+	verify(t, "msg: %M!", pf.Format(lr), "msg: hellooooo nurse!!")
+}
+
+type countingStringer struct{ calls *int }
+
+func (c countingStringer) String() string {
+	*c.calls++
+	return "expensive"
+}
+
+func TestLazyArguments(t *testing.T) {
+	mw := NewMemoryWriter(10)
+	log := NewTimber()
+	log.AddLogger(ConfigLogger{LogWriter: mw, Level: INFO, Formatter: NewPatFormatter("%M")})
+	calls := 0
+	lazy := func() string { calls++; return "dump" }
+	args := []interface{}{lazy, countingStringer{&calls}}
+	log.Debug("skipped %s %s", args...)
+	log.Debug(lazy)
+	if calls != 0 {
+		t.Errorf("expected nothing evaluated below the level, got %d calls", calls)
+	}
+	log.Info("state %s %v", args...)
+	log.Info(lazy)
+	log.Info(func() string { return "%d%%" }, 100)
+	log.Info(func() interface{} { return 42 })
+	log.GetLogger("named").Info("n=%v", func() interface{} { return 7 })
+	log.Close()
+	if calls != 3 {
+		t.Errorf("expected 3 calls got %d", calls)
+	}
+	if _, ok := args[0].(func() string); !ok {
+		t.Error("expected the caller's args left alone")
+	}
+	expected := []string{"state dump expensive", "dump", "100%", "42", "n=7"}
+	if got := mw.Messages(); !reflect.DeepEqual(got, expected) {
+		t.Errorf("expected %q got %q", expected, got)
+	}
+}
+
+func benchTimber(b *testing.B, format LogFormatter) *Timber {
+	log := NewTimber()
+	log.AddLogger(ConfigLogger{LogWriter: NullWriter{}, Level: INFO, Formatter: format})
+	b.Cleanup(log.Close)
+	b.ReportAllocs()
+	b.ResetTimer()
+	return log
+}
+
+func BenchmarkDisabledLevel(b *testing.B) {
+	log := benchTimber(b, NewPatFormatter("%M"))
+	for i := 0; i < b.N; i++ {
+		log.Debug("not logged")
+	}
+}
+
+func BenchmarkDisabledLazy(b *testing.B) {
+	log := benchTimber(b, NewPatFormatter("%M"))
+	state := func() string { return strings.Repeat("x", 100) }
+	for i := 0; i < b.N; i++ {
+		log.Debug("state %s", state)
+	}
+}
+
+func BenchmarkEnabledGuard(b *testing.B) {
+	log := benchTimber(b, NewPatFormatter("%M"))
+	for i := 0; i < b.N; i++ {
+		if log.DebugEnabled() {
+			b.Fatal("expected DEBUG off")
+		}
+	}
+}
+
+func BenchmarkMessageOnly(b *testing.B) {
+	log := benchTimber(b, NewPatFormatter("%M"))
+	for i := 0; i < b.N; i++ {
+		log.Info("just the message")
+	}
+	log.Flush()
+}
+
+func BenchmarkFullPattern(b *testing.B) {
+	log := benchTimber(b, NewPatFormatter("[%D %T] [%L] (%S) %P %M"))
+	for i := 0; i < b.N; i++ {
+		log.Info("with %s", "source")
+	}
+	log.Flush()
+}
+
+func BenchmarkFields(b *testing.B) {
+	b.Cleanup(RegisterContextExtractor(func(ctx context.Context) Fields {
+		if id, ok := ctx.Value(testCtxKey("bench")).(string); ok {
+			return Fields{TraceIDField: id}
+		}
+		return nil
+	}))
+	ctx := context.WithValue(context.Background(), testCtxKey("bench"), "4bf92f35")
+	log := benchTimber(b, NewJSONFormatter())
+	for i := 0; i < b.N; i++ {
+		log.InfoContext(ctx, "structured")
+	}
+	log.Flush()
+}
+
+func TestGranularPatterns(t *testing.T) {
+	cLog := ConfigLogger{Level: INFO, Granulars: map[string]Level{
+		"github.com/acme/svc/...":              WARNING,
+		"github.com/acme/svc/internal/...":     FINE,
+		"github.com/acme/svc/internal/db":      ERROR,
+		"github.com/acme/*/cache":              CRITICAL,
+		"github.com/acme/.../gen":              FINEST,
+		`re:\.\(\*Server\)\.Handle[A-Z]`:       DEBUG,
+		`re:^github.com/other/.*\.init`:        TRACE,
+		"github.com/acme/svc/api.(*Server).Ok": INFO,
+	}}
+	for funcPath, expected := range map[string]Level{
+		"github.com/acme/svc.main":                      WARNING,  // the prefix takes the package itself
+		"github.com/acme/svc/api.Serve":                 WARNING,  // and its sub-packages
+		"github.com/acme/svc/internal/queue.Pop":        FINE,     // the longer prefix wins
+		"github.com/acme/svc/internal/db.Query":         ERROR,    // exact beats every prefix
+		"github.com/acme/svc/internal/db/sql.Scan":      FINE,     // exact doesn't take sub-packages
+		"github.com/acme/svcx.main":                     NONE,     // a prefix stops at a path element
+		"github.com/acme/web/cache.Get":                 CRITICAL, // glob on the package
+		"github.com/acme/a/b/gen.New":                   FINEST,
+		"github.com/other/api.(*Server).HandleGet":      DEBUG, // regexp on the function
+		"github.com/other/api.(*Server).handle":         NONE,
+		"github.com/other/boot.init":                    TRACE,
+		"github.com/acme/svc/api.(*Server).HandleGet":   WARNING, // prefixes beat the regexps
+		"github.com/acme/svc/api.(*Server).Ok":          INFO,
+		"github.com/acme/web/cache/redis.(*Pool).Close": NONE, // * doesn't cross a slash
+	} {
+		rec := &LogRecord{FuncPath: funcPath, PackagePath: splitPackage(funcPath)}
+		_, lvl, ok := cLog.granularFor(rec)
+		if expected == NONE {
+			if ok {
+				t.Errorf("%s: expected no granular, got %v", funcPath, lvl)
+			}
+		} else if !ok || lvl != expected {
+			t.Errorf("%s: expected %v got %v %v", funcPath, expected, lvl, ok)
+		}
+	}
+
+	log := NewTimber()
+	log.FileDepth = DefaultFileDepth - 1 // called directly, not through the package functions
+	mw := NewMemoryWriter(10)
+	log.AddLogger(ConfigLogger{LogWriter: mw, Level: WARNING, Formatter: NewPatFormatter("%L %M")})
+	if err := log.SetGranular("re:(", DEBUG); err == nil {
+		t.Error("expected an error for a bad regexp")
+	}
+	if err := log.SetGranular("github.com/smw1218/...", DEBUG); err != nil {
+		t.Fatal(err)
+	}
+	if !log.DebugEnabled() {
+		t.Error("expected the pattern to enable DEBUG")
+	}
+	log.Debug("heard")
+	log.Fine("not heard")
+	log.Close()
+	expected := []string{"DEBG heard"}
+	if got := mw.Messages(); !reflect.DeepEqual(got, expected) {
+		t.Errorf("expected %q got %q", expected, got)
+	}
+}
+
+func TestSetGlobal(t *testing.T) {
+	first, second := NewMemoryWriter(10), NewMemoryWriter(10)
+	replacement := NewTimber()
+	replacement.AddLogger(ConfigLogger{LogWriter: first, Level: INFO, Formatter: NewPatFormatter("%M")})
+	original := SetGlobal(replacement)
+	defer func() { SetGlobal(original).Close() }()
+	if GetGlobal() != replacement || Global != replacement {
+		t.Fatal("expected the replacement to be the Global logger")
+	}
+	Info("one")
+	next := NewTimber()
+	next.AddLogger(ConfigLogger{LogWriter: second, Level: INFO, Formatter: NewPatFormatter("%M")})
+	SetGlobal(next).Close()
+	Info("two")
+	WithFields(Fields{"k": "v"}).Info("three")
+	Flush()
+	if got, expected := first.Messages(), []string{"one"}; !reflect.DeepEqual(got, expected) {
+		t.Errorf("expected %q got %q", expected, got)
+	}
+	if got, expected := second.Messages(), []string{"two", "three"}; !reflect.DeepEqual(got, expected) {
+		t.Errorf("expected %q got %q", expected, got)
+	}
+	if fresh := SetGlobal(nil); fresh != next || GetGlobal() == nil || GetGlobal() == next {
+		t.Errorf("expected nil to put in a new Timber")
+	}
+	next.Close()
+}
+
+// Log calls, AddLogger, reloads and Close on two instances and swaps of
+// the Global logger all at once; run with -race
+func TestConcurrentInstances(t *testing.T) {
+	original := GetGlobal()
+	defer SetGlobal(original)
+	access, app := NewTimber(), NewTimber()
+	accessLog := NewMemoryWriter(10000)
+	access.AddLogger(ConfigLogger{LogWriter: accessLog, Level: INFO, Formatter: NewPatFormatter("%M"), Tag: "access"})
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 200; j++ {
+				access.Info("GET /%d", j)
+				app.WithFields(Fields{"worker": i}).Warn("working")
+				Info("global")
+			}
+		}(i)
+	}
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for j := 0; j < 20; j++ {
+			app.AddLogger(ConfigLogger{LogWriter: NewMemoryWriter(10), Level: INFO, Formatter: NewPatFormatter("%L %M"), Tag: "app"})
+			app.LoadJSONConfigBytes([]byte(`{"Filters": [{"Enabled": true, "Tag": "null", "Type": "null", "Level": "DEBUG"}]}`))
+			app.SetDispatchBuffer(j % 3)
+			app.RemoveLogger("null")
+			g := NewTimber()
+			g.AddLogger(ConfigLogger{LogWriter: NullWriter{}, Level: INFO, Formatter: NewPatFormatter("%M")})
+			SetGlobal(g).Close()
+		}
+		app.Close()
+	}()
+	wg.Wait()
+	GetGlobal().Close()
+	// closing app and the Global loggers left access alone
+	access.Info("after")
+	if idx := access.AddLogger(ConfigLogger{LogWriter: NullWriter{}, Level: INFO, Formatter: NewPatFormatter("%M")}); idx < 0 {
+		t.Errorf("expected access to take loggers after the others closed")
+	}
+	access.Close()
+	if got := len(accessLog.Messages()); got != 4*200+1 {
+		t.Errorf("expected every access line, got %d", got)
+	}
+}
+
+func TestPanicln(t *testing.T) {
+	log := NewTimber()
+	mw := NewMemoryWriter(10)
+	log.AddLogger(ConfigLogger{LogWriter: mw, Level: NONE, Formatter: NewPatFormatter("%M")})
+	func() {
+		defer func() {
+			if r := recover(); r != "out of coffee" {
+				t.Errorf("expected the logged message as the panic, got %q", r)
+			}
+		}()
+		log.Panicln("out of", "coffee")
+	}()
+	log.Close()
+	if got := mw.Messages(); !reflect.DeepEqual(got, []string{"out of coffee"}) {
+		t.Errorf("expected the message without the newline, got %q", got)
+	}
+}
@@ -0,0 +1,185 @@
+// Package timbertest captures what a timber logger writes so a test can
+// check it without a file to parse.  Install adds a Recorder to the
+// Global logger for the length of a test:
+//
+//	func TestRetry(t *testing.T) {
+//		timbertest.Install(t)
+//		retry(flakyCall)
+//		timbertest.AssertLogged(t, timber.WARNING, "retrying")
+//	}
+//
+// The Recorder keeps the records themselves, so fields, levels and the
+// source are there to look at as well as the formatted message.  It's
+// added as one more logger at FINEST with a tag of its own and taken out
+// again when the test ends, so the loggers that were already there keep
+// going and nothing races with the goroutine that writes the records.
+// Tests that call t.Parallel share Global and each Recorder sees all of
+// their records; give those a Timber of their own and use Attach.
+package timbertest
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/smw1218/timber"
+)
+
+// The pattern the Recorder's messages are formatted with, see Messages
+const Format = "%L %M"
+
+// A timber LogWriter that keeps every record written to it
+type Recorder struct {
+	mu      sync.Mutex
+	records []timber.LogRecord
+	msgs    []string
+	log     *timber.Timber // flushed before reading, nil when not attached
+}
+
+func NewRecorder() *Recorder {
+	return new(Recorder)
+}
+
+var (
+	installs  int64
+	mu        sync.Mutex
+	installed = make(map[testing.TB]*Recorder)
+)
+
+// Adds a Recorder to log until tb and its subtests are done, then takes
+// it out again
+func Attach(tb testing.TB, log *timber.Timber) *Recorder {
+	tb.Helper()
+	r := NewRecorder()
+	tag := fmt.Sprintf("timbertest %s %d", tb.Name(), atomic.AddInt64(&installs, 1))
+	if log.AddLogger(timber.ConfigLogger{LogWriter: r, Level: timber.FINEST, Tag: tag,
+		Formatter: timber.NewPatFormatter(Format)}) < 0 {
+		tb.Fatalf("timbertest: can't add a recorder to the logger")
+	}
+	r.mu.Lock()
+	r.log = log
+	r.mu.Unlock()
+	tb.Cleanup(func() {
+		log.RemoveLogger(tag)
+		r.mu.Lock()
+		r.log = nil
+		r.mu.Unlock()
+	})
+	return r
+}
+
+// Attach for the Global logger.  The package's Assert functions use the
+// Recorder installed for tb.
+func Install(tb testing.TB) *Recorder {
+	tb.Helper()
+	r := Attach(tb, timber.GetGlobal())
+	mu.Lock()
+	installed[tb] = r
+	mu.Unlock()
+	tb.Cleanup(func() {
+		mu.Lock()
+		delete(installed, tb)
+		mu.Unlock()
+	})
+	return r
+}
+
+func (r *Recorder) LogWrite(msg string) {
+	r.LogWriteRecord(&timber.LogRecord{Message: msg}, msg)
+}
+
+func (r *Recorder) LogWriteRecord(rec *timber.LogRecord, msg string) {
+	r.mu.Lock()
+	r.records = append(r.records, *rec)
+	r.msgs = append(r.msgs, msg)
+	r.mu.Unlock()
+}
+
+func (r *Recorder) Close() {
+	// Nothing, the records stay available
+}
+
+// Waits for the records already logged to reach the Recorder
+func (r *Recorder) flush() {
+	r.mu.Lock()
+	log := r.log
+	r.mu.Unlock()
+	if log != nil {
+		log.Flush()
+	}
+}
+
+// A copy of the records, oldest first
+func (r *Recorder) Records() []timber.LogRecord {
+	r.flush()
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]timber.LogRecord(nil), r.records...)
+}
+
+// The records as formatted by the logger, Format when Attach added it
+func (r *Recorder) Messages() []string {
+	r.flush()
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]string(nil), r.msgs...)
+}
+
+// Forgets the records so far
+func (r *Recorder) Reset() {
+	r.flush()
+	r.mu.Lock()
+	r.records, r.msgs = nil, nil
+	r.mu.Unlock()
+}
+
+// The first record at lvl with substr in its message
+func (r *Recorder) Find(lvl timber.Level, substr string) (timber.LogRecord, bool) {
+	for _, rec := range r.Records() {
+		if rec.Level == lvl && strings.Contains(rec.Message, substr) {
+			return rec, true
+		}
+	}
+	return timber.LogRecord{}, false
+}
+
+// Fails tb unless a record at lvl has substr in its message
+func (r *Recorder) AssertLogged(tb testing.TB, lvl timber.Level, substr string) {
+	tb.Helper()
+	if _, ok := r.Find(lvl, substr); !ok {
+		tb.Errorf("timbertest: nothing logged at %s with %q, got %q", timber.LongLevelStrings[lvl], substr, r.Messages())
+	}
+}
+
+// Fails tb if a record at lvl has substr in its message
+func (r *Recorder) AssertNotLogged(tb testing.TB, lvl timber.Level, substr string) {
+	tb.Helper()
+	if rec, ok := r.Find(lvl, substr); ok {
+		tb.Errorf("timbertest: logged at %s with %q: %q", timber.LongLevelStrings[lvl], substr, rec.Message)
+	}
+}
+
+// Recorder.AssertLogged for the Recorder Install added for tb
+func AssertLogged(tb testing.TB, lvl timber.Level, substr string) {
+	tb.Helper()
+	recorder(tb).AssertLogged(tb, lvl, substr)
+}
+
+// Recorder.AssertNotLogged for the Recorder Install added for tb
+func AssertNotLogged(tb testing.TB, lvl timber.Level, substr string) {
+	tb.Helper()
+	recorder(tb).AssertNotLogged(tb, lvl, substr)
+}
+
+func recorder(tb testing.TB) *Recorder {
+	tb.Helper()
+	mu.Lock()
+	r := installed[tb]
+	mu.Unlock()
+	if r == nil {
+		tb.Fatalf("timbertest: Install wasn't called for %s", tb.Name())
+	}
+	return r
+}
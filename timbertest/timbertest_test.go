@@ -0,0 +1,65 @@
+package timbertest
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/smw1218/timber"
+)
+
+func TestInstall(t *testing.T) {
+	var r *Recorder
+	t.Run("installed", func(t *testing.T) {
+		r = Install(t)
+		timber.Warnw("retrying", timber.Fields{"attempt": 2})
+		timber.Debug("dialing %s", "db")
+		AssertLogged(t, timber.WARNING, "retry")
+		AssertLogged(t, timber.DEBUG, "dialing db")
+		AssertNotLogged(t, timber.ERROR, "retry")
+		rec, ok := r.Find(timber.WARNING, "retrying")
+		if !ok || rec.Fields["attempt"] != 2 || !strings.HasSuffix(rec.SourceFile, "timbertest_test.go") {
+			t.Errorf("expected the record with its fields and source got %+v", rec)
+		}
+		if got := r.Messages(); len(got) != 2 || got[0] != "WARN retrying" {
+			t.Errorf("expected the formatted messages got %q", got)
+		}
+		r.Reset()
+		if len(r.Records()) != 0 {
+			t.Error("expected no records after Reset")
+		}
+	})
+	timber.Info("after the test")
+	timber.Flush()
+	if len(r.Records()) != 0 {
+		t.Error("expected the recorder removed after the test")
+	}
+	for _, levels := range timber.Levels() {
+		if strings.HasPrefix(levels.Tag, "timbertest") {
+			t.Errorf("expected the recorder's logger gone, got %+v", levels)
+		}
+	}
+}
+
+type fakeTB struct {
+	testing.TB
+	failed []string
+}
+
+func (f *fakeTB) Helper() {}
+func (f *fakeTB) Errorf(format string, args ...interface{}) {
+	f.failed = append(f.failed, format)
+}
+
+func TestAttach(t *testing.T) {
+	log := timber.NewTimber()
+	defer log.Close()
+	r := Attach(t, log)
+	log.Error("disk full")
+	tb := &fakeTB{TB: t}
+	r.AssertLogged(tb, timber.ERROR, "disk")
+	r.AssertLogged(tb, timber.ERROR, "network")
+	r.AssertNotLogged(tb, timber.ERROR, "full")
+	if len(tb.failed) != 2 {
+		t.Errorf("expected the last two asserts to fail, got %d failures", len(tb.failed))
+	}
+}
@@ -0,0 +1,94 @@
+package timber
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// Tells the ids of the span a context is in, so records logged with the
+// context can be joined with the trace.  timber doesn't import a tracing
+// library; for OpenTelemetry:
+//
+//	timber.RegisterTraceProvider(timber.TraceProviderFunc(func(ctx context.Context) (string, string, bool) {
+//		sc := trace.SpanContextFromContext(ctx)
+//		return sc.TraceID().String(), sc.SpanID().String(), sc.IsValid()
+//	}))
+type TraceProvider interface {
+	SpanIDs(ctx context.Context) (traceID, spanID string, ok bool)
+}
+
+// Adapts a function to a TraceProvider
+type TraceProviderFunc func(ctx context.Context) (traceID, spanID string, ok bool)
+
+func (f TraceProviderFunc) SpanIDs(ctx context.Context) (string, string, bool) {
+	return f(ctx)
+}
+
+// Puts the ids from provider in the TraceIDField and SpanIDField of every
+// record logged with a context, the ...Context methods, FromContext and
+// the slog handler.  They print with %r and %n, or %{trace_id} and
+// %{span_id}, and the JSON and logfmt formatters write them like any
+// field.  Fields from NewContext and ContextWithTrace win over the
+// provider's.  The returned func removes the provider again.
+func RegisterTraceProvider(provider TraceProvider) (unregister func()) {
+	return RegisterContextExtractor(func(ctx context.Context) Fields {
+		traceID, spanID, ok := provider.SpanIDs(ctx)
+		if !ok {
+			return nil
+		}
+		return traceFields(traceID, spanID)
+	})
+}
+
+func traceFields(traceID, spanID string) Fields {
+	fields := Fields{TraceIDField: traceID}
+	if spanID != "" {
+		fields[SpanIDField] = spanID
+	}
+	return fields
+}
+
+// Returns a context that logs traceID and spanID, for code that gets the
+// ids without a tracer, e.g. from a message header.  An empty spanID is
+// left out.
+func ContextWithTrace(ctx context.Context, traceID, spanID string) context.Context {
+	return NewContext(ctx, traceFields(traceID, spanID))
+}
+
+// ContextWithTrace with the ids of a W3C traceparent header,
+// 00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01.  An error if
+// the header isn't one, ctx is returned as it is then.
+func ContextWithTraceparent(ctx context.Context, traceparent string) (context.Context, error) {
+	parts := strings.Split(strings.TrimSpace(traceparent), "-")
+	if len(parts) < 4 || len(parts[0]) != 2 || parts[0] == "ff" || (parts[0] == "00" && len(parts) != 4) ||
+		!isTraceHex(parts[1], 32) || !isTraceHex(parts[2], 16) || !isTraceHex(parts[0], 2) || !isTraceHex(parts[3], 2) {
+		return ctx, fmt.Errorf("TIMBER! Not a traceparent header: %q", traceparent)
+	}
+	return ContextWithTrace(ctx, parts[1], parts[2]), nil
+}
+
+// n lowercase hex digits, not all zero
+func isTraceHex(s string, n int) bool {
+	if len(s) != n {
+		return false
+	}
+	zero := true
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if (c < '0' || c > '9') && (c < 'a' || c > 'f') {
+			return false
+		}
+		zero = zero && c == '0'
+	}
+	return !zero || n == 2
+}
+
+// The trace and span ids records logged with ctx get, from the providers,
+// the extractors and the context's fields; false if there's no trace id
+func TraceFromContext(ctx context.Context) (traceID, spanID string, ok bool) {
+	fields := fieldsFromContext(ctx)
+	traceID, _ = fields[TraceIDField].(string)
+	spanID, _ = fields[SpanIDField].(string)
+	return traceID, spanID, traceID != ""
+}
@@ -0,0 +1,60 @@
+package timber
+
+import (
+	"context"
+	"reflect"
+	"testing"
+)
+
+type spanKey struct{}
+
+func TestTraceProvider(t *testing.T) {
+	t.Cleanup(RegisterTraceProvider(TraceProviderFunc(func(ctx context.Context) (string, string, bool) {
+		span, ok := ctx.Value(spanKey{}).([2]string)
+		return span[0], span[1], ok
+	})))
+	log := NewTimber()
+	mw := NewMemoryWriter(10)
+	log.AddLogger(ConfigLogger{LogWriter: mw, Level: DEBUG, Formatter: NewPatFormatter("%{trace_id}/%{span_id} %M")})
+	inSpan := context.WithValue(context.Background(), spanKey{}, [2]string{"4bf92f35", "00f067aa"})
+	log.InfoContext(inSpan, "in a span")
+	log.InfoContext(context.Background(), "no span")
+	log.FromContext(ContextWithTrace(inSpan, "beef", "")).Info("the context wins")
+	log.Close()
+	expected := []string{"4bf92f35/00f067aa in a span", "/ no span", "beef/00f067aa the context wins"}
+	if got := mw.Messages(); !reflect.DeepEqual(got, expected) {
+		t.Errorf("expected %q got %q", expected, got)
+	}
+	if traceID, spanID, ok := TraceFromContext(inSpan); !ok || traceID != "4bf92f35" || spanID != "00f067aa" {
+		t.Errorf("unexpected ids %q %q %v", traceID, spanID, ok)
+	}
+	if _, _, ok := TraceFromContext(context.Background()); ok {
+		t.Error("expected no trace")
+	}
+}
+
+func TestContextWithTraceparent(t *testing.T) {
+	ctx, err := ContextWithTraceparent(context.Background(), "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if traceID, spanID, _ := TraceFromContext(ctx); traceID != "4bf92f3577b34da6a3ce929d0e0e4736" || spanID != "00f067aa0ba902b7" {
+		t.Errorf("unexpected ids %q %q", traceID, spanID)
+	}
+	if _, err := ContextWithTraceparent(context.Background(), "01-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-future"); err != nil {
+		t.Errorf("expected a later version to parse: %v", err)
+	}
+	for _, bad := range []string{
+		"",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7",
+		"00-00000000000000000000000000000000-00f067aa0ba902b7-01",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-0000000000000000-01",
+		"00-4BF92F3577B34DA6A3CE929D0E0E4736-00f067aa0ba902b7-01",
+		"ff-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-extra",
+	} {
+		if _, err := ContextWithTraceparent(context.Background(), bad); err == nil {
+			t.Errorf("expected an error for %q", bad)
+		}
+	}
+}
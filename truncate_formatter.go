@@ -0,0 +1,41 @@
+package timber
+
+import (
+	"fmt"
+	"unicode/utf8"
+)
+
+// Cuts the output of another formatter down to MaxLength bytes and marks
+// what was dropped, e.g. "start of a huge blob…(truncated 5012 bytes)".
+// The cut never splits a multibyte character so the result may be a few
+// bytes short of MaxLength; the marker isn't counted.  Zero or less
+// leaves the output alone.
+type TruncateFormatter struct {
+	Formatter LogFormatter
+	MaxLength int
+}
+
+func NewTruncateFormatter(formatter LogFormatter, maxLength int) *TruncateFormatter {
+	return &TruncateFormatter{Formatter: formatter, MaxLength: maxLength}
+}
+
+// LogFormatter interface
+func (tf *TruncateFormatter) Format(rec *LogRecord) string {
+	return truncate(tf.Formatter.Format(rec), tf.MaxLength)
+}
+
+// SourceUser interface
+func (tf *TruncateFormatter) UsesSource() bool {
+	return formatterUsesSource(tf.Formatter)
+}
+
+func truncate(msg string, max int) string {
+	if max <= 0 || len(msg) <= max {
+		return msg
+	}
+	cut := max
+	for cut > 0 && !utf8.RuneStart(msg[cut]) {
+		cut--
+	}
+	return fmt.Sprintf("%s…(truncated %d bytes)", msg[:cut], len(msg)-cut)
+}
@@ -0,0 +1,433 @@
+package timber
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"strings"
+	"time"
+)
+
+// How a WireWriter encodes records
+type WireFormat byte
+
+const (
+	WireGob      WireFormat = 'G' // encoding/gob of a WireRecord
+	WireProtobuf WireFormat = 'P' // protocol buffers, the schema is with appendProtoRecord
+)
+
+func (format WireFormat) String() string {
+	switch format {
+	case WireGob:
+		return "gob"
+	case WireProtobuf:
+		return "protobuf"
+	}
+	return fmt.Sprintf("WireFormat(%d)", byte(format))
+}
+
+// Parses the wire property: gob or protobuf
+func ParseWireFormat(s string) (WireFormat, error) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "gob":
+		return WireGob, nil
+	case "protobuf", "proto":
+		return WireProtobuf, nil
+	}
+	return 0, fmt.Errorf("TIMBER! Unknown wire %q, expected gob or protobuf", s)
+}
+
+// The biggest frame a LogReceiver takes, a bigger one ends the connection
+const MaxWireFrame = 16 << 20
+
+// The parts of a LogRecord that go over the wire.  Field values are kept
+// as strings, integers, floats and bools; anything else, durations,
+// times and errors included, is sent as its string.
+type WireRecord struct {
+	Level       Level
+	Timestamp   time.Time
+	SourceFile  string
+	SourceLine  int
+	Message     string
+	FuncPath    string
+	PackagePath string
+	LoggerName  string
+	Fields      map[string]interface{}
+}
+
+func newWireRecord(rec *LogRecord) *WireRecord {
+	wr := &WireRecord{Level: rec.Level, Timestamp: rec.Timestamp, SourceFile: rec.SourceFile,
+		SourceLine: rec.SourceLine, Message: rec.Message, FuncPath: rec.FuncPath,
+		PackagePath: rec.PackagePath, LoggerName: rec.LoggerName}
+	if len(rec.Fields) > 0 {
+		wr.Fields = make(map[string]interface{}, len(rec.Fields))
+		for key, val := range rec.Fields {
+			if key != FormatField {
+				wr.Fields[key] = wireValue(val)
+			}
+		}
+	}
+	return wr
+}
+
+func wireValue(val interface{}) interface{} {
+	switch v := val.(type) {
+	case string, bool, int64, float64:
+		return v
+	case int:
+		return int64(v)
+	case int8:
+		return int64(v)
+	case int16:
+		return int64(v)
+	case int32:
+		return int64(v)
+	case uint8:
+		return int64(v)
+	case uint16:
+		return int64(v)
+	case uint32:
+		return int64(v)
+	case uint:
+		if uint64(v) <= math.MaxInt64 {
+			return int64(v)
+		}
+	case uint64:
+		if v <= math.MaxInt64 {
+			return int64(v)
+		}
+	case float32:
+		return float64(v)
+	case time.Time:
+		return v.Format(time.RFC3339Nano)
+	case error:
+		return v.Error()
+	case nil:
+		return ""
+	}
+	return fmt.Sprint(val)
+}
+
+// The record the receiving end logs, ready for the pipeline
+func (wr *WireRecord) logRecord() *LogRecord {
+	rec := &LogRecord{Level: wr.Level, Timestamp: wr.Timestamp, SourceFile: wr.SourceFile,
+		SourceLine: wr.SourceLine, Message: wr.Message, FuncPath: wr.FuncPath,
+		PackagePath: wr.PackagePath, LoggerName: wr.LoggerName}
+	if len(wr.Fields) > 0 {
+		rec.Fields = Fields(wr.Fields)
+	}
+	return rec
+}
+
+// A frame is the format byte, the payload's length as 4 bytes big endian
+// and the payload
+func appendWireFrame(buf []byte, format WireFormat, wr *WireRecord) ([]byte, error) {
+	start := len(buf)
+	buf = append(buf, byte(format), 0, 0, 0, 0)
+	switch format {
+	case WireGob:
+		// each frame has its own encoder so it decodes on its own, after
+		// a reconnect or with frames lost
+		var b bytes.Buffer
+		if err := gob.NewEncoder(&b).Encode(wr); err != nil {
+			return buf[:start], fmt.Errorf("TIMBER! Can't encode the record: %v", err)
+		}
+		buf = append(buf, b.Bytes()...)
+	case WireProtobuf:
+		buf = appendProtoRecord(buf, wr)
+	default:
+		return buf[:start], fmt.Errorf("TIMBER! Unknown wire format %q", byte(format))
+	}
+	binary.BigEndian.PutUint32(buf[start+1:], uint32(len(buf)-start-5))
+	return buf, nil
+}
+
+// Reads the next frame from r
+func readWireFrame(r io.Reader, buf []byte) (*WireRecord, []byte, error) {
+	var header [5]byte
+	if _, err := io.ReadFull(r, header[:]); err != nil {
+		return nil, buf, err
+	}
+	n := binary.BigEndian.Uint32(header[1:])
+	if n > MaxWireFrame {
+		return nil, buf, fmt.Errorf("TIMBER! Wire frame of %d bytes is too big", n)
+	}
+	if cap(buf) < int(n) {
+		buf = make([]byte, n)
+	}
+	buf = buf[:n]
+	if _, err := io.ReadFull(r, buf); err != nil {
+		return nil, buf, err
+	}
+	wr := new(WireRecord)
+	var err error
+	switch WireFormat(header[0]) {
+	case WireGob:
+		err = gob.NewDecoder(bytes.NewReader(buf)).Decode(wr)
+	case WireProtobuf:
+		err = parseProtoRecord(buf, wr)
+	default:
+		return nil, buf, fmt.Errorf("TIMBER! Unknown wire format %q", header[0])
+	}
+	if err == nil && (wr.Level < NONE || wr.Level > CRITICAL) {
+		err = fmt.Errorf("level %d", wr.Level)
+	}
+	if err != nil {
+		return nil, buf, fmt.Errorf("TIMBER! Bad wire frame: %v", err)
+	}
+	return wr, buf, nil
+}
+
+// WireProtobuf encodes a WireRecord as this message, so anything with
+// the schema can read and write it:
+//
+//	message LogRecord {
+//	  sint32 level = 1;
+//	  int64 timestamp_unix_nano = 2;
+//	  string source_file = 3;
+//	  int32 source_line = 4;
+//	  string message = 5;
+//	  string func_path = 6;
+//	  string package_path = 7;
+//	  string logger_name = 8;
+//	  map<string, Value> fields = 9;
+//	}
+//	message Value {
+//	  oneof kind {
+//	    string string_value = 1;
+//	    sint64 int_value = 2;
+//	    double double_value = 3;
+//	    bool bool_value = 4;
+//	  }
+//	}
+const (
+	protoVarint  = 0
+	protoFixed64 = 1
+	protoBytes   = 2
+	protoFixed32 = 5
+)
+
+func appendProtoTag(buf []byte, field, wireType int) []byte {
+	return binary.AppendUvarint(buf, uint64(field<<3|wireType))
+}
+
+func appendProtoString(buf []byte, field int, s string) []byte {
+	if s == "" {
+		return buf
+	}
+	buf = appendProtoTag(buf, field, protoBytes)
+	buf = binary.AppendUvarint(buf, uint64(len(s)))
+	return append(buf, s...)
+}
+
+func appendProtoVarint(buf []byte, field int, v uint64) []byte {
+	if v == 0 {
+		return buf
+	}
+	return binary.AppendUvarint(appendProtoTag(buf, field, protoVarint), v)
+}
+
+func zigzag(v int64) uint64 {
+	return uint64(v<<1) ^ uint64(v>>63)
+}
+
+func unzigzag(v uint64) int64 {
+	return int64(v>>1) ^ -int64(v&1)
+}
+
+// Appends a length delimited field with what fn appends
+func appendProtoMessage(buf []byte, field int, fn func(buf []byte) []byte) []byte {
+	body := fn(nil)
+	buf = appendProtoTag(buf, field, protoBytes)
+	buf = binary.AppendUvarint(buf, uint64(len(body)))
+	return append(buf, body...)
+}
+
+func appendProtoRecord(buf []byte, wr *WireRecord) []byte {
+	buf = appendProtoVarint(buf, 1, zigzag(int64(wr.Level)))
+	if !wr.Timestamp.IsZero() {
+		buf = appendProtoVarint(buf, 2, uint64(wr.Timestamp.UnixNano()))
+	}
+	buf = appendProtoString(buf, 3, wr.SourceFile)
+	buf = appendProtoVarint(buf, 4, uint64(int64(wr.SourceLine)))
+	buf = appendProtoString(buf, 5, wr.Message)
+	buf = appendProtoString(buf, 6, wr.FuncPath)
+	buf = appendProtoString(buf, 7, wr.PackagePath)
+	buf = appendProtoString(buf, 8, wr.LoggerName)
+	for key, val := range wr.Fields {
+		buf = appendProtoMessage(buf, 9, func(entry []byte) []byte {
+			entry = appendProtoString(entry, 1, key)
+			return appendProtoMessage(entry, 2, func(value []byte) []byte {
+				return appendProtoValue(value, val)
+			})
+		})
+	}
+	return buf
+}
+
+func appendProtoValue(buf []byte, val interface{}) []byte {
+	switch v := val.(type) {
+	case int64:
+		buf = appendProtoTag(buf, 2, protoVarint)
+		return binary.AppendUvarint(buf, zigzag(v))
+	case float64:
+		buf = appendProtoTag(buf, 3, protoFixed64)
+		return binary.LittleEndian.AppendUint64(buf, math.Float64bits(v))
+	case bool:
+		buf = appendProtoTag(buf, 4, protoVarint)
+		if v {
+			return append(buf, 1)
+		}
+		return append(buf, 0)
+	}
+	s, _ := val.(string)
+	buf = appendProtoTag(buf, 1, protoBytes)
+	buf = binary.AppendUvarint(buf, uint64(len(s)))
+	return append(buf, s...)
+}
+
+var errProtoTruncated = errors.New("truncated protobuf")
+
+// Calls fn with each field of a message: its number, wire type, the
+// value of a varint or fixed field and the bytes of a length delimited one
+func parseProto(buf []byte, fn func(field, wireType int, v uint64, b []byte) error) error {
+	for len(buf) > 0 {
+		tag, n := binary.Uvarint(buf)
+		if n <= 0 {
+			return errProtoTruncated
+		}
+		buf = buf[n:]
+		field, wireType := int(tag>>3), int(tag&7)
+		var v uint64
+		var b []byte
+		switch wireType {
+		case protoVarint:
+			if v, n = binary.Uvarint(buf); n <= 0 {
+				return errProtoTruncated
+			}
+			buf = buf[n:]
+		case protoFixed64:
+			if len(buf) < 8 {
+				return errProtoTruncated
+			}
+			v, buf = binary.LittleEndian.Uint64(buf), buf[8:]
+		case protoFixed32:
+			if len(buf) < 4 {
+				return errProtoTruncated
+			}
+			v, buf = uint64(binary.LittleEndian.Uint32(buf)), buf[4:]
+		case protoBytes:
+			size, n := binary.Uvarint(buf)
+			if n <= 0 || uint64(len(buf)-n) < size {
+				return errProtoTruncated
+			}
+			b, buf = buf[n:n+int(size)], buf[n+int(size):]
+		default:
+			return fmt.Errorf("unsupported protobuf wire type %d", wireType)
+		}
+		if err := fn(field, wireType, v, b); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func parseProtoRecord(buf []byte, wr *WireRecord) error {
+	return parseProto(buf, func(field, wireType int, v uint64, b []byte) error {
+		switch field {
+		case 1:
+			wr.Level = Level(unzigzag(v))
+		case 2:
+			wr.Timestamp = time.Unix(0, int64(v))
+		case 3:
+			wr.SourceFile = string(b)
+		case 4:
+			wr.SourceLine = int(int64(v))
+		case 5:
+			wr.Message = string(b)
+		case 6:
+			wr.FuncPath = string(b)
+		case 7:
+			wr.PackagePath = string(b)
+		case 8:
+			wr.LoggerName = string(b)
+		case 9:
+			var key string
+			var val interface{} = ""
+			err := parseProto(b, func(field, wireType int, v uint64, b []byte) error {
+				switch field {
+				case 1:
+					key = string(b)
+				case 2:
+					return parseProto(b, func(field, wireType int, v uint64, b []byte) error {
+						switch field {
+						case 1:
+							val = string(b)
+						case 2:
+							val = unzigzag(v)
+						case 3:
+							val = math.Float64frombits(v)
+						case 4:
+							val = v != 0
+						}
+						return nil
+					})
+				}
+				return nil
+			})
+			if err != nil {
+				return err
+			}
+			if wr.Fields == nil {
+				wr.Fields = make(map[string]interface{})
+			}
+			wr.Fields[key] = val
+		}
+		return nil
+	})
+}
+
+// Sends each record over a SocketWriter as a frame of the full record,
+// gob or protobuf encoded, instead of formatted text, so a LogReceiver
+// at the other end can log it again with its fields, level and source
+// intact.  The formatter isn't used.  Use a stream socket, tcp, tls or
+// unix; a compressed one works too.  The socket's fallback file doesn't
+// keep the frames apart, use a reconnect buffer to ride out an outage.
+type WireWriter struct {
+	socket *SocketWriter
+	format WireFormat
+}
+
+// socket's framing is turned off, the frames carry their own lengths
+func NewWireWriter(socket *SocketWriter, format WireFormat) *WireWriter {
+	socket.SetFraming("")
+	return &WireWriter{socket: socket, format: format}
+}
+
+// A message without a record goes out as an INFO record
+func (ww *WireWriter) LogWrite(msg string) {
+	ww.LogStructured(&LogRecord{Level: INFO, Message: msg, Timestamp: currentTime()})
+}
+
+// StructuredWriter interface.  Only an encoding error comes back, a
+// socket that fails reports it itself.
+func (ww *WireWriter) LogStructured(rec *LogRecord) error {
+	frame, err := appendWireFrame(nil, ww.format, newWireRecord(rec))
+	if err != nil {
+		return err
+	}
+	ww.socket.Write(frame)
+	return nil
+}
+
+func (ww *WireWriter) Flush() {
+	ww.socket.Flush()
+}
+
+func (ww *WireWriter) Close() {
+	ww.socket.Close()
+}
@@ -0,0 +1,58 @@
+package timber
+
+import (
+	"bytes"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWireRoundTrip(t *testing.T) {
+	now := time.Date(2024, 3, 1, 12, 30, 0, 123456789, time.UTC)
+	rec := &LogRecord{Level: WARNING, Timestamp: now, SourceFile: "/src/app/main.go", SourceLine: 42,
+		Message: "disk nearly full", FuncPath: "app/main.check", PackagePath: "app/main", LoggerName: "app.disk",
+		Fields: Fields{"free": 12, "pct": 0.5, "ok": false, "path": "/var", "took": 2 * time.Second,
+			"err": errors.New("no space"), "neg": int64(-7), FormatField: "skipped"}}
+	expected := map[string]interface{}{"free": int64(12), "pct": 0.5, "ok": false, "path": "/var",
+		"took": "2s", "err": "no space", "neg": int64(-7)}
+	for _, format := range []WireFormat{WireGob, WireProtobuf} {
+		frame, err := appendWireFrame(nil, format, newWireRecord(rec))
+		if err != nil {
+			t.Fatalf("%v: %v", format, err)
+		}
+		wr, _, err := readWireFrame(bytes.NewReader(frame), nil)
+		if err != nil {
+			t.Fatalf("%v: %v", format, err)
+		}
+		got := wr.logRecord()
+		if got.Level != rec.Level || !got.Timestamp.Equal(now) || got.SourceFile != rec.SourceFile ||
+			got.SourceLine != rec.SourceLine || got.Message != rec.Message || got.FuncPath != rec.FuncPath ||
+			got.PackagePath != rec.PackagePath || got.LoggerName != rec.LoggerName {
+			t.Errorf("%v: expected %+v got %+v", format, rec, got)
+		}
+		if !reflect.DeepEqual(map[string]interface{}(got.Fields), expected) {
+			t.Errorf("%v: expected fields %v got %v", format, expected, got.Fields)
+		}
+	}
+}
+
+func TestWireBadFrames(t *testing.T) {
+	good, _ := appendWireFrame(nil, WireProtobuf, &WireRecord{Level: INFO, Message: "hi"})
+	badLevel, _ := appendWireFrame(nil, WireProtobuf, &WireRecord{Level: CRITICAL + 3})
+	frames := map[string][]byte{
+		"unknown format": append([]byte{'X'}, good[1:]...),
+		"too big":        {'P', 0xff, 0xff, 0xff, 0xff},
+		"truncated":      append([]byte{'P', 0, 0, 0, 2}, 0x2a, 0x05),
+		"bad level":      badLevel,
+	}
+	for name, frame := range frames {
+		if _, _, err := readWireFrame(bytes.NewReader(frame), nil); err == nil || !strings.HasPrefix(err.Error(), "TIMBER!") {
+			t.Errorf("%s: expected an error, got %v", name, err)
+		}
+	}
+	if _, err := ParseWireFormat("xml"); err == nil {
+		t.Errorf("expected an error for an unknown wire")
+	}
+}
@@ -0,0 +1,56 @@
+package timber
+
+import (
+	"time"
+)
+
+// How long a logger has spent formatting and writing records, collected
+// for loggers added with TimeWrites set.  A slow disk or a stalling socket
+// shows up as a high Max or Avg.
+type WriteStats struct {
+	Count int64
+	Min   time.Duration
+	Max   time.Duration
+	Total time.Duration
+}
+
+func (ws WriteStats) Avg() time.Duration {
+	if ws.Count == 0 {
+		return 0
+	}
+	return ws.Total / time.Duration(ws.Count)
+}
+
+// only touched by the dispatch goroutine
+func (ws *WriteStats) record(start time.Time) {
+	d := time.Since(start)
+	if ws.Count == 0 || d < ws.Min {
+		ws.Min = d
+	}
+	if d > ws.Max {
+		ws.Max = d
+	}
+	ws.Count++
+	ws.Total += d
+}
+
+// Returns the stats of each logger, in the order they were added (the
+// index AddLogger returns).  Loggers without TimeWrites have zero stats.
+// Returns nil once the Timber is closed.
+func (t *Timber) WriteStats() []WriteStats {
+	statsChan := make(chan []WriteStats, 1)
+	if !t.configure(timberConfig{Action: actionStats, Stats: statsChan}) {
+		return nil
+	}
+	return <-statsChan
+}
+
+func collectStats(cls []ConfigLogger) []WriteStats {
+	stats := make([]WriteStats, len(cls))
+	for i, cLog := range cls {
+		if cLog.stats != nil {
+			stats[i] = *cLog.stats
+		}
+	}
+	return stats
+}